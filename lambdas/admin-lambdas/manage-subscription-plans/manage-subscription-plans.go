@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-xray-sdk-go/instrumentation/awsv2"
+	"github.com/aws/aws-xray-sdk-go/xray"
+	companylib "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/company-lib"
+)
+
+type Service struct {
+	ctx    context.Context
+	logger *log.Logger
+
+	planSVC *companylib.SubscriptionPlanService
+}
+
+func main() {
+	ctx, root := xray.BeginSegment(context.TODO(), "manage-subscription-plans")
+	defer root.Close(nil)
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		log.Fatalf("Cannot load config: %v\n", err)
+	}
+
+	awsv2.AWSV2Instrumentor(&cfg.APIOptions)
+
+	logger := log.New(os.Stdout, "", log.LstdFlags)
+	ddbclient := dynamodb.NewFromConfig(cfg)
+
+	planSVC := companylib.CreateSubscriptionPlanService(ctx, ddbclient, logger)
+	planSVC.SubscriptionPlansTable = os.Getenv("SUBSCRIPTION_PLANS_TABLE")
+	// Admins expect to see their own changes straight away
+	planSVC.CacheTTL = 0
+
+	svc := Service{
+		ctx:    ctx,
+		logger: logger,
+
+		planSVC: planSVC,
+	}
+
+	lambda.Start(svc.handleAPIRequests)
+}
+
+var RESP_HEADERS = map[string]string{
+	"Access-Control-Allow-Origin":  "*",
+	"Access-Control-Allow-Methods": "*",
+	"Access-Control-Allow-Headers": "X-Amz-Date,X-Api-Key,X-Amz-Security-Token,X-Requested-With,X-Auth-Token,Referer,User-Agent,Origin,Content-Type,Authorization,Accept,Access-Control-Allow-Methods,Access-Control-Allow-Origin,Access-Control-Allow-Headers",
+}
+
+func (svc *Service) handleAPIRequests(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	svc.ctx = ctx
+
+	switch request.HTTPMethod {
+
+	case "GET":
+		return svc.GETRequestHandler(request)
+	case "POST":
+		return svc.POSTRequestHandler(request)
+	case "PUT":
+		return svc.PUTRequestHandler(request)
+	case "DELETE":
+		return svc.DELETERequestHandler(request)
+	case "OPTIONS":
+		return events.APIGatewayProxyResponse{StatusCode: 200, Headers: RESP_HEADERS}, nil
+
+	default:
+		return jsonResponse(405, map[string]string{"error": "HTTP Method Not Support for this endpoint"}), nil
+	}
+}
+
+/*
+GET returns the plan catalogue.
+
+	?planId=professional      -> every version of the plan, oldest first
+	?includeRetired=true      -> latest version of every plan, including retired plans
+*/
+func (svc *Service) GETRequestHandler(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	if planID := request.QueryStringParameters["planId"]; planID != "" {
+		versions, err := svc.planSVC.GetPlanVersions(planID)
+		if err != nil {
+			return svc.errorResponse(err), nil
+		}
+		return jsonResponse(200, map[string]interface{}{"planId": planID, "versions": versions}), nil
+	}
+
+	plans, err := svc.planSVC.ListPlans(request.QueryStringParameters["includeRetired"] == "true")
+	if err != nil {
+		return svc.errorResponse(err), nil
+	}
+
+	return jsonResponse(200, map[string]interface{}{"plans": plans, "count": len(plans)}), nil
+}
+
+/*
+POST creates a new plan as version 1:
+
+	{
+		"planId": "team",
+		"planName": "Team Plan",
+		"planDescription": "...",
+		"maxTeams": 10,
+		"maxMembers": 60,
+		"displayOrder": 2,
+		"prices": { "AUD": { "monthly": 49.99, "yearly": 499.99 }, "USD": { "monthly": 34.99, "yearly": 349.99 } },
+		"features": ["10 teams", "60 members"],
+		"featureFlags": { "analytics_dashboard": true }
+	}
+*/
+func (svc *Service) POSTRequestHandler(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	var reqBody companylib.SubscriptionPlanInput
+	if err := json.Unmarshal([]byte(request.Body), &reqBody); err != nil {
+		svc.logger.Printf("Unable to Unmarshal the request body. Req Body string: %v", request.Body)
+		return jsonResponse(400, map[string]string{"error": "Invalid request body"}), nil
+	}
+
+	plan, err := svc.planSVC.CreatePlan(reqBody, adminUserName(request))
+	if err != nil {
+		return svc.errorResponse(err), nil
+	}
+
+	return jsonResponse(201, plan), nil
+}
+
+/*
+PUT publishes a new version of an existing plan. The body is the same as POST and replaces the
+plan definition; organizations on earlier versions keep their price and limits.
+*/
+func (svc *Service) PUTRequestHandler(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	var reqBody companylib.SubscriptionPlanInput
+	if err := json.Unmarshal([]byte(request.Body), &reqBody); err != nil {
+		svc.logger.Printf("Unable to Unmarshal the request body. Req Body string: %v", request.Body)
+		return jsonResponse(400, map[string]string{"error": "Invalid request body"}), nil
+	}
+	if reqBody.PlanID == "" {
+		return jsonResponse(400, map[string]string{"error": "planId is required"}), nil
+	}
+
+	plan, err := svc.planSVC.PublishPlanVersion(reqBody.PlanID, reqBody, adminUserName(request))
+	if err != nil {
+		return svc.errorResponse(err), nil
+	}
+
+	return jsonResponse(200, plan), nil
+}
+
+/*
+DELETE retires a plan (?planId=...). Plan versions are never deleted because organizations may
+still be billed at them.
+*/
+func (svc *Service) DELETERequestHandler(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	planID := request.QueryStringParameters["planId"]
+	if planID == "" {
+		return jsonResponse(400, map[string]string{"error": "planId is required"}), nil
+	}
+
+	if err := svc.planSVC.RetirePlan(planID, adminUserName(request)); err != nil {
+		return svc.errorResponse(err), nil
+	}
+
+	return jsonResponse(200, map[string]string{"message": "Subscription plan retired", "planId": planID}), nil
+}
+
+// adminUserName returns the signed in admin from the Cognito authorizer claims
+func adminUserName(request events.APIGatewayProxyRequest) string {
+	if claims, ok := request.RequestContext.Authorizer["claims"].(map[string]interface{}); ok {
+		for _, claim := range []string{"email", "cognito:username"} {
+			if value, ok := claims[claim].(string); ok && value != "" {
+				return value
+			}
+		}
+	}
+	return "unknown"
+}
+
+// errorResponse maps catalogue errors to HTTP status codes
+func (svc *Service) errorResponse(err error) events.APIGatewayProxyResponse {
+	svc.logger.Printf("Subscription plan request failed: %v", err)
+
+	switch {
+	case errors.Is(err, companylib.ErrSubscriptionPlanNotFound):
+		return jsonResponse(404, map[string]string{"error": err.Error()})
+	case errors.Is(err, companylib.ErrSubscriptionPlanExists), errors.Is(err, companylib.ErrSubscriptionPlanRetired):
+		return jsonResponse(409, map[string]string{"error": err.Error()})
+	case strings.HasPrefix(err.Error(), "invalid"):
+		return jsonResponse(400, map[string]string{"error": err.Error()})
+	default:
+		return jsonResponse(500, map[string]string{"error": "Internal server error"})
+	}
+}
+
+func jsonResponse(statusCode int, body interface{}) events.APIGatewayProxyResponse {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return events.APIGatewayProxyResponse{StatusCode: 500, Headers: RESP_HEADERS}
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: statusCode,
+		Body:       string(jsonData),
+		Headers:    RESP_HEADERS,
+	}
+}
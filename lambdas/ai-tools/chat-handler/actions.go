@@ -0,0 +1,511 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbTypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+
+	ctrl "github.com/busyfit-admin/saas-integrated-apis/lambdas/ai-tools/controllers"
+	awsclients "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/clients"
+)
+
+// Tools that change data never run inside the converse loop. Calling one only records a pending
+// action, which is returned to the UI in ChatResponse.PendingActions. The action runs when the user
+// confirms it with a follow-up request carrying its actionId and idempotencyKey; repeating that
+// request returns the first outcome instead of running the action again.
+
+// pendingActionWindow is how long a proposed action can be confirmed.
+const pendingActionWindow = 30 * time.Minute
+
+// Pending actions and their audit records share the chat's partition in the chat history table.
+// Message keys start with a zero-padded timestamp, so they sort before both prefixes, and
+// loadChatHistory reads only keys below actionKeyPrefix, the lower of the two.
+const (
+	actionKeyPrefix = "ACTION#"
+	auditKeyPrefix  = "AUDIT#"
+)
+
+const (
+	actionStatusPending    = "pending"
+	actionStatusProcessing = "processing"
+	actionStatusExecuted   = "executed"
+	actionStatusFailed     = "failed"
+	actionStatusCancelled  = "cancelled"
+)
+
+const (
+	actionDecisionConfirm = "confirm"
+	actionDecisionCancel  = "cancel"
+)
+
+var (
+	// errActionNotFound is returned for unknown actions and for actions proposed to someone else.
+	errActionNotFound = errors.New("action not found")
+	// errActionNotPending is returned when an action was already decided, is being executed or has expired.
+	errActionNotPending = errors.New("action is no longer pending")
+	errInvalidDecision  = errors.New(`decision must be "confirm" or "cancel"`)
+)
+
+// actionSpec describes a tool that changes data.
+type actionSpec struct {
+	// selfOnly actions always act as the caller, even for managers.
+	selfOnly bool
+	// check validates and normalises the authorised input before the action is proposed.
+	check func(in map[string]interface{}, chatCtx ChatContext) error
+	// describe returns the one-line summary the user confirms.
+	describe func(in map[string]interface{}) string
+	execute  toolExecutorFn
+}
+
+// actionRegistry maps mutating tool names to their specs. Each also needs a toolPolicy.
+var actionRegistry = map[string]actionSpec{
+	"create_task": {
+		selfOnly: true,
+		check:    checkCreateTask,
+		describe: describeCreateTask,
+		execute:  execCreateTask,
+	},
+	"log_task_time": {
+		selfOnly: true,
+		check:    checkLogTaskTime,
+		describe: func(in map[string]interface{}) string {
+			return fmt.Sprintf("Log %g hours on %s", getNumber(in, "hours"), getStr(in, "taskId"))
+		},
+		execute: execLogTaskTime,
+	},
+	"add_goal_comment": {
+		check:    checkAddGoalComment,
+		describe: describeAddGoalComment,
+		execute:  execAddGoalComment,
+	},
+	"request_feedback": {
+		selfOnly: true,
+		check:    checkRequestFeedback,
+		describe: func(in map[string]interface{}) string {
+			return fmt.Sprintf("Ask %s for feedback: %q", getStr(in, "toUsername"), getStr(in, "message"))
+		},
+		execute: execRequestFeedback,
+	},
+	"give_kudos": {
+		selfOnly: true,
+		check:    checkGiveKudos,
+		describe: func(in map[string]interface{}) string {
+			return fmt.Sprintf("Give %s kudos on the team feed: %q", getStr(in, "recipientUsername"), getStr(in, "message"))
+		},
+		execute: execGiveKudos,
+	},
+}
+
+// pendingActionRecord is the chat history item for a proposed action.
+// PK = chatId, SK = ACTION#{actionId}.
+type pendingActionRecord struct {
+	ChatID         string                 `dynamodbav:"chatId"`
+	MsgKey         string                 `dynamodbav:"msgKey"`
+	ActionID       string                 `dynamodbav:"actionId"`
+	IdempotencyKey string                 `dynamodbav:"idempotencyKey"`
+	Tool           string                 `dynamodbav:"tool"`
+	Input          map[string]interface{} `dynamodbav:"input"`
+	Summary        string                 `dynamodbav:"summary"`
+	Status         string                 `dynamodbav:"status"`
+	UserName       string                 `dynamodbav:"userName"`
+	UserID         string                 `dynamodbav:"userId"`
+	CreatedAt      string                 `dynamodbav:"createdAt"`
+	ConfirmBy      string                 `dynamodbav:"confirmBy"`
+	DecidedAt      string                 `dynamodbav:"decidedAt,omitempty"`
+	Result         string                 `dynamodbav:"result,omitempty"` // JSON
+	Error          string                 `dynamodbav:"error,omitempty"`
+	ExpiresAt      int64                  `dynamodbav:"expiresAt"` // Unix seconds TTL
+}
+
+// actionAuditRecord is written once per decided action and has no TTL.
+// PK = chatId, SK = AUDIT#{epoch_millis_padded}#{actionId}.
+type actionAuditRecord struct {
+	ChatID     string                 `dynamodbav:"chatId"`
+	MsgKey     string                 `dynamodbav:"msgKey"`
+	ActionID   string                 `dynamodbav:"actionId"`
+	Tool       string                 `dynamodbav:"tool"`
+	Input      map[string]interface{} `dynamodbav:"input"`
+	Summary    string                 `dynamodbav:"summary"`
+	Outcome    string                 `dynamodbav:"outcome"` // executed | failed | cancelled
+	UserName   string                 `dynamodbav:"userName"`
+	UserID     string                 `dynamodbav:"userId"`
+	ProposedAt string                 `dynamodbav:"proposedAt"`
+	DecidedAt  string                 `dynamodbav:"decidedAt"`
+	Result     string                 `dynamodbav:"result,omitempty"`
+	Error      string                 `dynamodbav:"error,omitempty"`
+}
+
+// actionStore records and decides pending actions for one chat on behalf of one caller.
+type actionStore struct {
+	ctx    context.Context
+	ddb    awsclients.DynamodbClient
+	table  string
+	chatID string
+	caller string
+	userID string
+
+	// pending collects the actions proposed during this request.
+	pending []PendingAction
+}
+
+func newActionStore(ctx context.Context, ddb awsclients.DynamodbClient, table, chatID, callerUserName, callerCognitoID string) *actionStore {
+	return &actionStore{ctx: ctx, ddb: ddb, table: table, chatID: chatID, caller: callerUserName, userID: callerCognitoID}
+}
+
+// propose validates an authorised call to a mutating tool and stores it as a pending action.
+// The returned text tells the model that nothing has changed yet.
+func (s *actionStore) propose(toolName string, input map[string]interface{}, access toolAccess, chatCtx ChatContext) string {
+	spec := actionRegistry[toolName]
+	if spec.selfOnly && !access.self {
+		return jsonStr(map[string]interface{}{"error": fmt.Sprintf("%v: %s can only act for the current user", errToolDenied, toolName)})
+	}
+	if err := spec.check(input, chatCtx); err != nil {
+		return jsonStr(map[string]interface{}{"error": err.Error()})
+	}
+
+	now := time.Now().UTC()
+	actionID := uuid.NewString()
+	rec := pendingActionRecord{
+		ChatID:         s.chatID,
+		MsgKey:         actionKeyPrefix + actionID,
+		ActionID:       actionID,
+		IdempotencyKey: uuid.NewString(),
+		Tool:           toolName,
+		Input:          input,
+		Summary:        spec.describe(input),
+		Status:         actionStatusPending,
+		UserName:       s.caller,
+		UserID:         s.userID,
+		CreatedAt:      now.Format(time.RFC3339),
+		ConfirmBy:      now.Add(pendingActionWindow).Format(time.RFC3339),
+		ExpiresAt:      now.Unix() + chatTTLSeconds,
+	}
+
+	item, err := attributevalue.MarshalMap(rec)
+	if err != nil {
+		return jsonStr(map[string]interface{}{"error": err.Error()})
+	}
+	if _, err := s.ddb.PutItem(s.ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(s.table),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(msgKey)"),
+	}); err != nil {
+		return jsonStr(map[string]interface{}{"error": fmt.Sprintf("could not record the proposed action: %v", err)})
+	}
+
+	s.pending = append(s.pending, PendingAction{
+		ActionID:       rec.ActionID,
+		IdempotencyKey: rec.IdempotencyKey,
+		Tool:           rec.Tool,
+		Summary:        rec.Summary,
+		Input:          rec.Input,
+		ConfirmBy:      rec.ConfirmBy,
+	})
+	return jsonStr(map[string]interface{}{
+		"status":   "awaiting_confirmation",
+		"actionId": rec.ActionID,
+		"summary":  rec.Summary,
+		"note":     "Nothing has been changed yet. Tell the user what will happen and that they need to confirm it.",
+	})
+}
+
+// claim moves a pending action to processing so only one request can decide it. It fails with
+// errActionNotPending when the action is not pending, belongs to someone else, carries another
+// idempotency key or has expired; callers then load it to tell those cases apart.
+func (s *actionStore) claim(actionID, idempotencyKey string, now time.Time) (*pendingActionRecord, error) {
+	out, err := s.ddb.UpdateItem(s.ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(s.table),
+		Key:                 s.actionKey(actionID),
+		UpdateExpression:    aws.String("SET #status = :processing, decidedAt = :now"),
+		ConditionExpression: aws.String("#status = :pending AND idempotencyKey = :key AND userName = :caller AND confirmBy > :now"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "status",
+		},
+		ExpressionAttributeValues: map[string]ddbTypes.AttributeValue{
+			":processing": &ddbTypes.AttributeValueMemberS{Value: actionStatusProcessing},
+			":pending":    &ddbTypes.AttributeValueMemberS{Value: actionStatusPending},
+			":key":        &ddbTypes.AttributeValueMemberS{Value: idempotencyKey},
+			":caller":     &ddbTypes.AttributeValueMemberS{Value: s.caller},
+			":now":        &ddbTypes.AttributeValueMemberS{Value: now.Format(time.RFC3339)},
+		},
+		ReturnValues: ddbTypes.ReturnValueAllNew,
+	})
+	if err != nil {
+		var ccf *ddbTypes.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			return nil, errActionNotPending
+		}
+		return nil, fmt.Errorf("claim action %s: %w", actionID, err)
+	}
+	var rec pendingActionRecord
+	if err := attributevalue.UnmarshalMap(out.Attributes, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// get loads an action the caller proposed with idempotencyKey, or returns errActionNotFound.
+func (s *actionStore) get(actionID, idempotencyKey string) (*pendingActionRecord, error) {
+	out, err := s.ddb.GetItem(s.ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.table),
+		Key:            s.actionKey(actionID),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("load action %s: %w", actionID, err)
+	}
+	if out.Item == nil {
+		return nil, errActionNotFound
+	}
+	var rec pendingActionRecord
+	if err := attributevalue.UnmarshalMap(out.Item, &rec); err != nil {
+		return nil, err
+	}
+	if rec.UserName != s.caller || rec.IdempotencyKey != idempotencyKey {
+		return nil, errActionNotFound
+	}
+	return &rec, nil
+}
+
+// finish stores the outcome of a claimed action together with its audit record.
+func (s *actionStore) finish(rec *pendingActionRecord, outcome, result, errMsg string, now time.Time) error {
+	rec.Status, rec.Result, rec.Error = outcome, result, errMsg
+
+	update := &ddbTypes.Update{
+		TableName:           aws.String(s.table),
+		Key:                 s.actionKey(rec.ActionID),
+		UpdateExpression:    aws.String("SET #status = :outcome, #result = :result, #error = :error"),
+		ConditionExpression: aws.String("#status = :processing"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "status",
+			"#result": "result",
+			"#error":  "error",
+		},
+		ExpressionAttributeValues: map[string]ddbTypes.AttributeValue{
+			":outcome":    &ddbTypes.AttributeValueMemberS{Value: outcome},
+			":result":     &ddbTypes.AttributeValueMemberS{Value: result},
+			":error":      &ddbTypes.AttributeValueMemberS{Value: errMsg},
+			":processing": &ddbTypes.AttributeValueMemberS{Value: actionStatusProcessing},
+		},
+	}
+	audit, err := attributevalue.MarshalMap(actionAuditRecord{
+		ChatID:     s.chatID,
+		MsgKey:     fmt.Sprintf("%s%020d#%s", auditKeyPrefix, now.UnixMilli(), rec.ActionID),
+		ActionID:   rec.ActionID,
+		Tool:       rec.Tool,
+		Input:      rec.Input,
+		Summary:    rec.Summary,
+		Outcome:    outcome,
+		UserName:   rec.UserName,
+		UserID:     rec.UserID,
+		ProposedAt: rec.CreatedAt,
+		DecidedAt:  now.Format(time.RFC3339),
+		Result:     result,
+		Error:      errMsg,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.ddb.TransactWriteItems(s.ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []ddbTypes.TransactWriteItem{
+			{Update: update},
+			{Put: &ddbTypes.Put{TableName: aws.String(s.table), Item: audit}},
+		},
+	})
+	return err
+}
+
+func (s *actionStore) actionKey(actionID string) map[string]ddbTypes.AttributeValue {
+	return map[string]ddbTypes.AttributeValue{
+		"chatId": &ddbTypes.AttributeValueMemberS{Value: s.chatID},
+		"msgKey": &ddbTypes.AttributeValueMemberS{Value: actionKeyPrefix + actionID},
+	}
+}
+
+// decideAction confirms or cancels a pending action. A confirmed action is authorised again
+// against its stored input before it runs. A repeated decision returns the stored outcome with
+// Replayed set.
+func decideAction(store *actionStore, authz *toolAuthorizer, chatCtx ChatContext, req ActionRequest) (*ActionResult, error) {
+	if req.Decision != actionDecisionConfirm && req.Decision != actionDecisionCancel {
+		return nil, errInvalidDecision
+	}
+
+	now := time.Now().UTC()
+	rec, err := store.claim(req.ActionID, req.IdempotencyKey, now)
+	if errors.Is(err, errActionNotPending) {
+		rec, err = store.get(req.ActionID, req.IdempotencyKey)
+		if err != nil {
+			return nil, err
+		}
+		switch rec.Status {
+		case actionStatusExecuted, actionStatusFailed, actionStatusCancelled:
+			result := actionResult(rec)
+			result.Replayed = true
+			return result, nil
+		}
+		return nil, errActionNotPending
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	outcome, result, errMsg := actionStatusCancelled, "", ""
+	if req.Decision == actionDecisionConfirm {
+		outcome = actionStatusExecuted
+		if _, err := authz.authorize(rec.Tool, rec.Input, chatCtx); err != nil {
+			outcome, errMsg = actionStatusFailed, err.Error()
+		} else if out, err := actionRegistry[rec.Tool].execute(rec.Input, authz.svc, chatCtx); err != nil {
+			outcome, errMsg = actionStatusFailed, err.Error()
+		} else {
+			result = jsonStr(out)
+		}
+	}
+
+	if err := store.finish(rec, outcome, result, errMsg, now); err != nil {
+		// The write, if any, has happened; the action stays in processing so it can't run twice.
+		authz.logger.Printf("actions: could not record outcome=%s action=%s chatId=%q: %v", outcome, rec.ActionID, store.chatID, err)
+	}
+	authz.logger.Printf("actions: %s tool=%s action=%s caller=%q", outcome, rec.Tool, rec.ActionID, store.caller)
+	return actionResult(rec), nil
+}
+
+func actionResult(rec *pendingActionRecord) *ActionResult {
+	res := &ActionResult{
+		ActionID: rec.ActionID,
+		Tool:     rec.Tool,
+		Summary:  rec.Summary,
+		Status:   rec.Status,
+		Error:    rec.Error,
+	}
+	if rec.Result != "" {
+		res.Result = json.RawMessage(rec.Result)
+	}
+	return res
+}
+
+// outcomeMessage is the assistant's reply to a decided action.
+func outcomeMessage(res *ActionResult) string {
+	switch res.Status {
+	case actionStatusExecuted:
+		return fmt.Sprintf("Done: %s.", res.Summary)
+	case actionStatusFailed:
+		return fmt.Sprintf("I couldn't complete \"%s\": %s", res.Summary, res.Error)
+	default:
+		return fmt.Sprintf("Cancelled: %s.", res.Summary)
+	}
+}
+
+// ==================== Action checks ====================
+
+func checkCreateTask(in map[string]interface{}, _ ChatContext) error {
+	if strings.TrimSpace(getStr(in, "title")) == "" {
+		return errors.New("title is required")
+	}
+	if _, ok := in["timeHours"]; ok {
+		hours, err := normalizeNumber(in, "timeHours")
+		if err != nil || hours < 0 {
+			return errors.New("timeHours must be a non-negative number")
+		}
+	}
+	return nil
+}
+
+func checkLogTaskTime(in map[string]interface{}, _ ChatContext) error {
+	if getStr(in, "taskId") == "" {
+		return errors.New("taskId is required")
+	}
+	hours, err := normalizeNumber(in, "hours")
+	if err != nil || hours <= 0 {
+		return errors.New("hours must be a positive number")
+	}
+	return nil
+}
+
+func checkAddGoalComment(in map[string]interface{}, _ ChatContext) error {
+	if getStr(in, "goalId") == "" || strings.TrimSpace(getStr(in, "text")) == "" {
+		return errors.New("goalId and text are required")
+	}
+	return nil
+}
+
+func checkRequestFeedback(in map[string]interface{}, chatCtx ChatContext) error {
+	to := getStr(in, "toUsername")
+	if to == "" || strings.TrimSpace(getStr(in, "message")) == "" {
+		return errors.New("toUsername and message are required")
+	}
+	if to == chatCtx.CallerUserName {
+		return errors.New("you cannot request feedback from yourself")
+	}
+	return nil
+}
+
+func checkGiveKudos(in map[string]interface{}, chatCtx ChatContext) error {
+	to := getStr(in, "recipientUsername")
+	if to == "" || strings.TrimSpace(getStr(in, "message")) == "" {
+		return errors.New("recipientUsername and message are required")
+	}
+	if strings.EqualFold(to, chatCtx.CallerUserName) {
+		return errors.New("you cannot give kudos to yourself")
+	}
+	return nil
+}
+
+func describeCreateTask(in map[string]interface{}) string {
+	summary := fmt.Sprintf("Create task %q", getStr(in, "title"))
+	if goalID := getStr(in, "goalId"); goalID != "" {
+		summary += " linked to goal " + goalID
+	}
+	if due := getStr(in, "dueDate"); due != "" {
+		summary += ", due " + due
+	}
+	return summary
+}
+
+func describeAddGoalComment(in map[string]interface{}) string {
+	return fmt.Sprintf("Comment on %s's goal %s: %q", getStr(in, "userName"), getStr(in, "goalId"), getStr(in, "text"))
+}
+
+// ==================== Action executors ====================
+
+func execCreateTask(in map[string]interface{}, svc *ctrl.Service, ctx ChatContext) (interface{}, error) {
+	return svc.CreateTask(ctx.CallerUserName, getStr(in, "teamId"), ctrl.NewTaskInput{
+		Title:       getStr(in, "title"),
+		Description: getStr(in, "description"),
+		Priority:    getStr(in, "priority"),
+		Status:      getStr(in, "status"),
+		GoalID:      getStr(in, "goalId"),
+		DueDate:     getStr(in, "dueDate"),
+		TimeHours:   getNumber(in, "timeHours"),
+	})
+}
+
+func execLogTaskTime(in map[string]interface{}, svc *ctrl.Service, ctx ChatContext) (interface{}, error) {
+	return svc.LogTaskTime(ctx.CallerUserName, getStr(in, "teamId"), getStr(in, "taskId"), getNumber(in, "hours"))
+}
+
+func execAddGoalComment(in map[string]interface{}, svc *ctrl.Service, ctx ChatContext) (interface{}, error) {
+	owner := withDefault(getStr(in, "userName"), ctx.CallerUserName)
+	role := "member"
+	if owner != ctx.CallerUserName {
+		role = "manager"
+	}
+	return svc.AddGoalComment(owner, getStr(in, "teamId"), getStr(in, "goalId"), ctx.CallerUserName, ctx.CallerDisplayName, role, getStr(in, "text"))
+}
+
+func execRequestFeedback(in map[string]interface{}, svc *ctrl.Service, ctx ChatContext) (interface{}, error) {
+	return svc.SendFeedbackRequest(ctx.CallerUserName, ctx.CallerDisplayName, getStr(in, "teamId"), getStr(in, "toUsername"), getStr(in, "message"), getStr(in, "dueDate"))
+}
+
+func execGiveKudos(in map[string]interface{}, svc *ctrl.Service, ctx ChatContext) (interface{}, error) {
+	return svc.GiveKudos(ctx.CallerUserName, ctx.CallerDisplayName, getStr(in, "teamId"), getStr(in, "recipientUsername"), getStr(in, "message"))
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodb_types "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+
+	ctrl "github.com/busyfit-admin/saas-integrated-apis/lambdas/ai-tools/controllers"
+	awsclients "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/clients"
+	companylib "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/company-lib"
+)
+
+func newTestActionStore(ddbClient *awsclients.MockDynamodbClient, caller string) *actionStore {
+	return newActionStore(context.TODO(), ddbClient, "ChatHistoryTable-test", "chat-1", caller, "sub-"+caller)
+}
+
+func actionItem(status, key string) map[string]dynamodb_types.AttributeValue {
+	item, _ := attributevalue.MarshalMap(pendingActionRecord{
+		ChatID: "chat-1", MsgKey: actionKeyPrefix + "act-1", ActionID: "act-1", IdempotencyKey: key,
+		Tool: "log_task_time", Input: map[string]interface{}{"taskId": "TASK-101", "hours": 3.0, "teamId": "TEAM#1", "userName": "ann"},
+		Summary: "Log 3 hours on TASK-101", Status: status, UserName: "ann", Result: `{"taskId":"TASK-101"}`,
+		ConfirmBy: time.Now().UTC().Add(time.Minute).Format(time.RFC3339),
+	})
+	return item
+}
+
+func TestProposeAction(t *testing.T) {
+	chatCtx := ChatContext{CallerUserName: "ann", CallerDisplayName: "Ann Lee", CallerTeamID: "TEAM#1"}
+
+	t.Run("It should record a pending action without changing anything", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			GetItemOutputs: []dynamodb.GetItemOutput{memberItem("TEAM#1", "ann", companylib.TeamMemberRoleMember)},
+			GetItemErrors:  []error{nil},
+			PutItemOutputs: []dynamodb.PutItemOutput{{}},
+			PutItemErrors:  []error{nil},
+		}
+		authz, _ := newTestAuthorizer(&ddbClient, "ann")
+		actions := newTestActionStore(&ddbClient, "ann")
+
+		out := runTool("log_task_time", map[string]interface{}{"taskId": "TASK-101", "hours": 3.0}, authz, actions, chatCtx)
+
+		assert.Contains(t, out, "awaiting_confirmation")
+		assert.Empty(t, ddbClient.UpdateItemInputs)
+		assert.Len(t, actions.pending, 1)
+		assert.Equal(t, "Log 3 hours on TASK-101", actions.pending[0].Summary)
+		assert.NotEmpty(t, actions.pending[0].IdempotencyKey)
+
+		var stored pendingActionRecord
+		attributevalue.UnmarshalMap(ddbClient.PutItemInputs[0].Item, &stored)
+		assert.Equal(t, "ChatHistoryTable-test", aws.ToString(ddbClient.PutItemInputs[0].TableName))
+		assert.Equal(t, actionStatusPending, stored.Status)
+		assert.Equal(t, "TEAM#1", stored.Input["teamId"])
+		assert.Equal(t, "ann", stored.UserName)
+	})
+
+	tests := []struct {
+		name     string
+		tool     string
+		input    map[string]interface{}
+		getItems []dynamodb.GetItemOutput
+		expected string
+	}{
+		{
+			name:     "It should not let a manager log time for a member",
+			tool:     "log_task_time",
+			input:    map[string]interface{}{"taskId": "TASK-101", "hours": 3.0, "userName": "bob"},
+			getItems: []dynamodb.GetItemOutput{memberItem("TEAM#1", "ann", companylib.TeamMemberRoleAdmin), memberItem("TEAM#1", "bob", companylib.TeamMemberRoleMember)},
+			expected: "can only act for the current user",
+		},
+		{
+			name:     "It should not write to a team the caller is not on",
+			tool:     "create_task",
+			input:    map[string]interface{}{"title": "Prepare QBR", "teamId": "TEAM#9"},
+			getItems: []dynamodb.GetItemOutput{{}},
+			expected: "not a member of team TEAM#9",
+		},
+		{
+			name:     "It should reject hours that are not positive",
+			tool:     "log_task_time",
+			input:    map[string]interface{}{"taskId": "TASK-101", "hours": -2.0},
+			getItems: []dynamodb.GetItemOutput{memberItem("TEAM#1", "ann", companylib.TeamMemberRoleMember)},
+			expected: "hours must be a positive number",
+		},
+		{
+			name:     "It should not ask the caller for their own feedback",
+			tool:     "request_feedback",
+			input:    map[string]interface{}{"toUsername": "ann", "message": "How did the demo go?"},
+			getItems: []dynamodb.GetItemOutput{memberItem("TEAM#1", "ann", companylib.TeamMemberRoleMember)},
+			expected: "cannot request feedback from yourself",
+		},
+		{
+			name:     "It should not let the caller give themselves kudos",
+			tool:     "give_kudos",
+			input:    map[string]interface{}{"recipientUsername": "Ann", "message": "Nice work"},
+			getItems: []dynamodb.GetItemOutput{memberItem("TEAM#1", "ann", companylib.TeamMemberRoleMember)},
+			expected: "cannot give kudos to yourself",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ddbClient := awsclients.MockDynamodbClient{
+				GetItemOutputs: test.getItems,
+				GetItemErrors:  make([]error, len(test.getItems)),
+			}
+			authz, _ := newTestAuthorizer(&ddbClient, "ann")
+			actions := newTestActionStore(&ddbClient, "ann")
+
+			out := runTool(test.tool, test.input, authz, actions, chatCtx)
+
+			assert.Contains(t, out, test.expected)
+			assert.Empty(t, ddbClient.PutItemInputs)
+			assert.Empty(t, actions.pending)
+		})
+	}
+}
+
+func TestDecideAction(t *testing.T) {
+	chatCtx := ChatContext{CallerUserName: "ann", CallerTeamID: "TEAM#1"}
+	confirm := ActionRequest{ActionID: "act-1", IdempotencyKey: "key-1", Decision: actionDecisionConfirm}
+	claimFailed := &dynamodb_types.ConditionalCheckFailedException{}
+
+	t.Run("It should run a confirmed action once and audit it", func(t *testing.T) {
+		task, _ := attributevalue.MarshalMap(ctrl.LinkedTaskRecord{TaskID: "TASK-101", Title: "Prepare QBR", TimeHours: 5})
+		ddbClient := awsclients.MockDynamodbClient{
+			UpdateItemOutputs: []dynamodb.UpdateItemOutput{
+				{Attributes: actionItem(actionStatusProcessing, "key-1")},
+				{Attributes: task},
+			},
+			UpdateItemErrors:         []error{nil, nil},
+			GetItemOutputs:           []dynamodb.GetItemOutput{memberItem("TEAM#1", "ann", companylib.TeamMemberRoleMember), teamItem("TEAM#1", "org-1"), orgItem("org-1")},
+			GetItemErrors:            []error{nil, nil, nil},
+			TransactWriteItemsOutput: []dynamodb.TransactWriteItemsOutput{{}},
+			TransactWriteItemsErrors: []error{nil},
+		}
+		authz, _ := newTestAuthorizer(&ddbClient, "ann")
+
+		result, err := decideAction(newTestActionStore(&ddbClient, "ann"), authz, chatCtx, confirm)
+
+		assert.NoError(t, err)
+		assert.Equal(t, actionStatusExecuted, result.Status)
+		assert.False(t, result.Replayed)
+		assert.Contains(t, string(result.Result), "TASK-101")
+
+		claim := ddbClient.UpdateItemInputs[0]
+		assert.Contains(t, aws.ToString(claim.ConditionExpression), "idempotencyKey = :key")
+		assert.Equal(t, "key-1", claim.ExpressionAttributeValues[":key"].(*dynamodb_types.AttributeValueMemberS).Value)
+		logTime := ddbClient.UpdateItemInputs[1]
+		assert.Equal(t, "PerfHubTable-test", aws.ToString(logTime.TableName))
+		assert.Equal(t, "3", logTime.ExpressionAttributeValues[":hours"].(*dynamodb_types.AttributeValueMemberN).Value)
+
+		var audit actionAuditRecord
+		attributevalue.UnmarshalMap(ddbClient.TransactWriteItemsInputs[0].TransactItems[1].Put.Item, &audit)
+		assert.Equal(t, actionStatusExecuted, audit.Outcome)
+		assert.Equal(t, "act-1", audit.ActionID)
+		assert.Equal(t, "ann", audit.UserName)
+	})
+
+	t.Run("It should post confirmed kudos on the team feed", func(t *testing.T) {
+		kudos, _ := attributevalue.MarshalMap(pendingActionRecord{
+			ChatID: "chat-1", MsgKey: actionKeyPrefix + "act-1", ActionID: "act-1", IdempotencyKey: "key-1",
+			Tool: "give_kudos", Input: map[string]interface{}{"recipientUsername": "bob", "message": "Great demo", "teamId": "TEAM#1", "userName": "ann"},
+			Summary: `Give bob kudos on the team feed: "Great demo"`, Status: actionStatusProcessing, UserName: "ann",
+			ConfirmBy: time.Now().UTC().Add(time.Minute).Format(time.RFC3339),
+		})
+		bob, _ := attributevalue.MarshalMap(companylib.EmployeeDynamodbData{UserName: "bob", FirstName: "Bob", LastName: "Ray"})
+		ddbClient := awsclients.MockDynamodbClient{
+			UpdateItemOutputs: []dynamodb.UpdateItemOutput{{Attributes: kudos}},
+			UpdateItemErrors:  []error{nil},
+			GetItemOutputs: []dynamodb.GetItemOutput{
+				memberItem("TEAM#1", "ann", companylib.TeamMemberRoleMember), memberItem("TEAM#1", "bob", companylib.TeamMemberRoleMember),
+				teamItem("TEAM#1", "org-1"), orgItem("org-1"), {Item: bob},
+			},
+			GetItemErrors:            make([]error, 5),
+			PutItemOutputs:           []dynamodb.PutItemOutput{{}},
+			PutItemErrors:            []error{nil},
+			BatchWriteItemOutputs:    []dynamodb.BatchWriteItemOutput{{}},
+			BatchErrors:              []error{nil},
+			TransactWriteItemsOutput: []dynamodb.TransactWriteItemsOutput{{}},
+			TransactWriteItemsErrors: []error{nil},
+		}
+		authz, _ := newTestAuthorizer(&ddbClient, "ann")
+
+		result, err := decideAction(newTestActionStore(&ddbClient, "ann"), authz, ChatContext{CallerUserName: "ann", CallerDisplayName: "Ann Lee", CallerTeamID: "TEAM#1"}, confirm)
+
+		assert.NoError(t, err)
+		assert.Equal(t, actionStatusExecuted, result.Status)
+
+		var post ctrl.KudosPostRecord
+		attributevalue.UnmarshalMap(ddbClient.PutItemInputs[0].Item, &post)
+		assert.Equal(t, "TeamFeedTable-test", aws.ToString(ddbClient.PutItemInputs[0].TableName))
+		assert.Equal(t, "kudos", post.Type)
+		assert.Equal(t, "TEAM#TEAM#1", post.GSI1PK)
+		assert.Equal(t, "Ann Lee", post.AuthorName)
+		assert.Equal(t, ctrl.KudosPostData{KudosRecipientUserID: "bob", KudosRecipientName: "Bob Ray"}, post.Data)
+		assert.Len(t, ddbClient.BatchWriteItemsInputs, 1)
+	})
+
+	t.Run("It should return the first outcome when a confirmation is repeated", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			UpdateItemOutputs: []dynamodb.UpdateItemOutput{{}},
+			UpdateItemErrors:  []error{claimFailed},
+			GetItemOutputs:    []dynamodb.GetItemOutput{{Item: actionItem(actionStatusExecuted, "key-1")}},
+			GetItemErrors:     []error{nil},
+		}
+		authz, _ := newTestAuthorizer(&ddbClient, "ann")
+
+		result, err := decideAction(newTestActionStore(&ddbClient, "ann"), authz, chatCtx, confirm)
+
+		assert.NoError(t, err)
+		assert.True(t, result.Replayed)
+		assert.Equal(t, actionStatusExecuted, result.Status)
+		assert.Len(t, ddbClient.UpdateItemInputs, 1)
+		assert.Empty(t, ddbClient.TransactWriteItemsInputs)
+	})
+
+	t.Run("It should hide actions proposed with another key", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			UpdateItemOutputs: []dynamodb.UpdateItemOutput{{}},
+			UpdateItemErrors:  []error{claimFailed},
+			GetItemOutputs:    []dynamodb.GetItemOutput{{Item: actionItem(actionStatusExecuted, "key-2")}},
+			GetItemErrors:     []error{nil},
+		}
+		authz, _ := newTestAuthorizer(&ddbClient, "ann")
+
+		_, err := decideAction(newTestActionStore(&ddbClient, "ann"), authz, chatCtx, confirm)
+
+		assert.ErrorIs(t, err, errActionNotFound)
+	})
+
+	t.Run("It should refuse an action that expired before it was confirmed", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			UpdateItemOutputs: []dynamodb.UpdateItemOutput{{}},
+			UpdateItemErrors:  []error{claimFailed},
+			GetItemOutputs:    []dynamodb.GetItemOutput{{Item: actionItem(actionStatusPending, "key-1")}},
+			GetItemErrors:     []error{nil},
+		}
+		authz, _ := newTestAuthorizer(&ddbClient, "ann")
+
+		_, err := decideAction(newTestActionStore(&ddbClient, "ann"), authz, chatCtx, confirm)
+
+		assert.ErrorIs(t, err, errActionNotPending)
+	})
+
+	t.Run("It should cancel without running the action", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			UpdateItemOutputs:        []dynamodb.UpdateItemOutput{{Attributes: actionItem(actionStatusProcessing, "key-1")}},
+			UpdateItemErrors:         []error{nil},
+			TransactWriteItemsOutput: []dynamodb.TransactWriteItemsOutput{{}},
+			TransactWriteItemsErrors: []error{nil},
+		}
+		authz, _ := newTestAuthorizer(&ddbClient, "ann")
+
+		result, err := decideAction(newTestActionStore(&ddbClient, "ann"), authz, chatCtx, ActionRequest{ActionID: "act-1", IdempotencyKey: "key-1", Decision: actionDecisionCancel})
+
+		assert.NoError(t, err)
+		assert.Equal(t, actionStatusCancelled, result.Status)
+		assert.Empty(t, ddbClient.GetItemInputs)
+		assert.Len(t, ddbClient.UpdateItemInputs, 1)
+	})
+
+	t.Run("It should record a failed action", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			UpdateItemOutputs:        []dynamodb.UpdateItemOutput{{Attributes: actionItem(actionStatusProcessing, "key-1")}, {}},
+			UpdateItemErrors:         []error{nil, claimFailed},
+			GetItemOutputs:           []dynamodb.GetItemOutput{memberItem("TEAM#1", "ann", companylib.TeamMemberRoleMember), teamItem("TEAM#1", "org-1"), orgItem("org-1")},
+			GetItemErrors:            []error{nil, nil, nil},
+			TransactWriteItemsOutput: []dynamodb.TransactWriteItemsOutput{{}},
+			TransactWriteItemsErrors: []error{nil},
+		}
+		authz, _ := newTestAuthorizer(&ddbClient, "ann")
+
+		result, err := decideAction(newTestActionStore(&ddbClient, "ann"), authz, chatCtx, confirm)
+
+		assert.NoError(t, err)
+		assert.Equal(t, actionStatusFailed, result.Status)
+		assert.Contains(t, result.Error, "not found: task TASK-101")
+		assert.Equal(t, "I couldn't complete \"Log 3 hours on TASK-101\": not found: task TASK-101", outcomeMessage(result))
+	})
+
+	t.Run("It should reject an unknown decision", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{}
+		authz, _ := newTestAuthorizer(&ddbClient, "ann")
+
+		_, err := decideAction(newTestActionStore(&ddbClient, "ann"), authz, chatCtx, ActionRequest{ActionID: "act-1", IdempotencyKey: "key-1", Decision: "maybe"})
+
+		assert.ErrorIs(t, err, errInvalidDecision)
+		assert.Empty(t, ddbClient.UpdateItemInputs)
+	})
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwksRefreshInterval limits how often an unknown key ID triggers a JWKS download, so forged
+// tokens cannot make every request fetch the key set.
+const jwksRefreshInterval = 5 * time.Minute
+
+// jwksRetryInterval is how long a failed JWKS download blocks the next attempt, so an outage of
+// the key endpoint is retried soon without being hit by every request.
+const jwksRetryInterval = 10 * time.Second
+
+var errInvalidToken = errors.New("invalid token")
+
+// cognitoClaims are the ID token claims the chat handler relies on.
+type cognitoClaims struct {
+	TokenUse string `json:"token_use"`
+	jwt.RegisteredClaims
+}
+
+// cognitoTokenVerifier checks Cognito ID tokens for requests that do not pass through the API
+// Gateway Cognito authorizer (the streaming function URL). It accepts the same tokens the
+// authorizer does: RS256 ID tokens issued by the tenant user pool for the tenant app client.
+type cognitoTokenVerifier struct {
+	issuer   string
+	clientID string
+	jwksURL  string
+	client   *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time // last successful download
+	failedAt  time.Time // last failed download
+}
+
+func newCognitoTokenVerifier(region, userPoolID, clientID string) *cognitoTokenVerifier {
+	issuer := fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s", region, userPoolID)
+	return &cognitoTokenVerifier{
+		issuer:   issuer,
+		clientID: clientID,
+		jwksURL:  issuer + "/.well-known/jwks.json",
+		client:   &http.Client{Timeout: 5 * time.Second},
+		keys:     map[string]*rsa.PublicKey{},
+	}
+}
+
+// verify returns the Cognito sub of a valid ID token. The "Bearer " prefix is optional.
+func (v *cognitoTokenVerifier) verify(ctx context.Context, header string) (string, error) {
+	raw := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(header), "Bearer "))
+	if raw == "" {
+		return "", errInvalidToken
+	}
+
+	claims := &cognitoClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return v.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(v.issuer),
+		jwt.WithAudience(v.clientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errInvalidToken, err)
+	}
+	if claims.TokenUse != "id" || claims.Subject == "" {
+		return "", fmt.Errorf("%w: not an ID token", errInvalidToken)
+	}
+	return claims.Subject, nil
+}
+
+// key returns the signing key kid, downloading the user pool's JWKS when the key is unknown.
+func (v *cognitoTokenVerifier) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(v.fetchedAt) < jwksRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if time.Since(v.failedAt) < jwksRetryInterval {
+		return nil, errors.New("signing keys are unavailable, the last download failed")
+	}
+
+	keys, err := v.fetchKeys(ctx)
+	if err != nil {
+		v.failedAt = time.Now()
+		return nil, err
+	}
+	v.keys, v.fetchedAt = keys, time.Now()
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// fetchKeys downloads and parses the RSA keys of the user pool's JWKS.
+func (v *cognitoTokenVerifier) fetchKeys(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.jwksURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetchKeys: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetchKeys: unexpected status %d", resp.StatusCode)
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return nil, fmt.Errorf("fetchKeys: decode failed: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	return keys, nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+const testKeyID = "test-key"
+
+// newTestVerifier returns a verifier that already trusts the returned key, so tests never
+// download a JWKS.
+func newTestVerifier(t *testing.T) (*cognitoTokenVerifier, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+	v := newCognitoTokenVerifier("eu-west-1", "eu-west-1_pool", "client-1")
+	v.keys[testKeyID] = &key.PublicKey
+	v.fetchedAt = time.Now()
+	return v, key
+}
+
+func idClaims(v *cognitoTokenVerifier, sub string) cognitoClaims {
+	return cognitoClaims{
+		TokenUse: "id",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   sub,
+			Issuer:    v.issuer,
+			Audience:  jwt.ClaimStrings{v.clientID},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+}
+
+func signToken(t *testing.T, key *rsa.PrivateKey, kid string, claims cognitoClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("could not sign token: %v", err)
+	}
+	return signed
+}
+
+func TestCognitoTokenVerifier(t *testing.T) {
+	v, key := newTestVerifier(t)
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	expired := idClaims(v, "sub-ann")
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	otherClient := idClaims(v, "sub-ann")
+	otherClient.Audience = jwt.ClaimStrings{"client-2"}
+	otherPool := idClaims(v, "sub-ann")
+	otherPool.Issuer = "https://cognito-idp.eu-west-1.amazonaws.com/eu-west-1_other"
+	access := idClaims(v, "sub-ann")
+	access.TokenUse = "access"
+
+	tests := []struct {
+		name        string
+		header      string
+		expectedSub string
+	}{
+		{name: "It should accept a valid ID token", header: "Bearer " + signToken(t, key, testKeyID, idClaims(v, "sub-ann")), expectedSub: "sub-ann"},
+		{name: "It should accept a token without the Bearer prefix", header: signToken(t, key, testKeyID, idClaims(v, "sub-ann")), expectedSub: "sub-ann"},
+		{name: "It should reject a missing token", header: ""},
+		{name: "It should reject an expired token", header: signToken(t, key, testKeyID, expired)},
+		{name: "It should reject a token for another app client", header: signToken(t, key, testKeyID, otherClient)},
+		{name: "It should reject a token from another user pool", header: signToken(t, key, testKeyID, otherPool)},
+		{name: "It should reject an access token", header: signToken(t, key, testKeyID, access)},
+		{name: "It should reject a token signed with an unknown key", header: signToken(t, otherKey, "other-key", idClaims(v, "sub-ann"))},
+		{name: "It should reject a forged signature", header: signToken(t, otherKey, testKeyID, idClaims(v, "sub-ann"))},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sub, err := v.verify(context.TODO(), test.header)
+			if test.expectedSub == "" {
+				assert.ErrorIs(t, err, errInvalidToken)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedSub, sub)
+		})
+	}
+}
+
+func TestCognitoTokenVerifierKeyDownload(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	t.Run("It should retry a failed download once the backoff has passed", func(t *testing.T) {
+		requests, failing := 0, true
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if failing {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+				"kid": testKeyID,
+				"kty": "RSA",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}}})
+		}))
+		defer server.Close()
+
+		v := newCognitoTokenVerifier("eu-west-1", "eu-west-1_pool", "client-1")
+		v.jwksURL = server.URL
+		token := signToken(t, key, testKeyID, idClaims(v, "sub-ann"))
+
+		_, err := v.verify(context.TODO(), token)
+		assert.ErrorIs(t, err, errInvalidToken)
+		assert.Equal(t, 1, requests)
+
+		// Within the backoff the download is not attempted again
+		failing = false
+		_, err = v.verify(context.TODO(), token)
+		assert.ErrorIs(t, err, errInvalidToken)
+		assert.Equal(t, 1, requests)
+
+		v.failedAt = time.Now().Add(-jwksRetryInterval)
+		sub, err := v.verify(context.TODO(), token)
+		assert.NoError(t, err)
+		assert.Equal(t, "sub-ann", sub)
+		assert.Equal(t, 2, requests)
+	})
+}
@@ -3,46 +3,215 @@ package main
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	bedrock "github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
 	bedrocktypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	ddbTypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/google/uuid"
+
+	awsclients "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/clients"
 )
 
-// loadChatHistory fetches the most recent `limit` messages for the given chatId,
-// ordered by SK (ascending — oldest first). Returns Bedrock-ready Message structs.
-func loadChatHistory(ctx context.Context, ddb *dynamodb.Client, table, chatId string, limit int32) ([]bedrocktypes.Message, error) {
-	out, err := ddb.Query(ctx, &dynamodb.QueryInput{
+// summaryBatch is how many messages beyond historyLimit may pile up before the oldest ones are
+// folded into the session summary. Folding in batches keeps the extra Bedrock call to roughly one
+// in every five turns.
+const summaryBatch = 10
+
+// firstMessageKey sorts before every message key ({epoch_millis_padded}#{uuid}).
+const firstMessageKey = "0"
+
+// messageRange is a half-open range of message keys (After, Before). After is exclusive and may be
+// empty; Before defaults to actionKeyPrefix, which sorts after every message.
+type messageRange struct {
+	After  string
+	Before string
+}
+
+// historyStore is the chat history a chat turn reads and writes: the session record, its messages
+// and the rolling summary. Its methods mirror the DynamoDB helpers in this file and in sessions.go.
+// The session management endpoints work on the table directly.
+type historyStore interface {
+	loadSession(ctx context.Context, chatId, ownerId string) (*chatSessionRecord, error)
+	queryMessages(ctx context.Context, chatId string, r messageRange, limit int32) ([]chatHistoryRecord, bool, error)
+	saveSummary(ctx context.Context, session *chatSessionRecord, summary, through string) error
+	saveTurn(ctx context.Context, chatId, userId, userMsg, assistantMsg string) error
+}
+
+// dynamoHistoryStore keeps chat history in the AI chat history table.
+type dynamoHistoryStore struct {
+	ddb   awsclients.DynamodbClient
+	table string
+}
+
+func (s dynamoHistoryStore) loadSession(ctx context.Context, chatId, ownerId string) (*chatSessionRecord, error) {
+	return loadSession(ctx, s.ddb, s.table, chatId, ownerId)
+}
+
+func (s dynamoHistoryStore) queryMessages(ctx context.Context, chatId string, r messageRange, limit int32) ([]chatHistoryRecord, bool, error) {
+	return queryMessages(ctx, s.ddb, s.table, chatId, r, limit)
+}
+
+func (s dynamoHistoryStore) saveSummary(ctx context.Context, session *chatSessionRecord, summary, through string) error {
+	return saveSessionSummary(ctx, s.ddb, s.table, session, summary, through)
+}
+
+func (s dynamoHistoryStore) saveTurn(ctx context.Context, chatId, userId, userMsg, assistantMsg string) error {
+	return saveChatTurn(ctx, s.ddb, s.table, chatId, userId, userMsg, assistantMsg)
+}
+
+// queryMessages returns the chat messages in r, oldest first. limit > 0 returns only the newest
+// `limit` messages, and more reports whether older ones in r were left out.
+// Pending action, audit and session records in the same partition sort after every message and
+// are never returned.
+func queryMessages(ctx context.Context, ddb awsclients.DynamodbClient, table, chatId string, r messageRange, limit int32) (records []chatHistoryRecord, more bool, err error) {
+	from, to := r.After, r.Before
+	if from == "" {
+		from = firstMessageKey
+	}
+	if to == "" {
+		to = actionKeyPrefix
+	}
+	input := &dynamodb.QueryInput{
 		TableName:              aws.String(table),
-		KeyConditionExpression: aws.String("chatId = :cid"),
+		KeyConditionExpression: aws.String("chatId = :cid AND msgKey BETWEEN :from AND :to"),
 		ExpressionAttributeValues: map[string]ddbTypes.AttributeValue{
-			":cid": &ddbTypes.AttributeValueMemberS{Value: chatId},
+			":cid":  &ddbTypes.AttributeValueMemberS{Value: chatId},
+			":from": &ddbTypes.AttributeValueMemberS{Value: from},
+			":to":   &ddbTypes.AttributeValueMemberS{Value: to},
 		},
-		ScanIndexForward: aws.Bool(false), // newest first
-		Limit:            aws.Int32(limit),
-	})
+		ScanIndexForward: aws.Bool(limit == 0),
+	}
+	if limit > 0 {
+		// One extra item tells us whether anything older is left
+		input.Limit = aws.Int32(limit + 1)
+	}
+
+	for {
+		out, err := ddb.Query(ctx, input)
+		if err != nil {
+			return nil, false, fmt.Errorf("queryMessages: query failed: %w", err)
+		}
+		for _, item := range out.Items {
+			var rec chatHistoryRecord
+			if err := attributevalue.UnmarshalMap(item, &rec); err != nil {
+				continue
+			}
+			// BETWEEN is inclusive at both ends
+			if rec.MsgKey == from || rec.MsgKey == to {
+				continue
+			}
+			records = append(records, rec)
+		}
+		if limit > 0 && int32(len(records)) > limit {
+			records, more = records[:limit], true
+			break
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+
+	if limit > 0 {
+		// Newest-first reads are reversed to chronological order (oldest first) for Bedrock.
+		for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+			records[i], records[j] = records[j], records[i]
+		}
+	}
+	return records, more, nil
+}
+
+// loadChatHistory returns the context for the next turn: the session summary and the messages it
+// does not cover, at most `limit` of them. Once more than limit+summaryBatch messages are
+// uncovered, the oldest are folded into the summary and the session record is updated so later
+// turns start from the new summary. Summarisation failures are logged and only cost context.
+func (svc *Service) loadChatHistory(ctx context.Context, session *chatSessionRecord, limit int32) (string, []chatHistoryRecord, error) {
+	summary := session.Summary
+	records, more, err := svc.history.queryMessages(ctx, session.ChatID, messageRange{After: session.SummarizedThrough}, limit+summaryBatch)
 	if err != nil {
-		return nil, fmt.Errorf("loadChatHistory: query failed: %w", err)
+		return summary, nil, err
+	}
+	if int32(len(records)) < limit+summaryBatch {
+		return summary, records, nil
 	}
 
-	records := make([]chatHistoryRecord, 0, len(out.Items))
-	for _, item := range out.Items {
-		var r chatHistoryRecord
-		if err := attributevalue.UnmarshalMap(item, &r); err != nil {
-			continue
+	fold, keep := records[:len(records)-int(limit)], records[len(records)-int(limit):]
+	if more {
+		// An earlier summarisation failed; pick up everything it missed.
+		older, _, err := svc.history.queryMessages(ctx, session.ChatID, messageRange{After: session.SummarizedThrough, Before: fold[0].MsgKey}, 0)
+		if err != nil {
+			svc.logger.Printf("warn: could not load older messages chatId=%q: %v", session.ChatID, err)
+		} else {
+			fold = append(older, fold...)
 		}
-		records = append(records, r)
 	}
 
-	// Reverse to chronological order (oldest first) for Bedrock.
-	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
-		records[i], records[j] = records[j], records[i]
+	updated, err := svc.summarizeMessages(ctx, summary, fold)
+	if err != nil {
+		svc.logger.Printf("warn: could not summarise chatId=%q: %v", session.ChatID, err)
+		return summary, keep, nil
 	}
+	through := fold[len(fold)-1].MsgKey
+	if err := svc.history.saveSummary(ctx, session, updated, through); err != nil {
+		svc.logger.Printf("warn: could not save summary chatId=%q: %v", session.ChatID, err)
+	}
+	return updated, keep, nil
+}
 
+// summarizeMessages asks the model to fold records into the running summary of a conversation.
+func (svc *Service) summarizeMessages(ctx context.Context, previous string, records []chatHistoryRecord) (string, error) {
+	var sb strings.Builder
+	if previous != "" {
+		sb.WriteString("Summary so far:\n")
+		sb.WriteString(previous)
+		sb.WriteString("\n\n")
+	}
+	sb.WriteString("New messages:\n")
+	for _, r := range records {
+		speaker := "User"
+		if r.Role == "assistant" {
+			speaker = "Assistant"
+		}
+		sb.WriteString(fmt.Sprintf("%s: %s\n", speaker, r.MessageText))
+	}
+
+	output, err := svc.bedrockClient.Converse(ctx, &bedrock.ConverseInput{
+		ModelId: aws.String(svc.modelID),
+		System: []bedrocktypes.SystemContentBlock{
+			&bedrocktypes.SystemContentBlockMemberText{Value: summaryPrompt},
+		},
+		Messages: []bedrocktypes.Message{{
+			Role:    bedrocktypes.ConversationRoleUser,
+			Content: []bedrocktypes.ContentBlock{&bedrocktypes.ContentBlockMemberText{Value: sb.String()}},
+		}},
+	})
+	if err != nil {
+		return "", fmt.Errorf("summarizeMessages: Converse failed: %w", err)
+	}
+	msgOutput, ok := output.Output.(*bedrocktypes.ConverseOutputMemberMessage)
+	if !ok {
+		return "", fmt.Errorf("summarizeMessages: unexpected Converse output type")
+	}
+	for _, block := range msgOutput.Value.Content {
+		if txt, ok := block.(*bedrocktypes.ContentBlockMemberText); ok && strings.TrimSpace(txt.Value) != "" {
+			return strings.TrimSpace(txt.Value), nil
+		}
+	}
+	return "", fmt.Errorf("summarizeMessages: empty summary")
+}
+
+const summaryPrompt = "You maintain a running summary of a conversation between an employee and a performance management assistant. " +
+	"Merge the new messages into the summary so far. Keep names, usernames, team, goal and task IDs, numbers, decisions, " +
+	"confirmed or cancelled actions and open questions. Drop greetings and repetition. " +
+	"Reply with the updated summary only, in at most 200 words."
+
+// toBedrockMessages converts stored messages into Bedrock Message structs.
+func toBedrockMessages(records []chatHistoryRecord) []bedrocktypes.Message {
 	messages := make([]bedrocktypes.Message, 0, len(records))
 	for _, r := range records {
 		role := bedrocktypes.ConversationRoleUser
@@ -56,12 +225,14 @@ func loadChatHistory(ctx context.Context, ddb *dynamodb.Client, table, chatId st
 			},
 		})
 	}
-	return messages, nil
+	return messages
 }
 
 // saveChatTurn writes one user message record and one assistant message record
 // to the DynamoDB chat history table. Both records receive a 6-month TTL.
-func saveChatTurn(ctx context.Context, ddb *dynamodb.Client, table, chatId, userId, userMsg, assistantMsg string) error {
+// The session record is created or touched in the same transaction, which fails if the chat
+// belongs to someone else.
+func saveChatTurn(ctx context.Context, ddb awsclients.DynamodbClient, table, chatId, userId, userMsg, assistantMsg string) error {
 	now := time.Now().UTC()
 	expiry := now.Unix() + chatTTLSeconds
 
@@ -94,6 +265,9 @@ func saveChatTurn(ctx context.Context, ddb *dynamodb.Client, table, chatId, user
 				}),
 			},
 		},
+		{
+			Update: touchSession(table, chatId, userId, sessionTitle(userMsg), 2, now),
+		},
 	}
 
 	_, err := ddb.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	bedrock "github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	bedrockdoc "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+	bedrocktypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// scriptedTurn is one recorded model reply.
+type scriptedTurn struct {
+	Text       string            `json:"text,omitempty"`
+	ToolUses   []scriptedToolUse `json:"toolUses,omitempty"`
+	StopReason string            `json:"stopReason,omitempty"`
+	// Error fails the call. ConverseStream streams the text first and then fails.
+	Error string `json:"error,omitempty"`
+}
+
+type scriptedToolUse struct {
+	ID    string          `json:"id"`
+	Name  string          `json:"name"`
+	Input json.RawMessage `json:"input,omitempty"`
+}
+
+// scriptedModel is a converseClient that replays recorded turns in order, through Converse or
+// ConverseStream, and records the messages it was sent. A call beyond the script fails.
+type scriptedModel struct {
+	turns    []scriptedTurn
+	requests [][]bedrocktypes.Message
+}
+
+func (m *scriptedModel) next(messages []bedrocktypes.Message) (scriptedTurn, error) {
+	if len(m.requests) == len(m.turns) {
+		return scriptedTurn{}, errors.New("the script has no more turns")
+	}
+	// The loop keeps appending to the same slice, so keep a copy of what was sent.
+	m.requests = append(m.requests, append([]bedrocktypes.Message(nil), messages...))
+	return m.turns[len(m.requests)-1], nil
+}
+
+func (m *scriptedModel) Converse(ctx context.Context, params *bedrock.ConverseInput, optFns ...func(*bedrock.Options)) (*bedrock.ConverseOutput, error) {
+	turn, err := m.next(params.Messages)
+	if err != nil {
+		return nil, err
+	}
+	if turn.Error != "" {
+		return nil, errors.New(turn.Error)
+	}
+
+	content := []bedrocktypes.ContentBlock{}
+	if turn.Text != "" {
+		content = append(content, &bedrocktypes.ContentBlockMemberText{Value: turn.Text})
+	}
+	for _, use := range turn.ToolUses {
+		input := map[string]interface{}{}
+		_ = json.Unmarshal(use.Input, &input)
+		content = append(content, &bedrocktypes.ContentBlockMemberToolUse{Value: bedrocktypes.ToolUseBlock{
+			ToolUseId: aws.String(use.ID),
+			Name:      aws.String(use.Name),
+			Input:     bedrockdoc.NewLazyDocument(input),
+		}})
+	}
+	return &bedrock.ConverseOutput{
+		Output: &bedrocktypes.ConverseOutputMemberMessage{Value: bedrocktypes.Message{
+			Role:    bedrocktypes.ConversationRoleAssistant,
+			Content: content,
+		}},
+		StopReason: bedrocktypes.StopReason(turn.StopReason),
+	}, nil
+}
+
+// ConverseStream streams the text a word at a time and each tool input in two halves, the way
+// Bedrock splits them across deltas.
+func (m *scriptedModel) ConverseStream(ctx context.Context, params *bedrock.ConverseStreamInput, optFns ...func(*bedrock.Options)) (bedrock.ConverseStreamOutputReader, error) {
+	turn, err := m.next(params.Messages)
+	if err != nil {
+		return nil, err
+	}
+
+	var blocks [][]bedrocktypes.ConverseStreamOutput
+	if turn.Text != "" {
+		blocks = append(blocks, textEvents(0, strings.SplitAfter(turn.Text, " ")...))
+	}
+	for _, use := range turn.ToolUses {
+		input := string(use.Input)
+		blocks = append(blocks, toolUseEvents(int32(len(blocks)), use.ID, use.Name, input[:len(input)/2], input[len(input)/2:]))
+	}
+	if turn.Error != "" {
+		return streamOf(errors.New(turn.Error), "", blocks...), nil
+	}
+	return streamOf(nil, bedrocktypes.StopReason(turn.StopReason), blocks...), nil
+}
+
+// memoryHistoryStore is a historyStore kept in memory. Like the table, it keeps every chat
+// with its owner and refuses to write to someone else's chat.
+type memoryHistoryStore struct {
+	sessions map[string]*chatSessionRecord
+	messages map[string][]chatHistoryRecord
+}
+
+func newMemoryHistoryStore() *memoryHistoryStore {
+	return &memoryHistoryStore{sessions: map[string]*chatSessionRecord{}, messages: map[string][]chatHistoryRecord{}}
+}
+
+func (s *memoryHistoryStore) loadSession(ctx context.Context, chatId, ownerId string) (*chatSessionRecord, error) {
+	session, ok := s.sessions[chatId]
+	if !ok {
+		return &chatSessionRecord{ChatID: chatId, MsgKey: sessionMsgKey, OwnerID: ownerId}, nil
+	}
+	if session.OwnerID != ownerId {
+		return nil, errSessionNotFound
+	}
+	copied := *session
+	return &copied, nil
+}
+
+func (s *memoryHistoryStore) queryMessages(ctx context.Context, chatId string, r messageRange, limit int32) ([]chatHistoryRecord, bool, error) {
+	var records []chatHistoryRecord
+	for _, rec := range s.messages[chatId] {
+		if rec.MsgKey > r.After && (r.Before == "" || rec.MsgKey < r.Before) {
+			records = append(records, rec)
+		}
+	}
+	if limit > 0 && int32(len(records)) > limit {
+		return records[len(records)-int(limit):], true, nil
+	}
+	return records, false, nil
+}
+
+func (s *memoryHistoryStore) saveSummary(ctx context.Context, session *chatSessionRecord, summary, through string) error {
+	stored, ok := s.sessions[session.ChatID]
+	if !ok || stored.OwnerID != session.OwnerID {
+		return errSessionNotFound
+	}
+	stored.Summary, stored.SummarizedThrough = summary, through
+	return nil
+}
+
+func (s *memoryHistoryStore) saveTurn(ctx context.Context, chatId, userId, userMsg, assistantMsg string) error {
+	session, ok := s.sessions[chatId]
+	if ok && session.OwnerID != userId {
+		return errSessionNotFound
+	}
+	if !ok {
+		session = &chatSessionRecord{ChatID: chatId, MsgKey: sessionMsgKey, OwnerID: userId, Title: sessionTitle(userMsg)}
+		s.sessions[chatId] = session
+	}
+	s.append(chatId, userId, "user", userMsg)
+	s.append(chatId, userId, "assistant", assistantMsg)
+	session.MessageCount += 2
+	return nil
+}
+
+func (s *memoryHistoryStore) append(chatId, userId, role, text string) {
+	s.messages[chatId] = append(s.messages[chatId], chatHistoryRecord{
+		ChatID:      chatId,
+		MsgKey:      fmt.Sprintf("%020d#m%d", len(s.messages[chatId])+1, len(s.messages[chatId])),
+		Role:        role,
+		MessageText: text,
+		UserID:      userId,
+	})
+}
@@ -3,8 +3,10 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
@@ -19,29 +21,111 @@ import (
 const maxToolIterations = 5
 
 // historyLimit is how many past messages (user+assistant pairs) to load per request.
+// Older messages reach the model through the session's rolling summary.
 const historyLimit = 20
 
-// Handle is the Lambda entry point. It parses the HTTP request, runs the
-// Bedrock converse loop, persists the conversation turn, and returns a response.
+// Handle is the Lambda entry point. It routes POST /v2/ai/chat to the chat flow and
+// /v2/ai/chat/sessions/... to session management.
 func (svc *Service) Handle(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	ctx := context.Background()
 
+	parts := splitPath(request.Path)
+	if len(parts) < 3 || parts[0] != "v2" || parts[1] != "ai" || parts[2] != "chat" || len(parts) > 6 {
+		return errResponse(http.StatusNotFound, "route not found")
+	}
+	if len(parts) == 3 {
+		if request.HTTPMethod != http.MethodPost {
+			return errResponse(http.StatusMethodNotAllowed, "method not allowed")
+		}
+		return svc.handleChat(ctx, request)
+	}
+	if parts[3] != "sessions" {
+		return errResponse(http.StatusNotFound, "route not found")
+	}
+
+	cognitoID, err := getCognitoIDFromRequest(request)
+	if err != nil {
+		return errResponse(http.StatusUnauthorized, "missing authentication")
+	}
+	return svc.handleSessions(ctx, request, parts, cognitoID)
+}
+
+// chatTurn is one chat request with the caller resolved and the chat's ownership checked.
+type chatTurn struct {
+	req     ChatRequest
+	session *chatSessionRecord
+	chatCtx ChatContext
+	authz   *toolAuthorizer
+	actions *actionStore
+}
+
+// handleChat parses a chat request, runs the Bedrock converse loop, persists the
+// conversation turn, and returns a response.
+func (svc *Service) handleChat(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	// --- 1. Parse request ---
+	req, err := parseChatRequest(request.Body)
+	if err != nil {
+		return errResponse(http.StatusBadRequest, err.Error())
+	}
+
+	// --- 2. Resolve caller identity ---
+	cognitoID, err := getCognitoIDFromRequest(request)
+	if err != nil {
+		return errResponse(http.StatusUnauthorized, "missing authentication")
+	}
+
+	turn, err := svc.startTurn(ctx, req, cognitoID)
+	if errors.Is(err, errSessionNotFound) {
+		return errResponse(http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		svc.logger.Printf("error: could not load session chatId=%q: %v", req.ChatID, err)
+		return errResponse(http.StatusInternalServerError, "could not load chat")
+	}
+
+	if req.Action != nil {
+		return svc.handleAction(ctx, turn)
+	}
+
+	// --- 3. Load conversation history and append the new user message ---
+	summary, messages := svc.turnMessages(ctx, turn)
+
+	// --- 4. Run Bedrock converse loop ---
+	finalText, toolsUsed, err := svc.converseWithTools(ctx, messages, summary, turn.chatCtx, turn.authz, turn.actions)
+	if err != nil {
+		svc.logger.Printf("error: bedrock converse failed chatId=%q: %v", req.ChatID, err)
+		return errResponse(http.StatusInternalServerError, "AI service error")
+	}
+
+	// --- 5. Persist conversation turn and return response ---
+	return jsonResponse(http.StatusOK, svc.finishTurn(ctx, turn, finalText, toolsUsed))
+}
+
+// parseChatRequest decodes and validates a chat request body. New chats get a generated chatId.
+func parseChatRequest(body string) (ChatRequest, error) {
 	var req ChatRequest
-	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
-		return errResponse(http.StatusBadRequest, "invalid request body")
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		return req, errors.New("invalid request body")
 	}
-	if strings.TrimSpace(req.Message) == "" {
-		return errResponse(http.StatusBadRequest, "message is required")
+	if req.Action != nil {
+		if req.ChatID == "" || req.Action.ActionID == "" || req.Action.IdempotencyKey == "" {
+			return req, errors.New("chatId, action.actionId and action.idempotencyKey are required")
+		}
+	} else if strings.TrimSpace(req.Message) == "" {
+		return req, errors.New("message is required")
 	}
 	if req.ChatID == "" {
 		req.ChatID = uuid.NewString()
 	}
+	return req, nil
+}
 
-	// --- 2. Resolve caller identity ---
-	cognitoID, err := getCognitoIDFromRequest(request)
+// startTurn loads the chat's session and resolves what the caller may see and do. Someone
+// else's chat is reported as errSessionNotFound before anything is read from it.
+func (svc *Service) startTurn(ctx context.Context, req ChatRequest, cognitoID string) (*chatTurn, error) {
+	session, err := svc.history.loadSession(ctx, req.ChatID, cognitoID)
 	if err != nil {
-		return errResponse(http.StatusUnauthorized, "missing authentication")
+		return nil, err
 	}
 
 	emp, err := svc.ctrlSVC.FindEmployeeByCognitoId(cognitoID)
@@ -49,69 +133,111 @@ func (svc *Service) Handle(request events.APIGatewayProxyRequest) (events.APIGat
 		svc.logger.Printf("warn: could not resolve employee for cognitoId=%q: %v", cognitoID, err)
 	}
 
-	chatCtx := ChatContext{
+	// The frontend context is only a hint; the authorizer keeps the parts the caller really holds.
+	authz := newToolAuthorizer(svc.ctrlSVC, svc.logger, emp.UserName)
+	chatCtx := authz.verifyContext(ChatContext{
 		CallerCognitoID:   cognitoID,
 		CallerUserName:    emp.UserName,
 		CallerDisplayName: emp.DisplayName,
 		CallerTeamID:      req.Context.TeamID,
 		CallerOrgID:       req.Context.OrgID,
 		TargetUserID:      req.Context.TargetUserID,
-	}
+	})
 
-	// --- 3. Load conversation history ---
-	history, err := loadChatHistory(ctx, svc.ddb, svc.chatHistoryTable, req.ChatID, historyLimit)
-	if err != nil {
-		svc.logger.Printf("warn: could not load chat history chatId=%q: %v", req.ChatID, err)
-		history = nil
+	return &chatTurn{
+		req:     req,
+		session: session,
+		chatCtx: chatCtx,
+		authz:   authz,
+		actions: newActionStore(ctx, svc.ddb, svc.chatHistoryTable, req.ChatID, emp.UserName, cognitoID),
+	}, nil
+}
+
+// turnMessages returns the session summary and the messages to send to the model: the history
+// the summary does not cover followed by the new user message. History failures only cost context.
+func (svc *Service) turnMessages(ctx context.Context, turn *chatTurn) (string, []bedrocktypes.Message) {
+	var summary string
+	var history []chatHistoryRecord
+	if turn.session.MessageCount > 0 {
+		var err error
+		summary, history, err = svc.loadChatHistory(ctx, turn.session, historyLimit)
+		if err != nil {
+			svc.logger.Printf("warn: could not load chat history chatId=%q: %v", turn.req.ChatID, err)
+		}
 	}
 
-	// --- 4. Append new user message ---
-	messages := make([]bedrocktypes.Message, len(history))
-	copy(messages, history)
+	messages := toBedrockMessages(history)
 	messages = append(messages, bedrocktypes.Message{
 		Role: bedrocktypes.ConversationRoleUser,
 		Content: []bedrocktypes.ContentBlock{
-			&bedrocktypes.ContentBlockMemberText{Value: req.Message},
+			&bedrocktypes.ContentBlockMemberText{Value: turn.req.Message},
 		},
 	})
+	return summary, messages
+}
 
-	// --- 5. Run Bedrock converse loop ---
-	finalText, toolsUsed, err := svc.converseWithTools(ctx, messages, chatCtx)
-	if err != nil {
-		svc.logger.Printf("error: bedrock converse failed chatId=%q: %v", req.ChatID, err)
-		return errResponse(http.StatusInternalServerError, "AI service error")
+// finishTurn persists the conversation turn and builds the response. A failed save is logged
+// and the answer is still returned.
+func (svc *Service) finishTurn(ctx context.Context, turn *chatTurn, finalText string, toolsUsed []string) ChatResponse {
+	if err := svc.history.saveTurn(ctx, turn.req.ChatID, turn.chatCtx.CallerCognitoID, turn.req.Message, finalText); err != nil {
+		svc.logger.Printf("warn: could not save chat turn chatId=%q: %v", turn.req.ChatID, err)
 	}
+	return ChatResponse{
+		ChatID:         turn.req.ChatID,
+		Response:       finalText,
+		ToolsUsed:      toolsUsed,
+		PendingActions: turn.actions.pending,
+	}
+}
 
-	// --- 6. Persist conversation turn ---
-	if err := saveChatTurn(ctx, svc.ddb, svc.chatHistoryTable, req.ChatID, cognitoID, req.Message, finalText); err != nil {
-		svc.logger.Printf("warn: could not save chat turn chatId=%q: %v", req.ChatID, err)
-		// non-fatal — response is still returned
+// handleAction confirms or cancels a pending action proposed earlier in the chat and records the
+// decision as a conversation turn so the model knows about it on the next message.
+func (svc *Service) handleAction(ctx context.Context, turn *chatTurn) (events.APIGatewayProxyResponse, error) {
+	req := turn.req
+	result, err := decideAction(turn.actions, turn.authz, turn.chatCtx, *req.Action)
+	switch {
+	case errors.Is(err, errActionNotFound):
+		return errResponse(http.StatusNotFound, err.Error())
+	case errors.Is(err, errActionNotPending):
+		return errResponse(http.StatusConflict, "action has expired or is already being processed")
+	case errors.Is(err, errInvalidDecision):
+		return errResponse(http.StatusBadRequest, err.Error())
+	case err != nil:
+		svc.logger.Printf("error: action %s failed chatId=%q: %v", req.Action.ActionID, req.ChatID, err)
+		return errResponse(http.StatusInternalServerError, "could not process action")
 	}
 
-	// --- 7. Return response ---
-	resp := ChatResponse{
-		ChatID:    req.ChatID,
-		Response:  finalText,
-		ToolsUsed: toolsUsed,
+	reply := outcomeMessage(result)
+	if !result.Replayed {
+		userMsg := fmt.Sprintf("[%s] %s", req.Action.Decision, result.Summary)
+		if err := svc.history.saveTurn(ctx, req.ChatID, turn.chatCtx.CallerCognitoID, userMsg, reply); err != nil {
+			svc.logger.Printf("warn: could not save action turn chatId=%q: %v", req.ChatID, err)
+		}
 	}
-	body, _ := json.Marshal(resp)
-	return events.APIGatewayProxyResponse{
-		StatusCode: http.StatusOK,
-		Headers:    map[string]string{"Content-Type": "application/json"},
-		Body:       string(body),
-	}, nil
+
+	return jsonResponse(http.StatusOK, ChatResponse{
+		ChatID:       req.ChatID,
+		Response:     reply,
+		ToolsUsed:    []string{result.Tool},
+		ActionResult: result,
+	})
 }
 
 // converseWithTools executes the Bedrock Converse API in a tool-use loop.
 // It appends tool results back into the conversation and recurses until
-// stop_reason is "end_turn" or the iteration cap is reached.
+// stop_reason is "end_turn" or the iteration cap is reached. finalText is the
+// text of every turn, separated by a blank line, as the streaming endpoint shows it.
 func (svc *Service) converseWithTools(
 	ctx context.Context,
 	messages []bedrocktypes.Message,
+	summary string,
 	chatCtx ChatContext,
+	authz *toolAuthorizer,
+	actions *actionStore,
 ) (finalText string, toolsUsed []string, err error) {
 	tools := buildToolList()
-	systemPrompt := buildSystemPrompt(chatCtx)
+	systemPrompt := buildSystemPrompt(chatCtx, summary)
+	var said []string
 
 	for i := 0; i < maxToolIterations; i++ {
 		output, err := svc.bedrockClient.Converse(ctx, &bedrock.ConverseInput{
@@ -133,79 +259,116 @@ func (svc *Service) converseWithTools(
 			return "", toolsUsed, fmt.Errorf("unexpected Converse output type")
 		}
 		assistantMsg := msgOutput.Value
+		if text := messageText(assistantMsg.Content); text != "" {
+			said = append(said, text)
+		}
 
-		switch output.StopReason {
-		case bedrocktypes.StopReasonEndTurn:
-			// Extract the text from the final assistant message.
-			for _, block := range assistantMsg.Content {
-				if txt, ok := block.(*bedrocktypes.ContentBlockMemberText); ok {
-					return txt.Value, toolsUsed, nil
-				}
-			}
-			return "", toolsUsed, nil
+		if output.StopReason != bedrocktypes.StopReasonToolUse {
+			// end_turn, or max_tokens, stop_sequence, etc. — return whatever text we have.
+			return strings.Join(said, "\n\n"), toolsUsed, nil
+		}
 
-		case bedrocktypes.StopReasonToolUse:
-			// Append the assistant message (with tool_use blocks) to the conversation.
-			messages = append(messages, bedrocktypes.Message{
-				Role:    bedrocktypes.ConversationRoleAssistant,
-				Content: assistantMsg.Content,
-			})
+		// Append the assistant message (with tool_use blocks) to the conversation.
+		messages = append(messages, bedrocktypes.Message{
+			Role:    bedrocktypes.ConversationRoleAssistant,
+			Content: assistantMsg.Content,
+		})
 
-			// Execute each tool and collect results.
-			toolResults := make([]bedrocktypes.ContentBlock, 0)
-			for _, block := range assistantMsg.Content {
-				toolUse, ok := block.(*bedrocktypes.ContentBlockMemberToolUse)
-				if !ok {
-					continue
-				}
-				toolName := aws.ToString(toolUse.Value.Name)
-				toolsUsed = append(toolsUsed, toolName)
-				svc.logger.Printf("tool_use: %s", toolName)
-
-				resultText, execErr := executeToolCall(toolName, toolUse.Value.Input, svc.ctrlSVC, chatCtx)
-				if execErr != nil {
-					svc.logger.Printf("tool %s error: %v", toolName, execErr)
-					resultText = fmt.Sprintf(`{"error":"%v"}`, execErr)
-				}
-
-				toolResults = append(toolResults, &bedrocktypes.ContentBlockMemberToolResult{
-					Value: bedrocktypes.ToolResultBlock{
-						ToolUseId: toolUse.Value.ToolUseId,
-						Content: []bedrocktypes.ToolResultContentBlock{
-							&bedrocktypes.ToolResultContentBlockMemberText{Value: resultText},
-						},
-					},
-				})
+		// Execute each tool and collect results.
+		calls := make([]toolCall, 0)
+		for _, block := range assistantMsg.Content {
+			toolUse, ok := block.(*bedrocktypes.ContentBlockMemberToolUse)
+			if !ok {
+				continue
 			}
-
-			// Append tool results as a user message and re-enter the loop.
-			messages = append(messages, bedrocktypes.Message{
-				Role:    bedrocktypes.ConversationRoleUser,
-				Content: toolResults,
+			name, input := aws.ToString(toolUse.Value.Name), toolUse.Value.Input
+			calls = append(calls, toolCall{
+				id:   toolUse.Value.ToolUseId,
+				name: name,
+				exec: func() (string, error) { return executeToolCall(name, input, authz, actions, chatCtx) },
 			})
-
-		default:
-			// max_tokens, stop_sequence, etc. — try to extract whatever text we have.
-			for _, block := range assistantMsg.Content {
-				if txt, ok := block.(*bedrocktypes.ContentBlockMemberText); ok {
-					return txt.Value, toolsUsed, nil
-				}
-			}
-			return "", toolsUsed, nil
+			toolsUsed = append(toolsUsed, name)
 		}
+
+		// Append tool results as a user message and re-enter the loop.
+		messages = append(messages, svc.runToolCalls(calls, ignoreProgress{}))
 	}
 
 	return "", toolsUsed, fmt.Errorf("reached maximum tool iterations (%d)", maxToolIterations)
 }
 
-// buildSystemPrompt constructs the system prompt injecting the caller's identity
-// and current date for temporal context.
-func buildSystemPrompt(ctx ChatContext) string {
+// turnObserver is told about a converse loop's progress as it happens. The streaming endpoint
+// forwards it to the client; the REST endpoint ignores it.
+type turnObserver interface {
+	textDelta(text string)
+	toolStart(toolUseID, name string)
+	toolEnd(toolUseID, name string, failed bool)
+}
+
+type ignoreProgress struct{}
+
+func (ignoreProgress) textDelta(string)             {}
+func (ignoreProgress) toolStart(string, string)     {}
+func (ignoreProgress) toolEnd(string, string, bool) {}
+
+// toolCall is one tool_use block of an assistant message, ready to execute.
+type toolCall struct {
+	id   *string
+	name string
+	exec func() (string, error)
+}
+
+// runToolCalls executes an assistant message's tool calls in order and returns the user
+// message carrying their results.
+func (svc *Service) runToolCalls(calls []toolCall, obs turnObserver) bedrocktypes.Message {
+	results := make([]bedrocktypes.ContentBlock, 0, len(calls))
+	for _, call := range calls {
+		svc.logger.Printf("tool_use: %s", call.name)
+		obs.toolStart(aws.ToString(call.id), call.name)
+
+		resultText, execErr := call.exec()
+		if execErr != nil {
+			svc.logger.Printf("tool %s error: %v", call.name, execErr)
+			resultText = fmt.Sprintf(`{"error":"%v"}`, execErr)
+		}
+		obs.toolEnd(aws.ToString(call.id), call.name, strings.HasPrefix(resultText, `{"error"`))
+
+		results = append(results, &bedrocktypes.ContentBlockMemberToolResult{
+			Value: bedrocktypes.ToolResultBlock{
+				ToolUseId: call.id,
+				Content: []bedrocktypes.ToolResultContentBlock{
+					&bedrocktypes.ToolResultContentBlockMemberText{Value: resultText},
+				},
+			},
+		})
+	}
+	return bedrocktypes.Message{
+		Role:    bedrocktypes.ConversationRoleUser,
+		Content: results,
+	}
+}
+
+// messageText joins the text blocks of an assistant message, or "" when it has none.
+func messageText(content []bedrocktypes.ContentBlock) string {
+	var sb strings.Builder
+	for _, block := range content {
+		if txt, ok := block.(*bedrocktypes.ContentBlockMemberText); ok {
+			sb.WriteString(txt.Value)
+		}
+	}
+	return sb.String()
+}
+
+// buildSystemPrompt constructs the system prompt injecting the caller's identity,
+// current date for temporal context and the summary of turns no longer sent as messages.
+func buildSystemPrompt(ctx ChatContext, summary string) string {
 	var sb strings.Builder
 	sb.WriteString("You are an AI performance management assistant for a SaaS platform. ")
 	sb.WriteString("You help employees, managers and admins understand performance data, track goals, and gain insights.\n\n")
 	sb.WriteString("Use the provided tools to retrieve accurate, real-time data before answering. ")
-	sb.WriteString("Never invent IDs, names or statistics — always fetch them via tools.\n\n")
+	sb.WriteString("Never invent IDs, names or statistics — always fetch them via tools.\n")
+	sb.WriteString("Tools that change data (create_task, log_task_time, add_goal_comment, request_feedback) only propose the change. ")
+	sb.WriteString("The user confirms it in the app, so never say a change has been made.\n\n")
 
 	sb.WriteString(fmt.Sprintf("Current user: %s", ctx.CallerDisplayName))
 	if ctx.CallerUserName != "" {
@@ -222,6 +385,11 @@ func buildSystemPrompt(ctx ChatContext) string {
 		sb.WriteString(fmt.Sprintf("Focus member (manager/admin context): %s\n", ctx.TargetUserID))
 	}
 	sb.WriteString(fmt.Sprintf("Current date (UTC): %s\n", time.Now().UTC().Format("2006-01-02")))
+	if summary != "" {
+		sb.WriteString("\nSummary of the earlier part of this conversation:\n")
+		sb.WriteString(summary)
+		sb.WriteRune('\n')
+	}
 	return sb.String()
 }
 
@@ -239,6 +407,34 @@ func getCognitoIDFromRequest(request events.APIGatewayProxyRequest) (string, err
 	return "", fmt.Errorf("cognito ID not found in request")
 }
 
+// splitPath splits a request path into unescaped segments.
+func splitPath(path string) []string {
+	trimmed := strings.Trim(path, "/")
+	if trimmed == "" {
+		return []string{}
+	}
+	raw := strings.Split(trimmed, "/")
+	out := make([]string, 0, len(raw))
+	for _, p := range raw {
+		if v, err := url.PathUnescape(p); err == nil {
+			out = append(out, v)
+		} else {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// jsonResponse marshals v as the response body.
+func jsonResponse(statusCode int, v interface{}) (events.APIGatewayProxyResponse, error) {
+	body, _ := json.Marshal(v)
+	return events.APIGatewayProxyResponse{
+		StatusCode: statusCode,
+		Headers:    map[string]string{"Content-Type": "application/json"},
+		Body:       string(body),
+	}, nil
+}
+
 // errResponse is a convenience helper that returns a JSON error body.
 func errResponse(statusCode int, message string) (events.APIGatewayProxyResponse, error) {
 	body, _ := json.Marshal(map[string]string{"error": message})
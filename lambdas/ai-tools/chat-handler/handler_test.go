@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	bedrocktypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/stretchr/testify/assert"
+
+	ctrl "github.com/busyfit-admin/saas-integrated-apis/lambdas/ai-tools/controllers"
+	awsclients "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/clients"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite testdata/conversations/*.golden from the current behaviour")
+
+const goldenChatID = "chat-golden"
+
+// conversationScript is a recorded conversation in testdata/conversations: the caller's message,
+// earlier messages of the chat, the model's turns in order and the DynamoDB query results the
+// tools read, in the order they are read.
+type conversationScript struct {
+	Description string            `json:"description"`
+	Message     string            `json:"message"`
+	History     []scriptedMessage `json:"history,omitempty"`
+	Turns       []scriptedTurn    `json:"turns"`
+	Queries     []scriptedQuery   `json:"queries,omitempty"`
+}
+
+type scriptedMessage struct {
+	Role string `json:"role"`
+	Text string `json:"text"`
+}
+
+type scriptedQuery struct {
+	Items []map[string]interface{} `json:"items,omitempty"`
+	Error string                   `json:"error,omitempty"`
+}
+
+// conversationRun is the outcome of one script run through the REST or the streaming endpoint.
+type conversationRun struct {
+	model  *scriptedModel
+	store  *memoryHistoryStore
+	status int
+	body   string
+}
+
+func newConversationService(t *testing.T, script conversationScript) (*Service, *scriptedModel, *memoryHistoryStore) {
+	ddbClient := &awsclients.MockDynamodbClient{
+		// The caller's employee record is read before anything else.
+		QueryOutputs: []dynamodb.QueryOutput{employeeQuery("ann", "sub-ann")},
+		QueryErrors:  []error{nil},
+	}
+	for _, q := range script.Queries {
+		out := dynamodb.QueryOutput{}
+		for _, item := range q.Items {
+			av, err := attributevalue.MarshalMap(item)
+			if err != nil {
+				t.Fatalf("bad query item: %v", err)
+			}
+			out.Items = append(out.Items, av)
+		}
+		out.Count = int32(len(out.Items))
+		var err error
+		if q.Error != "" {
+			err = errors.New(q.Error)
+		}
+		ddbClient.QueryOutputs = append(ddbClient.QueryOutputs, out)
+		ddbClient.QueryErrors = append(ddbClient.QueryErrors, err)
+	}
+
+	store := newMemoryHistoryStore()
+	if len(script.History) > 0 {
+		store.sessions[goldenChatID] = &chatSessionRecord{ChatID: goldenChatID, MsgKey: sessionMsgKey, OwnerID: "sub-ann", MessageCount: len(script.History)}
+		for _, m := range script.History {
+			store.append(goldenChatID, "sub-ann", m.Role, m.Text)
+		}
+	}
+
+	model := &scriptedModel{turns: script.Turns}
+	logger := log.New(&bytes.Buffer{}, "", 0)
+	return &Service{
+		logger: logger,
+		ctrlSVC: ctrl.CreateService(context.TODO(), ddbClient, logger, ctrl.Tables{
+			Employee:               "EmployeeTable-test",
+			EmployeeCognitoIdIndex: "CognitoIdIndex",
+			PerfHub:                "PerfHubTable-test",
+		}),
+		bedrockClient:    model,
+		ddb:              ddbClient,
+		history:          store,
+		chatHistoryTable: testChatTable,
+		modelID:          "model-test",
+	}, model, store
+}
+
+func chatRequestBody(script conversationScript) string {
+	body, _ := json.Marshal(ChatRequest{ChatID: goldenChatID, Message: script.Message})
+	return string(body)
+}
+
+func runREST(t *testing.T, script conversationScript) conversationRun {
+	svc, model, store := newConversationService(t, script)
+	request := events.APIGatewayProxyRequest{
+		Path:       "/v2/ai/chat",
+		HTTPMethod: http.MethodPost,
+		Body:       chatRequestBody(script),
+	}
+	request.RequestContext.Authorizer = map[string]interface{}{"claims": map[string]interface{}{"sub": "sub-ann"}}
+
+	resp, err := svc.Handle(request)
+	assert.NoError(t, err)
+	return conversationRun{model: model, store: store, status: resp.StatusCode, body: resp.Body}
+}
+
+func runStream(t *testing.T, script conversationScript, verifier *cognitoTokenVerifier, token string) conversationRun {
+	svc, model, store := newConversationService(t, script)
+	svc.tokens = verifier
+	request := events.LambdaFunctionURLRequest{
+		Headers: map[string]string{"authorization": token},
+		Body:    chatRequestBody(script),
+	}
+	request.RequestContext.HTTP.Method = http.MethodPost
+
+	resp, err := svc.HandleStream(context.TODO(), request)
+	assert.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return conversationRun{model: model, store: store, status: resp.StatusCode, body: string(body)}
+}
+
+// renderConversation writes what the model saw on its last call, how the script answered it and
+// which messages were saved.
+func renderConversation(run conversationRun, script conversationScript) string {
+	var sb strings.Builder
+	calls := len(run.model.requests)
+	fmt.Fprintf(&sb, "--- model calls: %d\n", calls)
+	if calls > 0 {
+		for _, msg := range run.model.requests[calls-1] {
+			renderMessage(&sb, msg)
+		}
+		last := script.Turns[calls-1]
+		if last.Text != "" {
+			fmt.Fprintf(&sb, "assistant: %s\n", last.Text)
+		}
+		for _, use := range last.ToolUses {
+			fmt.Fprintf(&sb, "assistant: [tool_use %s] %s %s\n", use.ID, use.Name, canonicalJSON(string(use.Input)))
+		}
+		if last.Error != "" {
+			fmt.Fprintf(&sb, "(model error: %s)\n", last.Error)
+		} else {
+			fmt.Fprintf(&sb, "(stop reason: %s)\n", last.StopReason)
+		}
+	}
+
+	sb.WriteString("--- saved\n")
+	saved := run.store.messages[goldenChatID]
+	if len(saved) == len(script.History) {
+		sb.WriteString("(nothing)\n")
+	}
+	for _, rec := range saved[len(script.History):] {
+		fmt.Fprintf(&sb, "%s: %s\n", rec.Role, rec.MessageText)
+	}
+	return sb.String()
+}
+
+func renderMessage(sb *strings.Builder, msg bedrocktypes.Message) {
+	for _, block := range msg.Content {
+		switch b := block.(type) {
+		case *bedrocktypes.ContentBlockMemberText:
+			fmt.Fprintf(sb, "%s: %s\n", msg.Role, b.Value)
+		case *bedrocktypes.ContentBlockMemberToolUse:
+			raw, _ := b.Value.Input.MarshalSmithyDocument()
+			fmt.Fprintf(sb, "%s: [tool_use %s] %s %s\n", msg.Role, aws.ToString(b.Value.ToolUseId), aws.ToString(b.Value.Name), canonicalJSON(string(raw)))
+		case *bedrocktypes.ContentBlockMemberToolResult:
+			for _, c := range b.Value.Content {
+				if txt, ok := c.(*bedrocktypes.ToolResultContentBlockMemberText); ok {
+					fmt.Fprintf(sb, "%s: [tool_result %s] %s\n", msg.Role, aws.ToString(b.Value.ToolUseId), txt.Value)
+				}
+			}
+		}
+	}
+}
+
+// canonicalJSON re-encodes a JSON object with sorted keys; empty input renders as {}.
+func canonicalJSON(raw string) string {
+	v := map[string]interface{}{}
+	_ = json.Unmarshal([]byte(raw), &v)
+	out, _ := json.Marshal(v)
+	return string(out)
+}
+
+// TestGoldenConversations replays every script in testdata/conversations through both the REST
+// and the streaming endpoint. Both must show the model the same conversation and save the same
+// messages; the result is compared with the script's .golden file. Run with -update after an
+// intended behaviour change and review the diff.
+func TestGoldenConversations(t *testing.T) {
+	verifier, key := newTestVerifier(t)
+	token := "Bearer " + signToken(t, key, testKeyID, idClaims(verifier, "sub-ann"))
+
+	files, err := filepath.Glob(filepath.Join("testdata", "conversations", "*.json"))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, files)
+
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".json")
+		t.Run(name, func(t *testing.T) {
+			raw, err := os.ReadFile(file)
+			assert.NoError(t, err)
+			var script conversationScript
+			if err := json.Unmarshal(raw, &script); err != nil {
+				t.Fatalf("invalid script: %v", err)
+			}
+
+			rest := runREST(t, script)
+			stream := runStream(t, script, verifier, token)
+
+			conversation := renderConversation(rest, script)
+			assert.Equal(t, conversation, renderConversation(stream, script), "the streaming endpoint should see and save the same conversation")
+
+			got := fmt.Sprintf("# %s\n%s--- POST /v2/ai/chat %d\n%s\n--- stream %d\n%s",
+				script.Description, conversation, rest.status, rest.body, stream.status, stream.body)
+
+			golden := strings.TrimSuffix(file, ".json") + ".golden"
+			if *updateGolden {
+				assert.NoError(t, os.WriteFile(golden, []byte(got), 0o644))
+			}
+			want, err := os.ReadFile(golden)
+			assert.NoError(t, err)
+			assert.Equal(t, string(want), got)
+		})
+	}
+}
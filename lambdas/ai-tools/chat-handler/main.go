@@ -1,6 +1,8 @@
 package main
 
 import (
+	"os"
+
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 )
@@ -11,6 +13,18 @@ func main() {
 		panic("ai-chat: failed to initialise service: " + err.Error())
 	}
 
+	// The same binary serves the streaming function URL when deployed with response streaming.
+	if os.Getenv("AI_CHAT_RESPONSE_STREAM") == "true" {
+		lambda.Start(svc.HandleStream)
+		return
+	}
+
+	// ...and the one-off job that creates session records for chats that predate them.
+	if os.Getenv("AI_CHAT_SESSION_BACKFILL") == "true" {
+		lambda.Start(svc.HandleSessionBackfill)
+		return
+	}
+
 	lambda.Start(func(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 		return svc.Handle(request)
 	})
@@ -4,17 +4,31 @@
 // and returns the assistant's response.
 package main
 
+import "encoding/json"
+
 // ChatRequest is the JSON body expected at POST /v2/ai/chat.
 type ChatRequest struct {
 	// ChatID identifies an ongoing conversation. If empty, a new UUID is generated.
 	ChatID string `json:"chatId"`
-	// Message is the user's natural-language input.
+	// Message is the user's natural-language input. It is not needed when Action is set.
 	Message string `json:"message"`
 	// Context provides optional frontend-supplied hints about the calling user's scope.
 	Context ChatContextInput `json:"context"`
+	// Action confirms or cancels a pending action returned earlier in this chat.
+	Action *ActionRequest `json:"action,omitempty"`
+}
+
+// ActionRequest decides a PendingAction. ActionID and IdempotencyKey are echoed from the
+// PendingAction; sending the same request again returns the first outcome.
+type ActionRequest struct {
+	ActionID       string `json:"actionId"`
+	IdempotencyKey string `json:"idempotencyKey"`
+	// Decision is "confirm" or "cancel".
+	Decision string `json:"decision"`
 }
 
-// ChatContextInput is the "context" object inside ChatRequest.
+// ChatContextInput is the "context" object inside ChatRequest. Values the caller does not
+// actually hold are dropped by toolAuthorizer.verifyContext before any tool runs.
 type ChatContextInput struct {
 	TeamID string `json:"teamId"`
 	OrgID  string `json:"orgId"`
@@ -27,6 +41,65 @@ type ChatResponse struct {
 	ChatID    string   `json:"chatId"`
 	Response  string   `json:"response"`
 	ToolsUsed []string `json:"toolsUsed"`
+	// PendingActions are changes the assistant proposed in this turn. None of them has run yet.
+	PendingActions []PendingAction `json:"pendingActions,omitempty"`
+	// ActionResult is the outcome of the request's Action.
+	ActionResult *ActionResult `json:"actionResult,omitempty"`
+}
+
+// PendingAction is a change proposed by the assistant that the user must confirm before
+// ConfirmBy (RFC 3339).
+type PendingAction struct {
+	ActionID       string                 `json:"actionId"`
+	IdempotencyKey string                 `json:"idempotencyKey"`
+	Tool           string                 `json:"tool"`
+	Summary        string                 `json:"summary"`
+	Input          map[string]interface{} `json:"input"`
+	ConfirmBy      string                 `json:"confirmBy"`
+}
+
+// ActionResult reports what happened to a decided action. Status is "executed", "failed" or
+// "cancelled"; Replayed is true when the action had already been decided by an earlier request.
+type ActionResult struct {
+	ActionID string          `json:"actionId"`
+	Tool     string          `json:"tool"`
+	Summary  string          `json:"summary"`
+	Status   string          `json:"status"`
+	Result   json.RawMessage `json:"result,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	Replayed bool            `json:"replayed,omitempty"`
+}
+
+// ChatSession is one entry of the caller's chat list.
+type ChatSession struct {
+	ChatID string `json:"chatId"`
+	// Title is generated from the first message until the user renames the chat.
+	Title          string `json:"title"`
+	MessageCount   int    `json:"messageCount"`
+	CreatedAt      string `json:"createdAt"`
+	LastActivityAt string `json:"lastActivityAt"`
+}
+
+// ChatSessionList is returned by GET /v2/ai/chat/sessions. NextCursor is empty on the last page.
+type ChatSessionList struct {
+	Sessions   []ChatSession `json:"sessions"`
+	NextCursor string        `json:"nextCursor,omitempty"`
+}
+
+// ChatMessage is one message of an exported transcript.
+type ChatMessage struct {
+	Role      string `json:"role"` // "user" | "assistant"
+	Text      string `json:"text"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// ChatSessionExport is returned by GET /v2/ai/chat/sessions/{chatId}/export. Summary is the
+// rolling summary the assistant uses in place of the oldest messages.
+type ChatSessionExport struct {
+	ChatSession
+	Summary    string        `json:"summary,omitempty"`
+	Messages   []ChatMessage `json:"messages"`
+	ExportedAt string        `json:"exportedAt"`
 }
 
 // ChatContext holds resolved runtime context about the caller. It is derived
@@ -42,6 +115,7 @@ type ChatContext struct {
 
 // chatHistoryRecord is the DynamoDB item shape for chat history.
 // PK = chatId, SK = {epoch_millis_padded}#{uuid}.
+// Messages sort before every other item in the chat's partition (actions, audits, session).
 type chatHistoryRecord struct {
 	ChatID      string `dynamodbav:"chatId"`
 	MsgKey      string `dynamodbav:"msgKey"`
@@ -0,0 +1,581 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	ctrl "github.com/busyfit-admin/saas-integrated-apis/lambdas/ai-tools/controllers"
+	companylib "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/company-lib"
+)
+
+// errToolDenied is returned by the authorizer when the caller may not run a tool with the given input.
+var errToolDenied = errors.New("not authorised")
+
+// callerRole is the highest role the caller holds over the data a tool call reads.
+type callerRole int
+
+const (
+	roleMember callerRole = iota
+	roleManager
+	roleOrgAdmin
+)
+
+func (r callerRole) String() string {
+	switch r {
+	case roleManager:
+		return "manager"
+	case roleOrgAdmin:
+		return "org-admin"
+	default:
+		return "member"
+	}
+}
+
+// toolScope says whose data a tool reaches and therefore what the caller must be.
+type toolScope int
+
+const (
+	// scopeSelf tools read the caller's own records. Naming another user needs manager rights over them.
+	scopeSelf toolScope = iota
+	// scopeTeam tools read team-wide data. The caller must be an active member of the team.
+	scopeTeam
+	// scopeManager tools read a team member's records. The caller must be an admin or owner of the
+	// team, or an admin of the organisation the team belongs to.
+	scopeManager
+	// scopeOrg tools read organisation-wide performance data. The caller must belong to the organisation.
+	scopeOrg
+	// scopeOrgAdmin tools read the people directory or admin data. The caller must be an organisation admin.
+	scopeOrgAdmin
+)
+
+// toolPolicy describes how one tool is authorised.
+type toolPolicy struct {
+	scope toolScope
+	// userField is the input naming the user whose data is read, if the tool takes one.
+	userField string
+	// orgFromTeam replaces the orgId input with the organisation the team belongs to.
+	orgFromTeam bool
+	// writes marks tools that change data in a team. Even on their own data the caller must be an
+	// active member of that team.
+	writes bool
+	// record and recordField name the performance record kind ("cycle", "goal", ...) and the input
+	// holding its ID for tools that read a record by ID. The record must belong to the caller's
+	// organisation.
+	record, recordField string
+}
+
+// toolPolicies classifies every tool in toolRegistry and actionRegistry. A tool without a policy is
+// never run.
+var toolPolicies = map[string]toolPolicy{
+	// Employee
+	"get_employee_information": {scope: scopeOrg, userField: "userName"},
+	"get_all_employees":        {scope: scopeOrgAdmin},
+	"find_employee_by_email":   {scope: scopeOrgAdmin},
+	"get_all_employee_groups":  {scope: scopeOrgAdmin},
+	// Team
+	"get_team_information":      {scope: scopeTeam},
+	"get_all_org_teams":         {scope: scopeOrg},
+	"get_team_members":          {scope: scopeTeam},
+	"get_team_member_directory": {scope: scopeTeam},
+	"get_user_teams":            {scope: scopeSelf, userField: "userName"},
+	"is_team_admin":             {scope: scopeTeam},
+	// Org
+	"get_org_info":   {scope: scopeOrg},
+	"get_org_admins": {scope: scopeOrgAdmin},
+	"get_org_users":  {scope: scopeOrgAdmin},
+	"is_org_admin":   {scope: scopeOrg, userField: "userName"},
+	// Performance cycles
+	"get_performance_cycles":        {scope: scopeOrg},
+	"get_performance_cycle_details": {scope: scopeOrg, record: "cycle", recordField: "cycleId"},
+	"get_cycle_analytics":           {scope: scopeOrg, record: "cycle", recordField: "cycleId"},
+	"get_all_quarters":              {scope: scopeOrg, record: "cycle", recordField: "cycleId"},
+	"get_quarter_details":           {scope: scopeOrg, record: "quarter", recordField: "quarterId"},
+	"get_quarter_analytics":         {scope: scopeOrg, record: "quarter", recordField: "quarterId"},
+	"get_quarter_meeting_notes":     {scope: scopeOrg, record: "quarter", recordField: "quarterId"},
+	// KPIs / OKRs
+	"get_all_kpis":   {scope: scopeOrg},
+	"get_kpi_detail": {scope: scopeOrg, record: "kpi", recordField: "kpiId"},
+	"get_all_okrs":   {scope: scopeOrg},
+	"get_okr_detail": {scope: scopeOrg, record: "okr", recordField: "okrId"},
+	// Org goals
+	"get_org_goal_detail":         {scope: scopeOrg, record: "goal", recordField: "goalId"},
+	"get_team_org_goals":          {scope: scopeTeam, orgFromTeam: true},
+	"get_org_goal_sub_items":      {scope: scopeOrg, record: "goal", recordField: "goalId"},
+	"get_user_goals_for_org_goal": {scope: scopeOrgAdmin, record: "goal", recordField: "orgGoalId"},
+	"get_goal_ladder_up":          {scope: scopeOrg, record: "goal", recordField: "goalId"},
+	"get_goal_value_history":      {scope: scopeOrg, record: "goal", recordField: "goalId"},
+	"get_goal_tasks":              {scope: scopeOrg, record: "goal", recordField: "goalId"},
+	"get_goal_tagged_teams":       {scope: scopeOrg, record: "goal", recordField: "goalId"},
+	// User goals, tasks, meetings and appreciations
+	"get_my_goals":             {scope: scopeSelf, userField: "userName"},
+	"get_my_goal":              {scope: scopeSelf, userField: "userName"},
+	"get_goal_linked_tasks":    {scope: scopeSelf, userField: "userName"},
+	"get_goal_comments":        {scope: scopeSelf, userField: "userName"},
+	"get_all_tasks":            {scope: scopeSelf, userField: "userName"},
+	"get_task":                 {scope: scopeSelf, userField: "userName"},
+	"get_my_meetings":          {scope: scopeSelf, userField: "userName"},
+	"get_meeting":              {scope: scopeSelf, userField: "userName"},
+	"get_my_appreciations":     {scope: scopeSelf, userField: "userName"},
+	"get_my_feedback_requests": {scope: scopeSelf, userField: "userName"},
+	// Manager — team member performance
+	"get_team_performance_members":   {scope: scopeManager},
+	"get_member_goals":               {scope: scopeManager, userField: "memberId"},
+	"get_member_tasks":               {scope: scopeManager, userField: "memberId"},
+	"get_member_meetings":            {scope: scopeManager, userField: "memberId"},
+	"get_member_appreciations":       {scope: scopeManager, userField: "memberId"},
+	"get_member_manager_comments":    {scope: scopeManager, userField: "memberId"},
+	"get_member_performance_summary": {scope: scopeManager, userField: "memberId"},
+	// Actions
+	"create_task":      {scope: scopeSelf, userField: "userName", writes: true},
+	"log_task_time":    {scope: scopeSelf, userField: "userName", writes: true},
+	"add_goal_comment": {scope: scopeSelf, userField: "userName", writes: true},
+	"request_feedback": {scope: scopeSelf, userField: "userName", writes: true},
+	"give_kudos":       {scope: scopeSelf, userField: "userName", writes: true},
+}
+
+// redactedFields maps result fields (lower-cased) to the lowest role allowed to see them. They are
+// stripped at any depth unless the caller is reading their own data.
+var redactedFields = map[string]callerRole{
+	// Employee identity and contact details
+	"cognitoid":   roleOrgAdmin,
+	"externalid":  roleOrgAdmin,
+	"phonenumber": roleOrgAdmin,
+	"logintype":   roleOrgAdmin,
+	"rolesdata":   roleOrgAdmin,
+	// Organisation billing
+	"taxid":                roleOrgAdmin,
+	"contactphone":         roleOrgAdmin,
+	"billingmode":          roleOrgAdmin,
+	"billingplan":          roleOrgAdmin,
+	"orgbillingstatus":     roleOrgAdmin,
+	"appliedpromocode":     roleOrgAdmin,
+	"promodiscountpercent": roleOrgAdmin,
+	"promodiscountamount":  roleOrgAdmin,
+	"promovaliduntil":      roleOrgAdmin,
+	"billingstartdate":     roleOrgAdmin,
+	"nextbillingdate":      roleOrgAdmin,
+	"lastpaymentdate":      roleOrgAdmin,
+	"creditbalance":        roleOrgAdmin,
+	"outstandinginvoiceid": roleOrgAdmin,
+	"invitationid":         roleOrgAdmin,
+	// Review outcomes
+	"overallrating":         roleManager,
+	"lastreviewdate":        roleManager,
+	"ispendingreview":       roleManager,
+	"hasuserupdatedreviews": roleManager,
+}
+
+// toolAccess is what the authorizer granted for one tool call.
+type toolAccess struct {
+	role callerRole
+	// self is true when the call only reads the caller's own data.
+	self bool
+}
+
+// toolAuthorizer enforces toolPolicies for one chat request. Membership lookups are cached for the
+// request because the model often calls several tools against the same team or organisation.
+type toolAuthorizer struct {
+	svc    *ctrl.Service
+	logger *log.Logger
+	caller string
+
+	members    map[string]*companylib.TeamMember // "{teamId}|{userName}" → active membership or nil
+	teamOrgs   map[string]string
+	orgMembers map[string]bool // "{orgId}|{userName}"
+	orgAdmins  map[string]bool // "{orgId}|{userName}"
+}
+
+// newToolAuthorizer returns an authorizer for calls made on behalf of callerUserName.
+func newToolAuthorizer(svc *ctrl.Service, logger *log.Logger, callerUserName string) *toolAuthorizer {
+	return &toolAuthorizer{
+		svc:        svc,
+		logger:     logger,
+		caller:     callerUserName,
+		members:    map[string]*companylib.TeamMember{},
+		teamOrgs:   map[string]string{},
+		orgMembers: map[string]bool{},
+		orgAdmins:  map[string]bool{},
+	}
+}
+
+// verifyContext drops the frontend-supplied team, organisation and focus member when the caller does
+// not hold them, so neither the system prompt nor tool defaults can point at data the caller can't see.
+func (a *toolAuthorizer) verifyContext(chatCtx ChatContext) ChatContext {
+	if a.caller == "" {
+		chatCtx.CallerTeamID, chatCtx.CallerOrgID, chatCtx.TargetUserID = "", "", ""
+		return chatCtx
+	}
+
+	if chatCtx.CallerTeamID != "" {
+		if member, err := a.teamMember(chatCtx.CallerTeamID, a.caller); err != nil || member == nil {
+			a.logger.Printf("policy: dropped context teamId=%q caller=%q err=%v", chatCtx.CallerTeamID, a.caller, err)
+			chatCtx.CallerTeamID = ""
+		}
+	}
+	if chatCtx.CallerOrgID != "" {
+		if _, err := a.orgRole(chatCtx.CallerOrgID); err != nil {
+			a.logger.Printf("policy: dropped context orgId=%q caller=%q: %v", chatCtx.CallerOrgID, a.caller, err)
+			chatCtx.CallerOrgID = ""
+		}
+	}
+	if chatCtx.TargetUserID != "" && chatCtx.TargetUserID != a.caller {
+		if _, err := a.manage(chatCtx.CallerTeamID, chatCtx.TargetUserID); err != nil {
+			a.logger.Printf("policy: dropped context targetUserId=%q caller=%q: %v", chatCtx.TargetUserID, a.caller, err)
+			chatCtx.TargetUserID = ""
+		}
+	}
+	return chatCtx
+}
+
+// authorize checks one tool call against its policy. On success the team, organisation and user
+// inputs are rewritten to the values that were checked, so the executor can't fall back to anything else.
+func (a *toolAuthorizer) authorize(toolName string, input map[string]interface{}, chatCtx ChatContext) (toolAccess, error) {
+	policy, ok := toolPolicies[toolName]
+	if !ok {
+		return toolAccess{}, fmt.Errorf("%w: %s has no access policy", errToolDenied, toolName)
+	}
+	if a.caller == "" {
+		return toolAccess{}, fmt.Errorf("%w: caller is not a known employee", errToolDenied)
+	}
+
+	user := ""
+	if policy.userField != "" {
+		fallback := a.caller
+		if policy.scope == scopeManager {
+			fallback = chatCtx.TargetUserID
+		}
+		user = withDefault(getStr(input, policy.userField), fallback)
+		input[policy.userField] = user
+	}
+
+	switch policy.scope {
+	case scopeSelf:
+		return a.authorizeSelf(input, chatCtx, policy, user)
+	case scopeTeam:
+		return a.authorizeTeam(input, chatCtx, policy)
+	case scopeManager:
+		return a.authorizeManager(input, chatCtx, policy, user)
+	case scopeOrg, scopeOrgAdmin:
+		return a.authorizeOrg(input, chatCtx, policy, user)
+	}
+	return toolAccess{}, fmt.Errorf("%w: %s has an unknown scope", errToolDenied, toolName)
+}
+
+func (a *toolAuthorizer) authorizeSelf(input map[string]interface{}, chatCtx ChatContext, policy toolPolicy, user string) (toolAccess, error) {
+	teamID := withDefault(getStr(input, "teamId"), chatCtx.CallerTeamID)
+	if teamID != "" {
+		input["teamId"] = teamID
+	}
+	if policy.writes && teamID == "" {
+		return toolAccess{}, fmt.Errorf("%w: teamId is required", errToolDenied)
+	}
+	if user == a.caller {
+		if policy.writes {
+			member, err := a.teamMember(teamID, a.caller)
+			if err != nil {
+				return toolAccess{}, err
+			}
+			if member == nil {
+				return toolAccess{}, fmt.Errorf("%w: caller is not a member of team %s", errToolDenied, teamID)
+			}
+		}
+		return toolAccess{role: roleMember, self: true}, nil
+	}
+
+	if teamID == "" {
+		// Without a team only an org admin may look up another member of their organisation.
+		role, err := a.orgRole(chatCtx.CallerOrgID)
+		if err != nil {
+			return toolAccess{}, err
+		}
+		if role != roleOrgAdmin {
+			return toolAccess{}, fmt.Errorf("%w: only org admins can read another member's data without a team", errToolDenied)
+		}
+		if err := a.requireOrgUser(chatCtx.CallerOrgID, user); err != nil {
+			return toolAccess{}, err
+		}
+		return toolAccess{role: roleOrgAdmin}, nil
+	}
+
+	role, err := a.manage(teamID, user)
+	if err != nil {
+		return toolAccess{}, err
+	}
+	return toolAccess{role: role}, nil
+}
+
+func (a *toolAuthorizer) authorizeTeam(input map[string]interface{}, chatCtx ChatContext, policy toolPolicy) (toolAccess, error) {
+	teamID := withDefault(getStr(input, "teamId"), chatCtx.CallerTeamID)
+	if teamID == "" {
+		return toolAccess{}, fmt.Errorf("%w: teamId is required", errToolDenied)
+	}
+	input["teamId"] = teamID
+
+	role, err := a.teamRole(teamID)
+	if err != nil {
+		return toolAccess{}, err
+	}
+	if policy.orgFromTeam {
+		orgID, err := a.teamOrg(teamID)
+		if err != nil {
+			return toolAccess{}, err
+		}
+		input["orgId"] = orgID
+	}
+	return toolAccess{role: role}, nil
+}
+
+func (a *toolAuthorizer) authorizeManager(input map[string]interface{}, chatCtx ChatContext, policy toolPolicy, member string) (toolAccess, error) {
+	teamID := withDefault(getStr(input, "teamId"), chatCtx.CallerTeamID)
+	if teamID == "" {
+		return toolAccess{}, fmt.Errorf("%w: teamId is required", errToolDenied)
+	}
+	input["teamId"] = teamID
+
+	if policy.userField != "" {
+		if member == "" {
+			return toolAccess{}, fmt.Errorf("%w: %s is required", errToolDenied, policy.userField)
+		}
+		role, err := a.manage(teamID, member)
+		if err != nil {
+			return toolAccess{}, err
+		}
+		return toolAccess{role: role}, nil
+	}
+
+	role, err := a.teamRole(teamID)
+	if err != nil {
+		return toolAccess{}, err
+	}
+	if role < roleManager {
+		return toolAccess{}, fmt.Errorf("%w: only managers of team %s can read its members' performance", errToolDenied, teamID)
+	}
+	return toolAccess{role: role}, nil
+}
+
+func (a *toolAuthorizer) authorizeOrg(input map[string]interface{}, chatCtx ChatContext, policy toolPolicy, user string) (toolAccess, error) {
+	orgID := withDefault(getStr(input, "orgId"), chatCtx.CallerOrgID)
+	if orgID == "" {
+		return toolAccess{}, fmt.Errorf("%w: orgId is required", errToolDenied)
+	}
+	input["orgId"] = orgID
+
+	role, err := a.orgRole(orgID)
+	if err != nil {
+		return toolAccess{}, err
+	}
+	if policy.scope == scopeOrgAdmin && role != roleOrgAdmin {
+		return toolAccess{}, fmt.Errorf("%w: only org admins can use this tool", errToolDenied)
+	}
+
+	if user != "" && user != a.caller {
+		if err := a.requireOrgUser(orgID, user); err != nil {
+			return toolAccess{}, err
+		}
+	}
+
+	if policy.record != "" {
+		recordID := getStr(input, policy.recordField)
+		owner, err := a.svc.GetRecordOrganization(policy.record, recordID)
+		if err != nil {
+			return toolAccess{}, fmt.Errorf("could not check %s %s: %w", policy.record, recordID, err)
+		}
+		if owner != "" && normalizeOrgID(owner) != normalizeOrgID(orgID) {
+			return toolAccess{}, fmt.Errorf("%w: %s %s belongs to another organisation", errToolDenied, policy.record, recordID)
+		}
+	}
+	return toolAccess{role: role, self: user == a.caller}, nil
+}
+
+// manage checks the caller may read member's data in teamID: the member must be on the team, and the
+// caller must be its admin/owner or an admin of the team's organisation.
+func (a *toolAuthorizer) manage(teamID, member string) (callerRole, error) {
+	if teamID == "" {
+		return roleMember, fmt.Errorf("%w: teamId is required to read another member's data", errToolDenied)
+	}
+	role, err := a.teamRole(teamID)
+	if err != nil {
+		return roleMember, err
+	}
+	if role < roleManager {
+		return roleMember, fmt.Errorf("%w: only managers of team %s can read another member's data", errToolDenied, teamID)
+	}
+
+	target, err := a.teamMember(teamID, member)
+	if err != nil {
+		return roleMember, err
+	}
+	if target == nil {
+		return roleMember, fmt.Errorf("%w: %s is not a member of team %s", errToolDenied, member, teamID)
+	}
+	return role, nil
+}
+
+// teamRole returns the caller's role over teamID, falling back to org admin of the team's organisation
+// when the caller is not on the team.
+func (a *toolAuthorizer) teamRole(teamID string) (callerRole, error) {
+	member, err := a.teamMember(teamID, a.caller)
+	if err != nil {
+		return roleMember, err
+	}
+	if member != nil && (member.Role == companylib.TeamMemberRoleAdmin || member.Role == companylib.TeamMemberRoleOwner) {
+		return roleManager, nil
+	}
+
+	orgID, err := a.teamOrg(teamID)
+	if err != nil {
+		return roleMember, err
+	}
+	if orgID != "" {
+		admin, err := a.isOrgAdmin(orgID, a.caller)
+		if err != nil {
+			return roleMember, err
+		}
+		if admin {
+			return roleOrgAdmin, nil
+		}
+	}
+
+	if member == nil {
+		return roleMember, fmt.Errorf("%w: caller is not a member of team %s", errToolDenied, teamID)
+	}
+	return roleMember, nil
+}
+
+// orgRole returns the caller's role in orgID, or a denial when the caller does not belong to it.
+func (a *toolAuthorizer) orgRole(orgID string) (callerRole, error) {
+	if orgID == "" {
+		return roleMember, fmt.Errorf("%w: orgId is required", errToolDenied)
+	}
+	admin, err := a.isOrgAdmin(orgID, a.caller)
+	if err != nil {
+		return roleMember, err
+	}
+	if admin {
+		return roleOrgAdmin, nil
+	}
+	member, err := a.isOrgMember(orgID, a.caller)
+	if err != nil {
+		return roleMember, err
+	}
+	if !member {
+		return roleMember, fmt.Errorf("%w: caller is not a member of organisation %s", errToolDenied, orgID)
+	}
+	return roleMember, nil
+}
+
+// requireOrgUser checks that userName is a member or admin of orgID.
+func (a *toolAuthorizer) requireOrgUser(orgID, userName string) error {
+	member, err := a.isOrgMember(orgID, userName)
+	if err != nil {
+		return err
+	}
+	if !member {
+		if member, err = a.isOrgAdmin(orgID, userName); err != nil {
+			return err
+		}
+	}
+	if !member {
+		return fmt.Errorf("%w: %s is not a member of organisation %s", errToolDenied, userName, orgID)
+	}
+	return nil
+}
+
+// teamMember returns the active membership of userName in teamID, or nil.
+func (a *toolAuthorizer) teamMember(teamID, userName string) (*companylib.TeamMember, error) {
+	key := teamID + "|" + userName
+	if member, ok := a.members[key]; ok {
+		return member, nil
+	}
+	member, err := a.svc.GetTeamMemberDetail(teamID, userName)
+	if err != nil {
+		return nil, fmt.Errorf("could not check membership of team %s: %w", teamID, err)
+	}
+	if member != nil && !member.IsActive {
+		member = nil
+	}
+	a.members[key] = member
+	return member, nil
+}
+
+// teamOrg returns the organisation teamID belongs to.
+func (a *toolAuthorizer) teamOrg(teamID string) (string, error) {
+	if orgID, ok := a.teamOrgs[teamID]; ok {
+		return orgID, nil
+	}
+	team, err := a.svc.TeamInformation(teamID)
+	if err != nil {
+		return "", fmt.Errorf("could not load team %s: %w", teamID, err)
+	}
+	a.teamOrgs[teamID] = team.OrgId
+	return team.OrgId, nil
+}
+
+func (a *toolAuthorizer) isOrgAdmin(orgID, userName string) (bool, error) {
+	key := normalizeOrgID(orgID) + "|" + userName
+	if admin, ok := a.orgAdmins[key]; ok {
+		return admin, nil
+	}
+	admin, err := a.svc.IsOrgAdmin(orgID, userName)
+	if err != nil {
+		return false, fmt.Errorf("could not check admins of organisation %s: %w", orgID, err)
+	}
+	a.orgAdmins[key] = admin
+	return admin, nil
+}
+
+func (a *toolAuthorizer) isOrgMember(orgID, userName string) (bool, error) {
+	key := normalizeOrgID(orgID) + "|" + userName
+	if member, ok := a.orgMembers[key]; ok {
+		return member, nil
+	}
+	member, err := a.svc.IsOrgMember(orgID, userName)
+	if err != nil {
+		return false, fmt.Errorf("could not check members of organisation %s: %w", orgID, err)
+	}
+	a.orgMembers[key] = member
+	return member, nil
+}
+
+// normalizeOrgID strips the ORG# key prefix so stored and request organisation IDs compare equal.
+func normalizeOrgID(orgID string) string {
+	return strings.TrimPrefix(orgID, "ORG#")
+}
+
+// redactResult removes fields the caller's role may not see. Results are round-tripped through JSON
+// so struct and map results are handled alike.
+func redactResult(result interface{}, access toolAccess) (interface{}, error) {
+	if access.self || access.role == roleOrgAdmin {
+		return result, nil
+	}
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return nil, err
+	}
+	return redactValue(generic, access.role), nil
+}
+
+func redactValue(v interface{}, role callerRole) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for key, field := range val {
+			if minRole, ok := redactedFields[strings.ToLower(key)]; ok && role < minRole {
+				delete(val, key)
+				continue
+			}
+			val[key] = redactValue(field, role)
+		}
+	case []interface{}:
+		for i, item := range val {
+			val[i] = redactValue(item, role)
+		}
+	}
+	return v
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodb_types "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+
+	ctrl "github.com/busyfit-admin/saas-integrated-apis/lambdas/ai-tools/controllers"
+	awsclients "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/clients"
+	companylib "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/company-lib"
+)
+
+func newTestAuthorizer(ddbClient *awsclients.MockDynamodbClient, caller string) (*toolAuthorizer, *bytes.Buffer) {
+	logs := &bytes.Buffer{}
+	logger := log.New(logs, "", 0)
+	svc := ctrl.CreateService(context.TODO(), ddbClient, logger, ctrl.Tables{
+		Teams:          "TeamsTable-test",
+		Organization:   "OrganizationTable-test",
+		OrgPerformance: "OrgPerformanceTable-test",
+		PerfHub:        "PerfHubTable-test",
+		TeamFeed:       "TeamFeedTable-test",
+	})
+	return newToolAuthorizer(svc, logger, caller), logs
+}
+
+func memberItem(teamID, userName string, role companylib.TeamMemberRole) dynamodb.GetItemOutput {
+	item, _ := attributevalue.MarshalMap(companylib.TeamMember{PK: teamID, SK: "USER#" + userName, TeamId: teamID, UserName: userName, Role: role, IsActive: true})
+	return dynamodb.GetItemOutput{Item: item}
+}
+
+func teamItem(teamID, orgID string) dynamodb.GetItemOutput {
+	item, _ := attributevalue.MarshalMap(companylib.TeamMetadata{PK: teamID, SK: "METADATA", TeamId: teamID, OrgId: orgID})
+	return dynamodb.GetItemOutput{Item: item}
+}
+
+func orgItem(orgID string) dynamodb.GetItemOutput {
+	item, _ := attributevalue.MarshalMap(companylib.Organization{PK: "ORG#" + orgID, SK: "METADATA", OrganizationId: orgID, OrgBillingStatus: companylib.OrgBillingStatusActive})
+	return dynamodb.GetItemOutput{Item: item}
+}
+
+func orgAdminItem(orgID, userName string) dynamodb.GetItemOutput {
+	item, _ := attributevalue.MarshalMap(companylib.OrgAdmin{PK: "ORG#" + orgID, SK: "ADMIN#" + userName, UserName: userName, IsActive: true})
+	return dynamodb.GetItemOutput{Item: item}
+}
+
+func orgUserItem(orgID, userName string) dynamodb.GetItemOutput {
+	item, _ := attributevalue.MarshalMap(companylib.OrgUser{PK: "ORG#" + orgID, SK: "USER#" + userName, UserName: userName, IsActive: true, Status: "ACTIVE"})
+	return dynamodb.GetItemOutput{Item: item}
+}
+
+func recordQuery(orgID string) dynamodb.QueryOutput {
+	item, _ := attributevalue.MarshalMap(companylib.PerformanceRecord{OrganizationId: "ORG#" + orgID})
+	return dynamodb.QueryOutput{Items: []map[string]dynamodb_types.AttributeValue{item}}
+}
+
+func TestToolPoliciesCoverRegistry(t *testing.T) {
+	for name := range toolRegistry {
+		_, ok := toolPolicies[name]
+		assert.True(t, ok, "%s has no access policy", name)
+	}
+	for name := range actionRegistry {
+		policy, ok := toolPolicies[name]
+		assert.True(t, ok && policy.writes, "%s has no write policy", name)
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	chatCtx := ChatContext{CallerUserName: "ann", CallerTeamID: "TEAM#1", CallerOrgID: "org-1"}
+
+	tests := []struct {
+		name         string
+		caller       string
+		tool         string
+		input        map[string]interface{}
+		getItems     []dynamodb.GetItemOutput
+		queries      []dynamodb.QueryOutput
+		expectedRole callerRole
+		expectedSelf bool
+		denied       bool
+		checkInput   map[string]interface{}
+	}{
+		{
+			name:         "It should let anyone read their own goals in their current team",
+			caller:       "ann",
+			tool:         "get_my_goals",
+			input:        map[string]interface{}{},
+			expectedSelf: true,
+			checkInput:   map[string]interface{}{"userName": "ann", "teamId": "TEAM#1"},
+		},
+		{
+			name:     "It should not let a member read a teammate's goals",
+			caller:   "ann",
+			tool:     "get_my_goals",
+			input:    map[string]interface{}{"userName": "bob"},
+			getItems: []dynamodb.GetItemOutput{memberItem("TEAM#1", "ann", companylib.TeamMemberRoleMember), teamItem("TEAM#1", "org-1"), {}},
+			denied:   true,
+		},
+		{
+			name:         "It should let a team admin read a member's manager comments",
+			caller:       "ann",
+			tool:         "get_member_manager_comments",
+			input:        map[string]interface{}{"memberId": "bob"},
+			getItems:     []dynamodb.GetItemOutput{memberItem("TEAM#1", "ann", companylib.TeamMemberRoleAdmin), memberItem("TEAM#1", "bob", companylib.TeamMemberRoleMember)},
+			expectedRole: roleManager,
+			checkInput:   map[string]interface{}{"memberId": "bob", "teamId": "TEAM#1"},
+		},
+		{
+			name:     "It should not let a member read another member's manager comments",
+			caller:   "ann",
+			tool:     "get_member_manager_comments",
+			input:    map[string]interface{}{"memberId": "bob"},
+			getItems: []dynamodb.GetItemOutput{memberItem("TEAM#1", "ann", companylib.TeamMemberRoleMember), teamItem("TEAM#1", "org-1"), {}},
+			denied:   true,
+		},
+		{
+			name:     "It should not let a team admin reach someone outside the team",
+			caller:   "ann",
+			tool:     "get_member_goals",
+			input:    map[string]interface{}{"memberId": "zed"},
+			getItems: []dynamodb.GetItemOutput{memberItem("TEAM#1", "ann", companylib.TeamMemberRoleOwner), {}},
+			denied:   true,
+		},
+		{
+			name:         "It should let an org admin manage a team they are not on",
+			caller:       "ann",
+			tool:         "get_member_performance_summary",
+			input:        map[string]interface{}{"teamId": "TEAM#2", "memberId": "bob"},
+			getItems:     []dynamodb.GetItemOutput{{}, teamItem("TEAM#2", "org-1"), orgAdminItem("org-1", "ann"), memberItem("TEAM#2", "bob", companylib.TeamMemberRoleMember)},
+			expectedRole: roleOrgAdmin,
+		},
+		{
+			name:     "It should need a member for member tools",
+			caller:   "ann",
+			tool:     "get_member_tasks",
+			input:    map[string]interface{}{},
+			denied:   true,
+			getItems: []dynamodb.GetItemOutput{},
+		},
+		{
+			name:     "It should not list team members to someone outside the team",
+			caller:   "ann",
+			tool:     "get_team_members",
+			input:    map[string]interface{}{"teamId": "TEAM#9"},
+			getItems: []dynamodb.GetItemOutput{{}, teamItem("TEAM#9", "org-2"), {}},
+			denied:   true,
+		},
+		{
+			name:         "It should take the organisation from the team for team goals",
+			caller:       "ann",
+			tool:         "get_team_org_goals",
+			input:        map[string]interface{}{"orgId": "org-2"},
+			getItems:     []dynamodb.GetItemOutput{memberItem("TEAM#1", "ann", companylib.TeamMemberRoleMember), teamItem("TEAM#1", "org-1"), {}},
+			expectedRole: roleMember,
+			checkInput:   map[string]interface{}{"teamId": "TEAM#1", "orgId": "org-1"},
+		},
+		{
+			name:     "It should keep the employee table to org admins",
+			caller:   "ann",
+			tool:     "get_all_employees",
+			input:    map[string]interface{}{},
+			getItems: []dynamodb.GetItemOutput{{}, orgUserItem("org-1", "ann")},
+			denied:   true,
+		},
+		{
+			name:         "It should give org admins the employee table",
+			caller:       "ann",
+			tool:         "get_all_employees",
+			input:        map[string]interface{}{},
+			getItems:     []dynamodb.GetItemOutput{orgAdminItem("org-1", "ann")},
+			expectedRole: roleOrgAdmin,
+		},
+		{
+			name:     "It should keep org admins to their own organisation",
+			caller:   "ann",
+			tool:     "get_org_admins",
+			input:    map[string]interface{}{"orgId": "org-2"},
+			getItems: []dynamodb.GetItemOutput{{}, {}},
+			denied:   true,
+		},
+		{
+			name:         "It should let members read their organisation's KPIs",
+			caller:       "ann",
+			tool:         "get_kpi_detail",
+			input:        map[string]interface{}{"kpiId": "kpi-1"},
+			getItems:     []dynamodb.GetItemOutput{{}, orgUserItem("org-1", "ann")},
+			queries:      []dynamodb.QueryOutput{recordQuery("org-1")},
+			expectedRole: roleMember,
+		},
+		{
+			name:     "It should not read a KPI that belongs to another organisation",
+			caller:   "ann",
+			tool:     "get_kpi_detail",
+			input:    map[string]interface{}{"kpiId": "kpi-9"},
+			getItems: []dynamodb.GetItemOutput{{}, orgUserItem("org-1", "ann")},
+			queries:  []dynamodb.QueryOutput{recordQuery("org-2")},
+			denied:   true,
+		},
+		{
+			name:     "It should not look up employees outside the organisation",
+			caller:   "ann",
+			tool:     "get_employee_information",
+			input:    map[string]interface{}{"userName": "eve"},
+			getItems: []dynamodb.GetItemOutput{{}, orgUserItem("org-1", "ann"), {}, {}},
+			denied:   true,
+		},
+		{
+			name:   "It should deny everything to a caller who is not a known employee",
+			caller: "",
+			tool:   "get_my_goals",
+			input:  map[string]interface{}{},
+			denied: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ddbClient := awsclients.MockDynamodbClient{
+				GetItemOutputs: test.getItems,
+				GetItemErrors:  make([]error, len(test.getItems)),
+				QueryOutputs:   test.queries,
+				QueryErrors:    make([]error, len(test.queries)),
+			}
+			authz, _ := newTestAuthorizer(&ddbClient, test.caller)
+
+			access, err := authz.authorize(test.tool, test.input, chatCtx)
+
+			assert.Len(t, ddbClient.GetItemInputs, len(test.getItems))
+			if test.denied {
+				assert.ErrorIs(t, err, errToolDenied)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedRole, access.role)
+			assert.Equal(t, test.expectedSelf, access.self)
+			for key, value := range test.checkInput {
+				assert.Equal(t, value, test.input[key], key)
+			}
+		})
+	}
+}
+
+func TestVerifyContext(t *testing.T) {
+	t.Run("It should drop a team, organisation and focus member the caller does not hold", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			GetItemOutputs: []dynamodb.GetItemOutput{{}, {}, {}},
+			GetItemErrors:  []error{nil, nil, nil},
+		}
+		authz, logs := newTestAuthorizer(&ddbClient, "ann")
+
+		chatCtx := authz.verifyContext(ChatContext{CallerUserName: "ann", CallerTeamID: "TEAM#9", CallerOrgID: "org-2", TargetUserID: "bob"})
+
+		assert.Equal(t, ChatContext{CallerUserName: "ann"}, chatCtx)
+		assert.Contains(t, logs.String(), `dropped context teamId="TEAM#9"`)
+		assert.Contains(t, logs.String(), `dropped context targetUserId="bob"`)
+	})
+
+	t.Run("It should keep what the caller holds", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			GetItemOutputs: []dynamodb.GetItemOutput{
+				memberItem("TEAM#1", "ann", companylib.TeamMemberRoleAdmin),
+				{}, orgUserItem("org-1", "ann"),
+				memberItem("TEAM#1", "bob", companylib.TeamMemberRoleMember),
+			},
+			GetItemErrors: []error{nil, nil, nil, nil},
+		}
+		authz, _ := newTestAuthorizer(&ddbClient, "ann")
+		in := ChatContext{CallerUserName: "ann", CallerTeamID: "TEAM#1", CallerOrgID: "org-1", TargetUserID: "bob"}
+
+		assert.Equal(t, in, authz.verifyContext(in))
+	})
+}
+
+func TestRedactResult(t *testing.T) {
+	org := companylib.Organization{OrganizationId: "org-1", OrgName: "Acme", TaxID: "TAX-1", CreditBalance: 20}
+
+	tests := []struct {
+		name     string
+		access   toolAccess
+		redacted bool
+	}{
+		{name: "It should hide billing from members", access: toolAccess{role: roleMember}, redacted: true},
+		{name: "It should hide billing from managers", access: toolAccess{role: roleManager}, redacted: true},
+		{name: "It should show billing to org admins", access: toolAccess{role: roleOrgAdmin}},
+		{name: "It should leave a caller's own data alone", access: toolAccess{role: roleMember, self: true}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := redactResult(org, test.access)
+
+			assert.NoError(t, err)
+			raw, _ := json.Marshal(result)
+			var fields map[string]interface{}
+			json.Unmarshal(raw, &fields)
+			assert.Equal(t, "Acme", fields["orgName"])
+			if test.redacted {
+				assert.NotContains(t, fields, "taxId")
+				assert.NotContains(t, fields, "creditBalance")
+			} else {
+				assert.Equal(t, "TAX-1", fields["taxId"])
+			}
+		})
+	}
+
+	t.Run("It should redact nested employee details", func(t *testing.T) {
+		result, err := redactResult([]companylib.EmployeeDynamodbData{{UserName: "bob", CognitoId: "sub-1", PhoneNumber: "555"}}, toolAccess{role: roleManager})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []interface{}{map[string]interface{}{"UserName": "bob"}}, result)
+	})
+}
+
+func TestRunTool(t *testing.T) {
+	chatCtx := ChatContext{CallerUserName: "ann", CallerTeamID: "TEAM#1", CallerOrgID: "org-1"}
+
+	t.Run("It should refuse and log a denied call without running the tool", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			GetItemOutputs: []dynamodb.GetItemOutput{memberItem("TEAM#1", "ann", companylib.TeamMemberRoleMember), teamItem("TEAM#1", "org-1"), {}},
+			GetItemErrors:  []error{nil, nil, nil},
+		}
+		authz, logs := newTestAuthorizer(&ddbClient, "ann")
+
+		out := runTool("get_member_manager_comments", map[string]interface{}{"memberId": "bob"}, authz, nil, chatCtx)
+
+		assert.Contains(t, out, "not authorised")
+		assert.Empty(t, ddbClient.QueryInputs)
+		assert.Contains(t, logs.String(), `policy: denied tool=get_member_manager_comments caller="ann"`)
+	})
+
+	t.Run("It should run an allowed call against the checked team", func(t *testing.T) {
+		comment, _ := attributevalue.MarshalMap(ctrl.ManagerCommentRecord{CommentID: "c-1", MemberID: "bob", Text: "Great quarter"})
+		ddbClient := awsclients.MockDynamodbClient{
+			GetItemOutputs: []dynamodb.GetItemOutput{memberItem("TEAM#1", "ann", companylib.TeamMemberRoleAdmin), memberItem("TEAM#1", "bob", companylib.TeamMemberRoleMember)},
+			GetItemErrors:  []error{nil, nil},
+			QueryOutputs:   []dynamodb.QueryOutput{{Items: []map[string]dynamodb_types.AttributeValue{comment}}},
+			QueryErrors:    []error{nil},
+		}
+		authz, _ := newTestAuthorizer(&ddbClient, "ann")
+
+		out := runTool("get_member_manager_comments", map[string]interface{}{"memberId": "bob"}, authz, nil, chatCtx)
+
+		assert.Contains(t, out, "Great quarter")
+		pk := ddbClient.QueryInputs[0].ExpressionAttributeValues[":pk"].(*dynamodb_types.AttributeValueMemberS)
+		assert.Equal(t, "USER#bob#TEAM#TEAM#1", pk.Value)
+	})
+}
@@ -13,15 +13,41 @@ import (
 	"github.com/aws/aws-xray-sdk-go/xray"
 
 	ctrl "github.com/busyfit-admin/saas-integrated-apis/lambdas/ai-tools/controllers"
+	awsclients "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/clients"
 )
 
+// converseClient is the model the chat handler talks to, in the shape of the Bedrock Converse API.
+// ConverseStream returns the stream's event reader rather than the SDK output, which cannot be
+// built outside the SDK. bedrockRuntime is the production implementation; another provider can be
+// plugged in by adapting it to these two calls.
+type converseClient interface {
+	Converse(ctx context.Context, params *bedrockruntime.ConverseInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.ConverseOutput, error)
+	ConverseStream(ctx context.Context, params *bedrockruntime.ConverseStreamInput, optFns ...func(*bedrockruntime.Options)) (bedrockruntime.ConverseStreamOutputReader, error)
+}
+
+// bedrockRuntime adapts *bedrockruntime.Client to converseClient.
+type bedrockRuntime struct {
+	*bedrockruntime.Client
+}
+
+func (c bedrockRuntime) ConverseStream(ctx context.Context, params *bedrockruntime.ConverseStreamInput, optFns ...func(*bedrockruntime.Options)) (bedrockruntime.ConverseStreamOutputReader, error) {
+	out, err := c.Client.ConverseStream(ctx, params, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	return out.GetStream(), nil
+}
+
 // Service holds every dependency needed by the chat-handler Lambda.
 type Service struct {
 	logger           *log.Logger
 	ctrlSVC          *ctrl.Service
-	bedrockClient    *bedrockruntime.Client
-	ddb              *dynamodb.Client
+	bedrockClient    converseClient
+	ddb              awsclients.DynamodbClient
+	history          historyStore
+	tokens           *cognitoTokenVerifier
 	chatHistoryTable string
+	sessionIndex     string
 	modelID          string
 }
 
@@ -31,9 +57,13 @@ type Service struct {
 // Required environment variables:
 //
 //	AI_CHAT_HISTORY_TABLE  — DynamoDB table for chat history
+//	AI_CHAT_HISTORY_TABLE_OWNER_INDEX — GSI listing session records by ownerId
 //	BEDROCK_MODEL_ID       — Bedrock model ID (e.g. anthropic.claude-3-5-sonnet-20241022-v2:0)
 //	EMPLOYEE_TABLE, EMPLOYEE_TABLE_COGNITO_ID_INDEX, EMPLOYEE_TABLE_EMAIL_ID_INDEX
 //	TEAMS_TABLE, ORGANIZATION_TABLE, ORG_PERFORMANCE_TABLE, PERF_HUB_TABLE
+//
+// The streaming function URL additionally needs COGNITO_USER_POOL_ID and COGNITO_CLIENT_ID to
+// verify ID tokens itself, because function URLs have no Cognito authorizer.
 func NewService() (*Service, error) {
 	ctx, seg := xray.BeginSegment(context.TODO(), "ai-chat-service-init")
 	defer seg.Close(nil)
@@ -56,12 +86,18 @@ func NewService() (*Service, error) {
 		modelID = "amazon.nova-pro-v1:0"
 	}
 
+	ddb := dynamodb.NewFromConfig(cfg)
+	chatHistoryTable := os.Getenv("AI_CHAT_HISTORY_TABLE")
+
 	return &Service{
 		logger:           logger,
 		ctrlSVC:          ctrlSVC,
-		bedrockClient:    bedrockruntime.NewFromConfig(cfg),
-		ddb:              dynamodb.NewFromConfig(cfg),
-		chatHistoryTable: os.Getenv("AI_CHAT_HISTORY_TABLE"),
+		bedrockClient:    bedrockRuntime{bedrockruntime.NewFromConfig(cfg)},
+		ddb:              ddb,
+		history:          dynamoHistoryStore{ddb: ddb, table: chatHistoryTable},
+		chatHistoryTable: chatHistoryTable,
+		sessionIndex:     os.Getenv("AI_CHAT_HISTORY_TABLE_OWNER_INDEX"),
 		modelID:          modelID,
+		tokens:           newCognitoTokenVerifier(cfg.Region, os.Getenv("COGNITO_USER_POOL_ID"), os.Getenv("COGNITO_CLIENT_ID")),
 	}, nil
 }
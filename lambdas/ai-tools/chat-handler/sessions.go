@@ -0,0 +1,625 @@
+package main
+
+// ==================== Routes ====================
+//
+// GET    /v2/ai/chat/sessions?limit=&cursor=     — the caller's chats, most recently active first
+// PATCH  /v2/ai/chat/sessions/{chatId}           — rename a chat
+// DELETE /v2/ai/chat/sessions/{chatId}           — delete a chat's messages and pending actions
+// GET    /v2/ai/chat/sessions/{chatId}/export    — full transcript
+//
+// Every chat has a session record (msgKey = SESSION) in its partition. Only the session record
+// carries ownerId, so OwnerIndex (ownerId, lastActivityAt) lists exactly one item per chat.
+// Chats from before session records get theirs from the session backfill job below.
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbTypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	awsclients "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/clients"
+)
+
+const (
+	sessionMsgKey = "SESSION"
+
+	maxTitleRunes   = 60
+	maxRenameRunes  = 100
+	defaultPageSize = 20
+	maxPageSize     = 100
+
+	// maxBatchRetries bounds the retries of unprocessed deletes before deleteSession gives up.
+	maxBatchRetries = 3
+)
+
+var (
+	// errSessionNotFound is returned for chats that do not exist and for chats owned by someone
+	// else, so callers cannot probe for other users' chat IDs.
+	errSessionNotFound = errors.New("chat not found")
+	errInvalidCursor   = errors.New("invalid cursor")
+)
+
+// chatSessionRecord is the per-chat metadata item.
+// PK = chatId, SK = SESSION. OwnerIndex: ownerId (HASH), lastActivityAt (RANGE).
+type chatSessionRecord struct {
+	ChatID         string `dynamodbav:"chatId"`
+	MsgKey         string `dynamodbav:"msgKey"`
+	OwnerID        string `dynamodbav:"ownerId"` // Cognito sub
+	Title          string `dynamodbav:"title"`
+	MessageCount   int    `dynamodbav:"messageCount"`
+	CreatedAt      string `dynamodbav:"createdAt"`
+	LastActivityAt string `dynamodbav:"lastActivityAt"`
+	// Summary covers every message up to and including SummarizedThrough (a msgKey).
+	Summary           string `dynamodbav:"summary,omitempty"`
+	SummarizedThrough string `dynamodbav:"summarizedThrough,omitempty"`
+	ExpiresAt         int64  `dynamodbav:"expiresAt"` // Unix seconds TTL, refreshed on every turn
+}
+
+func (r chatSessionRecord) toSession() ChatSession {
+	return ChatSession{
+		ChatID:         r.ChatID,
+		Title:          r.Title,
+		MessageCount:   r.MessageCount,
+		CreatedAt:      r.CreatedAt,
+		LastActivityAt: r.LastActivityAt,
+	}
+}
+
+// sessionKey is the primary key of a chat's session record.
+func sessionKey(chatId string) map[string]ddbTypes.AttributeValue {
+	return map[string]ddbTypes.AttributeValue{
+		"chatId": &ddbTypes.AttributeValueMemberS{Value: chatId},
+		"msgKey": &ddbTypes.AttributeValueMemberS{Value: sessionMsgKey},
+	}
+}
+
+// loadSession returns the session of chatId if ownerId may use it. A chat nobody has written to
+// yet comes back as an empty record with MessageCount 0. Chats started before session records
+// existed are adopted by their author on first access.
+func loadSession(ctx context.Context, ddb awsclients.DynamodbClient, table, chatId, ownerId string) (*chatSessionRecord, error) {
+	out, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(table),
+		Key:            sessionKey(chatId),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("loadSession: get failed: %w", err)
+	}
+	if len(out.Item) > 0 {
+		var rec chatSessionRecord
+		if err := attributevalue.UnmarshalMap(out.Item, &rec); err != nil {
+			return nil, fmt.Errorf("loadSession: unmarshal failed: %w", err)
+		}
+		if rec.OwnerID != ownerId {
+			return nil, errSessionNotFound
+		}
+		return &rec, nil
+	}
+
+	messages, _, err := queryMessages(ctx, ddb, table, chatId, messageRange{}, 0)
+	if err != nil {
+		return nil, fmt.Errorf("loadSession: %w", err)
+	}
+	if len(messages) == 0 {
+		return &chatSessionRecord{ChatID: chatId, MsgKey: sessionMsgKey}, nil
+	}
+	if messages[0].UserID != ownerId {
+		return nil, errSessionNotFound
+	}
+	return backfillSession(ctx, ddb, table, chatId, ownerId, messages)
+}
+
+// backfillSession creates the session record of a chat that predates session records.
+func backfillSession(ctx context.Context, ddb awsclients.DynamodbClient, table, chatId, ownerId string, messages []chatHistoryRecord) (*chatSessionRecord, error) {
+	last := messages[len(messages)-1]
+	rec := chatSessionRecord{
+		ChatID:         chatId,
+		MsgKey:         sessionMsgKey,
+		OwnerID:        ownerId,
+		Title:          sessionTitle(messages[0].MessageText),
+		MessageCount:   len(messages),
+		CreatedAt:      messages[0].CreatedAt,
+		LastActivityAt: last.CreatedAt,
+		ExpiresAt:      last.ExpiresAt,
+	}
+	item, err := attributevalue.MarshalMap(rec)
+	if err != nil {
+		return nil, fmt.Errorf("backfillSession: marshal failed: %w", err)
+	}
+	_, err = ddb.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(table),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(msgKey)"),
+	})
+	var ccf *ddbTypes.ConditionalCheckFailedException
+	if err != nil && !errors.As(err, &ccf) {
+		return nil, fmt.Errorf("backfillSession: put failed: %w", err)
+	}
+	// A concurrent request may have created it first; either way the record now exists.
+	return &rec, nil
+}
+
+// touchSession creates the session record on a chat's first turn and records activity on later
+// ones. The condition rejects turns written into someone else's chat.
+func touchSession(table, chatId, ownerId, title string, messages int, now time.Time) *ddbTypes.Update {
+	return &ddbTypes.Update{
+		TableName: aws.String(table),
+		Key:       sessionKey(chatId),
+		UpdateExpression: aws.String("SET ownerId = if_not_exists(ownerId, :owner), title = if_not_exists(title, :title), " +
+			"createdAt = if_not_exists(createdAt, :now), lastActivityAt = :now, expiresAt = :exp ADD messageCount :n"),
+		ConditionExpression: aws.String("attribute_not_exists(msgKey) OR ownerId = :owner"),
+		ExpressionAttributeValues: map[string]ddbTypes.AttributeValue{
+			":owner": &ddbTypes.AttributeValueMemberS{Value: ownerId},
+			":title": &ddbTypes.AttributeValueMemberS{Value: title},
+			":now":   &ddbTypes.AttributeValueMemberS{Value: now.Format(time.RFC3339)},
+			":exp":   &ddbTypes.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix()+chatTTLSeconds, 10)},
+			":n":     &ddbTypes.AttributeValueMemberN{Value: strconv.Itoa(messages)},
+		},
+	}
+}
+
+// saveSessionSummary stores a new rolling summary. The condition keeps a slower request from
+// replacing a summary that already covers more of the chat.
+func saveSessionSummary(ctx context.Context, ddb awsclients.DynamodbClient, table string, session *chatSessionRecord, summary, through string) error {
+	_, err := ddb.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(table),
+		Key:                 sessionKey(session.ChatID),
+		UpdateExpression:    aws.String("SET summary = :summary, summarizedThrough = :through"),
+		ConditionExpression: aws.String("ownerId = :owner AND (attribute_not_exists(summarizedThrough) OR summarizedThrough < :through)"),
+		ExpressionAttributeValues: map[string]ddbTypes.AttributeValue{
+			":summary": &ddbTypes.AttributeValueMemberS{Value: summary},
+			":through": &ddbTypes.AttributeValueMemberS{Value: through},
+			":owner":   &ddbTypes.AttributeValueMemberS{Value: session.OwnerID},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("saveSessionSummary: update failed: %w", err)
+	}
+	session.Summary, session.SummarizedThrough = summary, through
+	return nil
+}
+
+// sessionTitle derives a chat title from its first message: whitespace collapsed and cut at a
+// word boundary.
+func sessionTitle(message string) string {
+	title := strings.Join(strings.Fields(message), " ")
+	if utf8.RuneCountInString(title) <= maxTitleRunes {
+		return title
+	}
+	cut := string([]rune(title)[:maxTitleRunes])
+	if i := strings.LastIndex(cut, " "); i > len(cut)/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,.;:-") + "…"
+}
+
+// ==================== Session Operations ====================
+
+// listSessions returns a page of ownerId's chats, most recently active first.
+func listSessions(ctx context.Context, ddb awsclients.DynamodbClient, table, index, ownerId string, limit int, cursor string) ([]ChatSession, string, error) {
+	startKey, err := decodeCursor(cursor, ownerId)
+	if err != nil {
+		return nil, "", err
+	}
+	out, err := ddb.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(table),
+		IndexName:              aws.String(index),
+		KeyConditionExpression: aws.String("ownerId = :owner"),
+		ExpressionAttributeValues: map[string]ddbTypes.AttributeValue{
+			":owner": &ddbTypes.AttributeValueMemberS{Value: ownerId},
+		},
+		ScanIndexForward:  aws.Bool(false),
+		Limit:             aws.Int32(int32(limit)),
+		ExclusiveStartKey: startKey,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("listSessions: query failed: %w", err)
+	}
+
+	sessions := make([]ChatSession, 0, len(out.Items))
+	for _, item := range out.Items {
+		var rec chatSessionRecord
+		if err := attributevalue.UnmarshalMap(item, &rec); err != nil {
+			continue
+		}
+		sessions = append(sessions, rec.toSession())
+	}
+	next, err := encodeCursor(out.LastEvaluatedKey)
+	return sessions, next, err
+}
+
+// renameSession replaces the title of a chat the caller owns.
+func renameSession(ctx context.Context, ddb awsclients.DynamodbClient, table string, session *chatSessionRecord, title string) error {
+	_, err := ddb.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(table),
+		Key:                 sessionKey(session.ChatID),
+		UpdateExpression:    aws.String("SET title = :title"),
+		ConditionExpression: aws.String("ownerId = :owner"),
+		ExpressionAttributeValues: map[string]ddbTypes.AttributeValue{
+			":title": &ddbTypes.AttributeValueMemberS{Value: title},
+			":owner": &ddbTypes.AttributeValueMemberS{Value: session.OwnerID},
+		},
+	})
+	var ccf *ddbTypes.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		return errSessionNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("renameSession: update failed: %w", err)
+	}
+	session.Title = title
+	return nil
+}
+
+// deleteSession removes a chat's messages and pending actions, then its session record. Action
+// audit records are kept; they have no TTL and outlive the conversation on purpose.
+func deleteSession(ctx context.Context, ddb awsclients.DynamodbClient, table string, session *chatSessionRecord) error {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(table),
+		KeyConditionExpression: aws.String("chatId = :cid"),
+		ExpressionAttributeValues: map[string]ddbTypes.AttributeValue{
+			":cid": &ddbTypes.AttributeValueMemberS{Value: session.ChatID},
+		},
+		ProjectionExpression: aws.String("chatId, msgKey"),
+	}
+
+	var keys []map[string]ddbTypes.AttributeValue
+	for {
+		out, err := ddb.Query(ctx, input)
+		if err != nil {
+			return fmt.Errorf("deleteSession: query failed: %w", err)
+		}
+		for _, item := range out.Items {
+			msgKey, _ := item["msgKey"].(*ddbTypes.AttributeValueMemberS)
+			if msgKey == nil || msgKey.Value == sessionMsgKey || strings.HasPrefix(msgKey.Value, auditKeyPrefix) {
+				continue
+			}
+			keys = append(keys, item)
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+
+	for start := 0; start < len(keys); start += 25 {
+		end := start + 25
+		if end > len(keys) {
+			end = len(keys)
+		}
+		requests := make([]ddbTypes.WriteRequest, 0, end-start)
+		for _, key := range keys[start:end] {
+			requests = append(requests, ddbTypes.WriteRequest{DeleteRequest: &ddbTypes.DeleteRequest{Key: key}})
+		}
+		if err := batchDelete(ctx, ddb, table, requests); err != nil {
+			return err
+		}
+	}
+
+	// The session record goes last so a failed delete can be retried from the chat list.
+	_, err := ddb.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(table),
+		Key:                 sessionKey(session.ChatID),
+		ConditionExpression: aws.String("attribute_not_exists(msgKey) OR ownerId = :owner"),
+		ExpressionAttributeValues: map[string]ddbTypes.AttributeValue{
+			":owner": &ddbTypes.AttributeValueMemberS{Value: session.OwnerID},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("deleteSession: delete session failed: %w", err)
+	}
+	return nil
+}
+
+// batchDelete runs one BatchWriteItem, retrying unprocessed items a few times.
+func batchDelete(ctx context.Context, ddb awsclients.DynamodbClient, table string, requests []ddbTypes.WriteRequest) error {
+	pending := map[string][]ddbTypes.WriteRequest{table: requests}
+	for attempt := 0; attempt <= maxBatchRetries; attempt++ {
+		out, err := ddb.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: pending})
+		if err != nil {
+			return fmt.Errorf("deleteSession: batch delete failed: %w", err)
+		}
+		if len(out.UnprocessedItems[table]) == 0 {
+			return nil
+		}
+		pending = out.UnprocessedItems
+		time.Sleep(time.Duration(attempt+1) * 50 * time.Millisecond)
+	}
+	return fmt.Errorf("deleteSession: %d deletes still unprocessed", len(pending[table]))
+}
+
+// exportSession returns a chat's full transcript, oldest message first.
+func exportSession(ctx context.Context, ddb awsclients.DynamodbClient, table string, session *chatSessionRecord) (*ChatSessionExport, error) {
+	records, _, err := queryMessages(ctx, ddb, table, session.ChatID, messageRange{}, 0)
+	if err != nil {
+		return nil, fmt.Errorf("exportSession: %w", err)
+	}
+	messages := make([]ChatMessage, 0, len(records))
+	for _, r := range records {
+		messages = append(messages, ChatMessage{Role: r.Role, Text: r.MessageText, CreatedAt: r.CreatedAt})
+	}
+	return &ChatSessionExport{
+		ChatSession: session.toSession(),
+		Summary:     session.Summary,
+		Messages:    messages,
+		ExportedAt:  time.Now().UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// ==================== Session Backfill ====================
+//
+// Chats started before session records existed only get one when their author next opens them,
+// so until then they are missing from the chat list. The backfill job scans the chat history
+// table and creates the missing session records. A run stops before the Lambda times out and
+// returns a cursor; invoke it again with {"cursor": ...} until "done" is true. Re-running it is safe.
+
+const (
+	sessionBackfillPageSize = 500
+
+	// sessionBackfillMargin is the time left for the last page when a run stops
+	sessionBackfillMargin = 30 * time.Second
+)
+
+type SessionBackfillRequest struct {
+	Cursor string `json:"cursor"`
+}
+
+type SessionBackfillSummary struct {
+	Chats      int    `json:"chats"`   // Chats seen by this run
+	Created    int    `json:"created"` // Session records created
+	NextCursor string `json:"nextCursor,omitempty"`
+	Done       bool   `json:"done"`
+}
+
+// HandleSessionBackfill creates the session records of legacy chats from cursor onwards. A
+// failure stops the run and the error names the cursor to resume from.
+func (svc *Service) HandleSessionBackfill(ctx context.Context, req SessionBackfillRequest) (SessionBackfillSummary, error) {
+	summary := SessionBackfillSummary{}
+
+	startKey, err := decodeScanCursor(req.Cursor)
+	if err != nil {
+		return summary, err
+	}
+	input := &dynamodb.ScanInput{
+		TableName:            aws.String(svc.chatHistoryTable),
+		ProjectionExpression: aws.String("chatId, msgKey"),
+		Limit:                aws.Int32(sessionBackfillPageSize),
+		ExclusiveStartKey:    startKey,
+	}
+
+	// A chat's items span consecutive pages, so chats already handled by this run are skipped
+	seen := map[string]bool{}
+	for {
+		cursor, err := encodeCursor(input.ExclusiveStartKey)
+		if err != nil {
+			return summary, err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < sessionBackfillMargin {
+			summary.NextCursor = cursor
+			break
+		}
+
+		out, err := svc.ddb.Scan(ctx, input)
+		if err != nil {
+			return summary, fmt.Errorf("HandleSessionBackfill: scan failed, resume from cursor %q: %w", cursor, err)
+		}
+		for _, item := range out.Items {
+			chatId, _ := item["chatId"].(*ddbTypes.AttributeValueMemberS)
+			if chatId == nil || seen[chatId.Value] {
+				continue
+			}
+			seen[chatId.Value] = true
+			summary.Chats++
+
+			created, err := ensureSession(ctx, svc.ddb, svc.chatHistoryTable, chatId.Value)
+			if err != nil {
+				return summary, fmt.Errorf("HandleSessionBackfill: resume from cursor %q: %w", cursor, err)
+			}
+			if created {
+				summary.Created++
+			}
+		}
+
+		if len(out.LastEvaluatedKey) == 0 {
+			summary.Done = true
+			break
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+
+	svc.logger.Printf("Session backfill run complete: %+v", summary)
+	return summary, nil
+}
+
+// ensureSession creates the session record of chatId, owned by the author of its first message,
+// unless it already has one. Chats without messages are left alone.
+func ensureSession(ctx context.Context, ddb awsclients.DynamodbClient, table, chatId string) (bool, error) {
+	out, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:            aws.String(table),
+		Key:                  sessionKey(chatId),
+		ProjectionExpression: aws.String("chatId"),
+	})
+	if err != nil {
+		return false, fmt.Errorf("ensureSession: get failed: %w", err)
+	}
+	if len(out.Item) > 0 {
+		return false, nil
+	}
+
+	messages, _, err := queryMessages(ctx, ddb, table, chatId, messageRange{}, 0)
+	if err != nil {
+		return false, fmt.Errorf("ensureSession: %w", err)
+	}
+	if len(messages) == 0 {
+		return false, nil
+	}
+	if _, err := backfillSession(ctx, ddb, table, chatId, messages[0].UserID, messages); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ==================== Cursors ====================
+
+// encodeCursor serialises an OwnerIndex key as URL-safe base64 JSON. The keys are all strings.
+func encodeCursor(key map[string]ddbTypes.AttributeValue) (string, error) {
+	if len(key) == 0 {
+		return "", nil
+	}
+	plain := make(map[string]string, len(key))
+	for name, v := range key {
+		s, ok := v.(*ddbTypes.AttributeValueMemberS)
+		if !ok {
+			return "", fmt.Errorf("cursor key %s is not a string attribute", name)
+		}
+		plain[name] = s.Value
+	}
+	raw, err := json.Marshal(plain)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// decodeScanCursor parses a session backfill cursor from encodeCursor.
+func decodeScanCursor(cursor string) (map[string]ddbTypes.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var plain map[string]string
+	if err := json.Unmarshal(raw, &plain); err != nil || plain["chatId"] == "" || plain["msgKey"] == "" {
+		return nil, errInvalidCursor
+	}
+	return map[string]ddbTypes.AttributeValue{
+		"chatId": &ddbTypes.AttributeValueMemberS{Value: plain["chatId"]},
+		"msgKey": &ddbTypes.AttributeValueMemberS{Value: plain["msgKey"]},
+	}, nil
+}
+
+// decodeCursor parses a cursor from encodeCursor and checks it was issued to ownerId.
+func decodeCursor(cursor, ownerId string) (map[string]ddbTypes.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var plain map[string]string
+	if err := json.Unmarshal(raw, &plain); err != nil || plain["ownerId"] != ownerId {
+		return nil, errInvalidCursor
+	}
+	key := make(map[string]ddbTypes.AttributeValue, len(plain))
+	for name, v := range plain {
+		key[name] = &ddbTypes.AttributeValueMemberS{Value: v}
+	}
+	return key, nil
+}
+
+// ==================== Handlers ====================
+
+// RenameSessionRequest is the body of PATCH /v2/ai/chat/sessions/{chatId}.
+type RenameSessionRequest struct {
+	Title string `json:"title"`
+}
+
+// handleSessions dispatches /v2/ai/chat/sessions/... routes. parts: [v2, ai, chat, sessions, ...]
+func (svc *Service) handleSessions(ctx context.Context, request events.APIGatewayProxyRequest, parts []string, ownerId string) (events.APIGatewayProxyResponse, error) {
+	if len(parts) == 4 {
+		if request.HTTPMethod != http.MethodGet {
+			return errResponse(http.StatusMethodNotAllowed, "method not allowed")
+		}
+		return svc.listSessions(ctx, request, ownerId)
+	}
+
+	session, err := loadSession(ctx, svc.ddb, svc.chatHistoryTable, parts[4], ownerId)
+	if err == nil && session.MessageCount == 0 {
+		err = errSessionNotFound
+	}
+	if errors.Is(err, errSessionNotFound) {
+		return errResponse(http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		svc.logger.Printf("error: could not load session chatId=%q: %v", parts[4], err)
+		return errResponse(http.StatusInternalServerError, "could not load chat")
+	}
+
+	switch {
+	case len(parts) == 5 && request.HTTPMethod == http.MethodPatch:
+		var req RenameSessionRequest
+		if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+			return errResponse(http.StatusBadRequest, "invalid request body")
+		}
+		title := strings.TrimSpace(req.Title)
+		if title == "" || utf8.RuneCountInString(title) > maxRenameRunes {
+			return errResponse(http.StatusBadRequest, fmt.Sprintf("title must be 1-%d characters", maxRenameRunes))
+		}
+		if err := renameSession(ctx, svc.ddb, svc.chatHistoryTable, session, title); err != nil {
+			if errors.Is(err, errSessionNotFound) {
+				return errResponse(http.StatusNotFound, err.Error())
+			}
+			svc.logger.Printf("error: could not rename chatId=%q: %v", session.ChatID, err)
+			return errResponse(http.StatusInternalServerError, "could not rename chat")
+		}
+		return jsonResponse(http.StatusOK, session.toSession())
+
+	case len(parts) == 5 && request.HTTPMethod == http.MethodDelete:
+		if err := deleteSession(ctx, svc.ddb, svc.chatHistoryTable, session); err != nil {
+			svc.logger.Printf("error: could not delete chatId=%q: %v", session.ChatID, err)
+			return errResponse(http.StatusInternalServerError, "could not delete chat")
+		}
+		svc.logger.Printf("sessions: deleted chatId=%q", session.ChatID)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusNoContent}, nil
+
+	case len(parts) == 6 && parts[5] == "export" && request.HTTPMethod == http.MethodGet:
+		export, err := exportSession(ctx, svc.ddb, svc.chatHistoryTable, session)
+		if err != nil {
+			svc.logger.Printf("error: could not export chatId=%q: %v", session.ChatID, err)
+			return errResponse(http.StatusInternalServerError, "could not export chat")
+		}
+		return jsonResponse(http.StatusOK, export)
+	}
+
+	return errResponse(http.StatusNotFound, "route not found")
+}
+
+// listSessions serves GET /v2/ai/chat/sessions.
+func (svc *Service) listSessions(ctx context.Context, request events.APIGatewayProxyRequest, ownerId string) (events.APIGatewayProxyResponse, error) {
+	limit := defaultPageSize
+	if raw := request.QueryStringParameters["limit"]; raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return errResponse(http.StatusBadRequest, "limit must be a positive integer")
+		}
+		limit = n
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	sessions, next, err := listSessions(ctx, svc.ddb, svc.chatHistoryTable, svc.sessionIndex, ownerId, limit, request.QueryStringParameters["cursor"])
+	if errors.Is(err, errInvalidCursor) {
+		return errResponse(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		svc.logger.Printf("error: could not list sessions: %v", err)
+		return errResponse(http.StatusInternalServerError, "could not list chats")
+	}
+	return jsonResponse(http.StatusOK, ChatSessionList{Sessions: sessions, NextCursor: next})
+}
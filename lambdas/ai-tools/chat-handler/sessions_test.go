@@ -0,0 +1,463 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodb_types "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+
+	awsclients "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/clients"
+)
+
+const testChatTable = "ChatHistoryTable-test"
+
+func sessionItem(chatID, ownerID string, count int) dynamodb.GetItemOutput {
+	item, _ := attributevalue.MarshalMap(chatSessionRecord{
+		ChatID: chatID, MsgKey: sessionMsgKey, OwnerID: ownerID, Title: "How are my Q3 goals going?",
+		MessageCount: count, CreatedAt: "2024-07-01T10:00:00Z", LastActivityAt: "2024-07-02T09:00:00Z",
+	})
+	return dynamodb.GetItemOutput{Item: item}
+}
+
+func messageItems(chatID, userID string, n int) []map[string]dynamodb_types.AttributeValue {
+	items := make([]map[string]dynamodb_types.AttributeValue, 0, n)
+	for i := 0; i < n; i++ {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		item, _ := attributevalue.MarshalMap(chatHistoryRecord{
+			ChatID: chatID, MsgKey: fmt.Sprintf("%020d#m%d", 1719828000000+i, i), Role: role,
+			MessageText: fmt.Sprintf("message %d", i), UserID: userID,
+			CreatedAt: time.UnixMilli(int64(1719828000000 + i*60000)).UTC().Format(time.RFC3339), ExpiresAt: 1735000000,
+		})
+		items = append(items, item)
+	}
+	return items
+}
+
+func newTestSessionService(ddbClient *awsclients.MockDynamodbClient) *Service {
+	return &Service{
+		logger:           log.New(&bytes.Buffer{}, "", 0),
+		ddb:              ddbClient,
+		history:          dynamoHistoryStore{ddb: ddbClient, table: testChatTable},
+		chatHistoryTable: testChatTable,
+		sessionIndex:     "OwnerIndex",
+	}
+}
+
+func TestLoadSession(t *testing.T) {
+	tests := []struct {
+		name          string
+		getItem       dynamodb.GetItemOutput
+		queryOutputs  []dynamodb.QueryOutput
+		expectedCount int
+		expectedErr   error
+		backfilled    bool
+	}{
+		{
+			name:          "It should return the caller's session",
+			getItem:       sessionItem("chat-1", "sub-ann", 6),
+			expectedCount: 6,
+		},
+		{
+			name:        "It should hide a chat owned by someone else",
+			getItem:     sessionItem("chat-1", "sub-bob", 6),
+			expectedErr: errSessionNotFound,
+		},
+		{
+			name:          "It should start a new chat when nothing has been written yet",
+			queryOutputs:  []dynamodb.QueryOutput{{}},
+			expectedCount: 0,
+		},
+		{
+			name:          "It should adopt a chat the caller started before sessions existed",
+			queryOutputs:  []dynamodb.QueryOutput{{Items: messageItems("chat-1", "sub-ann", 4)}},
+			expectedCount: 4,
+			backfilled:    true,
+		},
+		{
+			name:         "It should not adopt another user's chat",
+			queryOutputs: []dynamodb.QueryOutput{{Items: messageItems("chat-1", "sub-bob", 4)}},
+			expectedErr:  errSessionNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ddbClient := awsclients.MockDynamodbClient{
+				GetItemOutputs: []dynamodb.GetItemOutput{test.getItem},
+				GetItemErrors:  []error{nil},
+				QueryOutputs:   test.queryOutputs,
+				QueryErrors:    make([]error, len(test.queryOutputs)),
+			}
+			if test.backfilled {
+				ddbClient.PutItemOutputs = []dynamodb.PutItemOutput{{}}
+				ddbClient.PutItemErrors = []error{nil}
+			}
+
+			session, err := loadSession(context.TODO(), &ddbClient, testChatTable, "chat-1", "sub-ann")
+
+			assert.ErrorIs(t, err, test.expectedErr)
+			if test.expectedErr != nil {
+				assert.Empty(t, ddbClient.PutItemInputs)
+				return
+			}
+			assert.Equal(t, test.expectedCount, session.MessageCount)
+			assert.Equal(t, "chat-1", session.ChatID)
+			if test.backfilled {
+				var stored chatSessionRecord
+				attributevalue.UnmarshalMap(ddbClient.PutItemInputs[0].Item, &stored)
+				assert.Equal(t, "sub-ann", stored.OwnerID)
+				assert.Equal(t, "message 0", stored.Title)
+				assert.Equal(t, 4, stored.MessageCount)
+			} else {
+				assert.Empty(t, ddbClient.PutItemInputs)
+			}
+		})
+	}
+}
+
+func TestHandleSessionBackfill(t *testing.T) {
+	keys := func(items []map[string]dynamodb_types.AttributeValue) []map[string]dynamodb_types.AttributeValue {
+		for _, item := range items {
+			for name := range item {
+				if name != "chatId" && name != "msgKey" {
+					delete(item, name)
+				}
+			}
+		}
+		return items
+	}
+
+	t.Run("It should create session records for chats that have none", func(t *testing.T) {
+		legacy := messageItems("chat-old", "sub-ann", 3)
+		page := append(keys(messageItems("chat-old", "sub-ann", 3)), keys(messageItems("chat-new", "sub-bob", 2))...)
+		ddbClient := &awsclients.MockDynamodbClient{
+			ScanOutputs:    []dynamodb.ScanOutput{{Items: page}},
+			ScanErrors:     []error{nil},
+			GetItemOutputs: []dynamodb.GetItemOutput{{}, sessionItem("chat-new", "sub-bob", 2)},
+			GetItemErrors:  []error{nil, nil},
+			QueryOutputs:   []dynamodb.QueryOutput{{Items: legacy}},
+			QueryErrors:    []error{nil},
+			PutItemOutputs: []dynamodb.PutItemOutput{{}},
+			PutItemErrors:  []error{nil},
+		}
+		svc := newTestSessionService(ddbClient)
+
+		summary, err := svc.HandleSessionBackfill(context.TODO(), SessionBackfillRequest{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, SessionBackfillSummary{Chats: 2, Created: 1, Done: true}, summary)
+		assert.Len(t, ddbClient.GetItemInputs, 2)
+		assert.Len(t, ddbClient.PutItemInputs, 1)
+		var stored chatSessionRecord
+		attributevalue.UnmarshalMap(ddbClient.PutItemInputs[0].Item, &stored)
+		assert.Equal(t, "chat-old", stored.ChatID)
+		assert.Equal(t, "sub-ann", stored.OwnerID)
+		assert.Equal(t, 3, stored.MessageCount)
+	})
+
+	t.Run("It should resume from a cursor and reject a malformed one", func(t *testing.T) {
+		ddbClient := &awsclients.MockDynamodbClient{
+			ScanOutputs: []dynamodb.ScanOutput{{}},
+			ScanErrors:  []error{nil},
+		}
+		svc := newTestSessionService(ddbClient)
+		start := map[string]dynamodb_types.AttributeValue{
+			"chatId": &dynamodb_types.AttributeValueMemberS{Value: "chat-1"},
+			"msgKey": &dynamodb_types.AttributeValueMemberS{Value: sessionMsgKey},
+		}
+		cursor, _ := encodeCursor(start)
+
+		summary, err := svc.HandleSessionBackfill(context.TODO(), SessionBackfillRequest{Cursor: cursor})
+
+		assert.NoError(t, err)
+		assert.True(t, summary.Done)
+		assert.Equal(t, start, ddbClient.ScanInputs[0].ExclusiveStartKey)
+
+		_, err = svc.HandleSessionBackfill(context.TODO(), SessionBackfillRequest{Cursor: "not a cursor"})
+		assert.ErrorIs(t, err, errInvalidCursor)
+	})
+}
+
+func TestSaveChatTurnTouchesSession(t *testing.T) {
+	ddbClient := awsclients.MockDynamodbClient{
+		TransactWriteItemsOutput: []dynamodb.TransactWriteItemsOutput{{}},
+		TransactWriteItemsErrors: []error{nil},
+	}
+
+	err := saveChatTurn(context.TODO(), &ddbClient, testChatTable, "chat-1", "sub-ann", "  How are   my Q3 goals going? ", "Two are on track.")
+
+	assert.NoError(t, err)
+	items := ddbClient.TransactWriteItemsInputs[0].TransactItems
+	assert.Len(t, items, 3)
+	update := items[2].Update
+	assert.Equal(t, sessionMsgKey, update.Key["msgKey"].(*dynamodb_types.AttributeValueMemberS).Value)
+	assert.Equal(t, "attribute_not_exists(msgKey) OR ownerId = :owner", aws.ToString(update.ConditionExpression))
+	assert.Equal(t, "sub-ann", update.ExpressionAttributeValues[":owner"].(*dynamodb_types.AttributeValueMemberS).Value)
+	assert.Equal(t, "How are my Q3 goals going?", update.ExpressionAttributeValues[":title"].(*dynamodb_types.AttributeValueMemberS).Value)
+	assert.Equal(t, "2", update.ExpressionAttributeValues[":n"].(*dynamodb_types.AttributeValueMemberN).Value)
+}
+
+func TestSessionTitle(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		expected string
+	}{
+		{name: "It should keep a short message", message: "Who is behind on goals?", expected: "Who is behind on goals?"},
+		{name: "It should collapse whitespace", message: "Log\n3 hours   on TASK-123", expected: "Log 3 hours on TASK-123"},
+		{
+			name:     "It should cut a long message at a word boundary",
+			message:  "Can you summarise how every member of the platform team is doing against their Q3 OKRs and KPIs?",
+			expected: "Can you summarise how every member of the platform team is…",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, sessionTitle(test.message))
+		})
+	}
+}
+
+func TestQueryMessages(t *testing.T) {
+	t.Run("It should return the newest messages oldest first and report older ones", func(t *testing.T) {
+		items := messageItems("chat-1", "sub-ann", 5)
+		newestFirst := []map[string]dynamodb_types.AttributeValue{items[4], items[3], items[2]}
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs: []dynamodb.QueryOutput{{Items: newestFirst, LastEvaluatedKey: items[2]}},
+			QueryErrors:  []error{nil},
+		}
+
+		records, more, err := queryMessages(context.TODO(), &ddbClient, testChatTable, "chat-1", messageRange{}, 2)
+
+		assert.NoError(t, err)
+		assert.True(t, more)
+		assert.Equal(t, []string{"message 3", "message 4"}, []string{records[0].MessageText, records[1].MessageText})
+		input := ddbClient.QueryInputs[0]
+		assert.False(t, aws.ToBool(input.ScanIndexForward))
+		assert.Equal(t, int32(3), aws.ToInt32(input.Limit))
+		assert.Equal(t, actionKeyPrefix, input.ExpressionAttributeValues[":to"].(*dynamodb_types.AttributeValueMemberS).Value)
+	})
+
+	t.Run("It should skip the message the summary already covers", func(t *testing.T) {
+		items := messageItems("chat-1", "sub-ann", 3)
+		var after chatHistoryRecord
+		attributevalue.UnmarshalMap(items[0], &after)
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs: []dynamodb.QueryOutput{{Items: items}},
+			QueryErrors:  []error{nil},
+		}
+
+		records, more, err := queryMessages(context.TODO(), &ddbClient, testChatTable, "chat-1", messageRange{After: after.MsgKey}, 0)
+
+		assert.NoError(t, err)
+		assert.False(t, more)
+		assert.Len(t, records, 2)
+		assert.Equal(t, "message 1", records[0].MessageText)
+	})
+}
+
+func TestLoadChatHistoryWithinWindow(t *testing.T) {
+	items := messageItems("chat-1", "sub-ann", 6)
+	newestFirst := make([]map[string]dynamodb_types.AttributeValue, 0, len(items))
+	for i := len(items) - 1; i >= 0; i-- {
+		newestFirst = append(newestFirst, items[i])
+	}
+	ddbClient := awsclients.MockDynamodbClient{
+		QueryOutputs: []dynamodb.QueryOutput{{Items: newestFirst}},
+		QueryErrors:  []error{nil},
+	}
+	svc := newTestSessionService(&ddbClient)
+	session := &chatSessionRecord{ChatID: "chat-1", OwnerID: "sub-ann", MessageCount: 6, Summary: "Ann asked about Q2.", SummarizedThrough: "00000001719827999999#x"}
+
+	summary, records, err := svc.loadChatHistory(context.TODO(), session, historyLimit)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Ann asked about Q2.", summary)
+	assert.Len(t, records, 6)
+	assert.Equal(t, "00000001719827999999#x", ddbClient.QueryInputs[0].ExpressionAttributeValues[":from"].(*dynamodb_types.AttributeValueMemberS).Value)
+	assert.Empty(t, ddbClient.UpdateItemInputs)
+}
+
+func TestListSessions(t *testing.T) {
+	t.Run("It should page through the caller's sessions", func(t *testing.T) {
+		lastKey := map[string]dynamodb_types.AttributeValue{
+			"chatId":         &dynamodb_types.AttributeValueMemberS{Value: "chat-1"},
+			"msgKey":         &dynamodb_types.AttributeValueMemberS{Value: sessionMsgKey},
+			"ownerId":        &dynamodb_types.AttributeValueMemberS{Value: "sub-ann"},
+			"lastActivityAt": &dynamodb_types.AttributeValueMemberS{Value: "2024-07-02T09:00:00Z"},
+		}
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs: []dynamodb.QueryOutput{
+				{Items: []map[string]dynamodb_types.AttributeValue{sessionItem("chat-1", "sub-ann", 6).Item}, LastEvaluatedKey: lastKey},
+				{},
+			},
+			QueryErrors: []error{nil, nil},
+		}
+
+		sessions, next, err := listSessions(context.TODO(), &ddbClient, testChatTable, "OwnerIndex", "sub-ann", 1, "")
+		assert.NoError(t, err)
+		assert.Equal(t, "chat-1", sessions[0].ChatID)
+		assert.Equal(t, 6, sessions[0].MessageCount)
+		assert.NotEmpty(t, next)
+
+		_, last, err := listSessions(context.TODO(), &ddbClient, testChatTable, "OwnerIndex", "sub-ann", 1, next)
+		assert.NoError(t, err)
+		assert.Empty(t, last)
+		assert.Equal(t, "OwnerIndex", aws.ToString(ddbClient.QueryInputs[1].IndexName))
+		assert.Equal(t, lastKey, ddbClient.QueryInputs[1].ExclusiveStartKey)
+	})
+
+	t.Run("It should reject another user's cursor", func(t *testing.T) {
+		cursor, _ := encodeCursor(map[string]dynamodb_types.AttributeValue{
+			"ownerId": &dynamodb_types.AttributeValueMemberS{Value: "sub-bob"},
+		})
+		ddbClient := awsclients.MockDynamodbClient{}
+
+		_, _, err := listSessions(context.TODO(), &ddbClient, testChatTable, "OwnerIndex", "sub-ann", 20, cursor)
+
+		assert.ErrorIs(t, err, errInvalidCursor)
+		assert.Empty(t, ddbClient.QueryInputs)
+	})
+}
+
+func TestDeleteSession(t *testing.T) {
+	keys := []map[string]dynamodb_types.AttributeValue{}
+	for _, msgKey := range []string{"00000001719828000000#m0", "00000001719828000001#m1", actionKeyPrefix + "act-1", auditKeyPrefix + "00000001719828000002#act-1", sessionMsgKey} {
+		keys = append(keys, map[string]dynamodb_types.AttributeValue{
+			"chatId": &dynamodb_types.AttributeValueMemberS{Value: "chat-1"},
+			"msgKey": &dynamodb_types.AttributeValueMemberS{Value: msgKey},
+		})
+	}
+	ddbClient := awsclients.MockDynamodbClient{
+		QueryOutputs:          []dynamodb.QueryOutput{{Items: keys}},
+		QueryErrors:           []error{nil},
+		BatchWriteItemOutputs: []dynamodb.BatchWriteItemOutput{{}},
+		BatchErrors:           []error{nil},
+		DeleteItemOutputs:     []dynamodb.DeleteItemOutput{{}},
+		DeleteItemErrors:      []error{nil},
+	}
+
+	err := deleteSession(context.TODO(), &ddbClient, testChatTable, &chatSessionRecord{ChatID: "chat-1", OwnerID: "sub-ann"})
+
+	assert.NoError(t, err)
+	deleted := []string{}
+	for _, req := range ddbClient.BatchWriteItemsInputs[0].RequestItems[testChatTable] {
+		deleted = append(deleted, req.DeleteRequest.Key["msgKey"].(*dynamodb_types.AttributeValueMemberS).Value)
+	}
+	assert.Equal(t, []string{"00000001719828000000#m0", "00000001719828000001#m1", actionKeyPrefix + "act-1"}, deleted)
+	assert.Equal(t, sessionMsgKey, ddbClient.DeleteItemInputs[0].Key["msgKey"].(*dynamodb_types.AttributeValueMemberS).Value)
+	assert.Equal(t, "sub-ann", ddbClient.DeleteItemInputs[0].ExpressionAttributeValues[":owner"].(*dynamodb_types.AttributeValueMemberS).Value)
+}
+
+func TestHandleSessions(t *testing.T) {
+	request := func(method, path, body string) events.APIGatewayProxyRequest {
+		return events.APIGatewayProxyRequest{
+			HTTPMethod: method,
+			Path:       path,
+			Body:       body,
+			Headers:    map[string]string{"X-Cognito-Id": "sub-ann"},
+		}
+	}
+
+	t.Run("It should not reveal another user's chat", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			GetItemOutputs: []dynamodb.GetItemOutput{sessionItem("chat-1", "sub-bob", 6)},
+			GetItemErrors:  []error{nil},
+		}
+
+		resp, _ := newTestSessionService(&ddbClient).Handle(request(http.MethodGet, "/v2/ai/chat/sessions/chat-1/export", ""))
+
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		assert.Empty(t, ddbClient.QueryInputs)
+	})
+
+	t.Run("It should not continue another user's chat", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			GetItemOutputs: []dynamodb.GetItemOutput{sessionItem("chat-1", "sub-bob", 6)},
+			GetItemErrors:  []error{nil},
+		}
+
+		resp, _ := newTestSessionService(&ddbClient).Handle(request(http.MethodPost, "/v2/ai/chat", `{"chatId":"chat-1","message":"What did Bob ask?"}`))
+
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		assert.Empty(t, ddbClient.QueryInputs)
+	})
+
+	t.Run("It should rename a chat", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			GetItemOutputs:    []dynamodb.GetItemOutput{sessionItem("chat-1", "sub-ann", 6)},
+			GetItemErrors:     []error{nil},
+			UpdateItemOutputs: []dynamodb.UpdateItemOutput{{}},
+			UpdateItemErrors:  []error{nil},
+		}
+
+		resp, _ := newTestSessionService(&ddbClient).Handle(request(http.MethodPatch, "/v2/ai/chat/sessions/chat-1", `{"title":" Q3 check-in "}`))
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var session ChatSession
+		json.Unmarshal([]byte(resp.Body), &session)
+		assert.Equal(t, "Q3 check-in", session.Title)
+		assert.Equal(t, "ownerId = :owner", aws.ToString(ddbClient.UpdateItemInputs[0].ConditionExpression))
+	})
+
+	t.Run("It should reject an empty title", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			GetItemOutputs: []dynamodb.GetItemOutput{sessionItem("chat-1", "sub-ann", 6)},
+			GetItemErrors:  []error{nil},
+		}
+
+		resp, _ := newTestSessionService(&ddbClient).Handle(request(http.MethodPatch, "/v2/ai/chat/sessions/chat-1", `{"title":"  "}`))
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Empty(t, ddbClient.UpdateItemInputs)
+	})
+
+	t.Run("It should export the full transcript", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			GetItemOutputs: []dynamodb.GetItemOutput{sessionItem("chat-1", "sub-ann", 4)},
+			GetItemErrors:  []error{nil},
+			QueryOutputs:   []dynamodb.QueryOutput{{Items: messageItems("chat-1", "sub-ann", 4)}},
+			QueryErrors:    []error{nil},
+		}
+
+		resp, _ := newTestSessionService(&ddbClient).Handle(request(http.MethodGet, "/v2/ai/chat/sessions/chat-1/export", ""))
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var export ChatSessionExport
+		json.Unmarshal([]byte(resp.Body), &export)
+		assert.Equal(t, "chat-1", export.ChatID)
+		assert.Len(t, export.Messages, 4)
+		assert.Equal(t, "assistant", export.Messages[1].Role)
+		assert.True(t, aws.ToBool(ddbClient.QueryInputs[0].ScanIndexForward))
+	})
+
+	t.Run("It should 404 a chat that does not exist", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			GetItemOutputs: []dynamodb.GetItemOutput{{}},
+			GetItemErrors:  []error{nil},
+			QueryOutputs:   []dynamodb.QueryOutput{{}},
+			QueryErrors:    []error{nil},
+		}
+
+		resp, _ := newTestSessionService(&ddbClient).Handle(request(http.MethodDelete, "/v2/ai/chat/sessions/chat-9", ""))
+
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		assert.True(t, strings.Contains(resp.Body, "chat not found"))
+	})
+}
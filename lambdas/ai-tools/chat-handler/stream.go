@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	bedrock "github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	bedrockdoc "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+	bedrocktypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// Streaming chat runs behind a Lambda function URL in RESPONSE_STREAM mode. The request body is
+// the same ChatRequest as POST /v2/ai/chat (without action) and the response is a
+// text/event-stream of:
+//
+//	start       {"chatId"}                         — once, before the model is called
+//	text        {"delta"}                          — incremental assistant text
+//	tool_start  {"toolUseId","tool"}               — a tool call is about to run
+//	tool_end    {"toolUseId","tool","failed"}      — the tool call finished
+//	done        ChatResponse                       — the answer, after the turn is saved; its
+//	                                                 response is all the text that was streamed
+//	error       {"error"}                          — the turn failed; nothing was saved
+//
+// Requests rejected before streaming starts get a plain JSON error body and status code.
+
+// HandleStream is the Lambda entry point for the streaming function URL.
+func (svc *Service) HandleStream(ctx context.Context, request events.LambdaFunctionURLRequest) (*events.LambdaFunctionURLStreamingResponse, error) {
+	if request.RequestContext.HTTP.Method != http.MethodPost {
+		return streamErrResponse(http.StatusMethodNotAllowed, "method not allowed")
+	}
+
+	// Function URLs have no Cognito authorizer, so the ID token is verified here.
+	cognitoID, err := svc.tokens.verify(ctx, request.Headers["authorization"])
+	if err != nil {
+		svc.logger.Printf("warn: rejected stream request: %v", err)
+		return streamErrResponse(http.StatusUnauthorized, "missing authentication")
+	}
+
+	req, err := parseChatRequest(request.Body)
+	if err != nil {
+		return streamErrResponse(http.StatusBadRequest, err.Error())
+	}
+	if req.Action != nil {
+		return streamErrResponse(http.StatusBadRequest, "actions are confirmed through POST /v2/ai/chat")
+	}
+
+	turn, err := svc.startTurn(ctx, req, cognitoID)
+	if errors.Is(err, errSessionNotFound) {
+		return streamErrResponse(http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		svc.logger.Printf("error: could not load session chatId=%q: %v", req.ChatID, err)
+		return streamErrResponse(http.StatusInternalServerError, "could not load chat")
+	}
+
+	// The runtime reads the body while the invoke context is still alive, so the turn runs in
+	// the background and writes events as they happen.
+	body, w := io.Pipe()
+	go func() {
+		defer w.Close()
+		svc.streamTurn(ctx, turn, &sseWriter{w: w})
+	}()
+
+	return &events.LambdaFunctionURLStreamingResponse{
+		StatusCode: http.StatusOK,
+		Headers: map[string]string{
+			"Content-Type":  "text/event-stream",
+			"Cache-Control": "no-cache",
+		},
+		Body: body,
+	}, nil
+}
+
+// streamTurn runs one chat turn and writes its events. The turn is saved even if the client has
+// gone away, as long as the model call completes.
+func (svc *Service) streamTurn(ctx context.Context, turn *chatTurn, sse *sseWriter) {
+	sse.send("start", map[string]string{"chatId": turn.req.ChatID})
+
+	summary, messages := svc.turnMessages(ctx, turn)
+	finalText, toolsUsed, err := svc.converseStreamWithTools(ctx, messages, summary, turn.chatCtx, turn.authz, turn.actions, sse)
+	if err != nil {
+		svc.logger.Printf("error: bedrock converse stream failed chatId=%q: %v", turn.req.ChatID, err)
+		sse.send("error", map[string]string{"error": "AI service error"})
+		return
+	}
+
+	sse.send("done", svc.finishTurn(ctx, turn, finalText, toolsUsed))
+}
+
+// converseStreamWithTools is converseWithTools over ConverseStream. Text and tool progress are
+// reported to obs as they arrive; finalText is exactly the text that was streamed.
+func (svc *Service) converseStreamWithTools(
+	ctx context.Context,
+	messages []bedrocktypes.Message,
+	summary string,
+	chatCtx ChatContext,
+	authz *toolAuthorizer,
+	actions *actionStore,
+	obs turnObserver,
+) (finalText string, toolsUsed []string, err error) {
+	tools := buildToolList()
+	systemPrompt := buildSystemPrompt(chatCtx, summary)
+	streamed := &streamedText{obs: obs}
+
+	for i := 0; i < maxToolIterations; i++ {
+		streamed.newTurn()
+		stream, err := svc.bedrockClient.ConverseStream(ctx, &bedrock.ConverseStreamInput{
+			ModelId: aws.String(svc.modelID),
+			System: []bedrocktypes.SystemContentBlock{
+				&bedrocktypes.SystemContentBlockMemberText{Value: systemPrompt},
+			},
+			Messages: messages,
+			ToolConfig: &bedrocktypes.ToolConfiguration{
+				Tools: tools,
+			},
+		})
+		if err != nil {
+			return "", toolsUsed, fmt.Errorf("ConverseStream call %d failed: %w", i+1, err)
+		}
+
+		reply, err := collectStream(stream, streamed)
+		if err != nil {
+			return "", toolsUsed, fmt.Errorf("ConverseStream call %d failed: %w", i+1, err)
+		}
+
+		if reply.stopReason != bedrocktypes.StopReasonToolUse {
+			return streamed.text.String(), toolsUsed, nil
+		}
+
+		messages = append(messages, reply.message)
+
+		calls := make([]toolCall, 0, len(reply.toolUses))
+		for _, use := range reply.toolUses {
+			use := use
+			calls = append(calls, toolCall{
+				id:   use.id,
+				name: use.name,
+				exec: func() (string, error) {
+					return executeStreamedToolCall(use.name, use.input, authz, actions, chatCtx)
+				},
+			})
+			toolsUsed = append(toolsUsed, use.name)
+		}
+		messages = append(messages, svc.runToolCalls(calls, streamed))
+	}
+
+	return "", toolsUsed, fmt.Errorf("reached maximum tool iterations (%d)", maxToolIterations)
+}
+
+// streamedText forwards progress to obs and keeps the text it streamed. Text from a new turn is
+// preceded by a blank line so it does not run on from the previous turn's.
+type streamedText struct {
+	obs       turnObserver
+	text      strings.Builder
+	turnStart bool
+}
+
+func (s *streamedText) newTurn() { s.turnStart = true }
+
+func (s *streamedText) textDelta(text string) {
+	if s.turnStart && s.text.Len() > 0 {
+		s.text.WriteString("\n\n")
+		s.obs.textDelta("\n\n")
+	}
+	s.turnStart = false
+	s.text.WriteString(text)
+	s.obs.textDelta(text)
+}
+
+func (s *streamedText) toolStart(toolUseID, name string) { s.obs.toolStart(toolUseID, name) }
+
+func (s *streamedText) toolEnd(toolUseID, name string, failed bool) {
+	s.obs.toolEnd(toolUseID, name, failed)
+}
+
+// streamedReply is an assistant message assembled from ConverseStream events.
+type streamedReply struct {
+	message    bedrocktypes.Message
+	toolUses   []streamedToolUse
+	stopReason bedrocktypes.StopReason
+}
+
+// streamedToolUse is a tool_use block whose input arrived as raw JSON text.
+type streamedToolUse struct {
+	id    *string
+	name  string
+	input string
+}
+
+// collectStream reads a ConverseStream to the end, reporting text deltas to obs, and assembles
+// the assistant message so it can be sent back to the model with the tool results.
+func collectStream(stream bedrock.ConverseStreamOutputReader, obs turnObserver) (streamedReply, error) {
+	defer stream.Close()
+
+	type block struct {
+		text    strings.Builder
+		input   strings.Builder
+		toolUse *bedrocktypes.ToolUseBlockStart
+	}
+	blocks := map[int32]*block{}
+	at := func(index *int32) *block {
+		i := aws.ToInt32(index)
+		if blocks[i] == nil {
+			blocks[i] = &block{}
+		}
+		return blocks[i]
+	}
+
+	var reply streamedReply
+	for event := range stream.Events() {
+		switch e := event.(type) {
+		case *bedrocktypes.ConverseStreamOutputMemberContentBlockStart:
+			if start, ok := e.Value.Start.(*bedrocktypes.ContentBlockStartMemberToolUse); ok {
+				at(e.Value.ContentBlockIndex).toolUse = &start.Value
+			}
+		case *bedrocktypes.ConverseStreamOutputMemberContentBlockDelta:
+			b := at(e.Value.ContentBlockIndex)
+			switch delta := e.Value.Delta.(type) {
+			case *bedrocktypes.ContentBlockDeltaMemberText:
+				b.text.WriteString(delta.Value)
+				obs.textDelta(delta.Value)
+			case *bedrocktypes.ContentBlockDeltaMemberToolUse:
+				b.input.WriteString(aws.ToString(delta.Value.Input))
+			}
+		case *bedrocktypes.ConverseStreamOutputMemberMessageStop:
+			reply.stopReason = e.Value.StopReason
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return reply, err
+	}
+
+	indexes := make([]int32, 0, len(blocks))
+	for i := range blocks {
+		indexes = append(indexes, i)
+	}
+	sort.Slice(indexes, func(a, b int) bool { return indexes[a] < indexes[b] })
+
+	reply.message.Role = bedrocktypes.ConversationRoleAssistant
+	for _, i := range indexes {
+		b := blocks[i]
+		if b.toolUse == nil {
+			if b.text.Len() > 0 {
+				reply.message.Content = append(reply.message.Content, &bedrocktypes.ContentBlockMemberText{Value: b.text.String()})
+			}
+			continue
+		}
+
+		use := streamedToolUse{id: b.toolUse.ToolUseId, name: aws.ToString(b.toolUse.Name), input: b.input.String()}
+		reply.toolUses = append(reply.toolUses, use)
+
+		// Bedrock wants the input back as an object; malformed input is reported by the tool call.
+		input := map[string]interface{}{}
+		_ = json.Unmarshal([]byte(use.input), &input)
+		reply.message.Content = append(reply.message.Content, &bedrocktypes.ContentBlockMemberToolUse{
+			Value: bedrocktypes.ToolUseBlock{
+				ToolUseId: use.id,
+				Name:      b.toolUse.Name,
+				Input:     bedrockdoc.NewLazyDocument(input),
+			},
+		})
+	}
+	return reply, nil
+}
+
+// sseWriter writes server-sent events and forwards converse progress as events. After the first
+// failed write (the client went away) it drops everything, so the turn can still finish.
+type sseWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (s *sseWriter) send(event string, data interface{}) {
+	if s.err != nil {
+		return
+	}
+	payload, _ := json.Marshal(data)
+	_, s.err = fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, payload)
+}
+
+func (s *sseWriter) textDelta(text string) {
+	s.send("text", map[string]string{"delta": text})
+}
+
+func (s *sseWriter) toolStart(toolUseID, name string) {
+	s.send("tool_start", map[string]string{"toolUseId": toolUseID, "tool": name})
+}
+
+func (s *sseWriter) toolEnd(toolUseID, name string, failed bool) {
+	s.send("tool_end", map[string]interface{}{"toolUseId": toolUseID, "tool": name, "failed": failed})
+}
+
+// streamErrResponse is errResponse for the streaming function URL.
+func streamErrResponse(statusCode int, message string) (*events.LambdaFunctionURLStreamingResponse, error) {
+	body, _ := json.Marshal(map[string]string{"error": message})
+	return &events.LambdaFunctionURLStreamingResponse{
+		StatusCode: statusCode,
+		Headers:    map[string]string{"Content-Type": "application/json"},
+		Body:       strings.NewReader(string(body)),
+	}, nil
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	bedrocktypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodb_types "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+
+	ctrl "github.com/busyfit-admin/saas-integrated-apis/lambdas/ai-tools/controllers"
+	awsclients "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/clients"
+	companylib "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/company-lib"
+)
+
+// fakeStream replays a fixed list of ConverseStream events and then reports err.
+type fakeStream struct {
+	events chan bedrocktypes.ConverseStreamOutput
+	err    error
+}
+
+func newFakeStream(err error, events ...bedrocktypes.ConverseStreamOutput) *fakeStream {
+	ch := make(chan bedrocktypes.ConverseStreamOutput, len(events))
+	for _, e := range events {
+		ch <- e
+	}
+	close(ch)
+	return &fakeStream{events: ch, err: err}
+}
+
+func (s *fakeStream) Events() <-chan bedrocktypes.ConverseStreamOutput { return s.events }
+func (s *fakeStream) Close() error                                     { return nil }
+func (s *fakeStream) Err() error                                       { return s.err }
+
+func textEvents(index int32, chunks ...string) []bedrocktypes.ConverseStreamOutput {
+	events := make([]bedrocktypes.ConverseStreamOutput, 0, len(chunks)+1)
+	for _, c := range chunks {
+		events = append(events, &bedrocktypes.ConverseStreamOutputMemberContentBlockDelta{Value: bedrocktypes.ContentBlockDeltaEvent{
+			ContentBlockIndex: aws.Int32(index),
+			Delta:             &bedrocktypes.ContentBlockDeltaMemberText{Value: c},
+		}})
+	}
+	return append(events, &bedrocktypes.ConverseStreamOutputMemberContentBlockStop{Value: bedrocktypes.ContentBlockStopEvent{ContentBlockIndex: aws.Int32(index)}})
+}
+
+func toolUseEvents(index int32, id, name string, inputChunks ...string) []bedrocktypes.ConverseStreamOutput {
+	events := []bedrocktypes.ConverseStreamOutput{
+		&bedrocktypes.ConverseStreamOutputMemberContentBlockStart{Value: bedrocktypes.ContentBlockStartEvent{
+			ContentBlockIndex: aws.Int32(index),
+			Start:             &bedrocktypes.ContentBlockStartMemberToolUse{Value: bedrocktypes.ToolUseBlockStart{ToolUseId: aws.String(id), Name: aws.String(name)}},
+		}},
+	}
+	for _, c := range inputChunks {
+		events = append(events, &bedrocktypes.ConverseStreamOutputMemberContentBlockDelta{Value: bedrocktypes.ContentBlockDeltaEvent{
+			ContentBlockIndex: aws.Int32(index),
+			Delta:             &bedrocktypes.ContentBlockDeltaMemberToolUse{Value: bedrocktypes.ToolUseBlockDelta{Input: aws.String(c)}},
+		}})
+	}
+	return append(events, &bedrocktypes.ConverseStreamOutputMemberContentBlockStop{Value: bedrocktypes.ContentBlockStopEvent{ContentBlockIndex: aws.Int32(index)}})
+}
+
+func streamOf(err error, stop bedrocktypes.StopReason, blocks ...[]bedrocktypes.ConverseStreamOutput) *fakeStream {
+	events := []bedrocktypes.ConverseStreamOutput{
+		&bedrocktypes.ConverseStreamOutputMemberMessageStart{Value: bedrocktypes.MessageStartEvent{Role: bedrocktypes.ConversationRoleAssistant}},
+	}
+	for _, b := range blocks {
+		events = append(events, b...)
+	}
+	if stop != "" {
+		events = append(events, &bedrocktypes.ConverseStreamOutputMemberMessageStop{Value: bedrocktypes.MessageStopEvent{StopReason: stop}})
+	}
+	return newFakeStream(err, events...)
+}
+
+// progressLog records converse progress as short strings.
+type progressLog []string
+
+func (p *progressLog) textDelta(text string) { *p = append(*p, "text:"+text) }
+func (p *progressLog) toolStart(toolUseID, name string) {
+	*p = append(*p, fmt.Sprintf("tool_start:%s:%s", toolUseID, name))
+}
+func (p *progressLog) toolEnd(toolUseID, name string, failed bool) {
+	*p = append(*p, fmt.Sprintf("tool_end:%s:%s:%t", toolUseID, name, failed))
+}
+
+func TestCollectStream(t *testing.T) {
+	t.Run("It should assemble text and tool input split across deltas", func(t *testing.T) {
+		stream := streamOf(nil, bedrocktypes.StopReasonToolUse,
+			textEvents(0, "Let me ", "check."),
+			toolUseEvents(1, "tu-1", "get_my_goals", `{"teamId":`, `"TEAM#1"}`),
+		)
+		progress := &progressLog{}
+
+		reply, err := collectStream(stream, progress)
+
+		assert.NoError(t, err)
+		assert.Equal(t, bedrocktypes.StopReasonToolUse, reply.stopReason)
+		assert.Equal(t, progressLog{"text:Let me ", "text:check."}, *progress)
+		assert.Equal(t, []streamedToolUse{{id: aws.String("tu-1"), name: "get_my_goals", input: `{"teamId":"TEAM#1"}`}}, reply.toolUses)
+		assert.Equal(t, bedrocktypes.ConversationRoleAssistant, reply.message.Role)
+		assert.Len(t, reply.message.Content, 2)
+		assert.Equal(t, "Let me check.", messageText(reply.message.Content))
+		toolUse, ok := reply.message.Content[1].(*bedrocktypes.ContentBlockMemberToolUse)
+		assert.True(t, ok)
+		assert.Equal(t, "get_my_goals", aws.ToString(toolUse.Value.Name))
+	})
+
+	t.Run("It should return the stream's error", func(t *testing.T) {
+		_, err := collectStream(streamOf(errors.New("throttled"), "", textEvents(0, "Hel")), &progressLog{})
+
+		assert.EqualError(t, err, "throttled")
+	})
+}
+
+func TestConverseStreamWithTools(t *testing.T) {
+	t.Run("It should stream the answer of a turn without tools", func(t *testing.T) {
+		model := &scriptedModel{turns: []scriptedTurn{{Text: "You have 3 goals.", StopReason: "end_turn"}}}
+		svc := &Service{logger: log.New(&bytes.Buffer{}, "", 0), bedrockClient: model}
+		progress := &progressLog{}
+
+		text, toolsUsed, err := svc.converseStreamWithTools(context.TODO(), userMessages("How many goals?"), "", ChatContext{}, nil, nil, progress)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "You have 3 goals.", text)
+		assert.Empty(t, toolsUsed)
+		assert.Equal(t, progressLog{"text:You ", "text:have ", "text:3 ", "text:goals."}, *progress)
+	})
+
+	t.Run("It should run tools between streamed turns and send their results back", func(t *testing.T) {
+		model := &scriptedModel{turns: []scriptedTurn{
+			{Text: "Checking.", ToolUses: []scriptedToolUse{{ID: "tu-1", Name: "get_weather", Input: []byte(`{"city":"Paris"}`)}}, StopReason: "tool_use"},
+			{Text: "No weather here.", StopReason: "end_turn"},
+		}}
+		svc := &Service{logger: log.New(&bytes.Buffer{}, "", 0), bedrockClient: model}
+		progress := &progressLog{}
+
+		text, toolsUsed, err := svc.converseStreamWithTools(context.TODO(), userMessages("Weather in Paris?"), "", ChatContext{}, nil, nil, progress)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Checking.\n\nNo weather here.", text)
+		assert.Equal(t, []string{"get_weather"}, toolsUsed)
+		assert.Equal(t, progressLog{
+			"text:Checking.",
+			"tool_start:tu-1:get_weather",
+			"tool_end:tu-1:get_weather:true",
+			"text:\n\n", "text:No ", "text:weather ", "text:here.",
+		}, *progress)
+
+		assert.Len(t, model.requests, 2)
+		sent := model.requests[1]
+		assert.Len(t, sent, 3)
+		assert.Equal(t, bedrocktypes.ConversationRoleAssistant, sent[1].Role)
+		result, ok := sent[2].Content[0].(*bedrocktypes.ContentBlockMemberToolResult)
+		assert.True(t, ok)
+		assert.Equal(t, "tu-1", aws.ToString(result.Value.ToolUseId))
+		assert.Equal(t, `{"error":"unknown tool: get_weather"}`, result.Value.Content[0].(*bedrocktypes.ToolResultContentBlockMemberText).Value)
+	})
+
+	t.Run("It should fail when the stream breaks", func(t *testing.T) {
+		model := &scriptedModel{turns: []scriptedTurn{{Text: "Hel", Error: "connection reset"}}}
+		svc := &Service{logger: log.New(&bytes.Buffer{}, "", 0), bedrockClient: model}
+
+		_, _, err := svc.converseStreamWithTools(context.TODO(), userMessages("Hello"), "", ChatContext{}, nil, nil, &progressLog{})
+
+		assert.EqualError(t, err, "ConverseStream call 1 failed: connection reset")
+	})
+}
+
+func userMessages(text string) []bedrocktypes.Message {
+	return []bedrocktypes.Message{{
+		Role:    bedrocktypes.ConversationRoleUser,
+		Content: []bedrocktypes.ContentBlock{&bedrocktypes.ContentBlockMemberText{Value: text}},
+	}}
+}
+
+func employeeQuery(userName, cognitoID string) dynamodb.QueryOutput {
+	item, _ := attributevalue.MarshalMap(companylib.EmployeeDynamodbData{UserName: userName, CognitoId: cognitoID, DisplayName: "Ann Lee"})
+	return dynamodb.QueryOutput{Count: 1, Items: []map[string]dynamodb_types.AttributeValue{item}}
+}
+
+func TestHandleStream(t *testing.T) {
+	verifier, key := newTestVerifier(t)
+	token := "Bearer " + signToken(t, key, testKeyID, idClaims(verifier, "sub-ann"))
+
+	newStreamService := func(ddbClient *awsclients.MockDynamodbClient, model *scriptedModel) *Service {
+		svc := newTestSessionService(ddbClient)
+		svc.tokens = verifier
+		svc.bedrockClient = model
+		svc.ctrlSVC = ctrl.CreateService(context.TODO(), ddbClient, svc.logger, ctrl.Tables{Employee: "EmployeeTable-test", EmployeeCognitoIdIndex: "CognitoIdIndex"})
+		return svc
+	}
+	request := func(authorization, body string) events.LambdaFunctionURLRequest {
+		req := events.LambdaFunctionURLRequest{Headers: map[string]string{"authorization": authorization}, Body: body}
+		req.RequestContext.HTTP.Method = http.MethodPost
+		return req
+	}
+
+	t.Run("It should reject a request without a valid ID token", func(t *testing.T) {
+		svc := newStreamService(&awsclients.MockDynamodbClient{}, &scriptedModel{})
+
+		resp, err := svc.HandleStream(context.TODO(), request("Bearer not-a-jwt", `{"message":"Hi"}`))
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("It should send action decisions to the REST endpoint", func(t *testing.T) {
+		svc := newStreamService(&awsclients.MockDynamodbClient{}, &scriptedModel{})
+
+		resp, err := svc.HandleStream(context.TODO(), request(token, `{"chatId":"chat-1","action":{"actionId":"act-1","decision":"confirm","idempotencyKey":"k-1"}}`))
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("It should not continue another user's chat", func(t *testing.T) {
+		ddbClient := &awsclients.MockDynamodbClient{
+			GetItemOutputs: []dynamodb.GetItemOutput{sessionItem("chat-1", "sub-bob", 4)},
+			GetItemErrors:  []error{nil},
+		}
+		svc := newStreamService(ddbClient, &scriptedModel{})
+
+		resp, err := svc.HandleStream(context.TODO(), request(token, `{"chatId":"chat-1","message":"Hi"}`))
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("It should stream a turn and save it when the answer is complete", func(t *testing.T) {
+		ddbClient := &awsclients.MockDynamodbClient{
+			GetItemOutputs:           []dynamodb.GetItemOutput{sessionItem("chat-1", "sub-ann", 0)},
+			GetItemErrors:            []error{nil},
+			QueryOutputs:             []dynamodb.QueryOutput{employeeQuery("ann", "sub-ann")},
+			QueryErrors:              []error{nil},
+			TransactWriteItemsOutput: []dynamodb.TransactWriteItemsOutput{{}},
+			TransactWriteItemsErrors: []error{nil},
+		}
+		model := &scriptedModel{turns: []scriptedTurn{{Text: "Hi Ann!", StopReason: "end_turn"}}}
+		svc := newStreamService(ddbClient, model)
+
+		resp, err := svc.HandleStream(context.TODO(), request(token, `{"chatId":"chat-1","message":"Hello"}`))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Headers["Content-Type"])
+
+		body, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "event: start\ndata: {\"chatId\":\"chat-1\"}\n\n"+
+			"event: text\ndata: {\"delta\":\"Hi \"}\n\n"+
+			"event: text\ndata: {\"delta\":\"Ann!\"}\n\n"+
+			"event: done\ndata: {\"chatId\":\"chat-1\",\"response\":\"Hi Ann!\",\"toolsUsed\":null}\n\n", string(body))
+
+		assert.Len(t, ddbClient.TransactWriteItemsInputs, 1)
+		saved := ddbClient.TransactWriteItemsInputs[0].TransactItems
+		var assistant chatHistoryRecord
+		_ = attributevalue.UnmarshalMap(saved[1].Put.Item, &assistant)
+		assert.Equal(t, "Hi Ann!", assistant.MessageText)
+	})
+
+	t.Run("It should report a failed turn without saving it", func(t *testing.T) {
+		ddbClient := &awsclients.MockDynamodbClient{
+			GetItemOutputs: []dynamodb.GetItemOutput{sessionItem("chat-1", "sub-ann", 0)},
+			GetItemErrors:  []error{nil},
+			QueryOutputs:   []dynamodb.QueryOutput{employeeQuery("ann", "sub-ann")},
+			QueryErrors:    []error{nil},
+		}
+		model := &scriptedModel{turns: []scriptedTurn{{Text: "Hi", Error: "throttled"}}}
+		svc := newStreamService(ddbClient, model)
+
+		resp, _ := svc.HandleStream(context.TODO(), request(token, `{"chatId":"chat-1","message":"Hello"}`))
+		body, _ := io.ReadAll(resp.Body)
+
+		assert.Contains(t, string(body), "event: error\ndata: {\"error\":\"AI service error\"}\n\n")
+		assert.Empty(t, ddbClient.TransactWriteItemsInputs)
+	})
+}
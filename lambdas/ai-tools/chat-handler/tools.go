@@ -2,7 +2,9 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	bedrockdoc "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
@@ -81,20 +83,66 @@ var toolRegistry = map[string]toolExecutorFn{
 
 // executeToolCall dispatches a tool call from Bedrock to the correct executor and
 // returns a JSON-encoded string suitable for use as a Bedrock ToolResultBlock text.
-func executeToolCall(toolName string, inputDoc bedrockdoc.Interface, svc *ctrl.Service, chatCtx ChatContext) (string, error) {
-	executor, ok := toolRegistry[toolName]
-	if !ok {
+// The input is read back as JSON: UnmarshalSmithyDocument only works on documents decoded from a
+// Bedrock response, not on ones built with NewLazyDocument by another converseClient.
+func executeToolCall(toolName string, inputDoc bedrockdoc.Interface, authz *toolAuthorizer, actions *actionStore, chatCtx ChatContext) (string, error) {
+	if !isKnownTool(toolName) {
 		return fmt.Sprintf(`{"error":"unknown tool: %s"}`, toolName), nil
 	}
-	var input map[string]interface{}
-	if err := inputDoc.UnmarshalSmithyDocument(&input); err != nil {
-		return fmt.Sprintf(`{"error":"failed to parse tool input: %v"}`, err), nil
+	var raw []byte
+	if inputDoc != nil {
+		var err error
+		if raw, err = inputDoc.MarshalSmithyDocument(); err != nil {
+			return fmt.Sprintf(`{"error":"failed to parse tool input: %v"}`, err), nil
+		}
+	}
+	return executeStreamedToolCall(toolName, string(raw), authz, actions, chatCtx)
+}
+
+// executeStreamedToolCall is executeToolCall for a tool input ConverseStream delivered as raw
+// JSON text.
+func executeStreamedToolCall(toolName, rawInput string, authz *toolAuthorizer, actions *actionStore, chatCtx ChatContext) (string, error) {
+	if !isKnownTool(toolName) {
+		return fmt.Sprintf(`{"error":"unknown tool: %s"}`, toolName), nil
+	}
+	input := map[string]interface{}{}
+	if s := strings.TrimSpace(rawInput); s != "" && s != "null" {
+		if err := json.Unmarshal([]byte(s), &input); err != nil {
+			return jsonStr(map[string]interface{}{"error": fmt.Sprintf("failed to parse tool input: %v", err)}), nil
+		}
+	}
+	return runTool(toolName, input, authz, actions, chatCtx), nil
+}
+
+func isKnownTool(toolName string) bool {
+	_, isRead := toolRegistry[toolName]
+	_, isAction := actionRegistry[toolName]
+	return isRead || isAction
+}
+
+// runTool checks a parsed tool call against its toolPolicy, runs the executor and redacts the
+// result for the caller's role. Calls to mutating tools are only recorded as pending actions.
+// Denials are logged and returned to the model as errors.
+func runTool(toolName string, input map[string]interface{}, authz *toolAuthorizer, actions *actionStore, chatCtx ChatContext) string {
+	access, err := authz.authorize(toolName, input, chatCtx)
+	if err != nil {
+		if errors.Is(err, errToolDenied) {
+			authz.logger.Printf("policy: denied tool=%s caller=%q input=%s: %v", toolName, authz.caller, jsonStr(input), err)
+		}
+		return jsonStr(map[string]interface{}{"error": err.Error()})
+	}
+	if _, ok := actionRegistry[toolName]; ok {
+		return actions.propose(toolName, input, access, chatCtx)
+	}
+	result, err := toolRegistry[toolName](input, authz.svc, chatCtx)
+	if err != nil {
+		return jsonStr(map[string]interface{}{"error": err.Error()})
 	}
-	result, err := executor(input, svc, chatCtx)
+	redacted, err := redactResult(result, access)
 	if err != nil {
-		return jsonStr(map[string]interface{}{"error": err.Error()}), nil
+		return jsonStr(map[string]interface{}{"error": err.Error()})
 	}
-	return jsonStr(result), nil
+	return jsonStr(redacted)
 }
 
 // buildToolList returns the complete list of Bedrock-compatible tool definitions.
@@ -207,8 +255,12 @@ func buildToolList() []bedrocktypes.Tool {
 		},
 		{
 			"get_cycle_analytics",
-			"Return aggregated analytics (completion rates, distributions) for a cycle.",
-			obj(prop("cycleId", str("Performance cycle ID")), req("cycleId")),
+			"Return aggregated analytics (completion rates, distributions) for a cycle, with KPI trend series per KPI and department, rolling averages, end-of-period forecasts and which KPIs are projected to miss their target.",
+			obj(
+				prop("cycleId", str("Performance cycle ID")),
+				prop("granularity", str("Optional trend granularity: month (default) | week")),
+				req("cycleId"),
+			),
 		},
 		{
 			"get_all_quarters",
@@ -227,8 +279,12 @@ func buildToolList() []bedrocktypes.Tool {
 		},
 		{
 			"get_quarter_analytics",
-			"Return analytics summary for a quarter.",
-			obj(prop("quarterId", str("Quarter ID")), req("quarterId")),
+			"Return analytics summary for a quarter, with KPI trend series, forecasts and which KPIs are projected to miss their target.",
+			obj(
+				prop("quarterId", str("Quarter ID")),
+				prop("granularity", str("Optional trend granularity: month (default) | week")),
+				req("quarterId"),
+			),
 		},
 		{
 			"get_quarter_meeting_notes",
@@ -492,6 +548,64 @@ func buildToolList() []bedrocktypes.Tool {
 			"Return a full performance summary for a team member, including goals, tasks, meetings, and appreciations.",
 			obj(prop("teamId", str("Team ID")), prop("memberId", str("Target member username")), req("teamId", "memberId")),
 		},
+		// ----- Actions — proposed here, run only after the user confirms them -----
+		{
+			"create_task",
+			"Propose creating a task for the current user. Nothing is created until the user confirms it in the app.",
+			obj(
+				prop("title", str("Task title")),
+				prop("description", str("Optional task description")),
+				prop("priority", str("Optional priority: low | medium | high | urgent")),
+				prop("status", str("Optional status: todo | in-progress | done | closed (default todo)")),
+				prop("goalId", str("Optional goal ID to link the task to")),
+				prop("dueDate", str("Optional due date (YYYY-MM-DD)")),
+				prop("timeHours", number("Optional hours already spent")),
+				prop("teamId", str("Team ID; defaults to caller's team")),
+				req("title"),
+			),
+		},
+		{
+			"log_task_time",
+			"Propose adding hours to the time logged on one of the current user's tasks (e.g. TASK-123). Nothing changes until the user confirms it.",
+			obj(
+				prop("taskId", str("Task ID, e.g. TASK-123")),
+				prop("hours", number("Hours to add")),
+				prop("teamId", str("Team ID; defaults to caller's team")),
+				req("taskId", "hours"),
+			),
+		},
+		{
+			"add_goal_comment",
+			"Propose adding a comment to a goal. Managers may comment on a team member's goal. Nothing is posted until the user confirms it.",
+			obj(
+				prop("goalId", str("Goal ID")),
+				prop("text", str("Comment text")),
+				prop("userName", str("Goal owner's username; defaults to caller")),
+				prop("teamId", str("Team ID; defaults to caller's team")),
+				req("goalId", "text"),
+			),
+		},
+		{
+			"request_feedback",
+			"Propose asking a teammate for feedback on behalf of the current user. Nothing is sent until the user confirms it.",
+			obj(
+				prop("toUsername", str("Username of the teammate to ask")),
+				prop("message", str("What the user would like feedback on")),
+				prop("dueDate", str("Optional due date (YYYY-MM-DD); defaults to two weeks from now")),
+				prop("teamId", str("Team ID; defaults to caller's team")),
+				req("toUsername", "message"),
+			),
+		},
+		{
+			"give_kudos",
+			"Propose posting kudos for a teammate on the team feed on behalf of the current user. Nothing is posted until the user confirms it.",
+			obj(
+				prop("recipientUsername", str("Username of the teammate receiving kudos")),
+				prop("message", str("What the kudos is for")),
+				prop("teamId", str("Team ID; defaults to caller's team")),
+				req("recipientUsername", "message"),
+			),
+		},
 	}
 
 	tools := make([]bedrocktypes.Tool, 0, len(defs))
@@ -548,6 +662,10 @@ func boolean(desc string) map[string]interface{} {
 	return map[string]interface{}{"type": "boolean", "description": desc}
 }
 
+func number(desc string) map[string]interface{} {
+	return map[string]interface{}{"type": "number", "description": desc}
+}
+
 func req(names ...string) map[string]interface{} {
 	reqs := make([]interface{}, len(names))
 	for i, n := range names {
@@ -576,6 +694,33 @@ func getBool(m map[string]interface{}, key string) bool {
 	return false
 }
 
+// getNumber returns a numeric input as float64, or 0 when it is missing or not a number.
+func getNumber(m map[string]interface{}, key string) float64 {
+	n, _ := toFloat(m[key])
+	return n
+}
+
+// normalizeNumber replaces a numeric input with its float64 value, so json.Number or other
+// numeric types are never stored as strings.
+func normalizeNumber(m map[string]interface{}, key string) (float64, error) {
+	n, err := toFloat(m[key])
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a number", key)
+	}
+	m[key] = n
+	return n, nil
+}
+
+func toFloat(v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case interface{ Float64() (float64, error) }:
+		return n.Float64()
+	}
+	return 0, fmt.Errorf("not a number: %v", v)
+}
+
 func jsonStr(v interface{}) string {
 	b, err := json.Marshal(v)
 	if err != nil {
@@ -677,7 +822,7 @@ func execGetPerformanceCycleDetails(in map[string]interface{}, svc *ctrl.Service
 }
 
 func execGetCycleAnalytics(in map[string]interface{}, svc *ctrl.Service, ctx ChatContext) (interface{}, error) {
-	return svc.GetCycleAnalytics(getStr(in, "cycleId"))
+	return svc.GetCycleAnalytics(getStr(in, "cycleId"), getStr(in, "granularity"))
 }
 
 func execGetAllQuarters(in map[string]interface{}, svc *ctrl.Service, ctx ChatContext) (interface{}, error) {
@@ -695,7 +840,7 @@ func execGetQuarterDetails(in map[string]interface{}, svc *ctrl.Service, ctx Cha
 }
 
 func execGetQuarterAnalytics(in map[string]interface{}, svc *ctrl.Service, ctx ChatContext) (interface{}, error) {
-	return svc.GetQuarterAnalytics(getStr(in, "quarterId"))
+	return svc.GetQuarterAnalytics(getStr(in, "quarterId"), getStr(in, "granularity"))
 }
 
 func execGetQuarterMeetingNotes(in map[string]interface{}, svc *ctrl.Service, ctx ChatContext) (interface{}, error) {
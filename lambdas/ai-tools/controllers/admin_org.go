@@ -29,3 +29,8 @@ func (s *Service) IsOrgAdmin(orgId, userName string) (bool, error) {
 func (s *Service) GetAdminOrganizations(userName string) ([]companylib.Organization, error) {
 	return s.orgSVC.GetAdminsOrganizations(userName)
 }
+
+// IsOrgMember returns true if the given user is an active member of the specified organisation.
+func (s *Service) IsOrgMember(orgId, userName string) (bool, error) {
+	return s.orgSVC.IsOrgMember(orgId, userName)
+}
@@ -35,9 +35,10 @@ func (s *Service) GetPerformanceCycleDetails(cycleID string, includeQuarters, in
 	return s.perfSVC.GetPerformanceCycleDetails(cycleID, includeQuarters, includeKPIs, includeOKRs, includeAnalytics)
 }
 
-// GetCycleAnalytics returns aggregated progress analytics for a performance cycle.
-func (s *Service) GetCycleAnalytics(cycleID string) (map[string]interface{}, error) {
-	return s.perfSVC.GetCycleAnalytics(cycleID)
+// GetCycleAnalytics returns aggregated progress analytics for a performance cycle,
+// with KPI trend series and forecasts by month or week.
+func (s *Service) GetCycleAnalytics(cycleID, granularity string) (map[string]interface{}, error) {
+	return s.perfSVC.GetCycleAnalytics(cycleID, granularity)
 }
 
 // ==================== Quarters ====================
@@ -53,9 +54,10 @@ func (s *Service) GetQuarterDetails(quarterID string, includeKPIs, includeOKRs,
 	return s.perfSVC.GetQuarterDetails(quarterID, includeKPIs, includeOKRs, includeMeetingNotes, includePendingReviews)
 }
 
-// GetQuarterAnalytics returns aggregated progress analytics for a quarter.
-func (s *Service) GetQuarterAnalytics(quarterID string) (map[string]interface{}, error) {
-	return s.perfSVC.GetQuarterAnalytics(quarterID)
+// GetQuarterAnalytics returns aggregated progress analytics for a quarter,
+// with KPI trend series and forecasts by month or week.
+func (s *Service) GetQuarterAnalytics(quarterID, granularity string) (map[string]interface{}, error) {
+	return s.perfSVC.GetQuarterAnalytics(quarterID, granularity)
 }
 
 // ==================== KPIs ====================
@@ -145,3 +147,11 @@ func (s *Service) GetOrgGoalSubItems(goalID string) (map[string]interface{}, err
 func (s *Service) GetQuarterMeetingNotes(quarterID, sortBy, order string) (map[string]interface{}, error) {
 	return s.perfSVC.ListMeetingNotes(quarterID, sortBy, order)
 }
+
+// ==================== Record Ownership ====================
+
+// GetRecordOrganization returns the organisation (ORG#...) that owns a performance record, or ""
+// when it does not exist. kind: "cycle" | "quarter" | "kpi" | "okr" | "goal".
+func (s *Service) GetRecordOrganization(kind, id string) (string, error) {
+	return s.perfSVC.GetRecordOrganization(kind, id)
+}
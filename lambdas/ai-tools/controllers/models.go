@@ -14,6 +14,10 @@ const (
 	skCommentInfix         = "#CMMNT#"
 	skManagerCommentPrefix = "MGRCMT#"
 	skMemberReviewPrefix   = "REVIEW#MEMBER#"
+
+	prefixPost     = "POST#"
+	skPostMetadata = "#METADATA"
+	postTypeKudos  = "kudos"
 )
 
 // buildPK returns the per-user per-team partition key: USER#{userName}#TEAM#{teamID}
@@ -49,6 +53,15 @@ const (
 
 // ==================== Task Types ====================
 
+type TaskPriority = string
+
+const (
+	TaskPriorityLow    TaskPriority = "low"
+	TaskPriorityMedium TaskPriority = "medium"
+	TaskPriorityHigh   TaskPriority = "high"
+	TaskPriorityUrgent TaskPriority = "urgent"
+)
+
 type TaskStatus = string
 
 const (
@@ -162,16 +175,24 @@ type AppreciationRecord struct {
 
 // FeedbackRequestRecord is a feedback request sent by an employee to a colleague.
 // PK=USER#{userName}#TEAM#{teamId}  SK=FBREQ#{requestId}
+// Pending requests carry reminderKey/remindAt so the performance hub scheduler can remind the
+// recipient and expire the request at the end of dueDate.
 type FeedbackRequestRecord struct {
-	PK        string `dynamodbav:"PK"`
-	SK        string `dynamodbav:"SK"`
-	RequestID string `dynamodbav:"requestId"`
-	UserName  string `dynamodbav:"userName"` // sender
-	To        string `dynamodbav:"to"`       // recipient userName
-	Message   string `dynamodbav:"message"`
-	Date      string `dynamodbav:"date"`
-	Status    string `dynamodbav:"status"` // "pending" | "completed"
-	CreatedAt string `dynamodbav:"createdAt"`
+	PK          string `dynamodbav:"PK"`
+	SK          string `dynamodbav:"SK"`
+	RequestID   string `dynamodbav:"requestId"`
+	TeamID      string `dynamodbav:"teamId,omitempty"`
+	UserName    string `dynamodbav:"userName"` // sender
+	FromName    string `dynamodbav:"fromName,omitempty"`
+	To          string `dynamodbav:"to"` // recipient userName
+	Message     string `dynamodbav:"message"`
+	Date        string `dynamodbav:"date"`
+	Status      string `dynamodbav:"status"` // "pending" | "completed" | "declined" | "expired"
+	DueDate     string `dynamodbav:"dueDate,omitempty"`
+	ExpiresAt   string `dynamodbav:"expiresAt,omitempty"`
+	ReminderKey string `dynamodbav:"reminderKey,omitempty"`
+	RemindAt    string `dynamodbav:"remindAt,omitempty"`
+	CreatedAt   string `dynamodbav:"createdAt"`
 }
 
 // ManagerCommentRecord is a manager-authored comment on a team member.
@@ -204,6 +225,33 @@ type TeamMemberReviewRecord struct {
 	UpdatedAt             string  `dynamodbav:"updatedAt"`
 }
 
+// KudosPostRecord is a kudos post on the team feed, in the shape of the team-feeds PostRecord so
+// it shows up in the feed, its search and its notifications like one posted in the app.
+// PK=POST#{postId}  SK=#METADATA  GSI1PK=TEAM#{teamId}  GSI1SK={createdAt}#{postId}
+type KudosPostRecord struct {
+	PK           string        `dynamodbav:"PK"`
+	SK           string        `dynamodbav:"SK"`
+	GSI1PK       string        `dynamodbav:"GSI1PK"`
+	GSI1SK       string        `dynamodbav:"GSI1SK"`
+	PostID       string        `dynamodbav:"postId"`
+	TeamID       string        `dynamodbav:"teamId"`
+	Type         string        `dynamodbav:"type"` // always "kudos"
+	AuthorUserID string        `dynamodbav:"authorUserId"`
+	AuthorName   string        `dynamodbav:"authorName"`
+	Content      string        `dynamodbav:"content,omitempty"`
+	LikeCount    int           `dynamodbav:"likeCount"`
+	CommentCount int           `dynamodbav:"commentCount"`
+	CreatedAt    string        `dynamodbav:"createdAt"`
+	UpdatedAt    string        `dynamodbav:"updatedAt"`
+	Data         KudosPostData `dynamodbav:"data"`
+}
+
+// KudosPostData is the type-specific "data" map of a kudos post.
+type KudosPostData struct {
+	KudosRecipientUserID string `dynamodbav:"kudosRecipientUserId"`
+	KudosRecipientName   string `dynamodbav:"kudosRecipientName,omitempty"`
+}
+
 // ==================== Filter Types ====================
 
 // GoalFilters contains optional filters for listing goals.
@@ -14,6 +14,7 @@ import (
 	"github.com/aws/aws-xray-sdk-go/instrumentation/awsv2"
 	"github.com/aws/aws-xray-sdk-go/xray"
 
+	awsclients "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/clients"
 	companylib "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/company-lib"
 )
 
@@ -29,10 +30,26 @@ type Service struct {
 	teamsSVC *companylib.TeamsServiceV2
 	orgSVC   *companylib.OrgServiceV2
 	perfSVC  *companylib.PerformanceService
+	notifSVC *companylib.NotificationService
 
-	// raw DynamoDB client for direct performance-hub table queries
-	ddb          *dynamodb.Client
+	// raw DynamoDB client for direct performance-hub and team feed table access
+	ddb          awsclients.DynamodbClient
 	perfHubTable string
+	feedTable    string
+}
+
+// Tables names the DynamoDB tables and indexes the controllers read from and write to.
+type Tables struct {
+	Employee                 string
+	EmployeeCognitoIdIndex   string
+	EmployeeEmailIdIndex     string
+	Teams                    string
+	Organization             string
+	OrgPerformance           string
+	PerfHub                  string
+	TeamFeed                 string
+	Notifications            string
+	NotificationsDigestIndex string
 }
 
 // NewService initialises all AWS clients and companylib services from environment variables.
@@ -40,12 +57,15 @@ type Service struct {
 // Required environment variables:
 //
 //	PERF_HUB_TABLE                    — DynamoDB table for user performance hub data
+//	TEAM_FEED_TABLE                   — DynamoDB table for team feed posts
 //	ORG_PERFORMANCE_TABLE             — DynamoDB table for org-level performance data
 //	ORGANIZATION_TABLE                — DynamoDB table for organisation records
 //	EMPLOYEE_TABLE                    — DynamoDB table for employee records
 //	EMPLOYEE_TABLE_COGNITO_ID_INDEX   — GSI name for Cognito ID lookups
 //	EMPLOYEE_TABLE_EMAIL_ID_INDEX     — GSI name for email lookups
 //	TEAMS_TABLE                       — DynamoDB table for team records
+//	NOTIFICATIONS_TABLE               — DynamoDB table for in-app notifications
+//	NOTIFICATIONS_TABLE_DIGEST_INDEX  — GSI name for notification digests
 func NewService() (*Service, error) {
 	ctx, seg := xray.BeginSegment(context.TODO(), "ai-tools-service")
 	defer seg.Close(nil)
@@ -57,26 +77,47 @@ func NewService() (*Service, error) {
 	awsv2.AWSV2Instrumentor(&cfg.APIOptions)
 
 	logger := log.New(os.Stdout, "[ai-tools] ", log.LstdFlags)
-	ddbClient := dynamodb.NewFromConfig(cfg)
 
+	return CreateService(ctx, dynamodb.NewFromConfig(cfg), logger, Tables{
+		Employee:                 os.Getenv("EMPLOYEE_TABLE"),
+		EmployeeCognitoIdIndex:   os.Getenv("EMPLOYEE_TABLE_COGNITO_ID_INDEX"),
+		EmployeeEmailIdIndex:     os.Getenv("EMPLOYEE_TABLE_EMAIL_ID_INDEX"),
+		Teams:                    os.Getenv("TEAMS_TABLE"),
+		Organization:             os.Getenv("ORGANIZATION_TABLE"),
+		OrgPerformance:           os.Getenv("ORG_PERFORMANCE_TABLE"),
+		PerfHub:                  os.Getenv("PERF_HUB_TABLE"),
+		TeamFeed:                 os.Getenv("TEAM_FEED_TABLE"),
+		Notifications:            os.Getenv("NOTIFICATIONS_TABLE"),
+		NotificationsDigestIndex: os.Getenv("NOTIFICATIONS_TABLE_DIGEST_INDEX"),
+	}), nil
+}
+
+// CreateService wires the companylib services over an existing DynamoDB client.
+func CreateService(ctx context.Context, ddbClient awsclients.DynamodbClient, logger *log.Logger, tables Tables) *Service {
 	// Employee service
 	empSVC := companylib.CreateEmployeeService(ctx, ddbClient, nil, logger)
-	empSVC.EmployeeTable = os.Getenv("EMPLOYEE_TABLE")
-	empSVC.EmployeeTable_CognitoId_Index = os.Getenv("EMPLOYEE_TABLE_COGNITO_ID_INDEX")
-	empSVC.EmployeeTable_EmailId_Index = os.Getenv("EMPLOYEE_TABLE_EMAIL_ID_INDEX")
+	empSVC.EmployeeTable = tables.Employee
+	empSVC.EmployeeTable_CognitoId_Index = tables.EmployeeCognitoIdIndex
+	empSVC.EmployeeTable_EmailId_Index = tables.EmployeeEmailIdIndex
 
 	// Teams service
 	teamsSVC := companylib.CreateTeamsServiceV2(ctx, ddbClient, logger, empSVC, nil)
-	teamsSVC.TeamsTable = os.Getenv("TEAMS_TABLE")
+	teamsSVC.TeamsTable = tables.Teams
 
 	// Org service
 	orgSVC := companylib.CreateOrgServiceV2(ctx, ddbClient, logger, empSVC, nil)
-	orgSVC.OrganizationTable = os.Getenv("ORGANIZATION_TABLE")
+	orgSVC.OrganizationTable = tables.Organization
 
 	// Org-performance service
 	perfSVC := companylib.CreatePerformanceService(ctx, ddbClient, logger)
-	perfSVC.OrgPerformanceTable = os.Getenv("ORG_PERFORMANCE_TABLE")
-	perfSVC.OrganizationTable = os.Getenv("ORGANIZATION_TABLE")
+	perfSVC.OrgPerformanceTable = tables.OrgPerformance
+	perfSVC.OrganizationTable = tables.Organization
+	perfSVC.PerfHubTable = tables.PerfHub
+
+	// Notification service
+	notifSVC := companylib.CreateNotificationService(ctx, ddbClient, logger, nil)
+	notifSVC.NotificationsTable = tables.Notifications
+	notifSVC.NotificationsTable_DigestIndex = tables.NotificationsDigestIndex
 
 	return &Service{
 		ctx:          ctx,
@@ -85,7 +126,9 @@ func NewService() (*Service, error) {
 		teamsSVC:     teamsSVC,
 		orgSVC:       orgSVC,
 		perfSVC:      perfSVC,
+		notifSVC:     notifSVC,
 		ddb:          ddbClient,
-		perfHubTable: os.Getenv("PERF_HUB_TABLE"),
-	}, nil
+		perfHubTable: tables.PerfHub,
+		feedTable:    tables.TeamFeed,
+	}
 }
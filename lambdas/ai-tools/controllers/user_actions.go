@@ -0,0 +1,360 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+
+	companylib "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/company-lib"
+)
+
+// ==================== User — Actions ====================
+//
+// The write functions below produce the same records as the performance hub routes
+// (tasks_ops.go, goals_ops.go, feedback_ops.go), so anything created through the assistant
+// shows up in the app exactly as if the user had done it there. Task numbers and feedback
+// windows come from company-lib, which the hub uses as well.
+
+// ErrInvalidInput is returned when a write is rejected before anything is stored.
+var ErrInvalidInput = errors.New("invalid input")
+
+// ErrNotFound is returned when a write targets a task or goal that does not exist.
+var ErrNotFound = errors.New("not found")
+
+// NewTaskInput is the caller-supplied part of a new task. Title is required.
+type NewTaskInput struct {
+	Title       string
+	Description string
+	// Priority is "low" | "medium" | "high" | "urgent". Empty = unset.
+	Priority string
+	// Status is "todo" | "in-progress" | "done" | "closed". Empty = "todo".
+	Status    string
+	GoalID    string
+	DueDate   string
+	Tags      []string
+	TimeHours float64
+}
+
+// CreateTask creates a task for (userName, teamID) with the next team-scoped TASK-N identifier.
+// When GoalID is set the goal must exist in the same user-team partition.
+func (s *Service) CreateTask(userName, teamID string, in NewTaskInput) (*LinkedTaskRecord, error) {
+	if teamID == "" {
+		return nil, fmt.Errorf("%w: teamId is required", ErrInvalidInput)
+	}
+	if strings.TrimSpace(in.Title) == "" {
+		return nil, fmt.Errorf("%w: title is required", ErrInvalidInput)
+	}
+	if err := validateTaskFields(in.Priority, in.Status); err != nil {
+		return nil, err
+	}
+	if in.TimeHours < 0 {
+		return nil, fmt.Errorf("%w: timeHours cannot be negative", ErrInvalidInput)
+	}
+	status := in.Status
+	if status == "" {
+		status = TaskStatusTodo
+	}
+
+	if in.GoalID != "" {
+		goal, err := s.GetMyGoal(userName, teamID, in.GoalID)
+		if err != nil {
+			return nil, err
+		}
+		if goal == nil {
+			return nil, fmt.Errorf("%w: goal %s", ErrNotFound, in.GoalID)
+		}
+	}
+	if err := s.orgSVC.EnsureTeamWritable(s.teamsSVC, teamID); err != nil {
+		return nil, err
+	}
+
+	taskID, taskNum, err := s.perfSVC.AllocateTaskID(teamID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to allocate task number: %w", err)
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	rec := LinkedTaskRecord{
+		PK:          buildPK(userName, teamID),
+		SK:          skTaskPrefix + taskID,
+		TaskID:      taskID,
+		TaskNumber:  taskNum,
+		GoalID:      in.GoalID,
+		UserName:    userName,
+		Title:       strings.TrimSpace(in.Title),
+		Description: in.Description,
+		Priority:    in.Priority,
+		Status:      status,
+		Done:        status == TaskStatusDone || status == TaskStatusClosed,
+		Tags:        in.Tags,
+		TimeHours:   in.TimeHours,
+		DueDate:     in.DueDate,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	item, err := attributevalue.MarshalMap(rec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.ddb.PutItem(s.ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.perfHubTable),
+		Item:      item,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to create task: %w", err)
+	}
+	return &rec, nil
+}
+
+// LogTaskTime adds hours to the time already logged on a task and returns the updated task.
+func (s *Service) LogTaskTime(userName, teamID, taskID string, hours float64) (*LinkedTaskRecord, error) {
+	if teamID == "" || taskID == "" {
+		return nil, fmt.Errorf("%w: teamId and taskId are required", ErrInvalidInput)
+	}
+	if hours <= 0 {
+		return nil, fmt.Errorf("%w: hours must be a positive number", ErrInvalidInput)
+	}
+	if err := s.orgSVC.EnsureTeamWritable(s.teamsSVC, teamID); err != nil {
+		return nil, err
+	}
+
+	result, err := s.ddb.UpdateItem(s.ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.perfHubTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: buildPK(userName, teamID)},
+			"SK": &types.AttributeValueMemberS{Value: skTaskPrefix + taskID},
+		},
+		UpdateExpression:    aws.String("SET timeHours = if_not_exists(timeHours, :zero) + :hours, updatedAt = :updatedAt"),
+		ConditionExpression: aws.String("attribute_exists(PK)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":zero":      &types.AttributeValueMemberN{Value: "0"},
+			":hours":     &types.AttributeValueMemberN{Value: fmt.Sprintf("%g", hours)},
+			":updatedAt": &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)},
+		},
+		ReturnValues: types.ReturnValueAllNew,
+	})
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			return nil, fmt.Errorf("%w: task %s", ErrNotFound, taskID)
+		}
+		return nil, fmt.Errorf("failed to log time: %w", err)
+	}
+
+	var rec LinkedTaskRecord
+	if err := attributevalue.UnmarshalMap(result.Attributes, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// AddGoalComment writes a comment on goalOwner's goal. role is "member" when the owner comments on
+// their own goal and "manager" when a team manager comments on a member's goal.
+func (s *Service) AddGoalComment(goalOwner, teamID, goalID, authorUserName, authorName, role, text string) (*GoalCommentRecord, error) {
+	if teamID == "" || goalID == "" {
+		return nil, fmt.Errorf("%w: teamId and goalId are required", ErrInvalidInput)
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("%w: text is required", ErrInvalidInput)
+	}
+	goal, err := s.GetMyGoal(goalOwner, teamID, goalID)
+	if err != nil {
+		return nil, err
+	}
+	if goal == nil {
+		return nil, fmt.Errorf("%w: goal %s", ErrNotFound, goalID)
+	}
+	if err := s.orgSVC.EnsureTeamWritable(s.teamsSVC, teamID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	commentID := uuid.New().String()
+	rec := GoalCommentRecord{
+		PK:             buildPK(goalOwner, teamID),
+		SK:             skGoalPrefix + goalID + skCommentInfix + commentID,
+		CommentID:      commentID,
+		GoalID:         goalID,
+		UserName:       goalOwner,
+		AuthorUserName: authorUserName,
+		Author:         authorName,
+		Initials:       initials(authorName),
+		Role:           role,
+		Text:           strings.TrimSpace(text),
+		Date:           now.Format("2006-01-02"),
+		CreatedAt:      now.Format(time.RFC3339),
+	}
+
+	item, err := attributevalue.MarshalMap(rec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.ddb.PutItem(s.ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.perfHubTable),
+		Item:      item,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to add comment: %w", err)
+	}
+	return &rec, nil
+}
+
+// SendFeedbackRequest asks toUserName, an active member of teamID, for feedback and notifies them.
+// dueDate is YYYY-MM-DD; empty means two weeks from now.
+func (s *Service) SendFeedbackRequest(userName, displayName, teamID, toUserName, message, dueDate string) (*FeedbackRequestRecord, error) {
+	if teamID == "" || toUserName == "" || strings.TrimSpace(message) == "" {
+		return nil, fmt.Errorf("%w: teamId, toUsername and message are required", ErrInvalidInput)
+	}
+	if toUserName == userName {
+		return nil, fmt.Errorf("%w: you cannot request feedback from yourself", ErrInvalidInput)
+	}
+
+	recipient, err := s.teamsSVC.GetTeamMemberDetails(teamID, toUserName)
+	if err != nil || recipient == nil || !recipient.IsActive {
+		return nil, fmt.Errorf("%w: %s is not an active member of this team", ErrInvalidInput, toUserName)
+	}
+
+	createdAt := time.Now().UTC()
+	expiresAt, err := companylib.FeedbackExpiry(createdAt, dueDate)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+	if err := s.orgSVC.EnsureTeamWritable(s.teamsSVC, teamID); err != nil {
+		return nil, err
+	}
+
+	requestID := uuid.New().String()
+	rec := FeedbackRequestRecord{
+		PK:          buildPK(userName, teamID),
+		SK:          skFeedbackReqPrefix + requestID,
+		RequestID:   requestID,
+		TeamID:      teamID,
+		UserName:    userName,
+		FromName:    displayName,
+		To:          toUserName,
+		Message:     strings.TrimSpace(message),
+		Date:        createdAt.Format("2006-01-02"),
+		Status:      "pending",
+		DueDate:     expiresAt.Add(-time.Second).Format("2006-01-02"),
+		ExpiresAt:   expiresAt.Format(time.RFC3339),
+		ReminderKey: companylib.FeedbackReminderKey,
+		RemindAt:    companylib.FeedbackRemindAt(createdAt, expiresAt).Format(time.RFC3339),
+		CreatedAt:   createdAt.Format(time.RFC3339),
+	}
+
+	item, err := attributevalue.MarshalMap(rec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.ddb.PutItem(s.ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.perfHubTable),
+		Item:      item,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to send feedback request: %w", err)
+	}
+
+	actorName := strings.TrimSpace(displayName)
+	if actorName == "" {
+		actorName = userName
+	}
+	if err := s.notifSVC.CreateNotifications([]companylib.Notification{{
+		UserName:      toUserName,
+		Type:          companylib.NotificationTypeFeedbackRequested,
+		ActorUserName: userName,
+		ActorName:     displayName,
+		TeamId:        teamID,
+		Message:       fmt.Sprintf("%s asked for your feedback (due %s)", actorName, rec.DueDate),
+	}}); err != nil {
+		s.logger.Printf("SendFeedbackRequest: failed to notify %s: %v", toUserName, err)
+	}
+	return &rec, nil
+}
+
+// GiveKudos posts kudos for recipientUserName, an active member of teamID, on the team feed and
+// notifies them.
+func (s *Service) GiveKudos(userName, displayName, teamID, recipientUserName, message string) (*KudosPostRecord, error) {
+	if teamID == "" || recipientUserName == "" || strings.TrimSpace(message) == "" {
+		return nil, fmt.Errorf("%w: teamId, recipientUsername and message are required", ErrInvalidInput)
+	}
+	if strings.EqualFold(recipientUserName, userName) {
+		return nil, fmt.Errorf("%w: you cannot give kudos to yourself", ErrInvalidInput)
+	}
+
+	recipient, err := s.teamsSVC.GetTeamMemberDetails(teamID, recipientUserName)
+	if err != nil || recipient == nil || !recipient.IsActive {
+		return nil, fmt.Errorf("%w: %s is not an active member of this team", ErrInvalidInput, recipientUserName)
+	}
+	if err := s.orgSVC.EnsureTeamWritable(s.teamsSVC, teamID); err != nil {
+		return nil, err
+	}
+
+	authorName := strings.TrimSpace(displayName)
+	if authorName == "" {
+		authorName = userName
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	postID := uuid.New().String()
+	rec := KudosPostRecord{
+		PK:           prefixPost + postID,
+		SK:           skPostMetadata,
+		GSI1PK:       buildTeamPK(teamID),
+		GSI1SK:       now + "#" + postID,
+		PostID:       postID,
+		TeamID:       teamID,
+		Type:         postTypeKudos,
+		AuthorUserID: userName,
+		AuthorName:   authorName,
+		Content:      strings.TrimSpace(message),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		Data:         KudosPostData{KudosRecipientUserID: recipient.UserName},
+	}
+	if employee, err := s.empSVC.GetEmployeeDataByUserName(recipient.UserName); err == nil {
+		rec.Data.KudosRecipientName = strings.TrimSpace(employee.FirstName + " " + employee.LastName)
+	}
+
+	item, err := attributevalue.MarshalMap(rec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.ddb.PutItem(s.ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(s.feedTable),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(PK)"),
+	}); err != nil {
+		return nil, fmt.Errorf("failed to post kudos: %w", err)
+	}
+
+	if err := s.notifSVC.CreateNotifications([]companylib.Notification{{
+		UserName:      recipient.UserName,
+		Type:          companylib.NotificationTypeKudos,
+		ActorUserName: userName,
+		ActorName:     displayName,
+		TeamId:        teamID,
+		PostId:        postID,
+		Message:       fmt.Sprintf("%s gave you kudos", authorName),
+	}}); err != nil {
+		s.logger.Printf("GiveKudos: failed to notify %s: %v", recipient.UserName, err)
+	}
+	return &rec, nil
+}
+
+func validateTaskFields(priority, status string) error {
+	switch priority {
+	case "", TaskPriorityLow, TaskPriorityMedium, TaskPriorityHigh, TaskPriorityUrgent:
+	default:
+		return fmt.Errorf("%w: priority must be one of: low, medium, high, urgent", ErrInvalidInput)
+	}
+	switch status {
+	case "", TaskStatusTodo, TaskStatusInProgress, TaskStatusDone, TaskStatusClosed:
+	default:
+		return fmt.Errorf("%w: status must be one of: todo, in-progress, done, closed", ErrInvalidInput)
+	}
+	return nil
+}
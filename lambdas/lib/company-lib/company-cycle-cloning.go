@@ -0,0 +1,365 @@
+package Companylib
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Cloning copies a cycle's quarters, KPIs, OKRs and key results into a new cycle covering another
+// date range. IDs are regenerated and every link between the copies (quarter, parent KPI, OKR) is
+// remapped. Dates move with the cycle: by whole months when both cycles start on the same day of
+// the month, keeping month ends on month ends, and by days otherwise. Recorded values are reset,
+// so KPIs start without a current value, key results at their startValue and everything in its
+// initial status. KPI values, audits, meeting notes, reviews and goal links are not copied.
+//
+// The copy runs as a copy job whose last chunk holds the new cycle itself, so the cycle only
+// appears once all of its contents have been written.
+
+// cycleDateFields are the dates moved along with the cycle
+var cycleDateFields = []string{"startDate", "endDate", "dueDate", "deadline"}
+
+// cloneCycleIdentityFields are regenerated or reset on every copied record
+var cloneCycleIdentityFields = []string{"id", "cycleId", "quarterId", "organizationId", "createdAt", "updatedAt", "status"}
+
+// cycleDateShift moves a date from the source cycle into the new one
+type cycleDateShift struct {
+	months int
+	days   int
+}
+
+func newCycleDateShift(from time.Time, to time.Time) cycleDateShift {
+	if from.Day() == to.Day() || (isMonthEnd(from) && isMonthEnd(to)) {
+		return cycleDateShift{months: (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month())}
+	}
+	return cycleDateShift{days: int(to.Sub(from).Hours() / 24)}
+}
+
+func isMonthEnd(t time.Time) bool {
+	return t.AddDate(0, 0, 1).Month() != t.Month()
+}
+
+func (s cycleDateShift) apply(t time.Time) time.Time {
+	if s.months == 0 {
+		return t.AddDate(0, 0, s.days)
+	}
+	first := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, s.months, 0)
+	last := first.AddDate(0, 1, -1)
+	if isMonthEnd(t) || t.Day() > last.Day() {
+		return last
+	}
+	return first.AddDate(0, 0, t.Day()-1)
+}
+
+// shiftDates moves the date fields of data that hold a date, leaving anything else untouched
+func (s cycleDateShift) shiftDates(data map[string]interface{}) {
+	for _, field := range cycleDateFields {
+		if t, ok := parseTrendDate(toString(data[field])); ok {
+			data[field] = s.apply(t).Format("2006-01-02")
+		}
+	}
+}
+
+func copyData(data map[string]interface{}, drop ...string) map[string]interface{} {
+	copied := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		copied[k] = v
+	}
+	for _, field := range drop {
+		delete(copied, field)
+	}
+	return copied
+}
+
+// optionalBool reads a boolean option, defaulting when it is absent
+func optionalBool(input map[string]interface{}, key string, fallback bool) bool {
+	if v, ok := input[key].(bool); ok {
+		return v
+	}
+	return fallback
+}
+
+// CloneCycle copies a cycle into a new one. The input takes the new cycle's name, startDate and
+// endDate, optionally fiscalYear and description, and includeQuarters, includeKPIs and includeOKRs
+// (all true by default).
+func (svc *PerformanceService) CloneCycle(cycleID string, input map[string]interface{}, createdBy string) (map[string]interface{}, error) {
+	source, err := svc.getRecordByGSI1(perfSKPrefix + "CYCLE#" + cycleID)
+	if err != nil {
+		return nil, err
+	}
+	if source == nil || source.EntityType != perfEntityCycle {
+		return nil, fmt.Errorf("%w: performance cycle not found", ErrCopyJobNotFound)
+	}
+	related, err := svc.queryByOrgPrefix(source.OrganizationId, fmt.Sprintf("%sCYCLE#%s#", perfSKPrefix, cycleID))
+	if err != nil {
+		return nil, err
+	}
+
+	cycle, records, counts, err := svc.planCycleClone(source, related, input)
+	if err != nil {
+		return nil, err
+	}
+	// the cycle goes last so it only shows up once everything in it exists
+	records = append(records, *cycle)
+
+	return svc.startCopyJob(source.OrganizationId, CopyJobTypeCloneCycle, map[string]interface{}{
+		"sourceCycleId": cycleID,
+		"targetCycleId": toString(cycle.Data["id"]),
+	}, records, counts, createdBy)
+}
+
+// planCycleClone builds the new cycle and the records copied into it
+func (svc *PerformanceService) planCycleClone(source *PerformanceRecord, related []PerformanceRecord, input map[string]interface{}) (*PerformanceRecord, []PerformanceRecord, CopyCounts, error) {
+	var counts CopyCounts
+	name := strings.TrimSpace(toString(input["name"]))
+	if name == "" {
+		return nil, nil, counts, fmt.Errorf("%w: name is required", ErrCopyJobInvalid)
+	}
+	start, err := time.Parse("2006-01-02", toString(input["startDate"]))
+	if err != nil {
+		return nil, nil, counts, fmt.Errorf("%w: startDate must be YYYY-MM-DD", ErrCopyJobInvalid)
+	}
+	end, err := time.Parse("2006-01-02", toString(input["endDate"]))
+	if err != nil {
+		return nil, nil, counts, fmt.Errorf("%w: endDate must be YYYY-MM-DD", ErrCopyJobInvalid)
+	}
+	if end.Before(start) {
+		return nil, nil, counts, fmt.Errorf("%w: endDate must not be before startDate", ErrCopyJobInvalid)
+	}
+	sourceStart, ok := parseTrendDate(toString(source.Data["startDate"]))
+	if !ok {
+		return nil, nil, counts, fmt.Errorf("%w: the source cycle has no startDate to clone from", ErrCopyJobInvalid)
+	}
+	shift := newCycleDateShift(sourceStart, start)
+	includeQuarters := optionalBool(input, "includeQuarters", true)
+	includeKPIs := optionalBool(input, "includeKPIs", true)
+	includeOKRs := optionalBool(input, "includeOKRs", true)
+
+	orgID := source.OrganizationId
+	sourceCycleID := toString(source.Data["id"])
+	cycleID := svc.generateID("cycle")
+	now := svc.now()
+
+	cycleData := copyData(currentPerfData(source), cloneCycleIdentityFields...)
+	cycleData["id"] = cycleID
+	cycleData["name"] = name
+	cycleData["startDate"] = start.Format("2006-01-02")
+	cycleData["endDate"] = end.Format("2006-01-02")
+	cycleData["status"] = "PLANNING"
+	cycleData["organizationId"] = orgID
+	cycleData["clonedFromCycleId"] = sourceCycleID
+	cycleData["createdAt"] = now
+	cycleData["updatedAt"] = now
+	delete(cycleData, "fiscalYear")
+	for _, field := range []string{"fiscalYear", "description"} {
+		if value := strings.TrimSpace(toString(input[field])); value != "" {
+			cycleData[field] = value
+		}
+	}
+	cycle := &PerformanceRecord{
+		PK:             orgID,
+		SK:             fmt.Sprintf("%sCYCLE#%s", perfSKPrefix, cycleID),
+		GSI1PK:         fmt.Sprintf("%sCYCLE#%s", perfSKPrefix, cycleID),
+		GSI1SK:         orgID,
+		EntityType:     perfEntityCycle,
+		OrganizationId: orgID,
+		Status:         "PLANNING",
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		SchemaVersion:  perfDataSchemaVersion,
+		Data:           cycleData,
+	}
+
+	records := []PerformanceRecord{}
+	quarterIDs := map[string]string{}
+	kpiIDs := map[string]string{}
+	okrIDs := map[string]string{}
+	var kpis, okrs, keyResults []PerformanceRecord
+
+	for _, rec := range related {
+		switch rec.EntityType {
+		case perfEntityQuarter:
+			if includeQuarters {
+				quarterIDs[rec.QuarterId] = svc.generateID("quarter")
+			}
+		case perfEntityKPI:
+			if includeKPIs {
+				kpiIDs[toString(rec.Data["id"])] = svc.generateID("kpi")
+				kpis = append(kpis, rec)
+			}
+		case perfEntityOKR:
+			if includeOKRs {
+				okrIDs[toString(rec.Data["id"])] = svc.generateID("okr")
+				okrs = append(okrs, rec)
+			}
+		case perfEntityKeyResult:
+			if includeOKRs {
+				keyResults = append(keyResults, rec)
+			}
+		}
+	}
+
+	for _, rec := range related {
+		if rec.EntityType != perfEntityQuarter || !includeQuarters {
+			continue
+		}
+		quarterID := quarterIDs[rec.QuarterId]
+		data := copyData(currentPerfData(&rec), append(cloneCycleIdentityFields, "reviewWindow")...)
+		shift.shiftDates(data)
+		if qStart, ok := parseTrendDate(toString(data["startDate"])); ok && qStart.After(end) {
+			return nil, nil, counts, fmt.Errorf("%w: quarter %s would start after the new cycle ends", ErrCopyJobInvalid, toString(rec.Data["name"]))
+		}
+		if qEnd, ok := parseTrendDate(toString(data["endDate"])); ok && qEnd.After(end) {
+			data["endDate"] = end.Format("2006-01-02")
+		}
+		data["id"] = quarterID
+		data["cycleId"] = cycleID
+		data["organizationId"] = orgID
+		data["status"] = "PLANNING"
+		data["createdAt"] = now
+		data["updatedAt"] = now
+		records = append(records, PerformanceRecord{
+			PK:             orgID,
+			SK:             fmt.Sprintf("%sCYCLE#%s#QUARTER#%s", perfSKPrefix, cycleID, quarterID),
+			GSI1PK:         fmt.Sprintf("%sQUARTER#%s", perfSKPrefix, quarterID),
+			GSI1SK:         fmt.Sprintf("%s#CYCLE#%s", orgID, cycleID),
+			EntityType:     perfEntityQuarter,
+			OrganizationId: orgID,
+			CycleId:        cycleID,
+			QuarterId:      quarterID,
+			Status:         "PLANNING",
+			CreatedAt:      now,
+			UpdatedAt:      now,
+			SchemaVersion:  perfDataSchemaVersion,
+			Data:           data,
+		})
+		counts.Quarters++
+	}
+
+	for _, rec := range kpis {
+		sourceID := toString(rec.Data["id"])
+		kpiID := kpiIDs[sourceID]
+		drop := append(append(append([]string{}, cloneCycleIdentityFields...), "parentKpiId", "currentValue"), append(kpiComputedFields, kpiSystemFields...)...)
+		data := copyData(currentPerfData(&rec), drop...)
+		shift.shiftDates(data)
+		parentID := kpiIDs[rec.ParentId]
+		quarterID := quarterIDs[rec.QuarterId]
+		data["id"] = kpiID
+		data["cycleId"] = cycleID
+		data["organizationId"] = orgID
+		data["status"] = "PLANNING"
+		data["clonedFromKpiId"] = sourceID
+		data["createdAt"] = now
+		data["updatedAt"] = now
+		if parentID != "" {
+			data["parentKpiId"] = parentID
+		}
+		if quarterID != "" {
+			data["quarterId"] = quarterID
+		}
+		records = append(records, PerformanceRecord{
+			PK:             orgID,
+			SK:             fmt.Sprintf("%sCYCLE#%s#KPI#%s", perfSKPrefix, cycleID, kpiID),
+			GSI1PK:         fmt.Sprintf("%sKPI#%s", perfSKPrefix, kpiID),
+			GSI1SK:         fmt.Sprintf("%s#CYCLE#%s", orgID, cycleID),
+			EntityType:     perfEntityKPI,
+			OrganizationId: orgID,
+			CycleId:        cycleID,
+			QuarterId:      quarterID,
+			ParentId:       parentID,
+			Owner:          rec.Owner,
+			Status:         "PLANNING",
+			CreatedAt:      now,
+			UpdatedAt:      now,
+			SchemaVersion:  perfDataSchemaVersion,
+			Data:           data,
+		})
+		counts.KPIs++
+	}
+
+	okrKeyResults := map[string][]map[string]interface{}{}
+	okrQuarters := map[string]string{}
+	for _, rec := range okrs {
+		okrQuarters[toString(rec.Data["id"])] = quarterIDs[rec.QuarterId]
+	}
+	for _, rec := range keyResults {
+		okrID, ok := okrIDs[rec.ParentId]
+		if !ok {
+			continue
+		}
+		krID := svc.generateID("kr")
+		data := copyData(currentPerfData(&rec), "id", "okrId", "createdAt", "updatedAt", "status", "currentValue", "progress", "confidenceScore")
+		shift.shiftDates(data)
+		if err := normalizeKeyResult(data); err != nil {
+			return nil, nil, counts, fmt.Errorf("%w: key result %s: %v", ErrCopyJobInvalid, toString(rec.Data["id"]), err)
+		}
+		data["id"] = krID
+		data["okrId"] = okrID
+		data["status"] = "ON_TRACK"
+		data["createdAt"] = now
+		data["updatedAt"] = now
+		quarterID := okrQuarters[rec.ParentId]
+		records = append(records, PerformanceRecord{
+			PK:             orgID,
+			SK:             fmt.Sprintf("%sCYCLE#%s#OKR#%s#KR#%s", perfSKPrefix, cycleID, okrID, krID),
+			GSI1PK:         fmt.Sprintf("%sKEYRESULT#%s", perfSKPrefix, krID),
+			GSI1SK:         fmt.Sprintf("%s#OKR#%s", orgID, okrID),
+			EntityType:     perfEntityKeyResult,
+			OrganizationId: orgID,
+			CycleId:        cycleID,
+			QuarterId:      quarterID,
+			ParentId:       okrID,
+			Status:         "ON_TRACK",
+			CreatedAt:      now,
+			UpdatedAt:      now,
+			SchemaVersion:  perfDataSchemaVersion,
+			Data:           data,
+		})
+		okrKeyResults[okrID] = append(okrKeyResults[okrID], data)
+		counts.KeyResults++
+	}
+
+	for _, rec := range okrs {
+		sourceID := toString(rec.Data["id"])
+		okrID := okrIDs[sourceID]
+		drop := append(append([]string{}, cloneCycleIdentityFields...), "keyResults", "confidenceScore")
+		data := copyData(currentPerfData(&rec), append(drop, okrComputedFields...)...)
+		shift.shiftDates(data)
+		quarterID := okrQuarters[sourceID]
+		krs := okrKeyResults[okrID]
+		progress := rollUpOKRProgress(krs, nil)
+		data["id"] = okrID
+		data["cycleId"] = cycleID
+		data["organizationId"] = orgID
+		data["status"] = "DRAFT"
+		data["clonedFromOkrId"] = sourceID
+		data["keyResults"] = krs
+		data["progress"] = progress.Progress
+		data["keyResultCount"] = len(krs)
+		data["progressUpdatedAt"] = now
+		data["createdAt"] = now
+		data["updatedAt"] = now
+		if quarterID != "" {
+			data["quarterId"] = quarterID
+		}
+		records = append(records, PerformanceRecord{
+			PK:             orgID,
+			SK:             fmt.Sprintf("%sCYCLE#%s#OKR#%s", perfSKPrefix, cycleID, okrID),
+			GSI1PK:         fmt.Sprintf("%sOKR#%s", perfSKPrefix, okrID),
+			GSI1SK:         fmt.Sprintf("%s#CYCLE#%s", orgID, cycleID),
+			EntityType:     perfEntityOKR,
+			OrganizationId: orgID,
+			CycleId:        cycleID,
+			QuarterId:      quarterID,
+			Owner:          rec.Owner,
+			Status:         "DRAFT",
+			CreatedAt:      now,
+			UpdatedAt:      now,
+			SchemaVersion:  perfDataSchemaVersion,
+			Data:           data,
+		})
+		counts.OKRs++
+	}
+
+	return cycle, records, counts, nil
+}
@@ -0,0 +1,129 @@
+package Companylib
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCycleDateShift(t *testing.T) {
+	day := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+
+	t.Run("It should move by months and keep month ends", func(t *testing.T) {
+		shift := newCycleDateShift(day("2025-01-01"), day("2026-01-01"))
+
+		assert.Equal(t, day("2026-03-31"), shift.apply(day("2025-03-31")))
+		assert.Equal(t, day("2026-06-30"), shift.apply(day("2025-06-30")))
+		assert.Equal(t, day("2026-02-15"), shift.apply(day("2025-02-15")))
+	})
+
+	t.Run("It should clamp to the end of a shorter month", func(t *testing.T) {
+		shift := newCycleDateShift(day("2025-01-31"), day("2025-02-28"))
+
+		assert.Equal(t, 1, shift.months)
+		assert.Equal(t, day("2025-02-28"), shift.apply(day("2025-01-30")))
+		assert.Equal(t, day("2025-04-30"), shift.apply(day("2025-03-31")))
+	})
+
+	t.Run("It should move by days when the cycles start on different days", func(t *testing.T) {
+		shift := newCycleDateShift(day("2025-01-06"), day("2026-01-05"))
+
+		assert.Equal(t, day("2026-03-30"), shift.apply(day("2025-03-31")))
+	})
+}
+
+func TestPlanCycleClone(t *testing.T) {
+	svc := newOKRTestService(nil)
+	source := &PerformanceRecord{
+		PK: "ORG#org-1", SK: "PERF#CYCLE#c-1", EntityType: perfEntityCycle, OrganizationId: "ORG#org-1", Status: "STARTED",
+		Data: map[string]interface{}{"id": "c-1", "name": "FY25", "startDate": "2025-01-01", "endDate": "2025-12-31", "fiscalYear": "2025", "status": "STARTED"},
+	}
+	related := []PerformanceRecord{
+		{EntityType: perfEntityQuarter, CycleId: "c-1", QuarterId: "q-1", Status: "STARTED", Data: map[string]interface{}{
+			"id": "q-1", "name": "Q1", "startDate": "2025-01-01", "endDate": "2025-03-31", "reviewWindow": map[string]interface{}{"opensAt": "2025-03-20"},
+		}},
+		{EntityType: perfEntityKPI, CycleId: "c-1", QuarterId: "q-1", Owner: "ann@example.com", Status: "STARTED", Data: map[string]interface{}{
+			"id": "kpi-1", "name": "Revenue", "owner": "ann@example.com", "targetValue": 100.0, "currentValue": 40.0, "ragStatus": "RED", "lastValueDate": "2025-02-01",
+		}},
+		{EntityType: perfEntityKPI, CycleId: "c-1", QuarterId: "q-1", ParentId: "kpi-1", Owner: "bob@example.com", Data: map[string]interface{}{
+			"id": "kpi-2", "name": "EMEA revenue", "owner": "bob@example.com", "targetValue": 40.0, "parentKpiId": "kpi-1",
+		}},
+		{EntityType: perfEntityKPIValue, CycleId: "c-1", ParentId: "kpi-1", Data: map[string]interface{}{"id": "v-1", "value": 40.0}},
+		{EntityType: perfEntityOKR, CycleId: "c-1", Status: "ACTIVE", Data: map[string]interface{}{
+			"id": "okr-1", "name": "Grow", "progress": 50.0, "health": OKRHealthBehind, "confidenceScore": 3.0,
+		}},
+		{EntityType: perfEntityKeyResult, CycleId: "c-1", ParentId: "okr-1", Status: "AT_RISK", Data: map[string]interface{}{
+			"id": "kr-1", "okrId": "okr-1", "type": KeyResultTypeIncrease, "startValue": 10.0, "targetValue": 20.0, "currentValue": 15.0, "progress": 50.0, "dueDate": "2025-06-30",
+		}},
+	}
+
+	t.Run("It should copy and remap everything with values reset", func(t *testing.T) {
+		cycle, records, counts, err := svc.planCycleClone(source, related, map[string]interface{}{
+			"name": "FY26", "startDate": "2026-01-01", "endDate": "2026-12-31",
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, CopyCounts{Quarters: 1, KPIs: 2, OKRs: 1, KeyResults: 1}, counts)
+		assert.Equal(t, "FY26", cycle.Data["name"])
+		assert.Equal(t, "PLANNING", cycle.Status)
+		assert.Equal(t, "c-1", cycle.Data["clonedFromCycleId"])
+		assert.NotContains(t, cycle.Data, "fiscalYear")
+		newCycleID := toString(cycle.Data["id"])
+
+		byType := map[string][]PerformanceRecord{}
+		for _, rec := range records {
+			assert.Equal(t, newCycleID, rec.CycleId)
+			byType[rec.EntityType] = append(byType[rec.EntityType], rec)
+		}
+		assert.Empty(t, byType[perfEntityKPIValue])
+
+		quarter := byType[perfEntityQuarter][0]
+		assert.Equal(t, "2026-03-31", quarter.Data["endDate"])
+		assert.NotContains(t, quarter.Data, "reviewWindow")
+		assert.Equal(t, "PLANNING", quarter.Status)
+
+		parent, child := byType[perfEntityKPI][0], byType[perfEntityKPI][1]
+		assert.NotEqual(t, "kpi-1", parent.Data["id"])
+		assert.Equal(t, quarter.QuarterId, parent.QuarterId)
+		assert.Equal(t, parent.Data["id"], child.ParentId)
+		assert.Equal(t, parent.Data["id"], child.Data["parentKpiId"])
+		for _, field := range []string{"currentValue", "ragStatus", "lastValueDate"} {
+			assert.NotContains(t, parent.Data, field)
+		}
+
+		okr, kr := byType[perfEntityOKR][0], byType[perfEntityKeyResult][0]
+		assert.Equal(t, okr.Data["id"], kr.ParentId)
+		assert.Equal(t, 10.0, kr.Data["currentValue"])
+		assert.Equal(t, 0.0, kr.Data["progress"])
+		assert.Equal(t, "2026-06-30", kr.Data["dueDate"])
+		assert.Equal(t, "ON_TRACK", kr.Status)
+		assert.Equal(t, "DRAFT", okr.Status)
+		assert.Equal(t, 0.0, okr.Data["progress"])
+		assert.NotContains(t, okr.Data, "confidenceScore")
+	})
+
+	t.Run("It should leave out what is excluded", func(t *testing.T) {
+		_, records, counts, err := svc.planCycleClone(source, related, map[string]interface{}{
+			"name": "FY26", "startDate": "2026-01-01", "endDate": "2026-12-31", "includeQuarters": false, "includeOKRs": false,
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, CopyCounts{KPIs: 2}, counts)
+		assert.Empty(t, records[0].QuarterId)
+	})
+
+	t.Run("It should reject quarters that fall outside the new range", func(t *testing.T) {
+		q2 := PerformanceRecord{EntityType: perfEntityQuarter, CycleId: "c-1", QuarterId: "q-2", Data: map[string]interface{}{
+			"id": "q-2", "name": "Q2", "startDate": "2025-04-01", "endDate": "2025-06-30",
+		}}
+		_, _, _, err := svc.planCycleClone(source, append(related, q2), map[string]interface{}{
+			"name": "Short", "startDate": "2025-12-01", "endDate": "2025-12-31",
+		})
+
+		assert.ErrorIs(t, err, ErrCopyJobInvalid)
+	})
+}
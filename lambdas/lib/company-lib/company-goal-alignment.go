@@ -0,0 +1,461 @@
+package Companylib
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The goal alignment of a cycle is a tree per org goal (KPI or OKR, sub-KPIs under their parent):
+//
+//	org goal → tagged team → ladder-up → member goal → linked task
+//
+// A ladder-up sits under the team named by its teamId, or directly under the goal. A member goal
+// sits under the ladder-up whose userGoalId names it, otherwise under its own team. A team that has
+// aligned goals or ladder-ups but was never tagged to the org goal appears with untagged set.
+//
+// progress is a node's own progress: the KPI evaluation or OKR roll-up, a ladder-up's progress, a
+// member goal's reported progress, and 100 or 0 for a done or open task. rolledUpProgress is the
+// mean rolledUpProgress of the children; member goals and childless nodes keep their own progress.
+//
+// Member goals without an orgGoalId, or naming an org goal that no longer exists, are orphans.
+
+const (
+	AlignmentNodeKPI        = "KPI"
+	AlignmentNodeOKR        = "OKR"
+	AlignmentNodeTeam       = "TEAM"
+	AlignmentNodeLadderUp   = "LADDER_UP"
+	AlignmentNodeMemberGoal = "MEMBER_GOAL"
+	AlignmentNodeTask       = "TASK"
+
+	AlignmentOrphanNotAligned      = "NOT_ALIGNED"
+	AlignmentOrphanOrgGoalNotFound = "ORG_GOAL_NOT_FOUND"
+)
+
+// AlignmentMemberGoal is a member's goal from UserPerformanceHubTable with its linked tasks
+type AlignmentMemberGoal struct {
+	GoalId    string
+	UserName  string
+	TeamId    string
+	Title     string
+	Status    string
+	DueDate   string
+	OrgGoalId string
+	Progress  float64
+	Tasks     []AlignmentTask
+}
+
+// AlignmentTask is a task linked to a member goal
+type AlignmentTask struct {
+	TaskId string
+	Title  string
+	Status string
+	Done   bool
+}
+
+// AlignmentNode is one level of the alignment tree
+type AlignmentNode struct {
+	Id               string           `json:"id"`
+	Type             string           `json:"type"`
+	Title            string           `json:"title"`
+	Owner            string           `json:"owner,omitempty"`
+	TeamId           string           `json:"teamId,omitempty"`
+	Status           string           `json:"status,omitempty"`
+	Progress         *float64         `json:"progress"`
+	RolledUpProgress *float64         `json:"rolledUpProgress"`
+	Untagged         bool             `json:"untagged,omitempty"`
+	OrgGoalId        string           `json:"orgGoalId,omitempty"`
+	OrphanReason     string           `json:"orphanReason,omitempty"`
+	Children         []*AlignmentNode `json:"children"`
+}
+
+// GoalAlignmentSummary counts the nodes of the alignment tree
+type GoalAlignmentSummary struct {
+	OrgGoals            int `json:"orgGoals"`
+	Teams               int `json:"teams"`
+	LadderUps           int `json:"ladderUps"`
+	AlignedMemberGoals  int `json:"alignedMemberGoals"`
+	OrphanedMemberGoals int `json:"orphanedMemberGoals"`
+	Tasks               int `json:"tasks"`
+	// AlignmentRate is the share of member goals aligned to an org goal, 0-100
+	AlignmentRate float64 `json:"alignmentRate"`
+}
+
+// GoalAlignment is the alignment tree of a performance cycle
+type GoalAlignment struct {
+	CycleId        string               `json:"cycleId"`
+	OrganizationId string               `json:"organizationId"`
+	GeneratedAt    string               `json:"generatedAt"`
+	Summary        GoalAlignmentSummary `json:"summary"`
+	Tree           []*AlignmentNode     `json:"tree"`
+	Orphans        []*AlignmentNode     `json:"orphans"`
+}
+
+// GetGoalAlignment builds the alignment tree of the cycle's org goals. memberGoals are the goals of
+// the organization's members and teamNames maps team IDs to display names.
+func (svc *PerformanceService) GetGoalAlignment(cycleID string, memberGoals []AlignmentMemberGoal, teamNames map[string]string) (*GoalAlignment, error) {
+	cycle, err := svc.getRecordByGSI1(perfSKPrefix + "CYCLE#" + cycleID)
+	if err != nil {
+		return nil, err
+	}
+	if cycle == nil {
+		return nil, fmt.Errorf("performance cycle not found")
+	}
+	related, err := svc.queryByOrgPrefix(cycle.OrganizationId, fmt.Sprintf("%sCYCLE#%s#", perfSKPrefix, cycleID))
+	if err != nil {
+		return nil, err
+	}
+	links, err := svc.queryByOrgPrefix(cycle.OrganizationId, perfSKPrefix+"GOAL#")
+	if err != nil {
+		return nil, err
+	}
+
+	// Member goals aligned to another cycle's goals are not orphans
+	inCycle := map[string]bool{}
+	for _, r := range related {
+		if r.EntityType == perfEntityKPI || r.EntityType == perfEntityOKR {
+			inCycle[toString(r.Data["id"])] = true
+		}
+	}
+	otherCycles := map[string]bool{}
+	for _, goal := range memberGoals {
+		if goal.OrgGoalId == "" || inCycle[goal.OrgGoalId] {
+			continue
+		}
+		if _, seen := otherCycles[goal.OrgGoalId]; seen {
+			continue
+		}
+		exists := false
+		for _, kind := range []string{"KPI#", "OKR#"} {
+			rec, err := svc.getRecordByGSI1(perfSKPrefix + kind + goal.OrgGoalId)
+			if err != nil {
+				return nil, err
+			}
+			exists = exists || rec != nil
+		}
+		otherCycles[goal.OrgGoalId] = exists
+	}
+
+	return buildGoalAlignment(cycle, related, links, memberGoals, teamNames, otherCycles, time.Now().UTC()), nil
+}
+
+func buildGoalAlignment(cycle *PerformanceRecord, related []PerformanceRecord, links []PerformanceRecord, memberGoals []AlignmentMemberGoal, teamNames map[string]string, otherCycles map[string]bool, now time.Time) *GoalAlignment {
+	alignment := &GoalAlignment{
+		CycleId:        toString(cycle.Data["id"]),
+		OrganizationId: cycle.OrganizationId,
+		GeneratedAt:    now.Format(time.RFC3339),
+		Tree:           []*AlignmentNode{},
+		Orphans:        []*AlignmentNode{},
+	}
+
+	teamTitle := func(teamID string, data map[string]interface{}) string {
+		if name := teamNames[teamID]; name != "" {
+			return name
+		}
+		if name := toString(data["teamName"]); name != "" {
+			return name
+		}
+		return teamID
+	}
+
+	// org goals, sub-KPIs under their parent
+	goals := map[string]*AlignmentNode{}
+	parents := map[string]string{}
+	order := []string{}
+	for i := range related {
+		r := &related[i]
+		var node *AlignmentNode
+		switch r.EntityType {
+		case perfEntityKPI:
+			node = &AlignmentNode{Type: AlignmentNodeKPI, Title: toString(r.Data["name"]), Owner: toString(r.Data["owner"])}
+			if evaluation := evaluateKPI(r.Data); evaluation.RagStatus != KPIRagNoData {
+				node.Progress = &evaluation.Progress
+			}
+			parents[toString(r.Data["id"])] = toString(r.Data["parentKpiId"])
+		case perfEntityOKR:
+			node = &AlignmentNode{Type: AlignmentNodeOKR, Title: toString(r.Data["objective"]), Owner: toString(r.Data["objectiveOwner"])}
+			if hasValue(r.Data, "progress") {
+				progress := toFloat(r.Data["progress"])
+				node.Progress = &progress
+			}
+		default:
+			continue
+		}
+		node.Id = toString(r.Data["id"])
+		node.Status = toString(r.Data["status"])
+		node.Children = []*AlignmentNode{}
+		goals[node.Id] = node
+		order = append(order, node.Id)
+	}
+	for _, id := range order {
+		if parent := goals[parents[id]]; parent != nil && parents[id] != id {
+			parent.Children = append(parent.Children, goals[id])
+			continue
+		}
+		alignment.Tree = append(alignment.Tree, goals[id])
+	}
+
+	teams := map[string]*AlignmentNode{}
+	teamNode := func(goalID string, teamID string, data map[string]interface{}, tagged bool) *AlignmentNode {
+		key := goalID + "#" + teamID
+		if node := teams[key]; node != nil {
+			if tagged {
+				node.Untagged = false
+			}
+			return node
+		}
+		node := &AlignmentNode{Id: teamID, Type: AlignmentNodeTeam, Title: teamTitle(teamID, data), TeamId: teamID, Untagged: !tagged, Children: []*AlignmentNode{}}
+		teams[key] = node
+		goals[goalID].Children = append(goals[goalID].Children, node)
+		return node
+	}
+
+	for i := range links {
+		if links[i].EntityType != perfEntityGoalTeam {
+			continue
+		}
+		goalID, teamID := toString(links[i].Data["goalId"]), toString(links[i].Data["teamId"])
+		if goals[goalID] != nil && teamID != "" {
+			teamNode(goalID, teamID, links[i].Data, true)
+		}
+	}
+
+	ladderUps := map[string]*AlignmentNode{} // by the member goal they collect
+	for i := range links {
+		r := &links[i]
+		if r.EntityType != perfEntityLadderUp {
+			continue
+		}
+		goalID := r.ParentId
+		if goalID == "" {
+			goalID = toString(r.Data["goalId"])
+		}
+		goal := goals[goalID]
+		if goal == nil {
+			continue
+		}
+		title := toString(r.Data["title"])
+		if title == "" {
+			title = toString(r.Data["name"])
+		}
+		owner := toString(r.Data["submittedBy"])
+		if owner == "" {
+			owner = r.Owner
+		}
+		node := &AlignmentNode{
+			Id: toString(r.Data["id"]), Type: AlignmentNodeLadderUp, Title: title, Owner: owner,
+			TeamId: toString(r.Data["teamId"]), Status: toString(r.Data["status"]), Children: []*AlignmentNode{},
+		}
+		if hasValue(r.Data, "progress") {
+			progress := toFloat(r.Data["progress"])
+			node.Progress = &progress
+		}
+		if node.TeamId != "" {
+			team := teamNode(goalID, node.TeamId, r.Data, false)
+			team.Children = append(team.Children, node)
+		} else {
+			goal.Children = append(goal.Children, node)
+		}
+		alignment.Summary.LadderUps++
+		if userGoalID := toString(r.Data["userGoalId"]); userGoalID != "" {
+			ladderUps[goalID+"#"+userGoalID] = node
+		}
+	}
+
+	for _, goal := range memberGoals {
+		progress := goal.Progress
+		node := &AlignmentNode{
+			Id: goal.GoalId, Type: AlignmentNodeMemberGoal, Title: goal.Title, Owner: goal.UserName,
+			TeamId: goal.TeamId, Status: goal.Status, Progress: &progress, Children: []*AlignmentNode{},
+		}
+		for _, task := range goal.Tasks {
+			done := 0.0
+			if task.Done || strings.EqualFold(task.Status, perfTaskStatusDone) {
+				done = 100
+			}
+			node.Children = append(node.Children, &AlignmentNode{
+				Id: task.TaskId, Type: AlignmentNodeTask, Title: task.Title, Owner: goal.UserName,
+				TeamId: goal.TeamId, Status: task.Status, Progress: &done, Children: []*AlignmentNode{},
+			})
+		}
+
+		switch {
+		case goal.OrgGoalId == "":
+			node.OrphanReason = AlignmentOrphanNotAligned
+		case goals[goal.OrgGoalId] != nil:
+			alignment.Summary.AlignedMemberGoals++
+			alignment.Summary.Tasks += len(goal.Tasks)
+			if ladderUp := ladderUps[goal.OrgGoalId+"#"+goal.GoalId]; ladderUp != nil {
+				ladderUp.Children = append(ladderUp.Children, node)
+			} else if goal.TeamId != "" {
+				team := teamNode(goal.OrgGoalId, goal.TeamId, nil, false)
+				team.Children = append(team.Children, node)
+			} else {
+				goals[goal.OrgGoalId].Children = append(goals[goal.OrgGoalId].Children, node)
+			}
+			continue
+		case otherCycles[goal.OrgGoalId]:
+			continue
+		default:
+			node.OrgGoalId = goal.OrgGoalId
+			node.OrphanReason = AlignmentOrphanOrgGoalNotFound
+		}
+		rollUpAlignment(node)
+		alignment.Orphans = append(alignment.Orphans, node)
+	}
+
+	alignment.Summary.OrgGoals = len(goals)
+	alignment.Summary.Teams = len(teams)
+	alignment.Summary.OrphanedMemberGoals = len(alignment.Orphans)
+	if total := alignment.Summary.AlignedMemberGoals + alignment.Summary.OrphanedMemberGoals; total > 0 {
+		alignment.Summary.AlignmentRate = roundProgress(float64(alignment.Summary.AlignedMemberGoals) / float64(total) * 100)
+	}
+
+	sortAlignmentNodes(alignment.Tree)
+	sortAlignmentNodes(alignment.Orphans)
+	for _, node := range alignment.Tree {
+		rollUpAlignment(node)
+	}
+	return alignment
+}
+
+// rollUpAlignment sets the rolledUpProgress of node and everything below it
+func rollUpAlignment(node *AlignmentNode) *float64 {
+	sum, count := 0.0, 0
+	for _, child := range node.Children {
+		if progress := rollUpAlignment(child); progress != nil {
+			sum += *progress
+			count++
+		}
+	}
+	switch {
+	case node.Type == AlignmentNodeMemberGoal || count == 0:
+		node.RolledUpProgress = node.Progress
+	default:
+		rolledUp := roundProgress(sum / float64(count))
+		node.RolledUpProgress = &rolledUp
+	}
+	return node.RolledUpProgress
+}
+
+var alignmentNodeRank = map[string]int{
+	AlignmentNodeKPI: 0, AlignmentNodeOKR: 1, AlignmentNodeTeam: 2, AlignmentNodeLadderUp: 3, AlignmentNodeMemberGoal: 4, AlignmentNodeTask: 5,
+}
+
+func sortAlignmentNodes(nodes []*AlignmentNode) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if nodes[i].Type != nodes[j].Type {
+			return alignmentNodeRank[nodes[i].Type] < alignmentNodeRank[nodes[j].Type]
+		}
+		if nodes[i].Owner != nodes[j].Owner && nodes[i].Type == AlignmentNodeMemberGoal {
+			return nodes[i].Owner < nodes[j].Owner
+		}
+		return nodes[i].Title < nodes[j].Title
+	})
+	for _, node := range nodes {
+		sortAlignmentNodes(node.Children)
+	}
+}
+
+// GoalAlignmentGraph flattens the alignment into nodes and parent → child edges for strategy map
+// tools. Node keys are type#id, as a team appears once under every goal it is aligned to.
+func GoalAlignmentGraph(alignment *GoalAlignment) map[string]interface{} {
+	nodes := []map[string]interface{}{}
+	edges := []map[string]interface{}{}
+	seen := map[string]bool{}
+
+	var walk func(node *AlignmentNode, parentKey string)
+	walk = func(node *AlignmentNode, parentKey string) {
+		key := node.Type + "#" + node.Id
+		if !seen[key] {
+			seen[key] = true
+			entry := map[string]interface{}{
+				"key":              key,
+				"id":               node.Id,
+				"type":             node.Type,
+				"title":            node.Title,
+				"owner":            node.Owner,
+				"status":           node.Status,
+				"progress":         node.Progress,
+				"rolledUpProgress": node.RolledUpProgress,
+			}
+			if node.OrphanReason != "" {
+				entry["orphanReason"] = node.OrphanReason
+			}
+			nodes = append(nodes, entry)
+		}
+		if parentKey != "" {
+			edges = append(edges, map[string]interface{}{"from": parentKey, "to": key})
+		}
+		for _, child := range node.Children {
+			walk(child, key)
+		}
+	}
+	for _, node := range alignment.Tree {
+		walk(node, "")
+	}
+	for _, node := range alignment.Orphans {
+		walk(node, "")
+	}
+
+	return map[string]interface{}{
+		"cycleId": alignment.CycleId,
+		"nodes":   nodes,
+		"edges":   edges,
+	}
+}
+
+// GoalAlignmentCSV writes one row per node of the alignment, parents before their children
+func GoalAlignmentCSV(alignment *GoalAlignment) (string, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write([]string{"level", "type", "id", "title", "owner", "teamId", "status", "progress", "rolledUpProgress", "parentId", "orphanReason"}); err != nil {
+		return "", err
+	}
+
+	formatProgress := func(progress *float64) string {
+		if progress == nil {
+			return ""
+		}
+		return fmt.Sprintf("%g", *progress)
+	}
+	var walk func(node *AlignmentNode, level int, parentID string) error
+	walk = func(node *AlignmentNode, level int, parentID string) error {
+		err := w.Write([]string{
+			fmt.Sprintf("%d", level), node.Type, csvText(node.Id), csvText(node.Title), csvText(node.Owner), csvText(node.TeamId),
+			csvText(node.Status), formatProgress(node.Progress), formatProgress(node.RolledUpProgress), csvText(parentID), node.OrphanReason,
+		})
+		if err != nil {
+			return err
+		}
+		for _, child := range node.Children {
+			if err := walk(child, level+1, node.Id); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, node := range append(append([]*AlignmentNode{}, alignment.Tree...), alignment.Orphans...) {
+		if err := walk(node, 0, ""); err != nil {
+			return "", err
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// csvText stops spreadsheets from evaluating user-entered text as a formula by prefixing a quote
+// to cells that start with a formula character
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
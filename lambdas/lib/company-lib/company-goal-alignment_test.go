@@ -0,0 +1,111 @@
+package Companylib
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildGoalAlignment(t *testing.T) {
+	cycle := PerformanceRecord{
+		GSI1PK: "PERF#CYCLE#c-1", EntityType: perfEntityCycle, OrganizationId: "ORG#org-1",
+		Data: map[string]interface{}{"id": "c-1"},
+	}
+	related := []PerformanceRecord{
+		{EntityType: perfEntityKPI, CycleId: "c-1", Data: map[string]interface{}{
+			"id": "kpi-rev", "name": "Revenue", "owner": "cfo@example.com", "targetValue": 100.0, "currentValue": 50.0,
+		}},
+		{EntityType: perfEntityKPI, CycleId: "c-1", ParentId: "kpi-rev", Data: map[string]interface{}{
+			"id": "kpi-sub", "name": "Renewals", "parentKpiId": "kpi-rev", "targetValue": 10.0,
+		}},
+		{EntityType: perfEntityOKR, CycleId: "c-1", Data: map[string]interface{}{"id": "okr-1", "objective": "Improve retention", "progress": 30.0}},
+		kpiValueRecord("kpi-rev", "2027-01-10", 50),
+	}
+	links := []PerformanceRecord{
+		{EntityType: perfEntityGoalTeam, ParentId: "kpi-rev", Data: map[string]interface{}{"goalId": "kpi-rev", "teamId": "t-sales"}},
+		{EntityType: perfEntityLadderUp, ParentId: "kpi-rev", Data: map[string]interface{}{
+			"id": "lu-1", "title": "Close 20 renewals", "teamId": "t-sales", "userGoalId": "g-ann", "status": "PENDING",
+		}},
+	}
+	memberGoals := []AlignmentMemberGoal{
+		{GoalId: "g-ann", UserName: "ann@example.com", TeamId: "t-sales", Title: "Renewals", OrgGoalId: "kpi-rev", Progress: 80, Tasks: []AlignmentTask{
+			{TaskId: "TASK-101", Title: "Call accounts", Done: true},
+			{TaskId: "TASK-102", Title: "Send quotes", Status: "todo"},
+		}},
+		{GoalId: "g-bob", UserName: "bob@example.com", TeamId: "t-ops", Title: "Billing fixes", OrgGoalId: "kpi-rev", Progress: 40},
+		{GoalId: "g-cat", UserName: "cat@example.com", TeamId: "t-ops", Title: "Learn Go", Progress: 10},
+		{GoalId: "g-dan", UserName: "dan@example.com", TeamId: "t-ops", Title: "Old goal", OrgGoalId: "kpi-gone"},
+		{GoalId: "g-eve", UserName: "eve@example.com", TeamId: "t-ops", Title: "Last year", OrgGoalId: "kpi-2026"},
+	}
+	teamNames := map[string]string{"t-sales": "Sales", "t-ops": "Ops"}
+	otherCycles := map[string]bool{"kpi-gone": false, "kpi-2026": true}
+
+	alignment := buildGoalAlignment(&cycle, related, links, memberGoals, teamNames, otherCycles, time.Date(2027, 2, 1, 0, 0, 0, 0, time.UTC))
+
+	assert.Equal(t, GoalAlignmentSummary{
+		OrgGoals: 3, Teams: 2, LadderUps: 1, AlignedMemberGoals: 2, OrphanedMemberGoals: 2, Tasks: 2, AlignmentRate: 50,
+	}, alignment.Summary)
+
+	assert.Len(t, alignment.Tree, 2)
+	revenue, okr := alignment.Tree[0], alignment.Tree[1]
+	assert.Equal(t, "kpi-rev", revenue.Id)
+	assert.Equal(t, 50.0, *revenue.Progress)
+	assert.Equal(t, 60.0, *revenue.RolledUpProgress)
+	assert.Equal(t, 30.0, *okr.RolledUpProgress)
+
+	assert.Len(t, revenue.Children, 3)
+	renewals, ops, sales := revenue.Children[0], revenue.Children[1], revenue.Children[2]
+	assert.Equal(t, "kpi-sub", renewals.Id)
+	assert.Nil(t, renewals.RolledUpProgress)
+
+	assert.Equal(t, "Ops", ops.Title)
+	assert.True(t, ops.Untagged)
+	assert.Equal(t, "g-bob", ops.Children[0].Id)
+
+	assert.Equal(t, "Sales", sales.Title)
+	assert.False(t, sales.Untagged)
+	ladderUp := sales.Children[0]
+	assert.Equal(t, AlignmentNodeLadderUp, ladderUp.Type)
+	assert.Equal(t, 80.0, *ladderUp.RolledUpProgress)
+	ann := ladderUp.Children[0]
+	assert.Equal(t, "g-ann", ann.Id)
+	assert.Equal(t, 80.0, *ann.RolledUpProgress)
+	assert.Equal(t, 100.0, *ann.Children[0].Progress)
+	assert.Equal(t, 0.0, *ann.Children[1].Progress)
+
+	assert.Len(t, alignment.Orphans, 2)
+	assert.Equal(t, AlignmentOrphanNotAligned, alignment.Orphans[0].OrphanReason)
+	assert.Equal(t, AlignmentOrphanOrgGoalNotFound, alignment.Orphans[1].OrphanReason)
+	assert.Equal(t, "kpi-gone", alignment.Orphans[1].OrgGoalId)
+
+	t.Run("It should export a strategy map graph", func(t *testing.T) {
+		graph := GoalAlignmentGraph(alignment)
+		assert.Len(t, graph["nodes"], 12)
+		edges := graph["edges"].([]map[string]interface{})
+		assert.Len(t, edges, 8)
+		assert.Contains(t, edges, map[string]interface{}{"from": "TEAM#t-sales", "to": "LADDER_UP#lu-1"})
+	})
+
+	t.Run("It should export CSV rows parents first", func(t *testing.T) {
+		body, err := GoalAlignmentCSV(alignment)
+		assert.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(body), "\n")
+		assert.Len(t, lines, 13)
+		assert.Equal(t, "level,type,id,title,owner,teamId,status,progress,rolledUpProgress,parentId,orphanReason", lines[0])
+		assert.Contains(t, lines, "2,LADDER_UP,lu-1,Close 20 renewals,,t-sales,PENDING,,80,t-sales,")
+		assert.Equal(t, "0,MEMBER_GOAL,g-cat,Learn Go,cat@example.com,t-ops,,10,10,,NOT_ALIGNED", lines[11])
+	})
+
+	t.Run("It should escape cells that start with a formula character", func(t *testing.T) {
+		body, err := GoalAlignmentCSV(&GoalAlignment{Orphans: []*AlignmentNode{
+			{Type: "MEMBER_GOAL", Id: "g-1", Title: "=HYPERLINK(\"http://x\")", Owner: "@bob", OrphanReason: AlignmentOrphanNotAligned},
+			{Type: "MEMBER_GOAL", Id: "g-2", Title: "-10% churn", Owner: "+bob"},
+		}})
+		assert.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(body), "\n")
+		assert.Equal(t, `0,MEMBER_GOAL,g-1,"'=HYPERLINK(""http://x"")",'@bob,,,,,,NOT_ALIGNED`, lines[1])
+		assert.Equal(t, "0,MEMBER_GOAL,g-2,'-10% churn,'+bob,,,,,,", lines[2])
+	})
+}
@@ -0,0 +1,575 @@
+package Companylib
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// KPI values are collected once per period of the KPI's reportingFrequency: daily, weekly (ISO
+// weeks), monthly, quarterly (calendar quarters) or annually. A value's date decides its period; a
+// second value for a period is rejected and the recorded one has to be amended instead. Adding or
+// amending a value leaves a KPI_VALUE_AUDIT record of who changed what.
+//
+// A period's value is due by the period's last day. A KPI is OVERDUE once a period after its
+// latest value has ended, within its quarter (or cycle), without a value; CURRENT when every ended
+// period has one; NOT_DUE before its first period ends; NOT_TRACKED without a reportingFrequency.
+
+const (
+	KPIFreshnessCurrent    = "CURRENT"
+	KPIFreshnessOverdue    = "OVERDUE"
+	KPIFreshnessNotDue     = "NOT_DUE"
+	KPIFreshnessNotTracked = "NOT_TRACKED"
+
+	KPIValueActionCreated = "CREATED"
+	KPIValueActionAmended = "AMENDED"
+
+	perfEntityKPIValueAudit   = "KPI_VALUE_AUDIT"
+	kpiMaxListedMissedPeriods = 12
+)
+
+var (
+	ErrKPIValueInvalid   = errors.New("invalid kpi value")
+	ErrKPIValueDuplicate = errors.New("a value is already recorded for this reporting period")
+	ErrKPIValueNotFound  = errors.New("kpi value not found")
+)
+
+// kpiSystemFields are maintained by value collection and reminders and cannot be patched
+var kpiSystemFields = []string{"lastValueDate", "lastReminderAt", "lastReminderPeriod"}
+
+// kpiPeriod is one reporting period, Start and End being its first and last day
+type kpiPeriod struct {
+	Key   string
+	Start time.Time
+	End   time.Time
+}
+
+// KPISubmissionStatus is how up to date a KPI's values are
+type KPISubmissionStatus struct {
+	KpiId              string   `json:"kpiId"`
+	Name               string   `json:"name"`
+	Owner              string   `json:"owner"`
+	Department         string   `json:"department,omitempty"`
+	Status             string   `json:"status"`
+	OrganizationId     string   `json:"organizationId"`
+	CycleId            string   `json:"cycleId"`
+	QuarterId          string   `json:"quarterId,omitempty"`
+	ReportingFrequency string   `json:"reportingFrequency,omitempty"`
+	Freshness          string   `json:"freshness"`
+	LastValueDate      string   `json:"lastValueDate,omitempty"`
+	LastValuePeriod    string   `json:"lastValuePeriod,omitempty"`
+	MissedPeriods      []string `json:"missedPeriods"` // the most recent kpiMaxListedMissedPeriods
+	MissedPeriodCount  int      `json:"missedPeriodCount"`
+	OverdueSince       string   `json:"overdueSince,omitempty"`
+	DaysOverdue        int      `json:"daysOverdue"`
+	NextDueDate        string   `json:"nextDueDate,omitempty"`
+	LastReminderAt     string   `json:"lastReminderAt,omitempty"`
+	LastReminderPeriod string   `json:"lastReminderPeriod,omitempty"`
+}
+
+// DataFreshnessSummary counts a cycle's KPIs by freshness
+type DataFreshnessSummary struct {
+	TotalKPIs  int `json:"totalKPIs"`
+	Current    int `json:"current"`
+	Overdue    int `json:"overdue"`
+	NotDue     int `json:"notDue"`
+	NotTracked int `json:"notTracked"`
+	// FreshnessRate is the share of KPIs with values due that are CURRENT, 0-100
+	FreshnessRate float64 `json:"freshnessRate"`
+}
+
+// OwnerDataFreshness counts one owner's KPIs by freshness
+type OwnerDataFreshness struct {
+	Owner   string `json:"owner"`
+	Current int    `json:"current"`
+	Overdue int    `json:"overdue"`
+}
+
+// CycleDataFreshness is the data freshness report of a performance cycle
+type CycleDataFreshness struct {
+	CycleId        string                `json:"cycleId"`
+	OrganizationId string                `json:"organizationId"`
+	GeneratedAt    string                `json:"generatedAt"`
+	Summary        DataFreshnessSummary  `json:"summary"`
+	KPIs           []KPISubmissionStatus `json:"kpis"`
+	Owners         []OwnerDataFreshness  `json:"owners"`
+}
+
+// kpiReportingPeriod is the period of frequency containing date
+func kpiReportingPeriod(frequency string, date time.Time) (kpiPeriod, bool) {
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	switch strings.ToLower(frequency) {
+	case "daily":
+		return kpiPeriod{Key: day.Format("2006-01-02"), Start: day, End: day}, true
+	case "weekly":
+		start := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		year, week := day.ISOWeek()
+		return kpiPeriod{Key: fmt.Sprintf("%d-W%02d", year, week), Start: start, End: start.AddDate(0, 0, 6)}, true
+	case "monthly":
+		start := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return kpiPeriod{Key: start.Format("2006-01"), Start: start, End: start.AddDate(0, 1, -1)}, true
+	case "quarterly":
+		quarter := (int(day.Month()) - 1) / 3
+		start := time.Date(day.Year(), time.Month(quarter*3+1), 1, 0, 0, 0, 0, time.UTC)
+		return kpiPeriod{Key: fmt.Sprintf("%d-Q%d", day.Year(), quarter+1), Start: start, End: start.AddDate(0, 3, -1)}, true
+	case "annually":
+		start := time.Date(day.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+		return kpiPeriod{Key: start.Format("2006"), Start: start, End: start.AddDate(1, 0, -1)}, true
+	}
+	return kpiPeriod{}, false
+}
+
+// kpiValueNumber accepts a JSON number or a numeric string
+func kpiValueNumber(v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case float64, float32, int, int64, int32:
+		return toFloat(n), nil
+	case string:
+		if parsed, err := strconv.ParseFloat(strings.TrimSpace(n), 64); err == nil {
+			return parsed, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: value must be a number", ErrKPIValueInvalid)
+}
+
+func kpiValueDate(record PerformanceRecord) time.Time {
+	date, _ := parseTrendDate(toString(record.Data["date"]))
+	return date
+}
+
+// latestKPIValue is the value with the latest date, the last recorded on ties
+func latestKPIValue(values []PerformanceRecord) *PerformanceRecord {
+	var latest *PerformanceRecord
+	for i := range values {
+		if values[i].EntityType != perfEntityKPIValue {
+			continue
+		}
+		if latest == nil {
+			latest = &values[i]
+			continue
+		}
+		date, latestDate := kpiValueDate(values[i]), kpiValueDate(*latest)
+		if date.After(latestDate) || (date.Equal(latestDate) && values[i].CreatedAt >= latest.CreatedAt) {
+			latest = &values[i]
+		}
+	}
+	return latest
+}
+
+func (svc *PerformanceService) kpiValueRecords(kpi *PerformanceRecord) ([]PerformanceRecord, error) {
+	return svc.queryByOrgPrefix(kpi.OrganizationId, fmt.Sprintf("%sCYCLE#%s#KPI#%s#VALUE#", perfSKPrefix, kpi.CycleId, toString(kpi.Data["id"])))
+}
+
+// syncKPICurrentValue points the KPI's currentValue at its latest value
+func (svc *PerformanceService) syncKPICurrentValue(kpi *PerformanceRecord, values []PerformanceRecord) {
+	latest := latestKPIValue(values)
+	if latest == nil {
+		return
+	}
+	if _, err := svc.patchRecord(kpi, map[string]interface{}{
+		"currentValue":  latest.Data["value"],
+		"lastValueDate": toString(latest.Data["date"]),
+	}); err != nil {
+		svc.logger.Printf("failed to update current value of KPI %s: %v", toString(kpi.Data["id"]), err)
+	}
+}
+
+// putKPIValueAudit records a change to a KPI value. changes maps each changed field to its from/to.
+func (svc *PerformanceService) putKPIValueAudit(kpi *PerformanceRecord, value map[string]interface{}, action string, changes map[string]interface{}, reason string, changedBy string) {
+	auditID := svc.generateID("kpi-value-audit")
+	now := svc.now()
+	kpiID := toString(kpi.Data["id"])
+	data := map[string]interface{}{
+		"id":        auditID,
+		"kpiId":     kpiID,
+		"valueId":   toString(value["id"]),
+		"date":      toString(value["date"]),
+		"action":    action,
+		"changes":   changes,
+		"changedBy": changedBy,
+		"changedAt": now,
+	}
+	if period := toString(value["reportingPeriod"]); period != "" {
+		data["reportingPeriod"] = period
+	}
+	if reason != "" {
+		data["reason"] = reason
+	}
+
+	err := svc.putRecord(PerformanceRecord{
+		PK:             kpi.PK,
+		SK:             fmt.Sprintf("%sCYCLE#%s#KPI#%s#AUDIT#%s#%s", perfSKPrefix, kpi.CycleId, kpiID, now, auditID),
+		GSI1PK:         fmt.Sprintf("%sKPI_VALUE_AUDIT#%s", perfSKPrefix, auditID),
+		GSI1SK:         fmt.Sprintf("%s#KPI#%s", kpi.OrganizationId, kpiID),
+		EntityType:     perfEntityKPIValueAudit,
+		OrganizationId: kpi.OrganizationId,
+		CycleId:        kpi.CycleId,
+		QuarterId:      kpi.QuarterId,
+		ParentId:       kpiID,
+		Owner:          changedBy,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		Data:           data,
+	})
+	if err != nil {
+		svc.logger.Printf("failed to record %s audit for KPI value %s: %v", action, toString(value["id"]), err)
+	}
+}
+
+// AmendKPIValue corrects the value or comment of a recorded KPI value. The date, and with it the
+// reporting period, cannot change.
+func (svc *PerformanceService) AmendKPIValue(kpiID string, valueID string, input map[string]interface{}, amendedBy string) (map[string]interface{}, error) {
+	kpi, err := svc.getRecordByGSI1(perfSKPrefix + "KPI#" + kpiID)
+	if err != nil {
+		return nil, err
+	}
+	if kpi == nil {
+		return nil, fmt.Errorf("kpi not found")
+	}
+	rec, err := svc.getRecordByGSI1(perfSKPrefix + "KPI_VALUE#" + valueID)
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil || rec.ParentId != kpiID {
+		return nil, ErrKPIValueNotFound
+	}
+
+	if date := toString(input["date"]); date != "" && date != toString(rec.Data["date"]) {
+		return nil, fmt.Errorf("%w: the date of a value cannot be amended, add a value for the other period instead", ErrKPIValueInvalid)
+	}
+
+	patch := map[string]interface{}{}
+	changes := map[string]interface{}{}
+	if hasValue(input, "value") {
+		value, err := kpiValueNumber(input["value"])
+		if err != nil {
+			return nil, err
+		}
+		if value != toFloat(rec.Data["value"]) {
+			changes["value"] = map[string]interface{}{"from": rec.Data["value"], "to": value}
+			patch["value"] = value
+		}
+	}
+	if comment, ok := input["comment"]; ok && toString(comment) != toString(rec.Data["comment"]) {
+		changes["comment"] = map[string]interface{}{"from": toString(rec.Data["comment"]), "to": toString(comment)}
+		patch["comment"] = toString(comment)
+	}
+	if len(changes) == 0 {
+		return nil, fmt.Errorf("%w: nothing to amend", ErrKPIValueInvalid)
+	}
+	patch["amendedBy"] = amendedBy
+	patch["amendedAt"] = svc.now()
+	patch["revision"] = max(toInt(rec.Data["revision"]), 1) + 1
+
+	updated, err := svc.patchRecord(rec, patch)
+	if err != nil {
+		return nil, err
+	}
+	svc.putKPIValueAudit(kpi, updated.Data, KPIValueActionAmended, changes, strings.TrimSpace(toString(input["reason"])), amendedBy)
+
+	if _, ok := changes["value"]; ok {
+		values, err := svc.kpiValueRecords(kpi)
+		if err != nil {
+			svc.logger.Printf("failed to load values of KPI %s: %v", kpiID, err)
+		} else {
+			for i := range values {
+				if values[i].SK == updated.SK {
+					values[i] = *updated
+				}
+			}
+			svc.syncKPICurrentValue(kpi, values)
+		}
+	}
+	return svc.toPayload(updated), nil
+}
+
+// GetKPIValueAudit lists the changes to a KPI's values, newest first
+func (svc *PerformanceService) GetKPIValueAudit(kpiID string) (map[string]interface{}, error) {
+	kpi, err := svc.getRecordByGSI1(perfSKPrefix + "KPI#" + kpiID)
+	if err != nil {
+		return nil, err
+	}
+	if kpi == nil {
+		return nil, fmt.Errorf("kpi not found")
+	}
+	records, err := svc.queryByOrgPrefix(kpi.OrganizationId, fmt.Sprintf("%sCYCLE#%s#KPI#%s#AUDIT#", perfSKPrefix, kpi.CycleId, kpiID))
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].SK > records[j].SK
+	})
+	audit := make([]map[string]interface{}, 0, len(records))
+	for i := range records {
+		audit = append(audit, svc.toPayload(&records[i]))
+	}
+	return map[string]interface{}{
+		"kpiId": kpiID,
+		"audit": audit,
+	}, nil
+}
+
+// kpiSubmissionStatus works out which of the KPI's reporting periods are missing a value as of now
+func kpiSubmissionStatus(kpi *PerformanceRecord, values []PerformanceRecord, periods map[string]*PerformanceRecord, now time.Time) KPISubmissionStatus {
+	status := KPISubmissionStatus{
+		KpiId:              toString(kpi.Data["id"]),
+		Name:               toString(kpi.Data["name"]),
+		Owner:              toString(kpi.Data["owner"]),
+		Department:         toString(kpi.Data["department"]),
+		Status:             toString(kpi.Data["status"]),
+		OrganizationId:     kpi.OrganizationId,
+		CycleId:            kpi.CycleId,
+		QuarterId:          kpi.QuarterId,
+		ReportingFrequency: strings.ToLower(toString(kpi.Data["reportingFrequency"])),
+		MissedPeriods:      []string{},
+		LastReminderAt:     toString(kpi.Data["lastReminderAt"]),
+		LastReminderPeriod: toString(kpi.Data["lastReminderPeriod"]),
+	}
+
+	latest := latestKPIValue(values)
+	if latest != nil {
+		status.LastValueDate = toString(latest.Data["date"])
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if _, ok := kpiReportingPeriod(status.ReportingFrequency, today); !ok {
+		status.Freshness = KPIFreshnessNotTracked
+		return status
+	}
+
+	windowStart, ok := kpiPeriodDate(kpi, periods, "startDate")
+	if !ok {
+		windowStart, ok = parseTrendDate(kpi.CreatedAt)
+	}
+	if !ok {
+		windowStart = today
+	}
+	windowEnd, ok := kpiPeriodDate(kpi, periods, "endDate")
+	if !ok {
+		windowEnd = today
+	}
+
+	// The first period still waiting for a value
+	from := windowStart
+	if latest != nil {
+		last, _ := kpiReportingPeriod(status.ReportingFrequency, kpiValueDate(*latest))
+		status.LastValuePeriod = last.Key
+		if last.End.AddDate(0, 0, 1).After(from) {
+			from = last.End.AddDate(0, 0, 1)
+		}
+	}
+	next, _ := kpiReportingPeriod(status.ReportingFrequency, from)
+
+	missed := []kpiPeriod{}
+	for next.End.Before(today) && !next.Start.After(windowEnd) {
+		missed = append(missed, next)
+		next, _ = kpiReportingPeriod(status.ReportingFrequency, next.End.AddDate(0, 0, 1))
+	}
+	if !next.Start.After(windowEnd) {
+		status.NextDueDate = next.End.Format("2006-01-02")
+	}
+
+	status.MissedPeriodCount = len(missed)
+	for _, period := range missed[max(0, len(missed)-kpiMaxListedMissedPeriods):] {
+		status.MissedPeriods = append(status.MissedPeriods, period.Key)
+	}
+	switch {
+	case len(missed) > 0:
+		status.Freshness = KPIFreshnessOverdue
+		status.OverdueSince = missed[0].End.Format("2006-01-02")
+		status.DaysOverdue = int(today.Sub(missed[0].End).Hours() / 24)
+	case latest == nil:
+		status.Freshness = KPIFreshnessNotDue
+	default:
+		status.Freshness = KPIFreshnessCurrent
+	}
+	return status
+}
+
+// GetCycleDataFreshness reports, for every KPI of the cycle, which reporting periods are missing a value
+func (svc *PerformanceService) GetCycleDataFreshness(cycleID string, now time.Time) (*CycleDataFreshness, error) {
+	cycle, err := svc.getRecordByGSI1(perfSKPrefix + "CYCLE#" + cycleID)
+	if err != nil {
+		return nil, err
+	}
+	if cycle == nil {
+		return nil, fmt.Errorf("performance cycle not found")
+	}
+	related, err := svc.queryByOrgPrefix(cycle.OrganizationId, fmt.Sprintf("%sCYCLE#%s#", perfSKPrefix, cycleID))
+	if err != nil {
+		return nil, err
+	}
+	return buildCycleDataFreshness(cycle, related, now), nil
+}
+
+func buildCycleDataFreshness(cycle *PerformanceRecord, related []PerformanceRecord, now time.Time) *CycleDataFreshness {
+	periods := map[string]*PerformanceRecord{cycle.GSI1PK: cycle}
+	valuesByKPI := map[string][]PerformanceRecord{}
+	for i := range related {
+		switch related[i].EntityType {
+		case perfEntityQuarter:
+			periods[related[i].GSI1PK] = &related[i]
+		case perfEntityKPIValue:
+			valuesByKPI[related[i].ParentId] = append(valuesByKPI[related[i].ParentId], related[i])
+		}
+	}
+
+	report := &CycleDataFreshness{
+		CycleId:        toString(cycle.Data["id"]),
+		OrganizationId: cycle.OrganizationId,
+		GeneratedAt:    now.UTC().Format(time.RFC3339),
+		KPIs:           []KPISubmissionStatus{},
+		Owners:         []OwnerDataFreshness{},
+	}
+	owners := map[string]*OwnerDataFreshness{}
+	for i := range related {
+		if related[i].EntityType != perfEntityKPI {
+			continue
+		}
+		status := kpiSubmissionStatus(&related[i], valuesByKPI[toString(related[i].Data["id"])], periods, now)
+		report.KPIs = append(report.KPIs, status)
+
+		report.Summary.TotalKPIs++
+		owner := owners[strings.ToLower(status.Owner)]
+		if owner == nil && status.Owner != "" {
+			owner = &OwnerDataFreshness{Owner: status.Owner}
+			owners[strings.ToLower(status.Owner)] = owner
+		}
+		switch status.Freshness {
+		case KPIFreshnessCurrent:
+			report.Summary.Current++
+			if owner != nil {
+				owner.Current++
+			}
+		case KPIFreshnessOverdue:
+			report.Summary.Overdue++
+			if owner != nil {
+				owner.Overdue++
+			}
+		case KPIFreshnessNotDue:
+			report.Summary.NotDue++
+		default:
+			report.Summary.NotTracked++
+		}
+	}
+	if due := report.Summary.Current + report.Summary.Overdue; due > 0 {
+		report.Summary.FreshnessRate = roundProgress(float64(report.Summary.Current) / float64(due) * 100)
+	}
+
+	// Most overdue first
+	sort.SliceStable(report.KPIs, func(i, j int) bool {
+		if report.KPIs[i].DaysOverdue != report.KPIs[j].DaysOverdue {
+			return report.KPIs[i].DaysOverdue > report.KPIs[j].DaysOverdue
+		}
+		return report.KPIs[i].Name < report.KPIs[j].Name
+	})
+	for _, owner := range owners {
+		report.Owners = append(report.Owners, *owner)
+	}
+	sort.SliceStable(report.Owners, func(i, j int) bool {
+		if report.Owners[i].Overdue != report.Owners[j].Overdue {
+			return report.Owners[i].Overdue > report.Owners[j].Overdue
+		}
+		return report.Owners[i].Owner < report.Owners[j].Owner
+	})
+	return report
+}
+
+// ListActivePerformanceCycles scans for the cycles of every organization that are not finalized or closed
+func (svc *PerformanceService) ListActivePerformanceCycles() ([]PerformanceRecord, error) {
+	input := &dynamodb.ScanInput{
+		TableName:        aws.String(svc.performanceTableName()),
+		FilterExpression: aws.String("EntityType = :cycle"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":cycle": &types.AttributeValueMemberS{Value: perfEntityCycle},
+		},
+	}
+
+	cycles := []PerformanceRecord{}
+	for {
+		out, err := svc.dynamodbClient.Scan(svc.ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan performance cycles: %w", err)
+		}
+		var page []PerformanceRecord
+		if err := attributevalue.UnmarshalListOfMaps(out.Items, &page); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal performance cycles: %w", err)
+		}
+		for _, cycle := range page {
+			if !isClosedPerformanceStatus(cycle.Status) {
+				cycles = append(cycles, cycle)
+			}
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			return cycles, nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+func isClosedPerformanceStatus(status string) bool {
+	return strings.EqualFold(status, "FINALIZED") || strings.EqualFold(status, "CLOSED")
+}
+
+// KPIRemindersDue groups the overdue KPIs of the report by owner. A KPI is reminded about again
+// once a newer period is missed, or repeatAfter after its last reminder.
+func KPIRemindersDue(report *CycleDataFreshness, now time.Time, repeatAfter time.Duration) map[string][]KPISubmissionStatus {
+	due := map[string][]KPISubmissionStatus{}
+	for _, status := range report.KPIs {
+		if status.Freshness != KPIFreshnessOverdue || status.Owner == "" || isClosedPerformanceStatus(status.Status) {
+			continue
+		}
+		latestMissed := status.MissedPeriods[len(status.MissedPeriods)-1]
+		if status.LastReminderPeriod == latestMissed {
+			if remindedAt, err := time.Parse(time.RFC3339, status.LastReminderAt); err == nil && now.Sub(remindedAt) < repeatAfter {
+				continue
+			}
+		}
+		owner := strings.ToLower(status.Owner)
+		due[owner] = append(due[owner], status)
+	}
+	return due
+}
+
+// MarkKPIReminderSent records the reminder on the KPI without touching its other fields
+func (svc *PerformanceService) MarkKPIReminderSent(status KPISubmissionStatus, at time.Time) error {
+	kpi, err := svc.getRecordByGSI1(perfSKPrefix + "KPI#" + status.KpiId)
+	if err != nil {
+		return err
+	}
+	if kpi == nil {
+		return fmt.Errorf("kpi not found")
+	}
+
+	period := ""
+	if len(status.MissedPeriods) > 0 {
+		period = status.MissedPeriods[len(status.MissedPeriods)-1]
+	}
+	_, err = svc.dynamodbClient.UpdateItem(svc.ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(svc.performanceTableName()),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: kpi.PK},
+			"SK": &types.AttributeValueMemberS{Value: kpi.SK},
+		},
+		UpdateExpression: aws.String("SET #data.lastReminderAt = :at, #data.lastReminderPeriod = :period"),
+		ExpressionAttributeNames: map[string]string{
+			"#data": "Data",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":at":     &types.AttributeValueMemberS{Value: at.UTC().Format(time.RFC3339)},
+			":period": &types.AttributeValueMemberS{Value: period},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record KPI reminder: %w", err)
+	}
+	return nil
+}
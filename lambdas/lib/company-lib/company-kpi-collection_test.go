@@ -0,0 +1,260 @@
+package Companylib
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodb_types "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	awsclients "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/clients"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKPIReportingPeriod(t *testing.T) {
+	date := time.Date(2025, 2, 12, 15, 0, 0, 0, time.UTC) // a Wednesday
+
+	cases := []struct {
+		frequency, key, start, end string
+	}{
+		{"daily", "2025-02-12", "2025-02-12", "2025-02-12"},
+		{"Weekly", "2025-W07", "2025-02-10", "2025-02-16"},
+		{"monthly", "2025-02", "2025-02-01", "2025-02-28"},
+		{"quarterly", "2025-Q1", "2025-01-01", "2025-03-31"},
+		{"annually", "2025", "2025-01-01", "2025-12-31"},
+	}
+	for _, c := range cases {
+		period, ok := kpiReportingPeriod(c.frequency, date)
+		assert.True(t, ok, c.frequency)
+		assert.Equal(t, c.key, period.Key)
+		assert.Equal(t, c.start, period.Start.Format("2006-01-02"))
+		assert.Equal(t, c.end, period.End.Format("2006-01-02"))
+	}
+
+	_, ok := kpiReportingPeriod("", date)
+	assert.False(t, ok)
+}
+
+func TestAddKPIValue(t *testing.T) {
+	kpi := PerformanceRecord{
+		PK: "ORG#org-1", SK: "PERF#CYCLE#c-1#KPI#kpi-1", GSI1PK: "PERF#KPI#kpi-1",
+		EntityType: perfEntityKPI, OrganizationId: "ORG#org-1", CycleId: "c-1",
+		Data: map[string]interface{}{"id": "kpi-1", "reportingFrequency": "monthly", "currentValue": 50.0},
+	}
+	april := kpiValueRecord("kpi-1", "2025-04-10", 50)
+	april.Data["id"] = "kpi-value-april"
+	april.Data["reportingPeriod"] = "2025-04"
+
+	t.Run("It should reject a second value for the period", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs: []dynamodb.QueryOutput{reviewQueryOutput(t, kpi), reviewQueryOutput(t, april)},
+			QueryErrors:  []error{nil, nil},
+		}
+		svc := newOKRTestService(&ddbClient)
+
+		_, err := svc.AddKPIValue("kpi-1", map[string]interface{}{"value": 55.0, "date": "2025-04-28"}, "owner@example.com")
+
+		assert.ErrorIs(t, err, ErrKPIValueDuplicate)
+		assert.Contains(t, err.Error(), "kpi-value-april")
+		assert.Empty(t, ddbClient.PutItemInputs)
+	})
+
+	t.Run("It should reject a second value for the period of a value stored without one", func(t *testing.T) {
+		legacy := kpiValueRecord("kpi-1", "2025-04-10", 50)
+		legacy.Data["id"] = "kpi-value-legacy"
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs: []dynamodb.QueryOutput{reviewQueryOutput(t, kpi), reviewQueryOutput(t, legacy)},
+			QueryErrors:  []error{nil, nil},
+		}
+		svc := newOKRTestService(&ddbClient)
+
+		_, err := svc.AddKPIValue("kpi-1", map[string]interface{}{"value": 55.0, "date": "2025-04-28"}, "owner@example.com")
+
+		assert.ErrorIs(t, err, ErrKPIValueDuplicate)
+		assert.Contains(t, err.Error(), "kpi-value-legacy")
+	})
+
+	t.Run("It should use the current reporting frequency for stored values", func(t *testing.T) {
+		quarterly := kpi
+		quarterly.Data = map[string]interface{}{"id": "kpi-1", "reportingFrequency": "quarterly"}
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs: []dynamodb.QueryOutput{reviewQueryOutput(t, quarterly), reviewQueryOutput(t, april)},
+			QueryErrors:  []error{nil, nil},
+		}
+		svc := newOKRTestService(&ddbClient)
+
+		// april was stored as monthly period 2025-04, which is in quarter 2025-Q2
+		_, err := svc.AddKPIValue("kpi-1", map[string]interface{}{"value": 55.0, "date": "2025-06-02"}, "owner@example.com")
+
+		assert.ErrorIs(t, err, ErrKPIValueDuplicate)
+		assert.Empty(t, ddbClient.PutItemInputs)
+	})
+
+	t.Run("It should store the value under its period so a concurrent submission fails", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs:   []dynamodb.QueryOutput{reviewQueryOutput(t, kpi), reviewQueryOutput(t)},
+			QueryErrors:    []error{nil, nil},
+			PutItemOutputs: []dynamodb.PutItemOutput{{}},
+			PutItemErrors:  []error{&dynamodb_types.ConditionalCheckFailedException{}},
+		}
+		svc := newOKRTestService(&ddbClient)
+
+		_, err := svc.AddKPIValue("kpi-1", map[string]interface{}{"value": 55.0, "date": "2025-04-28"}, "owner@example.com")
+
+		assert.ErrorIs(t, err, ErrKPIValueDuplicate)
+		value := recordFromPut(t, ddbClient.PutItemInputs[0])
+		assert.Equal(t, "PERF#CYCLE#c-1#KPI#kpi-1#VALUE#PERIOD#2025-04", value.SK)
+		assert.Equal(t, "attribute_not_exists(SK)", *ddbClient.PutItemInputs[0].ConditionExpression)
+	})
+
+	t.Run("It should reject a value that is not a number", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs: []dynamodb.QueryOutput{reviewQueryOutput(t, kpi)},
+			QueryErrors:  []error{nil},
+		}
+		svc := newOKRTestService(&ddbClient)
+
+		_, err := svc.AddKPIValue("kpi-1", map[string]interface{}{"value": "lots"}, "owner@example.com")
+
+		assert.ErrorIs(t, err, ErrKPIValueInvalid)
+	})
+
+	t.Run("It should audit a backfilled value without replacing the current value", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs:   []dynamodb.QueryOutput{reviewQueryOutput(t, kpi), reviewQueryOutput(t, april)},
+			QueryErrors:    []error{nil, nil},
+			PutItemOutputs: []dynamodb.PutItemOutput{{}, {}, {}},
+			PutItemErrors:  []error{nil, nil, nil},
+		}
+		svc := newOKRTestService(&ddbClient)
+
+		result, err := svc.AddKPIValue("kpi-1", map[string]interface{}{"value": "42", "date": "2025-03-05"}, "owner@example.com")
+
+		assert.NoError(t, err)
+		assert.Equal(t, 42.0, result["value"])
+		assert.Equal(t, "2025-03", result["reportingPeriod"])
+
+		assert.Len(t, ddbClient.PutItemInputs, 3)
+		audit := recordFromPut(t, ddbClient.PutItemInputs[1])
+		assert.Equal(t, perfEntityKPIValueAudit, audit.EntityType)
+		assert.Equal(t, KPIValueActionCreated, audit.Data["action"])
+		assert.Equal(t, "owner@example.com", audit.Data["changedBy"])
+
+		updatedKPI := recordFromPut(t, ddbClient.PutItemInputs[2])
+		assert.Equal(t, 50.0, updatedKPI.Data["currentValue"])
+		assert.Equal(t, "2025-04-10", updatedKPI.Data["lastValueDate"])
+	})
+}
+
+func TestAmendKPIValue(t *testing.T) {
+	kpi := PerformanceRecord{
+		PK: "ORG#org-1", SK: "PERF#CYCLE#c-1#KPI#kpi-1", GSI1PK: "PERF#KPI#kpi-1",
+		EntityType: perfEntityKPI, OrganizationId: "ORG#org-1", CycleId: "c-1",
+		Data: map[string]interface{}{"id": "kpi-1", "reportingFrequency": "monthly", "currentValue": 50.0},
+	}
+	april := kpiValueRecord("kpi-1", "2025-04-10", 50)
+	april.PK, april.SK = "ORG#org-1", "PERF#CYCLE#c-1#KPI#kpi-1#VALUE#kpi-value-april"
+	april.Data["id"] = "kpi-value-april"
+	april.Data["reportingPeriod"] = "2025-04"
+	april.Data["revision"] = 1.0
+
+	t.Run("It should correct the value and record who changed it", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs:   []dynamodb.QueryOutput{reviewQueryOutput(t, kpi), reviewQueryOutput(t, april), reviewQueryOutput(t, april)},
+			QueryErrors:    []error{nil, nil, nil},
+			PutItemOutputs: []dynamodb.PutItemOutput{{}, {}, {}},
+			PutItemErrors:  []error{nil, nil, nil},
+		}
+		svc := newOKRTestService(&ddbClient)
+
+		result, err := svc.AmendKPIValue("kpi-1", "kpi-value-april", map[string]interface{}{"value": 48.5, "reason": "late invoice"}, "admin@example.com")
+
+		assert.NoError(t, err)
+		assert.Equal(t, 48.5, result["value"])
+		assert.Equal(t, 2, result["revision"])
+		assert.Equal(t, "admin@example.com", result["amendedBy"])
+
+		audit := recordFromPut(t, ddbClient.PutItemInputs[1])
+		assert.Equal(t, KPIValueActionAmended, audit.Data["action"])
+		assert.Equal(t, "late invoice", audit.Data["reason"])
+		assert.Equal(t, map[string]interface{}{"from": 50.0, "to": 48.5}, audit.Data["changes"].(map[string]interface{})["value"])
+
+		updatedKPI := recordFromPut(t, ddbClient.PutItemInputs[2])
+		assert.Equal(t, 48.5, updatedKPI.Data["currentValue"])
+	})
+
+	t.Run("It should not move a value to another date", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs: []dynamodb.QueryOutput{reviewQueryOutput(t, kpi), reviewQueryOutput(t, april)},
+			QueryErrors:  []error{nil, nil},
+		}
+		svc := newOKRTestService(&ddbClient)
+
+		_, err := svc.AmendKPIValue("kpi-1", "kpi-value-april", map[string]interface{}{"date": "2025-05-01"}, "admin@example.com")
+
+		assert.ErrorIs(t, err, ErrKPIValueInvalid)
+		assert.Empty(t, ddbClient.PutItemInputs)
+	})
+}
+
+func TestBuildCycleDataFreshness(t *testing.T) {
+	now := time.Date(2025, 5, 10, 6, 0, 0, 0, time.UTC)
+	cycle := PerformanceRecord{
+		GSI1PK: "PERF#CYCLE#c-1", EntityType: perfEntityCycle, OrganizationId: "ORG#org-1",
+		Data: map[string]interface{}{"id": "c-1", "startDate": "2025-01-01", "endDate": "2025-06-30"},
+	}
+	kpi := func(id, frequency, owner string, extra map[string]interface{}) PerformanceRecord {
+		data := map[string]interface{}{"id": id, "name": id, "owner": owner, "reportingFrequency": frequency, "status": "ACTIVE"}
+		for k, v := range extra {
+			data[k] = v
+		}
+		return PerformanceRecord{EntityType: perfEntityKPI, OrganizationId: "ORG#org-1", CycleId: "c-1", Data: data}
+	}
+	related := []PerformanceRecord{
+		kpi("kpi-monthly", "monthly", "ann@example.com", map[string]interface{}{
+			"lastReminderPeriod": "2025-04", "lastReminderAt": now.Add(-24 * time.Hour).Format(time.RFC3339),
+		}),
+		kpi("kpi-weekly", "weekly", "ann@example.com", nil),
+		kpi("kpi-quarterly", "quarterly", "bob@example.com", nil),
+		kpi("kpi-annual", "annually", "bob@example.com", nil),
+		kpi("kpi-untracked", "", "bob@example.com", nil),
+		kpiValueRecord("kpi-monthly", "2025-02-14", 10),
+		kpiValueRecord("kpi-weekly", "2025-05-06", 3),
+	}
+
+	report := buildCycleDataFreshness(&cycle, related, now)
+
+	assert.Equal(t, DataFreshnessSummary{TotalKPIs: 5, Current: 1, Overdue: 2, NotDue: 1, NotTracked: 1, FreshnessRate: 33.3}, report.Summary)
+
+	statuses := map[string]KPISubmissionStatus{}
+	for _, status := range report.KPIs {
+		statuses[status.KpiId] = status
+	}
+	monthly := statuses["kpi-monthly"]
+	assert.Equal(t, KPIFreshnessOverdue, monthly.Freshness)
+	assert.Equal(t, []string{"2025-03", "2025-04"}, monthly.MissedPeriods)
+	assert.Equal(t, "2025-03-31", monthly.OverdueSince)
+	assert.Equal(t, 40, monthly.DaysOverdue)
+	assert.Equal(t, "2025-05-31", monthly.NextDueDate)
+	assert.Equal(t, "2025-02", monthly.LastValuePeriod)
+
+	assert.Equal(t, KPIFreshnessCurrent, statuses["kpi-weekly"].Freshness)
+	assert.Equal(t, "2025-05-18", statuses["kpi-weekly"].NextDueDate) // this week is already in
+	assert.Equal(t, []string{"2025-Q1"}, statuses["kpi-quarterly"].MissedPeriods)
+	assert.Equal(t, KPIFreshnessNotDue, statuses["kpi-annual"].Freshness)
+	assert.Equal(t, KPIFreshnessNotTracked, statuses["kpi-untracked"].Freshness)
+
+	assert.Equal(t, "kpi-monthly", report.KPIs[0].KpiId)
+	assert.Equal(t, []OwnerDataFreshness{
+		{Owner: "ann@example.com", Current: 1, Overdue: 1},
+		{Owner: "bob@example.com", Overdue: 1},
+	}, report.Owners)
+
+	t.Run("It should not remind again within the repeat interval", func(t *testing.T) {
+		due := KPIRemindersDue(report, now, 72*time.Hour)
+		assert.Len(t, due, 1)
+		assert.Equal(t, "kpi-quarterly", due["bob@example.com"][0].KpiId)
+
+		due = KPIRemindersDue(report, now.Add(72*time.Hour), 72*time.Hour)
+		assert.Len(t, due, 2)
+	})
+}
@@ -0,0 +1,244 @@
+package Companylib
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// KPIs are evaluated against their target in the direction that counts as better:
+//
+//	increase  higher is better (revenue, NPS)
+//	decrease  lower is better (churn, defect rate)
+//	maintain  closest to target is better (headcount, utilisation)
+//
+// direction defaults from the KPI's trend (up, down, stable) and otherwise to increase. Thresholds
+// used to have to run green >= amber >= red whatever the trend, so a KPI without a direction whose
+// thresholds still run that way keeps increase, the meaning it was saved with.
+//
+// progress (0-100) measures the way from baselineValue to targetValue, or the ratio to the target
+// without a baseline. For maintain it falls off with the distance from the target beyond the
+// tolerance.
+//
+// ragStatus uses the KPI's green/amber/red thresholds, given in the KPI's own unit and ordered by
+// direction (for maintain they are the allowed distance from the target). Anything worse than
+// amberThreshold is RED; redThreshold only matters when amberThreshold is not set. Without
+// thresholds progress is banded at 90 and 60. tolerance is how far a value may fall short of a
+// threshold, or of the target, and still count as meeting it.
+
+const (
+	KPIDirectionIncrease = "increase"
+	KPIDirectionDecrease = "decrease"
+	KPIDirectionMaintain = "maintain"
+
+	KPIRagGreen  = "GREEN"
+	KPIRagAmber  = "AMBER"
+	KPIRagRed    = "RED"
+	KPIRagNoData = "NO_DATA"
+
+	kpiDefaultGreenProgress = 90.0
+	kpiDefaultAmberProgress = 60.0
+)
+
+var ErrKPIInvalid = errors.New("invalid kpi")
+
+// kpiComputedFields are derived on every read and cannot be patched
+var kpiComputedFields = []string{"progress", "ragStatus"}
+
+// KPIEvaluation is how a KPI's current value measures up against its target and thresholds
+type KPIEvaluation struct {
+	Direction string  `json:"direction"`
+	Progress  float64 `json:"progress"`
+	RagStatus string  `json:"ragStatus"`
+}
+
+// kpiEvaluationFields are the fields validateKPIEvaluation checks
+var kpiEvaluationFields = []string{"direction", "trend", "tolerance", "greenThreshold", "amberThreshold", "redThreshold"}
+
+// kpiDirection is the KPI's direction, falling back to its trend
+func kpiDirection(kpi map[string]interface{}) string {
+	switch strings.ToLower(toString(kpi["direction"])) {
+	case KPIDirectionIncrease:
+		return KPIDirectionIncrease
+	case KPIDirectionDecrease:
+		return KPIDirectionDecrease
+	case KPIDirectionMaintain:
+		return KPIDirectionMaintain
+	}
+	if kpiThresholdsDescend(kpi) {
+		return KPIDirectionIncrease
+	}
+	switch strings.ToLower(toString(kpi["trend"])) {
+	case "down":
+		return KPIDirectionDecrease
+	case "stable":
+		return KPIDirectionMaintain
+	}
+	return KPIDirectionIncrease
+}
+
+// kpiThresholdsDescend reports whether the KPI's thresholds get lower from green to red, which
+// only fits a KPI where higher is better
+func kpiThresholdsDescend(kpi map[string]interface{}) bool {
+	descends := false
+	prev, seen := 0.0, false
+	for _, key := range []string{"greenThreshold", "amberThreshold", "redThreshold"} {
+		if !hasValue(kpi, key) {
+			continue
+		}
+		next := toFloat(kpi[key])
+		if seen {
+			if next > prev {
+				return false
+			}
+			descends = descends || next < prev
+		}
+		prev, seen = next, true
+	}
+	return descends
+}
+
+// validateKPIEvaluation checks the fields the evaluation reads
+func validateKPIEvaluation(input map[string]interface{}) error {
+	if direction := toString(input["direction"]); direction != "" {
+		allowed := map[string]bool{KPIDirectionIncrease: true, KPIDirectionDecrease: true, KPIDirectionMaintain: true}
+		if !allowed[strings.ToLower(direction)] {
+			return fmt.Errorf("invalid direction")
+		}
+		input["direction"] = strings.ToLower(direction)
+	}
+	if hasValue(input, "tolerance") && toFloat(input["tolerance"]) < 0 {
+		return fmt.Errorf("tolerance must be >= 0")
+	}
+
+	// Thresholds must get worse from green to red in the KPI's direction
+	thresholds := []string{}
+	for _, key := range []string{"greenThreshold", "amberThreshold", "redThreshold"} {
+		if hasValue(input, key) {
+			thresholds = append(thresholds, key)
+		}
+	}
+	lowerIsBetter := kpiDirection(input) != KPIDirectionIncrease
+	for i := 1; i < len(thresholds); i++ {
+		prev, next := toFloat(input[thresholds[i-1]]), toFloat(input[thresholds[i]])
+		if lowerIsBetter && prev > next {
+			return fmt.Errorf("greenThreshold must be <= amberThreshold <= redThreshold for a %s KPI", kpiDirection(input))
+		}
+		if !lowerIsBetter && prev < next {
+			return fmt.Errorf("greenThreshold must be >= amberThreshold >= redThreshold")
+		}
+	}
+	if kpiDirection(input) == KPIDirectionMaintain && len(thresholds) > 0 && toFloat(input[thresholds[0]]) < 0 {
+		return fmt.Errorf("thresholds of a maintain KPI are distances from the target and must be >= 0")
+	}
+	return nil
+}
+
+// evaluateKPI measures the KPI's currentValue against its target and thresholds
+func evaluateKPI(kpi map[string]interface{}) KPIEvaluation {
+	evaluation := KPIEvaluation{Direction: kpiDirection(kpi)}
+	if !hasValue(kpi, "currentValue") {
+		evaluation.RagStatus = KPIRagNoData
+		return evaluation
+	}
+
+	current := toFloat(kpi["currentValue"])
+	target := toFloat(kpi["targetValue"])
+	tolerance := math.Max(0, toFloat(kpi["tolerance"]))
+	evaluation.Progress = roundProgress(kpiProgress(evaluation.Direction, current, target, kpi, tolerance))
+
+	// How good the value is, as a number where higher is always better
+	score := func(v float64) float64 {
+		if evaluation.Direction == KPIDirectionIncrease {
+			return v
+		}
+		return -v
+	}
+	measured := current
+	if evaluation.Direction == KPIDirectionMaintain {
+		// thresholds are distances from the target
+		measured = math.Abs(current - target)
+	}
+	meets := func(threshold float64) bool {
+		return score(measured)+tolerance >= score(threshold)
+	}
+
+	switch {
+	case hasValue(kpi, "greenThreshold") || hasValue(kpi, "amberThreshold") || hasValue(kpi, "redThreshold"):
+		switch {
+		case hasValue(kpi, "greenThreshold") && meets(toFloat(kpi["greenThreshold"])):
+			evaluation.RagStatus = KPIRagGreen
+		case hasValue(kpi, "amberThreshold"):
+			evaluation.RagStatus = KPIRagRed
+			if meets(toFloat(kpi["amberThreshold"])) {
+				evaluation.RagStatus = KPIRagAmber
+			}
+		case hasValue(kpi, "redThreshold"):
+			evaluation.RagStatus = KPIRagAmber
+			if score(measured)+tolerance <= score(toFloat(kpi["redThreshold"])) {
+				evaluation.RagStatus = KPIRagRed
+			}
+		default:
+			evaluation.RagStatus = KPIRagRed
+		}
+	case evaluation.Direction != KPIDirectionMaintain && meets(target):
+		evaluation.RagStatus = KPIRagGreen
+	case evaluation.Progress >= kpiDefaultGreenProgress:
+		evaluation.RagStatus = KPIRagGreen
+	case evaluation.Progress >= kpiDefaultAmberProgress:
+		evaluation.RagStatus = KPIRagAmber
+	default:
+		evaluation.RagStatus = KPIRagRed
+	}
+	return evaluation
+}
+
+// kpiProgress is how far the KPI has come towards its target, 0-100
+func kpiProgress(direction string, current float64, target float64, kpi map[string]interface{}, tolerance float64) float64 {
+	hasBaseline := hasValue(kpi, "baselineValue")
+	baseline := toFloat(kpi["baselineValue"])
+
+	var progress float64
+	switch direction {
+	case KPIDirectionDecrease:
+		switch {
+		case hasBaseline && baseline != target:
+			progress = (baseline - current) / (baseline - target) * 100
+		case current <= target:
+			progress = 100
+		case current > 0 && target >= 0:
+			progress = target / current * 100
+		}
+	case KPIDirectionMaintain:
+		deviation := math.Max(0, math.Abs(current-target)-tolerance)
+		scale := math.Abs(target)
+		if hasBaseline && baseline != target {
+			scale = math.Abs(baseline - target)
+		}
+		if scale == 0 {
+			scale = 1
+		}
+		progress = 100 - deviation/scale*100
+	default:
+		switch {
+		case hasBaseline && baseline != target:
+			progress = (current - baseline) / (target - baseline) * 100
+		case target > 0:
+			progress = current / target * 100
+		case current >= target:
+			progress = 100
+		}
+	}
+	return math.Max(0, math.Min(100, progress))
+}
+
+// kpiPayload is the KPI's payload with its evaluation
+func (svc *PerformanceService) kpiPayload(record *PerformanceRecord) map[string]interface{} {
+	payload := svc.toPayload(record)
+	evaluation := evaluateKPI(payload)
+	payload["direction"] = evaluation.Direction
+	payload["progress"] = evaluation.Progress
+	payload["ragStatus"] = evaluation.RagStatus
+	return payload
+}
@@ -0,0 +1,212 @@
+package Companylib
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	awsclients "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/clients"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKPIDirection(t *testing.T) {
+	assert.Equal(t, KPIDirectionIncrease, kpiDirection(map[string]interface{}{}))
+	assert.Equal(t, KPIDirectionDecrease, kpiDirection(map[string]interface{}{"trend": "down"}))
+	assert.Equal(t, KPIDirectionMaintain, kpiDirection(map[string]interface{}{"trend": "stable"}))
+	assert.Equal(t, KPIDirectionIncrease, kpiDirection(map[string]interface{}{"trend": "down", "direction": "increase"}))
+}
+
+func TestEvaluateKPI(t *testing.T) {
+	tests := []struct {
+		name         string
+		kpi          map[string]interface{}
+		wantProgress float64
+		wantRag      string
+	}{
+		{
+			name:         "no value recorded yet",
+			kpi:          map[string]interface{}{"targetValue": 100.0},
+			wantProgress: 0,
+			wantRag:      KPIRagNoData,
+		},
+		{
+			name:         "increase without thresholds uses the default bands",
+			kpi:          map[string]interface{}{"targetValue": 100.0, "currentValue": 75.0},
+			wantProgress: 75,
+			wantRag:      KPIRagAmber,
+		},
+		{
+			name:         "increase from a baseline",
+			kpi:          map[string]interface{}{"targetValue": 100.0, "baselineValue": 80.0, "currentValue": 90.0},
+			wantProgress: 50,
+			wantRag:      KPIRagRed,
+		},
+		{
+			name:         "increase within tolerance of target is green",
+			kpi:          map[string]interface{}{"targetValue": 100.0, "currentValue": 80.0, "tolerance": 20.0},
+			wantProgress: 80,
+			wantRag:      KPIRagGreen,
+		},
+		{
+			name:         "decrease below target is complete",
+			kpi:          map[string]interface{}{"direction": "decrease", "targetValue": 2.0, "currentValue": 1.5},
+			wantProgress: 100,
+			wantRag:      KPIRagGreen,
+		},
+		{
+			name:         "decrease from a baseline",
+			kpi:          map[string]interface{}{"trend": "down", "targetValue": 2.0, "baselineValue": 10.0, "currentValue": 6.0},
+			wantProgress: 50,
+			wantRag:      KPIRagRed,
+		},
+		{
+			name: "decrease with thresholds in the KPI's unit",
+			kpi: map[string]interface{}{
+				"direction": "decrease", "targetValue": 2.0, "currentValue": 3.5,
+				"greenThreshold": 3.0, "amberThreshold": 4.0, "redThreshold": 6.0,
+			},
+			wantProgress: 57.1,
+			wantRag:      KPIRagAmber,
+		},
+		{
+			name: "increase with thresholds",
+			kpi: map[string]interface{}{
+				"targetValue": 100.0, "currentValue": 50.0,
+				"greenThreshold": 90.0, "amberThreshold": 70.0,
+			},
+			wantProgress: 50,
+			wantRag:      KPIRagRed,
+		},
+		{
+			name: "red threshold alone splits amber from red",
+			kpi: map[string]interface{}{
+				"targetValue": 100.0, "currentValue": 50.0,
+				"greenThreshold": 90.0, "redThreshold": 40.0,
+			},
+			wantProgress: 50,
+			wantRag:      KPIRagAmber,
+		},
+		{
+			name:         "maintain within tolerance",
+			kpi:          map[string]interface{}{"direction": "maintain", "targetValue": 50.0, "currentValue": 53.0, "tolerance": 5.0},
+			wantProgress: 100,
+			wantRag:      KPIRagGreen,
+		},
+		{
+			name: "maintain with distance thresholds",
+			kpi: map[string]interface{}{
+				"direction": "maintain", "targetValue": 50.0, "currentValue": 58.0,
+				"greenThreshold": 5.0, "amberThreshold": 10.0,
+			},
+			wantProgress: 84,
+			wantRag:      KPIRagAmber,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evaluation := evaluateKPI(tt.kpi)
+			assert.Equal(t, tt.wantProgress, evaluation.Progress)
+			assert.Equal(t, tt.wantRag, evaluation.RagStatus)
+		})
+	}
+}
+
+func TestValidateKPIEvaluation(t *testing.T) {
+	assert.NoError(t, validateKPIEvaluation(map[string]interface{}{"greenThreshold": 90.0, "amberThreshold": 60.0}))
+	assert.NoError(t, validateKPIEvaluation(map[string]interface{}{"direction": "decrease", "greenThreshold": 2.0, "amberThreshold": 5.0, "redThreshold": 8.0}))
+	assert.NoError(t, validateKPIEvaluation(map[string]interface{}{"trend": "down", "greenThreshold": 2.0, "redThreshold": 8.0}))
+
+	input := map[string]interface{}{"direction": "Decrease"}
+	assert.NoError(t, validateKPIEvaluation(input))
+	assert.Equal(t, KPIDirectionDecrease, input["direction"])
+
+	assert.Error(t, validateKPIEvaluation(map[string]interface{}{"greenThreshold": 2.0, "amberThreshold": 5.0}))
+	assert.Error(t, validateKPIEvaluation(map[string]interface{}{"direction": "decrease", "greenThreshold": 90.0, "amberThreshold": 60.0}))
+	assert.Error(t, validateKPIEvaluation(map[string]interface{}{"direction": "sideways"}))
+	assert.Error(t, validateKPIEvaluation(map[string]interface{}{"tolerance": -1.0}))
+	assert.Error(t, validateKPIEvaluation(map[string]interface{}{"direction": "maintain", "greenThreshold": -1.0}))
+}
+
+func TestUpdateKPIValidatesEvaluation(t *testing.T) {
+	kpi := PerformanceRecord{
+		PK: "ORG#org-1", SK: "PERF#CYCLE#c-1#KPI#kpi-1", GSI1PK: "PERF#KPI#kpi-1",
+		EntityType: perfEntityKPI, OrganizationId: "ORG#org-1", CycleId: "c-1",
+		Data: map[string]interface{}{"id": "kpi-1", "targetValue": 100.0, "greenThreshold": 90.0, "amberThreshold": 60.0},
+	}
+
+	t.Run("It should reject thresholds that do not fit the new direction", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs: []dynamodb.QueryOutput{reviewQueryOutput(t, kpi)},
+			QueryErrors:  []error{nil},
+		}
+		svc := newOKRTestService(&ddbClient)
+
+		_, err := svc.UpdateKPI("kpi-1", map[string]interface{}{"direction": "decrease"})
+
+		assert.True(t, errors.Is(err, ErrKPIInvalid))
+		assert.Empty(t, ddbClient.PutItemInputs)
+	})
+
+	t.Run("It should return the evaluated KPI", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs:   []dynamodb.QueryOutput{reviewQueryOutput(t, kpi)},
+			QueryErrors:    []error{nil},
+			PutItemOutputs: []dynamodb.PutItemOutput{{}},
+			PutItemErrors:  []error{nil},
+		}
+		svc := newOKRTestService(&ddbClient)
+
+		result, err := svc.UpdateKPI("kpi-1", map[string]interface{}{"currentValue": 95.0, "ragStatus": "RED"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, KPIRagGreen, result["ragStatus"])
+		assert.Equal(t, 95.0, result["progress"])
+		assert.NotContains(t, recordFromPut(t, ddbClient.PutItemInputs[0]).Data, "ragStatus")
+	})
+}
+
+func TestUpdateLegacyKPI(t *testing.T) {
+	// Saved when thresholds had to run green >= amber >= red whatever the trend
+	kpi := PerformanceRecord{
+		PK: "ORG#org-1", SK: "PERF#CYCLE#c-1#KPI#kpi-2", GSI1PK: "PERF#KPI#kpi-2",
+		EntityType: perfEntityKPI, OrganizationId: "ORG#org-1", CycleId: "c-1", SchemaVersion: perfDataSchemaVersion,
+		Data: map[string]interface{}{"id": "kpi-2", "trend": "down", "targetValue": 100.0, "currentValue": 95.0,
+			"greenThreshold": 90.0, "amberThreshold": 60.0, "redThreshold": 30.0},
+	}
+
+	t.Run("It should keep the higher-is-better meaning it was saved with", func(t *testing.T) {
+		evaluation := evaluateKPI(kpi.Data)
+
+		assert.Equal(t, KPIDirectionIncrease, evaluation.Direction)
+		assert.Equal(t, KPIRagGreen, evaluation.RagStatus)
+	})
+
+	t.Run("It should accept a patch that does not touch the evaluation", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs:   []dynamodb.QueryOutput{reviewQueryOutput(t, kpi)},
+			QueryErrors:    []error{nil},
+			PutItemOutputs: []dynamodb.PutItemOutput{{}},
+			PutItemErrors:  []error{nil},
+		}
+		svc := newOKRTestService(&ddbClient)
+
+		result, err := svc.UpdateKPI("kpi-2", map[string]interface{}{"description": "Weekly active teams"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, KPIRagGreen, result["ragStatus"])
+		assert.Equal(t, "Weekly active teams", recordFromPut(t, ddbClient.PutItemInputs[0]).Data["description"])
+	})
+
+	t.Run("It should check thresholds once the direction is set", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs: []dynamodb.QueryOutput{reviewQueryOutput(t, kpi)},
+			QueryErrors:  []error{nil},
+		}
+		svc := newOKRTestService(&ddbClient)
+
+		_, err := svc.UpdateKPI("kpi-2", map[string]interface{}{"direction": "decrease"})
+
+		assert.True(t, errors.Is(err, ErrKPIInvalid))
+		assert.Empty(t, ddbClient.PutItemInputs)
+	})
+}
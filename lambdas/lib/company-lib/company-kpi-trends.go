@@ -0,0 +1,444 @@
+package Companylib
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// KPI trends are built from the dated KPI_VALUE records added through AddKPIValue. Readings are
+// bucketed by calendar month or ISO week; a period's value is its latest reading and the KPI keeps
+// that value until the next reading. rollingAverage is the mean of the last kpiRollingWindow
+// periods with readings.
+//
+// The forecast fits a least-squares line through the period values and projects it to the end of
+// the KPI's quarter, or of the cycle for a cycle-wide KPI. Once the series covers two full seasons
+// (months of a quarter, or weeks of a quarter) the average residual of each season position is
+// added on top. A KPI is projectedToMiss when the projected value does not meet its target in the
+// KPI's direction, allowing for its tolerance. Each kpiTrends entry keeps the month key (Jan 2006)
+// it had before trends were bucketed; for weekly trends it is the month the week starts in.
+
+const (
+	KPITrendGranularityMonth = "month"
+	KPITrendGranularityWeek  = "week"
+
+	KPIForecastLinear   = "LINEAR"
+	KPIForecastSeasonal = "SEASONAL"
+
+	kpiRollingWindow      = 3
+	kpiSeasonLengthMonths = 3
+	kpiSeasonLengthWeeks  = 13
+	kpiTrendMaxPeriods    = 104
+)
+
+var ErrTrendGranularityInvalid = errors.New("invalid trend granularity")
+
+// kpiReading is one dated KPI_VALUE record
+type kpiReading struct {
+	date      time.Time
+	createdAt string
+	value     float64
+}
+
+// kpiTrendPoint is a KPI's value over one period
+type kpiTrendPoint struct {
+	index   int
+	value   float64
+	average float64
+	count   int
+}
+
+// KPIForecast is a KPI's projected value at the end of its period
+type KPIForecast struct {
+	Method             string  `json:"method"`
+	ForecastDate       string  `json:"forecastDate"`
+	ProjectedValue     float64 `json:"projectedValue"`
+	ProjectedProgress  float64 `json:"projectedProgress"`
+	ProjectedRagStatus string  `json:"projectedRagStatus"`
+	SlopePerPeriod     float64 `json:"slopePerPeriod"`
+	ProjectedToMiss    bool    `json:"projectedToMiss"`
+}
+
+// normalizeTrendGranularity defaults an empty granularity to month
+func normalizeTrendGranularity(granularity string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(granularity)) {
+	case "", KPITrendGranularityMonth:
+		return KPITrendGranularityMonth, nil
+	case KPITrendGranularityWeek:
+		return KPITrendGranularityWeek, nil
+	}
+	return "", ErrTrendGranularityInvalid
+}
+
+// trendPeriodIndex numbers calendar months or ISO weeks so consecutive periods differ by one
+func trendPeriodIndex(t time.Time, granularity string) int {
+	if granularity == KPITrendGranularityWeek {
+		days := int(t.Unix() / 86400)
+		// 1970-01-01 was a Thursday; shift so weeks start on Monday
+		return int(math.Floor(float64(days+3) / 7))
+	}
+	return t.Year()*12 + int(t.Month()) - 1
+}
+
+// trendPeriodStart is the first day of the period with the given index
+func trendPeriodStart(index int, granularity string) time.Time {
+	if granularity == KPITrendGranularityWeek {
+		return time.Unix(int64(index*7-3)*86400, 0).UTC()
+	}
+	return time.Date(index/12, time.Month(index%12+1), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// trendPeriodLabel is 2027-02 for a month or 2027-W06 for an ISO week
+func trendPeriodLabel(index int, granularity string) string {
+	start := trendPeriodStart(index, granularity)
+	if granularity == KPITrendGranularityWeek {
+		year, week := start.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	}
+	return start.Format("2006-01")
+}
+
+// parseTrendDate accepts a date or an RFC3339 timestamp
+func parseTrendDate(raw string) (time.Time, bool) {
+	if t, err := time.Parse("2006-01-02", raw); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t.UTC(), true
+	}
+	return time.Time{}, false
+}
+
+// kpiReadingsByKPI groups the KPI_VALUE records by KPI, oldest first
+func kpiReadingsByKPI(related []PerformanceRecord) map[string][]kpiReading {
+	readings := map[string][]kpiReading{}
+	for i := range related {
+		r := &related[i]
+		if r.EntityType != perfEntityKPIValue || !hasValue(r.Data, "value") {
+			continue
+		}
+		date, ok := parseTrendDate(toString(r.Data["date"]))
+		if !ok {
+			continue
+		}
+		readings[r.ParentId] = append(readings[r.ParentId], kpiReading{
+			date:      date,
+			createdAt: toString(r.Data["createdAt"]),
+			value:     toFloat(r.Data["value"]),
+		})
+	}
+	for kpiID := range readings {
+		sort.SliceStable(readings[kpiID], func(i, j int) bool {
+			a, b := readings[kpiID][i], readings[kpiID][j]
+			if !a.date.Equal(b.date) {
+				return a.date.Before(b.date)
+			}
+			return a.createdAt < b.createdAt
+		})
+	}
+	return readings
+}
+
+// bucketKPIReadings turns readings, oldest first, into one point per period with readings
+func bucketKPIReadings(readings []kpiReading, granularity string) []kpiTrendPoint {
+	points := []kpiTrendPoint{}
+	sum := 0.0
+	for _, reading := range readings {
+		index := trendPeriodIndex(reading.date, granularity)
+		if len(points) == 0 || points[len(points)-1].index != index {
+			points = append(points, kpiTrendPoint{index: index})
+			sum = 0
+		}
+		last := &points[len(points)-1]
+		sum += reading.value
+		last.count++
+		last.value = reading.value
+		last.average = sum / float64(last.count)
+	}
+	return points
+}
+
+// rollingAverages is the trailing mean over kpiRollingWindow values
+func rollingAverages(values []float64) []float64 {
+	averages := make([]float64, len(values))
+	sum := 0.0
+	for i, v := range values {
+		sum += v
+		if i >= kpiRollingWindow {
+			sum -= values[i-kpiRollingWindow]
+		}
+		averages[i] = sum / math.Min(float64(i+1), kpiRollingWindow)
+	}
+	return averages
+}
+
+// fitKPITrend is the least-squares line through the points after subtracting seasonal from each
+func fitKPITrend(points []kpiTrendPoint, seasonal func(index int) float64) func(index int) float64 {
+	meanX, meanY := 0.0, 0.0
+	for _, p := range points {
+		meanX += float64(p.index)
+		meanY += p.value - seasonal(p.index)
+	}
+	meanX /= float64(len(points))
+	meanY /= float64(len(points))
+
+	sxx, sxy := 0.0, 0.0
+	for _, p := range points {
+		sxx += (float64(p.index) - meanX) * (float64(p.index) - meanX)
+		sxy += (float64(p.index) - meanX) * (p.value - seasonal(p.index) - meanY)
+	}
+	slope := 0.0
+	if sxx > 0 {
+		slope = sxy / sxx
+	}
+	return func(index int) float64 {
+		return meanY + slope*(float64(index)-meanX)
+	}
+}
+
+// forecastKPIValue projects the period values to the period with index horizon. ok is false
+// with fewer than two periods of data.
+func forecastKPIValue(points []kpiTrendPoint, horizon int, granularity string) (projected float64, slope float64, method string, ok bool) {
+	if len(points) < 2 {
+		return 0, 0, "", false
+	}
+	noSeason := func(int) float64 { return 0 }
+	line := fitKPITrend(points, noSeason)
+	linear := func() (float64, float64, string, bool) {
+		return line(horizon), line(1) - line(0), KPIForecastLinear, true
+	}
+
+	seasonLength := kpiSeasonLengthMonths
+	if granularity == KPITrendGranularityWeek {
+		seasonLength = kpiSeasonLengthWeeks
+	}
+	if points[len(points)-1].index-points[0].index+1 < 2*seasonLength {
+		return linear()
+	}
+
+	// Season indices are the average residual of each position around the linear trend, centred
+	// on zero; the trend is then refitted on the deseasonalised values
+	residuals := make([]float64, seasonLength)
+	counts := make([]int, seasonLength)
+	for _, p := range points {
+		position := p.index % seasonLength
+		residuals[position] += p.value - line(p.index)
+		counts[position]++
+	}
+	centre := 0.0
+	for position, count := range counts {
+		if count == 0 {
+			return linear()
+		}
+		residuals[position] /= float64(count)
+		centre += residuals[position] / float64(seasonLength)
+	}
+	season := func(index int) float64 {
+		return residuals[index%seasonLength] - centre
+	}
+	line = fitKPITrend(points, season)
+	return line(horizon) + season(horizon), line(1) - line(0), KPIForecastSeasonal, true
+}
+
+// kpiMeetsTarget reports whether value reaches the KPI's target in its direction. A maintain KPI
+// rarely lands exactly on its target, so outside its tolerance it still meets the target while its
+// RAG thresholds (or the default progress bands) rate the value GREEN.
+func kpiMeetsTarget(kpi map[string]interface{}, value float64) bool {
+	target := toFloat(kpi["targetValue"])
+	tolerance := math.Max(0, toFloat(kpi["tolerance"]))
+	switch kpiDirection(kpi) {
+	case KPIDirectionDecrease:
+		return value-tolerance <= target
+	case KPIDirectionMaintain:
+		return math.Abs(value-target) <= tolerance || evaluateKPI(withKPIValue(kpi, value)).RagStatus == KPIRagGreen
+	}
+	return value+tolerance >= target
+}
+
+// withKPIValue is a copy of the KPI's data with currentValue replaced
+func withKPIValue(kpi map[string]interface{}, value float64) map[string]interface{} {
+	data := make(map[string]interface{}, len(kpi)+1)
+	for k, v := range kpi {
+		data[k] = v
+	}
+	data["currentValue"] = value
+	return data
+}
+
+// kpiPeriodDate is the startDate or endDate (field) of the KPI's quarter, or of its cycle
+func kpiPeriodDate(kpi *PerformanceRecord, periods map[string]*PerformanceRecord, field string) (time.Time, bool) {
+	keys := []string{}
+	if kpi.QuarterId != "" {
+		keys = append(keys, perfSKPrefix+"QUARTER#"+kpi.QuarterId)
+	}
+	keys = append(keys, perfSKPrefix+"CYCLE#"+kpi.CycleId)
+	for _, key := range keys {
+		if period := periods[key]; period != nil {
+			if date, ok := parseTrendDate(toString(period.Data[field])); ok {
+				return date, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// buildKPITrends builds the trend series, forecasts and per-period RAG counts for kpis
+func buildKPITrends(kpis []*PerformanceRecord, readingsByKPI map[string][]kpiReading, periods map[string]*PerformanceRecord, granularity string) (kpiSeries []map[string]interface{}, kpiTrends []map[string]interface{}, departmentTrends []map[string]interface{}, projectedToMiss int) {
+	kpiSeries = make([]map[string]interface{}, 0)
+	kpiTrends = make([]map[string]interface{}, 0)
+	departmentTrends = make([]map[string]interface{}, 0)
+
+	pointsByKPI := map[string][]kpiTrendPoint{}
+	first, last := math.MaxInt, math.MinInt
+	for _, kpi := range kpis {
+		kpiID := toString(kpi.Data["id"])
+		points := bucketKPIReadings(readingsByKPI[kpiID], granularity)
+		pointsByKPI[kpiID] = points
+		if len(points) > 0 {
+			first = min(first, points[0].index)
+			last = max(last, points[len(points)-1].index)
+		}
+	}
+
+	departmentMisses := map[string]int{}
+	for _, kpi := range kpis {
+		kpiID := toString(kpi.Data["id"])
+		points := pointsByKPI[kpiID]
+
+		values := make([]float64, len(points))
+		for i, p := range points {
+			values[i] = p.value
+		}
+		rolling := rollingAverages(values)
+		series := make([]map[string]interface{}, 0, len(points))
+		for i, p := range points {
+			series = append(series, map[string]interface{}{
+				"period":         trendPeriodLabel(p.index, granularity),
+				"periodStart":    trendPeriodStart(p.index, granularity).Format("2006-01-02"),
+				"value":          p.value,
+				"average":        p.average,
+				"readings":       p.count,
+				"rollingAverage": rolling[i],
+			})
+		}
+
+		entry := map[string]interface{}{
+			"kpiId":       kpiID,
+			"name":        toString(kpi.Data["name"]),
+			"department":  toString(kpi.Data["department"]),
+			"quarterId":   kpi.QuarterId,
+			"direction":   kpiDirection(kpi.Data),
+			"targetValue": kpi.Data["targetValue"],
+			"points":      series,
+		}
+		if end, ok := kpiPeriodDate(kpi, periods, "endDate"); ok && len(points) > 0 {
+			horizon := max(trendPeriodIndex(end, granularity), points[len(points)-1].index)
+			if projected, slope, method, ok := forecastKPIValue(points, horizon, granularity); ok {
+				evaluation := evaluateKPI(withKPIValue(kpi.Data, projected))
+				forecast := KPIForecast{
+					Method:             method,
+					ForecastDate:       end.Format("2006-01-02"),
+					ProjectedValue:     math.Round(projected*100) / 100,
+					ProjectedProgress:  evaluation.Progress,
+					ProjectedRagStatus: evaluation.RagStatus,
+					SlopePerPeriod:     math.Round(slope*100) / 100,
+					ProjectedToMiss:    !kpiMeetsTarget(kpi.Data, projected),
+				}
+				entry["forecast"] = forecast
+				if forecast.ProjectedToMiss {
+					projectedToMiss++
+					if dept := toString(kpi.Data["department"]); dept != "" {
+						departmentMisses[dept]++
+					}
+				}
+			}
+		}
+		kpiSeries = append(kpiSeries, entry)
+	}
+	sort.SliceStable(kpiSeries, func(i, j int) bool {
+		return toString(kpiSeries[i]["kpiId"]) < toString(kpiSeries[j]["kpiId"])
+	})
+
+	if first > last {
+		return kpiSeries, kpiTrends, departmentTrends, projectedToMiss
+	}
+	first = max(first, last-kpiTrendMaxPeriods+1)
+
+	// Walk the periods carrying each KPI's latest value forward
+	latest := map[string]*kpiTrendPoint{}
+	cursor := map[string]int{}
+	averages := []float64{}
+	departmentPoints := map[string][]map[string]interface{}{}
+	departmentAverages := map[string][]float64{}
+	for index := first; index <= last; index++ {
+		counts := map[string]int{}
+		progressTotal := 0.0
+		withData := 0
+		departmentTotals := map[string]float64{}
+		departmentCounts := map[string]int{}
+		for _, kpi := range kpis {
+			kpiID := toString(kpi.Data["id"])
+			points := pointsByKPI[kpiID]
+			for cursor[kpiID] < len(points) && points[cursor[kpiID]].index <= index {
+				latest[kpiID] = &points[cursor[kpiID]]
+				cursor[kpiID]++
+			}
+			if latest[kpiID] == nil {
+				counts[KPIRagNoData]++
+				continue
+			}
+			evaluation := evaluateKPI(withKPIValue(kpi.Data, latest[kpiID].value))
+			counts[evaluation.RagStatus]++
+			progressTotal += evaluation.Progress
+			withData++
+			if dept := toString(kpi.Data["department"]); dept != "" {
+				departmentTotals[dept] += evaluation.Progress
+				departmentCounts[dept]++
+			}
+		}
+
+		averageProgress := 0.0
+		if withData > 0 {
+			averageProgress = roundProgress(progressTotal / float64(withData))
+		}
+		averages = append(averages, averageProgress)
+		kpiTrends = append(kpiTrends, map[string]interface{}{
+			"month":           trendPeriodStart(index, granularity).Format("Jan 2006"),
+			"period":          trendPeriodLabel(index, granularity),
+			"periodStart":     trendPeriodStart(index, granularity).Format("2006-01-02"),
+			"onTrack":         counts[KPIRagGreen],
+			"atRisk":          counts[KPIRagAmber],
+			"behind":          counts[KPIRagRed],
+			"noData":          counts[KPIRagNoData],
+			"averageProgress": averageProgress,
+			"rollingAverage":  roundProgress(rollingAverages(averages)[len(averages)-1]),
+		})
+
+		for dept, total := range departmentTotals {
+			average := roundProgress(total / float64(departmentCounts[dept]))
+			departmentAverages[dept] = append(departmentAverages[dept], average)
+			departmentPoints[dept] = append(departmentPoints[dept], map[string]interface{}{
+				"period":          trendPeriodLabel(index, granularity),
+				"periodStart":     trendPeriodStart(index, granularity).Format("2006-01-02"),
+				"averageProgress": average,
+				"kpiCount":        departmentCounts[dept],
+				"rollingAverage":  roundProgress(rollingAverages(departmentAverages[dept])[len(departmentAverages[dept])-1]),
+			})
+		}
+	}
+
+	for dept, points := range departmentPoints {
+		departmentTrends = append(departmentTrends, map[string]interface{}{
+			"department":          dept,
+			"points":              points,
+			"kpisProjectedToMiss": departmentMisses[dept],
+		})
+	}
+	sort.SliceStable(departmentTrends, func(i, j int) bool {
+		return toString(departmentTrends[i]["department"]) < toString(departmentTrends[j]["department"])
+	})
+	return kpiSeries, kpiTrends, departmentTrends, projectedToMiss
+}
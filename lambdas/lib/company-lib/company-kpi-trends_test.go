@@ -0,0 +1,153 @@
+package Companylib
+
+import (
+	"testing"
+	"time"
+
+	awsclients "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/clients"
+	"github.com/stretchr/testify/assert"
+)
+
+func kpiValueRecord(kpiID string, date string, value float64) PerformanceRecord {
+	return PerformanceRecord{
+		EntityType: perfEntityKPIValue, CycleId: "c-1", ParentId: kpiID,
+		Data: map[string]interface{}{"kpiId": kpiID, "date": date, "value": value},
+	}
+}
+
+func TestNormalizeTrendGranularity(t *testing.T) {
+	granularity, err := normalizeTrendGranularity("")
+	assert.NoError(t, err)
+	assert.Equal(t, KPITrendGranularityMonth, granularity)
+
+	granularity, err = normalizeTrendGranularity("Week")
+	assert.NoError(t, err)
+	assert.Equal(t, KPITrendGranularityWeek, granularity)
+
+	_, err = normalizeTrendGranularity("day")
+	assert.ErrorIs(t, err, ErrTrendGranularityInvalid)
+}
+
+func TestTrendPeriods(t *testing.T) {
+	date := time.Date(2027, 2, 10, 0, 0, 0, 0, time.UTC) // a Wednesday
+
+	month := trendPeriodIndex(date, KPITrendGranularityMonth)
+	assert.Equal(t, "2027-02", trendPeriodLabel(month, KPITrendGranularityMonth))
+	assert.Equal(t, "2027-02-01", trendPeriodStart(month, KPITrendGranularityMonth).Format("2006-01-02"))
+	assert.Equal(t, month+1, trendPeriodIndex(date.AddDate(0, 1, 0), KPITrendGranularityMonth))
+
+	week := trendPeriodIndex(date, KPITrendGranularityWeek)
+	assert.Equal(t, "2027-W06", trendPeriodLabel(week, KPITrendGranularityWeek))
+	assert.Equal(t, "2027-02-08", trendPeriodStart(week, KPITrendGranularityWeek).Format("2006-01-02"))
+	assert.Equal(t, week, trendPeriodIndex(date.AddDate(0, 0, 4), KPITrendGranularityWeek)) // Sunday
+	assert.Equal(t, week+1, trendPeriodIndex(date.AddDate(0, 0, 5), KPITrendGranularityWeek))
+}
+
+func TestRollingAverages(t *testing.T) {
+	assert.Equal(t, []float64{10, 15, 20, 30}, rollingAverages([]float64{10, 20, 30, 40}))
+}
+
+func TestForecastKPIValue(t *testing.T) {
+	t.Run("It should need two periods", func(t *testing.T) {
+		_, _, _, ok := forecastKPIValue([]kpiTrendPoint{{index: 10, value: 5}}, 12, KPITrendGranularityMonth)
+		assert.False(t, ok)
+	})
+
+	t.Run("It should extend the linear trend", func(t *testing.T) {
+		points := []kpiTrendPoint{{index: 10, value: 10}, {index: 11, value: 20}, {index: 13, value: 40}}
+		projected, slope, method, ok := forecastKPIValue(points, 15, KPITrendGranularityMonth)
+		assert.True(t, ok)
+		assert.Equal(t, KPIForecastLinear, method)
+		assert.InDelta(t, 10.0, slope, 0.0001)
+		assert.InDelta(t, 60.0, projected, 0.0001)
+	})
+
+	t.Run("It should add the seasonal residual over two full seasons", func(t *testing.T) {
+		// flat at 100 with a +30 spike in the last month of each quarter
+		points := []kpiTrendPoint{}
+		for index := 24000; index < 24006; index++ {
+			value := 100.0
+			if index%kpiSeasonLengthMonths == 2 {
+				value = 130
+			}
+			points = append(points, kpiTrendPoint{index: index, value: value})
+		}
+		projected, _, method, ok := forecastKPIValue(points, 24008, KPITrendGranularityMonth)
+		assert.True(t, ok)
+		assert.Equal(t, KPIForecastSeasonal, method)
+		assert.InDelta(t, 130.0, projected, 1)
+	})
+}
+
+func TestKPIMeetsTarget(t *testing.T) {
+	assert.True(t, kpiMeetsTarget(map[string]interface{}{"targetValue": 100.0}, 100))
+	assert.False(t, kpiMeetsTarget(map[string]interface{}{"targetValue": 100.0}, 95))
+	assert.True(t, kpiMeetsTarget(map[string]interface{}{"targetValue": 100.0, "tolerance": 5.0}, 95))
+	assert.True(t, kpiMeetsTarget(map[string]interface{}{"direction": "decrease", "targetValue": 2.0}, 1.5))
+	assert.True(t, kpiMeetsTarget(map[string]interface{}{"direction": "maintain", "targetValue": 50.0, "tolerance": 2.0}, 52))
+	// without a tolerance a maintain KPI falls back to its RAG thresholds, or the default bands
+	assert.True(t, kpiMeetsTarget(map[string]interface{}{"direction": "maintain", "targetValue": 50.0}, 50.5))
+	assert.False(t, kpiMeetsTarget(map[string]interface{}{"direction": "maintain", "targetValue": 50.0}, 40))
+	assert.False(t, kpiMeetsTarget(map[string]interface{}{"direction": "maintain", "targetValue": 50.0, "greenThreshold": 2.0}, 53))
+}
+
+func TestBuildPerformanceAnalyticsKPITrends(t *testing.T) {
+	cycle := PerformanceRecord{
+		GSI1PK: "PERF#CYCLE#c-1", EntityType: perfEntityCycle, OrganizationId: "ORG#org-1",
+		Data: map[string]interface{}{"id": "c-1", "startDate": "2027-01-01", "endDate": "2027-06-30"},
+	}
+	related := []PerformanceRecord{
+		{EntityType: perfEntityKPI, CycleId: "c-1", Data: map[string]interface{}{
+			"id": "kpi-revenue", "name": "Revenue", "department": "Sales", "targetValue": 100.0, "currentValue": 40.0,
+		}},
+		{EntityType: perfEntityKPI, CycleId: "c-1", Data: map[string]interface{}{
+			"id": "kpi-churn", "name": "Churn", "department": "Sales", "direction": "decrease", "targetValue": 2.0, "currentValue": 2.5,
+		}},
+		{EntityType: perfEntityKPI, CycleId: "c-1", Data: map[string]interface{}{"id": "kpi-new", "targetValue": 10.0}},
+		kpiValueRecord("kpi-revenue", "2027-01-15", 10),
+		kpiValueRecord("kpi-revenue", "2027-01-31", 20),
+		kpiValueRecord("kpi-revenue", "2027-03-05", 40),
+		kpiValueRecord("kpi-churn", "2027-02-01", 4),
+		kpiValueRecord("kpi-churn", "2027-03-01", 2.5),
+	}
+	svc := newOKRTestService(&awsclients.MockDynamodbClient{})
+
+	analytics := svc.buildPerformanceAnalytics(&cycle, related, "", KPITrendGranularityMonth)
+
+	summary := analytics["summary"].(map[string]interface{})
+	assert.Equal(t, 1, summary["kpisProjectedToMiss"])
+
+	trends := analytics["kpiTrends"].([]map[string]interface{})
+	assert.Len(t, trends, 3)
+	assert.Equal(t, "2027-01", trends[0]["period"])
+	assert.Equal(t, "Jan 2027", trends[0]["month"])
+	assert.Equal(t, 2, trends[0]["noData"])
+	assert.Equal(t, "2027-03", trends[2]["period"])
+	assert.Equal(t, 1, trends[2]["noData"])
+
+	series := analytics["kpiSeries"].([]map[string]interface{})
+	assert.Len(t, series, 3)
+	churn, revenue := series[0], series[2]
+	assert.Equal(t, "kpi-revenue", revenue["kpiId"])
+	revenuePoints := revenue["points"].([]map[string]interface{})
+	assert.Len(t, revenuePoints, 2)
+	assert.Equal(t, 20.0, revenuePoints[0]["value"])
+	assert.Equal(t, 15.0, revenuePoints[0]["average"])
+	assert.Equal(t, 30.0, revenuePoints[1]["rollingAverage"])
+
+	// 20 in January and 40 in March project to 70 by June
+	revenueForecast := revenue["forecast"].(KPIForecast)
+	assert.Equal(t, "2027-06-30", revenueForecast.ForecastDate)
+	assert.Equal(t, 70.0, revenueForecast.ProjectedValue)
+	assert.True(t, revenueForecast.ProjectedToMiss)
+
+	// churn falling 1.5 a month reaches its target
+	assert.False(t, churn["forecast"].(KPIForecast).ProjectedToMiss)
+	assert.NotContains(t, series[1], "forecast")
+
+	departments := analytics["departmentTrends"].([]map[string]interface{})
+	assert.Len(t, departments, 1)
+	assert.Equal(t, "Sales", departments[0]["department"])
+	assert.Equal(t, 1, departments[0]["kpisProjectedToMiss"])
+	assert.Len(t, departments[0]["points"], 3)
+}
@@ -0,0 +1,425 @@
+package Companylib
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// A ladder-up is a team's proposal to contribute to an org goal, optionally through one of the
+// submitter's own goals. It moves through
+//
+//	PENDING → APPROVED | REJECTED
+//	REJECTED → RESUBMITTED → APPROVED | REJECTED
+//
+// and APPROVED is final. Every transition is written together with a GOAL_LADDER_UP_DECISION
+// entry in one transaction, conditioned on the status it moved from, so concurrent decisions fail
+// instead of overwriting each other. Decision entries are numbered per ladder-up and never updated.
+
+const (
+	LadderUpStatusPending     = "PENDING"
+	LadderUpStatusApproved    = "APPROVED"
+	LadderUpStatusRejected    = "REJECTED"
+	LadderUpStatusResubmitted = "RESUBMITTED"
+
+	LadderUpActionSubmitted   = "SUBMITTED"
+	LadderUpActionApproved    = "APPROVED"
+	LadderUpActionRejected    = "REJECTED"
+	LadderUpActionResubmitted = "RESUBMITTED"
+
+	perfEntityLadderUpDecision = "GOAL_LADDER_UP_DECISION"
+	ladderUpMaxTitleLength     = 200
+	ladderUpMaxTextLength      = 2000
+)
+
+var (
+	ErrLadderUpInvalid  = errors.New("invalid ladder-up")
+	ErrLadderUpNotFound = errors.New("ladder-up not found")
+	ErrLadderUpConflict = errors.New("ladder-up status has changed")
+)
+
+// ladderUpTransitions lists the statuses each ladder-up status can move to
+var ladderUpTransitions = map[string][]string{
+	LadderUpStatusPending:     {LadderUpStatusApproved, LadderUpStatusRejected},
+	LadderUpStatusRejected:    {LadderUpStatusResubmitted},
+	LadderUpStatusResubmitted: {LadderUpStatusApproved, LadderUpStatusRejected},
+}
+
+// CanTransitionLadderUp reports whether a ladder-up in status from may move to status to
+func CanTransitionLadderUp(from string, to string) bool {
+	for _, next := range ladderUpTransitions[ladderUpStatus(from)] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ladderUpStatus normalises a stored status; ladder-ups recorded before the workflow have none
+func ladderUpStatus(status string) string {
+	status = strings.ToUpper(strings.TrimSpace(status))
+	if status == "" {
+		return LadderUpStatusPending
+	}
+	return status
+}
+
+// IsLadderUpAwaitingDecision reports whether an approver can act on the ladder-up
+func IsLadderUpAwaitingDecision(status string) bool {
+	return CanTransitionLadderUp(status, LadderUpStatusApproved)
+}
+
+// ladderUpText reads an optional free-text field, trimmed and bounded
+func ladderUpText(input map[string]interface{}, field string, limit int) (string, error) {
+	value, ok := input[field]
+	if !ok || value == nil {
+		return "", nil
+	}
+	text, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("%w: %s must be a string", ErrLadderUpInvalid, field)
+	}
+	text = strings.TrimSpace(text)
+	if len(text) > limit {
+		return "", fmt.Errorf("%w: %s must be at most %d characters", ErrLadderUpInvalid, field, limit)
+	}
+	return text, nil
+}
+
+// GetLadderUp returns a ladder-up by ID
+func (svc *PerformanceService) GetLadderUp(ladderUpID string) (map[string]interface{}, error) {
+	rec, err := svc.getLadderUpRecord(ladderUpID)
+	if err != nil {
+		return nil, err
+	}
+	return svc.ladderUpPayload(rec), nil
+}
+
+func (svc *PerformanceService) getLadderUpRecord(ladderUpID string) (*PerformanceRecord, error) {
+	rec, err := svc.getRecordByGSI1(perfSKPrefix + "LADDER#" + ladderUpID)
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil || rec.EntityType != perfEntityLadderUp {
+		return nil, ErrLadderUpNotFound
+	}
+	return rec, nil
+}
+
+func (svc *PerformanceService) ladderUpPayload(rec *PerformanceRecord) map[string]interface{} {
+	payload := svc.toPayload(rec)
+	payload["status"] = ladderUpStatus(toString(payload["status"]))
+	if toString(payload["goalId"]) == "" {
+		payload["goalId"] = rec.ParentId
+	}
+	return payload
+}
+
+// SubmitLadderUp records a team's proposal to contribute to an org goal. The caller is responsible
+// for checking that the submitter belongs to the team and owns the linked goal.
+func (svc *PerformanceService) SubmitLadderUp(goalID string, input map[string]interface{}, submittedBy string) (map[string]interface{}, error) {
+	_, baseRec, goalType, err := svc.findGoalBase(goalID)
+	if err != nil {
+		return nil, err
+	}
+
+	title, err := ladderUpText(input, "title", ladderUpMaxTitleLength)
+	if err != nil {
+		return nil, err
+	}
+	if title == "" {
+		return nil, fmt.Errorf("%w: title is required", ErrLadderUpInvalid)
+	}
+	teamID, err := ladderUpText(input, "teamId", ladderUpMaxTitleLength)
+	if err != nil {
+		return nil, err
+	}
+	if teamID == "" {
+		return nil, fmt.Errorf("%w: teamId is required", ErrLadderUpInvalid)
+	}
+	description, err := ladderUpText(input, "description", ladderUpMaxTextLength)
+	if err != nil {
+		return nil, err
+	}
+	userGoalID, err := ladderUpText(input, "userGoalId", ladderUpMaxTitleLength)
+	if err != nil {
+		return nil, err
+	}
+
+	ladderUpID := svc.generateID("ladder-up")
+	now := svc.now()
+	data := map[string]interface{}{
+		"id":            ladderUpID,
+		"goalId":        goalID,
+		"goalType":      goalType,
+		"title":         title,
+		"teamId":        teamID,
+		"status":        LadderUpStatusPending,
+		"submittedBy":   submittedBy,
+		"submittedAt":   now,
+		"revision":      1,
+		"decisionCount": 0,
+		"createdAt":     now,
+		"updatedAt":     now,
+	}
+	if description != "" {
+		data["description"] = description
+	}
+	if userGoalID != "" {
+		data["userGoalId"] = userGoalID
+	}
+
+	record := PerformanceRecord{
+		PK:             baseRec.OrganizationId,
+		SK:             fmt.Sprintf("%sGOAL#%s#LADDER#%s", perfSKPrefix, goalID, ladderUpID),
+		GSI1PK:         fmt.Sprintf("%sLADDER#%s", perfSKPrefix, ladderUpID),
+		GSI1SK:         baseRec.OrganizationId,
+		EntityType:     perfEntityLadderUp,
+		OrganizationId: baseRec.OrganizationId,
+		CycleId:        baseRec.CycleId,
+		ParentId:       goalID,
+		Owner:          submittedBy,
+		Status:         LadderUpStatusPending,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		Data:           data,
+	}
+	if err := svc.transitionLadderUp(&record, "", LadderUpActionSubmitted, "", submittedBy); err != nil {
+		return nil, err
+	}
+	return svc.ladderUpPayload(&record), nil
+}
+
+// ResubmitLadderUp sends a rejected ladder-up back for a decision. The title, description and
+// linked goal may be revised; the team and org goal stay the same.
+func (svc *PerformanceService) ResubmitLadderUp(ladderUpID string, input map[string]interface{}, resubmittedBy string) (map[string]interface{}, error) {
+	rec, err := svc.getLadderUpRecord(ladderUpID)
+	if err != nil {
+		return nil, err
+	}
+	from := ladderUpStatus(rec.Status)
+	if !CanTransitionLadderUp(from, LadderUpStatusResubmitted) {
+		return nil, fmt.Errorf("%w: a %s ladder-up cannot be resubmitted", ErrLadderUpConflict, from)
+	}
+
+	for _, field := range []string{"teamId", "goalId"} {
+		if value, ok := input[field]; ok && toString(value) != toString(rec.Data[field]) {
+			return nil, fmt.Errorf("%w: %s cannot change on resubmission", ErrLadderUpInvalid, field)
+		}
+	}
+	title, err := ladderUpText(input, "title", ladderUpMaxTitleLength)
+	if err != nil {
+		return nil, err
+	}
+	description, err := ladderUpText(input, "description", ladderUpMaxTextLength)
+	if err != nil {
+		return nil, err
+	}
+	userGoalID, err := ladderUpText(input, "userGoalId", ladderUpMaxTitleLength)
+	if err != nil {
+		return nil, err
+	}
+	comment, err := ladderUpText(input, "comment", ladderUpMaxTextLength)
+	if err != nil {
+		return nil, err
+	}
+
+	if title != "" {
+		rec.Data["title"] = title
+	}
+	if _, ok := input["description"]; ok {
+		rec.Data["description"] = description
+	}
+	if userGoalID != "" {
+		rec.Data["userGoalId"] = userGoalID
+	}
+	for _, field := range []string{"decidedBy", "decidedAt", "decisionComment", "rejectionReason"} {
+		delete(rec.Data, field)
+	}
+	now := svc.now()
+	rec.Data["status"] = LadderUpStatusResubmitted
+	rec.Data["revision"] = int(toFloat(rec.Data["revision"])) + 1
+	rec.Data["resubmittedAt"] = now
+	rec.Data["updatedAt"] = now
+
+	if err := svc.transitionLadderUp(rec, from, LadderUpActionResubmitted, comment, resubmittedBy); err != nil {
+		return nil, err
+	}
+	return svc.ladderUpPayload(rec), nil
+}
+
+// ApproveLadderUp accepts a pending or resubmitted ladder-up, with an optional comment
+func (svc *PerformanceService) ApproveLadderUp(ladderUpID string, input map[string]interface{}, decidedBy string) (map[string]interface{}, error) {
+	return svc.decideLadderUp(ladderUpID, LadderUpStatusApproved, input, decidedBy)
+}
+
+// RejectLadderUp turns down a pending or resubmitted ladder-up; a reason is required
+func (svc *PerformanceService) RejectLadderUp(ladderUpID string, input map[string]interface{}, decidedBy string) (map[string]interface{}, error) {
+	return svc.decideLadderUp(ladderUpID, LadderUpStatusRejected, input, decidedBy)
+}
+
+func (svc *PerformanceService) decideLadderUp(ladderUpID string, to string, input map[string]interface{}, decidedBy string) (map[string]interface{}, error) {
+	rec, err := svc.getLadderUpRecord(ladderUpID)
+	if err != nil {
+		return nil, err
+	}
+	from := ladderUpStatus(rec.Status)
+	if !CanTransitionLadderUp(from, to) {
+		return nil, fmt.Errorf("%w: a %s ladder-up cannot be %s", ErrLadderUpConflict, from, strings.ToLower(to))
+	}
+
+	field, action := "comment", LadderUpActionApproved
+	if to == LadderUpStatusRejected {
+		field, action = "reason", LadderUpActionRejected
+	}
+	text, err := ladderUpText(input, field, ladderUpMaxTextLength)
+	if err != nil {
+		return nil, err
+	}
+	if to == LadderUpStatusRejected && text == "" {
+		return nil, fmt.Errorf("%w: a reason is required to reject a ladder-up", ErrLadderUpInvalid)
+	}
+
+	now := svc.now()
+	rec.Data["status"] = to
+	rec.Data["decidedBy"] = decidedBy
+	rec.Data["decidedAt"] = now
+	rec.Data["updatedAt"] = now
+	delete(rec.Data, "decisionComment")
+	delete(rec.Data, "rejectionReason")
+	if to == LadderUpStatusRejected {
+		rec.Data["rejectionReason"] = text
+	} else if text != "" {
+		rec.Data["decisionComment"] = text
+	}
+
+	if err := svc.transitionLadderUp(rec, from, action, text, decidedBy); err != nil {
+		return nil, err
+	}
+	return svc.ladderUpPayload(rec), nil
+}
+
+// transitionLadderUp writes the ladder-up in its new status with the matching decision entry.
+// from is the status it is moving out of, or empty for a new submission.
+func (svc *PerformanceService) transitionLadderUp(rec *PerformanceRecord, from string, action string, note string, actor string) error {
+	sequence := int(toFloat(rec.Data["decisionCount"])) + 1
+	ladderUpID := toString(rec.Data["id"])
+	to := toString(rec.Data["status"])
+	rec.Status = to
+	rec.UpdatedAt = toString(rec.Data["updatedAt"])
+	rec.Data["decisionCount"] = sequence
+
+	entry := map[string]interface{}{
+		"id":         fmt.Sprintf("%s-%04d", ladderUpID, sequence),
+		"ladderUpId": ladderUpID,
+		"goalId":     rec.ParentId,
+		"sequence":   sequence,
+		"action":     action,
+		"toStatus":   to,
+		"revision":   int(toFloat(rec.Data["revision"])),
+		"actor":      actor,
+		"at":         rec.UpdatedAt,
+	}
+	if from != "" {
+		entry["fromStatus"] = from
+	}
+	if note != "" {
+		entry["note"] = note
+	}
+	decision := PerformanceRecord{
+		PK:             rec.PK,
+		SK:             fmt.Sprintf("%sGOAL#%s#LADDERLOG#%s#%04d", perfSKPrefix, rec.ParentId, ladderUpID, sequence),
+		GSI1PK:         fmt.Sprintf("%sLADDER_DECISION#%s", perfSKPrefix, toString(entry["id"])),
+		GSI1SK:         rec.OrganizationId,
+		EntityType:     perfEntityLadderUpDecision,
+		OrganizationId: rec.OrganizationId,
+		CycleId:        rec.CycleId,
+		ParentId:       ladderUpID,
+		Owner:          actor,
+		Status:         to,
+		CreatedAt:      rec.UpdatedAt,
+		UpdatedAt:      rec.UpdatedAt,
+		Data:           entry,
+	}
+
+	ladderItem, err := attributevalue.MarshalMap(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal ladder-up: %w", err)
+	}
+	decisionItem, err := attributevalue.MarshalMap(decision)
+	if err != nil {
+		return fmt.Errorf("failed to marshal ladder-up decision: %w", err)
+	}
+
+	ladderPut := &types.Put{TableName: aws.String(svc.performanceTableName()), Item: ladderItem}
+	switch {
+	case from == "":
+		ladderPut.ConditionExpression = aws.String("attribute_not_exists(SK)")
+	case from == LadderUpStatusPending:
+		// ladder-ups recorded before the workflow have no status and count as pending
+		ladderPut.ConditionExpression = aws.String("attribute_not_exists(#status) OR #status = :from")
+	default:
+		ladderPut.ConditionExpression = aws.String("#status = :from")
+	}
+	if from != "" {
+		ladderPut.ExpressionAttributeNames = map[string]string{"#status": "Status"}
+		ladderPut.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":from": &types.AttributeValueMemberS{Value: from},
+		}
+	}
+
+	_, err = svc.dynamodbClient.TransactWriteItems(svc.ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{Put: ladderPut},
+			{Put: &types.Put{
+				TableName:           aws.String(svc.performanceTableName()),
+				Item:                decisionItem,
+				ConditionExpression: aws.String("attribute_not_exists(SK)"),
+			}},
+		},
+	})
+	if err != nil {
+		var cancelled *types.TransactionCanceledException
+		if errors.As(err, &cancelled) {
+			for _, reason := range cancelled.CancellationReasons {
+				if aws.ToString(reason.Code) == "ConditionalCheckFailed" {
+					return fmt.Errorf("%w: ladder-up %s was changed by someone else", ErrLadderUpConflict, ladderUpID)
+				}
+			}
+		}
+		return fmt.Errorf("failed to record ladder-up %s: %w", strings.ToLower(action), err)
+	}
+	return nil
+}
+
+// GetLadderUpHistory returns the ladder-up with its decision log, oldest entry first
+func (svc *PerformanceService) GetLadderUpHistory(ladderUpID string) (map[string]interface{}, error) {
+	rec, err := svc.getLadderUpRecord(ladderUpID)
+	if err != nil {
+		return nil, err
+	}
+	records, err := svc.queryByOrgPrefix(rec.OrganizationId, fmt.Sprintf("%sGOAL#%s#LADDERLOG#%s#", perfSKPrefix, rec.ParentId, ladderUpID))
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].SK < records[j].SK
+	})
+	decisions := make([]map[string]interface{}, 0, len(records))
+	for i := range records {
+		if records[i].EntityType != perfEntityLadderUpDecision {
+			continue
+		}
+		decisions = append(decisions, records[i].Data)
+	}
+	return map[string]interface{}{
+		"ladderUp":  svc.ladderUpPayload(rec),
+		"decisions": decisions,
+	}, nil
+}
@@ -0,0 +1,183 @@
+package Companylib
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodb_types "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	awsclients "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/clients"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanTransitionLadderUp(t *testing.T) {
+	assert.True(t, CanTransitionLadderUp(LadderUpStatusPending, LadderUpStatusApproved))
+	assert.True(t, CanTransitionLadderUp("", LadderUpStatusRejected)) // recorded before the workflow
+	assert.True(t, CanTransitionLadderUp(LadderUpStatusRejected, LadderUpStatusResubmitted))
+	assert.True(t, CanTransitionLadderUp(LadderUpStatusResubmitted, LadderUpStatusApproved))
+
+	assert.False(t, CanTransitionLadderUp(LadderUpStatusPending, LadderUpStatusResubmitted))
+	assert.False(t, CanTransitionLadderUp(LadderUpStatusRejected, LadderUpStatusApproved))
+	assert.False(t, CanTransitionLadderUp(LadderUpStatusApproved, LadderUpStatusRejected))
+}
+
+func ladderUpTestRecord(status string) PerformanceRecord {
+	return PerformanceRecord{
+		PK: "ORG#org-1", SK: "PERF#GOAL#kpi-1#LADDER#lu-1", GSI1PK: "PERF#LADDER#lu-1",
+		EntityType: perfEntityLadderUp, OrganizationId: "ORG#org-1", ParentId: "kpi-1", Status: status,
+		Data: map[string]interface{}{
+			"id": "lu-1", "goalId": "kpi-1", "title": "Close 20 renewals", "teamId": "t-sales",
+			"status": status, "submittedBy": "ann@example.com", "revision": 1.0, "decisionCount": 1.0,
+		},
+	}
+}
+
+func transactRecord(t *testing.T, input dynamodb.TransactWriteItemsInput, index int) PerformanceRecord {
+	var record PerformanceRecord
+	assert.NoError(t, attributevalue.UnmarshalMap(input.TransactItems[index].Put.Item, &record))
+	return record
+}
+
+func TestDecideLadderUp(t *testing.T) {
+	t.Run("It should require a reason to reject", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs: []dynamodb.QueryOutput{reviewQueryOutput(t, ladderUpTestRecord(LadderUpStatusPending))},
+			QueryErrors:  []error{nil},
+		}
+		svc := newOKRTestService(&ddbClient)
+
+		_, err := svc.RejectLadderUp("lu-1", map[string]interface{}{"reason": "  "}, "owner@example.com")
+
+		assert.ErrorIs(t, err, ErrLadderUpInvalid)
+		assert.Empty(t, ddbClient.TransactWriteItemsInputs)
+	})
+
+	t.Run("It should record the rejection with its decision entry", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs:             []dynamodb.QueryOutput{reviewQueryOutput(t, ladderUpTestRecord(LadderUpStatusPending))},
+			QueryErrors:              []error{nil},
+			TransactWriteItemsOutput: []dynamodb.TransactWriteItemsOutput{{}},
+			TransactWriteItemsErrors: []error{nil},
+		}
+		svc := newOKRTestService(&ddbClient)
+
+		result, err := svc.RejectLadderUp("lu-1", map[string]interface{}{"reason": "Not in scope this cycle"}, "owner@example.com")
+
+		assert.NoError(t, err)
+		assert.Equal(t, LadderUpStatusRejected, result["status"])
+		assert.Equal(t, "Not in scope this cycle", result["rejectionReason"])
+		assert.Equal(t, "owner@example.com", result["decidedBy"])
+
+		input := ddbClient.TransactWriteItemsInputs[0]
+		assert.Equal(t, "attribute_not_exists(#status) OR #status = :from", aws.ToString(input.TransactItems[0].Put.ConditionExpression))
+		assert.Equal(t, "attribute_not_exists(SK)", aws.ToString(input.TransactItems[1].Put.ConditionExpression))
+
+		decision := transactRecord(t, input, 1)
+		assert.Equal(t, perfEntityLadderUpDecision, decision.EntityType)
+		assert.Equal(t, "PERF#GOAL#kpi-1#LADDERLOG#lu-1#0002", decision.SK)
+		assert.Equal(t, LadderUpActionRejected, decision.Data["action"])
+		assert.Equal(t, LadderUpStatusPending, decision.Data["fromStatus"])
+		assert.Equal(t, "Not in scope this cycle", decision.Data["note"])
+	})
+
+	t.Run("It should not approve a rejected ladder-up", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs: []dynamodb.QueryOutput{reviewQueryOutput(t, ladderUpTestRecord(LadderUpStatusRejected))},
+			QueryErrors:  []error{nil},
+		}
+		svc := newOKRTestService(&ddbClient)
+
+		_, err := svc.ApproveLadderUp("lu-1", map[string]interface{}{}, "owner@example.com")
+
+		assert.ErrorIs(t, err, ErrLadderUpConflict)
+	})
+
+	t.Run("It should report a concurrent decision as a conflict", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs:             []dynamodb.QueryOutput{reviewQueryOutput(t, ladderUpTestRecord(LadderUpStatusResubmitted))},
+			QueryErrors:              []error{nil},
+			TransactWriteItemsOutput: []dynamodb.TransactWriteItemsOutput{{}},
+			TransactWriteItemsErrors: []error{&dynamodb_types.TransactionCanceledException{
+				CancellationReasons: []dynamodb_types.CancellationReason{{Code: aws.String("ConditionalCheckFailed")}, {Code: aws.String("None")}},
+			}},
+		}
+		svc := newOKRTestService(&ddbClient)
+
+		_, err := svc.ApproveLadderUp("lu-1", map[string]interface{}{"comment": "Good fit"}, "owner@example.com")
+
+		assert.ErrorIs(t, err, ErrLadderUpConflict)
+		assert.Equal(t, "#status = :from", aws.ToString(ddbClient.TransactWriteItemsInputs[0].TransactItems[0].Put.ConditionExpression))
+	})
+}
+
+func TestResubmitLadderUp(t *testing.T) {
+	rejected := ladderUpTestRecord(LadderUpStatusRejected)
+	rejected.Data["rejectionReason"] = "Too vague"
+	rejected.Data["decisionCount"] = 2.0
+
+	t.Run("It should keep the team", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs: []dynamodb.QueryOutput{reviewQueryOutput(t, rejected)},
+			QueryErrors:  []error{nil},
+		}
+		svc := newOKRTestService(&ddbClient)
+
+		_, err := svc.ResubmitLadderUp("lu-1", map[string]interface{}{"teamId": "t-ops"}, "ann@example.com")
+
+		assert.ErrorIs(t, err, ErrLadderUpInvalid)
+	})
+
+	t.Run("It should start a new revision", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs:             []dynamodb.QueryOutput{reviewQueryOutput(t, rejected)},
+			QueryErrors:              []error{nil},
+			TransactWriteItemsOutput: []dynamodb.TransactWriteItemsOutput{{}},
+			TransactWriteItemsErrors: []error{nil},
+		}
+		svc := newOKRTestService(&ddbClient)
+
+		result, err := svc.ResubmitLadderUp("lu-1", map[string]interface{}{"title": "Close 20 enterprise renewals"}, "ann@example.com")
+
+		assert.NoError(t, err)
+		assert.Equal(t, LadderUpStatusResubmitted, result["status"])
+		assert.Equal(t, "Close 20 enterprise renewals", result["title"])
+		assert.Equal(t, 2, result["revision"])
+		assert.NotContains(t, result, "rejectionReason")
+
+		decision := transactRecord(t, ddbClient.TransactWriteItemsInputs[0], 1)
+		assert.Equal(t, "PERF#GOAL#kpi-1#LADDERLOG#lu-1#0003", decision.SK)
+		assert.Equal(t, LadderUpActionResubmitted, decision.Data["action"])
+		assert.Equal(t, 2.0, decision.Data["revision"])
+	})
+}
+
+func TestGetGoalDetailsOwnerAndTitle(t *testing.T) {
+	t.Run("It should return an OKR's objective owner and objective", func(t *testing.T) {
+		okr := PerformanceRecord{
+			PK: "ORG#org-1", SK: "PERF#OKR#okr-1", GSI1PK: "PERF#OKR#okr-1",
+			EntityType: perfEntityOKR, OrganizationId: "ORG#org-1",
+			Data: map[string]interface{}{"id": "okr-1", "objective": "Grow renewals", "objectiveOwner": "olga@example.com"},
+		}
+		ddbClient := awsclients.MockDynamodbClient{
+			// Goals are looked up as a KPI first, then the OKR's cycle and key results are read
+			QueryOutputs: []dynamodb.QueryOutput{{}, reviewQueryOutput(t, okr), {}, {}},
+			QueryErrors:  []error{nil, nil, nil, nil},
+		}
+		svc := newOKRTestService(&ddbClient)
+
+		goal, err := svc.GetGoalDetails("okr-1", false, false, false, false, false, "olga@example.com")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "okr", goal["type"])
+		assert.Equal(t, "olga@example.com", goal["owner"])
+		assert.Equal(t, "Grow renewals", goal["name"])
+	})
+
+	t.Run("It should return a KPI's owner and name", func(t *testing.T) {
+		owner, title := goalOwnerAndTitle("kpi", map[string]interface{}{"name": "Renewals", "owner": "kim@example.com"})
+
+		assert.Equal(t, "kim@example.com", owner)
+		assert.Equal(t, "Renewals", title)
+	})
+}
@@ -0,0 +1,631 @@
+package Companylib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+
+	awsclients "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/clients"
+)
+
+// NotificationType identifies what happened to the recipient
+type NotificationType string
+
+const (
+	NotificationTypeMention      NotificationType = "MENTION"
+	NotificationTypeLike         NotificationType = "LIKE"
+	NotificationTypeComment      NotificationType = "COMMENT"
+	NotificationTypeTaskAssigned NotificationType = "TASK_ASSIGNED"
+	NotificationTypeKudos        NotificationType = "KUDOS"
+
+	NotificationTypeEventReminder      NotificationType = "EVENT_REMINDER"
+	NotificationTypeEventSpotConfirmed NotificationType = "EVENT_SPOT_CONFIRMED"
+
+	NotificationTypeFeedbackRequested NotificationType = "FEEDBACK_REQUESTED"
+	NotificationTypeFeedbackReminder  NotificationType = "FEEDBACK_REMINDER"
+	NotificationTypeFeedbackReceived  NotificationType = "FEEDBACK_RECEIVED"
+	NotificationTypeFeedbackDeclined  NotificationType = "FEEDBACK_DECLINED"
+	NotificationTypeFeedbackExpired   NotificationType = "FEEDBACK_EXPIRED"
+
+	NotificationTypeKPIValueDue NotificationType = "KPI_VALUE_DUE"
+
+	NotificationTypeLadderUpSubmitted NotificationType = "LADDER_UP_SUBMITTED"
+	NotificationTypeLadderUpApproved  NotificationType = "LADDER_UP_APPROVED"
+	NotificationTypeLadderUpRejected  NotificationType = "LADDER_UP_REJECTED"
+)
+
+const (
+	// NotificationRetention is how long notifications are kept before DynamoDB TTL removes them
+	NotificationRetention = 90 * 24 * time.Hour
+
+	// DigestFrequencyDaily is the only digest schedule. It is stored as the DigestIndex partition
+	// key while a user has the digest switched on, so the index only holds subscribers.
+	DigestFrequencyDaily = "DAILY"
+
+	// MaxNotificationIdsPerRequest bounds a single mark-read call
+	MaxNotificationIdsPerRequest = 100
+
+	notificationSKPrefix     = "NOTIF#"
+	notificationPrefsSK      = "PREFERENCES"
+	maxNotificationQueries   = 10
+	maxDigestNotifications   = 20
+	notificationBatchMax     = 25
+	notificationBatchRetries = 5
+)
+
+var ErrInvalidNotificationCursor = errors.New("invalid notification cursor")
+
+// Notification is one entry in a user's inbox. Notification IDs start with the creation time in
+// milliseconds so the inbox sorts newest first on SK.
+type Notification struct {
+	PK string `dynamodbav:"PK" json:"-"` // USER#{userName}
+	SK string `dynamodbav:"SK" json:"-"` // NOTIF#{notificationId}
+
+	NotificationId string           `dynamodbav:"NotificationId" json:"notificationId"`
+	UserName       string           `dynamodbav:"UserName" json:"userName"`
+	Type           NotificationType `dynamodbav:"Type" json:"type"`
+	ActorUserName  string           `dynamodbav:"ActorUserName" json:"actorUserName"`
+	ActorName      string           `dynamodbav:"ActorName,omitempty" json:"actorName,omitempty"`
+	TeamId         string           `dynamodbav:"TeamId,omitempty" json:"teamId,omitempty"`
+	PostId         string           `dynamodbav:"PostId,omitempty" json:"postId,omitempty"`
+	CommentId      string           `dynamodbav:"CommentId,omitempty" json:"commentId,omitempty"`
+	Message        string           `dynamodbav:"Message" json:"message"`
+	IsRead         bool             `dynamodbav:"IsRead" json:"isRead"`
+	ReadAt         string           `dynamodbav:"ReadAt,omitempty" json:"readAt,omitempty"`
+	CreatedAt      string           `dynamodbav:"CreatedAt" json:"createdAt"`
+	ExpiresAt      int64            `dynamodbav:"ExpiresAt" json:"-"` // TTL, unix seconds
+}
+
+// NotificationPreferences is stored in the user's inbox partition under SK PREFERENCES
+type NotificationPreferences struct {
+	PK string `dynamodbav:"PK" json:"-"`
+	SK string `dynamodbav:"SK" json:"-"`
+
+	UserName    string `dynamodbav:"UserName" json:"userName"`
+	EmailDigest bool   `dynamodbav:"EmailDigest" json:"emailDigest"`
+
+	// DigestFrequency is the DigestIndex partition key; it is removed when the digest is off
+	DigestFrequency string `dynamodbav:"DigestFrequency,omitempty" json:"digestFrequency,omitempty"`
+
+	// LastDigestNotificationId is the newest notification already sent in a digest
+	LastDigestNotificationId string `dynamodbav:"LastDigestNotificationId,omitempty" json:"-"`
+	LastDigestSentAt         string `dynamodbav:"LastDigestSentAt,omitempty" json:"lastDigestSentAt,omitempty"`
+	UpdatedAt                string `dynamodbav:"UpdatedAt,omitempty" json:"updatedAt,omitempty"`
+}
+
+// NotificationPage is one page of a user's inbox. NextCursor is empty on the last page.
+type NotificationPage struct {
+	Notifications []Notification `json:"notifications"`
+	NextCursor    string         `json:"nextCursor,omitempty"`
+}
+
+type NotificationService struct {
+	ctx            context.Context
+	dynamodbClient awsclients.DynamodbClient
+	logger         *log.Logger
+	emailSvc       *EmailService
+
+	NotificationsTable             string
+	NotificationsTable_DigestIndex string
+}
+
+func CreateNotificationService(ctx context.Context, ddbClient awsclients.DynamodbClient, logger *log.Logger, emailSvc *EmailService) *NotificationService {
+	return &NotificationService{
+		ctx:            ctx,
+		dynamodbClient: ddbClient,
+		logger:         logger,
+		emailSvc:       emailSvc,
+	}
+}
+
+// notificationPartitionKey is the user's inbox partition. User names are lower-cased so an inbox is
+// the same however a caller spells the address.
+func notificationPartitionKey(userName string) string {
+	return fmt.Sprintf("USER#%s", strings.ToLower(userName))
+}
+
+func notificationSortKey(notificationId string) string {
+	return notificationSKPrefix + notificationId
+}
+
+func newNotificationId(now time.Time) string {
+	return fmt.Sprintf("%013d-%s", now.UnixMilli(), uuid.New().String()[:8])
+}
+
+// ==================== Create ====================
+
+// CreateNotifications stores notifications for their recipients, whose user names are lower-cased.
+// Notifications addressed to the actor themselves are dropped, and each recipient gets at most one
+// notification per call, so callers should list the most specific notification (e.g. a mention)
+// first.
+func (svc *NotificationService) CreateNotifications(notifications []Notification) error {
+	now := time.Now().UTC()
+	seen := make(map[string]bool, len(notifications))
+
+	writes := make([]types.WriteRequest, 0, len(notifications))
+	for _, n := range notifications {
+		recipient := strings.ToLower(strings.TrimSpace(n.UserName))
+		if recipient == "" || recipient == strings.ToLower(n.ActorUserName) || seen[recipient] {
+			continue
+		}
+		seen[recipient] = true
+
+		n.NotificationId = newNotificationId(now)
+		n.UserName = recipient
+		n.PK = notificationPartitionKey(recipient)
+		n.SK = notificationSortKey(n.NotificationId)
+		n.IsRead = false
+		n.CreatedAt = now.Format(time.RFC3339)
+		n.ExpiresAt = now.Add(NotificationRetention).Unix()
+
+		item, err := attributevalue.MarshalMap(n)
+		if err != nil {
+			return fmt.Errorf("failed to marshal notification: %w", err)
+		}
+		writes = append(writes, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+	}
+
+	for start := 0; start < len(writes); start += notificationBatchMax {
+		end := start + notificationBatchMax
+		if end > len(writes) {
+			end = len(writes)
+		}
+
+		pending := map[string][]types.WriteRequest{svc.NotificationsTable: writes[start:end]}
+		for trial := 0; len(pending[svc.NotificationsTable]) > 0; trial++ {
+			if trial == notificationBatchRetries {
+				return fmt.Errorf("failed to write %d notifications after %d attempts", len(pending[svc.NotificationsTable]), trial)
+			}
+			if trial > 0 {
+				time.Sleep(time.Duration(trial) * 100 * time.Millisecond)
+			}
+			output, err := svc.dynamodbClient.BatchWriteItem(svc.ctx, &dynamodb.BatchWriteItemInput{RequestItems: pending})
+			if err != nil {
+				return fmt.Errorf("failed to write notifications: %w", err)
+			}
+			pending = output.UnprocessedItems
+		}
+	}
+
+	return nil
+}
+
+// ==================== Inbox ====================
+
+// ListNotifications returns a page of the user's inbox, newest first. cursor is the NextCursor of
+// the previous page.
+func (svc *NotificationService) ListNotifications(userName string, unreadOnly bool, limit int, cursor string) (*NotificationPage, error) {
+	if limit <= 0 || limit > MaxNotificationIdsPerRequest {
+		limit = MaxNotificationIdsPerRequest
+	}
+
+	input := svc.inboxQueryInput(userName, unreadOnly)
+	input.ScanIndexForward = aws.Bool(false)
+	if cursor != "" {
+		if strings.Contains(cursor, "#") {
+			return nil, ErrInvalidNotificationCursor
+		}
+		input.ExclusiveStartKey = map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: notificationPartitionKey(userName)},
+			"SK": &types.AttributeValueMemberS{Value: notificationSortKey(cursor)},
+		}
+	}
+
+	page := &NotificationPage{Notifications: []Notification{}}
+	var lastKey map[string]types.AttributeValue
+	for i := 0; i < maxNotificationQueries && len(page.Notifications) < limit; i++ {
+		input.Limit = aws.Int32(int32(limit - len(page.Notifications)))
+		if unreadOnly {
+			// Limit applies before the filter, so read a full page and trim below
+			input.Limit = aws.Int32(int32(limit))
+		}
+
+		output, err := svc.dynamodbClient.Query(svc.ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query notifications for %s: %w", userName, err)
+		}
+
+		var batch []Notification
+		if err := attributevalue.UnmarshalListOfMaps(output.Items, &batch); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal notifications: %w", err)
+		}
+		page.Notifications = append(page.Notifications, batch...)
+
+		lastKey = output.LastEvaluatedKey
+		if len(lastKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = lastKey
+	}
+
+	switch {
+	case len(page.Notifications) > limit:
+		page.Notifications = page.Notifications[:limit]
+		page.NextCursor = page.Notifications[limit-1].NotificationId
+	case len(lastKey) > 0:
+		var key Notification
+		if err := attributevalue.UnmarshalMap(lastKey, &key); err != nil {
+			return nil, fmt.Errorf("failed to read notification cursor: %w", err)
+		}
+		page.NextCursor = strings.TrimPrefix(key.SK, notificationSKPrefix)
+	}
+
+	return page, nil
+}
+
+// GetUnreadCount counts the user's unread notifications
+func (svc *NotificationService) GetUnreadCount(userName string) (int, error) {
+	return svc.CountNotifications(userName, true)
+}
+
+// CountNotifications counts the user's notifications, or only the unread ones
+func (svc *NotificationService) CountNotifications(userName string, unreadOnly bool) (int, error) {
+	input := svc.inboxQueryInput(userName, unreadOnly)
+	input.Select = types.SelectCount
+
+	count := 0
+	for {
+		output, err := svc.dynamodbClient.Query(svc.ctx, input)
+		if err != nil {
+			return 0, fmt.Errorf("failed to count notifications for %s: %w", userName, err)
+		}
+		count += int(output.Count)
+		if len(output.LastEvaluatedKey) == 0 {
+			return count, nil
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
+
+// MarkNotificationsRead marks the given notifications as read and returns how many changed.
+// Unknown IDs and notifications that were already read are skipped.
+func (svc *NotificationService) MarkNotificationsRead(userName string, notificationIds []string) (int, error) {
+	if len(notificationIds) > MaxNotificationIdsPerRequest {
+		return 0, fmt.Errorf("at most %d notifications can be marked at once", MaxNotificationIdsPerRequest)
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	marked := 0
+	for _, id := range notificationIds {
+		updated, err := svc.markRead(userName, id, now)
+		if err != nil {
+			return marked, err
+		}
+		if updated {
+			marked++
+		}
+	}
+	return marked, nil
+}
+
+// MarkAllNotificationsRead marks every unread notification in the user's inbox as read
+func (svc *NotificationService) MarkAllNotificationsRead(userName string) (int, error) {
+	input := svc.inboxQueryInput(userName, true)
+	input.ProjectionExpression = aws.String("PK, SK")
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	marked := 0
+	for {
+		output, err := svc.dynamodbClient.Query(svc.ctx, input)
+		if err != nil {
+			return marked, fmt.Errorf("failed to query unread notifications for %s: %w", userName, err)
+		}
+
+		var keys []Notification
+		if err := attributevalue.UnmarshalListOfMaps(output.Items, &keys); err != nil {
+			return marked, fmt.Errorf("failed to unmarshal notifications: %w", err)
+		}
+		for _, key := range keys {
+			updated, err := svc.markRead(userName, strings.TrimPrefix(key.SK, notificationSKPrefix), now)
+			if err != nil {
+				return marked, err
+			}
+			if updated {
+				marked++
+			}
+		}
+
+		if len(output.LastEvaluatedKey) == 0 {
+			return marked, nil
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
+
+// markRead returns false when the notification does not exist or was already read
+func (svc *NotificationService) markRead(userName, notificationId, now string) (bool, error) {
+	_, err := svc.dynamodbClient.UpdateItem(svc.ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(svc.NotificationsTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: notificationPartitionKey(userName)},
+			"SK": &types.AttributeValueMemberS{Value: notificationSortKey(notificationId)},
+		},
+		UpdateExpression:    aws.String("SET IsRead = :true, ReadAt = :now"),
+		ConditionExpression: aws.String("attribute_exists(SK) AND IsRead = :false"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":true":  &types.AttributeValueMemberBOOL{Value: true},
+			":false": &types.AttributeValueMemberBOOL{Value: false},
+			":now":   &types.AttributeValueMemberS{Value: now},
+		},
+	})
+	if err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to mark notification %s as read: %w", notificationId, err)
+	}
+	return true, nil
+}
+
+// inboxQueryInput queries the notifications in a user's partition, skipping the preferences item
+func (svc *NotificationService) inboxQueryInput(userName string, unreadOnly bool) *dynamodb.QueryInput {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(svc.NotificationsTable),
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :prefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":     &types.AttributeValueMemberS{Value: notificationPartitionKey(userName)},
+			":prefix": &types.AttributeValueMemberS{Value: notificationSKPrefix},
+		},
+	}
+	if unreadOnly {
+		input.FilterExpression = aws.String("IsRead = :false")
+		input.ExpressionAttributeValues[":false"] = &types.AttributeValueMemberBOOL{Value: false}
+	}
+	return input
+}
+
+// ==================== Preferences ====================
+
+// GetNotificationPreferences returns the user's preferences; users who never saved any get the
+// defaults (digest off)
+func (svc *NotificationService) GetNotificationPreferences(userName string) (*NotificationPreferences, error) {
+	output, err := svc.dynamodbClient.GetItem(svc.ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(svc.NotificationsTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: notificationPartitionKey(userName)},
+			"SK": &types.AttributeValueMemberS{Value: notificationPrefsSK},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification preferences for %s: %w", userName, err)
+	}
+
+	prefs := NotificationPreferences{UserName: userName}
+	if output.Item != nil {
+		if err := attributevalue.UnmarshalMap(output.Item, &prefs); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal notification preferences: %w", err)
+		}
+	}
+	return &prefs, nil
+}
+
+// UpdateNotificationPreferences switches the email digest on or off
+func (svc *NotificationService) UpdateNotificationPreferences(userName string, emailDigest bool) (*NotificationPreferences, error) {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(svc.NotificationsTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: notificationPartitionKey(userName)},
+			"SK": &types.AttributeValueMemberS{Value: notificationPrefsSK},
+		},
+		UpdateExpression: aws.String("SET UserName = :userName, EmailDigest = :emailDigest, UpdatedAt = :now REMOVE DigestFrequency"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":userName":    &types.AttributeValueMemberS{Value: userName},
+			":emailDigest": &types.AttributeValueMemberBOOL{Value: emailDigest},
+			":now":         &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)},
+		},
+		ReturnValues: types.ReturnValueAllNew,
+	}
+	if emailDigest {
+		input.UpdateExpression = aws.String("SET UserName = :userName, EmailDigest = :emailDigest, UpdatedAt = :now, DigestFrequency = :frequency")
+		input.ExpressionAttributeValues[":frequency"] = &types.AttributeValueMemberS{Value: DigestFrequencyDaily}
+	}
+
+	output, err := svc.dynamodbClient.UpdateItem(svc.ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update notification preferences for %s: %w", userName, err)
+	}
+
+	var prefs NotificationPreferences
+	if err := attributevalue.UnmarshalMap(output.Attributes, &prefs); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal notification preferences: %w", err)
+	}
+	return &prefs, nil
+}
+
+// ==================== Email Digest ====================
+
+// ListDigestSubscribers returns the preferences of every user with the email digest on
+func (svc *NotificationService) ListDigestSubscribers() ([]NotificationPreferences, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(svc.NotificationsTable),
+		IndexName:              aws.String(svc.NotificationsTable_DigestIndex),
+		KeyConditionExpression: aws.String("DigestFrequency = :frequency"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":frequency": &types.AttributeValueMemberS{Value: DigestFrequencyDaily},
+		},
+	}
+
+	var subscribers []NotificationPreferences
+	for {
+		output, err := svc.dynamodbClient.Query(svc.ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query digest subscribers: %w", err)
+		}
+
+		var batch []NotificationPreferences
+		if err := attributevalue.UnmarshalListOfMaps(output.Items, &batch); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal digest subscribers: %w", err)
+		}
+		subscribers = append(subscribers, batch...)
+
+		if len(output.LastEvaluatedKey) == 0 {
+			return subscribers, nil
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
+
+// SendNotificationDigest emails the user a summary of the unread notifications created since
+// their last digest. It returns false when there was nothing new to send.
+func (svc *NotificationService) SendNotificationDigest(prefs NotificationPreferences, inboxURL string) (bool, error) {
+	pending, err := svc.undigestedNotifications(prefs)
+	if err != nil {
+		return false, err
+	}
+	if len(pending) == 0 {
+		return false, nil
+	}
+
+	subject := "You have 1 new notification"
+	if len(pending) > 1 {
+		subject = fmt.Sprintf("You have %d new notifications", len(pending))
+	}
+
+	shown := pending
+	if len(shown) > maxDigestNotifications {
+		shown = shown[:maxDigestNotifications]
+	}
+
+	err = svc.emailSvc.SendEmail(EmailInput{
+		ToEmails: []string{prefs.UserName},
+		Subject:  subject,
+		HtmlBody: buildNotificationDigestHTML(subject, shown, len(pending), inboxURL),
+		TextBody: buildNotificationDigestText(subject, shown, len(pending), inboxURL),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to send notification digest to %s: %w", prefs.UserName, err)
+	}
+
+	// Pending is newest first; later digests start after the newest notification sent
+	_, err = svc.dynamodbClient.UpdateItem(svc.ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(svc.NotificationsTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: notificationPartitionKey(prefs.UserName)},
+			"SK": &types.AttributeValueMemberS{Value: notificationPrefsSK},
+		},
+		UpdateExpression: aws.String("SET LastDigestNotificationId = :lastId, LastDigestSentAt = :now"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":lastId": &types.AttributeValueMemberS{Value: pending[0].NotificationId},
+			":now":    &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)},
+		},
+	})
+	if err != nil {
+		return true, fmt.Errorf("failed to record notification digest for %s: %w", prefs.UserName, err)
+	}
+
+	return true, nil
+}
+
+// undigestedNotifications returns unread notifications newer than the last digest, newest first
+func (svc *NotificationService) undigestedNotifications(prefs NotificationPreferences) ([]Notification, error) {
+	// "~" sorts after every character used in notification IDs
+	from := notificationSKPrefix
+	if prefs.LastDigestNotificationId != "" {
+		from = notificationSortKey(prefs.LastDigestNotificationId) + "~"
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(svc.NotificationsTable),
+		KeyConditionExpression: aws.String("PK = :pk AND SK BETWEEN :from AND :to"),
+		FilterExpression:       aws.String("IsRead = :false"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":    &types.AttributeValueMemberS{Value: notificationPartitionKey(prefs.UserName)},
+			":from":  &types.AttributeValueMemberS{Value: from},
+			":to":    &types.AttributeValueMemberS{Value: notificationSKPrefix + "~"},
+			":false": &types.AttributeValueMemberBOOL{Value: false},
+		},
+		ScanIndexForward: aws.Bool(false),
+	}
+
+	var notifications []Notification
+	for {
+		output, err := svc.dynamodbClient.Query(svc.ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query notifications for digest: %w", err)
+		}
+
+		var batch []Notification
+		if err := attributevalue.UnmarshalListOfMaps(output.Items, &batch); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal notifications: %w", err)
+		}
+		notifications = append(notifications, batch...)
+
+		if len(output.LastEvaluatedKey) == 0 {
+			return notifications, nil
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
+
+func buildNotificationDigestHTML(headline string, notifications []Notification, total int, inboxURL string) string {
+	var rows strings.Builder
+	for _, n := range notifications {
+		rows.WriteString(fmt.Sprintf(`<li style="color: #555555; font-size: 16px; line-height: 1.5; margin-bottom: 8px;">%s</li>`, html.EscapeString(n.Message)))
+	}
+	more := ""
+	if total > len(notifications) {
+		more = fmt.Sprintf(`<p style="color: #555555; font-size: 14px;">and %d more.</p>`, total-len(notifications))
+	}
+
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="UTF-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1.0">
+</head>
+<body style="margin: 0; padding: 0; font-family: Arial, sans-serif; background-color: #f4f4f4;">
+	<table width="100%%" cellpadding="0" cellspacing="0" style="background-color: #f4f4f4; padding: 20px;">
+		<tr>
+			<td align="center">
+				<table width="600" cellpadding="0" cellspacing="0" style="background-color: #ffffff; border-radius: 8px; padding: 40px;">
+					<tr>
+						<td>
+							<h1 style="color: #333333; font-size: 24px; margin: 0 0 20px 0;">%s</h1>
+							<ul style="padding-left: 20px;">%s</ul>
+							%s
+							<div style="text-align: center; margin: 30px 0;">
+								<a href="%s" style="background-color: #007bff; color: #ffffff; padding: 12px 30px; text-decoration: none; border-radius: 5px; font-size: 16px;">View Notifications</a>
+							</div>
+							<p style="color: #999999; font-size: 12px; text-align: center;">You are receiving this because email digests are on in your notification settings.</p>
+							<p style="color: #999999; font-size: 12px; text-align: center;">© 2026 Gomovo Hub. All rights reserved.</p>
+						</td>
+					</tr>
+				</table>
+			</td>
+		</tr>
+	</table>
+</body>
+</html>`, html.EscapeString(headline), rows.String(), more, inboxURL)
+}
+
+func buildNotificationDigestText(headline string, notifications []Notification, total int, inboxURL string) string {
+	var lines strings.Builder
+	for _, n := range notifications {
+		lines.WriteString("- " + n.Message + "\n")
+	}
+	if total > len(notifications) {
+		lines.WriteString(fmt.Sprintf("and %d more.\n", total-len(notifications)))
+	}
+
+	return fmt.Sprintf(`%s
+
+%s
+View Notifications:
+%s
+
+You are receiving this because email digests are on in your notification settings.
+
+© 2026 Gomovo Hub. All rights reserved.
+`, headline, lines.String(), inboxURL)
+}
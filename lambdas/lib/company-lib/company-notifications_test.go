@@ -0,0 +1,221 @@
+package Companylib
+
+import (
+	"context"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodb_types "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	awsclients "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/clients"
+	"github.com/stretchr/testify/assert"
+)
+
+func newNotificationService(ddb *awsclients.MockDynamodbClient) *NotificationService {
+	svc := CreateNotificationService(context.TODO(), ddb, log.New(os.Stdout, "", log.LstdFlags), nil)
+	svc.NotificationsTable = "NotificationsTable"
+	svc.NotificationsTable_DigestIndex = "DigestIndex"
+	return svc
+}
+
+func notificationItems(ids ...string) []map[string]dynamodb_types.AttributeValue {
+	items := make([]map[string]dynamodb_types.AttributeValue, 0, len(ids))
+	for _, id := range ids {
+		item, _ := attributevalue.MarshalMap(Notification{
+			PK:             "USER#jane@example.com",
+			SK:             "NOTIF#" + id,
+			NotificationId: id,
+			UserName:       "jane@example.com",
+			Type:           NotificationTypeLike,
+		})
+		items = append(items, item)
+	}
+	return items
+}
+
+func TestCreateNotifications(t *testing.T) {
+	ddb := &awsclients.MockDynamodbClient{
+		BatchWriteItemOutputs: []dynamodb.BatchWriteItemOutput{{}},
+		BatchErrors:           []error{nil},
+	}
+	svc := newNotificationService(ddb)
+
+	err := svc.CreateNotifications([]Notification{
+		{UserName: "jane@example.com", ActorUserName: "sam@example.com", Type: NotificationTypeMention, Message: "Sam mentioned you"},
+		{UserName: "Jane@example.com", ActorUserName: "sam@example.com", Type: NotificationTypeComment, Message: "Sam commented on your post"},
+		{UserName: "sam@example.com", ActorUserName: "sam@example.com", Type: NotificationTypeComment},
+		{UserName: " Alex@Example.com", ActorUserName: "sam@example.com", Type: NotificationTypeMention, Message: "Sam mentioned you"},
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, ddb.BatchWriteItemsInputs, 1)
+
+	writes := ddb.BatchWriteItemsInputs[0].RequestItems["NotificationsTable"]
+	assert.Len(t, writes, 2, "self notifications and duplicates per recipient should be dropped")
+
+	var first Notification
+	assert.NoError(t, attributevalue.UnmarshalMap(writes[0].PutRequest.Item, &first))
+	assert.Equal(t, NotificationTypeMention, first.Type)
+	assert.Equal(t, "USER#jane@example.com", first.PK)
+	assert.Equal(t, "NOTIF#"+first.NotificationId, first.SK)
+	assert.False(t, first.IsRead)
+	assert.True(t, first.ExpiresAt > 0)
+
+	var second Notification
+	assert.NoError(t, attributevalue.UnmarshalMap(writes[1].PutRequest.Item, &second))
+	assert.Equal(t, "USER#alex@example.com", second.PK, "recipients should be lower-cased")
+	assert.Equal(t, "alex@example.com", second.UserName)
+}
+
+func TestListNotifications(t *testing.T) {
+	tests := []struct {
+		name           string
+		unreadOnly     bool
+		limit          int
+		cursor         string
+		outputs        []dynamodb.QueryOutput
+		expectedIds    []string
+		expectedCursor string
+		expectedError  error
+	}{
+		{
+			name:        "It should return the last page without a cursor",
+			limit:       20,
+			outputs:     []dynamodb.QueryOutput{{Items: notificationItems("3", "2", "1")}},
+			expectedIds: []string{"3", "2", "1"},
+		},
+		{
+			name:  "It should resume from the last evaluated key",
+			limit: 2,
+			outputs: []dynamodb.QueryOutput{{
+				Items: notificationItems("3", "2"),
+				LastEvaluatedKey: map[string]dynamodb_types.AttributeValue{
+					"PK": &dynamodb_types.AttributeValueMemberS{Value: "USER#jane@example.com"},
+					"SK": &dynamodb_types.AttributeValueMemberS{Value: "NOTIF#2"},
+				},
+			}},
+			expectedIds:    []string{"3", "2"},
+			expectedCursor: "2",
+		},
+		{
+			name:       "It should keep reading until a filtered page is full and trim it",
+			unreadOnly: true,
+			limit:      2,
+			outputs: []dynamodb.QueryOutput{
+				{
+					Items: notificationItems("5"),
+					LastEvaluatedKey: map[string]dynamodb_types.AttributeValue{
+						"PK": &dynamodb_types.AttributeValueMemberS{Value: "USER#jane@example.com"},
+						"SK": &dynamodb_types.AttributeValueMemberS{Value: "NOTIF#4"},
+					},
+				},
+				{Items: notificationItems("3", "1")},
+			},
+			expectedIds:    []string{"5", "3"},
+			expectedCursor: "3",
+		},
+		{
+			name:          "It should reject a malformed cursor",
+			limit:         20,
+			cursor:        "USER#someone-else",
+			expectedError: ErrInvalidNotificationCursor,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ddb := &awsclients.MockDynamodbClient{
+				QueryOutputs: test.outputs,
+				QueryErrors:  make([]error, len(test.outputs)),
+			}
+			svc := newNotificationService(ddb)
+
+			page, err := svc.ListNotifications("jane@example.com", test.unreadOnly, test.limit, test.cursor)
+
+			if test.expectedError != nil {
+				assert.ErrorIs(t, err, test.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+
+			ids := make([]string, 0, len(page.Notifications))
+			for _, n := range page.Notifications {
+				ids = append(ids, n.NotificationId)
+			}
+			assert.Equal(t, test.expectedIds, ids)
+			assert.Equal(t, test.expectedCursor, page.NextCursor)
+			if test.unreadOnly {
+				assert.Equal(t, "IsRead = :false", aws.ToString(ddb.QueryInputs[0].FilterExpression))
+			}
+		})
+	}
+}
+
+func TestMarkNotificationsRead(t *testing.T) {
+	ddb := &awsclients.MockDynamodbClient{
+		UpdateItemOutputs: []dynamodb.UpdateItemOutput{{}, {}},
+		UpdateItemErrors:  []error{nil, &dynamodb_types.ConditionalCheckFailedException{}},
+	}
+	svc := newNotificationService(ddb)
+
+	marked, err := svc.MarkNotificationsRead("jane@example.com", []string{"2", "1"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, marked, "already-read or missing notifications should not be counted")
+	assert.Equal(t, "NOTIF#2", ddb.UpdateItemInputs[0].Key["SK"].(*dynamodb_types.AttributeValueMemberS).Value)
+
+	_, err = svc.MarkNotificationsRead("jane@example.com", make([]string, MaxNotificationIdsPerRequest+1))
+	assert.Error(t, err)
+}
+
+func TestUpdateNotificationPreferences(t *testing.T) {
+	tests := []struct {
+		name        string
+		emailDigest bool
+		expression  string
+	}{
+		{
+			name:        "It should add the user to the digest index when the digest is switched on",
+			emailDigest: true,
+			expression:  "DigestFrequency = :frequency",
+		},
+		{
+			name:        "It should remove the user from the digest index when the digest is switched off",
+			emailDigest: false,
+			expression:  "REMOVE DigestFrequency",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			attributes, _ := attributevalue.MarshalMap(NotificationPreferences{UserName: "jane@example.com", EmailDigest: test.emailDigest})
+			ddb := &awsclients.MockDynamodbClient{
+				UpdateItemOutputs: []dynamodb.UpdateItemOutput{{Attributes: attributes}},
+				UpdateItemErrors:  []error{nil},
+			}
+			svc := newNotificationService(ddb)
+
+			prefs, err := svc.UpdateNotificationPreferences("jane@example.com", test.emailDigest)
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.emailDigest, prefs.EmailDigest)
+			assert.Contains(t, aws.ToString(ddb.UpdateItemInputs[0].UpdateExpression), test.expression)
+		})
+	}
+}
+
+func TestBuildNotificationDigest(t *testing.T) {
+	notifications := []Notification{{Message: "Sam <script> liked your post"}}
+
+	body := buildNotificationDigestHTML("You have 3 new notifications", notifications, 3, "https://app.example.com/notifications")
+	assert.Contains(t, body, "Sam &lt;script&gt; liked your post")
+	assert.Contains(t, body, "and 2 more.")
+
+	text := buildNotificationDigestText("You have 3 new notifications", notifications, 3, "https://app.example.com/notifications")
+	assert.True(t, strings.HasPrefix(text, "You have 3 new notifications"))
+	assert.Contains(t, text, "- Sam <script> liked your post")
+}
@@ -0,0 +1,459 @@
+package Companylib
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// An OKR's progress is rolled up from its key results. Each key result measures how far its
+// currentValue has moved from startValue towards targetValue:
+//
+//	INCREASE  (current - start) / (target - start)
+//	DECREASE  (start - current) / (start - target)
+//	BINARY    100 once currentValue reaches targetValue (1 by default), otherwise 0
+//
+// The OKR's progress is the weighted average of its key results (weight defaults to 1). Its
+// computedConfidence (0-10) is the weighted average of the key results' confidenceScore or, when
+// none carry one, how its progress compares to the time elapsed in its quarter or cycle; the OKR's
+// own confidenceScore is left as its owner entered it. Every change to a key result recalculates
+// the OKR and, when the result moved, appends an OKR_PROGRESS snapshot that GetOKRDetails returns
+// as progressHistory. Health and expectedProgress move with the calendar, so okrPayload works
+// them out on every read, along with the progress of OKRs created before the roll-up existed.
+
+const (
+	perfEntityOKRProgress = "OKR_PROGRESS"
+
+	KeyResultTypeIncrease = "INCREASE"
+	KeyResultTypeDecrease = "DECREASE"
+	KeyResultTypeBinary   = "BINARY"
+
+	OKRHealthOnTrack   = "ON_TRACK"
+	OKRHealthAtRisk    = "AT_RISK"
+	OKRHealthBehind    = "BEHIND"
+	OKRHealthCompleted = "COMPLETED"
+
+	OKRConfidenceFromKeyResults = "KEY_RESULTS"
+	OKRConfidenceFromPace       = "PACE"
+
+	okrConfidenceMax = 10.0
+
+	// okrAtRiskGap and okrBehindGap are how many points progress may trail the time elapsed in the
+	// period before an OKR is at risk or behind
+	okrAtRiskGap = 10.0
+	okrBehindGap = 30.0
+)
+
+var ErrKeyResultInvalid = errors.New("invalid key result")
+
+// okrComputedFields are written by the roll-up and cannot be patched directly
+var okrComputedFields = []string{"progress", "expectedProgress", "health", "computedConfidence", "confidenceSource", "keyResultCount", "progressUpdatedAt"}
+
+// OKRProgress is the roll-up of an OKR's key results
+type OKRProgress struct {
+	Progress           float64             `json:"progress"`
+	ExpectedProgress   *float64            `json:"expectedProgress,omitempty"` // nil when the period has no dates
+	Health             string              `json:"health"`
+	ComputedConfidence *float64            `json:"computedConfidence,omitempty"` // nil when it cannot be derived
+	ConfidenceSource   string              `json:"confidenceSource,omitempty"`
+	KeyResults         []KeyResultProgress `json:"keyResults"`
+}
+
+type KeyResultProgress struct {
+	ID           string  `json:"id"`
+	Type         string  `json:"type"`
+	Weight       float64 `json:"weight"`
+	CurrentValue float64 `json:"currentValue"`
+	Progress     float64 `json:"progress"`
+}
+
+// normalizeKeyResult validates a key result and fills in its defaults and progress
+func normalizeKeyResult(kr map[string]interface{}) error {
+	krType := strings.ToUpper(strings.TrimSpace(toString(kr["type"])))
+	if krType == "" {
+		krType = KeyResultTypeIncrease
+	}
+	switch krType {
+	case KeyResultTypeIncrease, KeyResultTypeDecrease, KeyResultTypeBinary:
+	default:
+		return fmt.Errorf("%w: type must be one of INCREASE, DECREASE, BINARY", ErrKeyResultInvalid)
+	}
+	kr["type"] = krType
+
+	if !hasValue(kr, "weight") {
+		kr["weight"] = 1.0
+	}
+	if toFloat(kr["weight"]) <= 0 {
+		return fmt.Errorf("%w: weight must be greater than 0", ErrKeyResultInvalid)
+	}
+
+	switch krType {
+	case KeyResultTypeBinary:
+		if !hasValue(kr, "startValue") {
+			kr["startValue"] = 0.0
+		}
+		if !hasValue(kr, "targetValue") {
+			kr["targetValue"] = 1.0
+		}
+	case KeyResultTypeDecrease:
+		// Decreasing from wherever it stands today unless told otherwise
+		if !hasValue(kr, "startValue") {
+			if !hasValue(kr, "currentValue") {
+				return fmt.Errorf("%w: startValue is required for a DECREASE key result", ErrKeyResultInvalid)
+			}
+			kr["startValue"] = kr["currentValue"]
+		}
+		if !hasValue(kr, "targetValue") {
+			return fmt.Errorf("%w: targetValue is required", ErrKeyResultInvalid)
+		}
+		if toFloat(kr["targetValue"]) >= toFloat(kr["startValue"]) {
+			return fmt.Errorf("%w: targetValue must be below startValue for a DECREASE key result", ErrKeyResultInvalid)
+		}
+	default:
+		if !hasValue(kr, "startValue") {
+			kr["startValue"] = 0.0
+		}
+		if !hasValue(kr, "targetValue") {
+			return fmt.Errorf("%w: targetValue is required", ErrKeyResultInvalid)
+		}
+		if toFloat(kr["targetValue"]) <= toFloat(kr["startValue"]) {
+			return fmt.Errorf("%w: targetValue must be above startValue for an INCREASE key result", ErrKeyResultInvalid)
+		}
+	}
+	if !hasValue(kr, "currentValue") {
+		kr["currentValue"] = kr["startValue"]
+	}
+
+	if hasValue(kr, "confidenceScore") {
+		confidence := toFloat(kr["confidenceScore"])
+		if confidence < 0 || confidence > okrConfidenceMax {
+			return fmt.Errorf("%w: confidenceScore must be between 0 and %g", ErrKeyResultInvalid, okrConfidenceMax)
+		}
+	}
+
+	kr["progress"] = keyResultProgress(kr)
+	return nil
+}
+
+// keyResultProgress is how far a key result has moved towards its target, 0-100
+func keyResultProgress(kr map[string]interface{}) float64 {
+	start := toFloat(kr["startValue"])
+	current := toFloat(kr["currentValue"])
+	target := toFloat(kr["targetValue"])
+
+	var fraction float64
+	switch strings.ToUpper(toString(kr["type"])) {
+	case KeyResultTypeBinary:
+		if !hasValue(kr, "targetValue") {
+			target = 1
+		}
+		if current >= target {
+			fraction = 1
+		}
+	case KeyResultTypeDecrease:
+		if start != target {
+			fraction = (start - current) / (start - target)
+		}
+	default:
+		// Key results created before roll-up was added have no startValue and count from 0
+		if target != start {
+			fraction = (current - start) / (target - start)
+		}
+	}
+	return roundProgress(math.Max(0, math.Min(1, fraction)) * 100)
+}
+
+// rollUpOKRProgress combines an OKR's key results. expected is how much of the OKR's period has
+// elapsed (0-100), or nil when the period has no dates.
+func rollUpOKRProgress(keyResults []map[string]interface{}, expected *float64) OKRProgress {
+	result := OKRProgress{KeyResults: make([]KeyResultProgress, 0, len(keyResults))}
+
+	var weightTotal, progressTotal, confidenceWeight, confidenceTotal float64
+	for _, kr := range keyResults {
+		weight := toFloat(kr["weight"])
+		if weight <= 0 {
+			weight = 1
+		}
+		progress := keyResultProgress(kr)
+		krType := strings.ToUpper(toString(kr["type"]))
+		if krType == "" {
+			krType = KeyResultTypeIncrease
+		}
+		result.KeyResults = append(result.KeyResults, KeyResultProgress{
+			ID:           toString(kr["id"]),
+			Type:         krType,
+			Weight:       weight,
+			CurrentValue: toFloat(kr["currentValue"]),
+			Progress:     progress,
+		})
+		weightTotal += weight
+		progressTotal += progress * weight
+		if hasValue(kr, "confidenceScore") {
+			confidenceWeight += weight
+			confidenceTotal += toFloat(kr["confidenceScore"]) * weight
+		}
+	}
+	if weightTotal > 0 {
+		result.Progress = roundProgress(progressTotal / weightTotal)
+	}
+
+	if expected != nil {
+		e := roundProgress(*expected)
+		result.ExpectedProgress = &e
+	}
+	result.Health = okrHealth(result.Progress, result.ExpectedProgress)
+
+	switch {
+	case confidenceWeight > 0:
+		c := roundProgress(confidenceTotal / confidenceWeight)
+		result.ComputedConfidence = &c
+		result.ConfidenceSource = OKRConfidenceFromKeyResults
+	case result.ExpectedProgress != nil && *result.ExpectedProgress > 0:
+		pace := result.Progress / *result.ExpectedProgress
+		c := roundProgress(math.Min(okrConfidenceMax, okrConfidenceMax*pace))
+		result.ComputedConfidence = &c
+		result.ConfidenceSource = OKRConfidenceFromPace
+	}
+	return result
+}
+
+// okrHealth compares progress against the time elapsed in the OKR's period
+func okrHealth(progress float64, expected *float64) string {
+	if progress >= 100 {
+		return OKRHealthCompleted
+	}
+	if expected == nil {
+		return OKRHealthOnTrack
+	}
+	gap := *expected - progress
+	switch {
+	case gap > okrBehindGap:
+		return OKRHealthBehind
+	case gap > okrAtRiskGap:
+		return OKRHealthAtRisk
+	}
+	return OKRHealthOnTrack
+}
+
+// expectedProgress is the share of a period (YYYY-MM-DD dates, end inclusive) elapsed at now, 0-100
+func expectedProgress(startDate string, endDate string, now time.Time) (float64, bool) {
+	start, err := time.Parse("2006-01-02", startDate)
+	if err != nil {
+		return 0, false
+	}
+	end, err := time.Parse("2006-01-02", endDate)
+	if err != nil {
+		return 0, false
+	}
+	end = end.AddDate(0, 0, 1)
+	if !end.After(start) {
+		return 0, false
+	}
+	elapsed := now.Sub(start).Seconds() / end.Sub(start).Seconds()
+	return math.Max(0, math.Min(1, elapsed)) * 100, true
+}
+
+// okrPeriodExpectedProgress uses the OKR's quarter dates when it has a quarter, otherwise its cycle's.
+// periods holds the quarter and cycle records already loaded, keyed by GSI1PK.
+func (svc *PerformanceService) okrPeriodExpectedProgress(okr *PerformanceRecord, periods map[string]*PerformanceRecord, now time.Time) *float64 {
+	keys := []string{}
+	if okr.QuarterId != "" {
+		keys = append(keys, perfSKPrefix+"QUARTER#"+okr.QuarterId)
+	}
+	keys = append(keys, perfSKPrefix+"CYCLE#"+okr.CycleId)
+
+	for _, key := range keys {
+		period, loaded := periods[key]
+		if !loaded && periods != nil {
+			continue
+		}
+		if !loaded {
+			rec, err := svc.getRecordByGSI1(key)
+			if err != nil {
+				svc.logger.Printf("failed to load period %s for OKR %s: %v", key, toString(okr.Data["id"]), err)
+				continue
+			}
+			period = rec
+		}
+		if period == nil {
+			continue
+		}
+		if expected, ok := expectedProgress(toString(period.Data["startDate"]), toString(period.Data["endDate"]), now); ok {
+			return &expected
+		}
+	}
+	return nil
+}
+
+// recalculateOKRProgress rolls keyResults up into the OKR and records a progress snapshot when the
+// roll-up changed. trigger is what caused it: "created" or the key result ID. The caller passes the
+// key results it just wrote, since a query straight after the write may not return them yet.
+func (svc *PerformanceService) recalculateOKRProgress(okr *PerformanceRecord, keyResults []map[string]interface{}, trigger string) (*OKRProgress, error) {
+	now := time.Now().UTC()
+	progress := rollUpOKRProgress(keyResults, svc.okrPeriodExpectedProgress(okr, nil, now))
+
+	changed := !hasValue(okr.Data, "progress") ||
+		toFloat(okr.Data["progress"]) != progress.Progress ||
+		(progress.ComputedConfidence != nil && toFloat(okr.Data["computedConfidence"]) != *progress.ComputedConfidence)
+
+	patch := map[string]interface{}{
+		"progress":          progress.Progress,
+		"keyResultCount":    len(keyResults),
+		"progressUpdatedAt": now.Format(time.RFC3339),
+	}
+	if progress.ComputedConfidence != nil {
+		patch["computedConfidence"] = *progress.ComputedConfidence
+		patch["confidenceSource"] = progress.ConfidenceSource
+	}
+	if _, err := svc.patchRecord(okr, patch); err != nil {
+		return nil, err
+	}
+
+	if changed {
+		if err := svc.putOKRProgressSnapshot(okr, progress, trigger, now); err != nil {
+			return nil, err
+		}
+	}
+	return &progress, nil
+}
+
+// okrPayload is the OKR's payload with its health and expectedProgress as of now. An OKR created
+// before the roll-up existed has no stored progress, so it is rolled up from its key results here.
+// periods and keyResultsByOKR hold the records a caller has already loaded; when either is nil the
+// missing records are read.
+func (svc *PerformanceService) okrPayload(record *PerformanceRecord, periods map[string]*PerformanceRecord, keyResultsByOKR map[string][]map[string]interface{}) map[string]interface{} {
+	now := time.Now().UTC()
+	payload := svc.toPayload(record)
+	okrID := toString(record.Data["id"])
+	expected := svc.okrPeriodExpectedProgress(record, periods, now)
+
+	if !hasValue(payload, "progress") {
+		keyResults, loaded := keyResultsByOKR[okrID]
+		if !loaded && keyResultsByOKR == nil {
+			var err error
+			keyResults, err = svc.getKeyResultsForOKR(okrID, record.OrganizationId, record.CycleId)
+			if err != nil {
+				svc.logger.Printf("failed to load key results for OKR %s: %v", okrID, err)
+			}
+		}
+		rollUp := rollUpOKRProgress(keyResults, expected)
+		payload["progress"] = rollUp.Progress
+		payload["keyResultCount"] = len(keyResults)
+		if rollUp.ComputedConfidence != nil {
+			payload["computedConfidence"] = *rollUp.ComputedConfidence
+			payload["confidenceSource"] = rollUp.ConfidenceSource
+		}
+	}
+
+	delete(payload, "expectedProgress")
+	if expected != nil {
+		e := roundProgress(*expected)
+		expected = &e
+		payload["expectedProgress"] = e
+	}
+	payload["health"] = okrHealth(toFloat(payload["progress"]), expected)
+	if strings.EqualFold(toString(payload["status"]), "COMPLETED") {
+		payload["health"] = OKRHealthCompleted
+	}
+	return payload
+}
+
+// okrReadRecords indexes the cycles, quarters and key results among records already loaded, for
+// okrPayload
+func (svc *PerformanceService) okrReadRecords(records []PerformanceRecord) (map[string]*PerformanceRecord, map[string][]map[string]interface{}) {
+	periods := map[string]*PerformanceRecord{}
+	keyResultsByOKR := map[string][]map[string]interface{}{}
+	for i := range records {
+		switch records[i].EntityType {
+		case perfEntityCycle, perfEntityQuarter:
+			periods[records[i].GSI1PK] = &records[i]
+		case perfEntityKeyResult:
+			keyResultsByOKR[records[i].ParentId] = append(keyResultsByOKR[records[i].ParentId], svc.toPayload(&records[i]))
+		}
+	}
+	return periods, keyResultsByOKR
+}
+
+func okrProgressPrefix(okrID string) string {
+	return fmt.Sprintf("%sOKR_PROGRESS#%s#", perfSKPrefix, okrID)
+}
+
+func (svc *PerformanceService) putOKRProgressSnapshot(okr *PerformanceRecord, progress OKRProgress, trigger string, now time.Time) error {
+	okrID := toString(okr.Data["id"])
+	recordedAt := now.Format(time.RFC3339)
+
+	keyResults := make([]interface{}, 0, len(progress.KeyResults))
+	for _, kr := range progress.KeyResults {
+		keyResults = append(keyResults, map[string]interface{}{
+			"id":           kr.ID,
+			"currentValue": kr.CurrentValue,
+			"progress":     kr.Progress,
+		})
+	}
+	data := map[string]interface{}{
+		"okrId":      okrID,
+		"progress":   progress.Progress,
+		"health":     progress.Health,
+		"trigger":    trigger,
+		"keyResults": keyResults,
+		"recordedAt": recordedAt,
+	}
+	if progress.ExpectedProgress != nil {
+		data["expectedProgress"] = *progress.ExpectedProgress
+	}
+	if progress.ComputedConfidence != nil {
+		data["computedConfidence"] = *progress.ComputedConfidence
+	}
+
+	return svc.putRecord(PerformanceRecord{
+		PK:             okr.PK,
+		SK:             okrProgressPrefix(okrID) + recordedAt + "#" + svc.generateID("snapshot"),
+		EntityType:     perfEntityOKRProgress,
+		OrganizationId: okr.OrganizationId,
+		CycleId:        okr.CycleId,
+		QuarterId:      okr.QuarterId,
+		ParentId:       okrID,
+		CreatedAt:      recordedAt,
+		UpdatedAt:      recordedAt,
+		Data:           data,
+	})
+}
+
+// getOKRProgressHistory returns the OKR's progress snapshots, oldest first
+func (svc *PerformanceService) getOKRProgressHistory(okrID string, orgID string) ([]map[string]interface{}, error) {
+	records, err := svc.queryByOrgPrefix(orgID, okrProgressPrefix(okrID))
+	if err != nil {
+		return nil, err
+	}
+	history := make([]map[string]interface{}, 0, len(records))
+	for _, r := range records {
+		if r.EntityType == perfEntityOKRProgress {
+			history = append(history, r.Data)
+		}
+	}
+	return history, nil
+}
+
+func (svc *PerformanceService) deleteOKRProgressHistory(okrID string, orgID string) error {
+	records, err := svc.queryByOrgPrefix(orgID, okrProgressPrefix(okrID))
+	if err != nil {
+		return err
+	}
+	for i := range records {
+		if err := svc.deleteRecord(&records[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasValue(data map[string]interface{}, key string) bool {
+	v, ok := data[key]
+	return ok && v != nil && v != ""
+}
+
+func roundProgress(v float64) float64 {
+	return math.Round(v*10) / 10
+}
@@ -0,0 +1,302 @@
+package Companylib
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	awsclients "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/clients"
+	"github.com/stretchr/testify/assert"
+)
+
+func newOKRTestService(ddbClient *awsclients.MockDynamodbClient) *PerformanceService {
+	return &PerformanceService{
+		ctx:                 context.Background(),
+		dynamodbClient:      ddbClient,
+		logger:              log.New(&bytes.Buffer{}, "TEST:", 0),
+		OrgPerformanceTable: "OrgPerformanceTable-test",
+	}
+}
+
+func recordFromPut(t *testing.T, input dynamodb.PutItemInput) PerformanceRecord {
+	var record PerformanceRecord
+	assert.NoError(t, attributevalue.UnmarshalMap(input.Item, &record))
+	return record
+}
+
+func TestKeyResultProgress(t *testing.T) {
+	tests := []struct {
+		name string
+		kr   map[string]interface{}
+		want float64
+	}{
+		{"increase part way", map[string]interface{}{"type": "INCREASE", "startValue": 10.0, "currentValue": 40.0, "targetValue": 70.0}, 50},
+		{"increase past target is capped", map[string]interface{}{"type": "INCREASE", "startValue": 0.0, "currentValue": 120.0, "targetValue": 100.0}, 100},
+		{"increase below start is floored", map[string]interface{}{"type": "INCREASE", "startValue": 10.0, "currentValue": 5.0, "targetValue": 20.0}, 0},
+		{"decrease part way", map[string]interface{}{"type": "DECREASE", "startValue": 20.0, "currentValue": 14.0, "targetValue": 5.0}, 40},
+		{"binary not done", map[string]interface{}{"type": "BINARY", "currentValue": 0.0}, 0},
+		{"binary done", map[string]interface{}{"type": "BINARY", "currentValue": 1.0}, 100},
+		{"legacy key result without type or start", map[string]interface{}{"currentValue": 20.0, "targetValue": 50.0}, 40},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, keyResultProgress(tt.kr))
+		})
+	}
+}
+
+func TestNormalizeKeyResult(t *testing.T) {
+	t.Run("It should fill in defaults and progress", func(t *testing.T) {
+		kr := map[string]interface{}{"name": "KR1", "targetValue": 50.0}
+		assert.NoError(t, normalizeKeyResult(kr))
+		assert.Equal(t, KeyResultTypeIncrease, kr["type"])
+		assert.Equal(t, 1.0, kr["weight"])
+		assert.Equal(t, 0.0, kr["startValue"])
+		assert.Equal(t, 0.0, kr["currentValue"])
+		assert.Equal(t, 0.0, kr["progress"])
+	})
+
+	t.Run("It should start a decrease from its current value", func(t *testing.T) {
+		kr := map[string]interface{}{"type": "decrease", "currentValue": 30.0, "targetValue": 10.0}
+		assert.NoError(t, normalizeKeyResult(kr))
+		assert.Equal(t, KeyResultTypeDecrease, kr["type"])
+		assert.Equal(t, 30.0, kr["startValue"])
+	})
+
+	invalid := []map[string]interface{}{
+		{"type": "SOMETIMES", "targetValue": 1.0},
+		{"targetValue": 10.0, "weight": 0.0},
+		{"startValue": 10.0, "targetValue": 5.0},
+		{"type": "DECREASE", "startValue": 5.0, "targetValue": 10.0},
+		{"type": "DECREASE", "targetValue": 10.0},
+		{"targetValue": 10.0, "confidenceScore": 11.0},
+	}
+	for _, kr := range invalid {
+		assert.True(t, errors.Is(normalizeKeyResult(kr), ErrKeyResultInvalid), "%v", kr)
+	}
+}
+
+func TestRollUpOKRProgress(t *testing.T) {
+	keyResults := []map[string]interface{}{
+		{"id": "kr-1", "type": "INCREASE", "startValue": 0.0, "currentValue": 50.0, "targetValue": 100.0, "weight": 3.0},
+		{"id": "kr-2", "type": "BINARY", "currentValue": 1.0, "targetValue": 1.0, "weight": 1.0},
+	}
+
+	t.Run("It should weight the key results", func(t *testing.T) {
+		progress := rollUpOKRProgress(keyResults, nil)
+		assert.Equal(t, 62.5, progress.Progress)
+		assert.Equal(t, OKRHealthOnTrack, progress.Health)
+		assert.Nil(t, progress.ComputedConfidence)
+		assert.Len(t, progress.KeyResults, 2)
+	})
+
+	t.Run("It should derive health and confidence from the time elapsed", func(t *testing.T) {
+		expected := 80.0
+		progress := rollUpOKRProgress(keyResults, &expected)
+		assert.Equal(t, OKRHealthAtRisk, progress.Health)
+		assert.Equal(t, 7.8, *progress.ComputedConfidence)
+		assert.Equal(t, OKRConfidenceFromPace, progress.ConfidenceSource)
+
+		expected = 100
+		assert.Equal(t, OKRHealthBehind, rollUpOKRProgress(keyResults, &expected).Health)
+	})
+
+	t.Run("It should prefer the key results' own confidence", func(t *testing.T) {
+		expected := 80.0
+		withConfidence := []map[string]interface{}{
+			{"id": "kr-1", "targetValue": 10.0, "currentValue": 10.0, "confidenceScore": 9.0, "weight": 1.0},
+			{"id": "kr-2", "targetValue": 10.0, "currentValue": 10.0, "confidenceScore": 6.0, "weight": 2.0},
+		}
+		progress := rollUpOKRProgress(withConfidence, &expected)
+		assert.Equal(t, 100.0, progress.Progress)
+		assert.Equal(t, OKRHealthCompleted, progress.Health)
+		assert.Equal(t, 7.0, *progress.ComputedConfidence)
+		assert.Equal(t, OKRConfidenceFromKeyResults, progress.ConfidenceSource)
+	})
+
+	t.Run("It should report no progress without key results", func(t *testing.T) {
+		progress := rollUpOKRProgress(nil, nil)
+		assert.Equal(t, 0.0, progress.Progress)
+		assert.Empty(t, progress.KeyResults)
+	})
+}
+
+func TestExpectedProgress(t *testing.T) {
+	now := time.Date(2027, 2, 15, 0, 0, 0, 0, time.UTC)
+
+	expected, ok := expectedProgress("2027-01-01", "2027-03-31", now)
+	assert.True(t, ok)
+	assert.InDelta(t, 50.0, expected, 0.1)
+
+	expected, ok = expectedProgress("2027-03-01", "2027-03-31", now)
+	assert.True(t, ok)
+	assert.Equal(t, 0.0, expected)
+
+	_, ok = expectedProgress("", "2027-03-31", now)
+	assert.False(t, ok)
+}
+
+func TestUpdateKeyResult(t *testing.T) {
+	okrData := map[string]interface{}{"id": "okr-1", "objective": "Grow", "progress": 0.0, "confidenceScore": 4.0}
+	okr := PerformanceRecord{
+		PK: "ORG#org-1", SK: "PERF#CYCLE#c-1#OKR#okr-1", GSI1PK: "PERF#OKR#okr-1",
+		EntityType: perfEntityOKR, OrganizationId: "ORG#org-1", CycleId: "c-1", Data: okrData,
+	}
+	kr1 := PerformanceRecord{
+		PK: "ORG#org-1", SK: "PERF#CYCLE#c-1#OKR#okr-1#KR#kr-1", GSI1PK: "PERF#KEYRESULT#kr-1",
+		EntityType: perfEntityKeyResult, OrganizationId: "ORG#org-1", CycleId: "c-1", ParentId: "okr-1",
+		Data: map[string]interface{}{"id": "kr-1", "okrId": "okr-1", "type": "INCREASE", "startValue": 0.0, "currentValue": 0.0, "targetValue": 100.0, "weight": 1.0},
+	}
+	kr2 := PerformanceRecord{
+		PK: "ORG#org-1", SK: "PERF#CYCLE#c-1#OKR#okr-1#KR#kr-2", GSI1PK: "PERF#KEYRESULT#kr-2",
+		EntityType: perfEntityKeyResult, OrganizationId: "ORG#org-1", CycleId: "c-1", ParentId: "okr-1",
+		Data: map[string]interface{}{"id": "kr-2", "okrId": "okr-1", "type": "BINARY", "currentValue": 0.0, "targetValue": 1.0, "weight": 1.0},
+	}
+	cycle := PerformanceRecord{
+		PK: "ORG#org-1", SK: "PERF#CYCLE#c-1", GSI1PK: "PERF#CYCLE#c-1",
+		EntityType: perfEntityCycle, OrganizationId: "ORG#org-1", Data: map[string]interface{}{"id": "c-1"},
+	}
+
+	t.Run("It should roll the new value up into the OKR and snapshot it", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs: []dynamodb.QueryOutput{
+				reviewQueryOutput(t, kr1),
+				reviewQueryOutput(t, okr),
+				reviewQueryOutput(t, kr1, kr2), // stale read: kr-1 still at 0
+				reviewQueryOutput(t, cycle),
+			},
+			QueryErrors:    []error{nil, nil, nil, nil},
+			PutItemOutputs: []dynamodb.PutItemOutput{{}, {}, {}},
+			PutItemErrors:  []error{nil, nil, nil},
+		}
+		svc := newOKRTestService(&ddbClient)
+
+		result, err := svc.UpdateKeyResult("kr-1", map[string]interface{}{"currentValue": 60.0, "progress": 99.0})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 60.0, result["progress"])
+		progress := result["okrProgress"].(*OKRProgress)
+		assert.Equal(t, 30.0, progress.Progress)
+
+		assert.Len(t, ddbClient.PutItemInputs, 3)
+		updatedOKR := recordFromPut(t, ddbClient.PutItemInputs[1])
+		assert.Equal(t, "PERF#CYCLE#c-1#OKR#okr-1", updatedOKR.SK)
+		assert.Equal(t, 30.0, updatedOKR.Data["progress"])
+		assert.Equal(t, 2.0, updatedOKR.Data["keyResultCount"])
+		assert.Equal(t, 4.0, updatedOKR.Data["confidenceScore"]) // the owner's score is kept
+		assert.NotContains(t, updatedOKR.Data, "health")
+
+		snapshot := recordFromPut(t, ddbClient.PutItemInputs[2])
+		assert.Equal(t, perfEntityOKRProgress, snapshot.EntityType)
+		assert.Contains(t, snapshot.SK, "PERF#OKR_PROGRESS#okr-1#")
+		assert.Equal(t, "kr-1", snapshot.Data["trigger"])
+	})
+
+	t.Run("It should reject an invalid patch without writing", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs: []dynamodb.QueryOutput{reviewQueryOutput(t, kr1)},
+			QueryErrors:  []error{nil},
+		}
+		svc := newOKRTestService(&ddbClient)
+
+		_, err := svc.UpdateKeyResult("kr-1", map[string]interface{}{"targetValue": -5.0})
+
+		assert.True(t, errors.Is(err, ErrKeyResultInvalid))
+		assert.Empty(t, ddbClient.PutItemInputs)
+	})
+}
+
+func TestOKRPayload(t *testing.T) {
+	now := time.Now().UTC()
+	cycle := PerformanceRecord{
+		GSI1PK: "PERF#CYCLE#c-1", EntityType: perfEntityCycle, OrganizationId: "ORG#org-1",
+		Data: map[string]interface{}{
+			"id":        "c-1",
+			"startDate": now.AddDate(0, 0, -50).Format("2006-01-02"),
+			"endDate":   now.AddDate(0, 0, 49).Format("2006-01-02"),
+		},
+	}
+	periods := map[string]*PerformanceRecord{cycle.GSI1PK: &cycle}
+
+	t.Run("It should work out health as of now rather than return the stored value", func(t *testing.T) {
+		okr := PerformanceRecord{EntityType: perfEntityOKR, CycleId: "c-1", Data: map[string]interface{}{
+			"id": "okr-1", "progress": 10.0, "health": OKRHealthOnTrack, "expectedProgress": 5.0, "confidenceScore": 8.0,
+		}}
+		svc := newOKRTestService(&awsclients.MockDynamodbClient{})
+
+		payload := svc.okrPayload(&okr, periods, nil)
+
+		assert.Equal(t, OKRHealthBehind, payload["health"])
+		assert.InDelta(t, 50.0, payload["expectedProgress"], 1)
+		assert.Equal(t, 8.0, payload["confidenceScore"])
+	})
+
+	t.Run("It should roll up an OKR created before progress was stored", func(t *testing.T) {
+		okr := PerformanceRecord{EntityType: perfEntityOKR, CycleId: "c-1", OrganizationId: "ORG#org-1", Data: map[string]interface{}{"id": "okr-1"}}
+		kr := PerformanceRecord{
+			PK: "ORG#org-1", SK: "PERF#CYCLE#c-1#OKR#okr-1#KR#kr-1", EntityType: perfEntityKeyResult, CycleId: "c-1", ParentId: "okr-1",
+			Data: map[string]interface{}{"id": "kr-1", "currentValue": 45.0, "targetValue": 100.0},
+		}
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs: []dynamodb.QueryOutput{reviewQueryOutput(t, kr)},
+			QueryErrors:  []error{nil},
+		}
+		svc := newOKRTestService(&ddbClient)
+
+		payload := svc.okrPayload(&okr, periods, nil)
+
+		assert.Equal(t, 45.0, payload["progress"])
+		assert.Equal(t, 1, payload["keyResultCount"])
+		assert.Equal(t, OKRConfidenceFromPace, payload["confidenceSource"])
+		assert.Contains(t, payload, "computedConfidence")
+		assert.NotContains(t, payload, "confidenceScore")
+		assert.Empty(t, ddbClient.PutItemInputs)
+	})
+
+	t.Run("It should report a completed OKR as completed", func(t *testing.T) {
+		okr := PerformanceRecord{EntityType: perfEntityOKR, CycleId: "c-1", Data: map[string]interface{}{"id": "okr-1", "status": "COMPLETED", "progress": 0.0}}
+		svc := newOKRTestService(&awsclients.MockDynamodbClient{})
+
+		assert.Equal(t, OKRHealthCompleted, svc.okrPayload(&okr, periods, nil)["health"])
+	})
+}
+
+func TestCycleAnalyticsOKRProgress(t *testing.T) {
+	now := time.Now().UTC()
+	cycle := PerformanceRecord{
+		GSI1PK: "PERF#CYCLE#c-1", EntityType: perfEntityCycle, OrganizationId: "ORG#org-1",
+		Data: map[string]interface{}{
+			"id":        "c-1",
+			"startDate": now.AddDate(0, 0, -50).Format("2006-01-02"),
+			"endDate":   now.AddDate(0, 0, 49).Format("2006-01-02"),
+		},
+	}
+	related := []PerformanceRecord{
+		{EntityType: perfEntityOKR, CycleId: "c-1", Data: map[string]interface{}{"id": "okr-1", "status": "ACTIVE", "confidenceScore": 9.0}},
+		{EntityType: perfEntityKeyResult, CycleId: "c-1", ParentId: "okr-1", Data: map[string]interface{}{"id": "kr-1", "currentValue": 45.0, "targetValue": 100.0}},
+		{EntityType: perfEntityOKR, CycleId: "c-1", Data: map[string]interface{}{"id": "okr-2", "status": "ACTIVE"}},
+		{EntityType: perfEntityKeyResult, CycleId: "c-1", ParentId: "okr-2", Data: map[string]interface{}{"id": "kr-2", "currentValue": 10.0, "targetValue": 100.0}},
+		{EntityType: perfEntityOKR, CycleId: "c-1", QuarterId: "q-2", Data: map[string]interface{}{"id": "okr-3", "status": "COMPLETED"}},
+	}
+	svc := newOKRTestService(&awsclients.MockDynamodbClient{})
+
+	analytics := svc.buildPerformanceAnalytics(&cycle, related, "", KPITrendGranularityMonth)
+	summary := analytics["summary"].(map[string]interface{})
+	assert.Equal(t, 3, summary["totalOKRs"])
+	assert.Equal(t, 1, summary["okrsOnTrack"])
+	assert.Equal(t, 1, summary["okrsBehind"])
+	assert.Equal(t, 1, summary["okrsCompleted"])
+	assert.Equal(t, 18.3, summary["averageOKRProgress"])
+
+	okrs := analytics["okrProgress"].([]map[string]interface{})
+	assert.Equal(t, "okr-3", okrs[0]["okrId"]) // least progress first
+	assert.Equal(t, "okr-2", okrs[1]["okrId"])
+
+	quarter := svc.buildPerformanceAnalytics(&cycle, related, "q-2", KPITrendGranularityMonth)
+	assert.Equal(t, 1, quarter["summary"].(map[string]interface{})["totalOKRs"])
+}
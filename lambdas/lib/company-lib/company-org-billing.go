@@ -0,0 +1,827 @@
+package Companylib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultBillingCurrency is the currency organizations are invoiced in unless they choose
+	// another currency their plan is priced in
+	DefaultBillingCurrency = "AUD"
+
+	// BillingHomeCountry is the country the platform bills from. Organizations outside
+	// this country that supply a TaxID are invoiced under reverse charge (no tax added).
+	BillingHomeCountry = "AU"
+
+	// InvoicePaymentTermsDays is the number of days an invoice can remain unpaid before the
+	// organization is marked OVERDUE
+	InvoicePaymentTermsDays = 7
+
+	// BillingGracePeriodDays is the number of days after an invoice falls due before an
+	// overdue organization is SUSPENDED
+	BillingGracePeriodDays = 14
+)
+
+// InvoiceStatus represents the payment state of an invoice
+type InvoiceStatus string
+
+const (
+	InvoiceStatusOpen InvoiceStatus = "OPEN" // Issued and awaiting payment
+	InvoiceStatusPaid InvoiceStatus = "PAID"
+	InvoiceStatusVoid InvoiceStatus = "VOID"
+)
+
+// InvoiceType represents the reason an invoice was raised
+type InvoiceType string
+
+const (
+	InvoiceTypeSubscription InvoiceType = "SUBSCRIPTION" // First invoice when moving onto a paid plan
+	InvoiceTypePlanChange   InvoiceType = "PLAN_CHANGE"  // Mid-cycle plan or billing frequency change
+	InvoiceTypeRenewal      InvoiceType = "RENEWAL"      // Scheduled renewal at NextBillingDate
+)
+
+// InvoiceLineItemType represents the kind of charge on an invoice line
+type InvoiceLineItemType string
+
+const (
+	InvoiceLineItemPlan      InvoiceLineItemType = "PLAN"      // Full period plan charge
+	InvoiceLineItemProration InvoiceLineItemType = "PRORATION" // Partial period charge for a new plan
+	InvoiceLineItemCredit    InvoiceLineItemType = "CREDIT"    // Unused time on a previous plan or carried credit balance
+)
+
+// PaymentStatus represents the outcome of a charge attempt reported by a payment provider
+type PaymentStatus string
+
+const (
+	PaymentStatusSucceeded PaymentStatus = "SUCCEEDED"
+	PaymentStatusFailed    PaymentStatus = "FAILED"
+	PaymentStatusPending   PaymentStatus = "PENDING" // Settled outside the provider, e.g. bank transfer
+)
+
+// InvoiceLineItem represents a single line on an invoice
+type InvoiceLineItem struct {
+	Type        InvoiceLineItemType `dynamodbav:"Type" json:"type"`
+	Description string              `dynamodbav:"Description" json:"description"`
+	PlanID      string              `dynamodbav:"PlanID,omitempty" json:"planId,omitempty"`
+	PeriodStart string              `dynamodbav:"PeriodStart,omitempty" json:"periodStart,omitempty"`
+	PeriodEnd   string              `dynamodbav:"PeriodEnd,omitempty" json:"periodEnd,omitempty"`
+	Amount      float64             `dynamodbav:"Amount" json:"amount"`
+}
+
+// Invoice represents an invoice stored in the Organization table
+type Invoice struct {
+	PK string `dynamodbav:"PK" json:"-"` // ORG#{organizationId}
+	SK string `dynamodbav:"SK" json:"-"` // INVOICE#{invoiceId}
+
+	OrganizationId string        `dynamodbav:"OrganizationId" json:"organizationId"`
+	InvoiceId      string        `dynamodbav:"InvoiceId" json:"invoiceId"`
+	InvoiceType    InvoiceType   `dynamodbav:"InvoiceType" json:"invoiceType"`
+	Status         InvoiceStatus `dynamodbav:"Status" json:"status"`
+	Currency       string        `dynamodbav:"Currency" json:"currency"`
+
+	PlanID      string      `dynamodbav:"PlanID" json:"planId"`
+	BillingPlan BillingPlan `dynamodbav:"BillingPlan" json:"billingPlan"`
+	PeriodStart string      `dynamodbav:"PeriodStart" json:"periodStart"`
+	PeriodEnd   string      `dynamodbav:"PeriodEnd" json:"periodEnd"`
+
+	LineItems []InvoiceLineItem `dynamodbav:"LineItems" json:"lineItems"`
+
+	// Amounts
+	Subtotal       float64 `dynamodbav:"Subtotal" json:"subtotal"` // Sum of all line items
+	PromoCode      string  `dynamodbav:"PromoCode,omitempty" json:"promoCode,omitempty"`
+	DiscountAmount float64 `dynamodbav:"DiscountAmount" json:"discountAmount"` // Promo discount on charges
+	TaxLabel       string  `dynamodbav:"TaxLabel,omitempty" json:"taxLabel,omitempty"`
+	TaxRate        float64 `dynamodbav:"TaxRate" json:"taxRate"`
+	TaxAmount      float64 `dynamodbav:"TaxAmount" json:"taxAmount"`
+	ReverseCharge  bool    `dynamodbav:"ReverseCharge" json:"reverseCharge"`
+	Total          float64 `dynamodbav:"Total" json:"total"`
+	CreditIssued   float64 `dynamodbav:"CreditIssued" json:"creditIssued"` // Credit carried forward to the organization
+
+	// Billing details captured at issue time
+	TaxID   string `dynamodbav:"TaxID,omitempty" json:"taxId,omitempty"`
+	Country string `dynamodbav:"Country,omitempty" json:"country,omitempty"`
+
+	// Payment tracking
+	IssuedAt         string `dynamodbav:"IssuedAt" json:"issuedAt"`
+	DueDate          string `dynamodbav:"DueDate" json:"dueDate"`
+	PaidAt           string `dynamodbav:"PaidAt,omitempty" json:"paidAt,omitempty"`
+	PaymentProvider  string `dynamodbav:"PaymentProvider,omitempty" json:"paymentProvider,omitempty"`
+	PaymentReference string `dynamodbav:"PaymentReference,omitempty" json:"paymentReference,omitempty"`
+	PaymentAttempts  int    `dynamodbav:"PaymentAttempts" json:"paymentAttempts"`
+	LastPaymentError string `dynamodbav:"LastPaymentError,omitempty" json:"lastPaymentError,omitempty"`
+
+	CreatedAt string `dynamodbav:"CreatedAt" json:"createdAt"`
+	UpdatedAt string `dynamodbav:"UpdatedAt" json:"updatedAt"`
+}
+
+// PaymentResult is returned by a payment provider after a charge attempt
+type PaymentResult struct {
+	Status        PaymentStatus `json:"status"`
+	Reference     string        `json:"reference,omitempty"`
+	FailureReason string        `json:"failureReason,omitempty"`
+}
+
+// RenewalResult summarises what ProcessRenewal did for a single organization
+type RenewalResult struct {
+	OrganizationId  string           `json:"organizationId"`
+	Invoice         *Invoice         `json:"invoice,omitempty"`
+	PaymentStatus   PaymentStatus    `json:"paymentStatus,omitempty"`
+	BillingStatus   OrgBillingStatus `json:"billingStatus"`
+	NextBillingDate string           `json:"nextBillingDate"`
+	Skipped         bool             `json:"skipped"`
+}
+
+// PaymentProvider charges invoices against an organization's payment method.
+// Implementations must be safe to call again for an invoice that previously failed.
+type PaymentProvider interface {
+	Name() string
+	Charge(ctx context.Context, org Organization, invoice Invoice) (PaymentResult, error)
+}
+
+// ManualPaymentProvider is used when invoices are settled outside the platform
+// (bank transfer, purchase order). Every charge is reported as pending.
+type ManualPaymentProvider struct{}
+
+func (p *ManualPaymentProvider) Name() string { return "MANUAL" }
+
+func (p *ManualPaymentProvider) Charge(ctx context.Context, org Organization, invoice Invoice) (PaymentResult, error) {
+	return PaymentResult{Status: PaymentStatusPending}, nil
+}
+
+// LocalPaymentProvider is an in-memory provider for local runs and tests.
+// Charges succeed unless the organization is listed in FailingOrgs.
+type LocalPaymentProvider struct {
+	FailingOrgs map[string]bool
+	Charges     []Invoice
+}
+
+func (p *LocalPaymentProvider) Name() string { return "LOCAL" }
+
+func (p *LocalPaymentProvider) Charge(ctx context.Context, org Organization, invoice Invoice) (PaymentResult, error) {
+	p.Charges = append(p.Charges, invoice)
+	if p.FailingOrgs[org.OrganizationId] {
+		return PaymentResult{Status: PaymentStatusFailed, FailureReason: "card declined"}, nil
+	}
+	return PaymentResult{
+		Status:    PaymentStatusSucceeded,
+		Reference: fmt.Sprintf("local_%s", invoice.InvoiceId),
+	}, nil
+}
+
+// CreatePaymentProvider returns the payment provider configured by name (PAYMENT_PROVIDER env var)
+func CreatePaymentProvider(name string) PaymentProvider {
+	switch strings.ToUpper(name) {
+	case "LOCAL":
+		return &LocalPaymentProvider{}
+	default:
+		return &ManualPaymentProvider{}
+	}
+}
+
+// taxRule describes the indirect tax charged for a billing country
+type taxRule struct {
+	Label string
+	Rate  float64
+}
+
+var billingTaxRules = map[string]taxRule{
+	"AU": {Label: "GST", Rate: 0.10},
+	"NZ": {Label: "GST", Rate: 0.15},
+	"SG": {Label: "GST", Rate: 0.09},
+	"IN": {Label: "GST", Rate: 0.18},
+	"CA": {Label: "GST", Rate: 0.05},
+	"GB": {Label: "VAT", Rate: 0.20},
+	"IE": {Label: "VAT", Rate: 0.23},
+	"DE": {Label: "VAT", Rate: 0.19},
+	"FR": {Label: "VAT", Rate: 0.20},
+	"NL": {Label: "VAT", Rate: 0.21},
+}
+
+var billingCountryAliases = map[string]string{
+	"AUSTRALIA":      "AU",
+	"NEW ZEALAND":    "NZ",
+	"SINGAPORE":      "SG",
+	"INDIA":          "IN",
+	"CANADA":         "CA",
+	"UNITED KINGDOM": "GB",
+	"UK":             "GB",
+	"IRELAND":        "IE",
+	"GERMANY":        "DE",
+	"FRANCE":         "FR",
+	"NETHERLANDS":    "NL",
+	"UNITED STATES":  "US",
+	"USA":            "US",
+}
+
+// normalizeBillingCountry converts a country name or code into an ISO 3166 alpha-2 code
+func normalizeBillingCountry(country string) string {
+	code := strings.ToUpper(strings.TrimSpace(country))
+	if alias, ok := billingCountryAliases[code]; ok {
+		return alias
+	}
+	return code
+}
+
+// resolveTax returns the tax label and rate for an organization and whether reverse charge applies
+func resolveTax(country, taxID string) (string, float64, bool) {
+	code := normalizeBillingCountry(country)
+	if code == "" {
+		code = BillingHomeCountry
+	}
+	if code != BillingHomeCountry && strings.TrimSpace(taxID) != "" {
+		return "", 0, true
+	}
+	rule, ok := billingTaxRules[code]
+	if !ok {
+		return "", 0, false
+	}
+	return rule.Label, rule.Rate, false
+}
+
+// roundCurrency rounds an amount to whole cents
+func roundCurrency(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// planPrice returns the price of a plan in the given currency and billing frequency. Callers validate
+// the currency up front, an unpriced currency is charged as zero.
+func planPrice(plan *SubscriptionPlan, currency string, billingPlan BillingPlan) float64 {
+	price, _ := plan.PriceFor(currency, billingPlan)
+	return price
+}
+
+// orgBillingCurrency returns the currency an organization is invoiced in
+func orgBillingCurrency(org Organization) string {
+	if org.BillingCurrency == "" {
+		return DefaultBillingCurrency
+	}
+	return org.BillingCurrency
+}
+
+// billingPeriodEnd returns the end of a billing period starting at start
+func billingPeriodEnd(start time.Time, billingPlan BillingPlan) time.Time {
+	if billingPlan == BillingPlanYearly {
+		return start.AddDate(1, 0, 0)
+	}
+	return start.AddDate(0, 1, 0)
+}
+
+// billingPeriodStart returns the start of the billing period ending at end
+func billingPeriodStart(end time.Time, billingPlan BillingPlan) time.Time {
+	if billingPlan == BillingPlanYearly {
+		return end.AddDate(-1, 0, 0)
+	}
+	return end.AddDate(0, -1, 0)
+}
+
+// remainingPeriodFraction returns the unused share of the period [start, end) at now, clamped to [0, 1]
+func remainingPeriodFraction(start, end, now time.Time) float64 {
+	total := end.Sub(start)
+	if total <= 0 {
+		return 0
+	}
+	fraction := float64(end.Sub(now)) / float64(total)
+	return math.Max(0, math.Min(1, fraction))
+}
+
+// orgPartitionKey returns the Organization table partition key for an organization ID
+func orgPartitionKey(organizationId string) string {
+	if !strings.HasPrefix(organizationId, "ORG#") {
+		return fmt.Sprintf("ORG#%s", organizationId)
+	}
+	return organizationId
+}
+
+// newInvoiceId builds an invoice ID. Renewal IDs are deterministic per billing period so a
+// re-run of the renewal job cannot raise the same invoice twice.
+func newInvoiceId(invoiceType InvoiceType, periodStart time.Time) string {
+	if invoiceType == InvoiceTypeRenewal {
+		return fmt.Sprintf("INV-%s-RENEWAL", periodStart.Format("20060102"))
+	}
+	return fmt.Sprintf("INV-%s-%s", periodStart.Format("20060102"), strings.ToUpper(uuid.New().String()[:8]))
+}
+
+// BuildInvoice prices a set of line items for an organization: applies the carried credit balance,
+// the active promo discount and tax, and returns the invoice (not persisted) with the credit left
+// over for the organization when credits exceed charges.
+func BuildInvoice(org Organization, invoiceType InvoiceType, planID string, billingPlan BillingPlan, periodStart, periodEnd time.Time, lineItems []InvoiceLineItem, now time.Time) Invoice {
+	if org.CreditBalance > 0 {
+		lineItems = append(lineItems, InvoiceLineItem{
+			Type:        InvoiceLineItemCredit,
+			Description: "Account credit applied",
+			Amount:      -roundCurrency(org.CreditBalance),
+		})
+	}
+
+	var charges, credits float64
+	for i := range lineItems {
+		lineItems[i].Amount = roundCurrency(lineItems[i].Amount)
+		if lineItems[i].Amount >= 0 {
+			charges += lineItems[i].Amount
+		} else {
+			credits += lineItems[i].Amount
+		}
+	}
+
+	// Promo discounts only apply to charges, never to credits
+	var discount float64
+	promoActive := org.AppliedPromoCode != ""
+	if promoActive && org.PromoValidUntil != "" {
+		validUntil, err := time.Parse(time.RFC3339, org.PromoValidUntil)
+		promoActive = err == nil && !now.After(validUntil)
+	}
+	if promoActive {
+		discount = charges*org.PromoDiscountPercent/100 + org.PromoDiscountAmount
+		discount = roundCurrency(math.Min(discount, charges))
+	}
+
+	taxable := roundCurrency(charges + credits - discount)
+	var creditIssued float64
+	if taxable < 0 {
+		creditIssued = -taxable
+		taxable = 0
+	}
+
+	taxLabel, taxRate, reverseCharge := resolveTax(org.Country, org.TaxID)
+	taxAmount := roundCurrency(taxable * taxRate)
+
+	nowStr := now.Format(time.RFC3339)
+	invoiceId := newInvoiceId(invoiceType, periodStart)
+
+	invoice := Invoice{
+		PK:             orgPartitionKey(org.OrganizationId),
+		SK:             fmt.Sprintf("INVOICE#%s", invoiceId),
+		OrganizationId: org.OrganizationId,
+		InvoiceId:      invoiceId,
+		InvoiceType:    invoiceType,
+		Status:         InvoiceStatusOpen,
+		Currency:       orgBillingCurrency(org),
+		PlanID:         planID,
+		BillingPlan:    billingPlan,
+		PeriodStart:    periodStart.Format(time.RFC3339),
+		PeriodEnd:      periodEnd.Format(time.RFC3339),
+		LineItems:      lineItems,
+		Subtotal:       roundCurrency(charges + credits),
+		DiscountAmount: discount,
+		TaxLabel:       taxLabel,
+		TaxRate:        taxRate,
+		TaxAmount:      taxAmount,
+		ReverseCharge:  reverseCharge,
+		Total:          roundCurrency(taxable + taxAmount),
+		CreditIssued:   roundCurrency(creditIssued),
+		TaxID:          org.TaxID,
+		Country:        org.Country,
+		IssuedAt:       nowStr,
+		DueDate:        now.AddDate(0, 0, InvoicePaymentTermsDays).Format(time.RFC3339),
+		CreatedAt:      nowStr,
+		UpdatedAt:      nowStr,
+	}
+	if discount > 0 {
+		invoice.PromoCode = org.AppliedPromoCode
+	}
+
+	return invoice
+}
+
+// BuildPlanChangeLineItems prorates a plan change at now. The unused share of the current period is
+// credited; when the billing frequency is unchanged the new plan is charged for the rest of the
+// current period, otherwise a new full period starts at now. Returns the line items and the
+// billing period the invoice covers.
+func BuildPlanChangeLineItems(org Organization, currentPlan, newPlan *SubscriptionPlan, newBillingPlan BillingPlan, now time.Time) ([]InvoiceLineItem, time.Time, time.Time) {
+	var lineItems []InvoiceLineItem
+
+	periodEnd, err := time.Parse(time.RFC3339, org.NextBillingDate)
+	inPaidCycle := err == nil && org.BillingMode == BillingModePaid && periodEnd.After(now) && currentPlan != nil
+
+	if inPaidCycle {
+		periodStart := billingPeriodStart(periodEnd, org.BillingPlan)
+		fraction := remainingPeriodFraction(periodStart, periodEnd, now)
+		lineItems = append(lineItems, InvoiceLineItem{
+			Type:        InvoiceLineItemCredit,
+			Description: fmt.Sprintf("Unused time on %s (%s)", currentPlan.PlanName, strings.ToLower(string(org.BillingPlan))),
+			PlanID:      currentPlan.PlanID,
+			PeriodStart: now.Format(time.RFC3339),
+			PeriodEnd:   periodEnd.Format(time.RFC3339),
+			Amount:      -planPrice(currentPlan, orgBillingCurrency(org), org.BillingPlan) * fraction,
+		})
+
+		if newBillingPlan == org.BillingPlan {
+			lineItems = append(lineItems, InvoiceLineItem{
+				Type:        InvoiceLineItemProration,
+				Description: fmt.Sprintf("Remaining time on %s (%s)", newPlan.PlanName, strings.ToLower(string(newBillingPlan))),
+				PlanID:      newPlan.PlanID,
+				PeriodStart: now.Format(time.RFC3339),
+				PeriodEnd:   periodEnd.Format(time.RFC3339),
+				Amount:      planPrice(newPlan, orgBillingCurrency(org), newBillingPlan) * fraction,
+			})
+			return lineItems, now, periodEnd
+		}
+	}
+
+	newPeriodEnd := billingPeriodEnd(now, newBillingPlan)
+	lineItems = append(lineItems, InvoiceLineItem{
+		Type:        InvoiceLineItemPlan,
+		Description: fmt.Sprintf("%s (%s)", newPlan.PlanName, strings.ToLower(string(newBillingPlan))),
+		PlanID:      newPlan.PlanID,
+		PeriodStart: now.Format(time.RFC3339),
+		PeriodEnd:   newPeriodEnd.Format(time.RFC3339),
+		Amount:      planPrice(newPlan, orgBillingCurrency(org), newBillingPlan),
+	})
+	return lineItems, now, newPeriodEnd
+}
+
+// chargeInvoice runs a charge attempt for an invoice. Zero-value invoices are settled without calling the provider.
+func (svc *OrgServiceV2) chargeInvoice(org Organization, invoice Invoice) PaymentResult {
+	if invoice.Total <= 0 {
+		return PaymentResult{Status: PaymentStatusSucceeded}
+	}
+
+	provider := svc.PaymentProvider
+	if provider == nil {
+		provider = &ManualPaymentProvider{}
+	}
+
+	result, err := provider.Charge(svc.ctx, org, invoice)
+	if err != nil {
+		svc.logger.Printf("Payment provider %s failed to charge invoice %s: %v", provider.Name(), invoice.InvoiceId, err)
+		return PaymentResult{Status: PaymentStatusFailed, FailureReason: err.Error()}
+	}
+
+	return result
+}
+
+// billingStatusAfterPayment works out the organization billing status after a charge attempt on an invoice
+func billingStatusAfterPayment(current OrgBillingStatus, invoice Invoice, result PaymentResult, now time.Time) OrgBillingStatus {
+	if result.Status == PaymentStatusSucceeded {
+		return OrgBillingStatusActive
+	}
+
+	dueDate, err := time.Parse(time.RFC3339, invoice.DueDate)
+	if err != nil {
+		dueDate = now
+	}
+
+	if now.After(dueDate.AddDate(0, 0, BillingGracePeriodDays)) {
+		return OrgBillingStatusSuspended
+	}
+	if result.Status == PaymentStatusFailed || now.After(dueDate) {
+		return OrgBillingStatusOverdue
+	}
+	if current == OrgBillingStatusTrial || current == "" {
+		return OrgBillingStatusActive
+	}
+	return current
+}
+
+// recordPaymentOutcome stores the result of a charge attempt on the invoice and the organization
+func (svc *OrgServiceV2) recordPaymentOutcome(org Organization, invoice *Invoice, result PaymentResult, now time.Time) (OrgBillingStatus, error) {
+	nowStr := now.Format(time.RFC3339)
+	status := billingStatusAfterPayment(org.OrgBillingStatus, *invoice, result, now)
+
+	provider := "NONE"
+	if svc.PaymentProvider != nil {
+		provider = svc.PaymentProvider.Name()
+	}
+
+	invoice.PaymentAttempts++
+	invoice.PaymentProvider = provider
+	invoice.UpdatedAt = nowStr
+
+	invoiceUpdate := &types.Update{
+		TableName: aws.String(svc.OrganizationTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: invoice.PK},
+			"SK": &types.AttributeValueMemberS{Value: invoice.SK},
+		},
+		ExpressionAttributeNames: map[string]string{
+			"#status": "Status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":one":       &types.AttributeValueMemberN{Value: "1"},
+			":provider":  &types.AttributeValueMemberS{Value: provider},
+			":updatedAt": &types.AttributeValueMemberS{Value: nowStr},
+		},
+		ConditionExpression: aws.String("attribute_exists(PK)"),
+	}
+
+	orgUpdate := &types.Update{
+		TableName: aws.String(svc.OrganizationTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: orgPartitionKey(org.OrganizationId)},
+			"SK": &types.AttributeValueMemberS{Value: "METADATA"},
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":billingStatus": &types.AttributeValueMemberS{Value: string(status)},
+			":updatedAt":     &types.AttributeValueMemberS{Value: nowStr},
+		},
+		ConditionExpression: aws.String("attribute_exists(OrganizationId)"),
+	}
+
+	if result.Status == PaymentStatusSucceeded {
+		invoice.Status = InvoiceStatusPaid
+		invoice.PaidAt = nowStr
+		invoice.PaymentReference = result.Reference
+		invoice.LastPaymentError = ""
+
+		invoiceUpdate.UpdateExpression = aws.String("SET #status = :paid, PaidAt = :paidAt, PaymentReference = :reference, PaymentProvider = :provider, PaymentAttempts = PaymentAttempts + :one, UpdatedAt = :updatedAt REMOVE LastPaymentError")
+		invoiceUpdate.ExpressionAttributeValues[":paid"] = &types.AttributeValueMemberS{Value: string(InvoiceStatusPaid)}
+		invoiceUpdate.ExpressionAttributeValues[":paidAt"] = &types.AttributeValueMemberS{Value: nowStr}
+		invoiceUpdate.ExpressionAttributeValues[":reference"] = &types.AttributeValueMemberS{Value: result.Reference}
+
+		orgUpdate.UpdateExpression = aws.String("SET OrgBillingStatus = :billingStatus, LastPaymentDate = :paidAt, UpdatedAt = :updatedAt REMOVE OutstandingInvoiceId, DunningRemindersSent")
+		orgUpdate.ExpressionAttributeValues[":paidAt"] = &types.AttributeValueMemberS{Value: nowStr}
+	} else {
+		invoice.LastPaymentError = result.FailureReason
+
+		invoiceUpdate.UpdateExpression = aws.String("SET PaymentProvider = :provider, PaymentAttempts = PaymentAttempts + :one, LastPaymentError = :paymentError, UpdatedAt = :updatedAt")
+		invoiceUpdate.ExpressionAttributeNames = nil
+		invoiceUpdate.ExpressionAttributeValues[":paymentError"] = &types.AttributeValueMemberS{Value: result.FailureReason}
+
+		orgUpdate.UpdateExpression = aws.String("SET OrgBillingStatus = :billingStatus, OutstandingInvoiceId = :invoiceId, UpdatedAt = :updatedAt")
+		orgUpdate.ExpressionAttributeValues[":invoiceId"] = &types.AttributeValueMemberS{Value: invoice.InvoiceId}
+	}
+
+	_, err := svc.dynamodbClient.TransactWriteItems(svc.ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{Update: invoiceUpdate},
+			{Update: orgUpdate},
+		},
+	})
+	if err != nil {
+		svc.logger.Printf("Failed to record payment outcome for invoice %s: %v", invoice.InvoiceId, err)
+		return org.OrgBillingStatus, fmt.Errorf("failed to record payment outcome: %w", err)
+	}
+
+	svc.logger.Printf("Invoice %s for organization %s: payment %s, billing status %s", invoice.InvoiceId, org.OrganizationId, result.Status, status)
+	return status, nil
+}
+
+// GetInvoice retrieves a single invoice for an organization
+func (svc *OrgServiceV2) GetInvoice(organizationId string, invoiceId string) (*Invoice, error) {
+	result, err := svc.dynamodbClient.GetItem(svc.ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(svc.OrganizationTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: orgPartitionKey(organizationId)},
+			"SK": &types.AttributeValueMemberS{Value: fmt.Sprintf("INVOICE#%s", invoiceId)},
+		},
+	})
+	if err != nil {
+		svc.logger.Printf("Failed to get invoice: %v", err)
+		return nil, fmt.Errorf("failed to get invoice: %w", err)
+	}
+
+	if result.Item == nil {
+		return nil, fmt.Errorf("invoice not found: %s", invoiceId)
+	}
+
+	var invoice Invoice
+	if err := attributevalue.UnmarshalMap(result.Item, &invoice); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal invoice: %w", err)
+	}
+
+	return &invoice, nil
+}
+
+// MarkInvoicePaid settles an open invoice that was paid outside the payment provider (bank transfer,
+// purchase order) and reactivates the organization
+func (svc *OrgServiceV2) MarkInvoicePaid(organizationId string, invoiceId string, paymentReference string) (*Invoice, error) {
+	org, err := svc.GetOrganization(organizationId)
+	if err != nil {
+		return nil, err
+	}
+
+	invoice, err := svc.GetInvoice(organizationId, invoiceId)
+	if err != nil {
+		return nil, err
+	}
+	if invoice.Status != InvoiceStatusOpen {
+		return nil, fmt.Errorf("invoice %s is %s and cannot be marked as paid", invoiceId, invoice.Status)
+	}
+
+	result := PaymentResult{Status: PaymentStatusSucceeded, Reference: paymentReference}
+	if _, err := svc.recordPaymentOutcome(*org, invoice, result, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+
+	return invoice, nil
+}
+
+// ListInvoices returns all invoices for an organization, newest first
+func (svc *OrgServiceV2) ListInvoices(organizationId string) ([]Invoice, error) {
+	queryInput := &dynamodb.QueryInput{
+		TableName:              aws.String(svc.OrganizationTable),
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk_prefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":        &types.AttributeValueMemberS{Value: orgPartitionKey(organizationId)},
+			":sk_prefix": &types.AttributeValueMemberS{Value: "INVOICE#"},
+		},
+		ScanIndexForward: aws.Bool(false),
+	}
+
+	invoices := []Invoice{}
+	for {
+		result, err := svc.dynamodbClient.Query(svc.ctx, queryInput)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query invoices: %w", err)
+		}
+
+		var page []Invoice
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal invoices: %w", err)
+		}
+		invoices = append(invoices, page...)
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		queryInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	return invoices, nil
+}
+
+// ListOrganizationsDueForBilling returns paid organizations whose billing date has passed or
+// that still have an unpaid invoice outstanding
+func (svc *OrgServiceV2) ListOrganizationsDueForBilling(now time.Time) ([]Organization, error) {
+	scanInput := &dynamodb.ScanInput{
+		TableName:        aws.String(svc.OrganizationTable),
+		FilterExpression: aws.String("SK = :metadata AND BillingMode = :paid AND (NextBillingDate <= :now OR attribute_exists(OutstandingInvoiceId))"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":metadata": &types.AttributeValueMemberS{Value: "METADATA"},
+			":paid":     &types.AttributeValueMemberS{Value: string(BillingModePaid)},
+			":now":      &types.AttributeValueMemberS{Value: now.Format(time.RFC3339)},
+		},
+	}
+
+	organizations := []Organization{}
+	for {
+		result, err := svc.dynamodbClient.Scan(svc.ctx, scanInput)
+		if err != nil {
+			svc.logger.Printf("Failed to scan organizations due for billing: %v", err)
+			return nil, fmt.Errorf("failed to scan organizations due for billing: %w", err)
+		}
+
+		var page []Organization
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal organizations: %w", err)
+		}
+		organizations = append(organizations, page...)
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		scanInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	return organizations, nil
+}
+
+// ProcessRenewal bills a single organization at now. An outstanding invoice is retried first; once
+// the organization is settled and NextBillingDate has passed, the next period's renewal invoice is
+// raised, NextBillingDate is advanced and the invoice is charged. Suspended organizations are not renewed.
+func (svc *OrgServiceV2) ProcessRenewal(org Organization, now time.Time) (*RenewalResult, error) {
+	result := &RenewalResult{
+		OrganizationId:  org.OrganizationId,
+		BillingStatus:   org.OrgBillingStatus,
+		NextBillingDate: org.NextBillingDate,
+	}
+
+	if org.BillingMode != BillingModePaid {
+		result.Skipped = true
+		return result, nil
+	}
+
+	if org.OutstandingInvoiceId != "" {
+		invoice, err := svc.GetInvoice(org.OrganizationId, org.OutstandingInvoiceId)
+		if err != nil {
+			return nil, err
+		}
+
+		if invoice.Status == InvoiceStatusOpen {
+			payment := svc.chargeInvoice(org, *invoice)
+			status, err := svc.recordPaymentOutcome(org, invoice, payment, now)
+			if err != nil {
+				return nil, err
+			}
+			result.Invoice = invoice
+			result.PaymentStatus = payment.Status
+			result.BillingStatus = status
+
+			if payment.Status != PaymentStatusSucceeded {
+				return result, nil
+			}
+			org.OrgBillingStatus = status
+		}
+		org.OutstandingInvoiceId = ""
+	}
+
+	if org.OrgBillingStatus == OrgBillingStatusSuspended {
+		result.Skipped = result.Invoice == nil
+		return result, nil
+	}
+
+	periodStart, err := time.Parse(time.RFC3339, org.NextBillingDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid next billing date %q for organization %s: %w", org.NextBillingDate, org.OrganizationId, err)
+	}
+	if periodStart.After(now) {
+		result.Skipped = result.Invoice == nil
+		return result, nil
+	}
+
+	// Renewals are priced at the plan version the organization is on, so organizations on a
+	// retired version keep their grandfathered price
+	plan, err := svc.GetOrgSubscriptionPlan(org)
+	if err != nil {
+		return nil, err
+	}
+
+	periodEnd := billingPeriodEnd(periodStart, org.BillingPlan)
+	lineItems := []InvoiceLineItem{
+		{
+			Type:        InvoiceLineItemPlan,
+			Description: fmt.Sprintf("%s (%s)", plan.PlanName, strings.ToLower(string(org.BillingPlan))),
+			PlanID:      plan.PlanID,
+			PeriodStart: periodStart.Format(time.RFC3339),
+			PeriodEnd:   periodEnd.Format(time.RFC3339),
+			Amount:      planPrice(plan, orgBillingCurrency(org), org.BillingPlan),
+		},
+	}
+	invoice := BuildInvoice(org, InvoiceTypeRenewal, plan.PlanID, org.BillingPlan, periodStart, periodEnd, lineItems, now)
+
+	if err := svc.issueInvoice(org, &invoice, periodEnd); err != nil {
+		return nil, err
+	}
+
+	payment := svc.chargeInvoice(org, invoice)
+	status, err := svc.recordPaymentOutcome(org, &invoice, payment, now)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Invoice = &invoice
+	result.PaymentStatus = payment.Status
+	result.BillingStatus = status
+	result.NextBillingDate = periodEnd.Format(time.RFC3339)
+	return result, nil
+}
+
+// issueInvoice stores a new invoice and moves the organization's NextBillingDate and credit balance
+// in one transaction. The org update is conditional on the NextBillingDate it was priced against, so
+// concurrent or repeated runs cannot bill the same period twice.
+func (svc *OrgServiceV2) issueInvoice(org Organization, invoice *Invoice, nextBillingDate time.Time) error {
+	invoiceItem, err := attributevalue.MarshalMap(invoice)
+	if err != nil {
+		return fmt.Errorf("failed to marshal invoice: %w", err)
+	}
+
+	_, err = svc.dynamodbClient.TransactWriteItems(svc.ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Put: &types.Put{
+					TableName:           aws.String(svc.OrganizationTable),
+					Item:                invoiceItem,
+					ConditionExpression: aws.String("attribute_not_exists(PK) AND attribute_not_exists(SK)"),
+				},
+			},
+			{
+				Update: &types.Update{
+					TableName: aws.String(svc.OrganizationTable),
+					Key: map[string]types.AttributeValue{
+						"PK": &types.AttributeValueMemberS{Value: orgPartitionKey(org.OrganizationId)},
+						"SK": &types.AttributeValueMemberS{Value: "METADATA"},
+					},
+					UpdateExpression:    aws.String("SET NextBillingDate = :nextBillingDate, CreditBalance = :creditBalance, UpdatedAt = :updatedAt"),
+					ConditionExpression: aws.String("attribute_exists(OrganizationId) AND (attribute_not_exists(NextBillingDate) OR NextBillingDate = :expectedBillingDate)"),
+					ExpressionAttributeValues: map[string]types.AttributeValue{
+						":nextBillingDate":     &types.AttributeValueMemberS{Value: nextBillingDate.Format(time.RFC3339)},
+						":creditBalance":       &types.AttributeValueMemberN{Value: fmt.Sprintf("%.2f", invoice.CreditIssued)},
+						":updatedAt":           &types.AttributeValueMemberS{Value: invoice.CreatedAt},
+						":expectedBillingDate": &types.AttributeValueMemberS{Value: org.NextBillingDate},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		var cancelled *types.TransactionCanceledException
+		if errors.As(err, &cancelled) {
+			return fmt.Errorf("invoice %s was already issued for organization %s: %w", invoice.InvoiceId, org.OrganizationId, err)
+		}
+		svc.logger.Printf("Failed to issue invoice %s: %v", invoice.InvoiceId, err)
+		return fmt.Errorf("failed to issue invoice: %w", err)
+	}
+
+	return nil
+}
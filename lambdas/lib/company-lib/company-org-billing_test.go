@@ -0,0 +1,393 @@
+package Companylib
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodb_types "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	awsclients "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/clients"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildInvoice(t *testing.T) {
+	now := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	periodEnd := now.AddDate(0, 1, 0)
+	planLine := []InvoiceLineItem{{Type: InvoiceLineItemPlan, Description: "Starter Plan (monthly)", Amount: 29.99}}
+
+	t.Run("It should add GST for Australian organizations", func(t *testing.T) {
+		org := Organization{OrganizationId: "org-1", Country: "Australia"}
+
+		invoice := BuildInvoice(org, InvoiceTypeRenewal, "starter", BillingPlanMonthly, now, periodEnd, planLine, now)
+
+		assert.Equal(t, "ORG#org-1", invoice.PK)
+		assert.Equal(t, "INVOICE#INV-20250301-RENEWAL", invoice.SK)
+		assert.Equal(t, "GST", invoice.TaxLabel)
+		assert.InDelta(t, 3.0, invoice.TaxAmount, 0.001)
+		assert.InDelta(t, 32.99, invoice.Total, 0.001)
+		assert.Equal(t, InvoiceStatusOpen, invoice.Status)
+		assert.Equal(t, now.AddDate(0, 0, InvoicePaymentTermsDays).Format(time.RFC3339), invoice.DueDate)
+	})
+
+	t.Run("It should reverse charge foreign organizations with a tax ID", func(t *testing.T) {
+		org := Organization{OrganizationId: "org-1", Country: "GB", TaxID: "GB123456789"}
+
+		invoice := BuildInvoice(org, InvoiceTypeRenewal, "starter", BillingPlanMonthly, now, periodEnd, planLine, now)
+
+		assert.True(t, invoice.ReverseCharge)
+		assert.Equal(t, 0.0, invoice.TaxAmount)
+		assert.InDelta(t, 29.99, invoice.Total, 0.001)
+	})
+
+	t.Run("It should apply an active promo discount before tax", func(t *testing.T) {
+		org := Organization{
+			OrganizationId:       "org-1",
+			Country:              "AU",
+			AppliedPromoCode:     "SAVE10",
+			PromoDiscountPercent: 10,
+			PromoDiscountAmount:  1,
+			PromoValidUntil:      now.AddDate(0, 1, 0).Format(time.RFC3339),
+		}
+
+		invoice := BuildInvoice(org, InvoiceTypeRenewal, "starter", BillingPlanMonthly, now, periodEnd, planLine, now)
+
+		assert.Equal(t, "SAVE10", invoice.PromoCode)
+		assert.InDelta(t, 4.0, invoice.DiscountAmount, 0.001)
+		assert.InDelta(t, 2.6, invoice.TaxAmount, 0.001)
+		assert.InDelta(t, 28.59, invoice.Total, 0.001)
+	})
+
+	t.Run("It should ignore an expired promo", func(t *testing.T) {
+		org := Organization{
+			OrganizationId:       "org-1",
+			Country:              "US",
+			AppliedPromoCode:     "SAVE10",
+			PromoDiscountPercent: 10,
+			PromoValidUntil:      now.AddDate(0, 0, -1).Format(time.RFC3339),
+		}
+
+		invoice := BuildInvoice(org, InvoiceTypeRenewal, "starter", BillingPlanMonthly, now, periodEnd, planLine, now)
+
+		assert.Equal(t, 0.0, invoice.DiscountAmount)
+		assert.Empty(t, invoice.PromoCode)
+		assert.InDelta(t, 29.99, invoice.Total, 0.001)
+	})
+
+	t.Run("It should carry unused credit forward instead of a negative total", func(t *testing.T) {
+		org := Organization{OrganizationId: "org-1", Country: "AU"}
+		lines := []InvoiceLineItem{
+			{Type: InvoiceLineItemCredit, Description: "Unused time", Amount: -40},
+			{Type: InvoiceLineItemProration, Description: "Remaining time", Amount: 15},
+		}
+
+		invoice := BuildInvoice(org, InvoiceTypePlanChange, "starter", BillingPlanMonthly, now, periodEnd, lines, now)
+
+		assert.Equal(t, 0.0, invoice.Total)
+		assert.InDelta(t, 25.0, invoice.CreditIssued, 0.001)
+	})
+}
+
+func TestBuildPlanChangeLineItems(t *testing.T) {
+	starter := &SubscriptionPlan{PlanID: "starter", PlanName: "Starter Plan", MonthlyPrice: 30, YearlyPrice: 300}
+	professional := &SubscriptionPlan{PlanID: "professional", PlanName: "Professional Plan", MonthlyPrice: 90, YearlyPrice: 900}
+
+	periodEnd := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	org := Organization{
+		OrganizationId:  "org-1",
+		BillingMode:     BillingModePaid,
+		BillingPlan:     BillingPlanMonthly,
+		CurrentPlanID:   "starter",
+		NextBillingDate: periodEnd.Format(time.RFC3339),
+	}
+
+	t.Run("It should prorate an upgrade on the same billing frequency", func(t *testing.T) {
+		// Half way through a 31 day period
+		now := periodEnd.Add(-31 * 12 * time.Hour)
+
+		lines, start, end := BuildPlanChangeLineItems(org, starter, professional, BillingPlanMonthly, now)
+
+		assert.Len(t, lines, 2)
+		assert.Equal(t, InvoiceLineItemCredit, lines[0].Type)
+		assert.InDelta(t, -15.0, lines[0].Amount, 0.001)
+		assert.Equal(t, InvoiceLineItemProration, lines[1].Type)
+		assert.InDelta(t, 45.0, lines[1].Amount, 0.001)
+		assert.Equal(t, now, start)
+		assert.Equal(t, periodEnd, end)
+	})
+
+	t.Run("It should start a new period when the billing frequency changes", func(t *testing.T) {
+		now := periodEnd.Add(-31 * 12 * time.Hour)
+
+		lines, start, end := BuildPlanChangeLineItems(org, starter, professional, BillingPlanYearly, now)
+
+		assert.Len(t, lines, 2)
+		assert.InDelta(t, -15.0, lines[0].Amount, 0.001)
+		assert.Equal(t, InvoiceLineItemPlan, lines[1].Type)
+		assert.Equal(t, 900.0, lines[1].Amount)
+		assert.Equal(t, now, start)
+		assert.Equal(t, now.AddDate(1, 0, 0), end)
+	})
+
+	t.Run("It should charge the full plan for an organization coming off trial", func(t *testing.T) {
+		trialOrg := Organization{OrganizationId: "org-1", BillingMode: BillingModeFree}
+		now := periodEnd
+
+		lines, _, end := BuildPlanChangeLineItems(trialOrg, nil, starter, BillingPlanMonthly, now)
+
+		assert.Len(t, lines, 1)
+		assert.Equal(t, 30.0, lines[0].Amount)
+		assert.Equal(t, now.AddDate(0, 1, 0), end)
+	})
+}
+
+func TestProcessRenewal(t *testing.T) {
+	now := time.Date(2025, 3, 2, 1, 0, 0, 0, time.UTC)
+	billingDate := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	newService := func(ddbClient *awsclients.MockDynamodbClient, provider PaymentProvider) *OrgServiceV2 {
+		return &OrgServiceV2{
+			ctx:               context.Background(),
+			dynamodbClient:    ddbClient,
+			logger:            log.New(&bytes.Buffer{}, "TEST:", 0),
+			OrganizationTable: "OrgTable-test",
+			PaymentProvider:   provider,
+		}
+	}
+
+	t.Run("It should invoice, advance the billing date and charge a due organization", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			TransactWriteItemsOutput: []dynamodb.TransactWriteItemsOutput{{}, {}},
+			TransactWriteItemsErrors: []error{nil, nil},
+		}
+		provider := &LocalPaymentProvider{}
+		svc := newService(&ddbClient, provider)
+
+		org := Organization{
+			OrganizationId:   "org-1",
+			Country:          "AU",
+			BillingMode:      BillingModePaid,
+			BillingPlan:      BillingPlanMonthly,
+			CurrentPlanID:    "starter",
+			OrgBillingStatus: OrgBillingStatusActive,
+			NextBillingDate:  billingDate.Format(time.RFC3339),
+		}
+
+		result, err := svc.ProcessRenewal(org, now)
+
+		assert.NoError(t, err)
+		assert.Equal(t, PaymentStatusSucceeded, result.PaymentStatus)
+		assert.Equal(t, OrgBillingStatusActive, result.BillingStatus)
+		assert.Equal(t, "2025-04-01T00:00:00Z", result.NextBillingDate)
+		assert.Equal(t, "INV-20250301-RENEWAL", result.Invoice.InvoiceId)
+		assert.Equal(t, InvoiceStatusPaid, result.Invoice.Status)
+		assert.Len(t, provider.Charges, 1)
+		assert.Len(t, ddbClient.TransactWriteItemsInputs, 2)
+
+		issue := ddbClient.TransactWriteItemsInputs[0].TransactItems
+		assert.Equal(t, "attribute_not_exists(PK) AND attribute_not_exists(SK)", *issue[0].Put.ConditionExpression)
+		assert.Equal(t, &dynamodb_types.AttributeValueMemberS{Value: "2025-03-01T00:00:00Z"}, issue[1].Update.ExpressionAttributeValues[":expectedBillingDate"])
+		assert.Equal(t, &dynamodb_types.AttributeValueMemberS{Value: "2025-04-01T00:00:00Z"}, issue[1].Update.ExpressionAttributeValues[":nextBillingDate"])
+	})
+
+	t.Run("It should mark the organization overdue when the charge fails", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			TransactWriteItemsOutput: []dynamodb.TransactWriteItemsOutput{{}, {}},
+			TransactWriteItemsErrors: []error{nil, nil},
+		}
+		provider := &LocalPaymentProvider{FailingOrgs: map[string]bool{"org-1": true}}
+		svc := newService(&ddbClient, provider)
+
+		org := Organization{
+			OrganizationId:   "org-1",
+			BillingMode:      BillingModePaid,
+			BillingPlan:      BillingPlanMonthly,
+			CurrentPlanID:    "starter",
+			OrgBillingStatus: OrgBillingStatusActive,
+			NextBillingDate:  billingDate.Format(time.RFC3339),
+		}
+
+		result, err := svc.ProcessRenewal(org, now)
+
+		assert.NoError(t, err)
+		assert.Equal(t, PaymentStatusFailed, result.PaymentStatus)
+		assert.Equal(t, OrgBillingStatusOverdue, result.BillingStatus)
+
+		orgUpdate := ddbClient.TransactWriteItemsInputs[1].TransactItems[1].Update
+		assert.Equal(t, &dynamodb_types.AttributeValueMemberS{Value: "INV-20250301-RENEWAL"}, orgUpdate.ExpressionAttributeValues[":invoiceId"])
+	})
+
+	t.Run("It should suspend an organization whose outstanding invoice is past the grace period", func(t *testing.T) {
+		invoice := Invoice{
+			PK:             "ORG#org-1",
+			SK:             "INVOICE#INV-20250101-RENEWAL",
+			OrganizationId: "org-1",
+			InvoiceId:      "INV-20250101-RENEWAL",
+			Status:         InvoiceStatusOpen,
+			Total:          32.99,
+			DueDate:        "2025-01-08T00:00:00Z",
+		}
+		invoiceItem, _ := attributevalue.MarshalMap(invoice)
+
+		ddbClient := awsclients.MockDynamodbClient{
+			GetItemOutputs:           []dynamodb.GetItemOutput{{Item: invoiceItem}},
+			GetItemErrors:            []error{nil},
+			TransactWriteItemsOutput: []dynamodb.TransactWriteItemsOutput{{}},
+			TransactWriteItemsErrors: []error{nil},
+		}
+		provider := &LocalPaymentProvider{FailingOrgs: map[string]bool{"org-1": true}}
+		svc := newService(&ddbClient, provider)
+
+		org := Organization{
+			OrganizationId:       "org-1",
+			BillingMode:          BillingModePaid,
+			BillingPlan:          BillingPlanMonthly,
+			CurrentPlanID:        "starter",
+			OrgBillingStatus:     OrgBillingStatusOverdue,
+			NextBillingDate:      "2025-02-01T00:00:00Z",
+			OutstandingInvoiceId: "INV-20250101-RENEWAL",
+		}
+
+		result, err := svc.ProcessRenewal(org, now)
+
+		assert.NoError(t, err)
+		assert.Equal(t, OrgBillingStatusSuspended, result.BillingStatus)
+		assert.Equal(t, "2025-02-01T00:00:00Z", result.NextBillingDate)
+		assert.Len(t, ddbClient.TransactWriteItemsInputs, 1)
+	})
+
+	t.Run("It should skip organizations that are not due yet", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{}
+		svc := newService(&ddbClient, &LocalPaymentProvider{})
+
+		org := Organization{
+			OrganizationId:   "org-1",
+			BillingMode:      BillingModePaid,
+			BillingPlan:      BillingPlanMonthly,
+			CurrentPlanID:    "starter",
+			OrgBillingStatus: OrgBillingStatusActive,
+			NextBillingDate:  now.AddDate(0, 0, 5).Format(time.RFC3339),
+		}
+
+		result, err := svc.ProcessRenewal(org, now)
+
+		assert.NoError(t, err)
+		assert.True(t, result.Skipped)
+		assert.Len(t, ddbClient.TransactWriteItemsInputs, 0)
+	})
+}
+
+func TestUpdateSubscription(t *testing.T) {
+	newService := func(ddbClient *awsclients.MockDynamodbClient) *OrgServiceV2 {
+		return &OrgServiceV2{
+			ctx:               context.Background(),
+			dynamodbClient:    ddbClient,
+			logger:            log.New(&bytes.Buffer{}, "TEST:", 0),
+			OrganizationTable: "OrgTable-test",
+			PaymentProvider:   &LocalPaymentProvider{},
+		}
+	}
+
+	adminItem, _ := attributevalue.MarshalMap(OrgAdmin{OrganizationId: "org-1", UserName: "ann", IsActive: true})
+	orgItem, _ := attributevalue.MarshalMap(Organization{
+		OrganizationId:     "org-1",
+		Country:            "AU",
+		BillingMode:        BillingModePaid,
+		BillingPlan:        BillingPlanMonthly,
+		CurrentPlanID:      "starter",
+		CurrentPlanVersion: 1,
+		NextBillingDate:    time.Now().UTC().AddDate(0, 0, 10).Format(time.RFC3339),
+	})
+	input := UpdateSubscriptionInput{OrganizationId: "org-1", PlanID: "professional", BillingPlan: BillingPlanMonthly}
+
+	t.Run("It should only apply the change to the plan it was priced against", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			GetItemOutputs:           []dynamodb.GetItemOutput{{Item: adminItem}, {Item: orgItem}},
+			GetItemErrors:            []error{nil, nil},
+			TransactWriteItemsOutput: []dynamodb.TransactWriteItemsOutput{{}, {}},
+			TransactWriteItemsErrors: []error{nil, nil},
+		}
+		svc := newService(&ddbClient)
+
+		invoice, err := svc.UpdateSubscription(input, "ann")
+
+		assert.NoError(t, err)
+		assert.Equal(t, InvoiceStatusPaid, invoice.Status)
+		orgUpdate := ddbClient.TransactWriteItemsInputs[0].TransactItems[1].Update
+		assert.Contains(t, *orgUpdate.ConditionExpression, "CurrentPlanID = :expectedPlanId")
+		assert.Equal(t, &dynamodb_types.AttributeValueMemberS{Value: "starter"}, orgUpdate.ExpressionAttributeValues[":expectedPlanId"])
+		assert.Equal(t, &dynamodb_types.AttributeValueMemberS{Value: "MONTHLY"}, orgUpdate.ExpressionAttributeValues[":expectedBillingPlan"])
+		assert.Equal(t, &dynamodb_types.AttributeValueMemberN{Value: "1"}, orgUpdate.ExpressionAttributeValues[":expectedPlanVersion"])
+		assert.Contains(t, *orgUpdate.ConditionExpression, "attribute_not_exists(OutstandingInvoiceId)")
+	})
+
+	t.Run("It should refuse a change while an earlier invoice is outstanding", func(t *testing.T) {
+		overdueItem, _ := attributevalue.MarshalMap(Organization{
+			OrganizationId:       "org-1",
+			Country:              "AU",
+			BillingMode:          BillingModePaid,
+			BillingPlan:          BillingPlanMonthly,
+			CurrentPlanID:        "starter",
+			CurrentPlanVersion:   1,
+			OrgBillingStatus:     OrgBillingStatusOverdue,
+			OutstandingInvoiceId: "INV-20250101-RENEWAL",
+		})
+		ddbClient := awsclients.MockDynamodbClient{
+			GetItemOutputs: []dynamodb.GetItemOutput{{Item: adminItem}, {Item: overdueItem}},
+			GetItemErrors:  []error{nil, nil},
+		}
+		svc := newService(&ddbClient)
+
+		invoice, err := svc.UpdateSubscription(input, "ann")
+
+		assert.ErrorIs(t, err, ErrInvoiceOutstanding)
+		assert.Nil(t, invoice)
+		assert.Empty(t, ddbClient.TransactWriteItemsInputs)
+	})
+
+	t.Run("It should reject a change when another request changed the plan first", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			GetItemOutputs:           []dynamodb.GetItemOutput{{Item: adminItem}, {Item: orgItem}},
+			GetItemErrors:            []error{nil, nil},
+			TransactWriteItemsOutput: []dynamodb.TransactWriteItemsOutput{{}},
+			TransactWriteItemsErrors: []error{&dynamodb_types.TransactionCanceledException{
+				CancellationReasons: []dynamodb_types.CancellationReason{
+					{Code: aws.String("None")},
+					{Code: aws.String("ConditionalCheckFailed")},
+				},
+			}},
+		}
+		svc := newService(&ddbClient)
+
+		invoice, err := svc.UpdateSubscription(input, "ann")
+
+		assert.ErrorIs(t, err, ErrSubscriptionChanged)
+		assert.Nil(t, invoice)
+	})
+
+	t.Run("It should return the invoice when the payment outcome cannot be recorded", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			GetItemOutputs:           []dynamodb.GetItemOutput{{Item: adminItem}, {Item: orgItem}},
+			GetItemErrors:            []error{nil, nil},
+			TransactWriteItemsOutput: []dynamodb.TransactWriteItemsOutput{{}, {}},
+			TransactWriteItemsErrors: []error{nil, errors.New("throttled")},
+		}
+		svc := newService(&ddbClient)
+
+		invoice, err := svc.UpdateSubscription(input, "ann")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "professional", invoice.PlanID)
+		assert.Len(t, ddbClient.TransactWriteItemsInputs, 2)
+
+		// The plan change itself left the invoice outstanding for dunning to collect
+		orgUpdate := ddbClient.TransactWriteItemsInputs[0].TransactItems[1].Update
+		assert.Contains(t, *orgUpdate.UpdateExpression, "OutstandingInvoiceId = :invoiceId")
+		assert.Equal(t, &dynamodb_types.AttributeValueMemberS{Value: invoice.InvoiceId}, orgUpdate.ExpressionAttributeValues[":invoiceId"])
+	})
+}
@@ -0,0 +1,403 @@
+package Companylib
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+)
+
+// Org user statuses
+const (
+	OrgUserStatusInvited = "INVITED"
+	OrgUserStatusActive  = "ACTIVE"
+)
+
+// InvitationStatus is the outcome recorded against an invitation token once it can no longer be used
+type InvitationStatus string
+
+const (
+	InvitationStatusAccepted InvitationStatus = "ACCEPTED"
+	InvitationStatusRevoked  InvitationStatus = "REVOKED"
+)
+
+var (
+	ErrInvitationNotFound     = errors.New("invitation not found")
+	ErrInvitationAlreadyUsed  = errors.New("invitation has already been accepted")
+	ErrInvitationRevoked      = errors.New("invitation has been revoked")
+	ErrInvitationSuperseded   = errors.New("invitation has been replaced by a newer invitation")
+	ErrInvitationNotRevocable = errors.New("only pending invitations can be revoked or resent")
+)
+
+// InvitationRecord is written once an invitation token is accepted or revoked so the token can
+// never be used again, even if the person is later invited afresh.
+type InvitationRecord struct {
+	PK string `dynamodbav:"PK" json:"-"` // ORG#{organizationId}
+	SK string `dynamodbav:"SK" json:"-"` // INVITATION#{invitationId}
+
+	OrganizationId string           `dynamodbav:"OrganizationId" json:"organizationId"`
+	InvitationId   string           `dynamodbav:"InvitationId" json:"invitationId"`
+	UserName       string           `dynamodbav:"UserName" json:"userName"`
+	TeamId         string           `dynamodbav:"TeamId,omitempty" json:"teamId,omitempty"`
+	Status         InvitationStatus `dynamodbav:"Status" json:"status"`
+	ActionedBy     string           `dynamodbav:"ActionedBy" json:"actionedBy"`
+	ActionedAt     string           `dynamodbav:"ActionedAt" json:"actionedAt"`
+}
+
+// NewInvitationId returns a new invitation ID, used as the jti of the invitation token
+func NewInvitationId() string {
+	return uuid.New().String()
+}
+
+func invitationSortKey(invitationId string) string {
+	return fmt.Sprintf("INVITATION#%s", invitationId)
+}
+
+// GetPendingInvitations returns the organization's invitations that have not been accepted yet
+func (svc *OrgServiceV2) GetPendingInvitations(organizationId string) ([]OrgUser, error) {
+	result, err := svc.dynamodbClient.Query(svc.ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(svc.OrganizationTable),
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk_prefix)"),
+		FilterExpression:       aws.String("#status = :invited"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "Status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":        &types.AttributeValueMemberS{Value: orgPartitionKey(organizationId)},
+			":sk_prefix": &types.AttributeValueMemberS{Value: "USER#"},
+			":invited":   &types.AttributeValueMemberS{Value: OrgUserStatusInvited},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pending invitations: organizationId %s: %w", organizationId, err)
+	}
+
+	invitations := make([]OrgUser, 0, len(result.Items))
+	if err := attributevalue.UnmarshalListOfMaps(result.Items, &invitations); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal pending invitations: %w", err)
+	}
+
+	return invitations, nil
+}
+
+// GetInvitationForAcceptance returns the pending invitation a verified token refers to. The token
+// must be the latest one issued for the person and must not have been accepted or revoked.
+func (svc *OrgServiceV2) GetInvitationForAcceptance(organizationId, userName, invitationId string) (*OrgUser, error) {
+	// Tokens issued before invitations carried an ID cannot be tied to a single use
+	if invitationId == "" {
+		return nil, ErrInvitationSuperseded
+	}
+	orgKey := orgPartitionKey(organizationId)
+
+	record, err := svc.getInvitationRecord(orgKey, invitationId)
+	if err != nil {
+		return nil, err
+	}
+	if record != nil {
+		if record.Status == InvitationStatusRevoked {
+			return nil, ErrInvitationRevoked
+		}
+		return nil, ErrInvitationAlreadyUsed
+	}
+
+	invitation, err := svc.getOrgUser(orgKey, userName)
+	if err != nil {
+		return nil, err
+	}
+	if invitation == nil {
+		return nil, fmt.Errorf("%w: %s in organization %s", ErrInvitationNotFound, userName, organizationId)
+	}
+	if invitation.Status != OrgUserStatusInvited {
+		return nil, ErrInvitationAlreadyUsed
+	}
+	if invitation.InvitationId != invitationId {
+		return nil, ErrInvitationSuperseded
+	}
+
+	return invitation, nil
+}
+
+// AcceptInvitation activates a pending invitation and marks its token as used in one transaction.
+// teamItems (from TeamsServiceV2.InvitationMembershipItems) are written in the same transaction so
+// the person never ends up in the organization without the team they were invited to.
+func (svc *OrgServiceV2) AcceptInvitation(invitation *OrgUser, displayName string, teamItems []types.TransactWriteItem) error {
+	orgKey := orgPartitionKey(invitation.OrganizationId)
+	now := time.Now().UTC().Format(time.RFC3339)
+	if displayName == "" {
+		displayName = invitation.UserName
+	}
+
+	recordItem, err := attributevalue.MarshalMap(InvitationRecord{
+		PK:             orgKey,
+		SK:             invitationSortKey(invitation.InvitationId),
+		OrganizationId: invitation.OrganizationId,
+		InvitationId:   invitation.InvitationId,
+		UserName:       invitation.UserName,
+		TeamId:         invitation.InvitationTeamId,
+		Status:         InvitationStatusAccepted,
+		ActionedBy:     invitation.UserName,
+		ActionedAt:     now,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal invitation record: %w", err)
+	}
+
+	// The invitee already holds the seat claimed when they were invited; invitations raised before
+	// seats were tracked claim one now
+	seats, err := svc.ReserveSeats(invitation.OrganizationId, []string{invitation.UserName})
+	if err != nil {
+		return err
+	}
+
+	transactItems := []types.TransactWriteItem{
+		{
+			Update: &types.Update{
+				TableName: aws.String(svc.OrganizationTable),
+				Key: map[string]types.AttributeValue{
+					"PK": &types.AttributeValueMemberS{Value: orgKey},
+					"SK": &types.AttributeValueMemberS{Value: fmt.Sprintf("USER#%s", invitation.UserName)},
+				},
+				UpdateExpression:    aws.String("SET #status = :active, IsActive = :true, DisplayName = :displayName, JoinedAt = :now, AcceptedAt = :now, UpdatedAt = :now REMOVE InvitationId"),
+				ConditionExpression: aws.String("#status = :invited AND InvitationId = :invitationId"),
+				ExpressionAttributeNames: map[string]string{
+					"#status": "Status",
+				},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":active":       &types.AttributeValueMemberS{Value: OrgUserStatusActive},
+					":invited":      &types.AttributeValueMemberS{Value: OrgUserStatusInvited},
+					":true":         &types.AttributeValueMemberBOOL{Value: true},
+					":displayName":  &types.AttributeValueMemberS{Value: displayName},
+					":now":          &types.AttributeValueMemberS{Value: now},
+					":invitationId": &types.AttributeValueMemberS{Value: invitation.InvitationId},
+				},
+			},
+		},
+		{
+			Put: &types.Put{
+				TableName:           aws.String(svc.OrganizationTable),
+				Item:                recordItem,
+				ConditionExpression: aws.String("attribute_not_exists(SK)"),
+			},
+		},
+	}
+	seatsOffset := len(transactItems)
+	transactItems = append(transactItems, seats.Items...)
+	transactItems = append(transactItems, teamItems...)
+
+	_, err = svc.dynamodbClient.TransactWriteItems(svc.ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: transactItems,
+	})
+	if err != nil {
+		svc.logger.Printf("Failed to accept invitation %s: %v", invitation.InvitationId, err)
+		if err := seats.TransactionError(err, seatsOffset); errors.Is(err, ErrMemberLimitReached) {
+			return err
+		}
+		// A concurrent accept of the same token fails the invitation conditions
+		var cancelled *types.TransactionCanceledException
+		if errors.As(err, &cancelled) && len(cancelled.CancellationReasons) > 1 {
+			for _, reason := range cancelled.CancellationReasons[:2] {
+				if aws.ToString(reason.Code) == "ConditionalCheckFailed" {
+					return ErrInvitationAlreadyUsed
+				}
+			}
+		}
+		return fmt.Errorf("failed to accept invitation: %w", err)
+	}
+
+	svc.logger.Printf("User %s accepted invitation %s to organization %s", invitation.UserName, invitation.InvitationId, invitation.OrganizationId)
+	return nil
+}
+
+// RevokeInvitation cancels a pending invitation, frees the seat it was holding and blocks its
+// token (org admins only)
+func (svc *OrgServiceV2) RevokeInvitation(organizationId, userName, requestingUser string) error {
+	invitation, err := svc.getPendingInvitationForAdmin(organizationId, userName, requestingUser)
+	if err != nil {
+		return err
+	}
+	orgKey := orgPartitionKey(organizationId)
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	transactItems := []types.TransactWriteItem{
+		{
+			Delete: &types.Delete{
+				TableName: aws.String(svc.OrganizationTable),
+				Key: map[string]types.AttributeValue{
+					"PK": &types.AttributeValueMemberS{Value: orgKey},
+					"SK": &types.AttributeValueMemberS{Value: fmt.Sprintf("USER#%s", userName)},
+				},
+				ConditionExpression: aws.String("#status = :invited"),
+				ExpressionAttributeNames: map[string]string{
+					"#status": "Status",
+				},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":invited": &types.AttributeValueMemberS{Value: OrgUserStatusInvited},
+				},
+			},
+		},
+	}
+
+	if invitation.InvitationId != "" {
+		recordItem, err := attributevalue.MarshalMap(InvitationRecord{
+			PK:             orgKey,
+			SK:             invitationSortKey(invitation.InvitationId),
+			OrganizationId: invitation.OrganizationId,
+			InvitationId:   invitation.InvitationId,
+			UserName:       userName,
+			TeamId:         invitation.InvitationTeamId,
+			Status:         InvitationStatusRevoked,
+			ActionedBy:     requestingUser,
+			ActionedAt:     now,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to marshal invitation record: %w", err)
+		}
+		transactItems = append(transactItems, types.TransactWriteItem{
+			Put: &types.Put{
+				TableName:           aws.String(svc.OrganizationTable),
+				Item:                recordItem,
+				ConditionExpression: aws.String("attribute_not_exists(SK)"),
+			},
+		})
+	}
+
+	// The invitation's seat goes back to the plan unless the person is also an admin
+	isAdmin, err := svc.IsOrgAdmin(orgKey, userName)
+	if err != nil {
+		return err
+	}
+	if !isAdmin {
+		releaseItems, err := svc.seatReleaseItems(orgKey, userName)
+		if err != nil {
+			return err
+		}
+		transactItems = append(transactItems, releaseItems...)
+	}
+
+	_, err = svc.dynamodbClient.TransactWriteItems(svc.ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: transactItems,
+	})
+	if err != nil {
+		svc.logger.Printf("Failed to revoke invitation for %s: %v", userName, err)
+		return fmt.Errorf("failed to revoke invitation: %w", err)
+	}
+
+	svc.logger.Printf("Invitation for %s to organization %s revoked by %s", userName, organizationId, requestingUser)
+	return nil
+}
+
+// ReissueInvitation gives a pending invitation a new ID so it can be emailed again. Tokens sent
+// before the reissue stop working. Returns the updated invitation (org admins only).
+func (svc *OrgServiceV2) ReissueInvitation(organizationId, userName, requestingUser string) (*OrgUser, error) {
+	invitation, err := svc.getPendingInvitationForAdmin(organizationId, userName, requestingUser)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	newInvitationId := NewInvitationId()
+
+	condition := "#status = :invited AND InvitationId = :previousId"
+	values := map[string]types.AttributeValue{
+		":invited":      &types.AttributeValueMemberS{Value: OrgUserStatusInvited},
+		":invitationId": &types.AttributeValueMemberS{Value: newInvitationId},
+		":invitedBy":    &types.AttributeValueMemberS{Value: requestingUser},
+		":now":          &types.AttributeValueMemberS{Value: now},
+	}
+	if invitation.InvitationId == "" {
+		condition = "#status = :invited AND attribute_not_exists(InvitationId)"
+	} else {
+		values[":previousId"] = &types.AttributeValueMemberS{Value: invitation.InvitationId}
+	}
+
+	_, err = svc.dynamodbClient.UpdateItem(svc.ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(svc.OrganizationTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: orgPartitionKey(organizationId)},
+			"SK": &types.AttributeValueMemberS{Value: fmt.Sprintf("USER#%s", userName)},
+		},
+		UpdateExpression:    aws.String("SET InvitationId = :invitationId, InvitedBy = :invitedBy, InvitedAt = :now, UpdatedAt = :now"),
+		ConditionExpression: aws.String(condition),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "Status",
+		},
+		ExpressionAttributeValues: values,
+	})
+	if err != nil {
+		svc.logger.Printf("Failed to reissue invitation for %s: %v", userName, err)
+		return nil, fmt.Errorf("failed to reissue invitation: %w", err)
+	}
+
+	invitation.InvitationId = newInvitationId
+	invitation.InvitedBy = requestingUser
+	invitation.InvitedAt = now
+	invitation.UpdatedAt = now
+	return invitation, nil
+}
+
+func (svc *OrgServiceV2) getPendingInvitationForAdmin(organizationId, userName, requestingUser string) (*OrgUser, error) {
+	isAdmin, err := svc.IsOrgAdmin(organizationId, requestingUser)
+	if err != nil {
+		return nil, err
+	}
+	if !isAdmin {
+		return nil, fmt.Errorf("user %s is not an admin of organization %s", requestingUser, organizationId)
+	}
+
+	invitation, err := svc.getOrgUser(orgPartitionKey(organizationId), userName)
+	if err != nil {
+		return nil, err
+	}
+	if invitation == nil {
+		return nil, fmt.Errorf("%w: %s in organization %s", ErrInvitationNotFound, userName, organizationId)
+	}
+	if invitation.Status != OrgUserStatusInvited {
+		return nil, ErrInvitationNotRevocable
+	}
+	if invitation.OrganizationId == "" {
+		invitation.OrganizationId = orgPartitionKey(organizationId)
+	}
+
+	return invitation, nil
+}
+
+func (svc *OrgServiceV2) getInvitationRecord(orgKey, invitationId string) (*InvitationRecord, error) {
+	result, err := svc.dynamodbClient.GetItem(svc.ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(svc.OrganizationTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: orgKey},
+			"SK": &types.AttributeValueMemberS{Value: invitationSortKey(invitationId)},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invitation record: invitationId %s: %w", invitationId, err)
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var record InvitationRecord
+	if err := attributevalue.UnmarshalMap(result.Item, &record); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal invitation record: invitationId %s: %w", invitationId, err)
+	}
+
+	return &record, nil
+}
+
+// TeamRoleFromInvitation maps the role carried by an invitation onto a team role. Invitations
+// cannot make someone a team owner.
+func TeamRoleFromInvitation(role string) TeamMemberRole {
+	switch strings.ToUpper(strings.TrimSpace(role)) {
+	case string(TeamMemberRoleAdmin):
+		return TeamMemberRoleAdmin
+	case string(TeamMemberRoleGuest), "VIEWER":
+		return TeamMemberRoleGuest
+	default:
+		return TeamMemberRoleMember
+	}
+}
@@ -0,0 +1,150 @@
+package Companylib
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodb_types "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	awsclients "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/clients"
+	"github.com/stretchr/testify/assert"
+)
+
+func invitedUserItem(status, invitationId string) dynamodb.GetItemOutput {
+	item, _ := attributevalue.MarshalMap(OrgUser{
+		PK:             "ORG#org-1",
+		SK:             "USER#new@example.com",
+		OrganizationId: "ORG#org-1",
+		UserName:       "new@example.com",
+		Status:         status,
+		IsActive:       true,
+		InvitationId:   invitationId,
+	})
+	return dynamodb.GetItemOutput{Item: item}
+}
+
+func invitationRecordItem(status InvitationStatus) dynamodb.GetItemOutput {
+	item, _ := attributevalue.MarshalMap(InvitationRecord{InvitationId: "inv-1", Status: status})
+	return dynamodb.GetItemOutput{Item: item}
+}
+
+func TestGetInvitationForAcceptance(t *testing.T) {
+	tests := []struct {
+		name          string
+		invitationId  string
+		outputs       []dynamodb.GetItemOutput
+		expectedError error
+	}{
+		{
+			name:         "It should return a pending invitation for its latest token",
+			invitationId: "inv-1",
+			outputs:      []dynamodb.GetItemOutput{{}, invitedUserItem(OrgUserStatusInvited, "inv-1")},
+		},
+		{
+			name:          "It should reject a token that has already been used",
+			invitationId:  "inv-1",
+			outputs:       []dynamodb.GetItemOutput{invitationRecordItem(InvitationStatusAccepted)},
+			expectedError: ErrInvitationAlreadyUsed,
+		},
+		{
+			name:          "It should reject a revoked token",
+			invitationId:  "inv-1",
+			outputs:       []dynamodb.GetItemOutput{invitationRecordItem(InvitationStatusRevoked)},
+			expectedError: ErrInvitationRevoked,
+		},
+		{
+			name:          "It should reject a token replaced by a resend",
+			invitationId:  "inv-1",
+			outputs:       []dynamodb.GetItemOutput{{}, invitedUserItem(OrgUserStatusInvited, "inv-2")},
+			expectedError: ErrInvitationSuperseded,
+		},
+		{
+			name:          "It should reject a token for a membership that is already active",
+			invitationId:  "inv-1",
+			outputs:       []dynamodb.GetItemOutput{{}, invitedUserItem(OrgUserStatusActive, "")},
+			expectedError: ErrInvitationAlreadyUsed,
+		},
+		{
+			name:          "It should reject a token for an invitation that no longer exists",
+			invitationId:  "inv-1",
+			outputs:       []dynamodb.GetItemOutput{{}, {}},
+			expectedError: ErrInvitationNotFound,
+		},
+		{
+			name:          "It should reject a token without an invitation ID",
+			expectedError: ErrInvitationSuperseded,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ddbClient := awsclients.MockDynamodbClient{
+				GetItemOutputs: test.outputs,
+				GetItemErrors:  make([]error, len(test.outputs)),
+			}
+			svc := newSeatsOrgService(&ddbClient)
+
+			invitation, err := svc.GetInvitationForAcceptance("ORG#org-1", "new@example.com", test.invitationId)
+
+			if test.expectedError != nil {
+				assert.ErrorIs(t, err, test.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "new@example.com", invitation.UserName)
+		})
+	}
+}
+
+func TestAcceptInvitation(t *testing.T) {
+	invitation := &OrgUser{OrganizationId: "ORG#org-1", UserName: "new@example.com", Status: OrgUserStatusInvited, InvitationId: "inv-1", InvitationTeamId: "TEAM#1"}
+	teamItems := []dynamodb_types.TransactWriteItem{{Put: &dynamodb_types.Put{TableName: aws.String("TeamsTable-test")}}}
+
+	t.Run("It should activate the member, consume the token and join the team in one transaction", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			GetItemOutputs:           []dynamodb.GetItemOutput{seatsOrgItem(3, 25), seatItem("new@example.com")},
+			GetItemErrors:            []error{nil, nil},
+			TransactWriteItemsOutput: []dynamodb.TransactWriteItemsOutput{{}},
+			TransactWriteItemsErrors: []error{nil},
+		}
+		svc := newSeatsOrgService(&ddbClient)
+
+		err := svc.AcceptInvitation(invitation, "New Person", teamItems)
+
+		assert.NoError(t, err)
+		items := ddbClient.TransactWriteItemsInputs[0].TransactItems
+		assert.Len(t, items, 4)
+		assert.Equal(t, "#status = :invited AND InvitationId = :invitationId", *items[0].Update.ConditionExpression)
+		assert.Equal(t, &dynamodb_types.AttributeValueMemberS{Value: "INVITATION#inv-1"}, items[1].Put.Item["SK"])
+		assert.NotNil(t, items[2].ConditionCheck)
+		assert.Equal(t, "TeamsTable-test", *items[3].Put.TableName)
+	})
+
+	t.Run("It should report a concurrent accept of the same token as already used", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			GetItemOutputs:           []dynamodb.GetItemOutput{seatsOrgItem(3, 25), seatItem("new@example.com")},
+			GetItemErrors:            []error{nil, nil},
+			TransactWriteItemsOutput: []dynamodb.TransactWriteItemsOutput{{}},
+			TransactWriteItemsErrors: []error{&dynamodb_types.TransactionCanceledException{
+				CancellationReasons: []dynamodb_types.CancellationReason{
+					{Code: aws.String("ConditionalCheckFailed")},
+					{Code: aws.String("None")},
+					{Code: aws.String("None")},
+				},
+			}},
+		}
+		svc := newSeatsOrgService(&ddbClient)
+
+		err := svc.AcceptInvitation(invitation, "New Person", nil)
+
+		assert.ErrorIs(t, err, ErrInvitationAlreadyUsed)
+	})
+}
+
+func TestTeamRoleFromInvitation(t *testing.T) {
+	assert.Equal(t, TeamMemberRoleAdmin, TeamRoleFromInvitation("admin"))
+	assert.Equal(t, TeamMemberRoleGuest, TeamRoleFromInvitation("VIEWER"))
+	assert.Equal(t, TeamMemberRoleMember, TeamRoleFromInvitation("MEMBER"))
+	assert.Equal(t, TeamMemberRoleMember, TeamRoleFromInvitation("OWNER"))
+}
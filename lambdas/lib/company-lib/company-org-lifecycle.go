@@ -0,0 +1,528 @@
+package Companylib
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ErrOrgReadOnly is returned when a write is attempted against a suspended organization
+var ErrOrgReadOnly = errors.New("organization is suspended and read-only until billing is resolved")
+
+// Default trial lifecycle settings
+var DefaultReminderDays = []int{7, 3, 1}
+
+const DefaultTrialGracePeriodDays = 3
+
+// LifecycleAction represents what the lifecycle job did for an organization
+type LifecycleAction string
+
+const (
+	LifecycleActionNone         LifecycleAction = "NONE"
+	LifecycleActionReminderSent LifecycleAction = "REMINDER_SENT"
+	LifecycleActionSuspended    LifecycleAction = "SUSPENDED"
+)
+
+// OrgLifecycleConfig configures trial expiry and dunning reminders
+type OrgLifecycleConfig struct {
+	ReminderDays         []int  // Days before trial end / suspension on which reminders are sent
+	TrialGracePeriodDays int    // Days after TrialEndDate before an unpaid trial is suspended
+	BillingURL           string // Link included in emails for admins to choose a plan or pay
+}
+
+// LifecycleResult summarises what ProcessTrialLifecycle or ProcessDunning did for a single organization
+type LifecycleResult struct {
+	OrganizationId string          `json:"organizationId"`
+	Action         LifecycleAction `json:"action"`
+	ReminderDays   int             `json:"reminderDays,omitempty"`
+	Recipients     []string        `json:"recipients,omitempty"`
+}
+
+// ParseReminderDays parses a comma separated list of reminder offsets (e.g. "7,3,1"). Offsets are
+// returned largest first; an empty string returns DefaultReminderDays.
+func ParseReminderDays(value string) ([]int, error) {
+	if strings.TrimSpace(value) == "" {
+		return append([]int{}, DefaultReminderDays...), nil
+	}
+
+	var days []int
+	for _, part := range strings.Split(value, ",") {
+		day, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || day < 0 {
+			return nil, fmt.Errorf("invalid reminder day %q", part)
+		}
+		days = append(days, day)
+	}
+
+	sort.Sort(sort.Reverse(sort.IntSlice(days)))
+	return days, nil
+}
+
+// IsWriteMethod reports whether an HTTP method modifies data
+func IsWriteMethod(method string) bool {
+	switch strings.ToUpper(method) {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// EnsureOrgWritable returns ErrOrgReadOnly when the organization is suspended
+func (svc *OrgServiceV2) EnsureOrgWritable(organizationId string) error {
+	org, err := svc.GetOrganization(organizationId)
+	if err != nil {
+		return err
+	}
+
+	if org.OrgBillingStatus == OrgBillingStatusSuspended {
+		return ErrOrgReadOnly
+	}
+
+	return nil
+}
+
+// EnsureTeamWritable returns ErrOrgReadOnly when the team's organization is suspended. A team that
+// does not exist is left for the caller to report. Any other lookup failure is returned, so writes
+// are refused rather than let through while the billing status cannot be read.
+func (svc *OrgServiceV2) EnsureTeamWritable(teamsSvc *TeamsServiceV2, teamId string) error {
+	if teamId == "" {
+		return nil
+	}
+
+	team, err := teamsSvc.GetTeamMetadata(teamId)
+	if errors.Is(err, ErrTeamNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to resolve organization for team %s: %w", teamId, err)
+	}
+
+	return svc.EnsureOrgWritable(team.OrgId)
+}
+
+// ListOrganizationsByBillingStatus returns all organizations with the given billing status
+func (svc *OrgServiceV2) ListOrganizationsByBillingStatus(status OrgBillingStatus) ([]Organization, error) {
+	scanInput := &dynamodb.ScanInput{
+		TableName:        aws.String(svc.OrganizationTable),
+		FilterExpression: aws.String("SK = :metadata AND OrgBillingStatus = :status"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":metadata": &types.AttributeValueMemberS{Value: "METADATA"},
+			":status":   &types.AttributeValueMemberS{Value: string(status)},
+		},
+	}
+
+	organizations := []Organization{}
+	for {
+		result, err := svc.dynamodbClient.Scan(svc.ctx, scanInput)
+		if err != nil {
+			svc.logger.Printf("Failed to scan organizations with status %s: %v", status, err)
+			return nil, fmt.Errorf("failed to scan organizations by billing status: %w", err)
+		}
+
+		var page []Organization
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal organizations: %w", err)
+		}
+		organizations = append(organizations, page...)
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		scanInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	return organizations, nil
+}
+
+// daysUntil returns the number of whole or part days from now until deadline (0 once it has passed)
+func daysUntil(deadline, now time.Time) int {
+	if !deadline.After(now) {
+		return 0
+	}
+	return int(math.Ceil(deadline.Sub(now).Hours() / 24))
+}
+
+// dueReminders returns the reminder offsets that have been reached but not yet sent. The job runs
+// daily, so after a missed run only the most urgent reminder is emailed but all reached offsets are
+// recorded as sent.
+func dueReminders(offsets []int, sent []int, daysLeft int) []int {
+	sentSet := map[int]bool{}
+	for _, day := range sent {
+		sentSet[day] = true
+	}
+
+	var due []int
+	for _, offset := range offsets {
+		if daysLeft <= offset && !sentSet[offset] {
+			due = append(due, offset)
+		}
+	}
+
+	sort.Sort(sort.Reverse(sort.IntSlice(due)))
+	return due
+}
+
+// ProcessTrialLifecycle handles a TRIAL organization at now: emails admins as the trial end date
+// approaches, sends a final notice once the trial has expired and suspends the organization when
+// the grace period has passed without a subscription.
+func (svc *OrgServiceV2) ProcessTrialLifecycle(org Organization, now time.Time, cfg OrgLifecycleConfig) (*LifecycleResult, error) {
+	result := &LifecycleResult{OrganizationId: org.OrganizationId, Action: LifecycleActionNone}
+
+	if org.OrgBillingStatus != OrgBillingStatusTrial || org.BillingMode == BillingModePaid {
+		return result, nil
+	}
+
+	trialEnd, err := time.Parse(time.RFC3339, org.TrialEndDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trial end date %q for organization %s: %w", org.TrialEndDate, org.OrganizationId, err)
+	}
+	suspendAt := trialEnd.AddDate(0, 0, cfg.TrialGracePeriodDays)
+
+	if !now.Before(suspendAt) {
+		if err := svc.suspendExpiredTrial(org, now); err != nil {
+			return nil, err
+		}
+		result.Action = LifecycleActionSuspended
+
+		// The suspension stands even if the notice cannot be delivered
+		notice := buildTrialSuspendedNotice(org, cfg)
+		if recipients, err := svc.sendBillingNotice(org, notice); err != nil {
+			svc.logger.Printf("Failed to send suspension notice for organization %s: %v", org.OrganizationId, err)
+		} else {
+			result.Recipients = recipients
+		}
+		return result, nil
+	}
+
+	// Reminders before the trial ends count down to TrialEndDate, the expiry notice (offset 0) is
+	// sent once during the grace period
+	daysLeft := daysUntil(trialEnd, now)
+	offsets := append(append([]int{}, cfg.ReminderDays...), 0)
+	due := dueReminders(offsets, org.TrialRemindersSent, daysLeft)
+	if len(due) == 0 {
+		return result, nil
+	}
+
+	notice := buildTrialReminderNotice(org, daysLeft, trialEnd, suspendAt, cfg)
+	recipients, err := svc.sendBillingNotice(org, notice)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := svc.recordRemindersSent(org.OrganizationId, "TrialRemindersSent", append(org.TrialRemindersSent, due...), now); err != nil {
+		return nil, err
+	}
+
+	result.Action = LifecycleActionReminderSent
+	result.ReminderDays = due[len(due)-1]
+	result.Recipients = recipients
+	return result, nil
+}
+
+// ProcessDunning emails admins of an OVERDUE organization as the suspension date of its outstanding
+// invoice approaches. The suspension itself is applied by the renewal job (ProcessRenewal).
+func (svc *OrgServiceV2) ProcessDunning(org Organization, now time.Time, cfg OrgLifecycleConfig) (*LifecycleResult, error) {
+	result := &LifecycleResult{OrganizationId: org.OrganizationId, Action: LifecycleActionNone}
+
+	if org.OrgBillingStatus != OrgBillingStatusOverdue || org.OutstandingInvoiceId == "" {
+		return result, nil
+	}
+
+	invoice, err := svc.GetInvoice(org.OrganizationId, org.OutstandingInvoiceId)
+	if err != nil {
+		return nil, err
+	}
+	if invoice.Status != InvoiceStatusOpen {
+		return result, nil
+	}
+
+	dueDate, err := time.Parse(time.RFC3339, invoice.DueDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid due date %q on invoice %s: %w", invoice.DueDate, invoice.InvoiceId, err)
+	}
+	suspendAt := dueDate.AddDate(0, 0, BillingGracePeriodDays)
+
+	daysLeft := daysUntil(suspendAt, now)
+	due := dueReminders(cfg.ReminderDays, org.DunningRemindersSent, daysLeft)
+	if len(due) == 0 || daysLeft == 0 {
+		return result, nil
+	}
+
+	notice := buildDunningNotice(org, *invoice, daysLeft, suspendAt, cfg)
+	recipients, err := svc.sendBillingNotice(org, notice)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := svc.recordRemindersSent(org.OrganizationId, "DunningRemindersSent", append(org.DunningRemindersSent, due...), now); err != nil {
+		return nil, err
+	}
+
+	result.Action = LifecycleActionReminderSent
+	result.ReminderDays = due[len(due)-1]
+	result.Recipients = recipients
+	return result, nil
+}
+
+// suspendExpiredTrial moves an expired trial to SUSPENDED. The update only applies if the trial has
+// not been extended or converted to a subscription since the organization was read.
+func (svc *OrgServiceV2) suspendExpiredTrial(org Organization, now time.Time) error {
+	nowStr := now.Format(time.RFC3339)
+
+	_, err := svc.dynamodbClient.UpdateItem(svc.ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(svc.OrganizationTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: orgPartitionKey(org.OrganizationId)},
+			"SK": &types.AttributeValueMemberS{Value: "METADATA"},
+		},
+		UpdateExpression:    aws.String("SET OrgBillingStatus = :suspended, SuspendedAt = :now, UpdatedAt = :now"),
+		ConditionExpression: aws.String("OrgBillingStatus = :trial AND TrialEndDate = :trialEndDate"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":suspended":    &types.AttributeValueMemberS{Value: string(OrgBillingStatusSuspended)},
+			":trial":        &types.AttributeValueMemberS{Value: string(OrgBillingStatusTrial)},
+			":trialEndDate": &types.AttributeValueMemberS{Value: org.TrialEndDate},
+			":now":          &types.AttributeValueMemberS{Value: nowStr},
+		},
+	})
+	if err != nil {
+		svc.logger.Printf("Failed to suspend organization %s: %v", org.OrganizationId, err)
+		return fmt.Errorf("failed to suspend organization: %w", err)
+	}
+
+	svc.logger.Printf("Suspended organization %s: trial ended %s", org.OrganizationId, org.TrialEndDate)
+	return nil
+}
+
+// recordRemindersSent stores the reminder offsets already emailed for an organization
+func (svc *OrgServiceV2) recordRemindersSent(organizationId string, attribute string, sent []int, now time.Time) error {
+	sentValues := make([]types.AttributeValue, 0, len(sent))
+	for _, day := range sent {
+		sentValues = append(sentValues, &types.AttributeValueMemberN{Value: strconv.Itoa(day)})
+	}
+
+	_, err := svc.dynamodbClient.UpdateItem(svc.ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(svc.OrganizationTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: orgPartitionKey(organizationId)},
+			"SK": &types.AttributeValueMemberS{Value: "METADATA"},
+		},
+		UpdateExpression: aws.String("SET #sent = :sent, UpdatedAt = :updatedAt"),
+		ExpressionAttributeNames: map[string]string{
+			"#sent": attribute,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":sent":      &types.AttributeValueMemberL{Value: sentValues},
+			":updatedAt": &types.AttributeValueMemberS{Value: now.Format(time.RFC3339)},
+		},
+		ConditionExpression: aws.String("attribute_exists(OrganizationId)"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record reminders sent: %w", err)
+	}
+
+	return nil
+}
+
+// listBillingContacts returns the email addresses that receive billing notices: the organization
+// contact email plus every active OWNER, ADMIN and BILLING_ONLY admin
+func (svc *OrgServiceV2) listBillingContacts(org Organization) ([]string, error) {
+	result, err := svc.dynamodbClient.Query(svc.ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(svc.OrganizationTable),
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk_prefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":        &types.AttributeValueMemberS{Value: orgPartitionKey(org.OrganizationId)},
+			":sk_prefix": &types.AttributeValueMemberS{Value: "ADMIN#"},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query org admins: %w", err)
+	}
+
+	var admins []OrgAdmin
+	if err := attributevalue.UnmarshalListOfMaps(result.Items, &admins); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal org admins: %w", err)
+	}
+
+	seen := map[string]bool{}
+	var contacts []string
+	add := func(email string) {
+		email = strings.TrimSpace(email)
+		if email != "" && !seen[strings.ToLower(email)] {
+			seen[strings.ToLower(email)] = true
+			contacts = append(contacts, email)
+		}
+	}
+
+	add(org.ContactEmail)
+	for _, admin := range admins {
+		if !admin.IsActive || admin.Role == OrgAdminRolePerformanceOnly {
+			continue
+		}
+		add(admin.UserName)
+	}
+
+	return contacts, nil
+}
+
+// billingNotice is the content of a trial or dunning email
+type billingNotice struct {
+	Subject  string
+	Headline string
+	Message  string
+	Action   string
+	Link     string
+}
+
+// sendBillingNotice emails a billing notice to the organization's billing contacts
+func (svc *OrgServiceV2) sendBillingNotice(org Organization, notice billingNotice) ([]string, error) {
+	if svc.emailSvc == nil {
+		return nil, fmt.Errorf("email service is not configured")
+	}
+
+	recipients, err := svc.listBillingContacts(org)
+	if err != nil {
+		return nil, err
+	}
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("organization %s has no billing contacts", org.OrganizationId)
+	}
+
+	err = svc.emailSvc.SendEmail(EmailInput{
+		ToEmails: recipients,
+		Subject:  notice.Subject,
+		HtmlBody: buildBillingNoticeHTML(notice),
+		TextBody: buildBillingNoticeText(notice),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to send billing notice: %w", err)
+	}
+
+	svc.logger.Printf("Sent billing notice %q for organization %s to %d recipient(s)", notice.Subject, org.OrganizationId, len(recipients))
+	return recipients, nil
+}
+
+func orgDisplayName(org Organization) string {
+	if org.OrgName != "" {
+		return org.OrgName
+	}
+	return "your organization"
+}
+
+func pluralDays(days int) string {
+	if days == 1 {
+		return "1 day"
+	}
+	return fmt.Sprintf("%d days", days)
+}
+
+func buildTrialReminderNotice(org Organization, daysLeft int, trialEnd, suspendAt time.Time, cfg OrgLifecycleConfig) billingNotice {
+	name := orgDisplayName(org)
+	if daysLeft == 0 {
+		return billingNotice{
+			Subject:  fmt.Sprintf("Your %s trial has ended", name),
+			Headline: "Your free trial has ended",
+			Message: fmt.Sprintf("The free trial for %s ended on %s. Choose a plan before %s to keep full access; after that the organization becomes read-only.",
+				name, trialEnd.Format("2 January 2006"), suspendAt.Format("2 January 2006")),
+			Action: "Choose a plan",
+			Link:   cfg.BillingURL,
+		}
+	}
+
+	return billingNotice{
+		Subject:  fmt.Sprintf("Your %s trial ends in %s", name, pluralDays(daysLeft)),
+		Headline: fmt.Sprintf("%s left in your free trial", pluralDays(daysLeft)),
+		Message: fmt.Sprintf("The free trial for %s ends on %s. Choose a plan to keep your teams, feeds and performance data without interruption.",
+			name, trialEnd.Format("2 January 2006")),
+		Action: "Choose a plan",
+		Link:   cfg.BillingURL,
+	}
+}
+
+func buildTrialSuspendedNotice(org Organization, cfg OrgLifecycleConfig) billingNotice {
+	name := orgDisplayName(org)
+	return billingNotice{
+		Subject:  fmt.Sprintf("%s has been suspended", name),
+		Headline: "Your organization is now read-only",
+		Message: fmt.Sprintf("The free trial and grace period for %s have ended. Your data is safe and can still be viewed, but changes are disabled until a plan is chosen.",
+			name),
+		Action: "Reactivate with a plan",
+		Link:   cfg.BillingURL,
+	}
+}
+
+func buildDunningNotice(org Organization, invoice Invoice, daysLeft int, suspendAt time.Time, cfg OrgLifecycleConfig) billingNotice {
+	name := orgDisplayName(org)
+	return billingNotice{
+		Subject:  fmt.Sprintf("Payment overdue for %s - %s until suspension", name, pluralDays(daysLeft)),
+		Headline: "Your payment is overdue",
+		Message: fmt.Sprintf("Invoice %s for %s %.2f is overdue. Please pay it before %s to avoid %s becoming read-only.",
+			invoice.InvoiceId, invoice.Currency, invoice.Total, suspendAt.Format("2 January 2006"), name),
+		Action: "Pay invoice",
+		Link:   cfg.BillingURL,
+	}
+}
+
+func buildBillingNoticeHTML(notice billingNotice) string {
+	link := notice.Link
+	if link == "" {
+		link = "#"
+	}
+
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="UTF-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1.0">
+</head>
+<body style="margin: 0; padding: 0; font-family: Arial, sans-serif; background-color: #f4f4f4;">
+	<table width="100%%" cellpadding="0" cellspacing="0" style="background-color: #f4f4f4; padding: 20px;">
+		<tr>
+			<td align="center">
+				<table width="600" cellpadding="0" cellspacing="0" style="background-color: #ffffff; border-radius: 8px; padding: 40px;">
+					<tr>
+						<td>
+							<h1 style="color: #333333; font-size: 24px; margin: 0 0 20px 0;">%s</h1>
+							<p style="color: #555555; font-size: 16px; line-height: 1.5;">%s</p>
+							<div style="text-align: center; margin: 30px 0;">
+								<a href="%s" style="background-color: #007bff; color: #ffffff; padding: 12px 30px; text-decoration: none; border-radius: 5px; font-size: 16px;">%s</a>
+							</div>
+							<p style="color: #999999; font-size: 12px; text-align: center;">© 2026 Gomovo Hub. All rights reserved.</p>
+						</td>
+					</tr>
+				</table>
+			</td>
+		</tr>
+	</table>
+</body>
+</html>`, notice.Headline, notice.Message, link, notice.Action)
+}
+
+func buildBillingNoticeText(notice billingNotice) string {
+	link := notice.Link
+	if link == "" {
+		link = "#"
+	}
+
+	return fmt.Sprintf(`%s
+
+Hello,
+
+%s
+
+%s:
+%s
+
+© 2026 Gomovo Hub. All rights reserved.
+`, notice.Headline, notice.Message, notice.Action, link)
+}
@@ -0,0 +1,254 @@
+package Companylib
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodb_types "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	awsclients "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/clients"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseReminderDays(t *testing.T) {
+	t.Run("It should return the defaults for an empty value", func(t *testing.T) {
+		days, err := ParseReminderDays("")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []int{7, 3, 1}, days)
+	})
+
+	t.Run("It should parse and order offsets largest first", func(t *testing.T) {
+		days, err := ParseReminderDays("1, 14,3")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []int{14, 3, 1}, days)
+	})
+
+	t.Run("It should reject invalid offsets", func(t *testing.T) {
+		_, err := ParseReminderDays("7,soon")
+
+		assert.Error(t, err)
+	})
+}
+
+func TestDueReminders(t *testing.T) {
+	t.Run("It should return nothing before the first offset", func(t *testing.T) {
+		assert.Empty(t, dueReminders([]int{7, 3, 1}, nil, 10))
+	})
+
+	t.Run("It should skip offsets already sent", func(t *testing.T) {
+		assert.Equal(t, []int{3}, dueReminders([]int{7, 3, 1}, []int{7}, 3))
+	})
+
+	t.Run("It should catch up on every offset reached after a missed run", func(t *testing.T) {
+		assert.Equal(t, []int{7, 3, 1}, dueReminders([]int{7, 3, 1}, nil, 1))
+	})
+}
+
+func TestProcessTrialLifecycle(t *testing.T) {
+	now := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	cfg := OrgLifecycleConfig{ReminderDays: []int{7, 3, 1}, TrialGracePeriodDays: 3, BillingURL: "https://app.example.com/billing"}
+
+	newService := func(ddbClient *awsclients.MockDynamodbClient) *OrgServiceV2 {
+		return &OrgServiceV2{
+			ctx:               context.Background(),
+			dynamodbClient:    ddbClient,
+			logger:            log.New(&bytes.Buffer{}, "TEST:", 0),
+			OrganizationTable: "OrgTable-test",
+		}
+	}
+
+	t.Run("It should suspend a trial once the grace period has passed", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			UpdateItemOutputs: []dynamodb.UpdateItemOutput{{}},
+			UpdateItemErrors:  []error{nil},
+		}
+		svc := newService(&ddbClient)
+
+		org := Organization{
+			OrganizationId:   "org-1",
+			BillingMode:      BillingModeFree,
+			OrgBillingStatus: OrgBillingStatusTrial,
+			TrialEndDate:     "2025-03-06T00:00:00Z",
+		}
+
+		result, err := svc.ProcessTrialLifecycle(org, now, cfg)
+
+		assert.NoError(t, err)
+		assert.Equal(t, LifecycleActionSuspended, result.Action)
+		assert.Len(t, ddbClient.UpdateItemInputs, 1)
+		assert.Equal(t, "OrgBillingStatus = :trial AND TrialEndDate = :trialEndDate", *ddbClient.UpdateItemInputs[0].ConditionExpression)
+		assert.Equal(t, &dynamodb_types.AttributeValueMemberS{Value: "SUSPENDED"}, ddbClient.UpdateItemInputs[0].ExpressionAttributeValues[":suspended"])
+	})
+
+	t.Run("It should not suspend during the grace period", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{}
+		svc := newService(&ddbClient)
+
+		org := Organization{
+			OrganizationId:     "org-1",
+			OrgBillingStatus:   OrgBillingStatusTrial,
+			TrialEndDate:       "2025-03-09T00:00:00Z",
+			TrialRemindersSent: []int{7, 3, 1, 0},
+		}
+
+		result, err := svc.ProcessTrialLifecycle(org, now, cfg)
+
+		assert.NoError(t, err)
+		assert.Equal(t, LifecycleActionNone, result.Action)
+		assert.Len(t, ddbClient.UpdateItemInputs, 0)
+	})
+
+	t.Run("It should not record a reminder that could not be sent", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{}
+		svc := newService(&ddbClient)
+
+		org := Organization{
+			OrganizationId:   "org-1",
+			OrgBillingStatus: OrgBillingStatusTrial,
+			TrialEndDate:     "2025-03-13T00:00:00Z",
+		}
+
+		_, err := svc.ProcessTrialLifecycle(org, now, cfg)
+
+		assert.Error(t, err)
+		assert.Len(t, ddbClient.UpdateItemInputs, 0)
+	})
+
+	t.Run("It should ignore organizations that are not on trial", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{}
+		svc := newService(&ddbClient)
+
+		org := Organization{
+			OrganizationId:   "org-1",
+			BillingMode:      BillingModePaid,
+			OrgBillingStatus: OrgBillingStatusActive,
+			TrialEndDate:     "2025-01-01T00:00:00Z",
+		}
+
+		result, err := svc.ProcessTrialLifecycle(org, now, cfg)
+
+		assert.NoError(t, err)
+		assert.Equal(t, LifecycleActionNone, result.Action)
+	})
+}
+
+func TestEnsureOrgWritable(t *testing.T) {
+	orgItem := func(status OrgBillingStatus) map[string]dynamodb_types.AttributeValue {
+		item, _ := attributevalue.MarshalMap(Organization{PK: "ORG#org-1", SK: "METADATA", OrganizationId: "org-1", OrgBillingStatus: status})
+		return item
+	}
+
+	testCases := []struct {
+		name        string
+		status      OrgBillingStatus
+		expectedErr error
+	}{
+		{name: "It should allow writes for an active organization", status: OrgBillingStatusActive},
+		{name: "It should allow writes for an overdue organization", status: OrgBillingStatusOverdue},
+		{name: "It should reject writes for a suspended organization", status: OrgBillingStatusSuspended, expectedErr: ErrOrgReadOnly},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ddbClient := awsclients.MockDynamodbClient{
+				GetItemOutputs: []dynamodb.GetItemOutput{{Item: orgItem(tc.status)}},
+				GetItemErrors:  []error{nil},
+			}
+			svc := OrgServiceV2{
+				ctx:               context.Background(),
+				dynamodbClient:    &ddbClient,
+				logger:            log.New(&bytes.Buffer{}, "TEST:", 0),
+				OrganizationTable: "OrgTable-test",
+			}
+
+			err := svc.EnsureOrgWritable("org-1")
+
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestEnsureTeamWritable(t *testing.T) {
+	teamItem, _ := attributevalue.MarshalMap(TeamMetadata{PK: "TEAM#1", SK: "METADATA", TeamId: "TEAM#1", OrgId: "org-1"})
+	orgItem := func(status OrgBillingStatus) map[string]dynamodb_types.AttributeValue {
+		item, _ := attributevalue.MarshalMap(Organization{PK: "ORG#org-1", SK: "METADATA", OrganizationId: "org-1", OrgBillingStatus: status})
+		return item
+	}
+
+	testCases := []struct {
+		name          string
+		getOutputs    []dynamodb.GetItemOutput
+		getErrors     []error
+		expectedErr   error
+		expectFailure bool
+	}{
+		{
+			name:       "It should allow writes for a team of an active organization",
+			getOutputs: []dynamodb.GetItemOutput{{Item: teamItem}, {Item: orgItem(OrgBillingStatusActive)}},
+			getErrors:  []error{nil, nil},
+		},
+		{
+			name:        "It should reject writes for a team of a suspended organization",
+			getOutputs:  []dynamodb.GetItemOutput{{Item: teamItem}, {Item: orgItem(OrgBillingStatusSuspended)}},
+			getErrors:   []error{nil, nil},
+			expectedErr: ErrOrgReadOnly,
+		},
+		{
+			name:       "It should leave a missing team to the caller",
+			getOutputs: []dynamodb.GetItemOutput{{}},
+			getErrors:  []error{nil},
+		},
+		{
+			name:          "It should refuse writes when the team cannot be read",
+			getOutputs:    []dynamodb.GetItemOutput{{}},
+			getErrors:     []error{errors.New("throttled")},
+			expectFailure: true,
+		},
+		{
+			name:          "It should refuse writes when the organization cannot be read",
+			getOutputs:    []dynamodb.GetItemOutput{{Item: teamItem}, {}},
+			getErrors:     []error{nil, errors.New("throttled")},
+			expectFailure: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ddbClient := awsclients.MockDynamodbClient{
+				GetItemOutputs: tc.getOutputs,
+				GetItemErrors:  tc.getErrors,
+			}
+			logger := log.New(&bytes.Buffer{}, "TEST:", 0)
+			svc := OrgServiceV2{
+				ctx:               context.Background(),
+				dynamodbClient:    &ddbClient,
+				logger:            logger,
+				OrganizationTable: "OrgTable-test",
+			}
+			teamsSvc := &TeamsServiceV2{ctx: context.Background(), dynamodbClient: &ddbClient, logger: logger, TeamsTable: "TeamsTable-test"}
+
+			err := svc.EnsureTeamWritable(teamsSvc, "TEAM#1")
+
+			switch {
+			case tc.expectedErr != nil:
+				assert.ErrorIs(t, err, tc.expectedErr)
+			case tc.expectFailure:
+				assert.Error(t, err)
+				assert.NotErrorIs(t, err, ErrOrgReadOnly)
+			default:
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
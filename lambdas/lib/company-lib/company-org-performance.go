@@ -2,6 +2,7 @@ package Companylib
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"sort"
@@ -46,6 +47,7 @@ type PerformanceService struct {
 
 	OrgPerformanceTable string
 	OrganizationTable   string
+	PerfHubTable        string // TeamMemberReviewRecord summaries are mirrored here by the review workflow
 }
 
 type PerformanceRecord struct {
@@ -62,6 +64,7 @@ type PerformanceRecord struct {
 	Status         string                 `dynamodbav:"Status,omitempty"`
 	CreatedAt      string                 `dynamodbav:"CreatedAt"`
 	UpdatedAt      string                 `dynamodbav:"UpdatedAt"`
+	SchemaVersion  int                    `dynamodbav:"SchemaVersion,omitempty"` // see company-perf-schemas.go
 	Data           map[string]interface{} `dynamodbav:"Data"`
 }
 
@@ -333,6 +336,10 @@ func (svc *PerformanceService) paginate(items []map[string]interface{}, options
 }
 
 func (svc *PerformanceService) CreatePerformanceCycle(orgID string, input map[string]interface{}) (map[string]interface{}, error) {
+	input, err := decodePerfInput(perfEntityCycle, input, nil)
+	if err != nil {
+		return nil, err
+	}
 	cycleID := svc.generateID("cycle")
 	now := svc.now()
 	if input["status"] == nil || toString(input["status"]) == "" {
@@ -353,6 +360,7 @@ func (svc *PerformanceService) CreatePerformanceCycle(orgID string, input map[st
 		Status:         toString(input["status"]),
 		CreatedAt:      now,
 		UpdatedAt:      now,
+		SchemaVersion:  perfDataSchemaVersion,
 		Data:           input,
 	}
 
@@ -435,24 +443,26 @@ func (svc *PerformanceService) GetPerformanceCycleDetails(cycleID string, includ
 		kpis := make([]map[string]interface{}, 0)
 		for _, item := range related {
 			if item.EntityType == perfEntityKPI {
-				kpis = append(kpis, svc.toPayload(&item))
+				kpis = append(kpis, svc.kpiPayload(&item))
 			}
 		}
 		result["kpis"] = kpis
 	}
 
 	if includeOKRs {
+		periods, keyResultsByOKR := svc.okrReadRecords(related)
+		periods[rec.GSI1PK] = rec
 		okrs := make([]map[string]interface{}, 0)
-		for _, item := range related {
-			if item.EntityType == perfEntityOKR {
-				okrs = append(okrs, svc.toPayload(&item))
+		for i := range related {
+			if related[i].EntityType == perfEntityOKR {
+				okrs = append(okrs, svc.okrPayload(&related[i], periods, keyResultsByOKR))
 			}
 		}
 		result["okrs"] = okrs
 	}
 
 	if includeAnalytics {
-		analytics, err := svc.GetCycleAnalytics(cycleID)
+		analytics, err := svc.GetCycleAnalytics(cycleID, "")
 		if err != nil {
 			return nil, err
 		}
@@ -470,7 +480,7 @@ func (svc *PerformanceService) UpdatePerformanceCycle(cycleID string, patch map[
 	if rec == nil {
 		return nil, fmt.Errorf("performance cycle not found")
 	}
-	updated, err := svc.patchRecord(rec, patch)
+	updated, err := svc.patchTypedRecord(rec, patch)
 	if err != nil {
 		return nil, err
 	}
@@ -492,6 +502,11 @@ func (svc *PerformanceService) DeletePerformanceCycle(cycleID string) error {
 		return err
 	}
 	for i := range related {
+		if related[i].EntityType == perfEntityOKR {
+			if err := svc.deleteOKRProgressHistory(toString(related[i].Data["id"]), rec.OrganizationId); err != nil {
+				return err
+			}
+		}
 		if err := svc.deleteRecord(&related[i]); err != nil {
 			return err
 		}
@@ -507,6 +522,10 @@ func (svc *PerformanceService) CreateQuarter(cycleID string, input map[string]in
 	if cycle == nil {
 		return nil, fmt.Errorf("performance cycle not found")
 	}
+	input, err = decodePerfInput(perfEntityQuarter, input, nil)
+	if err != nil {
+		return nil, err
+	}
 
 	quarterID := svc.generateID("quarter")
 	now := svc.now()
@@ -531,6 +550,7 @@ func (svc *PerformanceService) CreateQuarter(cycleID string, input map[string]in
 		Status:         toString(input["status"]),
 		CreatedAt:      now,
 		UpdatedAt:      now,
+		SchemaVersion:  perfDataSchemaVersion,
 		Data:           input,
 	}
 
@@ -582,17 +602,25 @@ func (svc *PerformanceService) GetQuarterDetails(quarterID string, includeKPIs b
 		kpis := make([]map[string]interface{}, 0)
 		for _, item := range related {
 			if item.EntityType == perfEntityKPI && item.QuarterId == quarterID {
-				kpis = append(kpis, svc.toPayload(&item))
+				kpis = append(kpis, svc.kpiPayload(&item))
 			}
 		}
 		result["kpis"] = kpis
 	}
 
 	if includeOKRs {
+		cycle, err := svc.getRecordByGSI1(perfSKPrefix + "CYCLE#" + rec.CycleId)
+		if err != nil {
+			return nil, err
+		}
+		periods, keyResultsByOKR := svc.okrReadRecords(related)
+		if cycle != nil {
+			periods[cycle.GSI1PK] = cycle
+		}
 		okrs := make([]map[string]interface{}, 0)
-		for _, item := range related {
-			if item.EntityType == perfEntityOKR && item.QuarterId == quarterID {
-				okrs = append(okrs, svc.toPayload(&item))
+		for i := range related {
+			if related[i].EntityType == perfEntityOKR && related[i].QuarterId == quarterID {
+				okrs = append(okrs, svc.okrPayload(&related[i], periods, keyResultsByOKR))
 			}
 		}
 		result["okrs"] = okrs
@@ -609,7 +637,7 @@ func (svc *PerformanceService) GetQuarterDetails(quarterID string, includeKPIs b
 	}
 
 	if includePendingReviews {
-		result["pendingReviews"] = []interface{}{}
+		result["pendingReviews"] = pendingReviewQueue(related, quarterID)
 	}
 
 	return result, nil
@@ -623,7 +651,7 @@ func (svc *PerformanceService) UpdateQuarter(quarterID string, patch map[string]
 	if rec == nil {
 		return nil, fmt.Errorf("quarter not found")
 	}
-	updated, err := svc.patchRecord(rec, patch)
+	updated, err := svc.patchTypedRecord(rec, patch)
 	if err != nil {
 		return nil, err
 	}
@@ -666,12 +694,6 @@ func (svc *PerformanceService) validateKPIInput(input map[string]interface{}) er
 	if input["targetValue"] == nil {
 		return fmt.Errorf("targetValue is required")
 	}
-	green := toFloat(input["greenThreshold"])
-	amber := toFloat(input["amberThreshold"])
-	red := toFloat(input["redThreshold"])
-	if (input["greenThreshold"] != nil || input["amberThreshold"] != nil || input["redThreshold"] != nil) && !(green >= amber && amber >= red) {
-		return fmt.Errorf("greenThreshold must be >= amberThreshold >= redThreshold")
-	}
 	if toString(input["trend"]) != "" {
 		allowed := map[string]bool{"up": true, "down": true, "stable": true}
 		if !allowed[strings.ToLower(toString(input["trend"]))] {
@@ -684,10 +706,14 @@ func (svc *PerformanceService) validateKPIInput(input map[string]interface{}) er
 			return fmt.Errorf("invalid incentiveImpact")
 		}
 	}
-	return nil
+	return validateKPIEvaluation(input)
 }
 
 func (svc *PerformanceService) CreateKPI(input map[string]interface{}, parentKPIID string) (map[string]interface{}, error) {
+	input, err := decodePerfInput(perfEntityKPI, input, nil)
+	if err != nil {
+		return nil, err
+	}
 	if err := svc.validateKPIInput(input); err != nil {
 		return nil, err
 	}
@@ -733,13 +759,14 @@ func (svc *PerformanceService) CreateKPI(input map[string]interface{}, parentKPI
 		Status:         toString(input["status"]),
 		CreatedAt:      now,
 		UpdatedAt:      now,
+		SchemaVersion:  perfDataSchemaVersion,
 		Data:           input,
 	}
 
 	if err := svc.putRecord(record); err != nil {
 		return nil, err
 	}
-	return input, nil
+	return svc.kpiPayload(&record), nil
 }
 
 func (svc *PerformanceService) ListKPIs(orgID string, filters map[string]string, options ListQueryOptions, includeSubKPIs bool) (map[string]interface{}, error) {
@@ -754,7 +781,7 @@ func (svc *PerformanceService) ListKPIs(orgID string, filters map[string]string,
 		if r.EntityType != perfEntityKPI {
 			continue
 		}
-		kpi := svc.toPayload(&r)
+		kpi := svc.kpiPayload(&r)
 		if filters["cycleId"] != "" && r.CycleId != filters["cycleId"] {
 			continue
 		}
@@ -809,7 +836,7 @@ func (svc *PerformanceService) GetKPIDetails(kpiID string, includeSubKPIs bool,
 		return nil, fmt.Errorf("kpi not found")
 	}
 
-	result := svc.toPayload(rec)
+	result := svc.kpiPayload(rec)
 	if includeSubKPIs || includeValueHistory {
 		related, err := svc.queryByOrgPrefix(rec.OrganizationId, fmt.Sprintf("%sCYCLE#%s#", perfSKPrefix, rec.CycleId))
 		if err != nil {
@@ -819,7 +846,7 @@ func (svc *PerformanceService) GetKPIDetails(kpiID string, includeSubKPIs bool,
 			subs := make([]map[string]interface{}, 0)
 			for _, item := range related {
 				if item.EntityType == perfEntityKPI && item.ParentId == kpiID {
-					subs = append(subs, svc.toPayload(&item))
+					subs = append(subs, svc.kpiPayload(&item))
 				}
 			}
 			result["subKPIs"] = subs
@@ -849,11 +876,32 @@ func (svc *PerformanceService) UpdateKPI(kpiID string, patch map[string]interfac
 	if rec == nil {
 		return nil, fmt.Errorf("kpi not found")
 	}
+	// Only a patch to the evaluation is checked against the thresholds, so KPIs saved under
+	// earlier rules can still be edited
+	checkEvaluation := false
+	for _, field := range kpiEvaluationFields {
+		if _, ok := patch[field]; ok {
+			checkEvaluation = true
+		}
+	}
+	patch, err = preparePerfPatch(rec, patch)
+	if err != nil {
+		return nil, err
+	}
+	merged := mergePerfData(rec.Data, patch)
+	if checkEvaluation {
+		if err := validateKPIEvaluation(merged); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrKPIInvalid, err)
+		}
+	}
+	if patch["direction"] != nil {
+		patch["direction"] = merged["direction"]
+	}
 	updated, err := svc.patchRecord(rec, patch)
 	if err != nil {
 		return nil, err
 	}
-	return svc.toPayload(updated), nil
+	return svc.kpiPayload(updated), nil
 }
 
 func (svc *PerformanceService) DeleteKPI(kpiID string, deleteSubKPIs bool) error {
@@ -879,17 +927,25 @@ func (svc *PerformanceService) DeleteKPI(kpiID string, deleteSubKPIs bool) error
 		}
 	}
 
-	prefix := fmt.Sprintf("%sCYCLE#%s#KPI#%s#VALUE#", perfSKPrefix, rec.CycleId, kpiID)
-	values, err := svc.queryByOrgPrefix(rec.OrganizationId, prefix)
+	// values and their audit trail
+	prefix := fmt.Sprintf("%sCYCLE#%s#KPI#%s#", perfSKPrefix, rec.CycleId, kpiID)
+	children, err := svc.queryByOrgPrefix(rec.OrganizationId, prefix)
 	if err == nil {
-		for i := range values {
-			_ = svc.deleteRecord(&values[i])
+		for i := range children {
+			_ = svc.deleteRecord(&children[i])
 		}
 	}
 
 	return svc.deleteRecord(rec)
 }
 
+// AddKPIValue records the KPI's value for the reporting period containing the value's date. A
+// period that already has a value is rejected with ErrKPIValueDuplicate; see AmendKPIValue.
+//
+// Periods are worked out from each value's date with the KPI's current reporting frequency, so
+// values recorded before periods were tracked, or under an earlier frequency, still count. A value
+// for a period is stored under that period's key, which keeps concurrent submissions for the same
+// period from both being written.
 func (svc *PerformanceService) AddKPIValue(kpiID string, input map[string]interface{}, recordedBy string) (map[string]interface{}, error) {
 	kpi, err := svc.getRecordByGSI1(perfSKPrefix + "KPI#" + kpiID)
 	if err != nil {
@@ -899,19 +955,56 @@ func (svc *PerformanceService) AddKPIValue(kpiID string, input map[string]interf
 		return nil, fmt.Errorf("kpi not found")
 	}
 
+	value, err := kpiValueNumber(input["value"])
+	if err != nil {
+		return nil, err
+	}
+	input["value"] = value
+	if input["date"] == nil || toString(input["date"]) == "" {
+		input["date"] = time.Now().UTC().Format("2006-01-02")
+	}
+	date, err := time.Parse("2006-01-02", toString(input["date"]))
+	if err != nil {
+		return nil, fmt.Errorf("%w: date must be YYYY-MM-DD", ErrKPIValueInvalid)
+	}
+	if date.After(time.Now().UTC()) {
+		return nil, fmt.Errorf("%w: date cannot be in the future", ErrKPIValueInvalid)
+	}
+
+	values, err := svc.kpiValueRecords(kpi)
+	if err != nil {
+		return nil, err
+	}
+	delete(input, "reportingPeriod")
+	frequency := toString(kpi.Data["reportingFrequency"])
+	period, hasPeriod := kpiReportingPeriod(frequency, date)
+	if hasPeriod {
+		for i := range values {
+			if existing, ok := kpiReportingPeriod(frequency, kpiValueDate(values[i])); ok && existing.Key == period.Key {
+				return nil, fmt.Errorf("%w: %s already has value %s, amend it instead", ErrKPIValueDuplicate, period.Key, toString(values[i].Data["id"]))
+			}
+		}
+		input["reportingPeriod"] = period.Key
+	}
+
 	valueID := svc.generateID("kpi-value")
+	valueKey := valueID
+	if hasPeriod {
+		valueKey = "PERIOD#" + period.Key
+	}
 	now := svc.now()
 	input["id"] = valueID
 	input["kpiId"] = kpiID
 	input["recordedBy"] = recordedBy
 	input["createdAt"] = now
-	if input["date"] == nil || toString(input["date"]) == "" {
-		input["date"] = time.Now().UTC().Format("2006-01-02")
+	input["revision"] = 1
+	for _, field := range []string{"amendedBy", "amendedAt"} {
+		delete(input, field)
 	}
 
 	record := PerformanceRecord{
 		PK:             kpi.PK,
-		SK:             fmt.Sprintf("%sCYCLE#%s#KPI#%s#VALUE#%s", perfSKPrefix, kpi.CycleId, kpiID, valueID),
+		SK:             fmt.Sprintf("%sCYCLE#%s#KPI#%s#VALUE#%s", perfSKPrefix, kpi.CycleId, kpiID, valueKey),
 		GSI1PK:         fmt.Sprintf("%sKPI_VALUE#%s", perfSKPrefix, valueID),
 		GSI1SK:         fmt.Sprintf("%s#KPI#%s", kpi.OrganizationId, kpiID),
 		EntityType:     perfEntityKPIValue,
@@ -925,18 +1018,38 @@ func (svc *PerformanceService) AddKPIValue(kpiID string, input map[string]interf
 		Data:           input,
 	}
 
-	if err := svc.putRecord(record); err != nil {
-		return nil, err
+	item, err := attributevalue.MarshalMap(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal record: %w", err)
 	}
-
-	_, _ = svc.patchRecord(kpi, map[string]interface{}{
-		"currentValue": input["value"],
+	_, err = svc.dynamodbClient.PutItem(svc.ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(svc.performanceTableName()),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(SK)"),
 	})
+	if err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return nil, fmt.Errorf("%w: %s was recorded by another request, amend it instead", ErrKPIValueDuplicate, period.Key)
+		}
+		return nil, fmt.Errorf("failed to put record: %w", err)
+	}
+	svc.putKPIValueAudit(kpi, input, KPIValueActionCreated, map[string]interface{}{
+		"value": map[string]interface{}{"from": nil, "to": value},
+	}, "", recordedBy)
+
+	// a backfilled value does not replace a later one as the current value
+	svc.syncKPICurrentValue(kpi, append(values, record))
 
 	return input, nil
 }
 
 func (svc *PerformanceService) CreateOKR(input map[string]interface{}) (map[string]interface{}, error) {
+	keyResultsRaw, _ := input["keyResults"].([]interface{})
+	input, err := decodePerfInput(perfEntityOKR, input, nil)
+	if err != nil {
+		return nil, err
+	}
 	cycleID := toString(input["cycleId"])
 	if cycleID == "" {
 		return nil, fmt.Errorf("cycleId is required")
@@ -949,6 +1062,21 @@ func (svc *PerformanceService) CreateOKR(input map[string]interface{}) (map[stri
 		return nil, fmt.Errorf("performance cycle not found")
 	}
 
+	for i, kr := range keyResultsRaw {
+		krMap, ok := kr.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		krMap, err = decodePerfInput(perfEntityKeyResult, krMap, nil)
+		if err != nil {
+			return nil, fmt.Errorf("key result %d: %w", i+1, err)
+		}
+		if err := normalizeKeyResult(krMap); err != nil {
+			return nil, err
+		}
+		keyResultsRaw[i] = krMap
+	}
+
 	okrID := svc.generateID("okr")
 	now := svc.now()
 	if input["status"] == nil || toString(input["status"]) == "" {
@@ -972,13 +1100,14 @@ func (svc *PerformanceService) CreateOKR(input map[string]interface{}) (map[stri
 		Status:         toString(input["status"]),
 		CreatedAt:      now,
 		UpdatedAt:      now,
+		SchemaVersion:  perfDataSchemaVersion,
 		Data:           input,
 	}
 	if err := svc.putRecord(record); err != nil {
 		return nil, err
 	}
 
-	if keyResultsRaw, ok := input["keyResults"].([]interface{}); ok {
+	if keyResultsRaw != nil {
 		createdKRs := make([]map[string]interface{}, 0, len(keyResultsRaw))
 		for _, kr := range keyResultsRaw {
 			if krMap, ok := kr.(map[string]interface{}); ok {
@@ -992,10 +1121,22 @@ func (svc *PerformanceService) CreateOKR(input map[string]interface{}) (map[stri
 		input["keyResults"] = createdKRs
 	}
 
-	return input, nil
+	createdKRs, _ := input["keyResults"].([]map[string]interface{})
+	if _, err := svc.recalculateOKRProgress(&record, createdKRs, "created"); err != nil {
+		return nil, err
+	}
+	return svc.okrPayload(&record, nil, nil), nil
 }
 
 func (svc *PerformanceService) createKeyResult(okrRecord PerformanceRecord, kr map[string]interface{}) (map[string]interface{}, error) {
+	kr, err := decodePerfInput(perfEntityKeyResult, kr, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := normalizeKeyResult(kr); err != nil {
+		return nil, err
+	}
+
 	keyResultID := svc.generateID("kr")
 	now := svc.now()
 	kr["id"] = keyResultID
@@ -1019,6 +1160,7 @@ func (svc *PerformanceService) createKeyResult(okrRecord PerformanceRecord, kr m
 		Status:         toString(kr["status"]),
 		CreatedAt:      now,
 		UpdatedAt:      now,
+		SchemaVersion:  perfDataSchemaVersion,
 		Data:           kr,
 	}
 
@@ -1034,12 +1176,13 @@ func (svc *PerformanceService) ListOKRs(orgID string, filters map[string]string,
 		return nil, err
 	}
 
+	periods, keyResultsByOKR := svc.okrReadRecords(records)
 	okrs := make([]map[string]interface{}, 0)
 	for _, r := range records {
 		if r.EntityType != perfEntityOKR {
 			continue
 		}
-		okr := svc.toPayload(&r)
+		okr := svc.okrPayload(&r, periods, keyResultsByOKR)
 		if filters["cycleId"] != "" && r.CycleId != filters["cycleId"] {
 			continue
 		}
@@ -1099,7 +1242,7 @@ func (svc *PerformanceService) GetOKRDetails(okrID string, includeKeyResults boo
 		return nil, fmt.Errorf("okr not found")
 	}
 
-	result := svc.toPayload(rec)
+	result := svc.okrPayload(rec, nil, nil)
 	if includeKeyResults {
 		krs, err := svc.getKeyResultsForOKR(okrID, rec.OrganizationId, rec.CycleId)
 		if err != nil {
@@ -1108,7 +1251,11 @@ func (svc *PerformanceService) GetOKRDetails(okrID string, includeKeyResults boo
 		result["keyResults"] = krs
 	}
 	if includeProgressHistory {
-		result["progressHistory"] = []interface{}{}
+		history, err := svc.getOKRProgressHistory(okrID, rec.OrganizationId)
+		if err != nil {
+			return nil, err
+		}
+		result["progressHistory"] = history
 	}
 	return result, nil
 }
@@ -1121,11 +1268,11 @@ func (svc *PerformanceService) UpdateOKR(okrID string, patch map[string]interfac
 	if rec == nil {
 		return nil, fmt.Errorf("okr not found")
 	}
-	updated, err := svc.patchRecord(rec, patch)
+	updated, err := svc.patchTypedRecord(rec, patch)
 	if err != nil {
 		return nil, err
 	}
-	return svc.toPayload(updated), nil
+	return svc.okrPayload(updated, nil, nil), nil
 }
 
 func (svc *PerformanceService) DeleteOKR(okrID string) error {
@@ -1145,9 +1292,25 @@ func (svc *PerformanceService) DeleteOKR(okrID string) error {
 			}
 		}
 	}
+	if err := svc.deleteOKRProgressHistory(okrID, rec.OrganizationId); err != nil {
+		return err
+	}
 	return svc.deleteRecord(rec)
 }
 
+func (svc *PerformanceService) GetKeyResult(keyResultID string) (map[string]interface{}, error) {
+	rec, err := svc.getRecordByGSI1(perfSKPrefix + "KEYRESULT#" + keyResultID)
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil {
+		return nil, fmt.Errorf("key result not found")
+	}
+	return svc.toPayload(rec), nil
+}
+
+// UpdateKeyResult patches a key result, recomputes its progress and rolls it up into its OKR. The
+// OKR's new progress is returned under okrProgress.
 func (svc *PerformanceService) UpdateKeyResult(keyResultID string, patch map[string]interface{}) (map[string]interface{}, error) {
 	rec, err := svc.getRecordByGSI1(perfSKPrefix + "KEYRESULT#" + keyResultID)
 	if err != nil {
@@ -1156,11 +1319,44 @@ func (svc *PerformanceService) UpdateKeyResult(keyResultID string, patch map[str
 	if rec == nil {
 		return nil, fmt.Errorf("key result not found")
 	}
-	updated, err := svc.patchRecord(rec, patch)
+
+	patch, err = preparePerfPatch(rec, patch)
 	if err != nil {
 		return nil, err
 	}
-	return svc.toPayload(updated), nil
+	merged := mergePerfData(rec.Data, patch)
+	if err := normalizeKeyResult(merged); err != nil {
+		return nil, err
+	}
+
+	updated, err := svc.patchRecord(rec, merged)
+	if err != nil {
+		return nil, err
+	}
+	result := svc.toPayload(updated)
+
+	okr, err := svc.getRecordByGSI1(perfSKPrefix + "OKR#" + rec.ParentId)
+	if err != nil {
+		return nil, err
+	}
+	if okr == nil {
+		return result, nil
+	}
+	keyResults, err := svc.getKeyResultsForOKR(rec.ParentId, okr.OrganizationId, okr.CycleId)
+	if err != nil {
+		return nil, err
+	}
+	for i := range keyResults {
+		if toString(keyResults[i]["id"]) == keyResultID {
+			keyResults[i] = result
+		}
+	}
+	progress, err := svc.recalculateOKRProgress(okr, keyResults, keyResultID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to roll up OKR progress: %w", err)
+	}
+	result["okrProgress"] = progress
+	return result, nil
 }
 
 func (svc *PerformanceService) ListMeetingNotes(quarterID string, sortBy string, order string) (map[string]interface{}, error) {
@@ -1208,6 +1404,10 @@ func (svc *PerformanceService) CreateMeetingNote(quarterID string, input map[str
 	if quarter == nil {
 		return nil, fmt.Errorf("quarter not found")
 	}
+	input, err = decodePerfInput(perfEntityMeeting, input, nil)
+	if err != nil {
+		return nil, err
+	}
 
 	noteID := svc.generateID("note")
 	now := svc.now()
@@ -1229,6 +1429,7 @@ func (svc *PerformanceService) CreateMeetingNote(quarterID string, input map[str
 		QuarterId:      quarterID,
 		CreatedAt:      now,
 		UpdatedAt:      now,
+		SchemaVersion:  perfDataSchemaVersion,
 		Data:           input,
 	}
 	if err := svc.putRecord(record); err != nil {
@@ -1237,6 +1438,17 @@ func (svc *PerformanceService) CreateMeetingNote(quarterID string, input map[str
 	return input, nil
 }
 
+func (svc *PerformanceService) GetMeetingNote(noteID string) (map[string]interface{}, error) {
+	rec, err := svc.getRecordByGSI1(perfSKPrefix + "MEETING#" + noteID)
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil {
+		return nil, fmt.Errorf("meeting note not found")
+	}
+	return svc.toPayload(rec), nil
+}
+
 func (svc *PerformanceService) UpdateMeetingNote(noteID string, patch map[string]interface{}) (map[string]interface{}, error) {
 	rec, err := svc.getRecordByGSI1(perfSKPrefix + "MEETING#" + noteID)
 	if err != nil {
@@ -1245,7 +1457,7 @@ func (svc *PerformanceService) UpdateMeetingNote(noteID string, patch map[string
 	if rec == nil {
 		return nil, fmt.Errorf("meeting note not found")
 	}
-	updated, err := svc.patchRecord(rec, patch)
+	updated, err := svc.patchTypedRecord(rec, patch)
 	if err != nil {
 		return nil, err
 	}
@@ -1263,7 +1475,12 @@ func (svc *PerformanceService) DeleteMeetingNote(noteID string) error {
 	return svc.deleteRecord(rec)
 }
 
-func (svc *PerformanceService) GetCycleAnalytics(cycleID string) (map[string]interface{}, error) {
+// GetCycleAnalytics summarises a cycle's KPIs and OKRs, with KPI trends by month or week (granularity)
+func (svc *PerformanceService) GetCycleAnalytics(cycleID string, granularity string) (map[string]interface{}, error) {
+	granularity, err := normalizeTrendGranularity(granularity)
+	if err != nil {
+		return nil, err
+	}
 	cycle, err := svc.getRecordByGSI1(perfSKPrefix + "CYCLE#" + cycleID)
 	if err != nil {
 		return nil, err
@@ -1277,52 +1494,133 @@ func (svc *PerformanceService) GetCycleAnalytics(cycleID string) (map[string]int
 		return nil, err
 	}
 
+	analytics := svc.buildPerformanceAnalytics(cycle, related, "", granularity)
+	analytics["cycleId"] = cycleID
+	return analytics, nil
+}
+
+// GetQuarterAnalytics is the cycle analytics limited to the KPIs and OKRs of one quarter
+func (svc *PerformanceService) GetQuarterAnalytics(quarterID string, granularity string) (map[string]interface{}, error) {
+	granularity, err := normalizeTrendGranularity(granularity)
+	if err != nil {
+		return nil, err
+	}
+	quarter, err := svc.getRecordByGSI1(perfSKPrefix + "QUARTER#" + quarterID)
+	if err != nil {
+		return nil, err
+	}
+	if quarter == nil {
+		return nil, fmt.Errorf("quarter not found")
+	}
+
+	cycle, err := svc.getRecordByGSI1(perfSKPrefix + "CYCLE#" + quarter.CycleId)
+	if err != nil {
+		return nil, err
+	}
+	if cycle == nil {
+		return nil, fmt.Errorf("performance cycle not found")
+	}
+
+	related, err := svc.queryByOrgPrefix(cycle.OrganizationId, fmt.Sprintf("%sCYCLE#%s#", perfSKPrefix, quarter.CycleId))
+	if err != nil {
+		return nil, err
+	}
+
+	analytics := svc.buildPerformanceAnalytics(cycle, related, quarterID, granularity)
+	analytics["cycleId"] = quarter.CycleId
+	analytics["quarterId"] = quarterID
+	return analytics, nil
+}
+
+// buildPerformanceAnalytics summarises the KPIs and OKRs among a cycle's records, or only those of
+// quarterID when it is set. OKR progress is rolled up from the key results in the same records and
+// KPI trends are built from their KPI_VALUE records.
+func (svc *PerformanceService) buildPerformanceAnalytics(cycle *PerformanceRecord, related []PerformanceRecord, quarterID string, granularity string) map[string]interface{} {
+	now := time.Now().UTC()
+
+	periods, keyResultsByOKR := svc.okrReadRecords(related)
+	periods[cycle.GSI1PK] = cycle
+
 	totalKPIs := 0
 	kpisOnTrack := 0
 	kpisAtRisk := 0
 	kpisBehind := 0
+	kpisNoData := 0
 	totalOKRs := 0
 	okrsCompleted := 0
 	okrsOnTrack := 0
 	okrsAtRisk := 0
+	okrsBehind := 0
 	kpiProgressTotal := 0.0
 	okrProgressTotal := 0.0
 	departments := map[string][]float64{}
+	okrProgress := make([]map[string]interface{}, 0)
+	kpis := []*PerformanceRecord{}
 
-	for _, r := range related {
+	for i := range related {
+		r := &related[i]
+		if quarterID != "" && r.QuarterId != quarterID {
+			continue
+		}
 		switch r.EntityType {
 		case perfEntityKPI:
 			totalKPIs++
-			current := toFloat(r.Data["currentValue"])
-			target := toFloat(r.Data["targetValue"])
-			progress := 0.0
-			if target > 0 {
-				progress = (current / target) * 100
-			}
-			kpiProgressTotal += progress
-			if progress >= 90 {
+			kpis = append(kpis, r)
+			evaluation := evaluateKPI(r.Data)
+			kpiProgressTotal += evaluation.Progress
+			switch evaluation.RagStatus {
+			case KPIRagGreen:
 				kpisOnTrack++
-			} else if progress >= 60 {
+			case KPIRagAmber:
 				kpisAtRisk++
-			} else {
+			case KPIRagRed:
 				kpisBehind++
+			default:
+				kpisNoData++
 			}
 			dept := toString(r.Data["department"])
 			if dept != "" {
-				departments[dept] = append(departments[dept], progress)
+				departments[dept] = append(departments[dept], evaluation.Progress)
 			}
 		case perfEntityOKR:
 			totalOKRs++
-			status := strings.ToUpper(toString(r.Data["status"]))
-			if status == "COMPLETED" {
+			okrID := toString(r.Data["id"])
+			rollUp := rollUpOKRProgress(keyResultsByOKR[okrID], svc.okrPeriodExpectedProgress(r, periods, now))
+			okrProgressTotal += rollUp.Progress
+
+			health := rollUp.Health
+			if strings.EqualFold(toString(r.Data["status"]), "COMPLETED") {
+				health = OKRHealthCompleted
+			}
+			switch health {
+			case OKRHealthCompleted:
 				okrsCompleted++
-			} else if status == "ACTIVE" {
+			case OKRHealthOnTrack:
 				okrsOnTrack++
-			} else if status == "DRAFT" {
+			case OKRHealthAtRisk:
 				okrsAtRisk++
+			case OKRHealthBehind:
+				okrsBehind++
 			}
-			confidence := toFloat(r.Data["confidenceScore"])
-			okrProgressTotal += confidence * 10
+
+			entry := map[string]interface{}{
+				"okrId":          okrID,
+				"objective":      toString(r.Data["objective"]),
+				"quarterId":      r.QuarterId,
+				"progress":       rollUp.Progress,
+				"health":         health,
+				"keyResultCount": len(rollUp.KeyResults),
+			}
+			if rollUp.ExpectedProgress != nil {
+				entry["expectedProgress"] = *rollUp.ExpectedProgress
+			}
+			if hasValue(r.Data, "confidenceScore") {
+				entry["confidenceScore"] = toFloat(r.Data["confidenceScore"])
+			}
+			if rollUp.ComputedConfidence != nil {
+				entry["computedConfidence"] = *rollUp.ComputedConfidence
+			}
+			okrProgress = append(okrProgress, entry)
 		}
 	}
 
@@ -1332,8 +1630,13 @@ func (svc *PerformanceService) GetCycleAnalytics(cycleID string) (map[string]int
 	}
 	averageOKRProgress := 0.0
 	if totalOKRs > 0 {
-		averageOKRProgress = okrProgressTotal / float64(totalOKRs)
+		averageOKRProgress = roundProgress(okrProgressTotal / float64(totalOKRs))
 	}
+	sort.SliceStable(okrProgress, func(i, j int) bool {
+		return toFloat(okrProgress[i]["progress"]) < toFloat(okrProgress[j]["progress"]) // least progress first
+	})
+
+	kpiSeries, kpiTrends, departmentTrends, kpisProjectedToMiss := buildKPITrends(kpis, kpiReadingsByKPI(related), periods, granularity)
 
 	departmentPerf := make([]map[string]interface{}, 0)
 	for dept, values := range departments {
@@ -1353,46 +1656,28 @@ func (svc *PerformanceService) GetCycleAnalytics(cycleID string) (map[string]int
 	}
 
 	return map[string]interface{}{
-		"cycleId": cycleID,
 		"summary": map[string]interface{}{
-			"totalKPIs":          totalKPIs,
-			"kpisOnTrack":        kpisOnTrack,
-			"kpisAtRisk":         kpisAtRisk,
-			"kpisBehind":         kpisBehind,
-			"totalOKRs":          totalOKRs,
-			"okrsCompleted":      okrsCompleted,
-			"okrsOnTrack":        okrsOnTrack,
-			"okrsAtRisk":         okrsAtRisk,
-			"averageKPIProgress": averageKPIProgress,
-			"averageOKRProgress": averageOKRProgress,
-		},
-		"kpiTrends": []map[string]interface{}{
-			{
-				"month":   time.Now().UTC().Format("Jan 2006"),
-				"onTrack": kpisOnTrack,
-				"atRisk":  kpisAtRisk,
-				"behind":  kpisBehind,
-			},
+			"totalKPIs":           totalKPIs,
+			"kpisOnTrack":         kpisOnTrack,
+			"kpisAtRisk":          kpisAtRisk,
+			"kpisBehind":          kpisBehind,
+			"kpisNoData":          kpisNoData,
+			"totalOKRs":           totalOKRs,
+			"okrsCompleted":       okrsCompleted,
+			"okrsOnTrack":         okrsOnTrack,
+			"okrsAtRisk":          okrsAtRisk,
+			"okrsBehind":          okrsBehind,
+			"averageKPIProgress":  averageKPIProgress,
+			"averageOKRProgress":  averageOKRProgress,
+			"kpisProjectedToMiss": kpisProjectedToMiss,
 		},
+		"granularity":           granularity,
+		"kpiTrends":             kpiTrends,
+		"kpiSeries":             kpiSeries,
+		"departmentTrends":      departmentTrends,
+		"okrProgress":           okrProgress,
 		"departmentPerformance": departmentPerf,
-	}, nil
-}
-
-func (svc *PerformanceService) GetQuarterAnalytics(quarterID string) (map[string]interface{}, error) {
-	quarter, err := svc.getRecordByGSI1(perfSKPrefix + "QUARTER#" + quarterID)
-	if err != nil {
-		return nil, err
-	}
-	if quarter == nil {
-		return nil, fmt.Errorf("quarter not found")
-	}
-
-	cycleAnalytics, err := svc.GetCycleAnalytics(quarter.CycleId)
-	if err != nil {
-		return nil, err
 	}
-	cycleAnalytics["quarterId"] = quarterID
-	return cycleAnalytics, nil
 }
 
 func (svc *PerformanceService) findGoalBase(goalID string) (map[string]interface{}, *PerformanceRecord, string, error) {
@@ -1405,24 +1690,70 @@ func (svc *PerformanceService) findGoalBase(goalID string) (map[string]interface
 	if rec, err := svc.getRecordByGSI1(perfSKPrefix + "OKR#" + goalID); err != nil {
 		return nil, nil, "", err
 	} else if rec != nil {
-		payload := svc.toPayload(rec)
+		payload := svc.okrPayload(rec, nil, nil)
 		return payload, rec, "okr", nil
 	}
 	return nil, nil, "", fmt.Errorf("goal not found")
 }
 
+// goalOwnerAndTitle returns a goal's owner and title. KPIs store them as owner/name, OKRs as
+// objectiveOwner/objective.
+func goalOwnerAndTitle(goalType string, data map[string]interface{}) (string, string) {
+	if goalType == "okr" {
+		owner := toString(data["objectiveOwner"])
+		if owner == "" {
+			owner = toString(data["owner"])
+		}
+		return owner, toString(data["objective"])
+	}
+	return toString(data["owner"]), toString(data["name"])
+}
+
+// GetRecordOrganization returns the organization (ORG#...) that owns a cycle, quarter, kpi, okr or
+// goal, so a caller holding only a record ID can check it against the organization it acts in.
+// It returns "" when the record does not exist.
+func (svc *PerformanceService) GetRecordOrganization(kind string, id string) (string, error) {
+	var entityTypes []string
+	switch kind {
+	case "cycle":
+		entityTypes = []string{perfEntityCycle}
+	case "quarter":
+		entityTypes = []string{perfEntityQuarter}
+	case "kpi":
+		entityTypes = []string{perfEntityKPI}
+	case "okr":
+		entityTypes = []string{perfEntityOKR}
+	case "goal":
+		entityTypes = []string{perfEntityKPI, perfEntityOKR}
+	default:
+		return "", fmt.Errorf("unsupported record kind %q", kind)
+	}
+
+	for _, entityType := range entityTypes {
+		rec, err := svc.getRecordByGSI1(perfSKPrefix + entityType + "#" + id)
+		if err != nil {
+			return "", err
+		}
+		if rec != nil {
+			return rec.OrganizationId, nil
+		}
+	}
+	return "", nil
+}
+
 func (svc *PerformanceService) GetGoalDetails(goalID string, includeValueHistory bool, includeTaggedTeams bool, includeSubItems bool, includeLadderUp bool, includePrivateTasks bool, userName string) (map[string]interface{}, error) {
 	base, baseRec, goalType, err := svc.findGoalBase(goalID)
 	if err != nil {
 		return nil, err
 	}
 
+	owner, title := goalOwnerAndTitle(goalType, base)
 	result := map[string]interface{}{
 		"id":             goalID,
-		"name":           toString(base["name"]),
+		"name":           title,
 		"type":           goalType,
 		"description":    toString(base["description"]),
-		"owner":          toString(base["owner"]),
+		"owner":          owner,
 		"currentValue":   base["currentValue"],
 		"targetValue":    base["targetValue"],
 		"unit":           toString(base["unitOfMeasure"]),
@@ -1435,12 +1766,17 @@ func (svc *PerformanceService) GetGoalDetails(goalID string, includeValueHistory
 		"organizationId": baseRec.OrganizationId,
 	}
 
-	current := toFloat(base["currentValue"])
-	target := toFloat(base["targetValue"])
-	if target > 0 {
-		result["progress"] = (current / target) * 100
+	if goalType == "okr" {
+		// Rolled up from the key results whenever one changes
+		result["progress"] = toFloat(base["progress"])
+		result["health"] = base["health"]
+		result["confidenceScore"] = base["confidenceScore"]
+		result["computedConfidence"] = base["computedConfidence"]
 	} else {
-		result["progress"] = 0.0
+		evaluation := evaluateKPI(base)
+		result["progress"] = evaluation.Progress
+		result["direction"] = evaluation.Direction
+		result["ragStatus"] = evaluation.RagStatus
 	}
 
 	if includeValueHistory {
@@ -1471,7 +1807,7 @@ func (svc *PerformanceService) UpdateGoal(goalID string, patch map[string]interf
 	if rec, err := svc.getRecordByGSI1(perfSKPrefix + "KPI#" + goalID); err != nil {
 		return nil, err
 	} else if rec != nil {
-		updated, err := svc.patchRecord(rec, patch)
+		updated, err := svc.patchTypedRecord(rec, patch)
 		if err != nil {
 			return nil, err
 		}
@@ -1480,7 +1816,7 @@ func (svc *PerformanceService) UpdateGoal(goalID string, patch map[string]interf
 	if rec, err := svc.getRecordByGSI1(perfSKPrefix + "OKR#" + goalID); err != nil {
 		return nil, err
 	} else if rec != nil {
-		updated, err := svc.patchRecord(rec, patch)
+		updated, err := svc.patchTypedRecord(rec, patch)
 		if err != nil {
 			return nil, err
 		}
@@ -1698,26 +2034,29 @@ func (svc *PerformanceService) GetTeamGoals(teamID string, orgID string, goalTyp
 			//   name        → objective
 			//   owner       → objectiveOwner
 			//   deadline    → timeBound (descriptive string, e.g. "Half-Yearly")
-			//   currentValue/targetValue/unit are per key-result, not at OKR level
+			//   currentValue/targetValue/unit are per key-result, not at OKR level;
+			//   progress is rolled up from the key results
 			detail = map[string]interface{}{
-				"id":              gID,
-				"type":            gType,
-				"name":            toString(base["objective"]),
-				"objective":       toString(base["objective"]),
-				"owner":           toString(base["objectiveOwner"]),
-				"status":          toString(base["status"]),
-				"cycleId":         toString(base["cycleId"]),
-				"quarterId":       base["quarterId"],
-				"timeBound":       toString(base["timeBound"]),
-				"deadline":        toString(base["timeBound"]),
-				"confidenceScore": base["confidenceScore"],
-				"keyResults":      base["keyResults"],
-				"currentValue":    nil,
-				"targetValue":     nil,
-				"unit":            "",
-				"progress":        0.0,
-				"createdAt":       base["createdAt"],
-				"updatedAt":       base["updatedAt"],
+				"id":                 gID,
+				"type":               gType,
+				"name":               toString(base["objective"]),
+				"objective":          toString(base["objective"]),
+				"owner":              toString(base["objectiveOwner"]),
+				"status":             toString(base["status"]),
+				"cycleId":            toString(base["cycleId"]),
+				"quarterId":          base["quarterId"],
+				"timeBound":          toString(base["timeBound"]),
+				"deadline":           toString(base["timeBound"]),
+				"confidenceScore":    base["confidenceScore"],
+				"computedConfidence": base["computedConfidence"],
+				"keyResults":         base["keyResults"],
+				"currentValue":       nil,
+				"targetValue":        nil,
+				"unit":               "",
+				"progress":           toFloat(base["progress"]),
+				"health":             base["health"],
+				"createdAt":          base["createdAt"],
+				"updatedAt":          base["updatedAt"],
 			}
 		} else {
 			// KPIs store fields under standard keys
@@ -1737,13 +2076,10 @@ func (svc *PerformanceService) GetTeamGoals(teamID string, orgID string, goalTyp
 				"createdAt":    base["createdAt"],
 				"updatedAt":    base["updatedAt"],
 			}
-			current := toFloat(base["currentValue"])
-			target := toFloat(base["targetValue"])
-			if target > 0 {
-				detail["progress"] = (current / target) * 100
-			} else {
-				detail["progress"] = 0.0
-			}
+			evaluation := evaluateKPI(base)
+			detail["progress"] = evaluation.Progress
+			detail["direction"] = evaluation.Direction
+			detail["ragStatus"] = evaluation.RagStatus
 		}
 		goals = append(goals, detail)
 	}
@@ -1782,6 +2118,10 @@ func (svc *PerformanceService) AddGoalSubItem(goalID string, input map[string]in
 	if err != nil {
 		return nil, err
 	}
+	input, err = decodePerfInput(perfEntityGoalSub, input, nil)
+	if err != nil {
+		return nil, err
+	}
 	subItemID := svc.generateID("sub-item")
 	now := svc.now()
 	input["id"] = subItemID
@@ -1797,9 +2137,11 @@ func (svc *PerformanceService) AddGoalSubItem(goalID string, input map[string]in
 		EntityType:     perfEntityGoalSub,
 		OrganizationId: baseRec.OrganizationId,
 		ParentId:       goalID,
+		Owner:          toString(input["owner"]),
 		Status:         toString(input["status"]),
 		CreatedAt:      now,
 		UpdatedAt:      now,
+		SchemaVersion:  perfDataSchemaVersion,
 		Data:           input,
 	}
 	if err := svc.putRecord(record); err != nil {
@@ -1808,6 +2150,17 @@ func (svc *PerformanceService) AddGoalSubItem(goalID string, input map[string]in
 	return input, nil
 }
 
+func (svc *PerformanceService) GetSubItem(subItemID string) (map[string]interface{}, error) {
+	rec, err := svc.getRecordByGSI1(perfSKPrefix + "SUBITEM#" + subItemID)
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil {
+		return nil, fmt.Errorf("sub-item not found")
+	}
+	return svc.toPayload(rec), nil
+}
+
 func (svc *PerformanceService) UpdateSubItem(subItemID string, patch map[string]interface{}) (map[string]interface{}, error) {
 	rec, err := svc.getRecordByGSI1(perfSKPrefix + "SUBITEM#" + subItemID)
 	if err != nil {
@@ -1816,7 +2169,7 @@ func (svc *PerformanceService) UpdateSubItem(subItemID string, patch map[string]
 	if rec == nil {
 		return nil, fmt.Errorf("sub-item not found")
 	}
-	updated, err := svc.patchRecord(rec, patch)
+	updated, err := svc.patchTypedRecord(rec, patch)
 	if err != nil {
 		return nil, err
 	}
@@ -1845,7 +2198,10 @@ func (svc *PerformanceService) GetGoalLadderUp(goalID string, status string) (ma
 	}
 	items := make([]map[string]interface{}, 0)
 	for _, r := range records {
-		item := svc.toPayload(&r)
+		if r.EntityType != perfEntityLadderUp {
+			continue
+		}
+		item := svc.ladderUpPayload(&r)
 		if status != "" && !strings.EqualFold(toString(item["status"]), status) {
 			continue
 		}
@@ -1854,30 +2210,6 @@ func (svc *PerformanceService) GetGoalLadderUp(goalID string, status string) (ma
 	return map[string]interface{}{"ladderUpItems": items}, nil
 }
 
-func (svc *PerformanceService) updateLadderStatus(ladderUpID string, nextStatus string, patch map[string]interface{}) (map[string]interface{}, error) {
-	rec, err := svc.getRecordByGSI1(perfSKPrefix + "LADDER#" + ladderUpID)
-	if err != nil {
-		return nil, err
-	}
-	if rec == nil {
-		return nil, fmt.Errorf("ladder up item not found")
-	}
-	patch["status"] = nextStatus
-	updated, err := svc.patchRecord(rec, patch)
-	if err != nil {
-		return nil, err
-	}
-	return svc.toPayload(updated), nil
-}
-
-func (svc *PerformanceService) ApproveLadderUp(ladderUpID string, patch map[string]interface{}) (map[string]interface{}, error) {
-	return svc.updateLadderStatus(ladderUpID, "APPROVED", patch)
-}
-
-func (svc *PerformanceService) RejectLadderUp(ladderUpID string, patch map[string]interface{}) (map[string]interface{}, error) {
-	return svc.updateLadderStatus(ladderUpID, "REJECTED", patch)
-}
-
 func (svc *PerformanceService) GetGoalTasks(goalID string, userName string, filters map[string]string, options ListQueryOptions) (map[string]interface{}, error) {
 	_, baseRec, _, err := svc.findGoalBase(goalID)
 	if err != nil {
@@ -1920,20 +2252,14 @@ func (svc *PerformanceService) CreateGoalTask(goalID string, userName string, in
 	if err != nil {
 		return nil, err
 	}
-	if toString(input["title"]) == "" {
-		return nil, fmt.Errorf("title is required")
-	}
-	if len(toString(input["title"])) > 200 {
-		return nil, fmt.Errorf("title must be <= 200 characters")
+	input, err = decodePerfInput(perfEntityGoalTask, input, nil)
+	if err != nil {
+		return nil, err
 	}
 	status := toString(input["status"])
 	if status == "" {
 		status = perfTaskStatusTodo
 	}
-	allowed := map[string]bool{perfTaskStatusTodo: true, perfTaskStatusProg: true, perfTaskStatusDone: true}
-	if !allowed[status] {
-		return nil, fmt.Errorf("invalid status")
-	}
 
 	taskID := svc.generateID("task")
 	now := svc.now()
@@ -1959,6 +2285,7 @@ func (svc *PerformanceService) CreateGoalTask(goalID string, userName string, in
 		Status:         status,
 		CreatedAt:      now,
 		UpdatedAt:      now,
+		SchemaVersion:  perfDataSchemaVersion,
 		Data:           input,
 	}
 
@@ -1982,6 +2309,10 @@ func (svc *PerformanceService) UpdateGoalTask(goalID string, taskID string, user
 	if rec.ParentId != goalID {
 		return nil, fmt.Errorf("task does not belong to goal")
 	}
+	patch, err = preparePerfPatch(rec, patch)
+	if err != nil {
+		return nil, err
+	}
 	if status := toString(patch["status"]); status == perfTaskStatusDone && toString(patch["completedDate"]) == "" {
 		patch["completedDate"] = time.Now().UTC().Format("2006-01-02")
 	}
@@ -0,0 +1,528 @@
+package Companylib
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// MemberLimitErrorCode is returned to API clients (with HTTP 402) when a request would take an
+// organization over the MaxMembersAllowed of its plan
+const MemberLimitErrorCode = "MEMBER_LIMIT_REACHED"
+
+var ErrMemberLimitReached = errors.New("organization member limit reached")
+
+// OrgSeat records that a person occupies one of the organization's plan seats. There is one seat
+// per person regardless of how many teams they belong to or whether they are an admin, an org
+// user or both. Seats are created and deleted in the same transaction as the CurrentUserCount
+// change on the organization so the two never drift apart.
+type OrgSeat struct {
+	PK string `dynamodbav:"PK" json:"-"` // ORG#{organizationId}
+	SK string `dynamodbav:"SK" json:"-"` // SEAT#{username}
+
+	OrganizationId string `dynamodbav:"OrganizationId" json:"organizationId"`
+	UserName       string `dynamodbav:"UserName" json:"userName"`
+	ClaimedAt      string `dynamodbav:"ClaimedAt" json:"claimedAt"`
+}
+
+// OrgUsage summarises plan usage for the billing page. Allowed values of -1 mean unlimited.
+type OrgUsage struct {
+	OrganizationId string `json:"organizationId"`
+	PlanID         string `json:"planId"`
+
+	SeatsUsed      int  `json:"seatsUsed"`
+	SeatsAllowed   int  `json:"seatsAllowed"`
+	SeatsAvailable int  `json:"seatsAvailable"` // -1 when unlimited
+	SeatLimitHit   bool `json:"seatLimitReached"`
+
+	TeamsUsed      int  `json:"teamsUsed"`
+	TeamsAllowed   int  `json:"teamsAllowed"`
+	TeamsAvailable int  `json:"teamsAvailable"` // -1 when unlimited
+	TeamLimitHit   bool `json:"teamLimitReached"`
+}
+
+// SeatReservation holds the transaction items that claim seats for a set of people. Callers add
+// Items to their own TransactWriteItems call so the seats are only taken if the membership write
+// succeeds, and pass any transaction error through TransactionError.
+type SeatReservation struct {
+	Items    []types.TransactWriteItem
+	NewSeats []string // People who did not hold a seat before this reservation
+
+	counterIndex int // Index of the CurrentUserCount update in Items, -1 if no new seats
+}
+
+// TransactionError converts a cancelled transaction caused by the seat counter condition into
+// ErrMemberLimitReached. offset is the index of the first reservation item in the transaction.
+func (r *SeatReservation) TransactionError(err error, offset int) error {
+	if err == nil || r == nil || r.counterIndex < 0 {
+		return err
+	}
+
+	var cancelled *types.TransactionCanceledException
+	if errors.As(err, &cancelled) {
+		index := offset + r.counterIndex
+		if index < len(cancelled.CancellationReasons) && aws.ToString(cancelled.CancellationReasons[index].Code) == "ConditionalCheckFailed" {
+			return fmt.Errorf("%w: seats were taken by another request, please retry", ErrMemberLimitReached)
+		}
+	}
+
+	return err
+}
+
+func seatSortKey(userName string) string {
+	return fmt.Sprintf("SEAT#%s", userName)
+}
+
+// memberLimitUnlimited reports whether a plan member limit should not be enforced. -1 is the
+// enterprise "unlimited" value; 0 is left by organizations created before limits were stored.
+func memberLimitUnlimited(maxMembers int) bool {
+	return maxMembers <= 0
+}
+
+// ReserveSeats checks the organization's member limit for the given people and returns the
+// transaction items that claim a seat for each person who does not already hold one. People who
+// already hold a seat cost nothing but their seat is condition-checked so a concurrent removal
+// cannot release it underneath the caller. Returns ErrMemberLimitReached when the plan does not
+// have enough free seats.
+func (svc *OrgServiceV2) ReserveSeats(organizationId string, userNames []string) (*SeatReservation, error) {
+	org, err := svc.GetOrganization(organizationId)
+	if err != nil {
+		return nil, err
+	}
+	if org, err = svc.ensureSeatsCounted(org); err != nil {
+		return nil, err
+	}
+	orgKey := orgPartitionKey(org.OrganizationId)
+
+	reservation := &SeatReservation{counterIndex: -1}
+	seen := make(map[string]bool)
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	for _, userName := range userNames {
+		userName = strings.TrimSpace(userName)
+		if userName == "" || seen[userName] {
+			continue
+		}
+		seen[userName] = true
+
+		hasSeat, err := svc.hasSeat(orgKey, userName)
+		if err != nil {
+			return nil, err
+		}
+
+		if hasSeat {
+			reservation.Items = append(reservation.Items, types.TransactWriteItem{
+				ConditionCheck: &types.ConditionCheck{
+					TableName: aws.String(svc.OrganizationTable),
+					Key: map[string]types.AttributeValue{
+						"PK": &types.AttributeValueMemberS{Value: orgKey},
+						"SK": &types.AttributeValueMemberS{Value: seatSortKey(userName)},
+					},
+					ConditionExpression: aws.String("attribute_exists(SK)"),
+				},
+			})
+			continue
+		}
+
+		seatItem, err := attributevalue.MarshalMap(OrgSeat{
+			PK:             orgKey,
+			SK:             seatSortKey(userName),
+			OrganizationId: org.OrganizationId,
+			UserName:       userName,
+			ClaimedAt:      now,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal seat: %w", err)
+		}
+
+		reservation.Items = append(reservation.Items, types.TransactWriteItem{
+			Put: &types.Put{
+				TableName:           aws.String(svc.OrganizationTable),
+				Item:                seatItem,
+				ConditionExpression: aws.String("attribute_not_exists(SK)"),
+			},
+		})
+		reservation.NewSeats = append(reservation.NewSeats, userName)
+	}
+
+	if len(reservation.NewSeats) == 0 {
+		return reservation, nil
+	}
+
+	requested := len(reservation.NewSeats)
+	if !memberLimitUnlimited(org.MaxMembersAllowed) && org.CurrentUserCount+requested > org.MaxMembersAllowed {
+		return nil, fmt.Errorf("%w: %d of %d seats used, %d requested", ErrMemberLimitReached, org.CurrentUserCount, org.MaxMembersAllowed, requested)
+	}
+
+	// The counter is only incremented while the plan limit is unchanged and the count leaves room
+	// for every new seat, so two concurrent reservations cannot both take the last seat. It is also
+	// only incremented once the seats have been counted, so a reservation cannot land between a
+	// backfill counting the SEAT# rows and storing the total.
+	condition := "attribute_exists(OrganizationId) AND attribute_exists(SeatsCountedAt) AND MaxMembersAllowed = :max"
+	values := map[string]types.AttributeValue{
+		":zero":      &types.AttributeValueMemberN{Value: "0"},
+		":inc":       &types.AttributeValueMemberN{Value: strconv.Itoa(requested)},
+		":max":       &types.AttributeValueMemberN{Value: strconv.Itoa(org.MaxMembersAllowed)},
+		":updatedAt": &types.AttributeValueMemberS{Value: now},
+	}
+	if !memberLimitUnlimited(org.MaxMembersAllowed) {
+		condition += " AND (attribute_not_exists(CurrentUserCount) OR CurrentUserCount <= :threshold)"
+		values[":threshold"] = &types.AttributeValueMemberN{Value: strconv.Itoa(org.MaxMembersAllowed - requested)}
+	}
+
+	reservation.counterIndex = len(reservation.Items)
+	reservation.Items = append(reservation.Items, types.TransactWriteItem{
+		Update: &types.Update{
+			TableName: aws.String(svc.OrganizationTable),
+			Key: map[string]types.AttributeValue{
+				"PK": &types.AttributeValueMemberS{Value: orgKey},
+				"SK": &types.AttributeValueMemberS{Value: "METADATA"},
+			},
+			UpdateExpression:          aws.String("SET CurrentUserCount = if_not_exists(CurrentUserCount, :zero) + :inc, UpdatedAt = :updatedAt"),
+			ConditionExpression:       aws.String(condition),
+			ExpressionAttributeValues: values,
+		},
+	})
+
+	return reservation, nil
+}
+
+// ClaimSeats reserves and immediately claims seats for the given people
+func (svc *OrgServiceV2) ClaimSeats(organizationId string, userNames []string) error {
+	reservation, err := svc.ReserveSeats(organizationId, userNames)
+	if err != nil {
+		return err
+	}
+	if len(reservation.NewSeats) == 0 {
+		return nil
+	}
+
+	_, err = svc.dynamodbClient.TransactWriteItems(svc.ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: reservation.Items,
+	})
+	if err != nil {
+		svc.logger.Printf("Failed to claim seats in organization %s: %v", organizationId, err)
+		return reservation.TransactionError(fmt.Errorf("failed to claim seats: %w", err), 0)
+	}
+
+	return nil
+}
+
+// seatReleaseItems returns the transaction items that free a person's seat, or nil if they do not
+// hold one. The caller is responsible for checking the person has no other membership that still
+// needs the seat.
+func (svc *OrgServiceV2) seatReleaseItems(orgKey, userName string) ([]types.TransactWriteItem, error) {
+	hasSeat, err := svc.hasSeat(orgKey, userName)
+	if err != nil || !hasSeat {
+		return nil, err
+	}
+
+	return []types.TransactWriteItem{
+		{
+			Delete: &types.Delete{
+				TableName: aws.String(svc.OrganizationTable),
+				Key: map[string]types.AttributeValue{
+					"PK": &types.AttributeValueMemberS{Value: orgKey},
+					"SK": &types.AttributeValueMemberS{Value: seatSortKey(userName)},
+				},
+				ConditionExpression: aws.String("attribute_exists(SK)"),
+			},
+		},
+		{
+			Update: &types.Update{
+				TableName: aws.String(svc.OrganizationTable),
+				Key: map[string]types.AttributeValue{
+					"PK": &types.AttributeValueMemberS{Value: orgKey},
+					"SK": &types.AttributeValueMemberS{Value: "METADATA"},
+				},
+				UpdateExpression:    aws.String("SET CurrentUserCount = CurrentUserCount - :dec, UpdatedAt = :updatedAt"),
+				ConditionExpression: aws.String("CurrentUserCount > :zero"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":dec":       &types.AttributeValueMemberN{Value: "1"},
+					":zero":      &types.AttributeValueMemberN{Value: "0"},
+					":updatedAt": &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)},
+				},
+			},
+		},
+	}, nil
+}
+
+func (svc *OrgServiceV2) hasSeat(orgKey, userName string) (bool, error) {
+	result, err := svc.dynamodbClient.GetItem(svc.ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(svc.OrganizationTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: orgKey},
+			"SK": &types.AttributeValueMemberS{Value: seatSortKey(userName)},
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to get seat: username %s, organizationId %s: %w", userName, orgKey, err)
+	}
+
+	return result.Item != nil, nil
+}
+
+// getOrgUser returns the USER# membership row for a person, or nil if there is none
+func (svc *OrgServiceV2) getOrgUser(orgKey, userName string) (*OrgUser, error) {
+	result, err := svc.dynamodbClient.GetItem(svc.ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(svc.OrganizationTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: orgKey},
+			"SK": &types.AttributeValueMemberS{Value: fmt.Sprintf("USER#%s", userName)},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get org user: username %s, organizationId %s: %w", userName, orgKey, err)
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var orgUser OrgUser
+	if err := attributevalue.UnmarshalMap(result.Item, &orgUser); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal org user: username %s, organizationId %s: %w", userName, orgKey, err)
+	}
+
+	return &orgUser, nil
+}
+
+// GetOrgUsage returns seats and teams used against the organization's plan limits
+func (svc *OrgServiceV2) GetOrgUsage(organizationId string) (*OrgUsage, error) {
+	org, err := svc.GetOrganization(organizationId)
+	if err != nil {
+		return nil, err
+	}
+	if org, err = svc.ensureSeatsCounted(org); err != nil {
+		return nil, err
+	}
+
+	usage := &OrgUsage{
+		OrganizationId: org.OrganizationId,
+		PlanID:         org.CurrentPlanID,
+		SeatsUsed:      org.CurrentUserCount,
+		SeatsAllowed:   org.MaxMembersAllowed,
+		SeatsAvailable: -1,
+		TeamsUsed:      org.CurrentTeamCount,
+		TeamsAllowed:   org.MaxTeamsAllowed,
+		TeamsAvailable: -1,
+	}
+
+	if !memberLimitUnlimited(org.MaxMembersAllowed) {
+		usage.SeatsAvailable = max(org.MaxMembersAllowed-org.CurrentUserCount, 0)
+		usage.SeatLimitHit = usage.SeatsAvailable == 0
+	}
+	if org.MaxTeamsAllowed != -1 {
+		usage.TeamsAvailable = max(org.MaxTeamsAllowed-org.CurrentTeamCount, 0)
+		usage.TeamLimitHit = usage.TeamsAvailable == 0
+	}
+
+	return usage, nil
+}
+
+// ensureSeatsCounted backfills seats for an organization created before seats were tracked and
+// returns the organization with its CurrentUserCount set. Every active admin, org user (invited or
+// joined) and active team member gets a SEAT# row, then the total is stored with SeatsCountedAt.
+// The backfill is idempotent, so concurrent callers may both run it.
+func (svc *OrgServiceV2) ensureSeatsCounted(org *Organization) (*Organization, error) {
+	if org.SeatsCountedAt != "" {
+		return org, nil
+	}
+	if svc.TeamsTable == "" {
+		return nil, fmt.Errorf("cannot count seats for organization %s: teams table is not configured", org.OrganizationId)
+	}
+
+	orgKey := orgPartitionKey(org.OrganizationId)
+	members, err := svc.seatHolders(orgKey)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, userName := range members {
+		seatItem, err := attributevalue.MarshalMap(OrgSeat{
+			PK:             orgKey,
+			SK:             seatSortKey(userName),
+			OrganizationId: org.OrganizationId,
+			UserName:       userName,
+			ClaimedAt:      now,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal seat: %w", err)
+		}
+
+		_, err = svc.dynamodbClient.PutItem(svc.ctx, &dynamodb.PutItemInput{
+			TableName:           aws.String(svc.OrganizationTable),
+			Item:                seatItem,
+			ConditionExpression: aws.String("attribute_not_exists(SK)"),
+		})
+		var conditionFailed *types.ConditionalCheckFailedException
+		if err != nil && !errors.As(err, &conditionFailed) {
+			return nil, fmt.Errorf("failed to backfill seat: username %s, organizationId %s: %w", userName, orgKey, err)
+		}
+	}
+
+	seatCount, err := svc.countSeats(orgKey)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = svc.dynamodbClient.UpdateItem(svc.ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(svc.OrganizationTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: orgKey},
+			"SK": &types.AttributeValueMemberS{Value: "METADATA"},
+		},
+		UpdateExpression:    aws.String("SET CurrentUserCount = :count, SeatsCountedAt = :now"),
+		ConditionExpression: aws.String("attribute_exists(OrganizationId) AND attribute_not_exists(SeatsCountedAt)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":count": &types.AttributeValueMemberN{Value: strconv.Itoa(seatCount)},
+			":now":   &types.AttributeValueMemberS{Value: now},
+		},
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if err != nil && !errors.As(err, &conditionFailed) {
+		return nil, fmt.Errorf("failed to store seat count: organizationId %s: %w", orgKey, err)
+	}
+	if err != nil {
+		// Another request finished the backfill first and its count is the one to use
+		return svc.GetOrganization(orgKey)
+	}
+
+	svc.logger.Printf("Backfilled %d seats for organization %s", seatCount, orgKey)
+	org.CurrentUserCount = seatCount
+	org.SeatsCountedAt = now
+	return org, nil
+}
+
+// seatHolders returns everyone who should hold a seat in the organization, sorted and without
+// duplicates
+func (svc *OrgServiceV2) seatHolders(orgKey string) ([]string, error) {
+	holders := make(map[string]bool)
+
+	var admins []OrgAdmin
+	if err := svc.queryAll(&dynamodb.QueryInput{
+		TableName:              aws.String(svc.OrganizationTable),
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk_prefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":        &types.AttributeValueMemberS{Value: orgKey},
+			":sk_prefix": &types.AttributeValueMemberS{Value: "ADMIN#"},
+		},
+	}, &admins); err != nil {
+		return nil, fmt.Errorf("failed to query org admins: %w", err)
+	}
+	for _, admin := range admins {
+		if admin.IsActive {
+			holders[admin.UserName] = true
+		}
+	}
+
+	// Invited users hold a seat from the moment they are invited
+	var users []OrgUser
+	if err := svc.queryAll(&dynamodb.QueryInput{
+		TableName:              aws.String(svc.OrganizationTable),
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk_prefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":        &types.AttributeValueMemberS{Value: orgKey},
+			":sk_prefix": &types.AttributeValueMemberS{Value: "USER#"},
+		},
+	}, &users); err != nil {
+		return nil, fmt.Errorf("failed to query org users: %w", err)
+	}
+	for _, user := range users {
+		holders[user.UserName] = true
+	}
+
+	var teams []TeamMetadata
+	if err := svc.queryAll(&dynamodb.QueryInput{
+		TableName:              aws.String(svc.TeamsTable),
+		IndexName:              aws.String("OrgId-Index"),
+		KeyConditionExpression: aws.String("OrgId = :orgId AND SK = :metadataSk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":orgId":      &types.AttributeValueMemberS{Value: orgKey},
+			":metadataSk": &types.AttributeValueMemberS{Value: "METADATA"},
+		},
+	}, &teams); err != nil {
+		return nil, fmt.Errorf("failed to query organization teams: %w", err)
+	}
+	for _, team := range teams {
+		var members []TeamMember
+		if err := svc.queryAll(&dynamodb.QueryInput{
+			TableName:              aws.String(svc.TeamsTable),
+			KeyConditionExpression: aws.String("PK = :teamId AND begins_with(SK, :userPrefix)"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":teamId":     &types.AttributeValueMemberS{Value: team.TeamId},
+				":userPrefix": &types.AttributeValueMemberS{Value: "USER#"},
+			},
+		}, &members); err != nil {
+			return nil, fmt.Errorf("failed to query team members: teamId %s: %w", team.TeamId, err)
+		}
+		for _, member := range members {
+			if member.IsActive {
+				holders[member.UserName] = true
+			}
+		}
+	}
+
+	userNames := make([]string, 0, len(holders))
+	for userName := range holders {
+		if userName != "" {
+			userNames = append(userNames, userName)
+		}
+	}
+	sort.Strings(userNames)
+	return userNames, nil
+}
+
+// countSeats returns the number of SEAT# rows in the organization
+func (svc *OrgServiceV2) countSeats(orgKey string) (int, error) {
+	queryInput := &dynamodb.QueryInput{
+		TableName:              aws.String(svc.OrganizationTable),
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk_prefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":        &types.AttributeValueMemberS{Value: orgKey},
+			":sk_prefix": &types.AttributeValueMemberS{Value: "SEAT#"},
+		},
+		Select: types.SelectCount,
+	}
+
+	count := 0
+	for {
+		result, err := svc.dynamodbClient.Query(svc.ctx, queryInput)
+		if err != nil {
+			return 0, fmt.Errorf("failed to count seats: organizationId %s: %w", orgKey, err)
+		}
+		count += int(result.Count)
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return count, nil
+		}
+		queryInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+// queryAll runs a query to the last page and unmarshals every item into out
+func (svc *OrgServiceV2) queryAll(queryInput *dynamodb.QueryInput, out interface{}) error {
+	var items []map[string]types.AttributeValue
+	for {
+		result, err := svc.dynamodbClient.Query(svc.ctx, queryInput)
+		if err != nil {
+			return err
+		}
+		items = append(items, result.Items...)
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		queryInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	return attributevalue.UnmarshalListOfMaps(items, out)
+}
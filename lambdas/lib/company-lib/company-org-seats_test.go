@@ -0,0 +1,253 @@
+package Companylib
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodb_types "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	awsclients "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/clients"
+	"github.com/stretchr/testify/assert"
+)
+
+func seatsOrgItem(currentUsers, maxMembers int) dynamodb.GetItemOutput {
+	item, _ := attributevalue.MarshalMap(Organization{
+		PK:                "ORG#org-1",
+		SK:                "METADATA",
+		OrganizationId:    "ORG#org-1",
+		CurrentPlanID:     "starter",
+		CurrentTeamCount:  2,
+		MaxTeamsAllowed:   5,
+		MaxMembersAllowed: maxMembers,
+		CurrentUserCount:  currentUsers,
+		SeatsCountedAt:    "2024-01-01T00:00:00Z",
+	})
+	return dynamodb.GetItemOutput{Item: item}
+}
+
+func seatItem(userName string) dynamodb.GetItemOutput {
+	item, _ := attributevalue.MarshalMap(OrgSeat{PK: "ORG#org-1", SK: seatSortKey(userName), OrganizationId: "ORG#org-1", UserName: userName})
+	return dynamodb.GetItemOutput{Item: item}
+}
+
+func seatsQueryItems(records ...interface{}) []map[string]dynamodb_types.AttributeValue {
+	items := make([]map[string]dynamodb_types.AttributeValue, 0, len(records))
+	for _, record := range records {
+		item, _ := attributevalue.MarshalMap(record)
+		items = append(items, item)
+	}
+	return items
+}
+
+func newSeatsOrgService(ddbClient *awsclients.MockDynamodbClient) *OrgServiceV2 {
+	return &OrgServiceV2{
+		ctx:               context.Background(),
+		dynamodbClient:    ddbClient,
+		logger:            log.New(&bytes.Buffer{}, "TEST:", 0),
+		OrganizationTable: "OrgTable-test",
+	}
+}
+
+func TestReserveSeats(t *testing.T) {
+	t.Run("It should claim a seat and guard the counter with the plan limit", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			GetItemOutputs: []dynamodb.GetItemOutput{seatsOrgItem(3, 25), {}, {}},
+			GetItemErrors:  []error{nil, nil, nil},
+		}
+		svc := newSeatsOrgService(&ddbClient)
+
+		reservation, err := svc.ReserveSeats("org-1", []string{"a@example.com", "b@example.com", "a@example.com"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"a@example.com", "b@example.com"}, reservation.NewSeats)
+		assert.Len(t, reservation.Items, 3)
+		assert.Equal(t, &dynamodb_types.AttributeValueMemberS{Value: "SEAT#a@example.com"}, reservation.Items[0].Put.Item["SK"])
+
+		counter := reservation.Items[2].Update
+		assert.Contains(t, *counter.ConditionExpression, "CurrentUserCount <= :threshold")
+		assert.Contains(t, *counter.ConditionExpression, "attribute_exists(SeatsCountedAt)")
+		assert.Equal(t, &dynamodb_types.AttributeValueMemberN{Value: "23"}, counter.ExpressionAttributeValues[":threshold"])
+		assert.Equal(t, &dynamodb_types.AttributeValueMemberN{Value: "2"}, counter.ExpressionAttributeValues[":inc"])
+	})
+
+	t.Run("It should reject new members once the plan is full", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			GetItemOutputs: []dynamodb.GetItemOutput{seatsOrgItem(25, 25), {}},
+			GetItemErrors:  []error{nil, nil},
+		}
+		svc := newSeatsOrgService(&ddbClient)
+
+		_, err := svc.ReserveSeats("org-1", []string{"new@example.com"})
+
+		assert.ErrorIs(t, err, ErrMemberLimitReached)
+	})
+
+	t.Run("It should not charge a seat to someone who already holds one", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			GetItemOutputs: []dynamodb.GetItemOutput{seatsOrgItem(25, 25), seatItem("member@example.com")},
+			GetItemErrors:  []error{nil, nil},
+		}
+		svc := newSeatsOrgService(&ddbClient)
+
+		reservation, err := svc.ReserveSeats("org-1", []string{"member@example.com"})
+
+		assert.NoError(t, err)
+		assert.Empty(t, reservation.NewSeats)
+		assert.Len(t, reservation.Items, 1)
+		assert.NotNil(t, reservation.Items[0].ConditionCheck)
+	})
+
+	t.Run("It should not limit unlimited plans", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			GetItemOutputs: []dynamodb.GetItemOutput{seatsOrgItem(500, -1), {}},
+			GetItemErrors:  []error{nil, nil},
+		}
+		svc := newSeatsOrgService(&ddbClient)
+
+		reservation, err := svc.ReserveSeats("org-1", []string{"new@example.com"})
+
+		assert.NoError(t, err)
+		assert.NotContains(t, *reservation.Items[1].Update.ConditionExpression, ":threshold")
+	})
+
+	t.Run("It should report a lost race for the last seat as the member limit", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			GetItemOutputs:           []dynamodb.GetItemOutput{seatsOrgItem(24, 25), {}},
+			GetItemErrors:            []error{nil, nil},
+			TransactWriteItemsOutput: []dynamodb.TransactWriteItemsOutput{{}},
+			TransactWriteItemsErrors: []error{&dynamodb_types.TransactionCanceledException{
+				CancellationReasons: []dynamodb_types.CancellationReason{
+					{Code: aws.String("None")},
+					{Code: aws.String("ConditionalCheckFailed")},
+				},
+			}},
+		}
+		svc := newSeatsOrgService(&ddbClient)
+
+		err := svc.ClaimSeats("org-1", []string{"new@example.com"})
+
+		assert.ErrorIs(t, err, ErrMemberLimitReached)
+	})
+}
+
+func TestRemoveOrgUserReleasesSeat(t *testing.T) {
+	t.Run("It should free the seat of a user who is not an admin", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			GetItemOutputs:           []dynamodb.GetItemOutput{{}, seatItem("member@example.com")},
+			GetItemErrors:            []error{nil, nil},
+			TransactWriteItemsOutput: []dynamodb.TransactWriteItemsOutput{{}},
+			TransactWriteItemsErrors: []error{nil},
+		}
+		svc := newSeatsOrgService(&ddbClient)
+
+		err := svc.RemoveOrgUser("ORG#org-1", "member@example.com")
+
+		assert.NoError(t, err)
+		items := ddbClient.TransactWriteItemsInputs[0].TransactItems
+		assert.Len(t, items, 3)
+		assert.Equal(t, &dynamodb_types.AttributeValueMemberS{Value: "ORG#org-1"}, items[0].Delete.Key["PK"])
+		assert.Equal(t, &dynamodb_types.AttributeValueMemberS{Value: "SEAT#member@example.com"}, items[1].Delete.Key["SK"])
+		assert.Equal(t, "SET CurrentUserCount = CurrentUserCount - :dec, UpdatedAt = :updatedAt", *items[2].Update.UpdateExpression)
+	})
+
+	t.Run("It should keep the seat of a user who is still an admin", func(t *testing.T) {
+		admin, _ := attributevalue.MarshalMap(OrgAdmin{UserName: "admin@example.com", IsActive: true})
+		ddbClient := awsclients.MockDynamodbClient{
+			GetItemOutputs:           []dynamodb.GetItemOutput{{Item: admin}},
+			GetItemErrors:            []error{nil},
+			TransactWriteItemsOutput: []dynamodb.TransactWriteItemsOutput{{}},
+			TransactWriteItemsErrors: []error{nil},
+		}
+		svc := newSeatsOrgService(&ddbClient)
+
+		err := svc.RemoveOrgUser("ORG#org-1", "admin@example.com")
+
+		assert.NoError(t, err)
+		assert.Len(t, ddbClient.TransactWriteItemsInputs[0].TransactItems, 1)
+	})
+
+	t.Run("It should not release a seat when the admin lookup fails", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			GetItemOutputs: []dynamodb.GetItemOutput{{}},
+			GetItemErrors:  []error{errors.New("throttled")},
+		}
+		svc := newSeatsOrgService(&ddbClient)
+
+		err := svc.RemoveOrgUser("ORG#org-1", "admin@example.com")
+
+		assert.Error(t, err)
+		assert.Empty(t, ddbClient.TransactWriteItemsInputs)
+	})
+}
+
+func TestGetOrgUsage(t *testing.T) {
+	t.Run("It should report seats and teams used against the plan", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			GetItemOutputs: []dynamodb.GetItemOutput{seatsOrgItem(25, 25)},
+			GetItemErrors:  []error{nil},
+		}
+		svc := newSeatsOrgService(&ddbClient)
+
+		usage, err := svc.GetOrgUsage("org-1")
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, usage.SeatsAvailable)
+		assert.True(t, usage.SeatLimitHit)
+		assert.Equal(t, 3, usage.TeamsAvailable)
+		assert.False(t, usage.TeamLimitHit)
+	})
+
+	t.Run("It should backfill seats for an organization created before seats were tracked", func(t *testing.T) {
+		legacyOrg, _ := attributevalue.MarshalMap(Organization{PK: "ORG#org-1", SK: "METADATA", OrganizationId: "ORG#org-1", MaxMembersAllowed: 10})
+		admins := seatsQueryItems(OrgAdmin{UserName: "admin@example.com", IsActive: true}, OrgAdmin{UserName: "former@example.com"})
+		users := seatsQueryItems(OrgUser{UserName: "admin@example.com", IsActive: true}, OrgUser{UserName: "invited@example.com"})
+		teams := seatsQueryItems(TeamMetadata{TeamId: "TEAM#t1", OrgId: "ORG#org-1"})
+		members := seatsQueryItems(TeamMember{UserName: "member@example.com", IsActive: true}, TeamMember{UserName: "left@example.com"})
+
+		ddbClient := awsclients.MockDynamodbClient{
+			GetItemOutputs:    []dynamodb.GetItemOutput{{Item: legacyOrg}},
+			GetItemErrors:     []error{nil},
+			QueryOutputs:      []dynamodb.QueryOutput{{Items: admins}, {Items: users}, {Items: teams}, {Items: members}, {Count: 3}},
+			QueryErrors:       []error{nil, nil, nil, nil, nil},
+			PutItemOutputs:    []dynamodb.PutItemOutput{{}, {}, {}},
+			PutItemErrors:     []error{nil, &dynamodb_types.ConditionalCheckFailedException{}, nil},
+			UpdateItemOutputs: []dynamodb.UpdateItemOutput{{}},
+			UpdateItemErrors:  []error{nil},
+		}
+		svc := newSeatsOrgService(&ddbClient)
+		svc.TeamsTable = "TeamsTable-test"
+
+		usage, err := svc.GetOrgUsage("org-1")
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, usage.SeatsUsed)
+		assert.Equal(t, 7, usage.SeatsAvailable)
+
+		var seated []string
+		for _, put := range ddbClient.PutItemInputs {
+			seated = append(seated, put.Item["UserName"].(*dynamodb_types.AttributeValueMemberS).Value)
+		}
+		assert.Equal(t, []string{"admin@example.com", "invited@example.com", "member@example.com"}, seated)
+		assert.Equal(t, "TeamsTable-test", *ddbClient.QueryInputs[3].TableName)
+		assert.Equal(t, &dynamodb_types.AttributeValueMemberN{Value: "3"}, ddbClient.UpdateItemInputs[0].ExpressionAttributeValues[":count"])
+		assert.Contains(t, *ddbClient.UpdateItemInputs[0].ConditionExpression, "attribute_not_exists(SeatsCountedAt)")
+	})
+
+	t.Run("It should not guess usage when seats cannot be backfilled", func(t *testing.T) {
+		legacyOrg, _ := attributevalue.MarshalMap(Organization{PK: "ORG#org-1", SK: "METADATA", OrganizationId: "ORG#org-1", MaxMembersAllowed: 10})
+		ddbClient := awsclients.MockDynamodbClient{
+			GetItemOutputs: []dynamodb.GetItemOutput{{Item: legacyOrg}},
+			GetItemErrors:  []error{nil},
+		}
+		svc := newSeatsOrgService(&ddbClient)
+
+		_, err := svc.GetOrgUsage("org-1")
+
+		assert.ErrorContains(t, err, "teams table is not configured")
+	})
+}
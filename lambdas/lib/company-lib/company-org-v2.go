@@ -2,6 +2,7 @@ package Companylib
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"strconv"
@@ -62,16 +63,35 @@ const (
 	OrgAdminRoleBillingOnly     OrgAdminRole = "BILLING_ONLY"     // Can only manage billing
 )
 
-// SubscriptionPlan represents available subscription plans
+// SubscriptionPlan represents a version of a plan in the subscription plan catalogue.
+// Versions are immutable once published: price or limit changes publish a new version and
+// organizations already on an older version keep it (grandfathered) until they change plan.
 type SubscriptionPlan struct {
-	PlanID          string   `json:"planId"`
-	PlanName        string   `json:"planName"`
-	PlanDescription string   `json:"planDescription"`
-	MaxTeams        int      `json:"maxTeams"`
-	MaxMembers      int      `json:"maxMembers"`
-	MonthlyPrice    float64  `json:"monthlyPrice"`
-	YearlyPrice     float64  `json:"yearlyPrice"`
-	Features        []string `json:"features"`
+	PK string `dynamodbav:"PK" json:"-"` // PLAN#{planId}
+	SK string `dynamodbav:"SK" json:"-"` // VERSION#{version}
+
+	PlanID          string                 `dynamodbav:"PlanID" json:"planId"`
+	Version         int                    `dynamodbav:"Version" json:"version"`
+	Status          SubscriptionPlanStatus `dynamodbav:"Status" json:"status"`
+	PlanName        string                 `dynamodbav:"PlanName" json:"planName"`
+	PlanDescription string                 `dynamodbav:"PlanDescription" json:"planDescription"`
+	MaxTeams        int                    `dynamodbav:"MaxTeams" json:"maxTeams"`
+	MaxMembers      int                    `dynamodbav:"MaxMembers" json:"maxMembers"`
+	DisplayOrder    int                    `dynamodbav:"DisplayOrder" json:"displayOrder"`
+
+	// Prices keyed by ISO 4217 currency code. MonthlyPrice/YearlyPrice are the prices in Currency
+	// and are filled in when the plan is read.
+	Prices       map[string]PlanPrice `dynamodbav:"Prices" json:"prices"`
+	Currency     string               `dynamodbav:"-" json:"currency"`
+	MonthlyPrice float64              `dynamodbav:"-" json:"monthlyPrice"`
+	YearlyPrice  float64              `dynamodbav:"-" json:"yearlyPrice"`
+
+	Features     []string        `dynamodbav:"Features" json:"features"`         // Display list
+	FeatureFlags map[string]bool `dynamodbav:"FeatureFlags" json:"featureFlags"` // Entitlements checked by the APIs
+
+	CreatedAt string `dynamodbav:"CreatedAt" json:"createdAt,omitempty"`
+	CreatedBy string `dynamodbav:"CreatedBy" json:"createdBy,omitempty"`
+	RetiredAt string `dynamodbav:"RetiredAt,omitempty" json:"retiredAt,omitempty"`
 }
 
 // Organization represents the enhanced organization structure
@@ -104,12 +124,14 @@ type Organization struct {
 	TaxID        string `dynamodbav:"TaxID" json:"taxId"`
 
 	// Billing and subscription info
-	BillingMode      BillingMode      `dynamodbav:"BillingMode" json:"billingMode"`
-	SubscriptionType SubscriptionType `dynamodbav:"SubscriptionType" json:"subscriptionType"`
-	BillingPlan      BillingPlan      `dynamodbav:"BillingPlan" json:"billingPlan"`
-	OrgBillingStatus OrgBillingStatus `dynamodbav:"OrgBillingStatus" json:"orgBillingStatus"`
-	CurrentPlanID    string           `dynamodbav:"CurrentPlanID" json:"currentPlanId"`
-	PlanType         string           `dynamodbav:"PlanType" json:"planType"` // For GSI
+	BillingMode        BillingMode      `dynamodbav:"BillingMode" json:"billingMode"`
+	SubscriptionType   SubscriptionType `dynamodbav:"SubscriptionType" json:"subscriptionType"`
+	BillingPlan        BillingPlan      `dynamodbav:"BillingPlan" json:"billingPlan"`
+	OrgBillingStatus   OrgBillingStatus `dynamodbav:"OrgBillingStatus" json:"orgBillingStatus"`
+	CurrentPlanID      string           `dynamodbav:"CurrentPlanID" json:"currentPlanId"`
+	CurrentPlanVersion int              `dynamodbav:"CurrentPlanVersion,omitempty" json:"currentPlanVersion,omitempty"` // Catalogue version the org is billed at
+	BillingCurrency    string           `dynamodbav:"BillingCurrency,omitempty" json:"billingCurrency,omitempty"`       // Defaults to DefaultBillingCurrency
+	PlanType           string           `dynamodbav:"PlanType" json:"planType"`                                         // For GSI
 
 	// Usage and limits
 	CurrentTeamCount  int `dynamodbav:"CurrentTeamCount" json:"currentTeamCount"`
@@ -119,6 +141,7 @@ type Organization struct {
 	// Promo and billing details
 	AppliedPromoCode     string  `dynamodbav:"AppliedPromoCode" json:"appliedPromoCode"`
 	PromoDiscountPercent float64 `dynamodbav:"PromoDiscountPercent" json:"promoDiscountPercent"`
+	PromoDiscountAmount  float64 `dynamodbav:"PromoDiscountAmount" json:"promoDiscountAmount"`
 	PromoValidUntil      string  `dynamodbav:"PromoValidUntil" json:"promoValidUntil"`
 
 	// Trial info
@@ -130,6 +153,15 @@ type Organization struct {
 	NextBillingDate  string `dynamodbav:"NextBillingDate" json:"nextBillingDate"`
 	LastPaymentDate  string `dynamodbav:"LastPaymentDate" json:"lastPaymentDate"`
 
+	// Invoicing
+	CreditBalance        float64 `dynamodbav:"CreditBalance" json:"creditBalance"`                                   // Credit carried to the next invoice
+	OutstandingInvoiceId string  `dynamodbav:"OutstandingInvoiceId,omitempty" json:"outstandingInvoiceId,omitempty"` // Unpaid invoice blocking renewal
+
+	// Trial expiry and dunning
+	TrialRemindersSent   []int  `dynamodbav:"TrialRemindersSent,omitempty" json:"-"`   // Reminder offsets (days before trial end) already emailed
+	DunningRemindersSent []int  `dynamodbav:"DunningRemindersSent,omitempty" json:"-"` // Reminder offsets (days before suspension) already emailed
+	SuspendedAt          string `dynamodbav:"SuspendedAt,omitempty" json:"suspendedAt,omitempty"`
+
 	// Timestamps
 	CreatedAt       string `dynamodbav:"CreatedAt" json:"createdAt"`
 	UpdatedAt       string `dynamodbav:"UpdatedAt" json:"updatedAt"`
@@ -137,6 +169,10 @@ type Organization struct {
 
 	// Current Total Users
 	CurrentUserCount int `dynamodbav:"CurrentUserCount" json:"currentUserCount"`
+
+	// SeatsCountedAt is when seats were first recorded for the organization's members. It is empty
+	// for organizations created before seats were tracked until their seats are backfilled.
+	SeatsCountedAt string `dynamodbav:"SeatsCountedAt,omitempty" json:"-"`
 }
 
 // OrgAdmin represents an organization administrator stored as separate table items
@@ -174,6 +210,13 @@ type OrgUser struct {
 	IsActive       bool         `dynamodbav:"IsActive" json:"isActive"`
 	Status         string       `dynamodbav:"Status" json:"status"` // INVITED, ACTIVE, SUSPENDED
 	UpdatedAt      string       `dynamodbav:"UpdatedAt" json:"updatedAt"`
+
+	// Invitation details, InvitationId is removed once the invitation is accepted
+	InvitationId     string `dynamodbav:"InvitationId,omitempty" json:"invitationId,omitempty"` // jti of the only token that can accept the invitation
+	InvitedBy        string `dynamodbav:"InvitedBy,omitempty" json:"invitedBy,omitempty"`
+	InvitedAt        string `dynamodbav:"InvitedAt,omitempty" json:"invitedAt,omitempty"`
+	InvitationTeamId string `dynamodbav:"InvitationTeamId,omitempty" json:"invitationTeamId,omitempty"`
+	AcceptedAt       string `dynamodbav:"AcceptedAt,omitempty" json:"acceptedAt,omitempty"`
 }
 
 // OrgMember represents a simplified view of organization members
@@ -244,6 +287,7 @@ type UpdateSubscriptionInput struct {
 	OrganizationId string      `json:"organizationId" validate:"required"`
 	PlanID         string      `json:"planId" validate:"required"`
 	BillingPlan    BillingPlan `json:"billingPlan" validate:"required"`
+	Currency       string      `json:"currency"` // Optional, defaults to the organization's billing currency
 }
 
 type ApplyPromoCodeInput struct {
@@ -272,6 +316,20 @@ type OrgServiceV2 struct {
 
 	OrganizationTable string
 	PromoCodesTable   string
+
+	// PaymentProvider charges subscription invoices. Defaults to manual settlement when nil.
+	PaymentProvider PaymentProvider
+
+	// PlanCatalog serves subscription plans. Defaults to the built-in plans when nil.
+	PlanCatalog *SubscriptionPlanService
+
+	// DefaultPlanID is the plan new organizations start their trial on. Defaults to
+	// DefaultTrialPlanID when empty.
+	DefaultPlanID string
+
+	// TeamsTable is read to find team members when seats are backfilled for an organization
+	// created before seats were tracked
+	TeamsTable string
 }
 
 // CreateOrgServiceV2 creates a new organization service
@@ -285,51 +343,60 @@ func CreateOrgServiceV2(ctx context.Context, ddbClient awsclients.DynamodbClient
 	}
 }
 
-// GetAvailableSubscriptionPlans returns all available subscription plans
-func (svc *OrgServiceV2) GetAvailableSubscriptionPlans() []SubscriptionPlan {
-	return []SubscriptionPlan{
-		{
-			PlanID:          "starter",
-			PlanName:        "Starter Plan",
-			PlanDescription: "Perfect for small teams just getting started",
-			MaxTeams:        5,
-			MaxMembers:      25,
-			MonthlyPrice:    29.99,
-			YearlyPrice:     299.99,
-			Features:        []string{"Basic team management", "Email support", "5 teams", "25 members"},
-		},
-		{
-			PlanID:          "professional",
-			PlanName:        "Professional Plan",
-			PlanDescription: "Great for growing organizations",
-			MaxTeams:        25,
-			MaxMembers:      150,
-			MonthlyPrice:    79.99,
-			YearlyPrice:     799.99,
-			Features:        []string{"Advanced team management", "Priority support", "25 teams", "150 members", "Analytics dashboard"},
-		},
-		{
-			PlanID:          "enterprise",
-			PlanName:        "Enterprise Plan",
-			PlanDescription: "For large organizations with advanced needs",
-			MaxTeams:        -1, // Unlimited
-			MaxMembers:      -1, // Unlimited
-			MonthlyPrice:    199.99,
-			YearlyPrice:     1999.99,
-			Features:        []string{"Unlimited teams", "Unlimited members", "24/7 support", "Custom integrations", "Advanced analytics"},
-		},
-	}
+// GetAvailableSubscriptionPlans returns the current version of every plan open to new subscriptions
+func (svc *OrgServiceV2) GetAvailableSubscriptionPlans() ([]SubscriptionPlan, error) {
+	return svc.planCatalog().ListPlans(false)
 }
 
-// GetSubscriptionPlanByID returns a specific subscription plan
+// GetSubscriptionPlanByID returns the current (active) version of a plan. Retired plans cannot be
+// subscribed to and are reported as not found.
 func (svc *OrgServiceV2) GetSubscriptionPlanByID(planID string) (*SubscriptionPlan, error) {
-	plans := svc.GetAvailableSubscriptionPlans()
-	for _, plan := range plans {
-		if plan.PlanID == planID {
-			return &plan, nil
-		}
+	return svc.planCatalog().GetCurrentPlan(planID)
+}
+
+// GetOrgSubscriptionPlan returns the plan version the organization is billed at, which may be a
+// retired version the organization has been grandfathered on
+func (svc *OrgServiceV2) GetOrgSubscriptionPlan(org Organization) (*SubscriptionPlan, error) {
+	return svc.planCatalog().GetPlanVersion(org.CurrentPlanID, org.CurrentPlanVersion)
+}
+
+// OrgHasFeature reports whether the organization's plan version enables the given feature flag
+func (svc *OrgServiceV2) OrgHasFeature(orgId string, feature string) (bool, error) {
+	org, err := svc.GetOrganization(orgId)
+	if err != nil {
+		return false, err
+	}
+
+	plan, err := svc.GetOrgSubscriptionPlan(*org)
+	if err != nil {
+		return false, err
+	}
+
+	return plan.HasFeature(feature), nil
+}
+
+// planCatalog returns the configured plan catalogue, falling back to the built-in default plans
+func (svc *OrgServiceV2) planCatalog() *SubscriptionPlanService {
+	if svc.PlanCatalog == nil {
+		svc.PlanCatalog = CreateSubscriptionPlanService(svc.ctx, svc.dynamodbClient, svc.logger)
+	}
+	return svc.PlanCatalog
+}
+
+// defaultPlan returns the current version of the plan new organizations start on. A configured
+// plan that is missing or retired is reported as ErrDefaultPlanUnavailable rather than not found,
+// since it is a deployment problem and not something the caller asked for.
+func (svc *OrgServiceV2) defaultPlan() (*SubscriptionPlan, error) {
+	planID := svc.DefaultPlanID
+	if planID == "" {
+		planID = DefaultTrialPlanID
+	}
+
+	plan, err := svc.GetSubscriptionPlanByID(planID)
+	if errors.Is(err, ErrSubscriptionPlanNotFound) {
+		return nil, fmt.Errorf("%w: plan %s has no active version, publish one or set DEFAULT_PLAN_ID to an active plan", ErrDefaultPlanUnavailable, planID)
 	}
-	return nil, fmt.Errorf("subscription plan not found: %s", planID)
+	return plan, err
 }
 
 // CreateOrganization creates a new organization with the creator as owner
@@ -341,8 +408,8 @@ func (svc *OrgServiceV2) CreateOrganization(input CreateOrganizationInput) (*Org
 	// Set default trial period (30 days)
 	trialEndDate := time.Now().UTC().AddDate(0, 0, 30).Format(time.RFC3339)
 
-	// Create organization with starter plan as default
-	starterPlan, err := svc.GetSubscriptionPlanByID("starter")
+	// Create organization on the default plan
+	trialPlan, err := svc.defaultPlan()
 	if err != nil {
 		return nil, err
 	}
@@ -372,17 +439,20 @@ func (svc *OrgServiceV2) CreateOrganization(input CreateOrganizationInput) (*Org
 		TaxID:        input.TaxID,
 
 		// Default billing settings
-		BillingMode:      BillingModeFree,
-		SubscriptionType: SubscriptionTypeTrial,
-		BillingPlan:      BillingPlanMonthly,
-		OrgBillingStatus: OrgBillingStatusTrial,
-		CurrentPlanID:    starterPlan.PlanID,
-		PlanType:         starterPlan.PlanID, // For GSI
-
-		// Usage limits from starter plan
+		BillingMode:        BillingModeFree,
+		SubscriptionType:   SubscriptionTypeTrial,
+		BillingPlan:        BillingPlanMonthly,
+		OrgBillingStatus:   OrgBillingStatusTrial,
+		CurrentPlanID:      trialPlan.PlanID,
+		CurrentPlanVersion: trialPlan.Version,
+		PlanType:           trialPlan.PlanID, // For GSI
+
+		// Usage limits from the default plan
 		CurrentTeamCount:  0,
-		MaxTeamsAllowed:   starterPlan.MaxTeams,
-		MaxMembersAllowed: starterPlan.MaxMembers,
+		MaxTeamsAllowed:   trialPlan.MaxTeams,
+		MaxMembersAllowed: trialPlan.MaxMembers,
+		CurrentUserCount:  1, // The creator's seat
+		SeatsCountedAt:    now,
 
 		// Trial settings
 		TrialStartDate: now,
@@ -436,7 +506,19 @@ func (svc *OrgServiceV2) CreateOrganization(input CreateOrganizationInput) (*Org
 		return nil, fmt.Errorf("failed to marshal admin: %w", err)
 	}
 
-	// Use TransactWriteItems to create both organization and admin atomically
+	seatItem, err := attributevalue.MarshalMap(OrgSeat{
+		PK:             orgId,
+		SK:             seatSortKey(input.CreatorUserName),
+		OrganizationId: orgId,
+		UserName:       input.CreatorUserName,
+		ClaimedAt:      now,
+	})
+	if err != nil {
+		svc.logger.Printf("Failed to marshal seat: %v", err)
+		return nil, fmt.Errorf("failed to marshal seat: %w", err)
+	}
+
+	// Use TransactWriteItems to create the organization, admin and admin's seat atomically
 	_, err = svc.dynamodbClient.TransactWriteItems(svc.ctx, &dynamodb.TransactWriteItemsInput{
 		TransactItems: []types.TransactWriteItem{
 			{
@@ -453,6 +535,13 @@ func (svc *OrgServiceV2) CreateOrganization(input CreateOrganizationInput) (*Org
 					ConditionExpression: aws.String("attribute_not_exists(PK) and attribute_not_exists(SK)"),
 				},
 			},
+			{
+				Put: &types.Put{
+					TableName:           aws.String(svc.OrganizationTable),
+					Item:                seatItem,
+					ConditionExpression: aws.String("attribute_not_exists(PK) and attribute_not_exists(SK)"),
+				},
+			},
 		},
 	})
 
@@ -547,6 +636,24 @@ func (svc *OrgServiceV2) IsOrgAdmin(organizationId string, userName string) (boo
 	return admin.IsActive, nil
 }
 
+// IsOrgMember checks if a user has an active membership row in an organization. Pending
+// invitations do not count.
+func (svc *OrgServiceV2) IsOrgMember(organizationId string, userName string) (bool, error) {
+	if !strings.HasPrefix(organizationId, "ORG#") {
+		organizationId = fmt.Sprintf("ORG#%s", organizationId)
+	}
+
+	orgUser, err := svc.getOrgUser(organizationId, userName)
+	if err != nil {
+		return false, err
+	}
+	if orgUser == nil {
+		return false, nil
+	}
+
+	return orgUser.IsActive && orgUser.Status != OrgUserStatusInvited, nil
+}
+
 // UpdateOrganization updates organization details (only org admins)
 func (svc *OrgServiceV2) UpdateOrganization(input UpdateOrganizationInput, requestingUser string) error {
 	// Verify requesting user is org admin
@@ -660,63 +767,169 @@ func (svc *OrgServiceV2) UpdateOrganization(input UpdateOrganizationInput, reque
 	return nil
 }
 
-// UpdateSubscription updates organization subscription plan (only org admins)
-func (svc *OrgServiceV2) UpdateSubscription(input UpdateSubscriptionInput, requestingUser string) error {
+// ErrSubscriptionChanged is returned when another request changed the organization's subscription
+// between reading it and applying a plan change.
+var ErrSubscriptionChanged = errors.New("subscription was changed by another request")
+
+// ErrInvoiceOutstanding is returned for a plan change while an earlier invoice is still unpaid. The
+// organization tracks one outstanding invoice for dunning, so it has to be settled first.
+var ErrInvoiceOutstanding = errors.New("an earlier invoice is still outstanding")
+
+// UpdateSubscription moves an organization onto a paid plan or changes its plan/billing frequency (only org admins).
+// An invoice is raised for the change - prorated against the unused part of the current period - and charged
+// through the configured payment provider. The plan change is conditional on the plan, version and billing
+// frequency it was priced against, so concurrent changes cannot both apply. The issued invoice is returned
+// once the change is committed, even if the payment outcome could not be recorded.
+func (svc *OrgServiceV2) UpdateSubscription(input UpdateSubscriptionInput, requestingUser string) (*Invoice, error) {
 	// Verify requesting user is org admin
 	isAdmin, err := svc.IsOrgAdmin(input.OrganizationId, requestingUser)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if !isAdmin {
-		return fmt.Errorf("user %s is not an admin of organization %s", requestingUser, input.OrganizationId)
+		return nil, fmt.Errorf("user %s is not an admin of organization %s", requestingUser, input.OrganizationId)
+	}
+
+	if input.BillingPlan != BillingPlanMonthly && input.BillingPlan != BillingPlanYearly {
+		return nil, fmt.Errorf("invalid billing plan: %s", input.BillingPlan)
 	}
 
 	// Validate plan exists
 	plan, err := svc.GetSubscriptionPlanByID(input.PlanID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	now := time.Now().UTC().Format(time.RFC3339)
+	org, err := svc.GetOrganization(input.OrganizationId)
+	if err != nil {
+		return nil, err
+	}
 
-	// Calculate next billing date based on billing plan
-	var nextBillingDate string
-	if input.BillingPlan == BillingPlanMonthly {
-		nextBillingDate = time.Now().UTC().AddDate(0, 1, 0).Format(time.RFC3339)
-	} else {
-		nextBillingDate = time.Now().UTC().AddDate(1, 0, 0).Format(time.RFC3339)
+	if org.OutstandingInvoiceId != "" {
+		return nil, fmt.Errorf("%w: invoice %s of organization %s must be paid before the plan can change", ErrInvoiceOutstanding, org.OutstandingInvoiceId, input.OrganizationId)
 	}
 
-	updateInput := &dynamodb.UpdateItemInput{
-		TableName: aws.String(svc.OrganizationTable),
-		Key: map[string]types.AttributeValue{
-			"PK": &types.AttributeValueMemberS{Value: fmt.Sprintf("ORG#%s", input.OrganizationId)},
-			"SK": &types.AttributeValueMemberS{Value: "METADATA"},
-		},
-		UpdateExpression: aws.String("SET CurrentPlanID = :planId, BillingPlan = :billingPlan, MaxTeamsAllowed = :maxTeams, MaxMembersAllowed = :maxMembers, NextBillingDate = :nextBillingDate, BillingMode = :billingMode, SubscriptionType = :subscriptionType, OrgBillingStatus = :billingStatus, PlanType = :planType, UpdatedAt = :updatedAt"),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":planId":           &types.AttributeValueMemberS{Value: input.PlanID},
-			":billingPlan":      &types.AttributeValueMemberS{Value: string(input.BillingPlan)},
-			":maxTeams":         &types.AttributeValueMemberN{Value: strconv.Itoa(plan.MaxTeams)},
-			":maxMembers":       &types.AttributeValueMemberN{Value: strconv.Itoa(plan.MaxMembers)},
-			":nextBillingDate":  &types.AttributeValueMemberS{Value: nextBillingDate},
-			":billingMode":      &types.AttributeValueMemberS{Value: string(BillingModePaid)},
-			":subscriptionType": &types.AttributeValueMemberS{Value: string(SubscriptionTypeSubscription)},
-			":billingStatus":    &types.AttributeValueMemberS{Value: string(OrgBillingStatusActive)},
-			":planType":         &types.AttributeValueMemberS{Value: input.PlanID}, // For GSI
-			":updatedAt":        &types.AttributeValueMemberS{Value: now},
-		},
-		ConditionExpression: aws.String("attribute_exists(OrganizationId)"),
+	currency := strings.ToUpper(strings.TrimSpace(input.Currency))
+	if currency == "" {
+		currency = orgBillingCurrency(*org)
+	}
+	if _, ok := plan.PriceFor(currency, input.BillingPlan); !ok {
+		return nil, fmt.Errorf("invalid currency: plan %s is not available in %s", plan.PlanID, currency)
 	}
 
-	_, err = svc.dynamodbClient.UpdateItem(svc.ctx, updateInput)
+	var currentPlan *SubscriptionPlan
+	if org.BillingMode == BillingModePaid && org.CurrentPlanID != "" {
+		if currency != orgBillingCurrency(*org) {
+			return nil, fmt.Errorf("invalid currency: billing currency cannot be changed from %s on an active subscription", orgBillingCurrency(*org))
+		}
+
+		// A grandfathered organization may move onto the current version of its plan
+		if org.CurrentPlanID == input.PlanID && org.BillingPlan == input.BillingPlan && effectivePlanVersion(org.CurrentPlanVersion) == plan.Version {
+			return nil, fmt.Errorf("organization %s is already subscribed to plan %s (%s)", input.OrganizationId, input.PlanID, input.BillingPlan)
+		}
+
+		currentPlan, err = svc.GetOrgSubscriptionPlan(*org)
+		if err != nil {
+			svc.logger.Printf("Failed to load current plan %s v%d for organization %s, billing without proration credit: %v", org.CurrentPlanID, org.CurrentPlanVersion, input.OrganizationId, err)
+		}
+	}
+	org.BillingCurrency = currency
+
+	invoiceType := InvoiceTypeSubscription
+	if currentPlan != nil {
+		invoiceType = InvoiceTypePlanChange
+	}
+
+	nowTime := time.Now().UTC()
+	now := nowTime.Format(time.RFC3339)
+
+	lineItems, periodStart, periodEnd := BuildPlanChangeLineItems(*org, currentPlan, plan, input.BillingPlan, nowTime)
+	invoice := BuildInvoice(*org, invoiceType, plan.PlanID, input.BillingPlan, periodStart, periodEnd, lineItems, nowTime)
+
+	invoiceItem, err := attributevalue.MarshalMap(invoice)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal invoice: %w", err)
+	}
+
+	// A plan change within the same billing frequency keeps the current billing date
+	nextBillingDate := periodEnd.Format(time.RFC3339)
+
+	orgValues := map[string]types.AttributeValue{
+		":planId":              &types.AttributeValueMemberS{Value: input.PlanID},
+		":planVersion":         &types.AttributeValueMemberN{Value: strconv.Itoa(plan.Version)},
+		":currency":            &types.AttributeValueMemberS{Value: currency},
+		":billingPlan":         &types.AttributeValueMemberS{Value: string(input.BillingPlan)},
+		":maxTeams":            &types.AttributeValueMemberN{Value: strconv.Itoa(plan.MaxTeams)},
+		":maxMembers":          &types.AttributeValueMemberN{Value: strconv.Itoa(plan.MaxMembers)},
+		":nextBillingDate":     &types.AttributeValueMemberS{Value: nextBillingDate},
+		":billingMode":         &types.AttributeValueMemberS{Value: string(BillingModePaid)},
+		":subscriptionType":    &types.AttributeValueMemberS{Value: string(SubscriptionTypeSubscription)},
+		":planType":            &types.AttributeValueMemberS{Value: input.PlanID}, // For GSI
+		":creditBalance":       &types.AttributeValueMemberN{Value: fmt.Sprintf("%.2f", invoice.CreditIssued)},
+		":invoiceId":           &types.AttributeValueMemberS{Value: invoice.InvoiceId},
+		":updatedAt":           &types.AttributeValueMemberS{Value: now},
+		":expectedPlanId":      &types.AttributeValueMemberS{Value: org.CurrentPlanID},
+		":expectedBillingPlan": &types.AttributeValueMemberS{Value: string(org.BillingPlan)},
+	}
+
+	// The change only applies to the subscription it was priced against. Stored plan fields may be
+	// missing on organizations created before they existed.
+	currentPlanCondition := "attribute_not_exists(OutstandingInvoiceId) AND (attribute_not_exists(CurrentPlanID) OR CurrentPlanID = :expectedPlanId) AND (attribute_not_exists(BillingPlan) OR BillingPlan = :expectedBillingPlan)"
+	if org.CurrentPlanVersion == 0 {
+		currentPlanCondition += " AND attribute_not_exists(CurrentPlanVersion)"
+	} else {
+		currentPlanCondition += " AND CurrentPlanVersion = :expectedPlanVersion"
+		orgValues[":expectedPlanVersion"] = &types.AttributeValueMemberN{Value: strconv.Itoa(org.CurrentPlanVersion)}
+	}
+
+	_, err = svc.dynamodbClient.TransactWriteItems(svc.ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Put: &types.Put{
+					TableName:           aws.String(svc.OrganizationTable),
+					Item:                invoiceItem,
+					ConditionExpression: aws.String("attribute_not_exists(PK) AND attribute_not_exists(SK)"),
+				},
+			},
+			{
+				Update: &types.Update{
+					TableName: aws.String(svc.OrganizationTable),
+					Key: map[string]types.AttributeValue{
+						"PK": &types.AttributeValueMemberS{Value: orgPartitionKey(input.OrganizationId)},
+						"SK": &types.AttributeValueMemberS{Value: "METADATA"},
+					},
+					UpdateExpression:          aws.String("SET CurrentPlanID = :planId, CurrentPlanVersion = :planVersion, BillingCurrency = :currency, BillingPlan = :billingPlan, MaxTeamsAllowed = :maxTeams, MaxMembersAllowed = :maxMembers, NextBillingDate = :nextBillingDate, BillingMode = :billingMode, SubscriptionType = :subscriptionType, PlanType = :planType, CreditBalance = :creditBalance, OutstandingInvoiceId = :invoiceId, UpdatedAt = :updatedAt"),
+					ExpressionAttributeValues: orgValues,
+					ConditionExpression:       aws.String("attribute_exists(OrganizationId) AND " + currentPlanCondition),
+				},
+			},
+		},
+	})
 	if err != nil {
+		var cancelled *types.TransactionCanceledException
+		if errors.As(err, &cancelled) && len(cancelled.CancellationReasons) > 1 && aws.ToString(cancelled.CancellationReasons[1].Code) == "ConditionalCheckFailed" {
+			return nil, fmt.Errorf("%w: organization %s, please retry", ErrSubscriptionChanged, input.OrganizationId)
+		}
 		svc.logger.Printf("Failed to update subscription: %v", err)
-		return fmt.Errorf("failed to update subscription: %w", err)
+		return nil, fmt.Errorf("failed to update subscription: %w", err)
 	}
 
-	svc.logger.Printf("Successfully updated subscription for organization %s to plan %s", input.OrganizationId, input.PlanID)
-	return nil
+	org.CurrentPlanID = input.PlanID
+	org.CurrentPlanVersion = plan.Version
+	org.BillingPlan = input.BillingPlan
+	org.BillingMode = BillingModePaid
+	org.NextBillingDate = nextBillingDate
+
+	// The new plan is live from here on, with the invoice recorded as outstanding until its payment
+	// is. A failure to record the payment leaves the invoice for dunning to collect, so it is logged
+	// rather than reported as a failed plan change.
+	payment := svc.chargeInvoice(*org, invoice)
+	if _, err := svc.recordPaymentOutcome(*org, &invoice, payment, nowTime); err != nil {
+		svc.logger.Printf("Failed to record payment %s for invoice %s of organization %s: %v", payment.Status, invoice.InvoiceId, input.OrganizationId, err)
+	}
+
+	svc.logger.Printf("Successfully updated subscription for organization %s to plan %s (invoice %s, payment %s)", input.OrganizationId, input.PlanID, invoice.InvoiceId, payment.Status)
+	return &invoice, nil
 }
 
 // CanCreateTeam checks if organization can create more teams based on their plan
@@ -921,10 +1134,11 @@ func (svc *OrgServiceV2) ApplyPromoCode(input ApplyPromoCodeInput, requestingUse
 					"PK": &types.AttributeValueMemberS{Value: fmt.Sprintf("ORG#%s", input.OrganizationId)},
 					"SK": &types.AttributeValueMemberS{Value: "METADATA"},
 				},
-				UpdateExpression: aws.String("SET AppliedPromoCode = :promoCode, PromoDiscountPercent = :discountPercent, PromoValidUntil = :promoValidUntil, UpdatedAt = :updatedAt"),
+				UpdateExpression: aws.String("SET AppliedPromoCode = :promoCode, PromoDiscountPercent = :discountPercent, PromoDiscountAmount = :discountAmount, PromoValidUntil = :promoValidUntil, UpdatedAt = :updatedAt"),
 				ExpressionAttributeValues: map[string]types.AttributeValue{
 					":promoCode":       &types.AttributeValueMemberS{Value: input.PromoCode},
 					":discountPercent": &types.AttributeValueMemberN{Value: fmt.Sprintf("%.2f", promoCode.DiscountPercent)},
+					":discountAmount":  &types.AttributeValueMemberN{Value: fmt.Sprintf("%.2f", promoCode.DiscountAmount)},
 					":promoValidUntil": &types.AttributeValueMemberS{Value: promoValidUntil},
 					":updatedAt":       &types.AttributeValueMemberS{Value: nowStr},
 				},
@@ -948,7 +1162,8 @@ func (svc *OrgServiceV2) ApplyPromoCode(input ApplyPromoCodeInput, requestingUse
 
 	// Add trial extension if applicable
 	if trialEndDate != "" {
-		transactItems[0].Update.UpdateExpression = aws.String("SET AppliedPromoCode = :promoCode, PromoDiscountPercent = :discountPercent, PromoValidUntil = :promoValidUntil, TrialEndDate = :trialEndDate, OrgBillingStatus = :trialStatus, UpdatedAt = :updatedAt")
+		// Reminders restart for the extended trial
+		transactItems[0].Update.UpdateExpression = aws.String("SET AppliedPromoCode = :promoCode, PromoDiscountPercent = :discountPercent, PromoDiscountAmount = :discountAmount, PromoValidUntil = :promoValidUntil, TrialEndDate = :trialEndDate, OrgBillingStatus = :trialStatus, UpdatedAt = :updatedAt REMOVE TrialRemindersSent")
 		transactItems[0].Update.ExpressionAttributeValues[":trialEndDate"] = &types.AttributeValueMemberS{Value: trialEndDate}
 		transactItems[0].Update.ExpressionAttributeValues[":trialStatus"] = &types.AttributeValueMemberS{Value: string(OrgBillingStatusTrial)}
 	}
@@ -1033,15 +1248,25 @@ func (svc *OrgServiceV2) AddOrgAdmin(organizationId string, newAdminUserName str
 		return fmt.Errorf("failed to marshal admin: %w", err)
 	}
 
-	_, err = svc.dynamodbClient.PutItem(svc.ctx, &dynamodb.PutItemInput{
-		TableName:           aws.String(svc.OrganizationTable),
-		Item:                adminItem,
-		ConditionExpression: aws.String("attribute_not_exists(PK)"),
+	// Admins take a plan seat unless they already hold one as an org or team member
+	reservation, err := svc.ReserveSeats(organizationId, []string{newAdminUserName})
+	if err != nil {
+		return err
+	}
+
+	_, err = svc.dynamodbClient.TransactWriteItems(svc.ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: append(reservation.Items, types.TransactWriteItem{
+			Put: &types.Put{
+				TableName:           aws.String(svc.OrganizationTable),
+				Item:                adminItem,
+				ConditionExpression: aws.String("attribute_not_exists(PK)"),
+			},
+		}),
 	})
 
 	if err != nil {
 		svc.logger.Printf("Failed to add org admin: %v", err)
-		return fmt.Errorf("failed to add org admin: %w", err)
+		return reservation.TransactionError(fmt.Errorf("failed to add org admin: %w", err), 0)
 	}
 
 	svc.logger.Printf("Successfully added admin %s to organization %s", newAdminUserName, organizationId)
@@ -1091,21 +1316,41 @@ func (svc *OrgServiceV2) RemoveOrgAdmin(organizationId string, adminUserName str
 
 	// Deactivate the admin
 	now := time.Now().UTC().Format(time.RFC3339)
-	updateInput := &dynamodb.UpdateItemInput{
-		TableName: aws.String(svc.OrganizationTable),
-		Key: map[string]types.AttributeValue{
-			"PK": &types.AttributeValueMemberS{Value: fmt.Sprintf("ORG#%s", organizationId)},
-			"SK": &types.AttributeValueMemberS{Value: fmt.Sprintf("ADMIN#%s", adminUserName)},
-		},
-		UpdateExpression: aws.String("SET IsActive = :inactive, UpdatedAt = :updatedAt"),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":inactive":  &types.AttributeValueMemberBOOL{Value: false},
-			":updatedAt": &types.AttributeValueMemberS{Value: now},
+	orgKey := orgPartitionKey(organizationId)
+	transactItems := []types.TransactWriteItem{
+		{
+			Update: &types.Update{
+				TableName: aws.String(svc.OrganizationTable),
+				Key: map[string]types.AttributeValue{
+					"PK": &types.AttributeValueMemberS{Value: orgKey},
+					"SK": &types.AttributeValueMemberS{Value: fmt.Sprintf("ADMIN#%s", adminUserName)},
+				},
+				UpdateExpression: aws.String("SET IsActive = :inactive, UpdatedAt = :updatedAt"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":inactive":  &types.AttributeValueMemberBOOL{Value: false},
+					":updatedAt": &types.AttributeValueMemberS{Value: now},
+				},
+				ConditionExpression: aws.String("attribute_exists(PK)"),
+			},
 		},
-		ConditionExpression: aws.String("attribute_exists(PK)"),
 	}
 
-	_, err = svc.dynamodbClient.UpdateItem(svc.ctx, updateInput)
+	// Free the seat unless the person is still an org user
+	orgUser, err := svc.getOrgUser(orgKey, adminUserName)
+	if err != nil {
+		return err
+	}
+	if orgUser == nil {
+		releaseItems, err := svc.seatReleaseItems(orgKey, adminUserName)
+		if err != nil {
+			return err
+		}
+		transactItems = append(transactItems, releaseItems...)
+	}
+
+	_, err = svc.dynamodbClient.TransactWriteItems(svc.ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: transactItems,
+	})
 	if err != nil {
 		svc.logger.Printf("Failed to remove org admin: %v", err)
 		return fmt.Errorf("failed to remove org admin: %w", err)
@@ -1166,41 +1411,71 @@ func (svc *OrgServiceV2) GetOrgAdmins(organizationId string, requestingUser stri
 func (svc *OrgServiceV2) reactivateAdmin(organizationId string, adminUserName string, newRole OrgAdminRole) error {
 	now := time.Now().UTC().Format(time.RFC3339)
 
-	updateInput := &dynamodb.UpdateItemInput{
-		TableName: aws.String(svc.OrganizationTable),
-		Key: map[string]types.AttributeValue{
-			"PK": &types.AttributeValueMemberS{Value: fmt.Sprintf("ORG#%s", organizationId)},
-			"SK": &types.AttributeValueMemberS{Value: fmt.Sprintf("ADMIN#%s", adminUserName)},
-		},
-		UpdateExpression: aws.String("SET IsActive = :active, #role = :role, AddedAt = :addedAt, UpdatedAt = :updatedAt"),
-		ExpressionAttributeNames: map[string]string{
-			"#role": "Role",
-		},
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":active":    &types.AttributeValueMemberBOOL{Value: true},
-			":role":      &types.AttributeValueMemberS{Value: string(newRole)},
-			":addedAt":   &types.AttributeValueMemberS{Value: now},
-			":updatedAt": &types.AttributeValueMemberS{Value: now},
-		},
-		ConditionExpression: aws.String("attribute_exists(PK)"),
+	// A reactivated admin takes their seat back
+	reservation, err := svc.ReserveSeats(organizationId, []string{adminUserName})
+	if err != nil {
+		return err
 	}
 
-	_, err := svc.dynamodbClient.UpdateItem(svc.ctx, updateInput)
+	_, err = svc.dynamodbClient.TransactWriteItems(svc.ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: append(reservation.Items, types.TransactWriteItem{
+			Update: &types.Update{
+				TableName: aws.String(svc.OrganizationTable),
+				Key: map[string]types.AttributeValue{
+					"PK": &types.AttributeValueMemberS{Value: orgPartitionKey(organizationId)},
+					"SK": &types.AttributeValueMemberS{Value: fmt.Sprintf("ADMIN#%s", adminUserName)},
+				},
+				UpdateExpression: aws.String("SET IsActive = :active, #role = :role, AddedAt = :addedAt, UpdatedAt = :updatedAt"),
+				ExpressionAttributeNames: map[string]string{
+					"#role": "Role",
+				},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":active":    &types.AttributeValueMemberBOOL{Value: true},
+					":role":      &types.AttributeValueMemberS{Value: string(newRole)},
+					":addedAt":   &types.AttributeValueMemberS{Value: now},
+					":updatedAt": &types.AttributeValueMemberS{Value: now},
+				},
+				ConditionExpression: aws.String("attribute_exists(PK)"),
+			},
+		}),
+	})
 	if err != nil {
-		return fmt.Errorf("failed to reactivate admin: %w", err)
+		return reservation.TransactionError(fmt.Errorf("failed to reactivate admin: %w", err), 0)
 	}
 
 	return nil
 }
 
-// RemoveOrgUser removes a user from an organization
+// RemoveOrgUser removes a user from an organization and frees their seat unless they are still an
+// active admin
 func (svc *OrgServiceV2) RemoveOrgUser(organizationId, userName string) error {
-	_, err := svc.dynamodbClient.DeleteItem(svc.ctx, &dynamodb.DeleteItemInput{
-		TableName: aws.String(svc.OrganizationTable),
-		Key: map[string]types.AttributeValue{
-			"PK": &types.AttributeValueMemberS{Value: fmt.Sprintf("ORG#%s", organizationId)},
-			"SK": &types.AttributeValueMemberS{Value: fmt.Sprintf("USER#%s", userName)},
+	orgKey := orgPartitionKey(organizationId)
+	transactItems := []types.TransactWriteItem{
+		{
+			Delete: &types.Delete{
+				TableName: aws.String(svc.OrganizationTable),
+				Key: map[string]types.AttributeValue{
+					"PK": &types.AttributeValueMemberS{Value: orgKey},
+					"SK": &types.AttributeValueMemberS{Value: fmt.Sprintf("USER#%s", userName)},
+				},
+			},
 		},
+	}
+
+	isAdmin, err := svc.IsOrgAdmin(orgKey, userName)
+	if err != nil {
+		return err
+	}
+	if !isAdmin {
+		releaseItems, err := svc.seatReleaseItems(orgKey, userName)
+		if err != nil {
+			return err
+		}
+		transactItems = append(transactItems, releaseItems...)
+	}
+
+	_, err = svc.dynamodbClient.TransactWriteItems(svc.ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: transactItems,
 	})
 
 	if err != nil {
@@ -0,0 +1,316 @@
+package Companylib
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Cloning a cycle and applying a template run as copy jobs. The job first works out every record
+// it will write and stores them in COPY_JOB_CHUNK records of copyJobChunkSize. Each chunk is then
+// written in one transaction together with the job's progress, conditioned on the chunk the job
+// expects to write next, so a failed or interrupted job resumes at the first unwritten chunk and
+// no chunk is ever written twice. The chunks are removed once the job completes.
+
+const (
+	perfEntityCopyJob      = "COPY_JOB"
+	perfEntityCopyJobChunk = "COPY_JOB_CHUNK"
+
+	CopyJobTypeCloneCycle    = "CLONE_CYCLE"
+	CopyJobTypeApplyTemplate = "APPLY_TEMPLATE"
+
+	CopyJobStatusRunning   = "RUNNING"
+	CopyJobStatusCompleted = "COMPLETED"
+	CopyJobStatusFailed    = "FAILED"
+
+	copyJobChunkSize = 25
+)
+
+var (
+	ErrCopyJobInvalid  = errors.New("invalid copy request")
+	ErrCopyJobNotFound = errors.New("copy job not found")
+	ErrCopyJobConflict = errors.New("copy job has moved on")
+)
+
+// CopyCounts is how many records of each kind a copy job writes
+type CopyCounts struct {
+	Quarters   int `json:"quarters"`
+	KPIs       int `json:"kpis"`
+	OKRs       int `json:"okrs"`
+	KeyResults int `json:"keyResults"`
+}
+
+func (c CopyCounts) toData() map[string]interface{} {
+	return map[string]interface{}{
+		"quarters":   c.Quarters,
+		"kpis":       c.KPIs,
+		"okrs":       c.OKRs,
+		"keyResults": c.KeyResults,
+	}
+}
+
+// copyJobChunk holds the records one transaction of a copy job writes
+type copyJobChunk struct {
+	PK             string              `dynamodbav:"PK"`
+	SK             string              `dynamodbav:"SK"`
+	EntityType     string              `dynamodbav:"EntityType"`
+	OrganizationId string              `dynamodbav:"OrganizationId"`
+	ParentId       string              `dynamodbav:"ParentId"`
+	CreatedAt      string              `dynamodbav:"CreatedAt"`
+	Records        []PerformanceRecord `dynamodbav:"Records"`
+}
+
+func copyJobChunkSK(jobID string, index int) string {
+	return fmt.Sprintf("%sJOB#%s#CHUNK#%04d", perfSKPrefix, jobID, index)
+}
+
+// startCopyJob stores the records in chunks, records the job and runs it. details describe the
+// job's source and target and are kept on the job record.
+func (svc *PerformanceService) startCopyJob(orgID string, jobType string, details map[string]interface{}, records []PerformanceRecord, counts CopyCounts, createdBy string) (map[string]interface{}, error) {
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%w: there is nothing to copy", ErrCopyJobInvalid)
+	}
+	jobID := svc.generateID("copy-job")
+	now := svc.now()
+
+	totalChunks := 0
+	for start := 0; start < len(records); start += copyJobChunkSize {
+		end := start + copyJobChunkSize
+		if end > len(records) {
+			end = len(records)
+		}
+		item, err := attributevalue.MarshalMap(copyJobChunk{
+			PK:             orgID,
+			SK:             copyJobChunkSK(jobID, totalChunks),
+			EntityType:     perfEntityCopyJobChunk,
+			OrganizationId: orgID,
+			ParentId:       jobID,
+			CreatedAt:      now,
+			Records:        records[start:end],
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal copy job chunk: %w", err)
+		}
+		if _, err := svc.dynamodbClient.PutItem(svc.ctx, &dynamodb.PutItemInput{
+			TableName: aws.String(svc.performanceTableName()),
+			Item:      item,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to store copy job chunk: %w", err)
+		}
+		totalChunks++
+	}
+
+	data := map[string]interface{}{
+		"id":           jobID,
+		"type":         jobType,
+		"status":       CopyJobStatusRunning,
+		"totalItems":   len(records),
+		"totalChunks":  totalChunks,
+		"nextChunk":    0,
+		"writtenItems": 0,
+		"counts":       counts.toData(),
+		"createdBy":    createdBy,
+		"createdAt":    now,
+		"updatedAt":    now,
+	}
+	for k, v := range details {
+		data[k] = v
+	}
+	job := PerformanceRecord{
+		PK:             orgID,
+		SK:             fmt.Sprintf("%sJOB#%s", perfSKPrefix, jobID),
+		GSI1PK:         fmt.Sprintf("%sJOB#%s", perfSKPrefix, jobID),
+		GSI1SK:         orgID,
+		EntityType:     perfEntityCopyJob,
+		OrganizationId: orgID,
+		Owner:          createdBy,
+		Status:         CopyJobStatusRunning,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		Data:           data,
+	}
+	if err := svc.putRecord(job); err != nil {
+		return nil, err
+	}
+	return svc.runCopyJob(&job)
+}
+
+// GetCopyJob returns a copy job by ID
+func (svc *PerformanceService) GetCopyJob(jobID string) (map[string]interface{}, error) {
+	job, err := svc.getCopyJobRecord(jobID)
+	if err != nil {
+		return nil, err
+	}
+	return svc.toPayload(job), nil
+}
+
+func (svc *PerformanceService) getCopyJobRecord(jobID string) (*PerformanceRecord, error) {
+	job, err := svc.getRecordByGSI1(perfSKPrefix + "JOB#" + jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil || job.EntityType != perfEntityCopyJob {
+		return nil, ErrCopyJobNotFound
+	}
+	return job, nil
+}
+
+// ResumeCopyJob continues a failed or interrupted copy job from its first unwritten chunk
+func (svc *PerformanceService) ResumeCopyJob(jobID string) (map[string]interface{}, error) {
+	job, err := svc.getCopyJobRecord(jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status == CopyJobStatusCompleted {
+		return nil, fmt.Errorf("%w: copy job %s has already completed", ErrCopyJobConflict, jobID)
+	}
+	return svc.runCopyJob(job)
+}
+
+// runCopyJob writes the job's remaining chunks. A failure is recorded on the job, which keeps its
+// place so it can be resumed.
+func (svc *PerformanceService) runCopyJob(job *PerformanceRecord) (map[string]interface{}, error) {
+	jobID := toString(job.Data["id"])
+	total := toInt(job.Data["totalChunks"])
+
+	for next := toInt(job.Data["nextChunk"]); next < total; next++ {
+		if err := svc.writeCopyJobChunk(job, next, total); err != nil {
+			if !errors.Is(err, ErrCopyJobConflict) {
+				svc.failCopyJob(job, next, err)
+			}
+			return svc.toPayload(job), fmt.Errorf("copy job %s stopped at chunk %d of %d: %w", jobID, next+1, total, err)
+		}
+	}
+
+	for i := 0; i < total; i++ {
+		if err := svc.deleteRecord(&PerformanceRecord{PK: job.PK, SK: copyJobChunkSK(jobID, i)}); err != nil {
+			svc.logger.Printf("failed to remove chunk %d of copy job %s: %v", i, jobID, err)
+		}
+	}
+	return svc.toPayload(job), nil
+}
+
+// writeCopyJobChunk writes one chunk and moves the job past it in the same transaction
+func (svc *PerformanceService) writeCopyJobChunk(job *PerformanceRecord, index int, total int) error {
+	out, err := svc.dynamodbClient.GetItem(svc.ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(svc.performanceTableName()),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: job.PK},
+			"SK": &types.AttributeValueMemberS{Value: copyJobChunkSK(toString(job.Data["id"]), index)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read chunk: %w", err)
+	}
+	if out.Item == nil {
+		return fmt.Errorf("chunk %d is missing", index)
+	}
+	var chunk copyJobChunk
+	if err := attributevalue.UnmarshalMap(out.Item, &chunk); err != nil {
+		return fmt.Errorf("failed to unmarshal chunk: %w", err)
+	}
+
+	items := make([]types.TransactWriteItem, 0, len(chunk.Records)+1)
+	for _, record := range chunk.Records {
+		item, err := attributevalue.MarshalMap(record)
+		if err != nil {
+			return fmt.Errorf("failed to marshal record: %w", err)
+		}
+		items = append(items, types.TransactWriteItem{Put: &types.Put{
+			TableName: aws.String(svc.performanceTableName()),
+			Item:      item,
+		}})
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	updated := *job
+	updated.Data = map[string]interface{}{}
+	for k, v := range job.Data {
+		updated.Data[k] = v
+	}
+	updated.Data["nextChunk"] = index + 1
+	updated.Data["writtenItems"] = toInt(job.Data["writtenItems"]) + len(chunk.Records)
+	updated.Data["updatedAt"] = now
+	updated.Status = CopyJobStatusRunning
+	delete(updated.Data, "error")
+	if index+1 == total {
+		updated.Status = CopyJobStatusCompleted
+		updated.Data["completedAt"] = now
+	}
+	updated.Data["status"] = updated.Status
+	updated.UpdatedAt = now
+
+	jobItem, err := attributevalue.MarshalMap(updated)
+	if err != nil {
+		return fmt.Errorf("failed to marshal copy job: %w", err)
+	}
+	items = append(items, types.TransactWriteItem{Put: &types.Put{
+		TableName:                aws.String(svc.performanceTableName()),
+		Item:                     jobItem,
+		ConditionExpression:      aws.String("#data.#next = :next"),
+		ExpressionAttributeNames: map[string]string{"#data": "Data", "#next": "nextChunk"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":next": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", index)},
+		},
+	}})
+
+	if _, err := svc.dynamodbClient.TransactWriteItems(svc.ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items}); err != nil {
+		var cancelled *types.TransactionCanceledException
+		if errors.As(err, &cancelled) && len(cancelled.CancellationReasons) == len(items) &&
+			aws.ToString(cancelled.CancellationReasons[len(items)-1].Code) == "ConditionalCheckFailed" {
+			return fmt.Errorf("%w: chunk %d was already written", ErrCopyJobConflict, index)
+		}
+		return fmt.Errorf("failed to write chunk: %w", err)
+	}
+	*job = updated
+	return nil
+}
+
+// failCopyJob records why the job stopped; the job keeps its place and can be resumed. Like
+// writeCopyJobChunk it only writes while the job is still at index, so a failure never overwrites
+// the progress of a run that has since moved the job on.
+func (svc *PerformanceService) failCopyJob(job *PerformanceRecord, index int, cause error) {
+	jobID := toString(job.Data["id"])
+	now := svc.now()
+	failed := *job
+	failed.Data = map[string]interface{}{}
+	for k, v := range job.Data {
+		failed.Data[k] = v
+	}
+	failed.Data["status"] = CopyJobStatusFailed
+	failed.Data["error"] = cause.Error()
+	failed.Data["failedChunk"] = index
+	failed.Data["updatedAt"] = now
+	failed.Status = CopyJobStatusFailed
+	failed.UpdatedAt = now
+
+	item, err := attributevalue.MarshalMap(failed)
+	if err != nil {
+		svc.logger.Printf("failed to record the failure of copy job %s: %v", jobID, err)
+		return
+	}
+	_, err = svc.dynamodbClient.PutItem(svc.ctx, &dynamodb.PutItemInput{
+		TableName:                aws.String(svc.performanceTableName()),
+		Item:                     item,
+		ConditionExpression:      aws.String("#data.#next = :next"),
+		ExpressionAttributeNames: map[string]string{"#data": "Data", "#next": "nextChunk"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":next": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", index)},
+		},
+	})
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		svc.logger.Printf("copy job %s moved past chunk %d; not recording its failure", jobID, index+1)
+		return
+	}
+	if err != nil {
+		svc.logger.Printf("failed to record the failure of copy job %s: %v", jobID, err)
+		return
+	}
+	*job = failed
+}
@@ -0,0 +1,140 @@
+package Companylib
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodb_types "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	awsclients "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/clients"
+	"github.com/stretchr/testify/assert"
+)
+
+func copyJobTestRecord(status string, nextChunk int) PerformanceRecord {
+	return PerformanceRecord{
+		PK: "ORG#org-1", SK: "PERF#JOB#job-1", GSI1PK: "PERF#JOB#job-1",
+		EntityType: perfEntityCopyJob, OrganizationId: "ORG#org-1", Status: status,
+		Data: map[string]interface{}{
+			"id": "job-1", "type": CopyJobTypeCloneCycle, "status": status,
+			"totalChunks": 2.0, "nextChunk": float64(nextChunk), "writtenItems": 25.0, "totalItems": 26.0,
+		},
+	}
+}
+
+func copyJobChunkOutput(t *testing.T, index int, records ...PerformanceRecord) dynamodb.GetItemOutput {
+	item, err := attributevalue.MarshalMap(copyJobChunk{
+		PK: "ORG#org-1", SK: copyJobChunkSK("job-1", index), EntityType: perfEntityCopyJobChunk, Records: records,
+	})
+	assert.NoError(t, err)
+	return dynamodb.GetItemOutput{Item: item}
+}
+
+func TestResumeCopyJob(t *testing.T) {
+	cycle := PerformanceRecord{PK: "ORG#org-1", SK: "PERF#CYCLE#c-2", EntityType: perfEntityCycle, Data: map[string]interface{}{"id": "c-2"}}
+
+	t.Run("It should write the remaining chunk with the job's progress and clean up", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs:             []dynamodb.QueryOutput{reviewQueryOutput(t, copyJobTestRecord(CopyJobStatusFailed, 1))},
+			QueryErrors:              []error{nil},
+			GetItemOutputs:           []dynamodb.GetItemOutput{copyJobChunkOutput(t, 1, cycle)},
+			GetItemErrors:            []error{nil},
+			TransactWriteItemsOutput: []dynamodb.TransactWriteItemsOutput{{}},
+			TransactWriteItemsErrors: []error{nil},
+			DeleteItemOutputs:        []dynamodb.DeleteItemOutput{{}, {}},
+			DeleteItemErrors:         []error{nil, nil},
+		}
+		svc := newOKRTestService(&ddbClient)
+
+		job, err := svc.ResumeCopyJob("job-1")
+
+		assert.NoError(t, err)
+		assert.Equal(t, CopyJobStatusCompleted, job["status"])
+		assert.Equal(t, 2, job["nextChunk"])
+		assert.Equal(t, 26, job["writtenItems"])
+
+		input := ddbClient.TransactWriteItemsInputs[0]
+		assert.Len(t, input.TransactItems, 2)
+		assert.Equal(t, "PERF#CYCLE#c-2", transactRecord(t, input, 0).SK)
+		assert.Equal(t, "#data.#next = :next", aws.ToString(input.TransactItems[1].Put.ConditionExpression))
+		assert.Equal(t, &dynamodb_types.AttributeValueMemberN{Value: "1"}, input.TransactItems[1].Put.ExpressionAttributeValues[":next"])
+		assert.Equal(t, CopyJobStatusCompleted, transactRecord(t, input, 1).Status)
+		assert.Len(t, ddbClient.DeleteItemInputs, 2)
+	})
+
+	t.Run("It should not write a chunk another run already wrote", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs:             []dynamodb.QueryOutput{reviewQueryOutput(t, copyJobTestRecord(CopyJobStatusRunning, 1))},
+			QueryErrors:              []error{nil},
+			GetItemOutputs:           []dynamodb.GetItemOutput{copyJobChunkOutput(t, 1, cycle)},
+			GetItemErrors:            []error{nil},
+			TransactWriteItemsOutput: []dynamodb.TransactWriteItemsOutput{{}},
+			TransactWriteItemsErrors: []error{&dynamodb_types.TransactionCanceledException{
+				CancellationReasons: []dynamodb_types.CancellationReason{{Code: aws.String("None")}, {Code: aws.String("ConditionalCheckFailed")}},
+			}},
+		}
+		svc := newOKRTestService(&ddbClient)
+
+		_, err := svc.ResumeCopyJob("job-1")
+
+		assert.ErrorIs(t, err, ErrCopyJobConflict)
+		assert.Empty(t, ddbClient.PutItemInputs)
+		assert.Empty(t, ddbClient.DeleteItemInputs)
+	})
+
+	t.Run("It should record a failure and keep the job's place", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs:             []dynamodb.QueryOutput{reviewQueryOutput(t, copyJobTestRecord(CopyJobStatusRunning, 1))},
+			QueryErrors:              []error{nil},
+			GetItemOutputs:           []dynamodb.GetItemOutput{copyJobChunkOutput(t, 1, cycle)},
+			GetItemErrors:            []error{nil},
+			TransactWriteItemsOutput: []dynamodb.TransactWriteItemsOutput{{}},
+			TransactWriteItemsErrors: []error{errors.New("throttled")},
+			PutItemOutputs:           []dynamodb.PutItemOutput{{}},
+			PutItemErrors:            []error{nil},
+		}
+		svc := newOKRTestService(&ddbClient)
+
+		job, err := svc.ResumeCopyJob("job-1")
+
+		assert.ErrorContains(t, err, "copy job job-1 stopped at chunk 2 of 2")
+		assert.Equal(t, CopyJobStatusFailed, job["status"])
+		assert.Equal(t, 1.0, job["nextChunk"])
+		assert.Equal(t, 1, job["failedChunk"])
+		assert.Equal(t, "#data.#next = :next", aws.ToString(ddbClient.PutItemInputs[0].ConditionExpression))
+		assert.Equal(t, &dynamodb_types.AttributeValueMemberN{Value: "1"}, ddbClient.PutItemInputs[0].ExpressionAttributeValues[":next"])
+	})
+
+	t.Run("It should not record a failure over a run that has moved the job on", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs:             []dynamodb.QueryOutput{reviewQueryOutput(t, copyJobTestRecord(CopyJobStatusRunning, 1))},
+			QueryErrors:              []error{nil},
+			GetItemOutputs:           []dynamodb.GetItemOutput{copyJobChunkOutput(t, 1, cycle)},
+			GetItemErrors:            []error{nil},
+			TransactWriteItemsOutput: []dynamodb.TransactWriteItemsOutput{{}},
+			TransactWriteItemsErrors: []error{errors.New("throttled")},
+			PutItemOutputs:           []dynamodb.PutItemOutput{{}},
+			PutItemErrors:            []error{&dynamodb_types.ConditionalCheckFailedException{}},
+		}
+		svc := newOKRTestService(&ddbClient)
+
+		job, err := svc.ResumeCopyJob("job-1")
+
+		assert.ErrorContains(t, err, "throttled")
+		assert.Equal(t, CopyJobStatusRunning, job["status"])
+		assert.Nil(t, job["failedChunk"])
+	})
+
+	t.Run("It should not resume a completed job", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs: []dynamodb.QueryOutput{reviewQueryOutput(t, copyJobTestRecord(CopyJobStatusCompleted, 2))},
+			QueryErrors:  []error{nil},
+		}
+		svc := newOKRTestService(&ddbClient)
+
+		_, err := svc.ResumeCopyJob("job-1")
+
+		assert.ErrorIs(t, err, ErrCopyJobConflict)
+	})
+}
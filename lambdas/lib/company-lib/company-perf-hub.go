@@ -0,0 +1,86 @@
+package Companylib
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Tasks and feedback requests in PERF_HUB_TABLE are written both by the performance hub routes and
+// by the assistant's actions. The rules below are shared so both write the same records and the
+// hub's reminder scheduler treats them alike.
+
+const (
+	DefaultFeedbackWindow = 14 * 24 * time.Hour
+	MaxFeedbackWindow     = 90 * 24 * time.Hour
+	FeedbackReminderLead  = 3 * 24 * time.Hour
+
+	// FeedbackReminderKey is the partition of FeedbackReminderIndex; it is removed once a request
+	// is answered, declined or expired
+	FeedbackReminderKey = "FEEDBACK_REQUEST"
+)
+
+// FeedbackExpiry returns when a request sent at now expires: the end of dueDate (YYYY-MM-DD,
+// UTC) or, without one, DefaultFeedbackWindow from now.
+func FeedbackExpiry(now time.Time, dueDate string) (time.Time, error) {
+	if dueDate == "" {
+		return now.Add(DefaultFeedbackWindow).Truncate(time.Second), nil
+	}
+	due, err := time.Parse("2006-01-02", dueDate)
+	if err != nil {
+		return time.Time{}, errors.New("dueDate must be in YYYY-MM-DD format")
+	}
+	expiresAt := due.AddDate(0, 0, 1)
+	if !expiresAt.After(now) {
+		return time.Time{}, errors.New("dueDate cannot be in the past")
+	}
+	if expiresAt.Sub(now) > MaxFeedbackWindow {
+		return time.Time{}, fmt.Errorf("dueDate must be within %d days", int(MaxFeedbackWindow.Hours()/24))
+	}
+	return expiresAt, nil
+}
+
+// FeedbackRemindAt schedules the reminder FeedbackReminderLead before expiry, or halfway through
+// the window when it is shorter than that.
+func FeedbackRemindAt(createdAt, expiresAt time.Time) time.Time {
+	window := expiresAt.Sub(createdAt)
+	if window > 2*FeedbackReminderLead {
+		return expiresAt.Add(-FeedbackReminderLead)
+	}
+	return createdAt.Add(window / 2).Truncate(time.Second)
+}
+
+// AllocateTaskID atomically increments the team-scoped task counter and returns the next TASK-N
+// identifier with its number. The first task in a team is TASK-101.
+func (svc *PerformanceService) AllocateTaskID(teamID string) (string, int, error) {
+	result, err := svc.dynamodbClient.UpdateItem(svc.ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(svc.PerfHubTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "TEAM#" + teamID},
+			"SK": &types.AttributeValueMemberS{Value: "COUNTER#TASK_NUM"},
+		},
+		UpdateExpression: aws.String("ADD taskCounter :incr"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":incr": &types.AttributeValueMemberN{Value: "1"},
+		},
+		ReturnValues: types.ReturnValueUpdatedNew,
+	})
+	if err != nil {
+		return "", 0, err
+	}
+
+	var counter struct {
+		TaskCounter int `dynamodbav:"taskCounter"`
+	}
+	if err := attributevalue.UnmarshalMap(result.Attributes, &counter); err != nil {
+		return "", 0, err
+	}
+	// DDB ADD starts from 0, so offset by 100 to make the first task TASK-101
+	taskNum := counter.TaskCounter + 100
+	return fmt.Sprintf("TASK-%d", taskNum), taskNum, nil
+}
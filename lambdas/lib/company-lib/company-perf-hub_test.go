@@ -0,0 +1,60 @@
+package Companylib
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodb_types "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	awsclients "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/clients"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeedbackExpiry(t *testing.T) {
+	now := time.Date(2026, 3, 10, 15, 30, 0, 0, time.UTC)
+
+	expiresAt, err := FeedbackExpiry(now, "")
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(14*24*time.Hour), expiresAt)
+
+	expiresAt, err = FeedbackExpiry(now, "2026-03-12")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 3, 13, 0, 0, 0, 0, time.UTC), expiresAt)
+
+	// Due today is allowed; it expires at midnight
+	_, err = FeedbackExpiry(now, "2026-03-10")
+	assert.NoError(t, err)
+
+	_, err = FeedbackExpiry(now, "2026-03-09")
+	assert.Error(t, err)
+	_, err = FeedbackExpiry(now, "2026-07-01")
+	assert.Error(t, err)
+	_, err = FeedbackExpiry(now, "10/03/2026")
+	assert.Error(t, err)
+}
+
+func TestFeedbackRemindAt(t *testing.T) {
+	created := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, created.Add(11*24*time.Hour), FeedbackRemindAt(created, created.Add(14*24*time.Hour)))
+	assert.Equal(t, created.Add(24*time.Hour), FeedbackRemindAt(created, created.Add(48*time.Hour)))
+}
+
+func TestAllocateTaskID(t *testing.T) {
+	counter, _ := attributevalue.MarshalMap(map[string]int{"taskCounter": 7})
+	ddbClient := awsclients.MockDynamodbClient{
+		UpdateItemOutputs: []dynamodb.UpdateItemOutput{{Attributes: counter}},
+		UpdateItemErrors:  []error{nil},
+	}
+	svc := newOKRTestService(&ddbClient)
+	svc.PerfHubTable = "PerfHubTable-test"
+
+	taskID, taskNum, err := svc.AllocateTaskID("team-1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "TASK-107", taskID)
+	assert.Equal(t, 107, taskNum)
+	assert.Equal(t, "PerfHubTable-test", *ddbClient.UpdateItemInputs[0].TableName)
+	assert.Equal(t, &dynamodb_types.AttributeValueMemberS{Value: "TEAM#team-1"}, ddbClient.UpdateItemInputs[0].Key["PK"])
+}
@@ -0,0 +1,857 @@
+package Companylib
+
+// The Data of cycles, quarters, KPIs, OKRs, key results, meeting notes, goal sub-items and goal
+// tasks follows a typed schema per entity (CycleData, QuarterData, ...). Request bodies are checked
+// against it before they are stored:
+//
+//   - unknown fields and values of the wrong type are rejected
+//   - server-owned fields (id, organizationId, timestamps, computed fields) are ignored
+//   - immutable fields (the cycle or quarter a KPI belongs to, ...) are set on create and a patch
+//     may only repeat them
+//   - a patch's null clears an optional field
+//
+// Records written before the schemas existed are migrated when they are next patched, or in bulk
+// by MigratePerformanceData. Fields the schema does not know, or whose value has the wrong type,
+// are moved to legacyFields instead of being dropped.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// perfDataSchemaVersion is stamped on records whose Data follows the current schemas
+const perfDataSchemaVersion = 1
+
+const (
+	perfListMaxItems        = 50
+	perfListItemMaxLength   = 200
+	perfCustomFieldsMax     = 20
+	perfCustomKeyMaxLength  = 64
+	perfCustomValueMaxChars = 500
+)
+
+var ErrPerfDataInvalid = errors.New("invalid performance data")
+
+// PerfRecordMeta holds the fields every typed performance record shares. customFields carries
+// client-defined scalar values; legacyFields holds what a migration could not map to the schema.
+type PerfRecordMeta struct {
+	ID             string                 `json:"id,omitempty" perf:"server"`
+	OrganizationID string                 `json:"organizationId,omitempty" perf:"server"`
+	CreatedAt      string                 `json:"createdAt,omitempty" perf:"server"`
+	UpdatedAt      string                 `json:"updatedAt,omitempty" perf:"server"`
+	CustomFields   map[string]interface{} `json:"customFields,omitempty" perf:"custom"`
+	LegacyFields   map[string]interface{} `json:"legacyFields,omitempty" perf:"server"`
+}
+
+type CycleData struct {
+	PerfRecordMeta
+	Name              string `json:"name,omitempty" perf:"required,max=200"`
+	Description       string `json:"description,omitempty" perf:"max=2000"`
+	FiscalYear        string `json:"fiscalYear,omitempty" perf:"max=20"`
+	StartDate         string `json:"startDate,omitempty" perf:"date"`
+	EndDate           string `json:"endDate,omitempty" perf:"date"`
+	Status            string `json:"status,omitempty" perf:"enum=PLANNING|STARTED|FINALIZED|CLOSED"`
+	ClonedFromCycleID string `json:"clonedFromCycleId,omitempty" perf:"server"`
+}
+
+type QuarterData struct {
+	PerfRecordMeta
+	CycleID      string                 `json:"cycleId,omitempty" perf:"server"`
+	Name         string                 `json:"name,omitempty" perf:"required,max=200"`
+	Description  string                 `json:"description,omitempty" perf:"max=2000"`
+	StartDate    string                 `json:"startDate,omitempty" perf:"date"`
+	EndDate      string                 `json:"endDate,omitempty" perf:"date"`
+	Status       string                 `json:"status,omitempty" perf:"enum=PLANNING|STARTED|FINALIZED|CLOSED"`
+	ReviewWindow map[string]interface{} `json:"reviewWindow,omitempty" perf:"server"`
+}
+
+type KPIData struct {
+	PerfRecordMeta
+	CycleID            string   `json:"cycleId,omitempty" perf:"immutable"`
+	QuarterID          string   `json:"quarterId,omitempty" perf:"immutable"`
+	ParentKpiID        string   `json:"parentKpiId,omitempty" perf:"server"`
+	Name               string   `json:"name,omitempty" perf:"required,max=200"`
+	Description        string   `json:"description,omitempty" perf:"max=2000"`
+	Owner              string   `json:"owner,omitempty" perf:"required,max=200"`
+	Department         string   `json:"department,omitempty" perf:"max=200"`
+	Category           string   `json:"category,omitempty" perf:"max=200"`
+	UnitOfMeasure      string   `json:"unitOfMeasure,omitempty" perf:"max=50"`
+	Unit               string   `json:"unit,omitempty" perf:"max=50"`
+	ReportingFrequency string   `json:"reportingFrequency,omitempty" perf:"enum=daily|weekly|monthly|quarterly|annually"`
+	Direction          string   `json:"direction,omitempty" perf:"enum=increase|decrease|maintain"`
+	Trend              string   `json:"trend,omitempty" perf:"enum=up|down|stable"`
+	IncentiveImpact    string   `json:"incentiveImpact,omitempty" perf:"enum=yes|no"`
+	TargetValue        *float64 `json:"targetValue,omitempty" perf:"required"`
+	CurrentValue       *float64 `json:"currentValue,omitempty"`
+	BaselineValue      *float64 `json:"baselineValue,omitempty"`
+	Tolerance          *float64 `json:"tolerance,omitempty"`
+	GreenThreshold     *float64 `json:"greenThreshold,omitempty"`
+	AmberThreshold     *float64 `json:"amberThreshold,omitempty"`
+	RedThreshold       *float64 `json:"redThreshold,omitempty"`
+	Weight             *float64 `json:"weight,omitempty"`
+	StartDate          string   `json:"startDate,omitempty" perf:"date"`
+	EndDate            string   `json:"endDate,omitempty" perf:"date"`
+	Status             string   `json:"status,omitempty" perf:"enum=PLANNING|STARTED|FINALIZED|CLOSED"`
+	Tags               []string `json:"tags,omitempty"`
+	Progress           *float64 `json:"progress,omitempty" perf:"server"`
+	RagStatus          string   `json:"ragStatus,omitempty" perf:"server"`
+	LastValueDate      string   `json:"lastValueDate,omitempty" perf:"server"`
+	LastReminderAt     string   `json:"lastReminderAt,omitempty" perf:"server"`
+	LastReminderPeriod string   `json:"lastReminderPeriod,omitempty" perf:"server"`
+	ClonedFromKpiID    string   `json:"clonedFromKpiId,omitempty" perf:"server"`
+	TemplateID         string   `json:"templateId,omitempty" perf:"server"`
+}
+
+type OKRData struct {
+	PerfRecordMeta
+	CycleID            string                   `json:"cycleId,omitempty" perf:"immutable"`
+	QuarterID          string                   `json:"quarterId,omitempty" perf:"immutable"`
+	Name               string                   `json:"name,omitempty" perf:"max=200"`
+	Objective          string                   `json:"objective,omitempty" perf:"max=500"`
+	Description        string                   `json:"description,omitempty" perf:"max=2000"`
+	Owner              string                   `json:"owner,omitempty" perf:"max=200"`
+	ObjectiveOwner     string                   `json:"objectiveOwner,omitempty" perf:"max=200"`
+	TimeBound          string                   `json:"timeBound,omitempty" perf:"max=50"`
+	Department         string                   `json:"department,omitempty" perf:"max=200"`
+	StartDate          string                   `json:"startDate,omitempty" perf:"date"`
+	EndDate            string                   `json:"endDate,omitempty" perf:"date"`
+	Status             string                   `json:"status,omitempty" perf:"max=50"`
+	ConfidenceScore    *float64                 `json:"confidenceScore,omitempty"`
+	Tags               []string                 `json:"tags,omitempty"`
+	KeyResults         []map[string]interface{} `json:"keyResults,omitempty" perf:"server"`
+	Progress           *float64                 `json:"progress,omitempty" perf:"server"`
+	ExpectedProgress   *float64                 `json:"expectedProgress,omitempty" perf:"server"`
+	Health             string                   `json:"health,omitempty" perf:"server"`
+	ComputedConfidence *float64                 `json:"computedConfidence,omitempty" perf:"server"`
+	ConfidenceSource   string                   `json:"confidenceSource,omitempty" perf:"server"`
+	KeyResultCount     *float64                 `json:"keyResultCount,omitempty" perf:"server"`
+	ProgressUpdatedAt  string                   `json:"progressUpdatedAt,omitempty" perf:"server"`
+	ClonedFromOkrID    string                   `json:"clonedFromOkrId,omitempty" perf:"server"`
+	TemplateID         string                   `json:"templateId,omitempty" perf:"server"`
+}
+
+type KeyResultData struct {
+	PerfRecordMeta
+	OKRID           string   `json:"okrId,omitempty" perf:"server"`
+	Name            string   `json:"name,omitempty" perf:"max=200"`
+	Description     string   `json:"description,omitempty" perf:"max=2000"`
+	Type            string   `json:"type,omitempty" perf:"enum=INCREASE|DECREASE|BINARY"`
+	StartValue      *float64 `json:"startValue,omitempty"`
+	TargetValue     *float64 `json:"targetValue,omitempty"`
+	CurrentValue    *float64 `json:"currentValue,omitempty"`
+	Weight          *float64 `json:"weight,omitempty"`
+	ConfidenceScore *float64 `json:"confidenceScore,omitempty"`
+	Unit            string   `json:"unit,omitempty" perf:"max=50"`
+	Owner           string   `json:"owner,omitempty" perf:"max=200"`
+	DueDate         string   `json:"dueDate,omitempty" perf:"date"`
+	Status          string   `json:"status,omitempty" perf:"max=50"`
+	Comment         string   `json:"comment,omitempty" perf:"max=2000"`
+	Progress        *float64 `json:"progress,omitempty" perf:"server"`
+}
+
+type MeetingNoteData struct {
+	PerfRecordMeta
+	CycleID     string   `json:"cycleId,omitempty" perf:"server"`
+	QuarterID   string   `json:"quarterId,omitempty" perf:"server"`
+	Title       string   `json:"title,omitempty" perf:"required,max=200"`
+	Date        string   `json:"date,omitempty" perf:"date"`
+	Notes       string   `json:"notes,omitempty" perf:"max=20000"`
+	Attendees   []string `json:"attendees,omitempty"`
+	ActionItems []string `json:"actionItems,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+type SubItemData struct {
+	PerfRecordMeta
+	ParentGoalID string   `json:"parentGoalId,omitempty" perf:"server"`
+	Title        string   `json:"title,omitempty" perf:"required,max=200"`
+	Description  string   `json:"description,omitempty" perf:"max=2000"`
+	Owner        string   `json:"owner,omitempty" perf:"max=200"`
+	Status       string   `json:"status,omitempty" perf:"max=50"`
+	DueDate      string   `json:"dueDate,omitempty" perf:"date"`
+	Progress     *float64 `json:"progress,omitempty"`
+}
+
+type GoalTaskData struct {
+	PerfRecordMeta
+	GoalID        string `json:"goalId,omitempty" perf:"server"`
+	UserID        string `json:"userId,omitempty" perf:"server"`
+	Title         string `json:"title,omitempty" perf:"required,max=200"`
+	Description   string `json:"description,omitempty" perf:"max=2000"`
+	Status        string `json:"status,omitempty" perf:"enum=todo|in-progress|completed"`
+	Priority      string `json:"priority,omitempty" perf:"max=20"`
+	DueDate       string `json:"dueDate,omitempty" perf:"date"`
+	CompletedDate string `json:"completedDate,omitempty" perf:"date"`
+}
+
+type perfFieldKind int
+
+const (
+	perfKindString perfFieldKind = iota
+	perfKindNumber
+	perfKindBool
+	perfKindStrings
+	perfKindObject
+	perfKindObjects
+)
+
+type perfFieldAccess int
+
+const (
+	perfFieldWritable perfFieldAccess = iota
+	perfFieldImmutable
+	perfFieldServer
+)
+
+type perfField struct {
+	name     string
+	kind     perfFieldKind
+	access   perfFieldAccess
+	required bool
+	date     bool
+	custom   bool
+	max      int
+	enum     []string
+}
+
+type perfSchema struct {
+	entityType string
+	fields     map[string]perfField
+	// readOnly are response-only fields (nested lists, analytics) that are ignored in request bodies
+	readOnly map[string]bool
+	// validate checks rules across fields. changed is nil on create; on a patch only rules that
+	// involve a changed field are checked, so an old record can still be edited.
+	validate func(data map[string]interface{}, changed map[string]interface{}) error
+}
+
+var perfSchemas = map[string]*perfSchema{
+	perfEntityCycle:     newPerfSchema(perfEntityCycle, CycleData{}, []string{"quarters", "kpis", "okrs", "analytics"}, validatePerfDateRange),
+	perfEntityQuarter:   newPerfSchema(perfEntityQuarter, QuarterData{}, []string{"kpis", "okrs", "meetingNotes", "pendingReviews"}, validatePerfDateRange),
+	perfEntityKPI:       newPerfSchema(perfEntityKPI, KPIData{}, []string{"subKPIs", "valueHistory"}, validatePerfDateRange),
+	perfEntityOKR:       newPerfSchema(perfEntityOKR, OKRData{}, []string{"progressHistory", "okrProgress"}, validateOKRData),
+	perfEntityKeyResult: newPerfSchema(perfEntityKeyResult, KeyResultData{}, []string{"okrProgress"}, nil),
+	perfEntityMeeting:   newPerfSchema(perfEntityMeeting, MeetingNoteData{}, nil, nil),
+	perfEntityGoalSub:   newPerfSchema(perfEntityGoalSub, SubItemData{}, nil, nil),
+	perfEntityGoalTask:  newPerfSchema(perfEntityGoalTask, GoalTaskData{}, nil, nil),
+}
+
+// newPerfSchema reads an entity's fields from its struct's json and perf tags
+func newPerfSchema(entityType string, model interface{}, readOnly []string, validate func(map[string]interface{}, map[string]interface{}) error) *perfSchema {
+	schema := &perfSchema{entityType: entityType, fields: map[string]perfField{}, readOnly: map[string]bool{}, validate: validate}
+	for _, name := range readOnly {
+		schema.readOnly[name] = true
+	}
+	schema.addFields(reflect.TypeOf(model))
+	return schema
+}
+
+func (schema *perfSchema) addFields(t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.Anonymous {
+			schema.addFields(sf.Type)
+			continue
+		}
+		name := strings.Split(sf.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		field := perfField{name: name, kind: perfKindOf(sf.Type)}
+		for _, opt := range strings.Split(sf.Tag.Get("perf"), ",") {
+			switch {
+			case opt == "":
+			case opt == "server":
+				field.access = perfFieldServer
+			case opt == "immutable":
+				field.access = perfFieldImmutable
+			case opt == "required":
+				field.required = true
+			case opt == "date":
+				field.date = true
+			case opt == "custom":
+				field.custom = true
+			case strings.HasPrefix(opt, "max="):
+				field.max, _ = strconv.Atoi(strings.TrimPrefix(opt, "max="))
+			case strings.HasPrefix(opt, "enum="):
+				field.enum = strings.Split(strings.TrimPrefix(opt, "enum="), "|")
+			default:
+				panic(fmt.Sprintf("%s.%s: unknown perf tag option %q", t.Name(), sf.Name, opt))
+			}
+		}
+		if field.access == perfFieldImmutable && field.kind != perfKindString {
+			panic(fmt.Sprintf("%s.%s: only string fields can be immutable", t.Name(), sf.Name))
+		}
+		schema.fields[name] = field
+	}
+}
+
+func perfKindOf(t reflect.Type) perfFieldKind {
+	switch {
+	case t.Kind() == reflect.String:
+		return perfKindString
+	case t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Float64:
+		return perfKindNumber
+	case t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Bool:
+		return perfKindBool
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String:
+		return perfKindStrings
+	case t.Kind() == reflect.Map:
+		return perfKindObject
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Map:
+		return perfKindObjects
+	}
+	panic(fmt.Sprintf("unsupported performance data field type %s", t))
+}
+
+// convert checks a value's type and returns it in the form it is stored in
+func (field perfField) convert(value interface{}) (interface{}, error) {
+	switch field.kind {
+	case perfKindString:
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("%s must be a string", field.name)
+	case perfKindNumber:
+		var n float64
+		switch v := value.(type) {
+		case string:
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be a number", field.name)
+			}
+			n = parsed
+		case json.Number:
+			parsed, err := v.Float64()
+			if err != nil {
+				return nil, fmt.Errorf("%s must be a number", field.name)
+			}
+			n = parsed
+		case float64, float32, int, int32, int64:
+			n = toFloat(v)
+		default:
+			return nil, fmt.Errorf("%s must be a number", field.name)
+		}
+		if math.IsNaN(n) || math.IsInf(n, 0) {
+			return nil, fmt.Errorf("%s must be a number", field.name)
+		}
+		return n, nil
+	case perfKindBool:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("%s must be true or false", field.name)
+	case perfKindStrings:
+		var items []interface{}
+		switch v := value.(type) {
+		case []interface{}:
+			items = v
+		case []string:
+			for _, s := range v {
+				items = append(items, s)
+			}
+		default:
+			return nil, fmt.Errorf("%s must be a list of strings", field.name)
+		}
+		list := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s must be a list of strings", field.name)
+			}
+			list = append(list, s)
+		}
+		return list, nil
+	case perfKindObject:
+		if m, ok := value.(map[string]interface{}); ok {
+			return m, nil
+		}
+		return nil, fmt.Errorf("%s must be an object", field.name)
+	case perfKindObjects:
+		var items []interface{}
+		switch v := value.(type) {
+		case []interface{}:
+			items = v
+		case []map[string]interface{}:
+			for _, m := range v {
+				items = append(items, m)
+			}
+		default:
+			return nil, fmt.Errorf("%s must be a list of objects", field.name)
+		}
+		for _, item := range items {
+			if _, ok := item.(map[string]interface{}); !ok {
+				return nil, fmt.Errorf("%s must be a list of objects", field.name)
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("%s has an unsupported type", field.name)
+}
+
+// check applies the field's rules to a converted value and returns it with enums in their canonical case
+func (field perfField) check(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		if field.required && strings.TrimSpace(v) == "" {
+			return nil, fmt.Errorf("%s is required", field.name)
+		}
+		if field.max > 0 && utf8.RuneCountInString(v) > field.max {
+			return nil, fmt.Errorf("%s must be <= %d characters", field.name, field.max)
+		}
+		if field.date && v != "" {
+			if _, err := time.Parse("2006-01-02", v); err != nil {
+				return nil, fmt.Errorf("%s must be YYYY-MM-DD", field.name)
+			}
+		}
+		if len(field.enum) > 0 && v != "" {
+			for _, allowed := range field.enum {
+				if strings.EqualFold(v, allowed) {
+					return allowed, nil
+				}
+			}
+			return nil, fmt.Errorf("%s must be one of %s", field.name, strings.Join(field.enum, ", "))
+		}
+	case []interface{}:
+		if field.kind != perfKindStrings {
+			break
+		}
+		if len(v) > perfListMaxItems {
+			return nil, fmt.Errorf("%s holds at most %d items", field.name, perfListMaxItems)
+		}
+		for _, item := range v {
+			if utf8.RuneCountInString(item.(string)) > perfListItemMaxLength {
+				return nil, fmt.Errorf("%s items must be <= %d characters", field.name, perfListItemMaxLength)
+			}
+		}
+	case map[string]interface{}:
+		if field.custom {
+			return value, checkPerfCustomFields(field.name, v)
+		}
+	}
+	return value, nil
+}
+
+// checkPerfCustomFields keeps customFields to a bounded set of scalar values
+func checkPerfCustomFields(name string, fields map[string]interface{}) error {
+	if len(fields) > perfCustomFieldsMax {
+		return fmt.Errorf("%s holds at most %d fields", name, perfCustomFieldsMax)
+	}
+	for key, value := range fields {
+		if key == "" || utf8.RuneCountInString(key) > perfCustomKeyMaxLength {
+			return fmt.Errorf("%s keys must be 1-%d characters", name, perfCustomKeyMaxLength)
+		}
+		switch v := value.(type) {
+		case nil, bool, float64, float32, int, int32, int64:
+		case string:
+			if utf8.RuneCountInString(v) > perfCustomValueMaxChars {
+				return fmt.Errorf("%s.%s must be <= %d characters", name, key, perfCustomValueMaxChars)
+			}
+		default:
+			return fmt.Errorf("%s.%s must be a string, number or boolean", name, key)
+		}
+	}
+	return nil
+}
+
+// decodePerfInput checks a request body against the entity's schema and returns the fields to
+// store. current is nil on create and the record's (migrated) data on a patch, where a null value
+// is returned as nil to clear the field.
+func decodePerfInput(entityType string, input map[string]interface{}, current map[string]interface{}) (map[string]interface{}, error) {
+	schema := perfSchemas[entityType]
+	if schema == nil {
+		return nil, fmt.Errorf("%w: %s records have no schema", ErrPerfDataInvalid, entityType)
+	}
+	creating := current == nil
+
+	keys := make([]string, 0, len(input))
+	for key := range input {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	out := map[string]interface{}{}
+	unknown := []string{}
+	problems := []string{}
+	for _, key := range keys {
+		value := input[key]
+		field, ok := schema.fields[key]
+		if !ok {
+			if !schema.readOnly[key] {
+				unknown = append(unknown, key)
+			}
+			continue
+		}
+		if field.access == perfFieldServer {
+			// legacyFields may be cleared once they have been looked at
+			if key == "legacyFields" && value == nil && !creating {
+				out[key] = nil
+			}
+			continue
+		}
+		if value == nil {
+			switch {
+			case creating:
+			case field.access == perfFieldImmutable && hasValue(current, key):
+				problems = append(problems, fmt.Sprintf("%s cannot be changed", key))
+			case field.required:
+				problems = append(problems, fmt.Sprintf("%s is required", key))
+			default:
+				out[key] = nil
+			}
+			continue
+		}
+		converted, err := field.convert(value)
+		if err == nil {
+			converted, err = field.check(converted)
+		}
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		if field.access == perfFieldImmutable && !creating {
+			if toString(current[key]) != converted {
+				if hasValue(current, key) {
+					problems = append(problems, fmt.Sprintf("%s cannot be changed", key))
+				} else {
+					problems = append(problems, fmt.Sprintf("%s can only be set when the record is created", key))
+				}
+			}
+			continue
+		}
+		out[key] = converted
+	}
+
+	if creating {
+		for _, name := range sortedPerfFieldNames(schema) {
+			if field := schema.fields[name]; field.required && field.access != perfFieldServer && !hasValue(out, name) {
+				problems = append(problems, fmt.Sprintf("%s is required", name))
+			}
+		}
+	}
+	if len(unknown) > 0 {
+		problems = append([]string{"unknown fields: " + strings.Join(unknown, ", ")}, problems...)
+	}
+	if len(problems) == 0 && schema.validate != nil {
+		merged, changed := out, map[string]interface{}(nil)
+		if !creating {
+			merged, changed = mergePerfData(current, out), out
+		}
+		if err := schema.validate(merged, changed); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrPerfDataInvalid, strings.Join(problems, "; "))
+	}
+	return out, nil
+}
+
+func sortedPerfFieldNames(schema *perfSchema) []string {
+	names := make([]string, 0, len(schema.fields))
+	for name := range schema.fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// mergePerfData applies changes to a copy of data, with nil values removing the field
+func mergePerfData(data map[string]interface{}, changes map[string]interface{}) map[string]interface{} {
+	merged := copyData(data)
+	for key, value := range changes {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+// validatePerfDateRange keeps endDate on or after startDate
+func validatePerfDateRange(data map[string]interface{}, changed map[string]interface{}) error {
+	if changed != nil && !hasValue(changed, "startDate") && !hasValue(changed, "endDate") {
+		return nil
+	}
+	start, end := toString(data["startDate"]), toString(data["endDate"])
+	if start != "" && end != "" && end < start {
+		return fmt.Errorf("endDate must be on or after startDate")
+	}
+	return nil
+}
+
+// validateOKRData needs an OKR to be named by its name or its objective
+func validateOKRData(data map[string]interface{}, changed map[string]interface{}) error {
+	if err := validatePerfDateRange(data, changed); err != nil {
+		return err
+	}
+	if _, nameChanged := changed["name"]; changed != nil && !nameChanged {
+		if _, objectiveChanged := changed["objective"]; !objectiveChanged {
+			return nil
+		}
+	}
+	if strings.TrimSpace(toString(data["name"])) == "" && strings.TrimSpace(toString(data["objective"])) == "" {
+		return fmt.Errorf("name or objective is required")
+	}
+	return nil
+}
+
+// migratePerfData maps a record's data onto its entity's schema. Fields the schema does not know
+// and values of the wrong type are moved to legacyFields; the names of the moved fields are returned.
+func migratePerfData(entityType string, data map[string]interface{}) (map[string]interface{}, []string) {
+	schema := perfSchemas[entityType]
+	if schema == nil {
+		return data, nil
+	}
+	clean := map[string]interface{}{}
+	legacy := map[string]interface{}{}
+	if existing, ok := data["legacyFields"].(map[string]interface{}); ok {
+		for key, value := range existing {
+			legacy[key] = value
+		}
+	}
+	moved := []string{}
+	for key, value := range data {
+		if key == "legacyFields" || value == nil {
+			continue
+		}
+		if field, ok := schema.fields[key]; ok {
+			if n, isNumber := value.(float64); isNumber && field.kind == perfKindString {
+				// e.g. a fiscalYear sent as 2027
+				value = strconv.FormatFloat(n, 'f', -1, 64)
+			}
+			if converted, err := field.convert(value); err == nil {
+				clean[key] = converted
+				continue
+			}
+		}
+		legacy[key] = value
+		moved = append(moved, key)
+	}
+	if len(legacy) > 0 {
+		clean["legacyFields"] = legacy
+	}
+	sort.Strings(moved)
+	return clean, moved
+}
+
+// perfRecordIdentity is the data the record's keys say it should carry. Clients could overwrite
+// these fields before the schemas existed.
+func perfRecordIdentity(rec *PerformanceRecord) map[string]string {
+	identity := map[string]string{}
+	if i := strings.LastIndex(rec.GSI1PK, "#"); i >= 0 {
+		identity["id"] = rec.GSI1PK[i+1:]
+	}
+	switch rec.EntityType {
+	case perfEntityQuarter:
+		identity["cycleId"] = rec.CycleId
+	case perfEntityKPI:
+		identity["cycleId"] = rec.CycleId
+		identity["quarterId"] = rec.QuarterId
+		identity["parentKpiId"] = rec.ParentId
+	case perfEntityOKR, perfEntityMeeting:
+		identity["cycleId"] = rec.CycleId
+		identity["quarterId"] = rec.QuarterId
+	case perfEntityKeyResult:
+		identity["okrId"] = rec.ParentId
+	case perfEntityGoalSub:
+		identity["parentGoalId"] = rec.ParentId
+	case perfEntityGoalTask:
+		identity["goalId"] = rec.ParentId
+		identity["userId"] = rec.Owner
+	}
+	return identity
+}
+
+// migratePerfRecord brings a record's data to the current schema version and restores its
+// identity fields. It returns the fields moved to legacyFields and the identity fields restored.
+func migratePerfRecord(rec *PerformanceRecord) ([]string, []string) {
+	data, moved := migratePerfData(rec.EntityType, rec.Data)
+	repaired := []string{}
+	identity := perfRecordIdentity(rec)
+	if _, ok := data["organizationId"]; ok {
+		identity["organizationId"] = rec.OrganizationId
+	}
+	names := make([]string, 0, len(identity))
+	for name := range identity {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := identity[name]
+		switch {
+		case value == "" && hasValue(data, name):
+			delete(data, name)
+		case value == "" || toString(data[name]) == value:
+			continue
+		default:
+			data[name] = value
+		}
+		repaired = append(repaired, name)
+	}
+	rec.Data = data
+	rec.SchemaVersion = perfDataSchemaVersion
+	return moved, repaired
+}
+
+// preparePerfPatch migrates the record's data if needed and checks a patch against the record's
+// schema. Cleared fields are removed from the record; the returned changes go to patchRecord.
+func preparePerfPatch(rec *PerformanceRecord, patch map[string]interface{}) (map[string]interface{}, error) {
+	if rec.SchemaVersion < perfDataSchemaVersion {
+		migratePerfRecord(rec)
+	}
+	changes, err := decodePerfInput(rec.EntityType, patch, rec.Data)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range changes {
+		if value != nil {
+			continue
+		}
+		delete(rec.Data, key)
+		delete(changes, key)
+		if key == "status" {
+			rec.Status = ""
+		}
+		if key == "owner" {
+			rec.Owner = ""
+		}
+	}
+	return changes, nil
+}
+
+// patchTypedRecord applies a checked patch to a record of an entity with a schema
+func (svc *PerformanceService) patchTypedRecord(rec *PerformanceRecord, patch map[string]interface{}) (*PerformanceRecord, error) {
+	changes, err := preparePerfPatch(rec, patch)
+	if err != nil {
+		return nil, err
+	}
+	return svc.patchRecord(rec, changes)
+}
+
+// currentPerfData is a record's data on the current schema with legacy fields left out, for
+// copying it into a new record
+func currentPerfData(rec *PerformanceRecord) map[string]interface{} {
+	copied := *rec
+	copied.Data = copyData(rec.Data)
+	migratePerfRecord(&copied)
+	delete(copied.Data, "legacyFields")
+	return copied.Data
+}
+
+// schemaPerfData is data on the entity's schema with what does not fit left out, for copying
+// stored template content into new records
+func schemaPerfData(entityType string, data map[string]interface{}) map[string]interface{} {
+	clean, _ := migratePerfData(entityType, data)
+	delete(clean, "legacyFields")
+	return clean
+}
+
+// DecodePerfData reads a record's data into its typed struct, e.g. a KPI's into KPIData
+func DecodePerfData(data map[string]interface{}, out interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal performance data: %w", err)
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return fmt.Errorf("%w: %s must be %s", ErrPerfDataInvalid, typeErr.Field, typeErr.Type)
+		}
+		return fmt.Errorf("%w: %v", ErrPerfDataInvalid, err)
+	}
+	return nil
+}
+
+// PerfDataMigration reports what MigratePerformanceData changed, or would change on a dry run
+type PerfDataMigration struct {
+	DryRun         bool           `json:"dryRun"`
+	Scanned        int            `json:"scanned"`
+	Migrated       int            `json:"migrated"`
+	Current        int            `json:"current"`
+	Skipped        int            `json:"skipped"`
+	ByEntity       map[string]int `json:"byEntity"`
+	MovedFields    map[string]int `json:"movedFields"`
+	RepairedFields map[string]int `json:"repairedFields"`
+}
+
+// MigratePerformanceData brings an organization's performance records to the current schema
+// version. A record changed while the migration ran is skipped and migrates on its next write
+// or the next run.
+func (svc *PerformanceService) MigratePerformanceData(orgID string, dryRun bool) (*PerfDataMigration, error) {
+	records, err := svc.queryByOrgPrefix(orgID, perfSKPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &PerfDataMigration{DryRun: dryRun, ByEntity: map[string]int{}, MovedFields: map[string]int{}, RepairedFields: map[string]int{}}
+	for i := range records {
+		rec := &records[i]
+		if perfSchemas[rec.EntityType] == nil {
+			continue
+		}
+		report.Scanned++
+		if rec.SchemaVersion >= perfDataSchemaVersion {
+			report.Current++
+			continue
+		}
+		moved, repaired := migratePerfRecord(rec)
+		if !dryRun {
+			if err := svc.putMigratedRecord(rec); err != nil {
+				if errors.Is(err, errPerfRecordChanged) {
+					report.Skipped++
+					continue
+				}
+				return nil, err
+			}
+		}
+		report.Migrated++
+		report.ByEntity[rec.EntityType]++
+		for _, field := range moved {
+			report.MovedFields[field]++
+		}
+		for _, field := range repaired {
+			report.RepairedFields[field]++
+		}
+	}
+	return report, nil
+}
+
+var errPerfRecordChanged = errors.New("performance record changed during migration")
+
+// putMigratedRecord writes a migrated record unless it was updated after it was read
+func (svc *PerformanceService) putMigratedRecord(rec *PerformanceRecord) error {
+	item, err := attributevalue.MarshalMap(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+	_, err = svc.dynamodbClient.PutItem(svc.ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(svc.performanceTableName()),
+		Item:                item,
+		ConditionExpression: aws.String("UpdatedAt = :updatedAt"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":updatedAt": &types.AttributeValueMemberS{Value: rec.UpdatedAt},
+		},
+	})
+	if err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return errPerfRecordChanged
+		}
+		return fmt.Errorf("failed to put record: %w", err)
+	}
+	return nil
+}
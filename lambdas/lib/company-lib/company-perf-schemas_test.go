@@ -0,0 +1,197 @@
+package Companylib
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodb_types "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	awsclients "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/clients"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodePerfInput(t *testing.T) {
+	t.Run("It should keep what the schema allows and ignore server-owned fields", func(t *testing.T) {
+		data, err := decodePerfInput(perfEntityKPI, map[string]interface{}{
+			"cycleId": "c-1", "name": "Revenue", "owner": "ann@example.com", "targetValue": "100",
+			"reportingFrequency": "Monthly", "tags": []interface{}{"sales"},
+			"id": "kpi-evil", "organizationId": "ORG#other", "createdAt": "2020-01-01", "ragStatus": "GREEN", "subKPIs": []interface{}{},
+		}, nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"cycleId": "c-1", "name": "Revenue", "owner": "ann@example.com", "targetValue": 100.0,
+			"reportingFrequency": "monthly", "tags": []interface{}{"sales"},
+		}, data)
+	})
+
+	t.Run("It should reject unknown fields, wrong types and missing required fields", func(t *testing.T) {
+		_, err := decodePerfInput(perfEntityKPI, map[string]interface{}{
+			"name": 42.0, "targetValue": "lots", "junk": true, "startDate": "01/02/2027",
+		}, nil)
+
+		assert.ErrorIs(t, err, ErrPerfDataInvalid)
+		assert.ErrorContains(t, err, "unknown fields: junk")
+		assert.ErrorContains(t, err, "name must be a string")
+		assert.ErrorContains(t, err, "targetValue must be a number")
+		assert.ErrorContains(t, err, "startDate must be YYYY-MM-DD")
+		assert.ErrorContains(t, err, "owner is required")
+	})
+
+	t.Run("It should bound custom fields", func(t *testing.T) {
+		_, err := decodePerfInput(perfEntityGoalTask, map[string]interface{}{
+			"title": "Prepare QBR", "customFields": map[string]interface{}{"nested": map[string]interface{}{}},
+		}, nil)
+
+		assert.ErrorContains(t, err, "customFields.nested must be a string, number or boolean")
+	})
+
+	t.Run("It should not let a patch move a record or drop a required field", func(t *testing.T) {
+		current := map[string]interface{}{"id": "kpi-1", "cycleId": "c-1", "name": "Revenue", "owner": "ann@example.com", "targetValue": 100.0}
+
+		_, err := decodePerfInput(perfEntityKPI, map[string]interface{}{"cycleId": "c-2"}, current)
+		assert.ErrorContains(t, err, "cycleId cannot be changed")
+
+		_, err = decodePerfInput(perfEntityKPI, map[string]interface{}{"quarterId": "q-1"}, current)
+		assert.ErrorContains(t, err, "quarterId can only be set when the record is created")
+
+		_, err = decodePerfInput(perfEntityKPI, map[string]interface{}{"owner": nil}, current)
+		assert.ErrorContains(t, err, "owner is required")
+
+		changes, err := decodePerfInput(perfEntityKPI, map[string]interface{}{"cycleId": "c-1", "description": nil, "id": "kpi-2"}, current)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"description": nil}, changes)
+	})
+
+	t.Run("It should check dates only when a patch changes them", func(t *testing.T) {
+		current := map[string]interface{}{"name": "FY27", "startDate": "2027-12-31", "endDate": "2027-01-01"}
+
+		_, err := decodePerfInput(perfEntityCycle, map[string]interface{}{"description": "Old dates"}, current)
+		assert.NoError(t, err)
+
+		_, err = decodePerfInput(perfEntityCycle, map[string]interface{}{"endDate": "2027-06-30"}, current)
+		assert.ErrorContains(t, err, "endDate must be on or after startDate")
+	})
+
+	t.Run("It should need an OKR to have a name or an objective", func(t *testing.T) {
+		_, err := decodePerfInput(perfEntityOKR, map[string]interface{}{"cycleId": "c-1"}, nil)
+		assert.ErrorContains(t, err, "name or objective is required")
+
+		_, err = decodePerfInput(perfEntityOKR, map[string]interface{}{"name": nil}, map[string]interface{}{"name": "Grow", "objective": "Grow revenue"})
+		assert.NoError(t, err)
+	})
+}
+
+func TestMigratePerfRecord(t *testing.T) {
+	rec := PerformanceRecord{
+		GSI1PK: "PERF#KPI#kpi-1", EntityType: perfEntityKPI, OrganizationId: "ORG#org-1", CycleId: "c-1",
+		Data: map[string]interface{}{
+			"id": "kpi-hijacked", "organizationId": "ORG#other", "cycleId": "c-1", "parentKpiId": "kpi-9",
+			"name": "Revenue", "targetValue": "100", "weight": "heavy", "colour": "blue",
+			"legacyFields": map[string]interface{}{"note": "from an earlier run"},
+		},
+	}
+
+	moved, repaired := migratePerfRecord(&rec)
+
+	assert.Equal(t, []string{"colour", "weight"}, moved)
+	assert.Equal(t, []string{"id", "organizationId", "parentKpiId"}, repaired)
+	assert.Equal(t, perfDataSchemaVersion, rec.SchemaVersion)
+	assert.Equal(t, map[string]interface{}{
+		"id": "kpi-1", "organizationId": "ORG#org-1", "cycleId": "c-1", "name": "Revenue", "targetValue": 100.0,
+		"legacyFields": map[string]interface{}{"note": "from an earlier run", "weight": "heavy", "colour": "blue"},
+	}, rec.Data)
+}
+
+func TestUpdatePerformanceCycleSchema(t *testing.T) {
+	legacy := PerformanceRecord{
+		PK: "ORG#org-1", SK: "PERF#CYCLE#c-1", GSI1PK: "PERF#CYCLE#c-1", EntityType: perfEntityCycle, OrganizationId: "ORG#org-1",
+		Data: map[string]interface{}{"id": "c-1", "name": "FY27", "description": "Old", "sponsor": "ceo@example.com"},
+	}
+
+	t.Run("It should migrate the record and keep server-owned fields", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs:   []dynamodb.QueryOutput{reviewQueryOutput(t, legacy)},
+			QueryErrors:    []error{nil},
+			PutItemOutputs: []dynamodb.PutItemOutput{{}},
+			PutItemErrors:  []error{nil},
+		}
+		svc := newOKRTestService(&ddbClient)
+
+		res, err := svc.UpdatePerformanceCycle("c-1", map[string]interface{}{"id": "c-2", "organizationId": "ORG#other", "description": nil, "status": "started"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "c-1", res["id"])
+		assert.Equal(t, "ORG#org-1", res["organizationId"])
+		assert.NotContains(t, res, "description")
+		stored := recordFromPut(t, ddbClient.PutItemInputs[0])
+		assert.Equal(t, "STARTED", stored.Status)
+		assert.Equal(t, perfDataSchemaVersion, stored.SchemaVersion)
+		assert.Equal(t, map[string]interface{}{"sponsor": "ceo@example.com"}, stored.Data["legacyFields"])
+	})
+
+	t.Run("It should reject fields the schema does not know", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs: []dynamodb.QueryOutput{reviewQueryOutput(t, legacy)},
+			QueryErrors:  []error{nil},
+		}
+		svc := newOKRTestService(&ddbClient)
+
+		_, err := svc.UpdatePerformanceCycle("c-1", map[string]interface{}{"sponsor": "cfo@example.com"})
+
+		assert.ErrorIs(t, err, ErrPerfDataInvalid)
+		assert.Empty(t, ddbClient.PutItemInputs)
+	})
+}
+
+func TestMigratePerformanceData(t *testing.T) {
+	legacy := PerformanceRecord{
+		PK: "ORG#org-1", SK: "PERF#CYCLE#c-1#QUARTER#q-1", GSI1PK: "PERF#QUARTER#q-1", EntityType: perfEntityQuarter,
+		OrganizationId: "ORG#org-1", CycleId: "c-1", QuarterId: "q-1", UpdatedAt: "2027-01-01T00:00:00Z",
+		Data: map[string]interface{}{"id": "q-1", "cycleId": "c-9", "name": "Q1", "theme": "growth"},
+	}
+	current := PerformanceRecord{
+		PK: "ORG#org-1", SK: "PERF#CYCLE#c-1", GSI1PK: "PERF#CYCLE#c-1", EntityType: perfEntityCycle, OrganizationId: "ORG#org-1",
+		SchemaVersion: perfDataSchemaVersion, Data: map[string]interface{}{"id": "c-1", "name": "FY27"},
+	}
+	value := PerformanceRecord{PK: "ORG#org-1", SK: "PERF#CYCLE#c-1#KPI#k-1#VALUE#v-1", EntityType: perfEntityKPIValue, Data: map[string]interface{}{"value": 1.0}}
+
+	t.Run("It should report without writing on a dry run", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs: []dynamodb.QueryOutput{reviewQueryOutput(t, legacy, current, value)},
+			QueryErrors:  []error{nil},
+		}
+		svc := newOKRTestService(&ddbClient)
+
+		report, err := svc.MigratePerformanceData("org-1", true)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &PerfDataMigration{
+			DryRun: true, Scanned: 2, Migrated: 1, Current: 1,
+			ByEntity:       map[string]int{perfEntityQuarter: 1},
+			MovedFields:    map[string]int{"theme": 1},
+			RepairedFields: map[string]int{"cycleId": 1},
+		}, report)
+		assert.Empty(t, ddbClient.PutItemInputs)
+	})
+
+	t.Run("It should write records that have not changed since they were read", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs:   []dynamodb.QueryOutput{reviewQueryOutput(t, legacy, legacy)},
+			QueryErrors:    []error{nil},
+			PutItemOutputs: []dynamodb.PutItemOutput{{}, {}},
+			PutItemErrors:  []error{nil, &dynamodb_types.ConditionalCheckFailedException{}},
+		}
+		svc := newOKRTestService(&ddbClient)
+
+		report, err := svc.MigratePerformanceData("org-1", false)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, report.Migrated)
+		assert.Equal(t, 1, report.Skipped)
+		assert.Equal(t, "UpdatedAt = :updatedAt", aws.ToString(ddbClient.PutItemInputs[0].ConditionExpression))
+		stored := recordFromPut(t, ddbClient.PutItemInputs[0])
+		assert.Equal(t, "c-1", stored.Data["cycleId"])
+		assert.Equal(t, perfDataSchemaVersion, stored.SchemaVersion)
+	})
+}
@@ -0,0 +1,551 @@
+package Companylib
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A performance template is a reusable, org-level set of KPIs and OKRs ("Sales KPIs") that can be
+// applied to any cycle. Templates carry no dates, values or IDs: each KPI has a ref, and sub-KPIs
+// point at their parent through parentRef, which is turned into parentKpiId when the template is
+// applied. Applying a template runs as a copy job (see company-perf-copy-jobs.go).
+
+const (
+	perfEntityTemplate = "PERF_TEMPLATE"
+
+	// perfTemplateMaxItems caps the KPIs, OKRs and key results in one template
+	perfTemplateMaxItems = 200
+)
+
+var (
+	ErrPerfTemplateInvalid  = errors.New("invalid performance template")
+	ErrPerfTemplateNotFound = errors.New("performance template not found")
+)
+
+// templateDropFields are tied to one cycle and are never kept in a template
+var templateDropFields = append(append([]string{}, cloneCycleIdentityFields...),
+	"parentKpiId", "currentValue", "clonedFromKpiId", "clonedFromOkrId", "templateId", "confidenceScore", "keyResults",
+	"startDate", "endDate", "dueDate", "deadline")
+
+// templateKeyResultDropFields are reset when a key result goes into a template
+var templateKeyResultDropFields = []string{"id", "okrId", "status", "createdAt", "updatedAt", "currentValue", "progress", "confidenceScore", "dueDate", "deadline"}
+
+// CreatePerformanceTemplate stores a template. Its KPIs and OKRs come from the input's kpis and
+// okrs, or are copied from the cycle named by sourceCycleId, optionally narrowed to kpiIds and okrIds.
+func (svc *PerformanceService) CreatePerformanceTemplate(orgID string, input map[string]interface{}, createdBy string) (map[string]interface{}, error) {
+	orgID = svc.normalizeOrgID(orgID)
+	name := strings.TrimSpace(toString(input["name"]))
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrPerfTemplateInvalid)
+	}
+	if len(name) > 200 {
+		return nil, fmt.Errorf("%w: name must be <= 200 characters", ErrPerfTemplateInvalid)
+	}
+
+	data := map[string]interface{}{}
+	if sourceCycleID := toString(input["sourceCycleId"]); sourceCycleID != "" {
+		kpis, okrs, err := svc.templateContentFromCycle(orgID, sourceCycleID, stringSet(input["kpiIds"]), stringSet(input["okrIds"]))
+		if err != nil {
+			return nil, err
+		}
+		input["kpis"], input["okrs"] = kpis, okrs
+		data["sourceCycleId"] = sourceCycleID
+	}
+	if err := svc.setTemplateContent(data, input); err != nil {
+		return nil, err
+	}
+
+	templateID := svc.generateID("template")
+	now := svc.now()
+	data["id"] = templateID
+	data["name"] = name
+	data["description"] = strings.TrimSpace(toString(input["description"]))
+	data["organizationId"] = orgID
+	data["createdBy"] = createdBy
+	data["createdAt"] = now
+	data["updatedAt"] = now
+
+	record := PerformanceRecord{
+		PK:             orgID,
+		SK:             fmt.Sprintf("%sTEMPLATE#%s", perfSKPrefix, templateID),
+		GSI1PK:         fmt.Sprintf("%sTEMPLATE#%s", perfSKPrefix, templateID),
+		GSI1SK:         orgID,
+		EntityType:     perfEntityTemplate,
+		OrganizationId: orgID,
+		Owner:          createdBy,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		Data:           data,
+	}
+	if err := svc.putRecord(record); err != nil {
+		return nil, err
+	}
+	return svc.toPayload(&record), nil
+}
+
+// ListPerformanceTemplates returns the organization's templates by name
+func (svc *PerformanceService) ListPerformanceTemplates(orgID string) ([]map[string]interface{}, error) {
+	records, err := svc.queryByOrgPrefix(svc.normalizeOrgID(orgID), perfSKPrefix+"TEMPLATE#")
+	if err != nil {
+		return nil, err
+	}
+	templates := []map[string]interface{}{}
+	for i := range records {
+		if records[i].EntityType == perfEntityTemplate {
+			templates = append(templates, svc.toPayload(&records[i]))
+		}
+	}
+	sort.SliceStable(templates, func(i, j int) bool {
+		return strings.ToLower(toString(templates[i]["name"])) < strings.ToLower(toString(templates[j]["name"]))
+	})
+	return templates, nil
+}
+
+// GetPerformanceTemplate returns a template by ID
+func (svc *PerformanceService) GetPerformanceTemplate(templateID string) (map[string]interface{}, error) {
+	rec, err := svc.getTemplateRecord(templateID)
+	if err != nil {
+		return nil, err
+	}
+	return svc.toPayload(rec), nil
+}
+
+func (svc *PerformanceService) getTemplateRecord(templateID string) (*PerformanceRecord, error) {
+	rec, err := svc.getRecordByGSI1(perfSKPrefix + "TEMPLATE#" + templateID)
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil || rec.EntityType != perfEntityTemplate {
+		return nil, ErrPerfTemplateNotFound
+	}
+	return rec, nil
+}
+
+// UpdatePerformanceTemplate changes a template's name, description, kpis or okrs
+func (svc *PerformanceService) UpdatePerformanceTemplate(templateID string, input map[string]interface{}) (map[string]interface{}, error) {
+	rec, err := svc.getTemplateRecord(templateID)
+	if err != nil {
+		return nil, err
+	}
+	patch := map[string]interface{}{}
+	if _, ok := input["name"]; ok {
+		name := strings.TrimSpace(toString(input["name"]))
+		if name == "" || len(name) > 200 {
+			return nil, fmt.Errorf("%w: name is required and must be <= 200 characters", ErrPerfTemplateInvalid)
+		}
+		patch["name"] = name
+	}
+	if _, ok := input["description"]; ok {
+		patch["description"] = strings.TrimSpace(toString(input["description"]))
+	}
+	_, hasKPIs := input["kpis"]
+	_, hasOKRs := input["okrs"]
+	if hasKPIs || hasOKRs {
+		content := map[string]interface{}{"kpis": rec.Data["kpis"], "okrs": rec.Data["okrs"]}
+		if hasKPIs {
+			content["kpis"] = input["kpis"]
+		}
+		if hasOKRs {
+			content["okrs"] = input["okrs"]
+		}
+		if err := svc.setTemplateContent(patch, content); err != nil {
+			return nil, err
+		}
+	}
+
+	updated, err := svc.patchRecord(rec, patch)
+	if err != nil {
+		return nil, err
+	}
+	return svc.toPayload(updated), nil
+}
+
+// DeletePerformanceTemplate removes a template; cycles it was applied to keep their KPIs and OKRs
+func (svc *PerformanceService) DeletePerformanceTemplate(templateID string) error {
+	rec, err := svc.getTemplateRecord(templateID)
+	if err != nil {
+		return err
+	}
+	return svc.deleteRecord(rec)
+}
+
+// setTemplateContent validates the kpis and okrs of input and stores them on data
+func (svc *PerformanceService) setTemplateContent(data map[string]interface{}, input map[string]interface{}) error {
+	kpis, err := svc.normalizeTemplateKPIs(toMapSlice(input["kpis"]))
+	if err != nil {
+		return err
+	}
+	okrs, keyResults, err := normalizeTemplateOKRs(toMapSlice(input["okrs"]))
+	if err != nil {
+		return err
+	}
+	items := len(kpis) + len(okrs) + keyResults
+	if items == 0 {
+		return fmt.Errorf("%w: a template needs at least one KPI or OKR", ErrPerfTemplateInvalid)
+	}
+	if items > perfTemplateMaxItems {
+		return fmt.Errorf("%w: a template holds at most %d KPIs, OKRs and key results", ErrPerfTemplateInvalid, perfTemplateMaxItems)
+	}
+	data["kpis"] = kpis
+	data["okrs"] = okrs
+	data["itemCount"] = items
+	return nil
+}
+
+// normalizeTemplateKPIs validates template KPIs and orders them so parents come before their sub-KPIs
+func (svc *PerformanceService) normalizeTemplateKPIs(raw []map[string]interface{}) ([]map[string]interface{}, error) {
+	kpis := make([]map[string]interface{}, 0, len(raw))
+	refs := map[string]map[string]interface{}{}
+	for i, item := range raw {
+		kpi := copyData(item, append(append([]string{}, templateDropFields...), append(kpiComputedFields, kpiSystemFields...)...)...)
+		ref := strings.TrimSpace(toString(kpi["ref"]))
+		if ref == "" {
+			ref = fmt.Sprintf("kpi-%d", i+1)
+		}
+		if refs[ref] != nil {
+			return nil, fmt.Errorf("%w: KPI ref %s is used twice", ErrPerfTemplateInvalid, ref)
+		}
+		kpi["ref"] = ref
+		if strings.TrimSpace(toString(kpi["parentRef"])) == "" {
+			delete(kpi, "parentRef")
+		}
+
+		// the owner is chosen when the template is applied
+		check := copyData(kpi, "ref", "parentRef")
+		placeholderOwner := toString(check["owner"]) == ""
+		if placeholderOwner {
+			check["owner"] = "template"
+		}
+		check, err := decodePerfInput(perfEntityKPI, check, nil)
+		if err == nil {
+			err = svc.validateKPIInput(check)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: KPI %s: %v", ErrPerfTemplateInvalid, ref, err)
+		}
+		if placeholderOwner {
+			delete(check, "owner")
+		}
+		check["ref"] = ref
+		if parentRef := kpi["parentRef"]; parentRef != nil {
+			check["parentRef"] = parentRef
+		}
+		kpi = check
+		refs[ref] = kpi
+		kpis = append(kpis, kpi)
+	}
+
+	ordered := make([]map[string]interface{}, 0, len(kpis))
+	placed := map[string]bool{}
+	var place func(kpi map[string]interface{}, path map[string]bool) error
+	place = func(kpi map[string]interface{}, path map[string]bool) error {
+		ref := toString(kpi["ref"])
+		if placed[ref] {
+			return nil
+		}
+		if path[ref] {
+			return fmt.Errorf("%w: KPI %s is its own ancestor", ErrPerfTemplateInvalid, ref)
+		}
+		path[ref] = true
+		if parentRef := toString(kpi["parentRef"]); parentRef != "" {
+			parent := refs[parentRef]
+			if parent == nil {
+				return fmt.Errorf("%w: KPI %s has unknown parentRef %s", ErrPerfTemplateInvalid, ref, parentRef)
+			}
+			if err := place(parent, path); err != nil {
+				return err
+			}
+		}
+		placed[ref] = true
+		ordered = append(ordered, kpi)
+		return nil
+	}
+	for _, kpi := range kpis {
+		if err := place(kpi, map[string]bool{}); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// normalizeTemplateOKRs validates template OKRs and their key results, returning the key result count
+func normalizeTemplateOKRs(raw []map[string]interface{}) ([]map[string]interface{}, int, error) {
+	okrs := make([]map[string]interface{}, 0, len(raw))
+	count := 0
+	for i, item := range raw {
+		okr, err := decodePerfInput(perfEntityOKR, copyData(item, append(append([]string{}, templateDropFields...), okrComputedFields...)...), nil)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w: OKR %d: %v", ErrPerfTemplateInvalid, i+1, err)
+		}
+		keyResults := []map[string]interface{}{}
+		for j, rawKR := range toMapSlice(item["keyResults"]) {
+			kr, err := decodePerfInput(perfEntityKeyResult, copyData(rawKR, templateKeyResultDropFields...), nil)
+			if err == nil {
+				err = normalizeKeyResult(copyData(kr))
+			}
+			if err != nil {
+				return nil, 0, fmt.Errorf("%w: OKR %d key result %d: %v", ErrPerfTemplateInvalid, i+1, j+1, err)
+			}
+			keyResults = append(keyResults, kr)
+		}
+		okr["keyResults"] = keyResults
+		count += len(keyResults)
+		okrs = append(okrs, okr)
+	}
+	return okrs, count, nil
+}
+
+// templateContentFromCycle copies a cycle's KPIs and OKRs into template form. Empty filters copy everything.
+func (svc *PerformanceService) templateContentFromCycle(orgID string, cycleID string, kpiIDs map[string]bool, okrIDs map[string]bool) ([]interface{}, []interface{}, error) {
+	cycle, err := svc.getRecordByGSI1(perfSKPrefix + "CYCLE#" + cycleID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cycle == nil || cycle.OrganizationId != orgID {
+		return nil, nil, fmt.Errorf("%w: performance cycle %s not found", ErrPerfTemplateInvalid, cycleID)
+	}
+	related, err := svc.queryByOrgPrefix(orgID, fmt.Sprintf("%sCYCLE#%s#", perfSKPrefix, cycleID))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	kpis := []interface{}{}
+	okrs := []interface{}{}
+	keyResults := map[string][]interface{}{}
+	for _, rec := range related {
+		if rec.EntityType == perfEntityKeyResult {
+			keyResults[rec.ParentId] = append(keyResults[rec.ParentId], currentPerfData(&rec))
+		}
+	}
+	for _, rec := range related {
+		id := toString(rec.Data["id"])
+		switch rec.EntityType {
+		case perfEntityKPI:
+			if len(kpiIDs) > 0 && !kpiIDs[id] {
+				continue
+			}
+			kpi := currentPerfData(&rec)
+			kpi["ref"] = id
+			if rec.ParentId != "" && (len(kpiIDs) == 0 || kpiIDs[rec.ParentId]) {
+				kpi["parentRef"] = rec.ParentId
+			}
+			kpis = append(kpis, kpi)
+		case perfEntityOKR:
+			if len(okrIDs) > 0 && !okrIDs[id] {
+				continue
+			}
+			okr := currentPerfData(&rec)
+			okr["keyResults"] = keyResults[id]
+			okrs = append(okrs, okr)
+		}
+	}
+	return kpis, okrs, nil
+}
+
+// ApplyTemplate adds a template's KPIs and OKRs to a cycle. The input takes templateId, optionally
+// quarterId to place them in one quarter and owner for KPIs and OKRs the template leaves unowned.
+// Each chunk of the copy job appears in the cycle as it is written.
+func (svc *PerformanceService) ApplyTemplate(cycleID string, input map[string]interface{}, createdBy string) (map[string]interface{}, error) {
+	templateID := toString(input["templateId"])
+	if templateID == "" {
+		return nil, fmt.Errorf("%w: templateId is required", ErrCopyJobInvalid)
+	}
+	template, err := svc.getTemplateRecord(templateID)
+	if err != nil {
+		return nil, err
+	}
+	cycle, err := svc.getRecordByGSI1(perfSKPrefix + "CYCLE#" + cycleID)
+	if err != nil {
+		return nil, err
+	}
+	if cycle == nil || cycle.EntityType != perfEntityCycle {
+		return nil, fmt.Errorf("%w: performance cycle not found", ErrCopyJobNotFound)
+	}
+	if cycle.OrganizationId != template.OrganizationId {
+		return nil, ErrPerfTemplateNotFound
+	}
+	if isClosedPerformanceStatus(cycle.Status) {
+		return nil, fmt.Errorf("%w: templates cannot be applied to a %s cycle", ErrCopyJobInvalid, cycle.Status)
+	}
+	quarterID := toString(input["quarterId"])
+	if quarterID != "" {
+		quarter, err := svc.getRecordByGSI1(perfSKPrefix + "QUARTER#" + quarterID)
+		if err != nil {
+			return nil, err
+		}
+		if quarter == nil || quarter.CycleId != cycleID {
+			return nil, fmt.Errorf("%w: quarter %s is not part of the cycle", ErrCopyJobInvalid, quarterID)
+		}
+	}
+
+	records, counts, err := svc.planTemplateApply(template, cycle, quarterID, strings.TrimSpace(toString(input["owner"])))
+	if err != nil {
+		return nil, err
+	}
+	return svc.startCopyJob(cycle.OrganizationId, CopyJobTypeApplyTemplate, map[string]interface{}{
+		"templateId":    templateID,
+		"targetCycleId": cycleID,
+	}, records, counts, createdBy)
+}
+
+// planTemplateApply builds the KPI, OKR and key result records a template adds to a cycle
+func (svc *PerformanceService) planTemplateApply(template *PerformanceRecord, cycle *PerformanceRecord, quarterID string, owner string) ([]PerformanceRecord, CopyCounts, error) {
+	var counts CopyCounts
+	orgID := cycle.OrganizationId
+	cycleID := toString(cycle.Data["id"])
+	templateID := toString(template.Data["id"])
+	now := svc.now()
+	records := []PerformanceRecord{}
+
+	kpiIDs := map[string]string{}
+	for _, item := range toMapSlice(template.Data["kpis"]) {
+		ref := toString(item["ref"])
+		kpiID := svc.generateID("kpi")
+		kpiIDs[ref] = kpiID
+		data := schemaPerfData(perfEntityKPI, copyData(item, "ref", "parentRef"))
+		if toString(data["owner"]) == "" {
+			data["owner"] = owner
+		}
+		if err := svc.validateKPIInput(data); err != nil {
+			return nil, counts, fmt.Errorf("%w: KPI %s: %v", ErrCopyJobInvalid, toString(item["name"]), err)
+		}
+		parentID := kpiIDs[toString(item["parentRef"])]
+		data["id"] = kpiID
+		data["cycleId"] = cycleID
+		data["organizationId"] = orgID
+		data["status"] = "PLANNING"
+		data["templateId"] = templateID
+		data["createdAt"] = now
+		data["updatedAt"] = now
+		if parentID != "" {
+			data["parentKpiId"] = parentID
+		}
+		if quarterID != "" {
+			data["quarterId"] = quarterID
+		}
+		records = append(records, PerformanceRecord{
+			PK:             orgID,
+			SK:             fmt.Sprintf("%sCYCLE#%s#KPI#%s", perfSKPrefix, cycleID, kpiID),
+			GSI1PK:         fmt.Sprintf("%sKPI#%s", perfSKPrefix, kpiID),
+			GSI1SK:         fmt.Sprintf("%s#CYCLE#%s", orgID, cycleID),
+			EntityType:     perfEntityKPI,
+			OrganizationId: orgID,
+			CycleId:        cycleID,
+			QuarterId:      quarterID,
+			ParentId:       parentID,
+			Owner:          toString(data["owner"]),
+			Status:         "PLANNING",
+			CreatedAt:      now,
+			UpdatedAt:      now,
+			SchemaVersion:  perfDataSchemaVersion,
+			Data:           data,
+		})
+		counts.KPIs++
+	}
+
+	for _, item := range toMapSlice(template.Data["okrs"]) {
+		okrID := svc.generateID("okr")
+		data := schemaPerfData(perfEntityOKR, copyData(item, "keyResults"))
+		if toString(data["owner"]) == "" && owner != "" {
+			data["owner"] = owner
+		}
+		krRecords := []PerformanceRecord{}
+		krs := []map[string]interface{}{}
+		for _, rawKR := range toMapSlice(item["keyResults"]) {
+			krID := svc.generateID("kr")
+			kr := schemaPerfData(perfEntityKeyResult, rawKR)
+			if err := normalizeKeyResult(kr); err != nil {
+				return nil, counts, fmt.Errorf("%w: OKR %s: %v", ErrCopyJobInvalid, toString(item["name"]), err)
+			}
+			kr["id"] = krID
+			kr["okrId"] = okrID
+			kr["status"] = "ON_TRACK"
+			kr["createdAt"] = now
+			kr["updatedAt"] = now
+			krRecords = append(krRecords, PerformanceRecord{
+				PK:             orgID,
+				SK:             fmt.Sprintf("%sCYCLE#%s#OKR#%s#KR#%s", perfSKPrefix, cycleID, okrID, krID),
+				GSI1PK:         fmt.Sprintf("%sKEYRESULT#%s", perfSKPrefix, krID),
+				GSI1SK:         fmt.Sprintf("%s#OKR#%s", orgID, okrID),
+				EntityType:     perfEntityKeyResult,
+				OrganizationId: orgID,
+				CycleId:        cycleID,
+				QuarterId:      quarterID,
+				ParentId:       okrID,
+				Status:         "ON_TRACK",
+				CreatedAt:      now,
+				UpdatedAt:      now,
+				SchemaVersion:  perfDataSchemaVersion,
+				Data:           kr,
+			})
+			krs = append(krs, kr)
+		}
+		progress := rollUpOKRProgress(krs, nil)
+		data["id"] = okrID
+		data["cycleId"] = cycleID
+		data["organizationId"] = orgID
+		data["status"] = "DRAFT"
+		data["templateId"] = templateID
+		data["keyResults"] = krs
+		data["progress"] = progress.Progress
+		data["keyResultCount"] = len(krs)
+		data["progressUpdatedAt"] = now
+		data["createdAt"] = now
+		data["updatedAt"] = now
+		if quarterID != "" {
+			data["quarterId"] = quarterID
+		}
+		records = append(records, PerformanceRecord{
+			PK:             orgID,
+			SK:             fmt.Sprintf("%sCYCLE#%s#OKR#%s", perfSKPrefix, cycleID, okrID),
+			GSI1PK:         fmt.Sprintf("%sOKR#%s", perfSKPrefix, okrID),
+			GSI1SK:         fmt.Sprintf("%s#CYCLE#%s", orgID, cycleID),
+			EntityType:     perfEntityOKR,
+			OrganizationId: orgID,
+			CycleId:        cycleID,
+			QuarterId:      quarterID,
+			Owner:          toString(data["owner"]),
+			Status:         "DRAFT",
+			CreatedAt:      now,
+			UpdatedAt:      now,
+			SchemaVersion:  perfDataSchemaVersion,
+			Data:           data,
+		})
+		records = append(records, krRecords...)
+		counts.OKRs++
+		counts.KeyResults += len(krRecords)
+	}
+	return records, counts, nil
+}
+
+// toMapSlice reads a list of objects, whether decoded from JSON or built in Go
+func toMapSlice(v interface{}) []map[string]interface{} {
+	switch items := v.(type) {
+	case []map[string]interface{}:
+		return items
+	case []interface{}:
+		result := make([]map[string]interface{}, 0, len(items))
+		for _, item := range items {
+			if m, ok := item.(map[string]interface{}); ok {
+				result = append(result, m)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// stringSet reads a list of strings into a set
+func stringSet(v interface{}) map[string]bool {
+	set := map[string]bool{}
+	if items, ok := v.([]interface{}); ok {
+		for _, item := range items {
+			if s := strings.TrimSpace(toString(item)); s != "" {
+				set[s] = true
+			}
+		}
+	}
+	return set
+}
@@ -0,0 +1,92 @@
+package Companylib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeTemplateKPIs(t *testing.T) {
+	svc := newOKRTestService(nil)
+
+	t.Run("It should put parents before their sub-KPIs and strip cycle fields", func(t *testing.T) {
+		kpis, err := svc.normalizeTemplateKPIs([]map[string]interface{}{
+			{"ref": "emea", "parentRef": "revenue", "name": "EMEA revenue", "targetValue": 40.0},
+			{"ref": "revenue", "name": "Revenue", "targetValue": 100.0, "currentValue": 55.0, "id": "kpi-9", "quarterId": "q-1"},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "revenue", kpis[0]["ref"])
+		assert.Equal(t, "emea", kpis[1]["ref"])
+		for _, field := range []string{"currentValue", "id", "quarterId"} {
+			assert.NotContains(t, kpis[0], field)
+		}
+	})
+
+	t.Run("It should reject unknown and circular parents", func(t *testing.T) {
+		_, err := svc.normalizeTemplateKPIs([]map[string]interface{}{
+			{"ref": "a", "parentRef": "missing", "name": "A", "targetValue": 1.0},
+		})
+		assert.ErrorIs(t, err, ErrPerfTemplateInvalid)
+
+		_, err = svc.normalizeTemplateKPIs([]map[string]interface{}{
+			{"ref": "a", "parentRef": "b", "name": "A", "targetValue": 1.0},
+			{"ref": "b", "parentRef": "a", "name": "B", "targetValue": 1.0},
+		})
+		assert.ErrorIs(t, err, ErrPerfTemplateInvalid)
+	})
+
+	t.Run("It should validate each KPI", func(t *testing.T) {
+		_, err := svc.normalizeTemplateKPIs([]map[string]interface{}{{"name": "No target"}})
+
+		assert.ErrorIs(t, err, ErrPerfTemplateInvalid)
+	})
+}
+
+func TestPlanTemplateApply(t *testing.T) {
+	svc := newOKRTestService(nil)
+	template := &PerformanceRecord{
+		EntityType: perfEntityTemplate, OrganizationId: "ORG#org-1",
+		Data: map[string]interface{}{
+			"id": "template-1",
+			"kpis": []interface{}{
+				map[string]interface{}{"ref": "revenue", "name": "Revenue", "targetValue": 100.0},
+				map[string]interface{}{"ref": "emea", "parentRef": "revenue", "name": "EMEA revenue", "targetValue": 40.0, "owner": "bob@example.com"},
+			},
+			"okrs": []interface{}{
+				map[string]interface{}{"name": "Grow", "keyResults": []interface{}{
+					map[string]interface{}{"type": KeyResultTypeIncrease, "targetValue": 10.0},
+				}},
+			},
+		},
+	}
+	cycle := &PerformanceRecord{EntityType: perfEntityCycle, OrganizationId: "ORG#org-1", Data: map[string]interface{}{"id": "c-1"}}
+
+	t.Run("It should create linked records owned by the default owner", func(t *testing.T) {
+		records, counts, err := svc.planTemplateApply(template, cycle, "q-1", "ann@example.com")
+
+		assert.NoError(t, err)
+		assert.Equal(t, CopyCounts{KPIs: 2, OKRs: 1, KeyResults: 1}, counts)
+		assert.Len(t, records, 4)
+
+		parent, child, okr, kr := records[0], records[1], records[2], records[3]
+		assert.Equal(t, "ann@example.com", parent.Owner)
+		assert.Equal(t, "bob@example.com", child.Owner)
+		assert.Equal(t, parent.Data["id"], child.ParentId)
+		assert.Equal(t, parent.Data["id"], child.Data["parentKpiId"])
+		assert.NotContains(t, child.Data, "ref")
+		assert.Equal(t, "q-1", parent.QuarterId)
+		assert.Equal(t, "template-1", parent.Data["templateId"])
+		assert.Equal(t, "c-1", parent.CycleId)
+
+		assert.Equal(t, perfEntityOKR, okr.EntityType)
+		assert.Equal(t, okr.Data["id"], kr.ParentId)
+		assert.Equal(t, 0.0, kr.Data["currentValue"])
+	})
+
+	t.Run("It should need an owner for unowned KPIs", func(t *testing.T) {
+		_, _, err := svc.planTemplateApply(template, cycle, "", "")
+
+		assert.ErrorIs(t, err, ErrCopyJobInvalid)
+	})
+}
@@ -0,0 +1,798 @@
+package Companylib
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Performance reviews run once per quarter. An org admin opens the quarter's review window, which
+// creates a review for every participant; the member then completes a self-assessment, their
+// manager an assessment rated against the team's SKILL and VALUE attributes, an org admin
+// calibrates the rating and finally the member and the manager both sign off, which locks it.
+//
+//	SELF_ASSESSMENT -> MANAGER_ASSESSMENT -> CALIBRATION -> SIGN_OFF -> LOCKED
+//
+// Reviews live in the performance table under their quarter. Each step also updates the member's
+// TeamMemberReviewRecord in the performance hub table, which the team views read.
+
+const (
+	perfEntityReview = "PERFORMANCE_REVIEW"
+
+	ReviewStatusSelfAssessment    = "SELF_ASSESSMENT"
+	ReviewStatusManagerAssessment = "MANAGER_ASSESSMENT"
+	ReviewStatusCalibration       = "CALIBRATION"
+	ReviewStatusSignOff           = "SIGN_OFF"
+	ReviewStatusLocked            = "LOCKED"
+
+	ReviewWindowOpen   = "OPEN"
+	ReviewWindowClosed = "CLOSED"
+
+	ReviewRatingMin = 1.0
+	ReviewRatingMax = 5.0
+
+	perfHubTeamPrefix         = "TEAM#"
+	perfHubMemberReviewPrefix = "REVIEW#MEMBER#"
+)
+
+var (
+	ErrReviewNotFound     = errors.New("performance review not found")
+	ErrReviewForbidden    = errors.New("not allowed to act on this performance review")
+	ErrReviewLocked       = errors.New("performance review is signed off and locked")
+	ErrReviewStage        = errors.New("performance review is not at this stage")
+	ErrReviewWindowClosed = errors.New("the review window for this quarter is not open")
+	ErrReviewConflict     = errors.New("performance review was changed by someone else, reload and try again")
+	ErrReviewInvalid      = errors.New("invalid performance review input")
+)
+
+// reviewStageOrder orders the review queue, earliest stage first
+var reviewStageOrder = map[string]int{
+	ReviewStatusSelfAssessment:    0,
+	ReviewStatusManagerAssessment: 1,
+	ReviewStatusCalibration:       2,
+	ReviewStatusSignOff:           3,
+	ReviewStatusLocked:            4,
+}
+
+// PerformanceReview is the Data of a PERFORMANCE_REVIEW record
+type PerformanceReview struct {
+	ID                   string             `json:"id"`
+	OrganizationID       string             `json:"organizationId"`
+	CycleID              string             `json:"cycleId"`
+	QuarterID            string             `json:"quarterId"`
+	TeamID               string             `json:"teamId"`
+	MemberUserName       string             `json:"memberUserName"`
+	MemberName           string             `json:"memberName"`
+	Status               string             `json:"status"`
+	Competencies         []ReviewCompetency `json:"competencies"`
+	SelfAssessment       *ReviewAssessment  `json:"selfAssessment,omitempty"`
+	ManagerAssessment    *ReviewAssessment  `json:"managerAssessment,omitempty"`
+	Calibration          *ReviewCalibration `json:"calibration,omitempty"`
+	FinalRating          float64            `json:"finalRating,omitempty"`
+	MemberSignedOffAt    string             `json:"memberSignedOffAt,omitempty"`
+	MemberSignOffComment string             `json:"memberSignOffComment,omitempty"`
+	ManagerSignedOffBy   string             `json:"managerSignedOffBy,omitempty"`
+	ManagerSignedOffAt   string             `json:"managerSignedOffAt,omitempty"`
+	LockedAt             string             `json:"lockedAt,omitempty"`
+	CreatedAt            string             `json:"createdAt"`
+	UpdatedAt            string             `json:"updatedAt"`
+	Version              int                `json:"version"` // bumped on every write, see putReview
+}
+
+// ReviewCompetency is a team SKILL or VALUE attribute the review is rated against, copied when the
+// review is created so later attribute changes do not alter it
+type ReviewCompetency struct {
+	AttributeID string            `json:"attributeId"`
+	Name        string            `json:"name"`
+	Type        TeamAttributeType `json:"type"`
+}
+
+type CompetencyRating struct {
+	AttributeID string  `json:"attributeId"`
+	Rating      float64 `json:"rating"`
+	Comment     string  `json:"comment,omitempty"`
+}
+
+type ReviewAssessment struct {
+	Summary       string             `json:"summary"`
+	Ratings       []CompetencyRating `json:"ratings"`
+	OverallRating float64            `json:"overallRating,omitempty"`
+	AuthorID      string             `json:"authorId"`
+	SubmittedAt   string             `json:"submittedAt,omitempty"`
+	UpdatedAt     string             `json:"updatedAt"`
+}
+
+type ReviewCalibration struct {
+	ManagerRating    float64 `json:"managerRating"`
+	CalibratedRating float64 `json:"calibratedRating"`
+	Notes            string  `json:"notes,omitempty"`
+	CalibratedBy     string  `json:"calibratedBy"`
+	CalibratedAt     string  `json:"calibratedAt"`
+}
+
+// ReviewAssessmentInput saves a draft assessment, or submits it when Submit is set
+type ReviewAssessmentInput struct {
+	Summary       string             `json:"summary"`
+	Ratings       []CompetencyRating `json:"ratings"`
+	OverallRating *float64           `json:"overallRating,omitempty"`
+	Submit        bool               `json:"submit"`
+}
+
+// ReviewParticipant is a member to review when a quarter's review window opens
+type ReviewParticipant struct {
+	TeamID         string
+	MemberUserName string
+	MemberName     string
+	Competencies   []ReviewCompetency
+}
+
+// ReviewCompetenciesFromAttributes returns the team's SKILL and VALUE attributes, skills first
+func ReviewCompetenciesFromAttributes(attributes []TeamAttribute) []ReviewCompetency {
+	competencies := []ReviewCompetency{}
+	for _, attr := range attributes {
+		if attr.AttributeType == AttributeTypeSkill || attr.AttributeType == AttributeTypeValue {
+			competencies = append(competencies, ReviewCompetency{AttributeID: attr.AttributeId, Name: attr.Name, Type: attr.AttributeType})
+		}
+	}
+	sort.SliceStable(competencies, func(i, j int) bool {
+		if competencies[i].Type != competencies[j].Type {
+			return competencies[i].Type == AttributeTypeSkill
+		}
+		return competencies[i].Name < competencies[j].Name
+	})
+	return competencies
+}
+
+// ==================== Review Window ====================
+
+// OpenReviewWindow opens the quarter's review window and creates a review for each participant who
+// does not have one yet, so it can be called again to add members who joined later
+func (svc *PerformanceService) OpenReviewWindow(quarterID string, input map[string]interface{}, participants []ReviewParticipant, openedBy string) (map[string]interface{}, error) {
+	quarter, err := svc.getRecordByGSI1(perfSKPrefix + "QUARTER#" + quarterID)
+	if err != nil {
+		return nil, err
+	}
+	if quarter == nil {
+		return nil, fmt.Errorf("quarter not found")
+	}
+
+	existing, err := svc.queryByOrgPrefix(quarter.OrganizationId, reviewQuarterPrefix(quarter.CycleId, quarterID))
+	if err != nil {
+		return nil, err
+	}
+	reviewed := map[string]bool{}
+	for _, r := range existing {
+		if r.EntityType == perfEntityReview {
+			reviewed[r.SK] = true
+		}
+	}
+
+	now := svc.now()
+	created := 0
+	for _, p := range participants {
+		record := svc.newReviewRecord(quarter, p, now)
+		if reviewed[record.SK] {
+			continue
+		}
+		review, err := reviewFromRecord(&record)
+		if err != nil {
+			return nil, err
+		}
+		if err := svc.putReview(&record, review, ""); err != nil {
+			if errors.Is(err, ErrReviewConflict) {
+				continue // created by a concurrent call
+			}
+			return nil, err
+		}
+		created++
+	}
+
+	window, _ := quarter.Data["reviewWindow"].(map[string]interface{})
+	if window == nil {
+		window = map[string]interface{}{}
+	}
+	window["status"] = ReviewWindowOpen
+	window["openedAt"] = now
+	window["openedBy"] = openedBy
+	delete(window, "closedAt")
+	delete(window, "closedBy")
+	for _, key := range []string{"selfAssessmentDueDate", "managerAssessmentDueDate"} {
+		if v := toString(input[key]); v != "" {
+			window[key] = v
+		}
+	}
+	if _, err := svc.patchRecord(quarter, map[string]interface{}{"reviewWindow": window}); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"quarterId":      quarterID,
+		"reviewWindow":   window,
+		"reviewsCreated": created,
+		"reviewsTotal":   len(reviewed) + created,
+	}, nil
+}
+
+// CloseReviewWindow stops further self and manager assessments. Calibration and sign-off of
+// reviews already submitted carry on.
+func (svc *PerformanceService) CloseReviewWindow(quarterID string, closedBy string) (map[string]interface{}, error) {
+	quarter, err := svc.getRecordByGSI1(perfSKPrefix + "QUARTER#" + quarterID)
+	if err != nil {
+		return nil, err
+	}
+	if quarter == nil {
+		return nil, fmt.Errorf("quarter not found")
+	}
+
+	window, _ := quarter.Data["reviewWindow"].(map[string]interface{})
+	if window == nil {
+		return nil, ErrReviewWindowClosed
+	}
+	window["status"] = ReviewWindowClosed
+	window["closedAt"] = svc.now()
+	window["closedBy"] = closedBy
+	updated, err := svc.patchRecord(quarter, map[string]interface{}{"reviewWindow": window})
+	if err != nil {
+		return nil, err
+	}
+	return svc.toPayload(updated), nil
+}
+
+// ListQuarterReviews lists the quarter's reviews, optionally filtered by status and teamId
+func (svc *PerformanceService) ListQuarterReviews(quarterID string, filters map[string]string) (map[string]interface{}, error) {
+	quarter, err := svc.getRecordByGSI1(perfSKPrefix + "QUARTER#" + quarterID)
+	if err != nil {
+		return nil, err
+	}
+	if quarter == nil {
+		return nil, fmt.Errorf("quarter not found")
+	}
+
+	records, err := svc.queryByOrgPrefix(quarter.OrganizationId, reviewQuarterPrefix(quarter.CycleId, quarterID))
+	if err != nil {
+		return nil, err
+	}
+
+	reviews := []PerformanceReview{}
+	for i := range records {
+		if records[i].EntityType != perfEntityReview {
+			continue
+		}
+		review, err := reviewFromRecord(&records[i])
+		if err != nil {
+			return nil, err
+		}
+		if filters["status"] != "" && !strings.EqualFold(review.Status, filters["status"]) {
+			continue
+		}
+		if filters["teamId"] != "" && review.TeamID != filters["teamId"] {
+			continue
+		}
+		reviews = append(reviews, *review)
+	}
+	sortReviewQueue(reviews)
+
+	counts := map[string]int{}
+	for _, r := range reviews {
+		counts[r.Status]++
+	}
+	return map[string]interface{}{"reviews": reviews, "total": len(reviews), "statusCounts": counts}, nil
+}
+
+// pendingReviewQueue summarises the reviews of a quarter that are not locked yet, for GetQuarterDetails
+func pendingReviewQueue(related []PerformanceRecord, quarterID string) []map[string]interface{} {
+	var reviews []PerformanceReview
+	for i := range related {
+		if related[i].EntityType != perfEntityReview || related[i].QuarterId != quarterID || related[i].Status == ReviewStatusLocked {
+			continue
+		}
+		if review, err := reviewFromRecord(&related[i]); err == nil {
+			reviews = append(reviews, *review)
+		}
+	}
+	sortReviewQueue(reviews)
+
+	queue := make([]map[string]interface{}, 0, len(reviews))
+	for _, r := range reviews {
+		queue = append(queue, map[string]interface{}{
+			"reviewId":       r.ID,
+			"teamId":         r.TeamID,
+			"memberUserName": r.MemberUserName,
+			"memberName":     r.MemberName,
+			"status":         r.Status,
+			"awaiting":       reviewAwaiting(r),
+			"updatedAt":      r.UpdatedAt,
+		})
+	}
+	return queue
+}
+
+// reviewAwaiting lists who the review is waiting on: member, manager and/or admin
+func reviewAwaiting(r PerformanceReview) []string {
+	switch r.Status {
+	case ReviewStatusSelfAssessment:
+		return []string{"member"}
+	case ReviewStatusManagerAssessment:
+		return []string{"manager"}
+	case ReviewStatusCalibration:
+		return []string{"admin"}
+	case ReviewStatusSignOff:
+		awaiting := []string{}
+		if r.MemberSignedOffAt == "" {
+			awaiting = append(awaiting, "member")
+		}
+		if r.ManagerSignedOffAt == "" {
+			awaiting = append(awaiting, "manager")
+		}
+		return awaiting
+	}
+	return []string{}
+}
+
+func sortReviewQueue(reviews []PerformanceReview) {
+	sort.SliceStable(reviews, func(i, j int) bool {
+		if reviews[i].Status != reviews[j].Status {
+			return reviewStageOrder[reviews[i].Status] < reviewStageOrder[reviews[j].Status]
+		}
+		if reviews[i].TeamID != reviews[j].TeamID {
+			return reviews[i].TeamID < reviews[j].TeamID
+		}
+		return reviews[i].MemberUserName < reviews[j].MemberUserName
+	})
+}
+
+// ==================== Review Steps ====================
+
+// GetReview returns a review by ID
+func (svc *PerformanceService) GetReview(reviewID string) (*PerformanceReview, error) {
+	_, review, err := svc.loadReview(reviewID)
+	return review, err
+}
+
+// GetMemberReview returns the member's current review in the team, as tracked by their
+// TeamMemberReviewRecord
+func (svc *PerformanceService) GetMemberReview(teamID string, memberUserName string) (*PerformanceReview, error) {
+	out, err := svc.dynamodbClient.GetItem(svc.ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(svc.PerfHubTable),
+		Key:       reviewSummaryKey(teamID, memberUserName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get review summary: %w", err)
+	}
+	if out.Item == nil {
+		return nil, ErrReviewNotFound
+	}
+	var summary struct {
+		ReviewID string `dynamodbav:"reviewId"`
+	}
+	if err := attributevalue.UnmarshalMap(out.Item, &summary); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal review summary: %w", err)
+	}
+	if summary.ReviewID == "" {
+		return nil, ErrReviewNotFound
+	}
+	return svc.GetReview(summary.ReviewID)
+}
+
+// SaveSelfAssessment saves the member's self-assessment and, on submit, passes the review to the manager
+func (svc *PerformanceService) SaveSelfAssessment(reviewID string, memberUserName string, input ReviewAssessmentInput) (*PerformanceReview, error) {
+	record, review, err := svc.loadReview(reviewID)
+	if err != nil {
+		return nil, err
+	}
+	if review.MemberUserName != memberUserName {
+		return nil, ErrReviewForbidden
+	}
+	if err := checkReviewStage(review, ReviewStatusSelfAssessment); err != nil {
+		return nil, err
+	}
+	if err := svc.ensureReviewWindowOpen(review.QuarterID); err != nil {
+		return nil, err
+	}
+	if err := validateCompetencyRatings(review.Competencies, input.Ratings, input.Submit); err != nil {
+		return nil, err
+	}
+	if input.Submit && strings.TrimSpace(input.Summary) == "" {
+		return nil, fmt.Errorf("%w: summary is required to submit the self-assessment", ErrReviewInvalid)
+	}
+
+	now := svc.now()
+	expected := review.Status
+	review.SelfAssessment = &ReviewAssessment{
+		Summary:   strings.TrimSpace(input.Summary),
+		Ratings:   input.Ratings,
+		AuthorID:  memberUserName,
+		UpdatedAt: now,
+	}
+	if input.Submit {
+		review.SelfAssessment.SubmittedAt = now
+		review.Status = ReviewStatusManagerAssessment
+	}
+	return review, svc.putReview(record, review, expected)
+}
+
+// SaveManagerAssessment saves the manager's assessment. Drafts can be saved while the member is
+// still on their self-assessment; submitting needs the self-assessment first and passes the
+// review to calibration. The overall rating defaults to the mean of the competency ratings.
+func (svc *PerformanceService) SaveManagerAssessment(reviewID string, managerUserName string, input ReviewAssessmentInput) (*PerformanceReview, error) {
+	record, review, err := svc.loadReview(reviewID)
+	if err != nil {
+		return nil, err
+	}
+	if review.MemberUserName == managerUserName {
+		return nil, ErrReviewForbidden
+	}
+	stages := []string{ReviewStatusSelfAssessment, ReviewStatusManagerAssessment}
+	if input.Submit {
+		stages = []string{ReviewStatusManagerAssessment}
+	}
+	if err := checkReviewStage(review, stages...); err != nil {
+		return nil, err
+	}
+	if err := svc.ensureReviewWindowOpen(review.QuarterID); err != nil {
+		return nil, err
+	}
+	if err := validateCompetencyRatings(review.Competencies, input.Ratings, input.Submit); err != nil {
+		return nil, err
+	}
+
+	overall := averageRating(input.Ratings)
+	if input.OverallRating != nil {
+		if err := validateRating(*input.OverallRating); err != nil {
+			return nil, err
+		}
+		overall = *input.OverallRating
+	}
+	if input.Submit && overall == 0 {
+		return nil, fmt.Errorf("%w: overallRating is required when the review has no competencies", ErrReviewInvalid)
+	}
+
+	now := svc.now()
+	expected := review.Status
+	review.ManagerAssessment = &ReviewAssessment{
+		Summary:       strings.TrimSpace(input.Summary),
+		Ratings:       input.Ratings,
+		OverallRating: overall,
+		AuthorID:      managerUserName,
+		UpdatedAt:     now,
+	}
+	if input.Submit {
+		review.ManagerAssessment.SubmittedAt = now
+		review.Status = ReviewStatusCalibration
+	}
+	return review, svc.putReview(record, review, expected)
+}
+
+// CalibrateReview sets the final rating, the manager's overall rating unless calibratedRating is
+// given, and passes the review to sign-off
+func (svc *PerformanceService) CalibrateReview(reviewID string, calibratedBy string, calibratedRating *float64, notes string) (*PerformanceReview, error) {
+	record, review, err := svc.loadReview(reviewID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkReviewStage(review, ReviewStatusCalibration); err != nil {
+		return nil, err
+	}
+
+	managerRating := review.ManagerAssessment.OverallRating
+	final := managerRating
+	if calibratedRating != nil {
+		if err := validateRating(*calibratedRating); err != nil {
+			return nil, err
+		}
+		final = *calibratedRating
+	}
+
+	expected := review.Status
+	review.Calibration = &ReviewCalibration{
+		ManagerRating:    managerRating,
+		CalibratedRating: final,
+		Notes:            strings.TrimSpace(notes),
+		CalibratedBy:     calibratedBy,
+		CalibratedAt:     svc.now(),
+	}
+	review.FinalRating = final
+	review.Status = ReviewStatusSignOff
+	return review, svc.putReview(record, review, expected)
+}
+
+// SignOffReview records the member's or the manager's sign-off. The review locks once both have signed.
+func (svc *PerformanceService) SignOffReview(reviewID string, userName string, asManager bool, comment string) (*PerformanceReview, error) {
+	record, review, err := svc.loadReview(reviewID)
+	if err != nil {
+		return nil, err
+	}
+	if asManager == (review.MemberUserName == userName) {
+		return nil, ErrReviewForbidden
+	}
+	if err := checkReviewStage(review, ReviewStatusSignOff); err != nil {
+		return nil, err
+	}
+
+	now := svc.now()
+	expected := review.Status
+	if asManager {
+		if review.ManagerSignedOffAt != "" {
+			return review, nil
+		}
+		review.ManagerSignedOffBy = userName
+		review.ManagerSignedOffAt = now
+	} else {
+		if review.MemberSignedOffAt != "" {
+			return review, nil
+		}
+		review.MemberSignedOffAt = now
+		review.MemberSignOffComment = strings.TrimSpace(comment)
+	}
+	if review.MemberSignedOffAt != "" && review.ManagerSignedOffAt != "" {
+		review.Status = ReviewStatusLocked
+		review.LockedAt = now
+	}
+	return review, svc.putReview(record, review, expected)
+}
+
+// ==================== Helpers ====================
+
+func reviewQuarterPrefix(cycleID string, quarterID string) string {
+	return fmt.Sprintf("%sCYCLE#%s#QUARTER#%s#REVIEW#", perfSKPrefix, cycleID, quarterID)
+}
+
+func (svc *PerformanceService) newReviewRecord(quarter *PerformanceRecord, p ReviewParticipant, now string) PerformanceRecord {
+	reviewID := svc.generateID("review")
+	competencies := p.Competencies
+	if competencies == nil {
+		competencies = []ReviewCompetency{}
+	}
+	review := PerformanceReview{
+		ID:             reviewID,
+		OrganizationID: quarter.OrganizationId,
+		CycleID:        quarter.CycleId,
+		QuarterID:      quarter.QuarterId,
+		TeamID:         p.TeamID,
+		MemberUserName: p.MemberUserName,
+		MemberName:     p.MemberName,
+		Status:         ReviewStatusSelfAssessment,
+		Competencies:   competencies,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	data, _ := reviewData(&review)
+	return PerformanceRecord{
+		PK:             quarter.OrganizationId,
+		SK:             reviewQuarterPrefix(quarter.CycleId, quarter.QuarterId) + p.TeamID + "#" + p.MemberUserName,
+		GSI1PK:         perfSKPrefix + "REVIEW#" + reviewID,
+		GSI1SK:         fmt.Sprintf("%s#QUARTER#%s", quarter.OrganizationId, quarter.QuarterId),
+		EntityType:     perfEntityReview,
+		OrganizationId: quarter.OrganizationId,
+		CycleId:        quarter.CycleId,
+		QuarterId:      quarter.QuarterId,
+		Owner:          p.MemberUserName,
+		Status:         review.Status,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		Data:           data,
+	}
+}
+
+func (svc *PerformanceService) loadReview(reviewID string) (*PerformanceRecord, *PerformanceReview, error) {
+	record, err := svc.getRecordByGSI1(perfSKPrefix + "REVIEW#" + reviewID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if record == nil || record.EntityType != perfEntityReview {
+		return nil, nil, ErrReviewNotFound
+	}
+	review, err := reviewFromRecord(record)
+	if err != nil {
+		return nil, nil, err
+	}
+	return record, review, nil
+}
+
+func reviewFromRecord(record *PerformanceRecord) (*PerformanceReview, error) {
+	raw, err := json.Marshal(record.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal review data: %w", err)
+	}
+	var review PerformanceReview
+	if err := json.Unmarshal(raw, &review); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal review data: %w", err)
+	}
+	return &review, nil
+}
+
+func reviewData(review *PerformanceReview) (map[string]interface{}, error) {
+	raw, err := json.Marshal(review)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal review: %w", err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal review: %w", err)
+	}
+	return data, nil
+}
+
+// putReview writes the review if its status is still expectedStatus ("" when creating it) and
+// nobody has saved it since it was read, then updates the member's TeamMemberReviewRecord
+func (svc *PerformanceService) putReview(record *PerformanceRecord, review *PerformanceReview, expectedStatus string) error {
+	readVersion := review.Version
+	review.Version++
+	review.UpdatedAt = svc.now()
+	data, err := reviewData(review)
+	if err != nil {
+		return err
+	}
+	record.Data = data
+	record.Status = review.Status
+	record.UpdatedAt = review.UpdatedAt
+
+	item, err := attributevalue.MarshalMap(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+	input := &dynamodb.PutItemInput{
+		TableName:           aws.String(svc.performanceTableName()),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(PK)"),
+	}
+	if expectedStatus != "" {
+		input.ConditionExpression = aws.String("#status = :expected AND #data.#version = :version")
+		input.ExpressionAttributeNames = map[string]string{"#status": "Status", "#data": "Data", "#version": "version"}
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":expected": &types.AttributeValueMemberS{Value: expectedStatus},
+			":version":  &types.AttributeValueMemberN{Value: strconv.Itoa(readVersion)},
+		}
+		if readVersion == 0 {
+			// Written before reviews were versioned
+			input.ConditionExpression = aws.String("#status = :expected AND attribute_not_exists(#data.#version)")
+			delete(input.ExpressionAttributeValues, ":version")
+		}
+	}
+	if _, err := svc.dynamodbClient.PutItem(svc.ctx, input); err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return ErrReviewConflict
+		}
+		return fmt.Errorf("failed to put review: %w", err)
+	}
+
+	return svc.updateReviewSummary(review)
+}
+
+func reviewSummaryKey(teamID string, memberUserName string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: perfHubTeamPrefix + teamID},
+		"SK": &types.AttributeValueMemberS{Value: perfHubMemberReviewPrefix + memberUserName},
+	}
+}
+
+// updateReviewSummary mirrors the review onto the member's TeamMemberReviewRecord. The record
+// follows the member's most recently opened review; updates from an older review are skipped.
+func (svc *PerformanceService) updateReviewSummary(review *PerformanceReview) error {
+	if svc.PerfHubTable == "" {
+		svc.logger.Printf("PerfHubTable not set, skipping review summary for %s", review.ID)
+		return nil
+	}
+
+	update := "SET teamId = :teamId, memberUserName = :member, reviewId = :reviewId, quarterId = :quarterId, " +
+		"reviewOpenedAt = :openedAt, reviewStatus = :status, isPendingReview = :pending, " +
+		"hasUserUpdatedReviews = :selfSubmitted, updatedAt = :now, "
+	values := map[string]types.AttributeValue{
+		":teamId":        &types.AttributeValueMemberS{Value: review.TeamID},
+		":member":        &types.AttributeValueMemberS{Value: review.MemberUserName},
+		":reviewId":      &types.AttributeValueMemberS{Value: review.ID},
+		":quarterId":     &types.AttributeValueMemberS{Value: review.QuarterID},
+		":openedAt":      &types.AttributeValueMemberS{Value: review.CreatedAt},
+		":status":        &types.AttributeValueMemberS{Value: review.Status},
+		":pending":       &types.AttributeValueMemberBOOL{Value: review.Status != ReviewStatusLocked},
+		":selfSubmitted": &types.AttributeValueMemberBOOL{Value: review.SelfAssessment != nil && review.SelfAssessment.SubmittedAt != ""},
+		":now":           &types.AttributeValueMemberS{Value: review.UpdatedAt},
+	}
+	if review.Status == ReviewStatusLocked {
+		update += "overallRating = :rating, lastReviewDate = :reviewDate"
+		values[":rating"] = &types.AttributeValueMemberN{Value: fmt.Sprint(review.FinalRating)}
+		values[":reviewDate"] = &types.AttributeValueMemberS{Value: review.LockedAt}
+	} else {
+		update += "overallRating = if_not_exists(overallRating, :zero)"
+		values[":zero"] = &types.AttributeValueMemberN{Value: "0"}
+	}
+
+	_, err := svc.dynamodbClient.UpdateItem(svc.ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(svc.PerfHubTable),
+		Key:                       reviewSummaryKey(review.TeamID, review.MemberUserName),
+		UpdateExpression:          aws.String(update),
+		ConditionExpression:       aws.String("attribute_not_exists(reviewOpenedAt) OR reviewOpenedAt <= :openedAt"),
+		ExpressionAttributeValues: values,
+	})
+	if err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			svc.logger.Printf("Review %s is not the current review for %s, summary left unchanged", review.ID, review.MemberUserName)
+			return nil
+		}
+		return fmt.Errorf("failed to update review summary: %w", err)
+	}
+	return nil
+}
+
+func (svc *PerformanceService) ensureReviewWindowOpen(quarterID string) error {
+	quarter, err := svc.getRecordByGSI1(perfSKPrefix + "QUARTER#" + quarterID)
+	if err != nil {
+		return err
+	}
+	if quarter == nil {
+		return fmt.Errorf("quarter not found")
+	}
+	window, _ := quarter.Data["reviewWindow"].(map[string]interface{})
+	if window == nil || toString(window["status"]) != ReviewWindowOpen {
+		return ErrReviewWindowClosed
+	}
+	return nil
+}
+
+func checkReviewStage(review *PerformanceReview, allowed ...string) error {
+	if review.Status == ReviewStatusLocked {
+		return ErrReviewLocked
+	}
+	for _, status := range allowed {
+		if review.Status == status {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: the review is at %s", ErrReviewStage, review.Status)
+}
+
+func validateRating(rating float64) error {
+	if rating < ReviewRatingMin || rating > ReviewRatingMax {
+		return fmt.Errorf("%w: ratings must be between %g and %g", ErrReviewInvalid, ReviewRatingMin, ReviewRatingMax)
+	}
+	return nil
+}
+
+// validateCompetencyRatings checks each rating is for one of the review's competencies, once, and
+// in range. Submitting needs every competency rated.
+func validateCompetencyRatings(competencies []ReviewCompetency, ratings []CompetencyRating, requireAll bool) error {
+	known := map[string]bool{}
+	for _, c := range competencies {
+		known[c.AttributeID] = true
+	}
+	seen := map[string]bool{}
+	for _, r := range ratings {
+		if !known[r.AttributeID] {
+			return fmt.Errorf("%w: attribute %q is not one of this review's competencies", ErrReviewInvalid, r.AttributeID)
+		}
+		if seen[r.AttributeID] {
+			return fmt.Errorf("%w: attribute %q is rated more than once", ErrReviewInvalid, r.AttributeID)
+		}
+		seen[r.AttributeID] = true
+		if err := validateRating(r.Rating); err != nil {
+			return err
+		}
+	}
+	if requireAll && len(seen) < len(competencies) {
+		return fmt.Errorf("%w: every competency must be rated to submit", ErrReviewInvalid)
+	}
+	return nil
+}
+
+// averageRating is the mean rating rounded to one decimal place, or 0 when there are none
+func averageRating(ratings []CompetencyRating) float64 {
+	if len(ratings) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, r := range ratings {
+		total += r.Rating
+	}
+	return math.Round(total/float64(len(ratings))*10) / 10
+}
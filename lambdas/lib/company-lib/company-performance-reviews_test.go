@@ -0,0 +1,433 @@
+package Companylib
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodb_types "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	awsclients "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/clients"
+	"github.com/stretchr/testify/assert"
+)
+
+func newReviewTestService(ddbClient *awsclients.MockDynamodbClient) *PerformanceService {
+	return &PerformanceService{
+		ctx:                 context.Background(),
+		dynamodbClient:      ddbClient,
+		logger:              log.New(&bytes.Buffer{}, "TEST:", 0),
+		OrgPerformanceTable: "OrgPerformanceTable-test",
+		PerfHubTable:        "PerfHubTable-test",
+	}
+}
+
+func reviewTestQuarter(windowStatus string) PerformanceRecord {
+	data := map[string]interface{}{"id": "q-1", "name": "Q1"}
+	if windowStatus != "" {
+		data["reviewWindow"] = map[string]interface{}{"status": windowStatus, "openedAt": "2025-01-01T00:00:00Z"}
+	}
+	return PerformanceRecord{
+		PK:             "ORG#org-1",
+		SK:             "PERF#CYCLE#c-1#QUARTER#q-1",
+		GSI1PK:         "PERF#QUARTER#q-1",
+		EntityType:     perfEntityQuarter,
+		OrganizationId: "ORG#org-1",
+		CycleId:        "c-1",
+		QuarterId:      "q-1",
+		Data:           data,
+	}
+}
+
+func reviewTestRecord(t *testing.T, review PerformanceReview) PerformanceRecord {
+	review.OrganizationID, review.CycleID, review.QuarterID = "ORG#org-1", "c-1", "q-1"
+	if review.ID == "" {
+		review.ID = "review-1"
+	}
+	if review.TeamID == "" {
+		review.TeamID = "team-1"
+	}
+	if review.MemberUserName == "" {
+		review.MemberUserName = "alice@example.com"
+	}
+	if review.CreatedAt == "" {
+		review.CreatedAt = "2025-01-01T00:00:00Z"
+	}
+	data, err := reviewData(&review)
+	assert.NoError(t, err)
+	return PerformanceRecord{
+		PK:             "ORG#org-1",
+		SK:             reviewQuarterPrefix("c-1", "q-1") + review.TeamID + "#" + review.MemberUserName,
+		GSI1PK:         "PERF#REVIEW#" + review.ID,
+		EntityType:     perfEntityReview,
+		OrganizationId: "ORG#org-1",
+		CycleId:        "c-1",
+		QuarterId:      "q-1",
+		Owner:          review.MemberUserName,
+		Status:         review.Status,
+		Data:           data,
+	}
+}
+
+func reviewQueryOutput(t *testing.T, records ...PerformanceRecord) dynamodb.QueryOutput {
+	items := []map[string]dynamodb_types.AttributeValue{}
+	for _, record := range records {
+		item, err := attributevalue.MarshalMap(record)
+		assert.NoError(t, err)
+		items = append(items, item)
+	}
+	return dynamodb.QueryOutput{Items: items}
+}
+
+func reviewFromPut(t *testing.T, input dynamodb.PutItemInput) *PerformanceReview {
+	var record PerformanceRecord
+	assert.NoError(t, attributevalue.UnmarshalMap(input.Item, &record))
+	review, err := reviewFromRecord(&record)
+	assert.NoError(t, err)
+	return review
+}
+
+var reviewTestCompetencies = []ReviewCompetency{
+	{AttributeID: "skill-1", Name: "Communication", Type: AttributeTypeSkill},
+	{AttributeID: "value-1", Name: "Ownership", Type: AttributeTypeValue},
+}
+
+func TestReviewCompetenciesFromAttributes(t *testing.T) {
+	competencies := ReviewCompetenciesFromAttributes([]TeamAttribute{
+		{AttributeId: "v", Name: "Ownership", AttributeType: AttributeTypeValue},
+		{AttributeId: "m", Name: "Launch", AttributeType: AttributeTypeMilestone},
+		{AttributeId: "s2", Name: "Go", AttributeType: AttributeTypeSkill},
+		{AttributeId: "s1", Name: "Design", AttributeType: AttributeTypeSkill},
+	})
+
+	assert.Equal(t, []ReviewCompetency{
+		{AttributeID: "s1", Name: "Design", Type: AttributeTypeSkill},
+		{AttributeID: "s2", Name: "Go", Type: AttributeTypeSkill},
+		{AttributeID: "v", Name: "Ownership", Type: AttributeTypeValue},
+	}, competencies)
+}
+
+func TestOpenReviewWindow(t *testing.T) {
+	t.Run("It should create reviews only for members without one and open the window", func(t *testing.T) {
+		existing := reviewTestRecord(t, PerformanceReview{Status: ReviewStatusManagerAssessment})
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs:      []dynamodb.QueryOutput{reviewQueryOutput(t, reviewTestQuarter("")), reviewQueryOutput(t, existing)},
+			QueryErrors:       []error{nil, nil},
+			PutItemOutputs:    []dynamodb.PutItemOutput{{}, {}},
+			PutItemErrors:     []error{nil, nil},
+			UpdateItemOutputs: []dynamodb.UpdateItemOutput{{}},
+			UpdateItemErrors:  []error{nil},
+		}
+		svc := newReviewTestService(&ddbClient)
+
+		result, err := svc.OpenReviewWindow("q-1", map[string]interface{}{"selfAssessmentDueDate": "2025-03-15"}, []ReviewParticipant{
+			{TeamID: "team-1", MemberUserName: "alice@example.com", MemberName: "Alice"},
+			{TeamID: "team-1", MemberUserName: "bob@example.com", MemberName: "Bob", Competencies: reviewTestCompetencies},
+		}, "admin@example.com")
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, result["reviewsCreated"])
+		assert.Equal(t, 2, result["reviewsTotal"])
+		assert.Equal(t, "attribute_not_exists(PK)", *ddbClient.PutItemInputs[0].ConditionExpression)
+
+		created := reviewFromPut(t, ddbClient.PutItemInputs[0])
+		assert.Equal(t, "bob@example.com", created.MemberUserName)
+		assert.Equal(t, ReviewStatusSelfAssessment, created.Status)
+		assert.Equal(t, reviewTestCompetencies, created.Competencies)
+
+		summary := ddbClient.UpdateItemInputs[0]
+		assert.Equal(t, "PerfHubTable-test", *summary.TableName)
+		assert.Equal(t, &dynamodb_types.AttributeValueMemberS{Value: "TEAM#team-1"}, summary.Key["PK"])
+		assert.Equal(t, &dynamodb_types.AttributeValueMemberS{Value: "REVIEW#MEMBER#bob@example.com"}, summary.Key["SK"])
+		assert.Equal(t, &dynamodb_types.AttributeValueMemberBOOL{Value: true}, summary.ExpressionAttributeValues[":pending"])
+		assert.Contains(t, *summary.UpdateExpression, "if_not_exists(overallRating, :zero)")
+
+		var quarter PerformanceRecord
+		assert.NoError(t, attributevalue.UnmarshalMap(ddbClient.PutItemInputs[1].Item, &quarter))
+		window := quarter.Data["reviewWindow"].(map[string]interface{})
+		assert.Equal(t, ReviewWindowOpen, window["status"])
+		assert.Equal(t, "2025-03-15", window["selfAssessmentDueDate"])
+	})
+
+	t.Run("It should return an error when the quarter does not exist", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs: []dynamodb.QueryOutput{{}},
+			QueryErrors:  []error{nil},
+		}
+		svc := newReviewTestService(&ddbClient)
+
+		_, err := svc.OpenReviewWindow("q-1", nil, nil, "admin@example.com")
+
+		assert.EqualError(t, err, "quarter not found")
+	})
+}
+
+func TestSaveSelfAssessment(t *testing.T) {
+	selfStage := PerformanceReview{Status: ReviewStatusSelfAssessment, Competencies: reviewTestCompetencies}
+	ratings := []CompetencyRating{{AttributeID: "skill-1", Rating: 4}, {AttributeID: "value-1", Rating: 3}}
+
+	t.Run("It should submit the self-assessment and pass the review to the manager", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs:      []dynamodb.QueryOutput{reviewQueryOutput(t, reviewTestRecord(t, selfStage)), reviewQueryOutput(t, reviewTestQuarter(ReviewWindowOpen))},
+			QueryErrors:       []error{nil, nil},
+			PutItemOutputs:    []dynamodb.PutItemOutput{{}},
+			PutItemErrors:     []error{nil},
+			UpdateItemOutputs: []dynamodb.UpdateItemOutput{{}},
+			UpdateItemErrors:  []error{nil},
+		}
+		svc := newReviewTestService(&ddbClient)
+
+		review, err := svc.SaveSelfAssessment("review-1", "alice@example.com", ReviewAssessmentInput{Summary: "Shipped billing", Ratings: ratings, Submit: true})
+
+		assert.NoError(t, err)
+		assert.Equal(t, ReviewStatusManagerAssessment, review.Status)
+		assert.NotEqual(t, "", review.SelfAssessment.SubmittedAt)
+
+		put := ddbClient.PutItemInputs[0]
+		assert.Equal(t, "#status = :expected AND attribute_not_exists(#data.#version)", *put.ConditionExpression)
+		assert.Equal(t, &dynamodb_types.AttributeValueMemberS{Value: ReviewStatusSelfAssessment}, put.ExpressionAttributeValues[":expected"])
+		assert.Equal(t, 1, reviewFromPut(t, put).Version)
+		assert.Equal(t, &dynamodb_types.AttributeValueMemberBOOL{Value: true}, ddbClient.UpdateItemInputs[0].ExpressionAttributeValues[":selfSubmitted"])
+	})
+
+	t.Run("It should only let the member write their self-assessment", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs: []dynamodb.QueryOutput{reviewQueryOutput(t, reviewTestRecord(t, selfStage))},
+			QueryErrors:  []error{nil},
+		}
+		svc := newReviewTestService(&ddbClient)
+
+		_, err := svc.SaveSelfAssessment("review-1", "bob@example.com", ReviewAssessmentInput{Summary: "x"})
+
+		assert.ErrorIs(t, err, ErrReviewForbidden)
+	})
+
+	t.Run("It should reject assessments once the window is closed", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs: []dynamodb.QueryOutput{reviewQueryOutput(t, reviewTestRecord(t, selfStage)), reviewQueryOutput(t, reviewTestQuarter(ReviewWindowClosed))},
+			QueryErrors:  []error{nil, nil},
+		}
+		svc := newReviewTestService(&ddbClient)
+
+		_, err := svc.SaveSelfAssessment("review-1", "alice@example.com", ReviewAssessmentInput{Summary: "x"})
+
+		assert.ErrorIs(t, err, ErrReviewWindowClosed)
+	})
+
+	t.Run("It should require every competency to be rated to submit", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs: []dynamodb.QueryOutput{reviewQueryOutput(t, reviewTestRecord(t, selfStage)), reviewQueryOutput(t, reviewTestQuarter(ReviewWindowOpen))},
+			QueryErrors:  []error{nil, nil},
+		}
+		svc := newReviewTestService(&ddbClient)
+
+		_, err := svc.SaveSelfAssessment("review-1", "alice@example.com", ReviewAssessmentInput{Summary: "x", Ratings: ratings[:1], Submit: true})
+
+		assert.ErrorIs(t, err, ErrReviewInvalid)
+		assert.Contains(t, err.Error(), "every competency must be rated to submit")
+		assert.Empty(t, ddbClient.PutItemInputs)
+	})
+
+	t.Run("It should only save over the version it read", func(t *testing.T) {
+		saved := selfStage
+		saved.Version = 3
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs:      []dynamodb.QueryOutput{reviewQueryOutput(t, reviewTestRecord(t, saved)), reviewQueryOutput(t, reviewTestQuarter(ReviewWindowOpen))},
+			QueryErrors:       []error{nil, nil},
+			PutItemOutputs:    []dynamodb.PutItemOutput{{}},
+			PutItemErrors:     []error{nil},
+			UpdateItemOutputs: []dynamodb.UpdateItemOutput{{}},
+			UpdateItemErrors:  []error{nil},
+		}
+		svc := newReviewTestService(&ddbClient)
+
+		review, err := svc.SaveSelfAssessment("review-1", "alice@example.com", ReviewAssessmentInput{Summary: "Draft"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 4, review.Version)
+		put := ddbClient.PutItemInputs[0]
+		assert.Equal(t, "#status = :expected AND #data.#version = :version", *put.ConditionExpression)
+		assert.Equal(t, &dynamodb_types.AttributeValueMemberN{Value: "3"}, put.ExpressionAttributeValues[":version"])
+	})
+
+	t.Run("It should report a conflict when the review changed concurrently", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs:   []dynamodb.QueryOutput{reviewQueryOutput(t, reviewTestRecord(t, selfStage)), reviewQueryOutput(t, reviewTestQuarter(ReviewWindowOpen))},
+			QueryErrors:    []error{nil, nil},
+			PutItemOutputs: []dynamodb.PutItemOutput{{}},
+			PutItemErrors:  []error{&dynamodb_types.ConditionalCheckFailedException{}},
+		}
+		svc := newReviewTestService(&ddbClient)
+
+		_, err := svc.SaveSelfAssessment("review-1", "alice@example.com", ReviewAssessmentInput{Summary: "x"})
+
+		assert.ErrorIs(t, err, ErrReviewConflict)
+	})
+}
+
+func TestSaveManagerAssessment(t *testing.T) {
+	t.Run("It should default the overall rating to the mean of the competency ratings", func(t *testing.T) {
+		record := reviewTestRecord(t, PerformanceReview{Status: ReviewStatusManagerAssessment, Competencies: reviewTestCompetencies})
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs:      []dynamodb.QueryOutput{reviewQueryOutput(t, record), reviewQueryOutput(t, reviewTestQuarter(ReviewWindowOpen))},
+			QueryErrors:       []error{nil, nil},
+			PutItemOutputs:    []dynamodb.PutItemOutput{{}},
+			PutItemErrors:     []error{nil},
+			UpdateItemOutputs: []dynamodb.UpdateItemOutput{{}},
+			UpdateItemErrors:  []error{nil},
+		}
+		svc := newReviewTestService(&ddbClient)
+
+		review, err := svc.SaveManagerAssessment("review-1", "manager@example.com", ReviewAssessmentInput{
+			Summary: "Strong quarter",
+			Ratings: []CompetencyRating{{AttributeID: "skill-1", Rating: 4}, {AttributeID: "value-1", Rating: 3.5}},
+			Submit:  true,
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3.8, review.ManagerAssessment.OverallRating)
+		assert.Equal(t, ReviewStatusCalibration, review.Status)
+		assert.Equal(t, "manager@example.com", review.ManagerAssessment.AuthorID)
+	})
+
+	t.Run("It should not submit before the member's self-assessment", func(t *testing.T) {
+		record := reviewTestRecord(t, PerformanceReview{Status: ReviewStatusSelfAssessment})
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs: []dynamodb.QueryOutput{reviewQueryOutput(t, record)},
+			QueryErrors:  []error{nil},
+		}
+		svc := newReviewTestService(&ddbClient)
+
+		rating := 4.0
+		_, err := svc.SaveManagerAssessment("review-1", "manager@example.com", ReviewAssessmentInput{OverallRating: &rating, Submit: true})
+
+		assert.ErrorIs(t, err, ErrReviewStage)
+	})
+
+	t.Run("It should reject ratings outside the scale", func(t *testing.T) {
+		record := reviewTestRecord(t, PerformanceReview{Status: ReviewStatusManagerAssessment, Competencies: reviewTestCompetencies})
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs: []dynamodb.QueryOutput{reviewQueryOutput(t, record), reviewQueryOutput(t, reviewTestQuarter(ReviewWindowOpen))},
+			QueryErrors:  []error{nil, nil},
+		}
+		svc := newReviewTestService(&ddbClient)
+
+		_, err := svc.SaveManagerAssessment("review-1", "manager@example.com", ReviewAssessmentInput{Ratings: []CompetencyRating{{AttributeID: "skill-1", Rating: 6}}})
+
+		assert.ErrorIs(t, err, ErrReviewInvalid)
+		assert.Contains(t, err.Error(), "ratings must be between 1 and 5")
+	})
+}
+
+func TestCalibrateAndSignOffReview(t *testing.T) {
+	t.Run("It should calibrate the final rating and move to sign-off", func(t *testing.T) {
+		record := reviewTestRecord(t, PerformanceReview{Status: ReviewStatusCalibration, ManagerAssessment: &ReviewAssessment{OverallRating: 3.8}})
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs:      []dynamodb.QueryOutput{reviewQueryOutput(t, record)},
+			QueryErrors:       []error{nil},
+			PutItemOutputs:    []dynamodb.PutItemOutput{{}},
+			PutItemErrors:     []error{nil},
+			UpdateItemOutputs: []dynamodb.UpdateItemOutput{{}},
+			UpdateItemErrors:  []error{nil},
+		}
+		svc := newReviewTestService(&ddbClient)
+
+		rating := 4.0
+		review, err := svc.CalibrateReview("review-1", "admin@example.com", &rating, "Aligned with peers")
+
+		assert.NoError(t, err)
+		assert.Equal(t, ReviewStatusSignOff, review.Status)
+		assert.Equal(t, 4.0, review.FinalRating)
+		assert.Equal(t, 3.8, review.Calibration.ManagerRating)
+	})
+
+	t.Run("It should lock the review once both sides have signed off", func(t *testing.T) {
+		record := reviewTestRecord(t, PerformanceReview{Status: ReviewStatusSignOff, FinalRating: 4, MemberSignedOffAt: "2025-03-20T00:00:00Z"})
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs:      []dynamodb.QueryOutput{reviewQueryOutput(t, record)},
+			QueryErrors:       []error{nil},
+			PutItemOutputs:    []dynamodb.PutItemOutput{{}},
+			PutItemErrors:     []error{nil},
+			UpdateItemOutputs: []dynamodb.UpdateItemOutput{{}},
+			UpdateItemErrors:  []error{nil},
+		}
+		svc := newReviewTestService(&ddbClient)
+
+		review, err := svc.SignOffReview("review-1", "manager@example.com", true, "")
+
+		assert.NoError(t, err)
+		assert.Equal(t, ReviewStatusLocked, review.Status)
+		assert.Equal(t, review.ManagerSignedOffAt, review.LockedAt)
+
+		summary := ddbClient.UpdateItemInputs[0]
+		assert.Contains(t, *summary.UpdateExpression, "overallRating = :rating, lastReviewDate = :reviewDate")
+		assert.Equal(t, &dynamodb_types.AttributeValueMemberN{Value: "4"}, summary.ExpressionAttributeValues[":rating"])
+		assert.Equal(t, &dynamodb_types.AttributeValueMemberBOOL{Value: false}, summary.ExpressionAttributeValues[":pending"])
+	})
+
+	t.Run("It should not let the member sign off as the manager", func(t *testing.T) {
+		record := reviewTestRecord(t, PerformanceReview{Status: ReviewStatusSignOff})
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs: []dynamodb.QueryOutput{reviewQueryOutput(t, record)},
+			QueryErrors:  []error{nil},
+		}
+		svc := newReviewTestService(&ddbClient)
+
+		_, err := svc.SignOffReview("review-1", "alice@example.com", true, "")
+
+		assert.ErrorIs(t, err, ErrReviewForbidden)
+	})
+
+	t.Run("It should reject changes to a locked review", func(t *testing.T) {
+		record := reviewTestRecord(t, PerformanceReview{Status: ReviewStatusLocked, ManagerAssessment: &ReviewAssessment{OverallRating: 3}})
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs: []dynamodb.QueryOutput{reviewQueryOutput(t, record)},
+			QueryErrors:  []error{nil},
+		}
+		svc := newReviewTestService(&ddbClient)
+
+		_, err := svc.CalibrateReview("review-1", "admin@example.com", nil, "")
+
+		assert.ErrorIs(t, err, ErrReviewLocked)
+	})
+
+	t.Run("It should leave the summary alone when a newer review owns it", func(t *testing.T) {
+		record := reviewTestRecord(t, PerformanceReview{Status: ReviewStatusSignOff, ManagerSignedOffAt: "2025-03-20T00:00:00Z"})
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs:      []dynamodb.QueryOutput{reviewQueryOutput(t, record)},
+			QueryErrors:       []error{nil},
+			PutItemOutputs:    []dynamodb.PutItemOutput{{}},
+			PutItemErrors:     []error{nil},
+			UpdateItemOutputs: []dynamodb.UpdateItemOutput{{}},
+			UpdateItemErrors:  []error{&dynamodb_types.ConditionalCheckFailedException{}},
+		}
+		svc := newReviewTestService(&ddbClient)
+
+		review, err := svc.SignOffReview("review-1", "alice@example.com", false, "Agreed")
+
+		assert.NoError(t, err)
+		assert.Equal(t, ReviewStatusLocked, review.Status)
+	})
+}
+
+func TestGetQuarterDetailsPendingReviews(t *testing.T) {
+	locked := reviewTestRecord(t, PerformanceReview{ID: "r-locked", MemberUserName: "carol@example.com", Status: ReviewStatusLocked})
+	signOff := reviewTestRecord(t, PerformanceReview{ID: "r-sign", MemberUserName: "bob@example.com", Status: ReviewStatusSignOff, MemberSignedOffAt: "2025-03-20T00:00:00Z"})
+	self := reviewTestRecord(t, PerformanceReview{ID: "r-self", MemberUserName: "alice@example.com", Status: ReviewStatusSelfAssessment})
+	ddbClient := awsclients.MockDynamodbClient{
+		QueryOutputs: []dynamodb.QueryOutput{reviewQueryOutput(t, reviewTestQuarter(ReviewWindowOpen)), reviewQueryOutput(t, reviewTestQuarter(ReviewWindowOpen), locked, signOff, self)},
+		QueryErrors:  []error{nil, nil},
+	}
+	svc := newReviewTestService(&ddbClient)
+
+	result, err := svc.GetQuarterDetails("q-1", false, false, false, true)
+
+	assert.NoError(t, err)
+	pending := result["pendingReviews"].([]map[string]interface{})
+	assert.Len(t, pending, 2)
+	assert.Equal(t, "r-self", pending[0]["reviewId"])
+	assert.Equal(t, []string{"member"}, pending[0]["awaiting"])
+	assert.Equal(t, "r-sign", pending[1]["reviewId"])
+	assert.Equal(t, []string{"manager"}, pending[1]["awaiting"])
+}
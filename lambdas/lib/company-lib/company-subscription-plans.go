@@ -0,0 +1,703 @@
+package Companylib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	awsclients "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/clients"
+)
+
+// DefaultPlanCacheTTL is how long a Lambda container serves plans from memory before re-reading the catalogue
+const DefaultPlanCacheTTL = 5 * time.Minute
+
+// DefaultTrialPlanID is the plan new organizations start their trial on unless another is configured
+const DefaultTrialPlanID = "starter"
+
+var (
+	ErrSubscriptionPlanNotFound = errors.New("subscription plan not found")
+	ErrSubscriptionPlanExists   = errors.New("subscription plan already exists")
+	ErrSubscriptionPlanRetired  = errors.New("subscription plan is retired")
+	ErrDefaultPlanUnavailable   = errors.New("default subscription plan is not available")
+)
+
+// SubscriptionPlanStatus represents the lifecycle of a plan version
+type SubscriptionPlanStatus string
+
+const (
+	SubscriptionPlanStatusActive  SubscriptionPlanStatus = "ACTIVE"  // Current version, open to new subscriptions
+	SubscriptionPlanStatusRetired SubscriptionPlanStatus = "RETIRED" // Superseded or withdrawn, existing orgs are grandfathered
+)
+
+// Feature flags the APIs check against an organization's plan. Flags are free-form strings so new
+// entitlements can be added to the catalogue without a code change; these are the ones in use.
+const (
+	PlanFeatureAnalyticsDashboard = "analytics_dashboard"
+	PlanFeatureAdvancedAnalytics  = "advanced_analytics"
+	PlanFeaturePrioritySupport    = "priority_support"
+	PlanFeatureCustomIntegrations = "custom_integrations"
+)
+
+// PlanPrice holds a plan's price in one currency
+type PlanPrice struct {
+	Monthly float64 `dynamodbav:"Monthly" json:"monthly"`
+	Yearly  float64 `dynamodbav:"Yearly" json:"yearly"`
+}
+
+// SubscriptionPlanInput is the admin payload used to create a plan or publish a new version of one
+type SubscriptionPlanInput struct {
+	PlanID          string               `json:"planId"`
+	PlanName        string               `json:"planName"`
+	PlanDescription string               `json:"planDescription"`
+	MaxTeams        int                  `json:"maxTeams"`   // -1 for unlimited
+	MaxMembers      int                  `json:"maxMembers"` // -1 for unlimited
+	DisplayOrder    int                  `json:"displayOrder"`
+	Prices          map[string]PlanPrice `json:"prices"`
+	Features        []string             `json:"features"`
+	FeatureFlags    map[string]bool      `json:"featureFlags"`
+}
+
+var (
+	planIdPattern   = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,49}$`)
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+)
+
+// PriceFor returns the plan's price in a currency for the given billing frequency. Plans built
+// without a price table are treated as priced in DefaultBillingCurrency only.
+func (plan SubscriptionPlan) PriceFor(currency string, billingPlan BillingPlan) (float64, bool) {
+	price, ok := plan.Prices[currency]
+	if !ok {
+		if len(plan.Prices) > 0 || currency != DefaultBillingCurrency {
+			return 0, false
+		}
+		price = PlanPrice{Monthly: plan.MonthlyPrice, Yearly: plan.YearlyPrice}
+	}
+
+	if billingPlan == BillingPlanYearly {
+		return price.Yearly, true
+	}
+	return price.Monthly, true
+}
+
+// HasFeature reports whether the plan enables a feature flag
+func (plan SubscriptionPlan) HasFeature(feature string) bool {
+	return plan.FeatureFlags[feature]
+}
+
+// effectivePlanVersion maps the version stored on an organization to a catalogue version.
+// Organizations created before the catalogue have no version and are billed at version 1.
+func effectivePlanVersion(version int) int {
+	if version <= 0 {
+		return 1
+	}
+	return version
+}
+
+func planPartitionKey(planID string) string {
+	return fmt.Sprintf("PLAN#%s", planID)
+}
+
+func planVersionSortKey(version int) string {
+	return fmt.Sprintf("VERSION#%05d", version)
+}
+
+// DefaultSubscriptionPlans returns version 1 of the built-in plans. A built-in plan is served from
+// here until it has versions in the catalogue table; its version 1 is written to the table with the
+// first admin change to it (see PublishPlanVersion and RetirePlan).
+func DefaultSubscriptionPlans() []SubscriptionPlan {
+	plans := []SubscriptionPlan{
+		{
+			PlanID:          "starter",
+			PlanName:        "Starter Plan",
+			PlanDescription: "Perfect for small teams just getting started",
+			MaxTeams:        5,
+			MaxMembers:      25,
+			DisplayOrder:    1,
+			Prices:          map[string]PlanPrice{DefaultBillingCurrency: {Monthly: 29.99, Yearly: 299.99}},
+			Features:        []string{"Basic team management", "Email support", "5 teams", "25 members"},
+			FeatureFlags:    map[string]bool{},
+		},
+		{
+			PlanID:          "professional",
+			PlanName:        "Professional Plan",
+			PlanDescription: "Great for growing organizations",
+			MaxTeams:        25,
+			MaxMembers:      150,
+			DisplayOrder:    2,
+			Prices:          map[string]PlanPrice{DefaultBillingCurrency: {Monthly: 79.99, Yearly: 799.99}},
+			Features:        []string{"Advanced team management", "Priority support", "25 teams", "150 members", "Analytics dashboard"},
+			FeatureFlags: map[string]bool{
+				PlanFeatureAnalyticsDashboard: true,
+				PlanFeaturePrioritySupport:    true,
+			},
+		},
+		{
+			PlanID:          "enterprise",
+			PlanName:        "Enterprise Plan",
+			PlanDescription: "For large organizations with advanced needs",
+			MaxTeams:        -1, // Unlimited
+			MaxMembers:      -1, // Unlimited
+			DisplayOrder:    3,
+			Prices:          map[string]PlanPrice{DefaultBillingCurrency: {Monthly: 199.99, Yearly: 1999.99}},
+			Features:        []string{"Unlimited teams", "Unlimited members", "24/7 support", "Custom integrations", "Advanced analytics"},
+			FeatureFlags: map[string]bool{
+				PlanFeatureAnalyticsDashboard: true,
+				PlanFeatureAdvancedAnalytics:  true,
+				PlanFeaturePrioritySupport:    true,
+				PlanFeatureCustomIntegrations: true,
+			},
+		},
+	}
+
+	for i := range plans {
+		plans[i].PK = planPartitionKey(plans[i].PlanID)
+		plans[i].SK = planVersionSortKey(1)
+		plans[i].Version = 1
+		plans[i].Status = SubscriptionPlanStatusActive
+		plans[i] = withDisplayPrices(plans[i])
+	}
+	return plans
+}
+
+// builtInPlan returns version 1 of a built-in plan
+func builtInPlan(planID string) (SubscriptionPlan, bool) {
+	for _, plan := range DefaultSubscriptionPlans() {
+		if plan.PlanID == planID {
+			return plan, true
+		}
+	}
+	return SubscriptionPlan{}, false
+}
+
+// withBuiltInPlans adds the built-in plans that have no versions in the catalogue table
+func withBuiltInPlans(plans []SubscriptionPlan) []SubscriptionPlan {
+	stored := map[string]bool{}
+	for _, plan := range plans {
+		stored[plan.PlanID] = true
+	}
+	for _, plan := range DefaultSubscriptionPlans() {
+		if !stored[plan.PlanID] {
+			plans = append(plans, plan)
+		}
+	}
+
+	sort.Slice(plans, func(i, j int) bool {
+		if plans[i].PlanID != plans[j].PlanID {
+			return plans[i].PlanID < plans[j].PlanID
+		}
+		return plans[i].Version < plans[j].Version
+	})
+	return plans
+}
+
+// withDisplayPrices fills MonthlyPrice/YearlyPrice from the default currency price
+func withDisplayPrices(plan SubscriptionPlan) SubscriptionPlan {
+	plan.Currency = DefaultBillingCurrency
+	if price, ok := plan.Prices[DefaultBillingCurrency]; ok {
+		plan.MonthlyPrice = price.Monthly
+		plan.YearlyPrice = price.Yearly
+	}
+	return plan
+}
+
+// SubscriptionPlanService reads and maintains the versioned subscription plan catalogue.
+// Reads are served from an in-memory cache that is refreshed every CacheTTL.
+type SubscriptionPlanService struct {
+	ctx            context.Context
+	dynamodbClient awsclients.DynamodbClient
+	logger         *log.Logger
+
+	SubscriptionPlansTable string
+	CacheTTL               time.Duration
+
+	mu          sync.Mutex
+	cachedPlans []SubscriptionPlan
+	cachedAt    time.Time
+}
+
+// CreateSubscriptionPlanService creates a new subscription plan catalogue service
+func CreateSubscriptionPlanService(ctx context.Context, ddbClient awsclients.DynamodbClient, logger *log.Logger) *SubscriptionPlanService {
+	return &SubscriptionPlanService{
+		ctx:            ctx,
+		dynamodbClient: ddbClient,
+		logger:         logger,
+		CacheTTL:       DefaultPlanCacheTTL,
+	}
+}
+
+// InvalidateCache forces the next read to reload the catalogue
+func (svc *SubscriptionPlanService) InvalidateCache() {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	svc.cachedPlans = nil
+	svc.cachedAt = time.Time{}
+}
+
+// allPlanVersions returns every version of every plan, ordered by plan and version
+func (svc *SubscriptionPlanService) allPlanVersions() ([]SubscriptionPlan, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	if svc.cachedPlans != nil && time.Since(svc.cachedAt) < svc.CacheTTL {
+		return svc.cachedPlans, nil
+	}
+
+	plans, err := svc.scanCatalogue()
+	if err != nil {
+		return nil, err
+	}
+	plans = withBuiltInPlans(plans)
+
+	svc.cachedPlans = plans
+	svc.cachedAt = time.Now()
+	return plans, nil
+}
+
+func (svc *SubscriptionPlanService) scanCatalogue() ([]SubscriptionPlan, error) {
+	if svc.SubscriptionPlansTable == "" {
+		return nil, nil
+	}
+
+	scanInput := &dynamodb.ScanInput{
+		TableName: aws.String(svc.SubscriptionPlansTable),
+	}
+
+	plans := []SubscriptionPlan{}
+	for {
+		result, err := svc.dynamodbClient.Scan(svc.ctx, scanInput)
+		if err != nil {
+			svc.logger.Printf("Failed to scan subscription plans: %v", err)
+			return nil, fmt.Errorf("failed to scan subscription plans: %w", err)
+		}
+
+		var page []SubscriptionPlan
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal subscription plans: %w", err)
+		}
+		plans = append(plans, page...)
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		scanInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	for i := range plans {
+		plans[i] = withDisplayPrices(plans[i])
+	}
+
+	return plans, nil
+}
+
+// ListPlans returns the latest version of each plan ordered for display. Retired plans are only
+// included when includeRetired is set.
+func (svc *SubscriptionPlanService) ListPlans(includeRetired bool) ([]SubscriptionPlan, error) {
+	versions, err := svc.allPlanVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	latest := map[string]SubscriptionPlan{}
+	for _, plan := range versions {
+		if current, ok := latest[plan.PlanID]; !ok || plan.Version > current.Version {
+			latest[plan.PlanID] = plan
+		}
+	}
+
+	plans := []SubscriptionPlan{}
+	for _, plan := range latest {
+		if plan.Status == SubscriptionPlanStatusActive || includeRetired {
+			plans = append(plans, plan)
+		}
+	}
+	sort.Slice(plans, func(i, j int) bool {
+		if plans[i].DisplayOrder != plans[j].DisplayOrder {
+			return plans[i].DisplayOrder < plans[j].DisplayOrder
+		}
+		return plans[i].PlanID < plans[j].PlanID
+	})
+
+	return plans, nil
+}
+
+// GetPlanVersions returns every version of a plan, oldest first
+func (svc *SubscriptionPlanService) GetPlanVersions(planID string) ([]SubscriptionPlan, error) {
+	versions, err := svc.allPlanVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	plans := []SubscriptionPlan{}
+	for _, plan := range versions {
+		if plan.PlanID == planID {
+			plans = append(plans, plan)
+		}
+	}
+	if len(plans) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrSubscriptionPlanNotFound, planID)
+	}
+
+	return plans, nil
+}
+
+// GetCurrentPlan returns the active version of a plan
+func (svc *SubscriptionPlanService) GetCurrentPlan(planID string) (*SubscriptionPlan, error) {
+	versions, err := svc.allPlanVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, plan := range versions {
+		if plan.PlanID == planID && plan.Status == SubscriptionPlanStatusActive {
+			return &plan, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrSubscriptionPlanNotFound, planID)
+}
+
+// GetPlanVersion returns a specific version of a plan, whether or not it is still active. A version
+// published by another container since the cache was loaded triggers one reload.
+func (svc *SubscriptionPlanService) GetPlanVersion(planID string, version int) (*SubscriptionPlan, error) {
+	version = effectivePlanVersion(version)
+
+	for attempt := 0; attempt < 2; attempt++ {
+		versions, err := svc.allPlanVersions()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, plan := range versions {
+			if plan.PlanID == planID && plan.Version == version {
+				return &plan, nil
+			}
+		}
+
+		if attempt == 0 {
+			svc.InvalidateCache()
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s version %d", ErrSubscriptionPlanNotFound, planID, version)
+}
+
+// CreatePlan adds a new plan to the catalogue as version 1
+func (svc *SubscriptionPlanService) CreatePlan(input SubscriptionPlanInput, createdBy string) (*SubscriptionPlan, error) {
+	if !planIdPattern.MatchString(input.PlanID) {
+		return nil, fmt.Errorf("invalid plan ID %q: use 2-50 lowercase letters, digits or hyphens", input.PlanID)
+	}
+	if _, ok := builtInPlan(input.PlanID); ok {
+		return nil, fmt.Errorf("%w: %s is a built-in plan, publish a new version of it instead", ErrSubscriptionPlanExists, input.PlanID)
+	}
+
+	plan, err := newPlanVersion(input.PlanID, 1, input, createdBy)
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := attributevalue.MarshalMap(plan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal subscription plan: %w", err)
+	}
+
+	_, err = svc.dynamodbClient.PutItem(svc.ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(svc.SubscriptionPlansTable),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(PK)"),
+	})
+	if err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return nil, fmt.Errorf("%w: %s", ErrSubscriptionPlanExists, input.PlanID)
+		}
+		svc.logger.Printf("Failed to create subscription plan %s: %v", input.PlanID, err)
+		return nil, fmt.Errorf("failed to create subscription plan: %w", err)
+	}
+
+	svc.InvalidateCache()
+	svc.logger.Printf("Created subscription plan %s v1", input.PlanID)
+
+	created := withDisplayPrices(plan)
+	return &created, nil
+}
+
+// PublishPlanVersion publishes a new version of an existing plan and retires the previous active
+// version. Organizations on earlier versions keep them until they change plan. Publishing a new
+// version of a retired plan re-opens it to new subscriptions.
+func (svc *SubscriptionPlanService) PublishPlanVersion(planID string, input SubscriptionPlanInput, createdBy string) (*SubscriptionPlan, error) {
+	versions, seedBuiltIn, err := svc.planVersionsForUpdate(planID)
+	if err != nil {
+		return nil, err
+	}
+	previous := versions[len(versions)-1]
+
+	plan, err := newPlanVersion(planID, previous.Version+1, input, createdBy)
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := attributevalue.MarshalMap(plan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal subscription plan: %w", err)
+	}
+
+	transactItems := []types.TransactWriteItem{
+		{
+			Put: &types.Put{
+				TableName:           aws.String(svc.SubscriptionPlansTable),
+				Item:                item,
+				ConditionExpression: aws.String("attribute_not_exists(PK)"),
+			},
+		},
+	}
+	if seedBuiltIn {
+		seed, err := svc.seedRetiredVersionPut(previous, plan.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		transactItems = append(transactItems, types.TransactWriteItem{Put: seed})
+	} else if previous.Status == SubscriptionPlanStatusActive {
+		transactItems = append(transactItems, types.TransactWriteItem{
+			Update: svc.retireVersionUpdate(previous, plan.CreatedAt),
+		})
+	}
+
+	_, err = svc.dynamodbClient.TransactWriteItems(svc.ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: transactItems,
+	})
+	if err != nil {
+		var cancelled *types.TransactionCanceledException
+		if errors.As(err, &cancelled) {
+			return nil, fmt.Errorf("plan %s was changed by another request, reload and try again: %w", planID, err)
+		}
+		svc.logger.Printf("Failed to publish subscription plan %s v%d: %v", planID, plan.Version, err)
+		return nil, fmt.Errorf("failed to publish subscription plan: %w", err)
+	}
+
+	svc.InvalidateCache()
+	svc.logger.Printf("Published subscription plan %s v%d", planID, plan.Version)
+
+	published := withDisplayPrices(plan)
+	return &published, nil
+}
+
+// RetirePlan withdraws a plan from new subscriptions. Organizations already on it are grandfathered.
+func (svc *SubscriptionPlanService) RetirePlan(planID string, retiredBy string) error {
+	versions, seedBuiltIn, err := svc.planVersionsForUpdate(planID)
+	if err != nil {
+		return err
+	}
+
+	current := versions[len(versions)-1]
+	if current.Status != SubscriptionPlanStatusActive {
+		return fmt.Errorf("%w: %s", ErrSubscriptionPlanRetired, planID)
+	}
+
+	retiredAt := time.Now().UTC().Format(time.RFC3339)
+	if seedBuiltIn {
+		seed, err := svc.seedRetiredVersionPut(current, retiredAt)
+		if err != nil {
+			return err
+		}
+		_, err = svc.dynamodbClient.PutItem(svc.ctx, &dynamodb.PutItemInput{
+			TableName:           seed.TableName,
+			Item:                seed.Item,
+			ConditionExpression: seed.ConditionExpression,
+		})
+		if err != nil {
+			var conditionFailed *types.ConditionalCheckFailedException
+			if errors.As(err, &conditionFailed) {
+				return fmt.Errorf("plan %s was changed by another request, reload and try again: %w", planID, err)
+			}
+			svc.logger.Printf("Failed to retire subscription plan %s: %v", planID, err)
+			return fmt.Errorf("failed to retire subscription plan: %w", err)
+		}
+
+		svc.InvalidateCache()
+		svc.logger.Printf("Subscription plan %s v%d retired by %s", planID, current.Version, retiredBy)
+		return nil
+	}
+
+	update := svc.retireVersionUpdate(current, retiredAt)
+	_, err = svc.dynamodbClient.UpdateItem(svc.ctx, &dynamodb.UpdateItemInput{
+		TableName:                 update.TableName,
+		Key:                       update.Key,
+		UpdateExpression:          update.UpdateExpression,
+		ConditionExpression:       update.ConditionExpression,
+		ExpressionAttributeNames:  update.ExpressionAttributeNames,
+		ExpressionAttributeValues: update.ExpressionAttributeValues,
+	})
+	if err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return fmt.Errorf("%w: %s", ErrSubscriptionPlanRetired, planID)
+		}
+		svc.logger.Printf("Failed to retire subscription plan %s: %v", planID, err)
+		return fmt.Errorf("failed to retire subscription plan: %w", err)
+	}
+
+	svc.InvalidateCache()
+	svc.logger.Printf("Subscription plan %s v%d retired by %s", planID, current.Version, retiredBy)
+	return nil
+}
+
+// planVersionsForUpdate returns a plan's stored versions, oldest first. A built-in plan that has no
+// versions in the table yet is returned as its default version 1 with seedBuiltIn set, and the caller
+// writes that version along with its change.
+func (svc *SubscriptionPlanService) planVersionsForUpdate(planID string) (versions []SubscriptionPlan, seedBuiltIn bool, err error) {
+	versions, err = svc.queryPlanVersions(planID)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(versions) > 0 {
+		return versions, false, nil
+	}
+
+	plan, ok := builtInPlan(planID)
+	if !ok {
+		return nil, false, fmt.Errorf("%w: %s", ErrSubscriptionPlanNotFound, planID)
+	}
+	return []SubscriptionPlan{plan}, true, nil
+}
+
+// seedRetiredVersionPut writes a built-in plan's default version 1 to the table as retired. It only
+// succeeds while the plan has no stored versions.
+func (svc *SubscriptionPlanService) seedRetiredVersionPut(plan SubscriptionPlan, retiredAt string) (*types.Put, error) {
+	plan.Status = SubscriptionPlanStatusRetired
+	plan.RetiredAt = retiredAt
+	plan.CreatedBy = "system"
+
+	item, err := attributevalue.MarshalMap(plan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal subscription plan: %w", err)
+	}
+
+	return &types.Put{
+		TableName:           aws.String(svc.SubscriptionPlansTable),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(PK)"),
+	}, nil
+}
+
+// queryPlanVersions reads a plan's versions straight from the table, bypassing the cache, so that
+// admin changes are made against the latest state
+func (svc *SubscriptionPlanService) queryPlanVersions(planID string) ([]SubscriptionPlan, error) {
+	queryInput := &dynamodb.QueryInput{
+		TableName:              aws.String(svc.SubscriptionPlansTable),
+		KeyConditionExpression: aws.String("PK = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: planPartitionKey(planID)},
+		},
+		ScanIndexForward: aws.Bool(true),
+	}
+
+	versions := []SubscriptionPlan{}
+	for {
+		result, err := svc.dynamodbClient.Query(svc.ctx, queryInput)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query subscription plan versions: %w", err)
+		}
+
+		var page []SubscriptionPlan
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal subscription plan versions: %w", err)
+		}
+		versions = append(versions, page...)
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		queryInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	return versions, nil
+}
+
+func (svc *SubscriptionPlanService) retireVersionUpdate(plan SubscriptionPlan, retiredAt string) *types.Update {
+	return &types.Update{
+		TableName: aws.String(svc.SubscriptionPlansTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: plan.PK},
+			"SK": &types.AttributeValueMemberS{Value: plan.SK},
+		},
+		UpdateExpression:         aws.String("SET #status = :retired, RetiredAt = :retiredAt"),
+		ConditionExpression:      aws.String("#status = :active"),
+		ExpressionAttributeNames: map[string]string{"#status": "Status"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":retired":   &types.AttributeValueMemberS{Value: string(SubscriptionPlanStatusRetired)},
+			":active":    &types.AttributeValueMemberS{Value: string(SubscriptionPlanStatusActive)},
+			":retiredAt": &types.AttributeValueMemberS{Value: retiredAt},
+		},
+	}
+}
+
+// newPlanVersion validates an admin payload and builds the catalogue item for a plan version
+func newPlanVersion(planID string, version int, input SubscriptionPlanInput, createdBy string) (SubscriptionPlan, error) {
+	if strings.TrimSpace(input.PlanName) == "" {
+		return SubscriptionPlan{}, fmt.Errorf("invalid plan: planName is required")
+	}
+	if input.MaxTeams == 0 || input.MaxTeams < -1 {
+		return SubscriptionPlan{}, fmt.Errorf("invalid plan: maxTeams must be positive or -1 for unlimited")
+	}
+	if input.MaxMembers == 0 || input.MaxMembers < -1 {
+		return SubscriptionPlan{}, fmt.Errorf("invalid plan: maxMembers must be positive or -1 for unlimited")
+	}
+
+	// Every plan is priced in the default currency so existing organizations can always be billed
+	if _, ok := input.Prices[DefaultBillingCurrency]; !ok {
+		return SubscriptionPlan{}, fmt.Errorf("invalid plan: a %s price is required", DefaultBillingCurrency)
+	}
+	prices := map[string]PlanPrice{}
+	for currency, price := range input.Prices {
+		if !currencyPattern.MatchString(currency) {
+			return SubscriptionPlan{}, fmt.Errorf("invalid plan: unknown currency code %q", currency)
+		}
+		if price.Monthly < 0 || price.Yearly < 0 {
+			return SubscriptionPlan{}, fmt.Errorf("invalid plan: %s prices cannot be negative", currency)
+		}
+		prices[currency] = PlanPrice{Monthly: roundCurrency(price.Monthly), Yearly: roundCurrency(price.Yearly)}
+	}
+
+	flags := map[string]bool{}
+	for feature, enabled := range input.FeatureFlags {
+		feature = strings.TrimSpace(feature)
+		if feature == "" {
+			return SubscriptionPlan{}, fmt.Errorf("invalid plan: feature flag keys cannot be empty")
+		}
+		flags[feature] = enabled
+	}
+
+	features := input.Features
+	if features == nil {
+		features = []string{}
+	}
+
+	return SubscriptionPlan{
+		PK:              planPartitionKey(planID),
+		SK:              planVersionSortKey(version),
+		PlanID:          planID,
+		Version:         version,
+		Status:          SubscriptionPlanStatusActive,
+		PlanName:        strings.TrimSpace(input.PlanName),
+		PlanDescription: input.PlanDescription,
+		MaxTeams:        input.MaxTeams,
+		MaxMembers:      input.MaxMembers,
+		DisplayOrder:    input.DisplayOrder,
+		Prices:          prices,
+		Features:        features,
+		FeatureFlags:    flags,
+		CreatedAt:       time.Now().UTC().Format(time.RFC3339),
+		CreatedBy:       createdBy,
+	}, nil
+}
@@ -0,0 +1,355 @@
+package Companylib
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodb_types "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	awsclients "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/clients"
+	"github.com/stretchr/testify/assert"
+)
+
+func planItem(planID string, version int, status SubscriptionPlanStatus, monthly float64) map[string]dynamodb_types.AttributeValue {
+	item, _ := attributevalue.MarshalMap(SubscriptionPlan{
+		PK:         planPartitionKey(planID),
+		SK:         planVersionSortKey(version),
+		PlanID:     planID,
+		Version:    version,
+		Status:     status,
+		PlanName:   "Starter Plan",
+		MaxTeams:   5,
+		MaxMembers: 25,
+		Prices: map[string]PlanPrice{
+			"AUD": {Monthly: monthly, Yearly: monthly * 10},
+			"USD": {Monthly: monthly - 10, Yearly: (monthly - 10) * 10},
+		},
+		FeatureFlags: map[string]bool{PlanFeatureAnalyticsDashboard: version > 1},
+	})
+	return item
+}
+
+func TestSubscriptionPlanPriceFor(t *testing.T) {
+	t.Run("It should price a plan in any currency in its price table", func(t *testing.T) {
+		plan := SubscriptionPlan{Prices: map[string]PlanPrice{"AUD": {Monthly: 30, Yearly: 300}, "USD": {Monthly: 20, Yearly: 200}}}
+
+		price, ok := plan.PriceFor("USD", BillingPlanYearly)
+
+		assert.True(t, ok)
+		assert.Equal(t, 200.0, price)
+	})
+
+	t.Run("It should reject a currency the plan is not priced in", func(t *testing.T) {
+		plan := SubscriptionPlan{Prices: map[string]PlanPrice{"AUD": {Monthly: 30, Yearly: 300}}}
+
+		_, ok := plan.PriceFor("EUR", BillingPlanMonthly)
+
+		assert.False(t, ok)
+	})
+
+	t.Run("It should treat flat prices as the default currency", func(t *testing.T) {
+		plan := SubscriptionPlan{MonthlyPrice: 29.99, YearlyPrice: 299.99}
+
+		price, ok := plan.PriceFor(DefaultBillingCurrency, BillingPlanMonthly)
+		_, otherOk := plan.PriceFor("USD", BillingPlanMonthly)
+
+		assert.True(t, ok)
+		assert.Equal(t, 29.99, price)
+		assert.False(t, otherOk)
+	})
+}
+
+func TestSubscriptionPlanCatalogue(t *testing.T) {
+	newService := func(ddbClient *awsclients.MockDynamodbClient) *SubscriptionPlanService {
+		svc := CreateSubscriptionPlanService(context.Background(), ddbClient, log.New(&bytes.Buffer{}, "TEST:", 0))
+		svc.SubscriptionPlansTable = "SubscriptionPlansTable-test"
+		return svc
+	}
+
+	t.Run("It should serve the current version and keep retired versions for grandfathered orgs", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			ScanOutputs: []dynamodb.ScanOutput{{Items: []map[string]dynamodb_types.AttributeValue{
+				planItem("starter", 2, SubscriptionPlanStatusActive, 39.99),
+				planItem("starter", 1, SubscriptionPlanStatusRetired, 29.99),
+			}}},
+			ScanErrors: []error{nil},
+		}
+		svc := newService(&ddbClient)
+
+		current, err := svc.GetCurrentPlan("starter")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, current.Version)
+		assert.Equal(t, 39.99, current.MonthlyPrice)
+		assert.True(t, current.HasFeature(PlanFeatureAnalyticsDashboard))
+
+		// Organizations created before the catalogue have no version and are billed at version 1
+		legacy, err := svc.GetPlanVersion("starter", 0)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, legacy.Version)
+		assert.Equal(t, SubscriptionPlanStatusRetired, legacy.Status)
+		assert.False(t, legacy.HasFeature(PlanFeatureAnalyticsDashboard))
+
+		// Built-in plans without stored versions are still offered
+		plans, err := svc.ListPlans(false)
+		assert.NoError(t, err)
+		assert.Len(t, plans, 3)
+		assert.Equal(t, "starter", plans[0].PlanID)
+		assert.Equal(t, 2, plans[0].Version)
+
+		// Served from the cache after the first read
+		assert.Len(t, ddbClient.ScanInputs, 1)
+	})
+
+	t.Run("It should not offer a retired plan to new subscriptions", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			ScanOutputs: []dynamodb.ScanOutput{{Items: []map[string]dynamodb_types.AttributeValue{
+				planItem("starter", 1, SubscriptionPlanStatusRetired, 29.99),
+				planItem("professional", 1, SubscriptionPlanStatusActive, 79.99),
+			}}},
+			ScanErrors: []error{nil},
+		}
+		svc := newService(&ddbClient)
+
+		_, err := svc.GetCurrentPlan("starter")
+		assert.ErrorIs(t, err, ErrSubscriptionPlanNotFound)
+
+		plans, err := svc.ListPlans(false)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"professional", "enterprise"}, []string{plans[0].PlanID, plans[1].PlanID})
+
+		plans, err = svc.ListPlans(true)
+		assert.NoError(t, err)
+		assert.Len(t, plans, 3)
+	})
+
+	t.Run("It should fall back to the default plans when the catalogue is empty", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			ScanOutputs: []dynamodb.ScanOutput{{}},
+			ScanErrors:  []error{nil},
+		}
+		svc := newService(&ddbClient)
+
+		plans, err := svc.ListPlans(false)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"starter", "professional", "enterprise"}, []string{plans[0].PlanID, plans[1].PlanID, plans[2].PlanID})
+		assert.Equal(t, 29.99, plans[0].MonthlyPrice)
+	})
+
+	t.Run("It should keep resolving the built-in plans once a custom plan is stored", func(t *testing.T) {
+		custom, _ := attributevalue.MarshalMap(SubscriptionPlan{
+			PK:         planPartitionKey("team"),
+			SK:         planVersionSortKey(1),
+			PlanID:     "team",
+			Version:    1,
+			Status:     SubscriptionPlanStatusActive,
+			PlanName:   "Team Plan",
+			MaxTeams:   10,
+			MaxMembers: 60,
+			Prices:     map[string]PlanPrice{"AUD": {Monthly: 49.99, Yearly: 499.99}},
+		})
+		ddbClient := awsclients.MockDynamodbClient{
+			PutItemOutputs: []dynamodb.PutItemOutput{{}},
+			PutItemErrors:  []error{nil},
+			ScanOutputs:    []dynamodb.ScanOutput{{Items: []map[string]dynamodb_types.AttributeValue{custom}}},
+			ScanErrors:     []error{nil},
+		}
+		svc := newService(&ddbClient)
+
+		_, err := svc.CreatePlan(SubscriptionPlanInput{
+			PlanID:     "team",
+			PlanName:   "Team Plan",
+			MaxTeams:   10,
+			MaxMembers: 60,
+			Prices:     map[string]PlanPrice{"AUD": {Monthly: 49.99, Yearly: 499.99}},
+		}, "admin@example.com")
+		assert.NoError(t, err)
+
+		starter, err := svc.GetCurrentPlan("starter")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, starter.Version)
+		assert.Equal(t, 25, starter.MaxMembers)
+
+		legacy, err := svc.GetPlanVersion("starter", 0)
+		assert.NoError(t, err)
+		assert.Equal(t, 29.99, legacy.MonthlyPrice)
+
+		team, err := svc.GetCurrentPlan("team")
+		assert.NoError(t, err)
+		assert.Equal(t, "Team Plan", team.PlanName)
+	})
+
+	t.Run("It should not create a plan over a built-in plan", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{}
+		svc := newService(&ddbClient)
+
+		_, err := svc.CreatePlan(SubscriptionPlanInput{
+			PlanID:     "starter",
+			PlanName:   "Starter Plan",
+			MaxTeams:   5,
+			MaxMembers: 25,
+			Prices:     map[string]PlanPrice{"AUD": {Monthly: 19.99, Yearly: 199.99}},
+		}, "admin@example.com")
+
+		assert.ErrorIs(t, err, ErrSubscriptionPlanExists)
+		assert.Len(t, ddbClient.PutItemInputs, 0)
+	})
+
+	t.Run("It should store version 1 of a built-in plan with its first new version", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs:             []dynamodb.QueryOutput{{}},
+			QueryErrors:              []error{nil},
+			TransactWriteItemsOutput: []dynamodb.TransactWriteItemsOutput{{}},
+			TransactWriteItemsErrors: []error{nil},
+		}
+		svc := newService(&ddbClient)
+
+		plan, err := svc.PublishPlanVersion("starter", SubscriptionPlanInput{
+			PlanName:   "Starter Plan",
+			MaxTeams:   5,
+			MaxMembers: 30,
+			Prices:     map[string]PlanPrice{"AUD": {Monthly: 34.99, Yearly: 349.99}},
+		}, "admin@example.com")
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, plan.Version)
+
+		items := ddbClient.TransactWriteItemsInputs[0].TransactItems
+		assert.Len(t, items, 2)
+		assert.Equal(t, &dynamodb_types.AttributeValueMemberS{Value: "VERSION#00002"}, items[0].Put.Item["SK"])
+
+		var seeded SubscriptionPlan
+		assert.NoError(t, attributevalue.UnmarshalMap(items[1].Put.Item, &seeded))
+		assert.Equal(t, "VERSION#00001", seeded.SK)
+		assert.Equal(t, SubscriptionPlanStatusRetired, seeded.Status)
+		assert.Equal(t, map[string]PlanPrice{"AUD": {Monthly: 29.99, Yearly: 299.99}}, seeded.Prices)
+		assert.Equal(t, "attribute_not_exists(PK)", *items[1].Put.ConditionExpression)
+	})
+
+	t.Run("It should publish a new version and retire the previous one in one transaction", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			QueryOutputs: []dynamodb.QueryOutput{{Items: []map[string]dynamodb_types.AttributeValue{
+				planItem("starter", 1, SubscriptionPlanStatusActive, 29.99),
+			}}},
+			QueryErrors:              []error{nil},
+			TransactWriteItemsOutput: []dynamodb.TransactWriteItemsOutput{{}},
+			TransactWriteItemsErrors: []error{nil},
+		}
+		svc := newService(&ddbClient)
+
+		plan, err := svc.PublishPlanVersion("starter", SubscriptionPlanInput{
+			PlanName:   "Starter Plan",
+			MaxTeams:   5,
+			MaxMembers: 30,
+			Prices:     map[string]PlanPrice{"AUD": {Monthly: 34.99, Yearly: 349.99}},
+		}, "admin@example.com")
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, plan.Version)
+		assert.Equal(t, 34.99, plan.MonthlyPrice)
+
+		items := ddbClient.TransactWriteItemsInputs[0].TransactItems
+		assert.Len(t, items, 2)
+		assert.Equal(t, &dynamodb_types.AttributeValueMemberS{Value: "VERSION#00002"}, items[0].Put.Item["SK"])
+		assert.Equal(t, &dynamodb_types.AttributeValueMemberS{Value: "VERSION#00001"}, items[1].Update.Key["SK"])
+		assert.Equal(t, "#status = :active", *items[1].Update.ConditionExpression)
+	})
+
+	t.Run("It should require a price in the default currency", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{}
+		svc := newService(&ddbClient)
+
+		_, err := svc.CreatePlan(SubscriptionPlanInput{
+			PlanID:     "team",
+			PlanName:   "Team Plan",
+			MaxTeams:   10,
+			MaxMembers: 60,
+			Prices:     map[string]PlanPrice{"USD": {Monthly: 34.99, Yearly: 349.99}},
+		}, "admin@example.com")
+
+		assert.Error(t, err)
+		assert.Len(t, ddbClient.PutItemInputs, 0)
+	})
+}
+
+func TestDefaultPlan(t *testing.T) {
+	newService := func(ddbClient *awsclients.MockDynamodbClient, defaultPlanID string) *OrgServiceV2 {
+		svc := CreateOrgServiceV2(context.Background(), ddbClient, log.New(&bytes.Buffer{}, "TEST:", 0), nil, nil)
+		svc.PlanCatalog = CreateSubscriptionPlanService(context.Background(), ddbClient, log.New(&bytes.Buffer{}, "TEST:", 0))
+		svc.PlanCatalog.SubscriptionPlansTable = "SubscriptionPlansTable-test"
+		svc.DefaultPlanID = defaultPlanID
+		return svc
+	}
+	retiredStarter := dynamodb.ScanOutput{Items: []map[string]dynamodb_types.AttributeValue{
+		planItem("starter", 1, SubscriptionPlanStatusRetired, 29.99),
+		planItem("team", 1, SubscriptionPlanStatusActive, 49.99),
+	}}
+
+	t.Run("It should start new organizations on the configured plan", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{ScanOutputs: []dynamodb.ScanOutput{retiredStarter}, ScanErrors: []error{nil}}
+		svc := newService(&ddbClient, "team")
+
+		plan, err := svc.defaultPlan()
+
+		assert.NoError(t, err)
+		assert.Equal(t, "team", plan.PlanID)
+	})
+
+	t.Run("It should explain that the default plan is unavailable", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{ScanOutputs: []dynamodb.ScanOutput{retiredStarter}, ScanErrors: []error{nil}}
+		svc := newService(&ddbClient, "")
+
+		_, err := svc.defaultPlan()
+
+		assert.ErrorIs(t, err, ErrDefaultPlanUnavailable)
+		assert.ErrorContains(t, err, "plan starter has no active version")
+	})
+}
+
+func TestProcessRenewalGrandfatheredPlan(t *testing.T) {
+	t.Run("It should renew an organization at its retired plan version's price", func(t *testing.T) {
+		ddbClient := awsclients.MockDynamodbClient{
+			ScanOutputs: []dynamodb.ScanOutput{{Items: []map[string]dynamodb_types.AttributeValue{
+				planItem("starter", 1, SubscriptionPlanStatusRetired, 29.99),
+				planItem("starter", 2, SubscriptionPlanStatusActive, 39.99),
+			}}},
+			ScanErrors:               []error{nil},
+			TransactWriteItemsOutput: []dynamodb.TransactWriteItemsOutput{{}, {}},
+			TransactWriteItemsErrors: []error{nil, nil},
+		}
+		logger := log.New(&bytes.Buffer{}, "TEST:", 0)
+		catalog := CreateSubscriptionPlanService(context.Background(), &ddbClient, logger)
+		catalog.SubscriptionPlansTable = "SubscriptionPlansTable-test"
+
+		svc := &OrgServiceV2{
+			ctx:               context.Background(),
+			dynamodbClient:    &ddbClient,
+			logger:            logger,
+			OrganizationTable: "OrgTable-test",
+			PaymentProvider:   &LocalPaymentProvider{},
+			PlanCatalog:       catalog,
+		}
+
+		org := Organization{
+			OrganizationId:     "org-1",
+			Country:            "US",
+			BillingMode:        BillingModePaid,
+			BillingPlan:        BillingPlanMonthly,
+			BillingCurrency:    "USD",
+			CurrentPlanID:      "starter",
+			CurrentPlanVersion: 1,
+			OrgBillingStatus:   OrgBillingStatusActive,
+			NextBillingDate:    "2025-03-01T00:00:00Z",
+		}
+
+		result, err := svc.ProcessRenewal(org, time.Date(2025, 3, 2, 1, 0, 0, 0, time.UTC))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "USD", result.Invoice.Currency)
+		assert.Equal(t, 19.99, result.Invoice.LineItems[0].Amount)
+	})
+}
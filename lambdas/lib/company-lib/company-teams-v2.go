@@ -2,6 +2,7 @@ package Companylib
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
@@ -16,6 +17,9 @@ import (
 	awsclients "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/clients"
 )
 
+// ErrTeamNotFound is returned when a team has no metadata record
+var ErrTeamNotFound = errors.New("team not found")
+
 // TeamStatus represents the status of a team
 type TeamStatus string
 
@@ -113,6 +117,10 @@ type TeamsServiceV2 struct {
 	emailSvc       *EmailService
 
 	TeamsTable string
+
+	// OrgService enforces the organization's plan member limit when members are added. Seats are
+	// not checked when it is nil.
+	OrgService *OrgServiceV2
 }
 
 // CreateTeamsServiceV2 creates a new teams service
@@ -417,7 +425,7 @@ func (svc *TeamsServiceV2) GetTeamMetadata(teamId string) (*TeamMetadata, error)
 	}
 
 	if result.Item == nil {
-		return nil, fmt.Errorf("team not found: %s", teamId)
+		return nil, fmt.Errorf("%w: %s", ErrTeamNotFound, teamId)
 	}
 
 	var metadata TeamMetadata
@@ -623,13 +631,24 @@ func (svc *TeamsServiceV2) AddTeamMembers(input AddTeamMembersInput, requestingU
 		},
 	})
 
+	// New members take an organization seat unless they already hold one
+	var reservation *SeatReservation
+	reservationOffset := len(transactItems)
+	if svc.OrgService != nil {
+		reservation, err = svc.OrgService.ReserveSeats(metadata.OrgId, input.UserNames)
+		if err != nil {
+			return err
+		}
+		transactItems = append(transactItems, reservation.Items...)
+	}
+
 	_, err = svc.dynamodbClient.TransactWriteItems(svc.ctx, &dynamodb.TransactWriteItemsInput{
 		TransactItems: transactItems,
 	})
 
 	if err != nil {
 		svc.logger.Printf("Failed to add team members: %v", err)
-		return fmt.Errorf("failed to add team members: %w", err)
+		return reservation.TransactionError(fmt.Errorf("failed to add team members: %w", err), reservationOffset)
 	}
 
 	svc.logger.Printf("Successfully added %d members to team %s", len(input.UserNames), input.TeamId)
@@ -660,6 +679,12 @@ func (svc *TeamsServiceV2) AddTeamMembersCheckCognito(input AddTeamMembersInput,
 	if metadata.Status != TeamStatusActive {
 		return fmt.Errorf("cannot add members to inactive team")
 	}
+	// Check the member limit before creating Cognito users for people who cannot join
+	if svc.OrgService != nil {
+		if _, err := svc.OrgService.ReserveSeats(metadata.OrgId, input.UserNames); err != nil {
+			return err
+		}
+	}
 	// Check and create users in Cognito if they don't exist
 	for _, userName := range input.UserNames {
 		emp, err := svc.employeeSvc.GetEmployeeDataByUserName(userName)
@@ -678,6 +703,89 @@ func (svc *TeamsServiceV2) AddTeamMembersCheckCognito(input AddTeamMembersInput,
 	return svc.AddTeamMembers(input, requestingUser)
 }
 
+// InvitationMembershipItems returns the transaction items that add an invitee to the team they
+// were invited to, for OrgServiceV2.AcceptInvitation. Someone who is already an active member
+// keeps their membership and takes the invited role.
+func (svc *TeamsServiceV2) InvitationMembershipItems(teamId, organizationId, userName, displayName string, role TeamMemberRole) ([]types.TransactWriteItem, error) {
+	metadata, err := svc.GetTeamMetadata(teamId)
+	if err != nil {
+		return nil, err
+	}
+	if orgPartitionKey(metadata.OrgId) != orgPartitionKey(organizationId) {
+		return nil, fmt.Errorf("team %s does not belong to organization %s", teamId, organizationId)
+	}
+	if metadata.Status != TeamStatusActive {
+		return nil, fmt.Errorf("cannot add members to inactive team")
+	}
+
+	existing, err := svc.GetTeamMemberDetails(teamId, userName)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	if existing != nil && existing.IsActive {
+		return []types.TransactWriteItem{
+			{
+				Update: &types.Update{
+					TableName: aws.String(svc.TeamsTable),
+					Key: map[string]types.AttributeValue{
+						"PK": &types.AttributeValueMemberS{Value: teamId},
+						"SK": &types.AttributeValueMemberS{Value: fmt.Sprintf("USER#%s", userName)},
+					},
+					UpdateExpression: aws.String("SET #role = :role"),
+					ExpressionAttributeNames: map[string]string{
+						"#role": "Role",
+					},
+					ExpressionAttributeValues: map[string]types.AttributeValue{
+						":role": &types.AttributeValueMemberS{Value: string(role)},
+					},
+				},
+			},
+		}, nil
+	}
+
+	memberItem, err := attributevalue.MarshalMap(TeamMember{
+		PK:          teamId,
+		SK:          fmt.Sprintf("USER#%s", userName),
+		GSI1PK:      fmt.Sprintf("USER#%s", userName),
+		GSI1SK:      teamId,
+		TeamId:      teamId,
+		UserName:    userName,
+		DisplayName: displayName,
+		Role:        role,
+		JoinedAt:    now,
+		IsActive:    true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal team member: %w", err)
+	}
+
+	return []types.TransactWriteItem{
+		{
+			Put: &types.Put{
+				TableName: aws.String(svc.TeamsTable),
+				Item:      memberItem,
+			},
+		},
+		{
+			Update: &types.Update{
+				TableName: aws.String(svc.TeamsTable),
+				Key: map[string]types.AttributeValue{
+					"PK": &types.AttributeValueMemberS{Value: teamId},
+					"SK": &types.AttributeValueMemberS{Value: "METADATA"},
+				},
+				UpdateExpression:    aws.String("SET MemberCount = MemberCount + :increment, UpdatedAt = :updatedAt"),
+				ConditionExpression: aws.String("attribute_exists(PK)"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":increment": &types.AttributeValueMemberN{Value: "1"},
+					":updatedAt": &types.AttributeValueMemberS{Value: now},
+				},
+			},
+		},
+	}, nil
+}
+
 // UpdateMemberRole updates a team member's role
 func (svc *TeamsServiceV2) UpdateMemberRole(input UpdateMemberRoleInput, requestingUser string) error {
 	// Verify requesting user is admin
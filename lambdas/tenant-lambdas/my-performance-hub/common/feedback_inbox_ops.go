@@ -0,0 +1,712 @@
+package common
+
+// ==================== Routes ====================
+//
+// GET  /v2/users/me/feedback-inbox?teamId=&status=          — feedback requests addressed to me
+// POST /v2/users/me/feedback-inbox/{requestId}/response     — answer a feedback request
+// POST /v2/users/me/feedback-inbox/{requestId}/decline      — decline a feedback request
+//
+// Pending requests expire at the end of their due date. The send-feedback-reminders schedule
+// reminds the recipient once before then and marks the request expired afterwards.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+
+	companylib "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/company-lib"
+)
+
+const (
+	// minAnonymousResponses is how many anonymous responses must exist before they are included
+	// in a summary, so a single anonymous answer cannot be traced back to its author.
+	minAnonymousResponses = 3
+
+	maxFeedbackCommentLength = 2000
+)
+
+var feedbackCategories = []FeedbackCategory{
+	FeedbackCategoryTechnical,
+	FeedbackCategoryLeadership,
+	FeedbackCategoryCommunication,
+	FeedbackCategoryCollaboration,
+}
+
+// ==================== Inbox ====================
+
+func (svc *Service) handleFeedbackInbox(request events.APIGatewayProxyRequest, parts []string, userName, displayName, teamID string) (events.APIGatewayProxyResponse, error) {
+	switch {
+	case len(parts) == 4 && request.HTTPMethod == "GET":
+		return svc.listFeedbackInbox(userName, teamID, request.QueryStringParameters)
+	case len(parts) == 6 && parts[5] == "response" && request.HTTPMethod == "POST":
+		return svc.respondToFeedbackRequest(userName, displayName, teamID, parts[4], request.Body)
+	case len(parts) == 6 && parts[5] == "decline" && request.HTTPMethod == "POST":
+		return svc.declineFeedbackRequest(userName, displayName, teamID, parts[4], request.Body)
+	}
+	return svc.errResp(http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed")
+}
+
+func (svc *Service) listFeedbackInbox(userName, teamID string, queryParams map[string]string) (events.APIGatewayProxyResponse, error) {
+	statusFilter := queryString(queryParams, "status")
+
+	items, err := svc.queryAll(&dynamodb.QueryInput{
+		TableName:              aws.String(svc.perfHubTable),
+		IndexName:              aws.String(FeedbackToIndex),
+		KeyConditionExpression: aws.String("#to = :to"),
+		ExpressionAttributeNames: map[string]string{
+			"#to": "to",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":to": &types.AttributeValueMemberS{Value: userName},
+		},
+		ScanIndexForward: aws.Bool(false), // newest first
+	})
+	if err != nil {
+		svc.logger.Printf("listFeedbackInbox query error: %v", err)
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to list feedback inbox")
+	}
+
+	var requests []FeedbackRequestRecord
+	attributevalue.UnmarshalListOfMaps(items, &requests)
+
+	now := time.Now().UTC()
+	out := make([]map[string]interface{}, 0, len(requests))
+	for _, r := range requests {
+		if feedbackRequestTeam(r) != teamID {
+			continue
+		}
+		r.Status = effectiveFeedbackStatus(r, now)
+		if statusFilter != "" && r.Status != statusFilter {
+			continue
+		}
+		out = append(out, buildFeedbackInboxResponse(r))
+	}
+
+	return svc.okResp(map[string]interface{}{"feedbackRequests": out})
+}
+
+func (svc *Service) respondToFeedbackRequest(userName, displayName, teamID, requestID, body string) (events.APIGatewayProxyResponse, error) {
+	req, err := parseBody[RespondFeedbackRequestBody](body)
+	if err != nil {
+		return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+	}
+	if err := validateFeedbackResponse(req); err != nil {
+		return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+	}
+
+	rec, errResp := svc.openInboxRequest(userName, teamID, requestID)
+	if errResp != nil {
+		return *errResp, nil
+	}
+
+	now := time.Now().UTC()
+	responseID := uuid.New().String()
+	response := FeedbackResponseRecord{
+		PK:           rec.PK,
+		SK:           SKFeedbackRespPrefix + responseID,
+		ResponseID:   responseID,
+		TeamID:       teamID,
+		UserName:     rec.UserName,
+		Anonymous:    req.Anonymous,
+		Ratings:      req.Ratings,
+		Strengths:    strings.TrimSpace(req.Strengths),
+		Improvements: strings.TrimSpace(req.Improvements),
+		Date:         now.Format("2006-01-02"),
+	}
+	if !req.Anonymous {
+		response.RequestID = rec.RequestID
+		response.Responder = userName
+		response.ResponderName = displayName
+		response.CreatedAt = now.Format(time.RFC3339)
+	}
+
+	item, err := attributevalue.MarshalMap(response)
+	if err != nil {
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to marshal feedback response")
+	}
+
+	// The requester sees when each of their requests was answered, so an anonymous answer keeps no
+	// time that could be matched against the response
+	update := "SET #status = :completed, respondedAt = :now, anonymous = :anonymous REMOVE reminderKey, remindAt"
+	if req.Anonymous {
+		update = "SET #status = :completed, anonymous = :anonymous REMOVE reminderKey, remindAt"
+	}
+	_, err = svc.ddb.TransactWriteItems(svc.ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{Update: &types.Update{
+				TableName:           aws.String(svc.perfHubTable),
+				Key:                 feedbackRequestKey(*rec),
+				UpdateExpression:    aws.String(update),
+				ConditionExpression: aws.String("#status = :pending AND (attribute_not_exists(expiresAt) OR expiresAt > :now)"),
+				ExpressionAttributeNames: map[string]string{
+					"#status": "status",
+				},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":completed": &types.AttributeValueMemberS{Value: string(FeedbackStatusCompleted)},
+					":pending":   &types.AttributeValueMemberS{Value: string(FeedbackStatusPending)},
+					":now":       &types.AttributeValueMemberS{Value: now.Format(time.RFC3339)},
+					":anonymous": &types.AttributeValueMemberBOOL{Value: req.Anonymous},
+				},
+			}},
+			{Put: &types.Put{
+				TableName:           aws.String(svc.perfHubTable),
+				Item:                item,
+				ConditionExpression: aws.String("attribute_not_exists(PK)"),
+			}},
+		},
+	})
+	if err != nil {
+		var tce *types.TransactionCanceledException
+		if errors.As(err, &tce) {
+			return svc.errResp(http.StatusConflict, "CONFLICT", "This feedback request is no longer pending")
+		}
+		svc.logger.Printf("respondToFeedbackRequest TransactWriteItems error: %v", err)
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to submit feedback")
+	}
+
+	notification := companylib.Notification{
+		UserName: rec.UserName,
+		Type:     companylib.NotificationTypeFeedbackReceived,
+		TeamId:   teamID,
+		Message:  "You received anonymous feedback",
+	}
+	if !req.Anonymous {
+		notification.ActorUserName = userName
+		notification.ActorName = displayName
+		notification.Message = fmt.Sprintf("%s responded to your feedback request", feedbackActorName(displayName, userName))
+	}
+	svc.notify([]companylib.Notification{notification})
+
+	rec.Status = string(FeedbackStatusCompleted)
+	rec.Anonymous = req.Anonymous
+	if !req.Anonymous {
+		rec.RespondedAt = now.Format(time.RFC3339)
+	}
+	return svc.createdResp(map[string]interface{}{"feedbackRequest": buildFeedbackInboxResponse(*rec)})
+}
+
+func (svc *Service) declineFeedbackRequest(userName, displayName, teamID, requestID, body string) (events.APIGatewayProxyResponse, error) {
+	req := &DeclineFeedbackRequestBody{}
+	if strings.TrimSpace(body) != "" {
+		parsed, err := parseBody[DeclineFeedbackRequestBody](body)
+		if err != nil {
+			return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		}
+		req = parsed
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if len(reason) > maxFeedbackCommentLength {
+		return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("reason must be at most %d characters", maxFeedbackCommentLength))
+	}
+
+	rec, errResp := svc.openInboxRequest(userName, teamID, requestID)
+	if errResp != nil {
+		return *errResp, nil
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	_, err := svc.ddb.UpdateItem(svc.ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(svc.perfHubTable),
+		Key:                 feedbackRequestKey(*rec),
+		UpdateExpression:    aws.String("SET #status = :declined, declinedAt = :now, declineReason = :reason REMOVE reminderKey, remindAt"),
+		ConditionExpression: aws.String("#status = :pending AND (attribute_not_exists(expiresAt) OR expiresAt > :now)"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":declined": &types.AttributeValueMemberS{Value: string(FeedbackStatusDeclined)},
+			":pending":  &types.AttributeValueMemberS{Value: string(FeedbackStatusPending)},
+			":now":      &types.AttributeValueMemberS{Value: now},
+			":reason":   &types.AttributeValueMemberS{Value: reason},
+		},
+	})
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			return svc.errResp(http.StatusConflict, "CONFLICT", "This feedback request is no longer pending")
+		}
+		svc.logger.Printf("declineFeedbackRequest UpdateItem error: %v", err)
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to decline feedback request")
+	}
+
+	svc.notify([]companylib.Notification{{
+		UserName:      rec.UserName,
+		Type:          companylib.NotificationTypeFeedbackDeclined,
+		ActorUserName: userName,
+		ActorName:     displayName,
+		TeamId:        teamID,
+		Message:       fmt.Sprintf("%s declined your feedback request", feedbackActorName(displayName, userName)),
+	}})
+
+	rec.Status = string(FeedbackStatusDeclined)
+	rec.DeclinedAt = now
+	rec.DeclineReason = reason
+	return svc.okResp(map[string]interface{}{"feedbackRequest": buildFeedbackInboxResponse(*rec)})
+}
+
+// openInboxRequest loads a feedback request addressed to userName in teamID and checks that it
+// can still be answered or declined.
+func (svc *Service) openInboxRequest(userName, teamID, requestID string) (*FeedbackRequestRecord, *events.APIGatewayProxyResponse) {
+	rec, err := svc.findInboxRequest(userName, requestID)
+	if err != nil {
+		svc.logger.Printf("findInboxRequest error: %v", err)
+		resp, _ := svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to load feedback request")
+		return nil, &resp
+	}
+	if rec == nil || feedbackRequestTeam(*rec) != teamID {
+		resp, _ := svc.errResp(http.StatusNotFound, "NOT_FOUND", "Feedback request not found")
+		return nil, &resp
+	}
+	if status := effectiveFeedbackStatus(*rec, time.Now().UTC()); status != string(FeedbackStatusPending) {
+		resp, _ := svc.errResp(http.StatusConflict, "CONFLICT", "This feedback request is already "+status)
+		return nil, &resp
+	}
+	return rec, nil
+}
+
+// findInboxRequest looks a request up through FeedbackToIndex, so only the recipient can find it.
+func (svc *Service) findInboxRequest(userName, requestID string) (*FeedbackRequestRecord, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(svc.perfHubTable),
+		IndexName:              aws.String(FeedbackToIndex),
+		KeyConditionExpression: aws.String("#to = :to"),
+		FilterExpression:       aws.String("requestId = :id"),
+		ExpressionAttributeNames: map[string]string{
+			"#to": "to",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":to": &types.AttributeValueMemberS{Value: userName},
+			":id": &types.AttributeValueMemberS{Value: requestID},
+		},
+	}
+	for {
+		result, err := svc.ddb.Query(svc.ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		if len(result.Items) > 0 {
+			var rec FeedbackRequestRecord
+			if err := attributevalue.UnmarshalMap(result.Items[0], &rec); err != nil {
+				return nil, err
+			}
+			return &rec, nil
+		}
+		if len(result.LastEvaluatedKey) == 0 {
+			return nil, nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+func buildFeedbackInboxResponse(r FeedbackRequestRecord) map[string]interface{} {
+	resp := map[string]interface{}{
+		"id":        r.RequestID,
+		"teamId":    feedbackRequestTeam(r),
+		"from":      r.UserName,
+		"fromName":  r.FromName,
+		"message":   r.Message,
+		"status":    r.Status,
+		"date":      r.Date,
+		"dueDate":   r.DueDate,
+		"expiresAt": r.ExpiresAt,
+		"createdAt": r.CreatedAt,
+	}
+	if r.Status == string(FeedbackStatusCompleted) {
+		resp["anonymous"] = r.Anonymous
+	}
+	if r.RespondedAt != "" && !r.Anonymous {
+		resp["respondedAt"] = r.RespondedAt
+	}
+	if r.DeclinedAt != "" {
+		resp["declinedAt"] = r.DeclinedAt
+		resp["declineReason"] = r.DeclineReason
+	}
+	return resp
+}
+
+func buildFeedbackResponseResponse(r FeedbackResponseRecord) map[string]interface{} {
+	return map[string]interface{}{
+		"id":            r.ResponseID,
+		"responder":     r.Responder,
+		"responderName": r.ResponderName,
+		"ratings":       r.Ratings,
+		"strengths":     r.Strengths,
+		"improvements":  r.Improvements,
+		"date":          r.Date,
+	}
+}
+
+// ==================== Feedback Reminders (scheduled) ====================
+
+// FeedbackReminderSummary is returned by the scheduled reminder run and written to the logs
+type FeedbackReminderSummary struct {
+	RunAt    string `json:"runAt"`
+	Due      int    `json:"due"`
+	Reminded int    `json:"reminded"`
+	Expired  int    `json:"expired"`
+	Failed   int    `json:"failed"`
+}
+
+// HandleFeedbackReminderSchedule reminds recipients of pending feedback requests that are close
+// to their due date, and expires the requests whose due date has passed. Each request is claimed
+// with a conditional update on its remindAt before anyone is notified.
+func (svc *Service) HandleFeedbackReminderSchedule(ctx context.Context, event events.CloudWatchEvent) (FeedbackReminderSummary, error) {
+	now := time.Now().UTC()
+	summary := FeedbackReminderSummary{RunAt: now.Format(time.RFC3339)}
+
+	items, err := svc.queryAll(&dynamodb.QueryInput{
+		TableName:              aws.String(svc.perfHubTable),
+		IndexName:              aws.String(FeedbackReminderIndex),
+		KeyConditionExpression: aws.String("reminderKey = :key AND remindAt <= :now"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":key": &types.AttributeValueMemberS{Value: companylib.FeedbackReminderKey},
+			":now": &types.AttributeValueMemberS{Value: summary.RunAt},
+		},
+	})
+	if err != nil {
+		return summary, fmt.Errorf("failed to query due feedback reminders: %w", err)
+	}
+
+	var requests []FeedbackRequestRecord
+	if err := attributevalue.UnmarshalListOfMaps(items, &requests); err != nil {
+		return summary, fmt.Errorf("failed to unmarshal due feedback reminders: %w", err)
+	}
+	summary.Due = len(requests)
+
+	for _, rec := range requests {
+		expired := effectiveFeedbackStatus(rec, now) == string(FeedbackStatusExpired)
+		var claimed bool
+		if expired {
+			claimed, err = svc.expireFeedbackRequest(rec)
+		} else {
+			claimed, err = svc.remindFeedbackRequest(rec)
+		}
+		if err != nil {
+			svc.logger.Printf("Failed to process feedback request %s: %v", rec.RequestID, err)
+			summary.Failed++
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		if expired {
+			summary.Expired++
+		} else {
+			summary.Reminded++
+		}
+	}
+
+	svc.logger.Printf("Feedback reminder run complete: %+v", summary)
+	return summary, nil
+}
+
+// remindFeedbackRequest reminds the recipient and moves remindAt to the expiry, so the next
+// time the request comes due it is expired rather than reminded again.
+func (svc *Service) remindFeedbackRequest(rec FeedbackRequestRecord) (bool, error) {
+	_, err := svc.ddb.UpdateItem(svc.ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(svc.perfHubTable),
+		Key:                 feedbackRequestKey(rec),
+		UpdateExpression:    aws.String("SET remindAt = :expires, remindersSent = if_not_exists(remindersSent, :zero) + :one"),
+		ConditionExpression: aws.String("remindAt = :due AND #status = :pending"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":expires": &types.AttributeValueMemberS{Value: rec.ExpiresAt},
+			":due":     &types.AttributeValueMemberS{Value: rec.RemindAt},
+			":pending": &types.AttributeValueMemberS{Value: string(FeedbackStatusPending)},
+			":zero":    &types.AttributeValueMemberN{Value: "0"},
+			":one":     &types.AttributeValueMemberN{Value: "1"},
+		},
+	})
+	if claimed, err := claimResult(err); !claimed {
+		return false, err
+	}
+
+	svc.notify([]companylib.Notification{{
+		UserName:      rec.To,
+		Type:          companylib.NotificationTypeFeedbackReminder,
+		ActorUserName: rec.UserName,
+		ActorName:     rec.FromName,
+		TeamId:        feedbackRequestTeam(rec),
+		Message:       fmt.Sprintf("Reminder: %s is waiting for your feedback (due %s)", feedbackActorName(rec.FromName, rec.UserName), rec.DueDate),
+	}})
+	return true, nil
+}
+
+// expireFeedbackRequest marks a pending request expired and tells the requester.
+func (svc *Service) expireFeedbackRequest(rec FeedbackRequestRecord) (bool, error) {
+	_, err := svc.ddb.UpdateItem(svc.ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(svc.perfHubTable),
+		Key:                 feedbackRequestKey(rec),
+		UpdateExpression:    aws.String("SET #status = :expired REMOVE reminderKey, remindAt"),
+		ConditionExpression: aws.String("remindAt = :due AND #status = :pending"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":expired": &types.AttributeValueMemberS{Value: string(FeedbackStatusExpired)},
+			":due":     &types.AttributeValueMemberS{Value: rec.RemindAt},
+			":pending": &types.AttributeValueMemberS{Value: string(FeedbackStatusPending)},
+		},
+	})
+	if claimed, err := claimResult(err); !claimed {
+		return false, err
+	}
+
+	svc.notify([]companylib.Notification{{
+		UserName: rec.UserName,
+		Type:     companylib.NotificationTypeFeedbackExpired,
+		TeamId:   feedbackRequestTeam(rec),
+		Message:  fmt.Sprintf("Your feedback request to %s expired without a response", rec.To),
+	}})
+	return true, nil
+}
+
+// claimResult treats a failed condition as "claimed by another run, or answered since".
+func claimResult(err error) (bool, error) {
+	if err == nil {
+		return true, nil
+	}
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to claim feedback request: %w", err)
+}
+
+// ==================== Aggregation ====================
+
+// FeedbackSummary aggregates the responses to a member's feedback requests.
+type FeedbackSummary struct {
+	Requested          int                       `json:"requested"`
+	Pending            int                       `json:"pending"`
+	Completed          int                       `json:"completed"`
+	Declined           int                       `json:"declined"`
+	Expired            int                       `json:"expired"`
+	ResponseRate       float64                   `json:"responseRate"` // % of requests answered
+	Responses          int                       `json:"responses"`
+	AnonymousResponses int                       `json:"anonymousResponses"`
+	AnonymousWithheld  bool                      `json:"anonymousWithheld"` // true while below minAnonymousResponses
+	AverageRating      float64                   `json:"averageRating"`
+	Categories         []FeedbackCategorySummary `json:"categories"`
+	Strengths          []FeedbackComment         `json:"strengths"`
+	Improvements       []FeedbackComment         `json:"improvements"`
+}
+
+type FeedbackCategorySummary struct {
+	Category      FeedbackCategory  `json:"category"`
+	AverageRating float64           `json:"averageRating"`
+	Ratings       int               `json:"ratings"`
+	Comments      []FeedbackComment `json:"comments"`
+}
+
+type FeedbackComment struct {
+	Text     string `json:"text"`
+	From     string `json:"from,omitempty"`
+	FromName string `json:"fromName,omitempty"`
+	Date     string `json:"date"`
+}
+
+// aggregateFeedback counts requests by their effective status and averages the ratings of the
+// responses. Anonymous responses are only included once there are minAnonymousResponses of them.
+func aggregateFeedback(requests []FeedbackRequestRecord, responses []FeedbackResponseRecord, now time.Time) FeedbackSummary {
+	summary := FeedbackSummary{
+		Requested:    len(requests),
+		Strengths:    []FeedbackComment{},
+		Improvements: []FeedbackComment{},
+	}
+	for _, r := range requests {
+		switch FeedbackRequestStatus(effectiveFeedbackStatus(r, now)) {
+		case FeedbackStatusPending:
+			summary.Pending++
+		case FeedbackStatusCompleted:
+			summary.Completed++
+		case FeedbackStatusDeclined:
+			summary.Declined++
+		case FeedbackStatusExpired:
+			summary.Expired++
+		}
+	}
+	if summary.Requested > 0 {
+		summary.ResponseRate = roundTenth(float64(summary.Completed) * 100 / float64(summary.Requested))
+	}
+
+	for _, r := range responses {
+		if r.Anonymous {
+			summary.AnonymousResponses++
+		}
+	}
+	includeAnonymous := summary.AnonymousResponses >= minAnonymousResponses
+	summary.AnonymousWithheld = summary.AnonymousResponses > 0 && !includeAnonymous
+
+	type tally struct {
+		sum, count int
+		comments   []FeedbackComment
+	}
+	tallies := map[FeedbackCategory]*tally{}
+	for _, c := range feedbackCategories {
+		tallies[c] = &tally{comments: []FeedbackComment{}}
+	}
+
+	var ratingSum, ratingCount int
+	for _, r := range responses {
+		if r.Anonymous && !includeAnonymous {
+			continue
+		}
+		summary.Responses++
+		comment := func(text string) FeedbackComment {
+			c := FeedbackComment{Text: text, Date: r.Date}
+			if !r.Anonymous {
+				c.From = r.Responder
+				c.FromName = r.ResponderName
+			}
+			return c
+		}
+		for _, rating := range r.Ratings {
+			t, ok := tallies[rating.Category]
+			if !ok {
+				continue
+			}
+			t.sum += rating.Rating
+			t.count++
+			ratingSum += rating.Rating
+			ratingCount++
+			if rating.Comment != "" {
+				t.comments = append(t.comments, comment(rating.Comment))
+			}
+		}
+		if r.Strengths != "" {
+			summary.Strengths = append(summary.Strengths, comment(r.Strengths))
+		}
+		if r.Improvements != "" {
+			summary.Improvements = append(summary.Improvements, comment(r.Improvements))
+		}
+	}
+	if ratingCount > 0 {
+		summary.AverageRating = roundTenth(float64(ratingSum) / float64(ratingCount))
+	}
+
+	summary.Categories = make([]FeedbackCategorySummary, 0, len(feedbackCategories))
+	for _, c := range feedbackCategories {
+		t := tallies[c]
+		cs := FeedbackCategorySummary{Category: c, Ratings: t.count, Comments: t.comments}
+		if t.count > 0 {
+			cs.AverageRating = roundTenth(float64(t.sum) / float64(t.count))
+		}
+		summary.Categories = append(summary.Categories, cs)
+	}
+	return summary
+}
+
+// ==================== Helpers ====================
+
+// validateFeedbackResponse requires at least one rating, each for a distinct category and
+// between 1 and 5.
+func validateFeedbackResponse(req *RespondFeedbackRequestBody) error {
+	if len(req.Ratings) == 0 {
+		return errors.New("at least one rating is required")
+	}
+	seen := map[FeedbackCategory]bool{}
+	for i := range req.Ratings {
+		rating := &req.Ratings[i]
+		if !rating.Category.IsValid() {
+			return fmt.Errorf("category must be one of technical, leadership, communication, collaboration")
+		}
+		if seen[rating.Category] {
+			return fmt.Errorf("category %s is rated more than once", rating.Category)
+		}
+		seen[rating.Category] = true
+		if rating.Rating < 1 || rating.Rating > 5 {
+			return fmt.Errorf("rating for %s must be between 1 and 5", rating.Category)
+		}
+		rating.Comment = strings.TrimSpace(rating.Comment)
+		if len(rating.Comment) > maxFeedbackCommentLength {
+			return fmt.Errorf("comment for %s must be at most %d characters", rating.Category, maxFeedbackCommentLength)
+		}
+	}
+	if len(strings.TrimSpace(req.Strengths)) > maxFeedbackCommentLength || len(strings.TrimSpace(req.Improvements)) > maxFeedbackCommentLength {
+		return fmt.Errorf("strengths and improvements must be at most %d characters", maxFeedbackCommentLength)
+	}
+	return nil
+}
+
+// effectiveFeedbackStatus reports a pending request past its expiry as expired, without
+// waiting for the scheduler to write it.
+func effectiveFeedbackStatus(r FeedbackRequestRecord, now time.Time) string {
+	if r.Status != string(FeedbackStatusPending) || r.ExpiresAt == "" {
+		return r.Status
+	}
+	expiresAt, err := time.Parse(time.RFC3339, r.ExpiresAt)
+	if err == nil && !now.Before(expiresAt) {
+		return string(FeedbackStatusExpired)
+	}
+	return r.Status
+}
+
+// feedbackRequestTeam returns the team of a request; requests sent before teamId was stored
+// carry it only in their PK.
+func feedbackRequestTeam(r FeedbackRequestRecord) string {
+	if r.TeamID != "" {
+		return r.TeamID
+	}
+	if i := strings.LastIndex(r.PK, "#TEAM#"); i >= 0 {
+		return r.PK[i+len("#TEAM#"):]
+	}
+	return ""
+}
+
+func feedbackRequestKey(r FeedbackRequestRecord) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: r.PK},
+		"SK": &types.AttributeValueMemberS{Value: r.SK},
+	}
+}
+
+func feedbackActorName(displayName, userName string) string {
+	if strings.TrimSpace(displayName) != "" {
+		return strings.TrimSpace(displayName)
+	}
+	return userName
+}
+
+func roundTenth(v float64) float64 {
+	return math.Round(v*10) / 10
+}
+
+func (svc *Service) notify(notifications []companylib.Notification) {
+	if len(notifications) == 0 {
+		return
+	}
+	if err := svc.notifSVC.CreateNotifications(notifications); err != nil {
+		svc.logger.Printf("Failed to create %d notifications: %v", len(notifications), err)
+	}
+}
+
+// queryAll follows LastEvaluatedKey until the query is exhausted.
+func (svc *Service) queryAll(input *dynamodb.QueryInput) ([]map[string]types.AttributeValue, error) {
+	paged := *input
+	var items []map[string]types.AttributeValue
+	for {
+		result, err := svc.ddb.Query(svc.ctx, &paged)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, result.Items...)
+		if len(result.LastEvaluatedKey) == 0 {
+			return items, nil
+		}
+		paged.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
@@ -5,9 +5,11 @@ package common
 // GET  /v2/users/me/appreciations              — list received appreciations
 // POST /v2/users/me/feedback-requests          — send feedback request
 // GET  /v2/users/me/feedback-requests          — list sent feedback requests
+// GET  /v2/users/me/feedback-requests/summary  — aggregated responses to my requests
 // GET  /v2/teams/{teamId}/members/directory    — team member directory
 
 import (
+	"fmt"
 	"net/http"
 	"sort"
 	"time"
@@ -18,6 +20,8 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/google/uuid"
+
+	companylib "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/company-lib"
 )
 
 // ==================== Appreciations ====================
@@ -75,73 +79,89 @@ func (svc *Service) handleFeedbackRequests(request events.APIGatewayProxyRequest
 		case "GET":
 			return svc.listFeedbackRequests(userName, teamID, request.QueryStringParameters)
 		case "POST":
-			return svc.sendFeedbackRequest(userName, teamID, request.Body)
+			return svc.sendFeedbackRequest(userName, displayName, teamID, request.Body)
 		}
 	}
+	if len(parts) == 5 && parts[4] == "summary" && request.HTTPMethod == "GET" {
+		return svc.getFeedbackSummary(userName, teamID)
+	}
 	return svc.errResp(http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed")
 }
 
 func (svc *Service) listFeedbackRequests(userName, teamID string, queryParams map[string]string) (events.APIGatewayProxyResponse, error) {
 	statusFilter := queryString(queryParams, "status")
 
-	result, err := svc.ddb.Query(svc.ctx, &dynamodb.QueryInput{
-		TableName:              aws.String(svc.perfHubTable),
-		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :prefix)"),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":pk":     &types.AttributeValueMemberS{Value: buildPK(userName, teamID)},
-			":prefix": &types.AttributeValueMemberS{Value: SKFeedbackReqPrefix},
-		},
-	})
+	requests, err := svc.fetchFeedbackRequests(userName, teamID)
 	if err != nil {
 		svc.logger.Printf("listFeedbackRequests query error: %v", err)
 		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to list feedback requests")
 	}
-
-	var requests []FeedbackRequestRecord
-	attributevalue.UnmarshalListOfMaps(result.Items, &requests)
-
-	if statusFilter != "" {
-		filtered := make([]FeedbackRequestRecord, 0)
-		for _, r := range requests {
-			if r.Status == statusFilter {
-				filtered = append(filtered, r)
-			}
+	responses, err := svc.fetchFeedbackResponses(userName, teamID)
+	if err != nil {
+		svc.logger.Printf("listFeedbackRequests responses query error: %v", err)
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to list feedback requests")
+	}
+	responseByRequest := make(map[string]FeedbackResponseRecord, len(responses))
+	for _, r := range responses {
+		if r.RequestID != "" {
+			responseByRequest[r.RequestID] = r
 		}
-		requests = filtered
 	}
 
-	sort.Slice(requests, func(i, j int) bool {
-		return requests[i].CreatedAt > requests[j].CreatedAt // newest first
-	})
-
+	now := time.Now().UTC()
 	out := make([]map[string]interface{}, 0, len(requests))
 	for _, r := range requests {
-		out = append(out, buildFeedbackRequestResponse(r))
+		r.Status = effectiveFeedbackStatus(r, now)
+		if statusFilter != "" && r.Status != statusFilter {
+			continue
+		}
+		resp := buildFeedbackRequestResponse(r)
+		if fr, ok := responseByRequest[r.RequestID]; ok {
+			resp["response"] = buildFeedbackResponseResponse(fr)
+		}
+		out = append(out, resp)
 	}
 
 	return svc.okResp(map[string]interface{}{"feedbackRequests": out})
 }
 
-func (svc *Service) sendFeedbackRequest(userName, teamID, body string) (events.APIGatewayProxyResponse, error) {
+func (svc *Service) sendFeedbackRequest(userName, displayName, teamID, body string) (events.APIGatewayProxyResponse, error) {
 	req, err := parseBody[SendFeedbackRequestBody](body)
 	if err != nil || req.ToUsername == "" || req.Message == "" {
 		return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "toUsername and message are required")
 	}
+	if req.ToUsername == userName {
+		return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "You cannot request feedback from yourself")
+	}
+
+	recipient, err := svc.teamsSVC.GetTeamMemberDetails(teamID, req.ToUsername)
+	if err != nil || recipient == nil || !recipient.IsActive {
+		return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "toUsername must be an active member of this team")
+	}
 
-	now := time.Now().UTC().Format(time.RFC3339)
-	today := time.Now().UTC().Format("2006-01-02")
+	createdAt := time.Now().UTC()
+	expiresAt, err := companylib.FeedbackExpiry(createdAt, req.DueDate)
+	if err != nil {
+		return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+	}
 	requestID := uuid.New().String()
 
 	rec := FeedbackRequestRecord{
-		PK:        buildPK(userName, teamID),
-		SK:        SKFeedbackReqPrefix + requestID,
-		RequestID: requestID,
-		UserName:  userName,
-		To:        req.ToUsername,
-		Message:   req.Message,
-		Status:    "pending",
-		Date:      today,
-		CreatedAt: now,
+		PK:          buildPK(userName, teamID),
+		SK:          SKFeedbackReqPrefix + requestID,
+		RequestID:   requestID,
+		TeamID:      teamID,
+		UserName:    userName,
+		FromName:    displayName,
+		To:          req.ToUsername,
+		Message:     req.Message,
+		Status:      string(FeedbackStatusPending),
+		Date:        createdAt.Format("2006-01-02"),
+		DueDate:     expiresAt.Add(-time.Second).Format("2006-01-02"),
+		ExpiresAt:   expiresAt.Format(time.RFC3339),
+		ReminderKey: companylib.FeedbackReminderKey,
+		RemindAt:    companylib.FeedbackRemindAt(createdAt, expiresAt).Format(time.RFC3339),
+		CreatedAt:   createdAt.Format(time.RFC3339),
 	}
 
 	item, err := attributevalue.MarshalMap(rec)
@@ -156,19 +176,102 @@ func (svc *Service) sendFeedbackRequest(userName, teamID, body string) (events.A
 		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to send feedback request")
 	}
 
+	svc.notify([]companylib.Notification{{
+		UserName:      rec.To,
+		Type:          companylib.NotificationTypeFeedbackRequested,
+		ActorUserName: userName,
+		ActorName:     displayName,
+		TeamId:        teamID,
+		Message:       fmt.Sprintf("%s asked for your feedback (due %s)", feedbackActorName(displayName, userName), rec.DueDate),
+	}})
+
 	return svc.createdResp(map[string]interface{}{"feedbackRequest": buildFeedbackRequestResponse(rec)})
 }
 
+// getFeedbackSummary aggregates the responses to the caller's feedback requests.
+func (svc *Service) getFeedbackSummary(userName, teamID string) (events.APIGatewayProxyResponse, error) {
+	summary, err := svc.fetchFeedbackSummary(userName, teamID)
+	if err != nil {
+		svc.logger.Printf("getFeedbackSummary error: %v", err)
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to summarise feedback")
+	}
+	return svc.okResp(summary)
+}
+
+func (svc *Service) fetchFeedbackSummary(userName, teamID string) (FeedbackSummary, error) {
+	requests, err := svc.fetchFeedbackRequests(userName, teamID)
+	if err != nil {
+		return FeedbackSummary{}, err
+	}
+	responses, err := svc.fetchFeedbackResponses(userName, teamID)
+	if err != nil {
+		return FeedbackSummary{}, err
+	}
+	return aggregateFeedback(requests, responses, time.Now().UTC()), nil
+}
+
+// fetchFeedbackRequests returns the requests userName sent in teamID, newest first.
+func (svc *Service) fetchFeedbackRequests(userName, teamID string) ([]FeedbackRequestRecord, error) {
+	items, err := svc.queryAll(&dynamodb.QueryInput{
+		TableName:              aws.String(svc.perfHubTable),
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :prefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":     &types.AttributeValueMemberS{Value: buildPK(userName, teamID)},
+			":prefix": &types.AttributeValueMemberS{Value: SKFeedbackReqPrefix},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var requests []FeedbackRequestRecord
+	attributevalue.UnmarshalListOfMaps(items, &requests)
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].CreatedAt > requests[j].CreatedAt // newest first
+	})
+	return requests, nil
+}
+
+// fetchFeedbackResponses returns the responses given to userName's requests in teamID.
+func (svc *Service) fetchFeedbackResponses(userName, teamID string) ([]FeedbackResponseRecord, error) {
+	items, err := svc.queryAll(&dynamodb.QueryInput{
+		TableName:              aws.String(svc.perfHubTable),
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :prefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":     &types.AttributeValueMemberS{Value: buildPK(userName, teamID)},
+			":prefix": &types.AttributeValueMemberS{Value: SKFeedbackRespPrefix},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var responses []FeedbackResponseRecord
+	attributevalue.UnmarshalListOfMaps(items, &responses)
+	return responses, nil
+}
+
 func buildFeedbackRequestResponse(r FeedbackRequestRecord) map[string]interface{} {
-	return map[string]interface{}{
+	resp := map[string]interface{}{
 		"id":        r.RequestID,
 		"to":        r.To,
 		"from":      r.UserName,
 		"message":   r.Message,
 		"status":    r.Status,
 		"date":      r.Date,
+		"dueDate":   r.DueDate,
+		"expiresAt": r.ExpiresAt,
 		"createdAt": r.CreatedAt,
 	}
+	// Older anonymous answers were stored with a respondedAt, which would identify the responder
+	if r.RespondedAt != "" && !r.Anonymous {
+		resp["respondedAt"] = r.RespondedAt
+	}
+	if r.DeclinedAt != "" {
+		resp["declinedAt"] = r.DeclinedAt
+		resp["declineReason"] = r.DeclineReason
+	}
+	return resp
 }
 
 // ==================== Team Member Directory ====================
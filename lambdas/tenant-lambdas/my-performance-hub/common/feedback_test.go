@@ -0,0 +1,167 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEffectiveFeedbackStatus(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	pending := FeedbackRequestRecord{Status: "pending", ExpiresAt: "2026-03-11T00:00:00Z"}
+
+	assert.Equal(t, "pending", effectiveFeedbackStatus(pending, now))
+	assert.Equal(t, "expired", effectiveFeedbackStatus(pending, now.Add(12*time.Hour)))
+
+	// Requests sent before expiry was recorded never expire on their own
+	assert.Equal(t, "pending", effectiveFeedbackStatus(FeedbackRequestRecord{Status: "pending"}, now))
+
+	completed := pending
+	completed.Status = "completed"
+	assert.Equal(t, "completed", effectiveFeedbackStatus(completed, now.Add(48*time.Hour)))
+}
+
+func TestFeedbackRequestTeam(t *testing.T) {
+	assert.Equal(t, "team-1", feedbackRequestTeam(FeedbackRequestRecord{TeamID: "team-1", PK: buildPK("a@x.com", "team-2")}))
+	assert.Equal(t, "team-2", feedbackRequestTeam(FeedbackRequestRecord{PK: buildPK("a@x.com", "team-2")}))
+}
+
+func TestFeedbackRequestResponseHidesAnonymousAnswerTime(t *testing.T) {
+	named := FeedbackRequestRecord{RequestID: "r1", Status: "completed", RespondedAt: "2026-03-10T09:41:00Z"}
+	anonymous := named
+	anonymous.Anonymous = true
+
+	assert.Equal(t, "2026-03-10T09:41:00Z", buildFeedbackRequestResponse(named)["respondedAt"])
+	assert.Equal(t, "2026-03-10T09:41:00Z", buildFeedbackInboxResponse(named)["respondedAt"])
+
+	// Answered anonymously before respondedAt stopped being stored for anonymous answers
+	assert.NotContains(t, buildFeedbackRequestResponse(anonymous), "respondedAt")
+	assert.NotContains(t, buildFeedbackInboxResponse(anonymous), "respondedAt")
+	assert.Equal(t, true, buildFeedbackInboxResponse(anonymous)["anonymous"])
+}
+
+func TestValidateFeedbackResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    RespondFeedbackRequestBody
+		wantErr string
+	}{
+		{
+			name:    "no ratings",
+			body:    RespondFeedbackRequestBody{Strengths: "great"},
+			wantErr: "at least one rating",
+		},
+		{
+			name:    "unknown category",
+			body:    RespondFeedbackRequestBody{Ratings: []FeedbackCategoryRating{{Category: "charisma", Rating: 4}}},
+			wantErr: "category must be one of",
+		},
+		{
+			name: "duplicate category",
+			body: RespondFeedbackRequestBody{Ratings: []FeedbackCategoryRating{
+				{Category: FeedbackCategoryTechnical, Rating: 4},
+				{Category: FeedbackCategoryTechnical, Rating: 5},
+			}},
+			wantErr: "more than once",
+		},
+		{
+			name:    "rating out of range",
+			body:    RespondFeedbackRequestBody{Ratings: []FeedbackCategoryRating{{Category: FeedbackCategoryLeadership, Rating: 6}}},
+			wantErr: "between 1 and 5",
+		},
+		{
+			name: "valid",
+			body: RespondFeedbackRequestBody{Ratings: []FeedbackCategoryRating{
+				{Category: FeedbackCategoryTechnical, Rating: 4, Comment: "  solid reviews  "},
+				{Category: FeedbackCategoryCommunication, Rating: 3},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateFeedbackResponse(&tt.body)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, "solid reviews", tt.body.Ratings[0].Comment)
+				return
+			}
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAggregateFeedback(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	requests := []FeedbackRequestRecord{
+		{RequestID: "r1", Status: "completed"},
+		{RequestID: "r2", Status: "completed"},
+		{RequestID: "r3", Status: "declined"},
+		{RequestID: "r4", Status: "pending", ExpiresAt: "2026-03-09T00:00:00Z"},
+		{RequestID: "r5", Status: "pending", ExpiresAt: "2026-03-20T00:00:00Z"},
+	}
+	responses := []FeedbackResponseRecord{
+		{
+			RequestID: "r1", Responder: "bob@x.com", ResponderName: "Bob", Date: "2026-03-05",
+			Ratings: []FeedbackCategoryRating{
+				{Category: FeedbackCategoryTechnical, Rating: 5, Comment: "Strong code reviews"},
+				{Category: FeedbackCategoryCommunication, Rating: 3},
+			},
+			Strengths: "Mentoring",
+		},
+		{
+			Anonymous: true, Date: "2026-03-06",
+			Ratings:      []FeedbackCategoryRating{{Category: FeedbackCategoryTechnical, Rating: 1}},
+			Improvements: "Anonymous critique",
+		},
+	}
+
+	summary := aggregateFeedback(requests, responses, now)
+
+	assert.Equal(t, 5, summary.Requested)
+	assert.Equal(t, 1, summary.Pending)
+	assert.Equal(t, 2, summary.Completed)
+	assert.Equal(t, 1, summary.Declined)
+	assert.Equal(t, 1, summary.Expired)
+	assert.Equal(t, 40.0, summary.ResponseRate)
+
+	// A lone anonymous response is withheld
+	assert.Equal(t, 1, summary.Responses)
+	assert.Equal(t, 1, summary.AnonymousResponses)
+	assert.True(t, summary.AnonymousWithheld)
+	assert.Equal(t, 4.0, summary.AverageRating)
+	assert.Empty(t, summary.Improvements)
+	assert.Equal(t, []FeedbackComment{{Text: "Mentoring", From: "bob@x.com", FromName: "Bob", Date: "2026-03-05"}}, summary.Strengths)
+
+	assert.Len(t, summary.Categories, 4)
+	assert.Equal(t, FeedbackCategoryTechnical, summary.Categories[0].Category)
+	assert.Equal(t, 5.0, summary.Categories[0].AverageRating)
+	assert.Equal(t, 1, summary.Categories[0].Ratings)
+	assert.Equal(t, "Strong code reviews", summary.Categories[0].Comments[0].Text)
+	assert.Equal(t, 0, summary.Categories[1].Ratings)
+	assert.Equal(t, 3.0, summary.Categories[2].AverageRating)
+}
+
+func TestAggregateFeedbackIncludesAnonymousAtThreshold(t *testing.T) {
+	anon := FeedbackResponseRecord{
+		Anonymous:    true,
+		Date:         "2026-03-06",
+		Ratings:      []FeedbackCategoryRating{{Category: FeedbackCategoryCollaboration, Rating: 4}},
+		Improvements: "More pairing",
+	}
+	responses := []FeedbackResponseRecord{anon, anon, anon}
+	responses[2].Ratings = []FeedbackCategoryRating{{Category: FeedbackCategoryCollaboration, Rating: 3}}
+
+	summary := aggregateFeedback(nil, responses, time.Now())
+
+	assert.False(t, summary.AnonymousWithheld)
+	assert.Equal(t, 3, summary.Responses)
+	assert.Equal(t, 3.7, summary.AverageRating)
+	assert.Equal(t, 3.7, summary.Categories[3].AverageRating)
+	assert.Len(t, summary.Improvements, 3)
+	assert.Empty(t, summary.Improvements[0].From)
+	assert.Equal(t, 0.0, summary.ResponseRate)
+}
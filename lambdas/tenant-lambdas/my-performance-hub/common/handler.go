@@ -2,12 +2,15 @@ package common
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
 
 	"github.com/aws/aws-lambda-go/events"
+
+	companylib "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/company-lib"
 )
 
 // ==================== Entry Point ====================
@@ -35,6 +38,20 @@ func (svc *Service) Handle(request events.APIGatewayProxyRequest) (events.APIGat
 		return svc.errResp(http.StatusNotFound, "NOT_FOUND", "Route not found")
 	}
 
+	// Suspended organizations are read-only
+	if companylib.IsWriteMethod(request.HTTPMethod) {
+		teamID := strings.TrimSpace(request.QueryStringParameters["teamId"])
+		if len(parts) >= 3 && parts[1] == "teams" {
+			teamID = parts[2]
+		}
+		if err := svc.orgSVC.EnsureTeamWritable(svc.teamsSVC, teamID); errors.Is(err, companylib.ErrOrgReadOnly) {
+			return svc.errResp(http.StatusForbidden, "ORG_READ_ONLY", err.Error())
+		} else if err != nil {
+			svc.logger.Printf("Could not check billing status for team %s: %v", teamID, err)
+			return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Could not check organization billing status")
+		}
+	}
+
 	// /v2/users/me/...
 	if len(parts) >= 4 && parts[1] == "users" && parts[2] == "me" {
 		return svc.handleMe(request, parts, userName, employee.FirstName+" "+employee.LastName)
@@ -77,8 +94,12 @@ func (svc *Service) handleMe(request events.APIGatewayProxyRequest, parts []stri
 		return svc.handleAppreciations(request, parts, userName, teamID)
 	case "feedback-requests":
 		return svc.handleFeedbackRequests(request, parts, userName, displayName, teamID)
+	case "feedback-inbox":
+		return svc.handleFeedbackInbox(request, parts, userName, displayName, teamID)
 	case "tasks":
 		return svc.handleTasks(request, parts, userName, teamID)
+	case "review":
+		return svc.handleMyReview(request, parts, userName, teamID)
 	}
 
 	return svc.errResp(http.StatusNotFound, "NOT_FOUND", "Route not found")
@@ -10,6 +10,7 @@ const (
 	SKMeetingPrefix        = "MEETING#"
 	SKAppreciationPrefix   = "APPR#"
 	SKFeedbackReqPrefix    = "FBREQ#"
+	SKFeedbackRespPrefix   = "FBRESP#"
 	SKTaskPrefix           = "TASK#"
 	SKCommentInfix         = "#CMMNT#"
 	SKManagerCommentPrefix = "MGRCMT#"
@@ -89,6 +90,37 @@ const (
 	FeedbackCategoryCollaboration FeedbackCategory = "collaboration"
 )
 
+// IsValid reports whether c is one of the feedback categories.
+func (c FeedbackCategory) IsValid() bool {
+	switch c {
+	case FeedbackCategoryTechnical, FeedbackCategoryLeadership, FeedbackCategoryCommunication, FeedbackCategoryCollaboration:
+		return true
+	}
+	return false
+}
+
+// ==================== Feedback Request Status ====================
+
+type FeedbackRequestStatus string
+
+const (
+	FeedbackStatusPending   FeedbackRequestStatus = "pending"
+	FeedbackStatusCompleted FeedbackRequestStatus = "completed"
+	FeedbackStatusDeclined  FeedbackRequestStatus = "declined"
+	FeedbackStatusExpired   FeedbackRequestStatus = "expired"
+)
+
+// ==================== DDB Indexes ====================
+
+const (
+	// FeedbackToIndex — HASH to, RANGE createdAt. The recipient's feedback inbox.
+	FeedbackToIndex = "FeedbackToIndex"
+
+	// FeedbackReminderIndex — HASH reminderKey, RANGE remindAt. Sparse: only pending feedback
+	// requests carry reminderKey, so the scheduler reads just the requests that need attention.
+	FeedbackReminderIndex = "FeedbackReminderIndex"
+)
+
 // ==================== DDB Records ====================
 
 // GoalRecord — PK=USER#{userName}#TEAM#{teamId} SK=GOAL#{goalId}
@@ -182,17 +214,56 @@ type AppreciationRecord struct {
 	CreatedAt      string `dynamodbav:"createdAt"`
 }
 
-// FeedbackRequestRecord — PK=USER#{userName} SK=FBREQ#{requestId}
+// FeedbackRequestRecord — PK=USER#{userName}#TEAM#{teamId} SK=FBREQ#{requestId}
+// Pending requests expire at the end of dueDate. While pending they carry reminderKey/remindAt
+// (FeedbackReminderIndex) so the scheduler can remind the recipient and later expire the request.
 type FeedbackRequestRecord struct {
-	PK        string `dynamodbav:"PK"`
-	SK        string `dynamodbav:"SK"`
-	RequestID string `dynamodbav:"requestId"`
-	UserName  string `dynamodbav:"userName"` // the requester (sender)
-	To        string `dynamodbav:"to"`       // toUsername
-	Message   string `dynamodbav:"message"`
-	Date      string `dynamodbav:"date"`
-	Status    string `dynamodbav:"status"` // pending | completed
-	CreatedAt string `dynamodbav:"createdAt"`
+	PK            string `dynamodbav:"PK"`
+	SK            string `dynamodbav:"SK"`
+	RequestID     string `dynamodbav:"requestId"`
+	TeamID        string `dynamodbav:"teamId,omitempty"`
+	UserName      string `dynamodbav:"userName"` // the requester (sender)
+	FromName      string `dynamodbav:"fromName,omitempty"`
+	To            string `dynamodbav:"to"` // toUsername
+	Message       string `dynamodbav:"message"`
+	Date          string `dynamodbav:"date"`
+	Status        string `dynamodbav:"status"` // pending | completed | declined | expired
+	DueDate       string `dynamodbav:"dueDate,omitempty"`
+	ExpiresAt     string `dynamodbav:"expiresAt,omitempty"`
+	Anonymous     bool   `dynamodbav:"anonymous,omitempty"`
+	RespondedAt   string `dynamodbav:"respondedAt,omitempty"` // not kept for anonymous answers
+	DeclinedAt    string `dynamodbav:"declinedAt,omitempty"`
+	DeclineReason string `dynamodbav:"declineReason,omitempty"`
+	ReminderKey   string `dynamodbav:"reminderKey,omitempty"`
+	RemindAt      string `dynamodbav:"remindAt,omitempty"`
+	RemindersSent int    `dynamodbav:"remindersSent,omitempty"`
+	CreatedAt     string `dynamodbav:"createdAt"`
+}
+
+// FeedbackResponseRecord — PK=USER#{requester}#TEAM#{teamId} SK=FBRESP#{responseId}
+// Anonymous responses store neither the responder nor the request they answer, and only the
+// date they were given, so they cannot be traced back to a request.
+type FeedbackResponseRecord struct {
+	PK            string                   `dynamodbav:"PK"`
+	SK            string                   `dynamodbav:"SK"`
+	ResponseID    string                   `dynamodbav:"responseId"`
+	RequestID     string                   `dynamodbav:"requestId,omitempty"`
+	TeamID        string                   `dynamodbav:"teamId"`
+	UserName      string                   `dynamodbav:"userName"` // the requester
+	Responder     string                   `dynamodbav:"responder,omitempty"`
+	ResponderName string                   `dynamodbav:"responderName,omitempty"`
+	Anonymous     bool                     `dynamodbav:"anonymous"`
+	Ratings       []FeedbackCategoryRating `dynamodbav:"ratings"`
+	Strengths     string                   `dynamodbav:"strengths,omitempty"`
+	Improvements  string                   `dynamodbav:"improvements,omitempty"`
+	Date          string                   `dynamodbav:"date"`
+	CreatedAt     string                   `dynamodbav:"createdAt,omitempty"`
+}
+
+type FeedbackCategoryRating struct {
+	Category FeedbackCategory `dynamodbav:"category" json:"category"`
+	Rating   int              `dynamodbav:"rating" json:"rating"` // 1-5
+	Comment  string           `dynamodbav:"comment,omitempty" json:"comment,omitempty"`
 }
 
 // ==================== Request Bodies ====================
@@ -273,6 +344,18 @@ type CreateMeetingRequest struct {
 type SendFeedbackRequestBody struct {
 	ToUsername string `json:"toUsername"`
 	Message    string `json:"message"`
+	DueDate    string `json:"dueDate,omitempty"` // YYYY-MM-DD, defaults to 14 days out
+}
+
+type RespondFeedbackRequestBody struct {
+	Ratings      []FeedbackCategoryRating `json:"ratings"`
+	Strengths    string                   `json:"strengths,omitempty"`
+	Improvements string                   `json:"improvements,omitempty"`
+	Anonymous    bool                     `json:"anonymous"`
+}
+
+type DeclineFeedbackRequestBody struct {
+	Reason string `json:"reason,omitempty"`
 }
 
 // ==================== Team Performance (Manager View) Records ====================
@@ -294,6 +377,7 @@ type ManagerCommentRecord struct {
 
 // TeamMemberReviewRecord — PK=TEAM#{teamID} SK=REVIEW#MEMBER#{memberUserName}
 // Tracks per-member review lifecycle state visible to the manager.
+// Written by the performance review workflow in company-lib (company-performance-reviews.go).
 type TeamMemberReviewRecord struct {
 	PK                    string  `dynamodbav:"PK"`
 	SK                    string  `dynamodbav:"SK"`
@@ -303,6 +387,8 @@ type TeamMemberReviewRecord struct {
 	LastReviewDate        string  `dynamodbav:"lastReviewDate,omitempty"`
 	IsPendingReview       bool    `dynamodbav:"isPendingReview"`
 	HasUserUpdatedReviews bool    `dynamodbav:"hasUserUpdatedReviews"`
+	ReviewID              string  `dynamodbav:"reviewId,omitempty"`
+	ReviewStatus          string  `dynamodbav:"reviewStatus,omitempty"`
 	UpdatedAt             string  `dynamodbav:"updatedAt"`
 }
 
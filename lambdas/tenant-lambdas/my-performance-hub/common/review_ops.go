@@ -0,0 +1,134 @@
+package common
+
+// ==================== Routes ====================
+//
+// GET  /v2/users/me/review?teamId=                                      — my current performance review
+// PUT  /v2/users/me/review/self-assessment?teamId=                      — save or submit my self-assessment
+// POST /v2/users/me/review/sign-off?teamId=                             — sign off my review
+// GET  /v2/teams/{teamId}/members/{memberId}/review                     — member's current review (manager view)
+// PUT  /v2/teams/{teamId}/members/{memberId}/review/manager-assessment  — save or submit the manager assessment
+// POST /v2/teams/{teamId}/members/{memberId}/review/sign-off            — manager sign-off
+//
+// Reviews are opened, listed and calibrated by org admins through the org performance APIs.
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	companylib "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/company-lib"
+)
+
+// SignOffReviewRequest — for POST .../review/sign-off
+type SignOffReviewRequest struct {
+	Comment string `json:"comment"`
+}
+
+// handleMyReview dispatches /v2/users/me/review/... routes.
+func (svc *Service) handleMyReview(request events.APIGatewayProxyRequest, parts []string, userName, teamID string) (events.APIGatewayProxyResponse, error) {
+	if errResp := svc.assertTeamMember(teamID, userName); errResp != nil {
+		return *errResp, nil
+	}
+
+	review, err := svc.perfSVC.GetMemberReview(teamID, userName)
+	if err != nil {
+		return svc.reviewErrResp(err)
+	}
+
+	switch {
+	case len(parts) == 4 && request.HTTPMethod == "GET":
+		return svc.okResp(review)
+	case len(parts) == 5 && parts[4] == "self-assessment" && request.HTTPMethod == "PUT":
+		req, err := parseBody[companylib.ReviewAssessmentInput](request.Body)
+		if err != nil {
+			return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		}
+		updated, err := svc.perfSVC.SaveSelfAssessment(review.ID, userName, *req)
+		if err != nil {
+			return svc.reviewErrResp(err)
+		}
+		return svc.okResp(updated)
+	case len(parts) == 5 && parts[4] == "sign-off" && request.HTTPMethod == "POST":
+		req, err := parseBody[SignOffReviewRequest](request.Body)
+		if err != nil {
+			return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		}
+		updated, err := svc.perfSVC.SignOffReview(review.ID, userName, false, req.Comment)
+		if err != nil {
+			return svc.reviewErrResp(err)
+		}
+		return svc.okResp(updated)
+	}
+
+	return svc.errResp(http.StatusNotFound, "NOT_FOUND", "Route not found")
+}
+
+// handleMemberReview dispatches /v2/teams/{teamId}/members/{memberId}/review/... routes for the
+// member's manager.
+func (svc *Service) handleMemberReview(request events.APIGatewayProxyRequest, parts []string, teamID, memberID, managerUserName string) (events.APIGatewayProxyResponse, error) {
+	if errResp := svc.assertTeamManager(teamID, managerUserName); errResp != nil {
+		return *errResp, nil
+	}
+	if memberID == managerUserName {
+		return svc.errResp(http.StatusForbidden, "FORBIDDEN", "You cannot act as the manager on your own review")
+	}
+
+	review, err := svc.perfSVC.GetMemberReview(teamID, memberID)
+	if err != nil {
+		return svc.reviewErrResp(err)
+	}
+
+	switch {
+	case len(parts) == 6 && request.HTTPMethod == "GET":
+		return svc.okResp(review)
+	case len(parts) == 7 && parts[6] == "manager-assessment" && request.HTTPMethod == "PUT":
+		req, err := parseBody[companylib.ReviewAssessmentInput](request.Body)
+		if err != nil {
+			return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		}
+		updated, err := svc.perfSVC.SaveManagerAssessment(review.ID, managerUserName, *req)
+		if err != nil {
+			return svc.reviewErrResp(err)
+		}
+		return svc.okResp(updated)
+	case len(parts) == 7 && parts[6] == "sign-off" && request.HTTPMethod == "POST":
+		updated, err := svc.perfSVC.SignOffReview(review.ID, managerUserName, true, "")
+		if err != nil {
+			return svc.reviewErrResp(err)
+		}
+		return svc.okResp(updated)
+	}
+
+	return svc.errResp(http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed")
+}
+
+// assertTeamManager verifies that userName is an active ADMIN or OWNER of teamID.
+// Returns a pointer to an APIGatewayProxyResponse if the check fails, nil otherwise.
+func (svc *Service) assertTeamManager(teamID, userName string) *events.APIGatewayProxyResponse {
+	member, err := svc.teamsSVC.GetTeamMemberDetails(teamID, userName)
+	if err != nil || member == nil || !member.IsActive ||
+		(member.Role != companylib.TeamMemberRoleAdmin && member.Role != companylib.TeamMemberRoleOwner) {
+		resp, _ := svc.errResp(http.StatusForbidden, "FORBIDDEN", "Only team admins and owners can review members")
+		return &resp
+	}
+	return nil
+}
+
+// reviewErrResp maps the review workflow errors to their HTTP status.
+func (svc *Service) reviewErrResp(err error) (events.APIGatewayProxyResponse, error) {
+	switch {
+	case errors.Is(err, companylib.ErrReviewNotFound):
+		return svc.errResp(http.StatusNotFound, "NOT_FOUND", "No performance review is open for this member")
+	case errors.Is(err, companylib.ErrReviewForbidden):
+		return svc.errResp(http.StatusForbidden, "FORBIDDEN", err.Error())
+	case errors.Is(err, companylib.ErrReviewLocked), errors.Is(err, companylib.ErrReviewStage), errors.Is(err, companylib.ErrReviewConflict):
+		return svc.errResp(http.StatusConflict, "CONFLICT", err.Error())
+	case errors.Is(err, companylib.ErrReviewWindowClosed):
+		return svc.errResp(http.StatusBadRequest, "REVIEW_WINDOW_CLOSED", err.Error())
+	case errors.Is(err, companylib.ErrReviewInvalid):
+		return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+	}
+	svc.logger.Printf("review error: %v", err)
+	return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to update the performance review")
+}
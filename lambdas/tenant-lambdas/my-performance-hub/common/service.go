@@ -20,6 +20,9 @@ type Service struct {
 	logger       *log.Logger
 	empSVC       *companylib.EmployeeService
 	teamsSVC     *companylib.TeamsServiceV2
+	orgSVC       *companylib.OrgServiceV2
+	perfSVC      *companylib.PerformanceService
+	notifSVC     *companylib.NotificationService
 	ddb          *dynamodb.Client
 	perfHubTable string
 }
@@ -48,11 +51,25 @@ func NewService() (*Service, error) {
 	teamsSvc := companylib.CreateTeamsServiceV2(ctx, ddbClient, logger, empSvc, nil)
 	teamsSvc.TeamsTable = os.Getenv("TEAMS_TABLE")
 
+	orgSvc := companylib.CreateOrgServiceV2(ctx, ddbClient, logger, empSvc, nil)
+	orgSvc.OrganizationTable = os.Getenv("ORGANIZATION_TABLE")
+
+	perfSvc := companylib.CreatePerformanceService(ctx, ddbClient, logger)
+	perfSvc.OrgPerformanceTable = os.Getenv("ORG_PERFORMANCE_TABLE")
+	perfSvc.PerfHubTable = os.Getenv("PERF_HUB_TABLE")
+
+	notifSvc := companylib.CreateNotificationService(ctx, ddbClient, logger, nil)
+	notifSvc.NotificationsTable = os.Getenv("NOTIFICATIONS_TABLE")
+	notifSvc.NotificationsTable_DigestIndex = os.Getenv("NOTIFICATIONS_TABLE_DIGEST_INDEX")
+
 	return &Service{
 		ctx:          ctx,
 		logger:       logger,
 		empSVC:       empSvc,
 		teamsSVC:     teamsSvc,
+		orgSVC:       orgSvc,
+		perfSVC:      perfSvc,
+		notifSVC:     notifSvc,
 		ddb:          ddbClient,
 		perfHubTable: os.Getenv("PERF_HUB_TABLE"),
 	}, nil
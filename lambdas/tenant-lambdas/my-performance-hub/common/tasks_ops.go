@@ -162,14 +162,13 @@ func (svc *Service) createTask(userName, teamID, body string) (events.APIGateway
 	}
 
 	// Allocate team-scoped TASK-N identifier
-	taskNum, err := svc.nextTaskNumber(teamID)
+	taskID, taskNum, err := svc.perfSVC.AllocateTaskID(teamID)
 	if err != nil {
-		svc.logger.Printf("createTask nextTaskNumber error: %v", err)
+		svc.logger.Printf("createTask AllocateTaskID error: %v", err)
 		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to allocate task number")
 	}
 
 	now := time.Now().UTC().Format(time.RFC3339)
-	taskID := fmt.Sprintf("TASK-%d", taskNum)
 	done := status == string(TaskStatusDone) || status == string(TaskStatusClosed)
 
 	rec := LinkedTaskRecord{
@@ -381,34 +380,3 @@ func buildTaskResponse(t LinkedTaskRecord) map[string]interface{} {
 		"updatedAt":   t.UpdatedAt,
 	}
 }
-
-// ==================== Task Number Counter ====================
-
-// nextTaskNumber atomically increments the team-scoped task counter and returns
-// the next task number. The first task in a team gets number 101.
-func (svc *Service) nextTaskNumber(teamID string) (int, error) {
-	result, err := svc.ddb.UpdateItem(svc.ctx, &dynamodb.UpdateItemInput{
-		TableName: aws.String(svc.perfHubTable),
-		Key: map[string]types.AttributeValue{
-			"PK": &types.AttributeValueMemberS{Value: buildTeamPK(teamID)},
-			"SK": &types.AttributeValueMemberS{Value: "COUNTER#TASK_NUM"},
-		},
-		UpdateExpression: aws.String("ADD taskCounter :incr"),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":incr": &types.AttributeValueMemberN{Value: "1"},
-		},
-		ReturnValues: types.ReturnValueUpdatedNew,
-	})
-	if err != nil {
-		return 0, err
-	}
-
-	var counter struct {
-		TaskCounter int `dynamodbav:"taskCounter"`
-	}
-	if err := attributevalue.UnmarshalMap(result.Attributes, &counter); err != nil {
-		return 0, err
-	}
-	// DDB ADD starts from 0, so offset by 100 to make the first task TASK-101
-	return counter.TaskCounter + 100, nil
-}
@@ -9,6 +9,8 @@ package common
 // GET  /v2/teams/{teamId}/members/{memberId}/comments                  — manager comments & feedback
 // POST /v2/teams/{teamId}/members/{memberId}/comments                  — add manager comment
 // GET  /v2/teams/{teamId}/members/{memberId}/performance-summary       — all-in-one member detail
+//
+// Member review routes (/v2/teams/{teamId}/members/{memberId}/review/...) are in review_ops.go.
 
 import (
 	"net/http"
@@ -35,6 +37,8 @@ import (
 //	[v2, teams, {teamId}, members, {memberId}, appreciations]
 //	[v2, teams, {teamId}, members, {memberId}, comments]
 //	[v2, teams, {teamId}, members, {memberId}, performance-summary]
+//	[v2, teams, {teamId}, members, {memberId}, review]                       — GET member review
+//	[v2, teams, {teamId}, members, {memberId}, review, {action}]             — manager-assessment | sign-off
 func (svc *Service) handleTeamPerformance(request events.APIGatewayProxyRequest, parts []string, managerUserName, managerDisplayName string) (events.APIGatewayProxyResponse, error) {
 	teamID := parts[2]
 
@@ -56,6 +60,11 @@ func (svc *Service) handleTeamPerformance(request events.APIGatewayProxyRequest,
 		return svc.errResp(http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed")
 	}
 
+	// /v2/teams/{teamId}/members/{memberId}/review[/{action}]  (6-7 parts)
+	if len(parts) >= 6 && len(parts) <= 7 && parts[3] == "members" && parts[5] == "review" {
+		return svc.handleMemberReview(request, parts, teamID, parts[4], managerUserName)
+	}
+
 	// /v2/teams/{teamId}/members/{memberId}/{resource}  (6 parts)
 	if len(parts) == 6 && parts[3] == "members" {
 		memberID := parts[4]
@@ -134,11 +143,15 @@ func (svc *Service) getTeamPerformanceMembers(teamID, managerUserName string) (e
 			memberMap["lastReviewDate"] = review.LastReviewDate
 			memberMap["isPendingReview"] = review.IsPendingReview
 			memberMap["hasUserUpdatedReviews"] = review.HasUserUpdatedReviews
+			memberMap["reviewId"] = review.ReviewID
+			memberMap["reviewStatus"] = review.ReviewStatus
 		} else {
 			memberMap["overallRating"] = 0.0
 			memberMap["lastReviewDate"] = nil
 			memberMap["isPendingReview"] = false
 			memberMap["hasUserUpdatedReviews"] = false
+			memberMap["reviewId"] = nil
+			memberMap["reviewStatus"] = nil
 		}
 		out = append(out, memberMap)
 	}
@@ -448,6 +461,16 @@ func (svc *Service) getMemberPerformanceSummary(teamID, memberID, managerUserNam
 		commentList = append(commentList, buildManagerCommentResponse(c))
 	}
 
+	// Peer feedback — only team admins and owners see it
+	var feedback *FeedbackSummary
+	if svc.assertTeamManager(teamID, managerUserName) == nil {
+		if summary, err := svc.fetchFeedbackSummary(memberID, teamID); err != nil {
+			svc.logger.Printf("getMemberPerformanceSummary feedback error: %v", err)
+		} else {
+			feedback = &summary
+		}
+	}
+
 	return svc.okResp(map[string]interface{}{
 		"profile":       profile,
 		"okrs":          okrs,
@@ -455,6 +478,7 @@ func (svc *Service) getMemberPerformanceSummary(teamID, memberID, managerUserNam
 		"meetings":      meetings,
 		"appreciations": appreciations,
 		"comments":      commentList,
+		"feedback":      feedback,
 	})
 }
 
@@ -11,6 +11,7 @@ require (
 	github.com/aws/aws-xray-sdk-go v1.8.2
 	github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/company-lib v0.0.0
 	github.com/google/uuid v1.6.0
+	github.com/stretchr/testify v1.7.2
 )
 
 require (
@@ -42,10 +43,12 @@ require (
 	github.com/aws/smithy-go v1.24.0 // indirect
 	github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/clients v0.0.0-00010101000000-000000000000 // indirect
 	github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/utils v0.0.0-00010101000000-000000000000 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/golang/protobuf v1.4.3 // indirect
 	github.com/jmespath/go-jmespath v0.4.0 // indirect
 	github.com/klauspost/compress v1.15.0 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
 	github.com/valyala/fasthttp v1.34.0 // indirect
 	golang.org/x/net v0.7.0 // indirect
@@ -54,6 +57,7 @@ require (
 	google.golang.org/genproto v0.0.0-20210114201628-6edceaf6022f // indirect
 	google.golang.org/grpc v1.35.0 // indirect
 	google.golang.org/protobuf v1.25.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
 
 replace github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/company-lib => ../../lib/company-lib
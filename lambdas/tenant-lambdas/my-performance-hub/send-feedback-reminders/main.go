@@ -0,0 +1,19 @@
+package main
+
+import (
+	"log"
+
+	"github.com/aws/aws-lambda-go/lambda"
+
+	common "github.com/busyfit-admin/saas-integrated-apis/lambdas/tenant-lambdas/my-performance-hub/common"
+)
+
+// Runs on an EventBridge schedule, reminds recipients of pending feedback requests and expires overdue ones
+func main() {
+	svc, err := common.NewService()
+	if err != nil {
+		log.Fatalf("failed to initialize send-feedback-reminders service: %v", err)
+	}
+
+	lambda.Start(svc.HandleFeedbackReminderSchedule)
+}
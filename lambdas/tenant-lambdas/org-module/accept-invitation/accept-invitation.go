@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-xray-sdk-go/instrumentation/awsv2"
+	"github.com/aws/aws-xray-sdk-go/xray"
+
+	companylib "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/company-lib"
+)
+
+type Service struct {
+	ctx      context.Context
+	logger   *log.Logger
+	orgSVC   *companylib.OrgServiceV2
+	teamsSVC *companylib.TeamsServiceV2
+	empSVC   *companylib.EmployeeService
+}
+
+var RESP_HEADERS = companylib.GetHeadersForAPI("OrganizationAPI")
+
+func main() {
+	ctx, root := xray.BeginSegment(context.TODO(), "accept-invitation")
+	defer root.Close(nil)
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		log.Fatalf("Cannot load config: %v\n", err)
+	}
+
+	awsv2.AWSV2Instrumentor(&cfg.APIOptions)
+
+	logger := log.New(os.Stdout, "", log.LstdFlags)
+	ddbclient := dynamodb.NewFromConfig(cfg)
+
+	// Initialize employee service
+	empSvc := companylib.CreateEmployeeService(ctx, ddbclient, nil, logger)
+	empSvc.EmployeeTable = os.Getenv("EMPLOYEE_TABLE")
+	empSvc.EmployeeTable_CognitoId_Index = os.Getenv("EMPLOYEE_TABLE_COGNITO_ID_INDEX")
+
+	// Initialize organization service
+	orgSvc := companylib.CreateOrgServiceV2(ctx, ddbclient, logger, empSvc, nil)
+	orgSvc.OrganizationTable = os.Getenv("ORGANIZATION_TABLE")
+	orgSvc.TeamsTable = os.Getenv("TEAMS_TABLE")
+
+	// Initialize teams service
+	teamsSvc := companylib.CreateTeamsServiceV2(ctx, ddbclient, logger, empSvc, nil)
+	teamsSvc.TeamsTable = os.Getenv("TEAMS_TABLE")
+
+	svc := &Service{
+		ctx:      ctx,
+		logger:   logger,
+		orgSVC:   orgSvc,
+		teamsSVC: teamsSvc,
+		empSVC:   empSvc,
+	}
+
+	lambda.Start(svc.Handler)
+}
+
+// Handler handles the Lambda request
+func (svc *Service) Handler(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	svc.logger.Printf("Received request: %s %s", request.HTTPMethod, request.Path)
+
+	// Handle OPTIONS request for CORS preflight
+	if request.HTTPMethod == "OPTIONS" {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+			Headers:    RESP_HEADERS,
+			Body:       "",
+		}, nil
+	}
+
+	switch request.HTTPMethod {
+	case "POST":
+		return svc.acceptInvitation(request)
+	default:
+		return svc.errorResponse(http.StatusMethodNotAllowed, "Method not allowed", nil)
+	}
+}
+
+// AcceptInvitationRequest carries the token from the invitation link
+type AcceptInvitationRequest struct {
+	Token string `json:"token"`
+}
+
+// acceptInvitation verifies the invitation token, checks it was sent to the signed in user and
+// activates their organization membership (and team membership, if the invitation was for a team)
+func (svc *Service) acceptInvitation(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var req AcceptInvitationRequest
+	if err := json.Unmarshal([]byte(request.Body), &req); err != nil || req.Token == "" {
+		return svc.errorResponse(http.StatusBadRequest, "token is required", err)
+	}
+
+	claims, err := verifyInvitationToken(req.Token)
+	if err != nil {
+		svc.logger.Printf("Invitation token rejected: %v", err)
+		if errors.Is(err, errInvitationExpired) {
+			return svc.errorResponse(http.StatusGone, "Invitation has expired, ask for it to be resent", err)
+		}
+		return svc.errorResponse(http.StatusBadRequest, "Invalid invitation token", err)
+	}
+
+	// The invitation can only be accepted by the person it was sent to
+	callerEmail, displayName, err := svc.getCaller(request)
+	if err != nil {
+		svc.logger.Printf("Failed to identify caller: %v", err)
+		return svc.errorResponse(http.StatusUnauthorized, "Unauthorized", err)
+	}
+	if !strings.EqualFold(strings.TrimSpace(callerEmail), strings.TrimSpace(claims.Email)) {
+		svc.logger.Printf("Invitation for %s presented by %s", claims.Email, callerEmail)
+		return svc.errorResponse(http.StatusForbidden, "This invitation was sent to a different email address", nil)
+	}
+
+	// Suspended organizations are read-only
+	if err := svc.orgSVC.EnsureOrgWritable(claims.OrganizationId); err != nil {
+		if errors.Is(err, companylib.ErrOrgReadOnly) {
+			return svc.errorResponse(http.StatusForbidden, "Organization is read-only", err)
+		}
+		return svc.errorResponse(http.StatusInternalServerError, "Failed to load organization", err)
+	}
+
+	invitation, err := svc.orgSVC.GetInvitationForAcceptance(claims.OrganizationId, claims.Email, claims.ID)
+	if err != nil {
+		return svc.invitationErrorResponse(err)
+	}
+
+	// The team and role come from the signed token, which must match the recorded invitation
+	var teamItems []types.TransactWriteItem
+	if claims.TeamId != invitation.InvitationTeamId {
+		return svc.errorResponse(http.StatusBadRequest, "Invalid invitation token", fmt.Errorf("token team %q does not match invitation team %q", claims.TeamId, invitation.InvitationTeamId))
+	}
+	if claims.TeamId != "" {
+		teamItems, err = svc.teamsSVC.InvitationMembershipItems(claims.TeamId, claims.OrganizationId, invitation.UserName, displayName, companylib.TeamRoleFromInvitation(claims.Role))
+		if err != nil {
+			svc.logger.Printf("Cannot add %s to team %s: %v", invitation.UserName, claims.TeamId, err)
+			return svc.errorResponse(http.StatusConflict, "The team in this invitation is no longer available", err)
+		}
+	}
+
+	if err := svc.orgSVC.AcceptInvitation(invitation, displayName, teamItems); err != nil {
+		return svc.invitationErrorResponse(err)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"message":          "Invitation accepted",
+		"organizationId":   claims.OrganizationId,
+		"organizationName": claims.OrganizationName,
+		"teamId":           claims.TeamId,
+		"role":             claims.Role,
+	})
+	if err != nil {
+		return svc.errorResponse(http.StatusInternalServerError, "Failed to create response", err)
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers:    RESP_HEADERS,
+		Body:       string(body),
+	}, nil
+}
+
+// getCaller returns the signed in user's email, from the Cognito claims or their employee record,
+// and their display name
+func (svc *Service) getCaller(request events.APIGatewayProxyRequest) (string, string, error) {
+	var email, cognitoId string
+	if claims, ok := request.RequestContext.Authorizer["claims"].(map[string]interface{}); ok {
+		email, _ = claims["email"].(string)
+		cognitoId, _ = claims["sub"].(string)
+	}
+	// Fallback to headers (for testing/development)
+	if cognitoId == "" {
+		cognitoId = request.Headers["cognito-id"]
+	}
+	if cognitoId == "" {
+		return "", "", fmt.Errorf("cognito ID not found in request")
+	}
+
+	displayName := ""
+	employee, err := svc.empSVC.GetEmployeeDataByCognitoId(cognitoId)
+	if err == nil {
+		displayName = employee.DisplayName
+		if email == "" {
+			email = employee.EmailID
+		}
+	}
+	if email == "" {
+		return "", "", fmt.Errorf("email not found for cognito ID %s", cognitoId)
+	}
+
+	return email, displayName, nil
+}
+
+// invitationErrorResponse maps invitation errors to HTTP status codes
+func (svc *Service) invitationErrorResponse(err error) (events.APIGatewayProxyResponse, error) {
+	svc.logger.Printf("Failed to accept invitation: %v", err)
+
+	switch {
+	case errors.Is(err, companylib.ErrInvitationNotFound):
+		return svc.errorResponse(http.StatusNotFound, "Invitation not found", err)
+	case errors.Is(err, companylib.ErrInvitationAlreadyUsed):
+		return svc.errorResponse(http.StatusConflict, "Invitation has already been accepted", err)
+	case errors.Is(err, companylib.ErrInvitationRevoked), errors.Is(err, companylib.ErrInvitationSuperseded):
+		return svc.errorResponse(http.StatusGone, "Invitation is no longer valid, ask for it to be resent", err)
+	case errors.Is(err, companylib.ErrMemberLimitReached):
+		return svc.errorResponse(http.StatusPaymentRequired, companylib.MemberLimitErrorCode, err)
+	default:
+		return svc.errorResponse(http.StatusInternalServerError, "Failed to accept invitation", err)
+	}
+}
+
+// errorResponse creates an error response
+func (svc *Service) errorResponse(statusCode int, message string, err error) (events.APIGatewayProxyResponse, error) {
+	errorMessage := message
+	if err != nil {
+		errorMessage = fmt.Sprintf("%s: %v", message, err)
+	}
+
+	body, _ := json.Marshal(map[string]string{
+		"error":   message,
+		"details": errorMessage,
+	})
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: statusCode,
+		Headers:    RESP_HEADERS,
+		Body:       string(body),
+	}, nil
+}
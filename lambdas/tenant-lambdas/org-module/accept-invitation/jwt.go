@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	errInvalidInvitationToken = errors.New("invalid invitation token")
+	errInvitationExpired      = errors.New("invitation has expired")
+)
+
+// InvitationTokenClaims represents the JWT claims issued by send-invitations
+type InvitationTokenClaims struct {
+	Email            string `json:"email"`
+	OrganizationId   string `json:"organizationId"`
+	OrganizationName string `json:"organizationName"`
+	TeamId           string `json:"teamId,omitempty"`
+	Role             string `json:"role"`
+	InvitedBy        string `json:"invitedBy"`
+	jwt.RegisteredClaims
+}
+
+// verifyInvitationToken checks the token was signed (HS256) with INVITATION_TOKEN_SECRET and has not
+// expired, and returns its claims
+func verifyInvitationToken(tokenString string) (*InvitationTokenClaims, error) {
+	secret := os.Getenv("INVITATION_TOKEN_SECRET")
+	if secret == "" {
+		return nil, fmt.Errorf("INVITATION_TOKEN_SECRET environment variable not set")
+	}
+
+	claims := &InvitationTokenClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, errInvitationExpired
+		}
+		return nil, fmt.Errorf("%w: %v", errInvalidInvitationToken, err)
+	}
+	if !token.Valid {
+		return nil, errInvalidInvitationToken
+	}
+
+	if claims.Email == "" || claims.OrganizationId == "" {
+		return nil, fmt.Errorf("%w: missing email or organization", errInvalidInvitationToken)
+	}
+
+	return claims, nil
+}
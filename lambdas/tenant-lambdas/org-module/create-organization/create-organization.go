@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -55,6 +56,9 @@ func main() {
 	orgSvc := companylib.CreateOrgServiceV2(ctx, ddbclient, logger, empSvc, emailSvc)
 	orgSvc.OrganizationTable = os.Getenv("ORGANIZATION_TABLE")
 	orgSvc.PromoCodesTable = os.Getenv("PROMO_CODES_TABLE")
+	orgSvc.PlanCatalog = companylib.CreateSubscriptionPlanService(ctx, ddbclient, logger)
+	orgSvc.PlanCatalog.SubscriptionPlansTable = os.Getenv("SUBSCRIPTION_PLANS_TABLE")
+	orgSvc.DefaultPlanID = os.Getenv("DEFAULT_PLAN_ID")
 
 	svc := &Service{
 		ctx:    ctx,
@@ -122,6 +126,10 @@ func (svc *Service) createOrganization(userName string, request events.APIGatewa
 
 	// Create the organization
 	organization, err := svc.orgSVC.CreateOrganization(input)
+	if errors.Is(err, companylib.ErrDefaultPlanUnavailable) {
+		svc.logger.Printf("Failed to create organization: %v", err)
+		return svc.errorResponse(http.StatusServiceUnavailable, "Organizations cannot be created until the default plan is available", err)
+	}
 	if err != nil {
 		svc.logger.Printf("Failed to create organization: %v", err)
 		return svc.errorResponse(http.StatusInternalServerError, "Failed to create organization", err)
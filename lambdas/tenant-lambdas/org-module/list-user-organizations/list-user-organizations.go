@@ -55,6 +55,8 @@ func main() {
 	orgSvc := companylib.CreateOrgServiceV2(ctx, ddbclient, logger, empSvc, emailSvc)
 	orgSvc.OrganizationTable = os.Getenv("ORGANIZATION_TABLE")
 	orgSvc.PromoCodesTable = os.Getenv("PROMO_CODES_TABLE")
+	orgSvc.PlanCatalog = companylib.CreateSubscriptionPlanService(ctx, ddbclient, logger)
+	orgSvc.PlanCatalog.SubscriptionPlansTable = os.Getenv("SUBSCRIPTION_PLANS_TABLE")
 
 	svc := &Service{
 		ctx:    ctx,
@@ -113,7 +115,11 @@ func (svc *Service) listUserOrganizations(userName string, request events.APIGat
 	}
 
 	// Get available subscription plans for reference
-	availablePlans := svc.orgSVC.GetAvailableSubscriptionPlans()
+	availablePlans, err := svc.orgSVC.GetAvailableSubscriptionPlans()
+	if err != nil {
+		svc.logger.Printf("Failed to get subscription plans: %v", err)
+		return svc.errorResponse(http.StatusInternalServerError, "Failed to retrieve subscription plans", err)
+	}
 	planMap := make(map[string]companylib.SubscriptionPlan)
 	for _, plan := range availablePlans {
 		planMap[plan.PlanID] = plan
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -307,6 +308,9 @@ func (svc *Service) Handler(request events.APIGatewayProxyRequest) (events.APIGa
 			}
 			res, err := svc.perfSVC.UpdateKPI(kpiID, patch)
 			if err != nil {
+				if errors.Is(err, companylib.ErrKPIInvalid) {
+					return svc.errorResponse(http.StatusBadRequest, "Invalid KPI", err)
+				}
 				return svc.errorResponse(http.StatusInternalServerError, "Failed to update KPI", err)
 			}
 			return svc.successResponse(http.StatusOK, res)
@@ -353,6 +357,12 @@ func (svc *Service) Handler(request events.APIGatewayProxyRequest) (events.APIGa
 			return svc.errorResponse(http.StatusBadRequest, "Invalid request body", err)
 		}
 		res, err := svc.perfSVC.AddKPIValue(kpiID, input, userName)
+		if errors.Is(err, companylib.ErrKPIValueDuplicate) {
+			return svc.errorResponse(http.StatusConflict, "Failed to add KPI value", err)
+		}
+		if errors.Is(err, companylib.ErrKPIValueInvalid) {
+			return svc.errorResponse(http.StatusBadRequest, "Failed to add KPI value", err)
+		}
 		if err != nil {
 			return svc.errorResponse(http.StatusUnprocessableEntity, "Failed to add KPI value", err)
 		}
@@ -433,17 +443,24 @@ func (svc *Service) Handler(request events.APIGatewayProxyRequest) (events.APIGa
 
 	if len(parts) == 3 && parts[1] == "key-results" && request.HTTPMethod == "PATCH" {
 		keyResultID := parts[2]
+		keyResult, err := svc.perfSVC.GetKeyResult(keyResultID)
+		if err != nil {
+			return svc.errorResponse(http.StatusNotFound, "Key result not found", err)
+		}
+		if err := svc.ensureOrgAdmin(toString(keyResult["organizationId"]), userName); err != nil {
+			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
+		}
 		patch, err := parseBody(request.Body)
 		if err != nil {
 			return svc.errorResponse(http.StatusBadRequest, "Invalid request body", err)
 		}
 		res, err := svc.perfSVC.UpdateKeyResult(keyResultID, patch)
 		if err != nil {
+			if errors.Is(err, companylib.ErrKeyResultInvalid) {
+				return svc.errorResponse(http.StatusBadRequest, "Invalid key result", err)
+			}
 			return svc.errorResponse(http.StatusInternalServerError, "Failed to update key result", err)
 		}
-		if err := svc.ensureOrgAdmin(toString(res["organizationId"]), userName); err != nil {
-			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
-		}
 		return svc.successResponse(http.StatusOK, res)
 	}
 
@@ -502,7 +519,10 @@ func (svc *Service) Handler(request events.APIGatewayProxyRequest) (events.APIGa
 
 	if len(parts) == 4 && parts[1] == "performance-cycles" && parts[3] == "analytics" && request.HTTPMethod == "GET" {
 		cycleID := parts[2]
-		res, err := svc.perfSVC.GetCycleAnalytics(cycleID)
+		res, err := svc.perfSVC.GetCycleAnalytics(cycleID, request.QueryStringParameters["granularity"])
+		if errors.Is(err, companylib.ErrTrendGranularityInvalid) {
+			return svc.errorResponse(http.StatusBadRequest, "granularity must be month or week", err)
+		}
 		if err != nil {
 			return svc.errorResponse(http.StatusInternalServerError, "Failed to get cycle analytics", err)
 		}
@@ -525,7 +545,10 @@ func (svc *Service) Handler(request events.APIGatewayProxyRequest) (events.APIGa
 		if err := svc.ensureOrgAdmin(toString(quarter["organizationId"]), userName); err != nil {
 			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
 		}
-		res, err := svc.perfSVC.GetQuarterAnalytics(quarterID)
+		res, err := svc.perfSVC.GetQuarterAnalytics(quarterID, request.QueryStringParameters["granularity"])
+		if errors.Is(err, companylib.ErrTrendGranularityInvalid) {
+			return svc.errorResponse(http.StatusBadRequest, "granularity must be month or week", err)
+		}
 		if err != nil {
 			return svc.errorResponse(http.StatusInternalServerError, "Failed to get quarter analytics", err)
 		}
@@ -722,25 +745,38 @@ func (svc *Service) Handler(request events.APIGatewayProxyRequest) (events.APIGa
 	if len(parts) == 4 && parts[1] == "ladder-up" && request.HTTPMethod == "PATCH" {
 		ladderID := parts[2]
 		action := parts[3]
-		patch, err := parseBody(request.Body)
+		if action != "approve" && action != "reject" {
+			return svc.errorResponse(http.StatusNotFound, "Route not found", nil)
+		}
+		input, err := parseBody(request.Body)
 		if err != nil {
 			return svc.errorResponse(http.StatusBadRequest, "Invalid request body", err)
 		}
+		ladderUp, err := svc.perfSVC.GetLadderUp(ladderID)
+		if err != nil {
+			return svc.errorResponse(http.StatusNotFound, "Ladder-up not found", err)
+		}
+		if err := svc.ensureOrgAdmin(toString(ladderUp["organizationId"]), userName); err != nil {
+			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
+		}
+		if strings.EqualFold(toString(ladderUp["submittedBy"]), userName) {
+			return svc.errorResponse(http.StatusForbidden, "Access denied", fmt.Errorf("a ladder-up cannot be decided by its submitter"))
+		}
 		var res map[string]interface{}
-		switch action {
-		case "approve":
-			res, err = svc.perfSVC.ApproveLadderUp(ladderID, patch)
-		case "reject":
-			res, err = svc.perfSVC.RejectLadderUp(ladderID, patch)
-		default:
-			return svc.errorResponse(http.StatusNotFound, "Route not found", nil)
+		if action == "approve" {
+			res, err = svc.perfSVC.ApproveLadderUp(ladderID, input, userName)
+		} else {
+			res, err = svc.perfSVC.RejectLadderUp(ladderID, input, userName)
+		}
+		if errors.Is(err, companylib.ErrLadderUpConflict) {
+			return svc.errorResponse(http.StatusConflict, "Failed to update ladder-up item", err)
+		}
+		if errors.Is(err, companylib.ErrLadderUpInvalid) {
+			return svc.errorResponse(http.StatusBadRequest, "Failed to update ladder-up item", err)
 		}
 		if err != nil {
 			return svc.errorResponse(http.StatusInternalServerError, "Failed to update ladder-up item", err)
 		}
-		if err := svc.ensureOrgAdmin(toString(res["organizationId"]), userName); err != nil {
-			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
-		}
 		return svc.successResponse(http.StatusOK, res)
 	}
 
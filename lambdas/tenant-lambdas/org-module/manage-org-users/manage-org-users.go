@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -55,6 +56,7 @@ func main() {
 	// Initialize organization service
 	orgSvc := companylib.CreateOrgServiceV2(ctx, ddbclient, logger, empSvc, emailSvc)
 	orgSvc.OrganizationTable = os.Getenv("ORGANIZATION_TABLE")
+	orgSvc.TeamsTable = os.Getenv("TENANT_TEAMS_TABLE")
 	orgSvc.PromoCodesTable = os.Getenv("PROMO_CODES_TABLE")
 
 	svc := &Service{
@@ -287,6 +289,9 @@ func (svc *Service) addOrgUser(orgId string, requestingUser string, request even
 		err = svc.orgSVC.AddOrgAdmin(orgId, input.UserName, role, requestingUser)
 		if err != nil {
 			svc.logger.Printf("Failed to add org admin: %v", err)
+			if errors.Is(err, companylib.ErrMemberLimitReached) {
+				return svc.errorResponse(http.StatusPaymentRequired, companylib.MemberLimitErrorCode, err)
+			}
 			return svc.errorResponse(http.StatusInternalServerError, fmt.Sprintf("Failed to add admin: %v", err), err)
 		}
 	} else {
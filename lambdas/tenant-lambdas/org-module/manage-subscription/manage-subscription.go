@@ -3,10 +3,12 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
@@ -54,7 +56,11 @@ func main() {
 	// Initialize organization service
 	orgSvc := companylib.CreateOrgServiceV2(ctx, ddbclient, logger, empSvc, emailSvc)
 	orgSvc.OrganizationTable = os.Getenv("ORGANIZATION_TABLE")
+	orgSvc.TeamsTable = os.Getenv("TEAMS_TABLE")
 	orgSvc.PromoCodesTable = os.Getenv("PROMO_CODES_TABLE")
+	orgSvc.PaymentProvider = companylib.CreatePaymentProvider(os.Getenv("PAYMENT_PROVIDER"))
+	orgSvc.PlanCatalog = companylib.CreateSubscriptionPlanService(ctx, ddbclient, logger)
+	orgSvc.PlanCatalog.SubscriptionPlansTable = os.Getenv("SUBSCRIPTION_PLANS_TABLE")
 
 	svc := &Service{
 		ctx:    ctx,
@@ -104,6 +110,12 @@ func (svc *Service) Handler(request events.APIGatewayProxyRequest) (events.APIGa
 
 	switch request.HTTPMethod {
 	case "GET":
+		if strings.HasSuffix(strings.TrimSuffix(request.Path, "/"), "/invoices") {
+			return svc.listInvoices(orgId, employee.EmailID)
+		}
+		if strings.HasSuffix(strings.TrimSuffix(request.Path, "/"), "/usage") {
+			return svc.getUsage(orgId, employee.EmailID)
+		}
 		return svc.getSubscriptionPlans(orgId, employee.EmailID, request)
 	case "PUT":
 		return svc.updateSubscription(orgId, employee.EmailID, request)
@@ -134,17 +146,26 @@ func (svc *Service) getSubscriptionPlans(orgId string, userName string, request
 	}
 
 	// Get available subscription plans
-	availablePlans := svc.orgSVC.GetAvailableSubscriptionPlans()
+	availablePlans, err := svc.orgSVC.GetAvailableSubscriptionPlans()
+	if err != nil {
+		svc.logger.Printf("Failed to get subscription plans: %v", err)
+		return svc.errorResponse(http.StatusInternalServerError, "Failed to retrieve subscription plans", err)
+	}
 
-	// Get current plan details
+	// Get the plan version the organization is billed at, which may be a retired (grandfathered) version
 	var currentPlan *companylib.SubscriptionPlan
-	for _, plan := range availablePlans {
-		if plan.PlanID == organization.CurrentPlanID {
-			currentPlan = &plan
-			break
+	if organization.CurrentPlanID != "" {
+		currentPlan, err = svc.orgSVC.GetOrgSubscriptionPlan(*organization)
+		if err != nil {
+			svc.logger.Printf("Failed to get current plan %s: %v", organization.CurrentPlanID, err)
 		}
 	}
 
+	billingCurrency := organization.BillingCurrency
+	if billingCurrency == "" {
+		billingCurrency = companylib.DefaultBillingCurrency
+	}
+
 	// Return the subscription information
 	body, err := json.Marshal(map[string]interface{}{
 		"currentSubscription": map[string]interface{}{
@@ -156,17 +177,28 @@ func (svc *Service) getSubscriptionPlans(orgId string, userName string, request
 					return "Unknown"
 				}
 			}(),
-			"billingMode":      organization.BillingMode,
-			"subscriptionType": organization.SubscriptionType,
-			"billingPlan":      organization.BillingPlan,
-			"billingStatus":    organization.OrgBillingStatus,
-			"maxTeams":         organization.MaxTeamsAllowed,
-			"currentTeams":     organization.CurrentTeamCount,
-			"maxMembers":       organization.MaxMembersAllowed,
-			"nextBillingDate":  organization.NextBillingDate,
-			"trialEndDate":     organization.TrialEndDate,
-			"appliedPromoCode": organization.AppliedPromoCode,
-			"promoDiscount":    organization.PromoDiscountPercent,
+			"planVersion": func() int {
+				if currentPlan != nil {
+					return currentPlan.Version
+				}
+				return 0
+			}(),
+			"grandfathered":        currentPlan != nil && currentPlan.Status == companylib.SubscriptionPlanStatusRetired,
+			"billingCurrency":      billingCurrency,
+			"billingMode":          organization.BillingMode,
+			"subscriptionType":     organization.SubscriptionType,
+			"billingPlan":          organization.BillingPlan,
+			"billingStatus":        organization.OrgBillingStatus,
+			"maxTeams":             organization.MaxTeamsAllowed,
+			"currentTeams":         organization.CurrentTeamCount,
+			"maxMembers":           organization.MaxMembersAllowed,
+			"nextBillingDate":      organization.NextBillingDate,
+			"trialEndDate":         organization.TrialEndDate,
+			"appliedPromoCode":     organization.AppliedPromoCode,
+			"promoDiscount":        organization.PromoDiscountPercent,
+			"creditBalance":        organization.CreditBalance,
+			"lastPaymentDate":      organization.LastPaymentDate,
+			"outstandingInvoiceId": organization.OutstandingInvoiceId,
 		},
 		"availablePlans": availablePlans,
 	})
@@ -205,12 +237,21 @@ func (svc *Service) updateSubscription(orgId string, userName string, request ev
 	input.OrganizationId = orgId
 
 	// Update the subscription
-	err := svc.orgSVC.UpdateSubscription(input, userName)
+	invoice, err := svc.orgSVC.UpdateSubscription(input, userName)
 	if err != nil {
 		svc.logger.Printf("Failed to update subscription: %v", err)
 		if err.Error() == fmt.Sprintf("user %s is not an admin of organization %s", userName, orgId) {
 			return svc.errorResponse(http.StatusForbidden, "Access denied: Not an organization admin", err)
 		}
+		if errors.Is(err, companylib.ErrSubscriptionChanged) {
+			return svc.errorResponse(http.StatusConflict, "Subscription was changed by another request, please retry", err)
+		}
+		if errors.Is(err, companylib.ErrInvoiceOutstanding) {
+			return svc.errorResponse(http.StatusConflict, "An earlier invoice must be paid before the plan can change", err)
+		}
+		if errors.Is(err, companylib.ErrSubscriptionPlanNotFound) || strings.Contains(err.Error(), "invalid") || strings.Contains(err.Error(), "already subscribed") {
+			return svc.errorResponse(http.StatusBadRequest, "Invalid subscription change", err)
+		}
 		return svc.errorResponse(http.StatusInternalServerError, "Failed to update subscription", err)
 	}
 
@@ -234,6 +275,8 @@ func (svc *Service) updateSubscription(orgId string, userName string, request ev
 		"subscription": map[string]interface{}{
 			"planId":           organization.CurrentPlanID,
 			"planName":         plan.PlanName,
+			"planVersion":      organization.CurrentPlanVersion,
+			"billingCurrency":  organization.BillingCurrency,
 			"billingMode":      organization.BillingMode,
 			"subscriptionType": organization.SubscriptionType,
 			"billingPlan":      organization.BillingPlan,
@@ -241,7 +284,9 @@ func (svc *Service) updateSubscription(orgId string, userName string, request ev
 			"maxTeams":         organization.MaxTeamsAllowed,
 			"maxMembers":       organization.MaxMembersAllowed,
 			"nextBillingDate":  organization.NextBillingDate,
+			"creditBalance":    organization.CreditBalance,
 		},
+		"invoice": invoice,
 	})
 	if err != nil {
 		svc.logger.Printf("Failed to marshal response: %v", err)
@@ -255,6 +300,75 @@ func (svc *Service) updateSubscription(orgId string, userName string, request ev
 	}, nil
 }
 
+// listInvoices returns the organization's invoices, newest first
+func (svc *Service) listInvoices(orgId string, userName string) (events.APIGatewayProxyResponse, error) {
+	svc.logger.Printf("Listing invoices for organization %s, user: %s", orgId, userName)
+
+	// Verify user is admin of the organization
+	isAdmin, err := svc.orgSVC.IsOrgAdmin(orgId, userName)
+	if err != nil {
+		svc.logger.Printf("Failed to check admin status: %v", err)
+		return svc.errorResponse(http.StatusInternalServerError, "Failed to verify permissions", err)
+	}
+	if !isAdmin {
+		return svc.errorResponse(http.StatusForbidden, "Access denied: Not an organization admin", nil)
+	}
+
+	invoices, err := svc.orgSVC.ListInvoices(orgId)
+	if err != nil {
+		svc.logger.Printf("Failed to list invoices: %v", err)
+		return svc.errorResponse(http.StatusInternalServerError, "Failed to list invoices", err)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"invoices": invoices,
+		"count":    len(invoices),
+	})
+	if err != nil {
+		svc.logger.Printf("Failed to marshal response: %v", err)
+		return svc.errorResponse(http.StatusInternalServerError, "Failed to create response", err)
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers:    RESP_HEADERS,
+		Body:       string(body),
+	}, nil
+}
+
+// getUsage returns seats and teams used against the organization's plan limits for the billing page
+func (svc *Service) getUsage(orgId string, userName string) (events.APIGatewayProxyResponse, error) {
+	svc.logger.Printf("Getting plan usage for organization %s, user: %s", orgId, userName)
+
+	// Verify user is admin of the organization
+	isAdmin, err := svc.orgSVC.IsOrgAdmin(orgId, userName)
+	if err != nil {
+		svc.logger.Printf("Failed to check admin status: %v", err)
+		return svc.errorResponse(http.StatusInternalServerError, "Failed to verify permissions", err)
+	}
+	if !isAdmin {
+		return svc.errorResponse(http.StatusForbidden, "Access denied: Not an organization admin", nil)
+	}
+
+	usage, err := svc.orgSVC.GetOrgUsage(orgId)
+	if err != nil {
+		svc.logger.Printf("Failed to get plan usage: %v", err)
+		return svc.errorResponse(http.StatusInternalServerError, "Failed to get plan usage", err)
+	}
+
+	body, err := json.Marshal(usage)
+	if err != nil {
+		svc.logger.Printf("Failed to marshal response: %v", err)
+		return svc.errorResponse(http.StatusInternalServerError, "Failed to create response", err)
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers:    RESP_HEADERS,
+		Body:       string(body),
+	}, nil
+}
+
 // getCognitoIdFromRequest extracts Cognito ID from Cognito authorizer context
 func (svc *Service) getCognitoIdFromRequest(request events.APIGatewayProxyRequest) (string, error) {
 	// Try to get from authorizer context first
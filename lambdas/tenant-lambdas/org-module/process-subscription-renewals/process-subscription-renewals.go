@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-xray-sdk-go/instrumentation/awsv2"
+	"github.com/aws/aws-xray-sdk-go/xray"
+
+	companylib "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/company-lib"
+)
+
+type Service struct {
+	ctx    context.Context
+	logger *log.Logger
+	orgSVC *companylib.OrgServiceV2
+}
+
+// RenewalRunSummary is returned by the scheduled run and written to the logs
+type RenewalRunSummary struct {
+	RunAt     string `json:"runAt"`
+	Due       int    `json:"due"`
+	Invoiced  int    `json:"invoiced"`
+	Paid      int    `json:"paid"`
+	Pending   int    `json:"pending"`
+	Overdue   int    `json:"overdue"`
+	Suspended int    `json:"suspended"`
+	Skipped   int    `json:"skipped"`
+	Failed    int    `json:"failed"`
+}
+
+func main() {
+	ctx, root := xray.BeginSegment(context.TODO(), "process-subscription-renewals")
+	defer root.Close(nil)
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		log.Fatalf("Cannot load config: %v\n", err)
+	}
+
+	awsv2.AWSV2Instrumentor(&cfg.APIOptions)
+
+	logger := log.New(os.Stdout, "", log.LstdFlags)
+	ddbclient := dynamodb.NewFromConfig(cfg)
+
+	// Initialize organization service
+	orgSvc := companylib.CreateOrgServiceV2(ctx, ddbclient, logger, nil, nil)
+	orgSvc.OrganizationTable = os.Getenv("ORGANIZATION_TABLE")
+	orgSvc.PaymentProvider = companylib.CreatePaymentProvider(os.Getenv("PAYMENT_PROVIDER"))
+	orgSvc.PlanCatalog = companylib.CreateSubscriptionPlanService(ctx, ddbclient, logger)
+	orgSvc.PlanCatalog.SubscriptionPlansTable = os.Getenv("SUBSCRIPTION_PLANS_TABLE")
+
+	svc := &Service{
+		ctx:    ctx,
+		logger: logger,
+		orgSVC: orgSvc,
+	}
+
+	lambda.Start(svc.Handler)
+}
+
+// Handler runs on the EventBridge schedule. It raises renewal invoices for every organization whose
+// NextBillingDate has passed, retries outstanding invoices and moves unpaid organizations to
+// OVERDUE and then SUSPENDED. One organization failing does not stop the run.
+func (svc *Service) Handler(ctx context.Context, event events.CloudWatchEvent) (RenewalRunSummary, error) {
+	now := time.Now().UTC()
+	summary := RenewalRunSummary{RunAt: now.Format(time.RFC3339)}
+
+	organizations, err := svc.orgSVC.ListOrganizationsDueForBilling(now)
+	if err != nil {
+		svc.logger.Printf("Failed to list organizations due for billing: %v", err)
+		return summary, err
+	}
+	summary.Due = len(organizations)
+
+	for _, org := range organizations {
+		result, err := svc.orgSVC.ProcessRenewal(org, now)
+		if err != nil {
+			svc.logger.Printf("Failed to process renewal for organization %s: %v", org.OrganizationId, err)
+			summary.Failed++
+			continue
+		}
+
+		if result.Skipped {
+			summary.Skipped++
+			continue
+		}
+
+		if result.Invoice != nil && result.Invoice.InvoiceType == companylib.InvoiceTypeRenewal {
+			summary.Invoiced++
+		}
+
+		switch result.PaymentStatus {
+		case companylib.PaymentStatusSucceeded:
+			summary.Paid++
+		case companylib.PaymentStatusPending:
+			summary.Pending++
+		}
+
+		switch result.BillingStatus {
+		case companylib.OrgBillingStatusOverdue:
+			summary.Overdue++
+		case companylib.OrgBillingStatusSuspended:
+			summary.Suspended++
+		}
+	}
+
+	svc.logger.Printf("Subscription renewal run complete: %+v", summary)
+	return summary, nil
+}
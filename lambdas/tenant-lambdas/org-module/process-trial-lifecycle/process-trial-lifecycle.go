@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/ses"
+	"github.com/aws/aws-xray-sdk-go/instrumentation/awsv2"
+	"github.com/aws/aws-xray-sdk-go/xray"
+
+	companylib "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/company-lib"
+)
+
+type Service struct {
+	ctx          context.Context
+	logger       *log.Logger
+	orgSVC       *companylib.OrgServiceV2
+	lifecycleCfg companylib.OrgLifecycleConfig
+}
+
+// LifecycleRunSummary is returned by the scheduled run and written to the logs
+type LifecycleRunSummary struct {
+	RunAt            string `json:"runAt"`
+	TrialOrgs        int    `json:"trialOrgs"`
+	OverdueOrgs      int    `json:"overdueOrgs"`
+	TrialReminders   int    `json:"trialReminders"`
+	DunningReminders int    `json:"dunningReminders"`
+	Suspended        int    `json:"suspended"`
+	Failed           int    `json:"failed"`
+}
+
+func main() {
+	ctx, root := xray.BeginSegment(context.TODO(), "process-trial-lifecycle")
+	defer root.Close(nil)
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		log.Fatalf("Cannot load config: %v\n", err)
+	}
+
+	awsv2.AWSV2Instrumentor(&cfg.APIOptions)
+
+	logger := log.New(os.Stdout, "", log.LstdFlags)
+	ddbclient := dynamodb.NewFromConfig(cfg)
+	sesClient := ses.NewFromConfig(cfg)
+
+	// Email service
+	emailSvc := companylib.CreateEmailService(ctx, sesClient, logger)
+
+	// Initialize organization service
+	orgSvc := companylib.CreateOrgServiceV2(ctx, ddbclient, logger, nil, emailSvc)
+	orgSvc.OrganizationTable = os.Getenv("ORGANIZATION_TABLE")
+
+	reminderDays, err := companylib.ParseReminderDays(os.Getenv("REMINDER_DAYS"))
+	if err != nil {
+		log.Fatalf("Invalid REMINDER_DAYS: %v\n", err)
+	}
+
+	gracePeriodDays := companylib.DefaultTrialGracePeriodDays
+	if value := os.Getenv("TRIAL_GRACE_PERIOD_DAYS"); value != "" {
+		gracePeriodDays, err = strconv.Atoi(value)
+		if err != nil || gracePeriodDays < 0 {
+			log.Fatalf("Invalid TRIAL_GRACE_PERIOD_DAYS: %s\n", value)
+		}
+	}
+
+	baseURL := os.Getenv("APP_BASE_URL")
+	if baseURL == "" {
+		baseURL = "https://app.gomovo.com"
+	}
+
+	svc := &Service{
+		ctx:    ctx,
+		logger: logger,
+		orgSVC: orgSvc,
+		lifecycleCfg: companylib.OrgLifecycleConfig{
+			ReminderDays:         reminderDays,
+			TrialGracePeriodDays: gracePeriodDays,
+			BillingURL:           strings.TrimSuffix(baseURL, "/") + "/settings/billing",
+		},
+	}
+
+	lambda.Start(svc.Handler)
+}
+
+// Handler runs on the EventBridge schedule. TRIAL organizations get reminder emails as the trial
+// end approaches and are suspended once the grace period passes; OVERDUE organizations get dunning
+// reminders before their outstanding invoice causes a suspension.
+func (svc *Service) Handler(ctx context.Context, event events.CloudWatchEvent) (LifecycleRunSummary, error) {
+	now := time.Now().UTC()
+	summary := LifecycleRunSummary{RunAt: now.Format(time.RFC3339)}
+
+	trialOrgs, err := svc.orgSVC.ListOrganizationsByBillingStatus(companylib.OrgBillingStatusTrial)
+	if err != nil {
+		return summary, err
+	}
+	summary.TrialOrgs = len(trialOrgs)
+
+	for _, org := range trialOrgs {
+		result, err := svc.orgSVC.ProcessTrialLifecycle(org, now, svc.lifecycleCfg)
+		if err != nil {
+			svc.logger.Printf("Failed to process trial for organization %s: %v", org.OrganizationId, err)
+			summary.Failed++
+			continue
+		}
+
+		switch result.Action {
+		case companylib.LifecycleActionReminderSent:
+			summary.TrialReminders++
+		case companylib.LifecycleActionSuspended:
+			summary.Suspended++
+		}
+	}
+
+	overdueOrgs, err := svc.orgSVC.ListOrganizationsByBillingStatus(companylib.OrgBillingStatusOverdue)
+	if err != nil {
+		return summary, err
+	}
+	summary.OverdueOrgs = len(overdueOrgs)
+
+	for _, org := range overdueOrgs {
+		result, err := svc.orgSVC.ProcessDunning(org, now, svc.lifecycleCfg)
+		if err != nil {
+			svc.logger.Printf("Failed to process dunning for organization %s: %v", org.OrganizationId, err)
+			summary.Failed++
+			continue
+		}
+
+		if result.Action == companylib.LifecycleActionReminderSent {
+			summary.DunningReminders++
+		}
+	}
+
+	svc.logger.Printf("Trial lifecycle run complete: %+v", summary)
+	return summary, nil
+}
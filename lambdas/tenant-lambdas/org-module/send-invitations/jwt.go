@@ -8,8 +8,9 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// generateInvitationToken creates a JWT token containing all invitation data
-func (svc *Service) GenerateInvitationToken(email, organizationId, organizationName, teamId, role, invitedBy string) (string, error) {
+// GenerateInvitationToken creates a JWT token containing all invitation data. The invitation ID is
+// carried as the token ID (jti) so each token can be accepted once and replaced on resend.
+func (svc *Service) GenerateInvitationToken(invitationId, email, organizationId, organizationName, teamId, role, invitedBy string) (string, error) {
 	// Get JWT secret from environment
 	secret := os.Getenv("INVITATION_TOKEN_SECRET")
 	if secret == "" {
@@ -26,6 +27,7 @@ func (svc *Service) GenerateInvitationToken(email, organizationId, organizationN
 		Role:             role,
 		InvitedBy:        invitedBy,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        invitationId,
 			IssuedAt:  jwt.NewNumericDate(now),
 			ExpiresAt: jwt.NewNumericDate(now.Add(7 * 24 * time.Hour)), // 7 days expiration
 		},
@@ -3,9 +3,11 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 	"time"
@@ -63,6 +65,7 @@ func main() {
 	// Initialize organization service
 	orgSvc := companylib.CreateOrgServiceV2(ctx, ddbclient, logger, empSvc, emailSvc)
 	orgSvc.OrganizationTable = os.Getenv("ORGANIZATION_TABLE")
+	orgSvc.TeamsTable = os.Getenv("TENANT_TEAMS_TABLE")
 	orgSvc.PromoCodesTable = os.Getenv("PROMO_CODES_TABLE")
 
 	svc := &Service{
@@ -104,9 +107,18 @@ func (svc *Service) Handler(request events.APIGatewayProxyRequest) (events.APIGa
 		return svc.errorResponse(http.StatusUnauthorized, "User not found", err)
 	}
 
+	path := strings.TrimSuffix(request.Path, "/")
+
 	switch request.HTTPMethod {
+	case "GET":
+		return svc.listPendingInvitations(employee)
 	case "POST":
+		if strings.HasSuffix(path, "/resend") {
+			return svc.resendInvitation(employee, request)
+		}
 		return svc.sendInvitations(employee, request)
+	case "DELETE":
+		return svc.revokeInvitation(employee, request)
 	default:
 		return svc.errorResponse(http.StatusMethodNotAllowed, "Method not allowed", nil)
 	}
@@ -162,20 +174,15 @@ func (svc *Service) sendInvitations(employee companylib.EmployeeDynamodbData, re
 	org, err := svc.orgSVC.GetAdminOrganization(employee.EmailID)
 	if err != nil {
 		svc.logger.Printf("Failed to get organization details: %v", err)
+		return svc.errorResponse(http.StatusForbidden, "Only organization admins can send invitations", err)
 	}
 	svc.logger.Printf("User is part of organization: %s (%s)", org.OrgName, org.OrganizationId)
 	organizationId := org.OrganizationId
-	organizationName := org.OrgName
-
-	// Get base URL for invitation links
-	baseURL := os.Getenv("APP_BASE_URL")
-	if baseURL == "" {
-		baseURL = "https://app.gomovo.com"
-	}
 
-	// Process each invitee: generate token, send email, create DDB record
+	// Process each invitee: claim a seat and record the invitation, then email the token
 	successCount := 0
 	failedCount := 0
+	limitReachedCount := 0
 	results := make([]companylib.InvitationEmailResult, 0, len(req.Invitees))
 
 	for _, invitee := range req.Invitees {
@@ -183,85 +190,62 @@ func (svc *Service) sendInvitations(employee companylib.EmployeeDynamodbData, re
 			Email: invitee.Email,
 		}
 
-		// Fetch team name if teamId is provided
-		var teamName string
-		if invitee.TeamId != "" {
-			fetchedTeamName, err := svc.getTeamName(invitee.TeamId)
-			if err != nil {
-				svc.logger.Printf("Warning: Failed to fetch team name for %s: %v", invitee.TeamId, err)
-			} else {
-				teamName = fetchedTeamName
-			}
-		}
-
-		// Generate invitation link with JWT token for this specific invitee
-		var invitationLink string
-		if req.InvitationLink != "" {
-			// Use custom invitation link if provided
-			invitationLink = req.InvitationLink
-		} else {
-			// Generate JWT token with invitation data
-			token, err := svc.GenerateInvitationToken(invitee.Email, organizationId, organizationName, invitee.TeamId, invitee.Role, employee.EmailID)
-			if err != nil {
-				svc.logger.Printf("Warning: Failed to generate invitation token for %s: %v", invitee.Email, err)
-				invitationLink = fmt.Sprintf("%s/accept-invitation", baseURL)
-			} else {
-				invitationLink = fmt.Sprintf("%s/accept-invitation?token=%s", baseURL, token)
+		// Invitees take a plan seat, so don't invite anyone the plan has no room for
+		seats, err := svc.orgSVC.ReserveSeats(organizationId, []string{invitee.Email})
+		if err == nil {
+			// Each invitation gets a new ID which its token must carry to be accepted
+			invitationId := companylib.NewInvitationId()
+			err = svc.createInvitedEmployee(invitee.Email, invitee.Role, invitee.TeamId, organizationId, employee.UserName, invitationId, seats)
+			if err == nil {
+				if err = svc.sendInvitationEmail(org, invitee.Email, invitee.Role, invitee.TeamId, invitationId, inviterName, employee.EmailID, req.InvitationLink, req.CustomMessage); err != nil {
+					// The invitation is recorded, so it can be resent once the email problem is fixed
+					err = fmt.Errorf("invitation saved but the email could not be sent, resend it later: %w", err)
+				}
 			}
 		}
 
-		// Prepare invitation input for this specific invitee
-		invitationInput := companylib.InvitationEmailInput{
-			EmailAddresses:   []string{invitee.Email},
-			OrganizationName: organizationName,
-			TeamName:         teamName,
-			InviterName:      inviterName,
-			InvitationLink:   invitationLink,
-			CustomMessage:    req.CustomMessage,
-		}
-
-		// Send invitation email
-		emailResults, err := svc.emailSVC.SendInvitationEmails(invitationInput)
-		if err != nil || len(emailResults) == 0 || !emailResults[0].Success {
-			svc.logger.Printf("Failed to send invitation to %s: %v", invitee.Email, err)
+		if err != nil {
+			svc.logger.Printf("Failed to invite %s: %v", invitee.Email, err)
 			result.Success = false
-			if err != nil {
-				result.Error = err.Error()
-			} else if len(emailResults) > 0 {
-				result.Error = emailResults[0].Error
+			result.Error = err.Error()
+			if errors.Is(err, companylib.ErrMemberLimitReached) {
+				result.Error = fmt.Sprintf("%s: %v", companylib.MemberLimitErrorCode, err)
+				limitReachedCount++
 			}
 			failedCount++
 		} else {
 			result.Success = true
 			successCount++
-
-			// Create employee record with INVITED status
-			if err := svc.createInvitedEmployee(invitee.Email, invitee.Role, invitee.TeamId, organizationId, employee.UserName); err != nil {
-				svc.logger.Printf("Warning: Failed to create employee record for %s: %v", invitee.Email, err)
-				// Don't fail the invitation if employee record creation fails
-			}
 		}
 
 		results = append(results, result)
 	}
 
-	svc.logger.Printf("Invitation results - Success: %d, Failed: %d", successCount, failedCount)
+	svc.logger.Printf("Invitation results - Success: %d, Failed: %d, Member limit reached: %d", successCount, failedCount, limitReachedCount)
 
 	// Return results
-	body, err := json.Marshal(map[string]interface{}{
+	response := map[string]interface{}{
 		"message":      fmt.Sprintf("Sent %d invitations successfully, %d failed", successCount, failedCount),
 		"totalSent":    len(req.Invitees),
 		"successCount": successCount,
 		"failedCount":  failedCount,
 		"results":      results,
-	})
+	}
+	if limitReachedCount > 0 {
+		response["code"] = companylib.MemberLimitErrorCode
+		response["limitReachedCount"] = limitReachedCount
+	}
+	body, err := json.Marshal(response)
 	if err != nil {
 		svc.logger.Printf("Failed to marshal response: %v", err)
 		return svc.errorResponse(http.StatusInternalServerError, "Failed to create response", err)
 	}
 
 	statusCode := http.StatusOK
-	if failedCount > 0 && successCount == 0 {
+	if limitReachedCount > 0 && limitReachedCount == failedCount && successCount == 0 {
+		// Every invitation was blocked by the plan's member limit
+		statusCode = http.StatusPaymentRequired
+	} else if failedCount > 0 && successCount == 0 {
 		// All failed
 		statusCode = http.StatusInternalServerError
 	} else if failedCount > 0 {
@@ -276,6 +260,187 @@ func (svc *Service) sendInvitations(employee companylib.EmployeeDynamodbData, re
 	}, nil
 }
 
+// sendInvitationEmail emails an invitation link carrying a signed token for the invitation
+func (svc *Service) sendInvitationEmail(org *companylib.Organization, email, role, teamId, invitationId, inviterName, inviterEmail, customLink, customMessage string) error {
+	// Fetch team name if teamId is provided
+	var teamName string
+	if teamId != "" {
+		fetchedTeamName, err := svc.getTeamName(teamId)
+		if err != nil {
+			svc.logger.Printf("Warning: Failed to fetch team name for %s: %v", teamId, err)
+		} else {
+			teamName = fetchedTeamName
+		}
+	}
+
+	// Generate JWT token with invitation data; invitations cannot be accepted without it
+	token, err := svc.GenerateInvitationToken(invitationId, email, org.OrganizationId, org.OrgName, teamId, role, inviterEmail)
+	if err != nil {
+		return fmt.Errorf("failed to generate invitation token: %w", err)
+	}
+
+	emailResults, err := svc.emailSVC.SendInvitationEmails(companylib.InvitationEmailInput{
+		EmailAddresses:   []string{email},
+		OrganizationName: org.OrgName,
+		TeamName:         teamName,
+		InviterName:      inviterName,
+		InvitationLink:   invitationLink(customLink, token),
+		CustomMessage:    customMessage,
+	})
+	if err != nil {
+		return err
+	}
+	if len(emailResults) == 0 {
+		return fmt.Errorf("no email result returned for %s", email)
+	}
+	if !emailResults[0].Success {
+		return errors.New(emailResults[0].Error)
+	}
+
+	return nil
+}
+
+// invitationLink adds the token to the custom invitation link, or to APP_BASE_URL/accept-invitation
+func invitationLink(customLink, token string) string {
+	link := customLink
+	if link == "" {
+		baseURL := os.Getenv("APP_BASE_URL")
+		if baseURL == "" {
+			baseURL = "https://app.gomovo.com"
+		}
+		link = fmt.Sprintf("%s/accept-invitation", baseURL)
+	}
+
+	separator := "?"
+	if strings.Contains(link, "?") {
+		separator = "&"
+	}
+	return fmt.Sprintf("%s%stoken=%s", link, separator, url.QueryEscape(token))
+}
+
+// listPendingInvitations returns the invitations of the caller's organization that are still pending
+func (svc *Service) listPendingInvitations(employee companylib.EmployeeDynamodbData) (events.APIGatewayProxyResponse, error) {
+	org, err := svc.orgSVC.GetAdminOrganization(employee.EmailID)
+	if err != nil {
+		return svc.errorResponse(http.StatusForbidden, "Only organization admins can manage invitations", err)
+	}
+
+	invitations, err := svc.orgSVC.GetPendingInvitations(org.OrganizationId)
+	if err != nil {
+		svc.logger.Printf("Failed to list pending invitations: %v", err)
+		return svc.errorResponse(http.StatusInternalServerError, "Failed to list invitations", err)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"invitations": invitations,
+		"count":       len(invitations),
+	})
+	if err != nil {
+		return svc.errorResponse(http.StatusInternalServerError, "Failed to create response", err)
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers:    RESP_HEADERS,
+		Body:       string(body),
+	}, nil
+}
+
+// InvitationActionRequest identifies a pending invitation to resend or revoke
+type InvitationActionRequest struct {
+	Email          string `json:"email"`
+	InvitationLink string `json:"invitationLink,omitempty"`
+	CustomMessage  string `json:"customMessage,omitempty"`
+}
+
+// resendInvitation issues a new token for a pending invitation and emails it. Earlier tokens stop working.
+func (svc *Service) resendInvitation(employee companylib.EmployeeDynamodbData, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var req InvitationActionRequest
+	if err := json.Unmarshal([]byte(request.Body), &req); err != nil || req.Email == "" {
+		return svc.errorResponse(http.StatusBadRequest, "email is required", err)
+	}
+
+	org, err := svc.orgSVC.GetAdminOrganization(employee.EmailID)
+	if err != nil {
+		return svc.errorResponse(http.StatusForbidden, "Only organization admins can manage invitations", err)
+	}
+
+	invitation, err := svc.orgSVC.ReissueInvitation(org.OrganizationId, req.Email, employee.EmailID)
+	if err != nil {
+		return svc.invitationErrorResponse("Failed to resend invitation", err)
+	}
+
+	inviterName := employee.EmailID
+	if employee.DisplayName != "" {
+		inviterName = employee.DisplayName
+	}
+	err = svc.sendInvitationEmail(org, invitation.UserName, string(invitation.Role), invitation.InvitationTeamId, invitation.InvitationId, inviterName, employee.EmailID, req.InvitationLink, req.CustomMessage)
+	if err != nil {
+		svc.logger.Printf("Failed to resend invitation to %s: %v", req.Email, err)
+		return svc.errorResponse(http.StatusInternalServerError, "Failed to send invitation email", err)
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"message":    "Invitation resent",
+		"invitation": invitation,
+	})
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers:    RESP_HEADERS,
+		Body:       string(body),
+	}, nil
+}
+
+// revokeInvitation cancels a pending invitation (?email=... or {"email": ...})
+func (svc *Service) revokeInvitation(employee companylib.EmployeeDynamodbData, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	email := request.QueryStringParameters["email"]
+	if email == "" && request.Body != "" {
+		var req InvitationActionRequest
+		if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+			return svc.errorResponse(http.StatusBadRequest, "Invalid request body", err)
+		}
+		email = req.Email
+	}
+	if email == "" {
+		return svc.errorResponse(http.StatusBadRequest, "email is required", nil)
+	}
+
+	org, err := svc.orgSVC.GetAdminOrganization(employee.EmailID)
+	if err != nil {
+		return svc.errorResponse(http.StatusForbidden, "Only organization admins can manage invitations", err)
+	}
+
+	if err := svc.orgSVC.RevokeInvitation(org.OrganizationId, email, employee.EmailID); err != nil {
+		return svc.invitationErrorResponse("Failed to revoke invitation", err)
+	}
+
+	body, _ := json.Marshal(map[string]string{
+		"message": "Invitation revoked",
+		"email":   email,
+	})
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers:    RESP_HEADERS,
+		Body:       string(body),
+	}, nil
+}
+
+// invitationErrorResponse maps invitation errors to HTTP status codes
+func (svc *Service) invitationErrorResponse(message string, err error) (events.APIGatewayProxyResponse, error) {
+	svc.logger.Printf("%s: %v", message, err)
+
+	switch {
+	case errors.Is(err, companylib.ErrInvitationNotFound):
+		return svc.errorResponse(http.StatusNotFound, "Invitation not found", err)
+	case errors.Is(err, companylib.ErrInvitationNotRevocable):
+		return svc.errorResponse(http.StatusConflict, "Invitation is no longer pending", err)
+	case strings.Contains(err.Error(), "not an admin"):
+		return svc.errorResponse(http.StatusForbidden, "Access denied: Not an organization admin", err)
+	default:
+		return svc.errorResponse(http.StatusInternalServerError, message, err)
+	}
+}
+
 // getCognitoIdFromRequest extracts Cognito ID from Cognito authorizer context
 func (svc *Service) getCognitoIdFromRequest(request events.APIGatewayProxyRequest) (string, error) {
 	// Try to get from authorizer context first
@@ -379,30 +544,25 @@ func (svc *Service) checkUserInOrganization(organizationId, email string) (*OrgU
 	return userData, nil
 }
 
-// createInvitedEmployee creates an employee record with INVITED status and optionally adds to team
-func (svc *Service) createInvitedEmployee(email, role, teamId, organizationId, invitedBy string) error {
-	// Check if employee already exists
-	emp, err := svc.empSVC.GetEmployeeDataByUserName(email)
-	if err == nil && emp.CognitoId != "" {
-		svc.logger.Printf("Employee %s already exists, skipping creation", email)
-		return nil
-	}
+// createInvitedEmployee records a pending invitation: an org user with INVITED status that holds the
+// invitee's seat and the ID of the only token that can accept it. The invitee joins the team named
+// in the invitation when they accept. Inviting someone who is already pending replaces their
+// previous invitation.
+func (svc *Service) createInvitedEmployee(email, role, teamId, organizationId, invitedBy, invitationId string, seats *companylib.SeatReservation) error {
+	now := time.Now().UTC().Format(time.RFC3339)
 
-	// Create employee record with INVITED status
+	// Check if user already exists in organization
+	existingUser, err := svc.checkUserInOrganization(organizationId, email)
+	if err != nil {
+		existingUser = nil
+	}
+	if existingUser != nil && existingUser.IsActive && existingUser.Status != companylib.OrgUserStatusInvited {
+		return fmt.Errorf("user %s is already a member of the organization, add them to teams from the team page", email)
+	}
 
 	// Build transaction items
 	transactItems := []types.TransactWriteItem{}
 
-	// Check if user already exists in organization and is active
-	userExistsInOrg := false
-	if organizationId != "" {
-		existingUser, err := svc.checkUserInOrganization(organizationId, email)
-		if err == nil && existingUser != nil && existingUser.IsActive {
-			svc.logger.Printf("User %s already exists and is active in organization %s", email, organizationId)
-			userExistsInOrg = true
-		}
-	}
-
 	// 1. Add to Employee table - disabling this as the new employee is record is created only when user is accepting the invitiation.
 	//
 	// employeeData := companylib.EmployeeDynamodbData{
@@ -430,50 +590,77 @@ func (svc *Service) createInvitedEmployee(email, role, teamId, organizationId, i
 	// transactItems = append(transactItems, putItemEmployeeTable)
 
 	// 2. Add to Organization table (ORG#orgId -> USER#email mapping)
-	// Only add if user doesn't already exist and is active in the organization
-	if organizationId != "" && !userExistsInOrg {
-		putItemOrgTable := types.TransactWriteItem{
-			Put: &types.Put{
+	if existingUser != nil && existingUser.Status == companylib.OrgUserStatusInvited {
+		// Re-inviting a pending invitee: the new token replaces the old one
+		updateExpression := "SET #role = :role, InvitationId = :invitationId, InvitedBy = :invitedBy, InvitedAt = :now, UpdatedAt = :now"
+		values := map[string]types.AttributeValue{
+			":role":         &types.AttributeValueMemberS{Value: role},
+			":invitationId": &types.AttributeValueMemberS{Value: invitationId},
+			":invitedBy":    &types.AttributeValueMemberS{Value: invitedBy},
+			":now":          &types.AttributeValueMemberS{Value: now},
+			":invited":      &types.AttributeValueMemberS{Value: companylib.OrgUserStatusInvited},
+		}
+		if teamId != "" {
+			updateExpression += ", InvitationTeamId = :teamId"
+			values[":teamId"] = &types.AttributeValueMemberS{Value: teamId}
+		} else {
+			updateExpression += " REMOVE InvitationTeamId"
+		}
+
+		transactItems = append(transactItems, types.TransactWriteItem{
+			Update: &types.Update{
 				TableName: aws.String(svc.orgSVC.OrganizationTable),
-				Item: map[string]types.AttributeValue{
-					"PK":             &types.AttributeValueMemberS{Value: fmt.Sprintf("%s", organizationId)},
-					"SK":             &types.AttributeValueMemberS{Value: fmt.Sprintf("USER#%s", email)},
-					"GSI1PK":         &types.AttributeValueMemberS{Value: fmt.Sprintf("USER#%s", email)},
-					"GSI1SK":         &types.AttributeValueMemberS{Value: fmt.Sprintf("%s", organizationId)},
-					"OrganizationId": &types.AttributeValueMemberS{Value: organizationId},
-					"UserName":       &types.AttributeValueMemberS{Value: email},
-					"Role":           &types.AttributeValueMemberS{Value: role},
-					"Status":         &types.AttributeValueMemberS{Value: "INVITED"},
-					"IsActive":       &types.AttributeValueMemberBOOL{Value: true}, // Active in organization by default unless they are removed later.
-					"AddedAt":        &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)},
-					"UpdatedAt":      &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)},
+				Key: map[string]types.AttributeValue{
+					"PK": &types.AttributeValueMemberS{Value: organizationId},
+					"SK": &types.AttributeValueMemberS{Value: fmt.Sprintf("USER#%s", email)},
+				},
+				UpdateExpression:    aws.String(updateExpression),
+				ConditionExpression: aws.String("#status = :invited"),
+				ExpressionAttributeNames: map[string]string{
+					"#role":   "Role",
+					"#status": "Status",
 				},
-				ConditionExpression: aws.String("attribute_not_exists(PK) AND attribute_not_exists(SK)"),
+				ExpressionAttributeValues: values,
 			},
+		})
+	} else {
+		item := map[string]types.AttributeValue{
+			"PK":             &types.AttributeValueMemberS{Value: fmt.Sprintf("%s", organizationId)},
+			"SK":             &types.AttributeValueMemberS{Value: fmt.Sprintf("USER#%s", email)},
+			"GSI1PK":         &types.AttributeValueMemberS{Value: fmt.Sprintf("USER#%s", email)},
+			"GSI1SK":         &types.AttributeValueMemberS{Value: fmt.Sprintf("%s", organizationId)},
+			"OrganizationId": &types.AttributeValueMemberS{Value: organizationId},
+			"UserName":       &types.AttributeValueMemberS{Value: email},
+			"Role":           &types.AttributeValueMemberS{Value: role},
+			"Status":         &types.AttributeValueMemberS{Value: companylib.OrgUserStatusInvited},
+			"IsActive":       &types.AttributeValueMemberBOOL{Value: true}, // Active in organization by default unless they are removed later.
+			"InvitationId":   &types.AttributeValueMemberS{Value: invitationId},
+			"InvitedBy":      &types.AttributeValueMemberS{Value: invitedBy},
+			"InvitedAt":      &types.AttributeValueMemberS{Value: now},
+			"AddedAt":        &types.AttributeValueMemberS{Value: now},
+			"UpdatedAt":      &types.AttributeValueMemberS{Value: now},
+		}
+		// 3. The team is joined on acceptance, with the role from the invitation
+		if teamId != "" {
+			item["InvitationTeamId"] = &types.AttributeValueMemberS{Value: teamId}
 		}
-		transactItems = append(transactItems, putItemOrgTable)
-	}
 
-	// 3. Add to Team table if teamId is provided
-	if teamId != "" {
-		putItemTeamTable := types.TransactWriteItem{
+		transactItems = append(transactItems, types.TransactWriteItem{
 			Put: &types.Put{
-				TableName: aws.String(svc.empSVC.TenantTeamsTable), // Teams use same table
-				Item: map[string]types.AttributeValue{
-					"PK":       &types.AttributeValueMemberS{Value: fmt.Sprintf("%s", teamId)},
-					"SK":       &types.AttributeValueMemberS{Value: fmt.Sprintf("USER#%s", email)},
-					"GSI1PK":   &types.AttributeValueMemberS{Value: fmt.Sprintf("USER#%s", email)},
-					"GSI1SK":   &types.AttributeValueMemberS{Value: fmt.Sprintf("%s", teamId)},
-					"TeamId":   &types.AttributeValueMemberS{Value: teamId},
-					"UserName": &types.AttributeValueMemberS{Value: email},
-					"Role":     &types.AttributeValueMemberS{Value: role},
-					"JoinedAt": &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)},
-					"IsActive": &types.AttributeValueMemberBOOL{Value: true}, // Active in teams by default unless they are removed later.
+				TableName:           aws.String(svc.orgSVC.OrganizationTable),
+				Item:                item,
+				ConditionExpression: aws.String("attribute_not_exists(SK) OR IsActive = :false"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":false": &types.AttributeValueMemberBOOL{Value: false},
 				},
-				ConditionExpression: aws.String("attribute_not_exists(PK) AND attribute_not_exists(SK)"),
 			},
-		}
-		transactItems = append(transactItems, putItemTeamTable)
+		})
+	}
+
+	// The seat is claimed together with the invitation
+	seatsOffset := len(transactItems)
+	if seats != nil {
+		transactItems = append(transactItems, seats.Items...)
 	}
 
 	// Execute transaction
@@ -481,17 +668,12 @@ func (svc *Service) createInvitedEmployee(email, role, teamId, organizationId, i
 		TransactItems: transactItems,
 	})
 	if err != nil {
-		// Ignore conditional check failures (user already exists)
-		if strings.Contains(err.Error(), "ConditionalCheckFailed") {
-			svc.logger.Printf("Employee %s already exists in one or more tables", email)
-			return nil
-		}
-		return fmt.Errorf("failed to create employee record: %w", err)
+		return seats.TransactionError(fmt.Errorf("failed to create invitation record: %w", err), seatsOffset)
 	}
 
-	logMsg := fmt.Sprintf("Created INVITED employee record for %s with role %s", email, role)
+	logMsg := fmt.Sprintf("Created INVITED record for %s with role %s", email, role)
 	if teamId != "" {
-		logMsg += fmt.Sprintf(" in team %s", teamId)
+		logMsg += fmt.Sprintf(" for team %s", teamId)
 	}
 	svc.logger.Printf(logMsg)
 	return nil
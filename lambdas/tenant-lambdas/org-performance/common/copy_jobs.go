@@ -0,0 +1,43 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	companylib "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/company-lib"
+)
+
+// copyJobErrorResponse maps clone, template and copy job errors to their HTTP status
+func (svc *Service) copyJobErrorResponse(message string, err error) (events.APIGatewayProxyResponse, error) {
+	switch {
+	case errors.Is(err, companylib.ErrCopyJobInvalid), errors.Is(err, companylib.ErrPerfTemplateInvalid):
+		return svc.errorResponse(http.StatusBadRequest, message, err)
+	case errors.Is(err, companylib.ErrCopyJobNotFound), errors.Is(err, companylib.ErrPerfTemplateNotFound):
+		return svc.errorResponse(http.StatusNotFound, message, err)
+	case errors.Is(err, companylib.ErrCopyJobConflict):
+		return svc.errorResponse(http.StatusConflict, message, err)
+	}
+	return svc.errorResponse(http.StatusInternalServerError, message, err)
+}
+
+// copyJobResponse returns a finished copy job. A job that stopped part-way comes back with the
+// error so the caller can resume it by its id.
+func (svc *Service) copyJobResponse(successStatus int, message string, job map[string]interface{}, err error) (events.APIGatewayProxyResponse, error) {
+	if err == nil {
+		return svc.successResponse(successStatus, job)
+	}
+	if job == nil || errors.Is(err, companylib.ErrCopyJobConflict) {
+		return svc.copyJobErrorResponse(message, err)
+	}
+	svc.logger.Printf("%s: %v", message, err)
+	body, _ := json.Marshal(map[string]interface{}{
+		"error":   message,
+		"message": fmt.Sprintf("%s: %v", message, err),
+		"job":     job,
+	})
+	return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Headers: RESP_HEADERS, Body: string(body)}, nil
+}
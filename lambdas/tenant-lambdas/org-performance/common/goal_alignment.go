@@ -0,0 +1,190 @@
+package common
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	companylib "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/company-lib"
+)
+
+// alignmentReadConcurrency bounds the team and member reads goalAlignment runs at once
+const alignmentReadConcurrency = 16
+
+// goalAlignment builds the cycle's alignment tree from the goals and linked tasks of the active
+// members of the organization's teams. Members' goals live under per-member partitions, so they are
+// read in parallel rather than one query after another.
+func (svc *Service) goalAlignment(cycleID string, orgID string) (*companylib.GoalAlignment, error) {
+	if svc.perfHubTable == "" {
+		return nil, fmt.Errorf("PERF_HUB_TABLE is not configured")
+	}
+	teams, err := svc.teamsSVC.GetOrganizationTeams(orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	teamNames := map[string]string{}
+	activeTeams := []companylib.TeamMetadata{}
+	for _, team := range teams {
+		teamNames[team.TeamId] = team.TeamName
+		if team.Status != companylib.TeamStatusInactive {
+			activeTeams = append(activeTeams, team)
+		}
+	}
+
+	teamMembers := make([][]companylib.TeamMember, len(activeTeams))
+	err = inParallel(len(activeTeams), func(i int) error {
+		members, err := svc.teamsSVC.GetTeamMembers(activeTeams[i].TeamId)
+		teamMembers[i] = members
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	type teamMember struct{ userName, teamID string }
+	aligned := []teamMember{}
+	for i, members := range teamMembers {
+		for _, member := range members {
+			if member.IsActive && member.Role != companylib.TeamMemberRoleGuest {
+				aligned = append(aligned, teamMember{member.UserName, activeTeams[i].TeamId})
+			}
+		}
+	}
+
+	goalsByMember := make([][]companylib.AlignmentMemberGoal, len(aligned))
+	err = inParallel(len(aligned), func(i int) error {
+		goals, err := svc.memberAlignmentGoals(aligned[i].userName, aligned[i].teamID)
+		goalsByMember[i] = goals
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	memberGoals := []companylib.AlignmentMemberGoal{}
+	for _, goals := range goalsByMember {
+		memberGoals = append(memberGoals, goals...)
+	}
+
+	return svc.perfSVC.GetGoalAlignment(cycleID, memberGoals, teamNames)
+}
+
+// inParallel calls fn for 0..n-1 on up to alignmentReadConcurrency goroutines and returns the
+// error of the lowest index that failed
+func inParallel(n int, fn func(i int) error) error {
+	errs := make([]error, n)
+	slots := make(chan struct{}, alignmentReadConcurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			errs[i] = fn(i)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// memberAlignmentGoals reads a member's goals in a team, with the tasks linked to each
+func (svc *Service) memberAlignmentGoals(userName string, teamID string) ([]companylib.AlignmentMemberGoal, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(svc.perfHubTable),
+		KeyConditionExpression: aws.String("PK = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("USER#%s#TEAM#%s", userName, teamID)},
+		},
+	}
+
+	goals := []companylib.AlignmentMemberGoal{}
+	tasks := map[string][]companylib.AlignmentTask{}
+	for {
+		out, err := svc.ddb.Query(svc.ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query goals of %s: %w", userName, err)
+		}
+		for _, item := range out.Items {
+			sk := ""
+			if v, ok := item["SK"].(*types.AttributeValueMemberS); ok {
+				sk = v.Value
+			}
+			switch {
+			case strings.HasPrefix(sk, "GOAL#") && !strings.Contains(sk, "#CMMNT#"):
+				var g userGoalItem
+				if err := attributevalue.UnmarshalMap(item, &g); err != nil {
+					svc.logger.Printf("warn: failed to unmarshal goal %s of %s: %v", sk, userName, err)
+					continue
+				}
+				goals = append(goals, companylib.AlignmentMemberGoal{
+					GoalId:    g.GoalID,
+					UserName:  userName,
+					TeamId:    teamID,
+					Title:     g.Title,
+					Status:    g.Status,
+					DueDate:   g.DueDate,
+					OrgGoalId: g.OrgGoalID,
+					Progress:  float64(g.Progress),
+				})
+			case strings.HasPrefix(sk, "TASK#"):
+				var t userTaskItem
+				if err := attributevalue.UnmarshalMap(item, &t); err != nil {
+					svc.logger.Printf("warn: failed to unmarshal task %s of %s: %v", sk, userName, err)
+					continue
+				}
+				if t.GoalID != "" {
+					tasks[t.GoalID] = append(tasks[t.GoalID], companylib.AlignmentTask{TaskId: t.TaskID, Title: t.Title, Status: t.Status, Done: t.Done})
+				}
+			}
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+
+	for i := range goals {
+		goals[i].Tasks = tasks[goals[i].GoalId]
+	}
+	return goals, nil
+}
+
+// goalAlignmentFormats are the accepted values of the goal-alignment format query parameter
+var goalAlignmentFormats = map[string]bool{"": true, "tree": true, "graph": true, "csv": true}
+
+// goalAlignmentResponse renders the alignment as a tree (default), a strategy map graph or CSV
+func (svc *Service) goalAlignmentResponse(alignment *companylib.GoalAlignment, format string) (events.APIGatewayProxyResponse, error) {
+	switch strings.ToLower(format) {
+	case "graph":
+		return svc.successResponse(http.StatusOK, companylib.GoalAlignmentGraph(alignment))
+	case "csv":
+		body, err := companylib.GoalAlignmentCSV(alignment)
+		if err != nil {
+			return svc.errorResponse(http.StatusInternalServerError, "Failed to export goal alignment", err)
+		}
+		headers := map[string]string{}
+		for k, v := range RESP_HEADERS {
+			headers[k] = v
+		}
+		headers["Content-Type"] = "text/csv"
+		headers["Content-Disposition"] = fmt.Sprintf(`attachment; filename="goal-alignment-%s.csv"`, alignment.CycleId)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Headers: headers, Body: body}, nil
+	default:
+		return svc.successResponse(http.StatusOK, alignment)
+	}
+}
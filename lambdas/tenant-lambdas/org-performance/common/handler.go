@@ -3,6 +3,7 @@ package common
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -10,6 +11,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -30,8 +32,16 @@ type Service struct {
 	orgSVC       *companylib.OrgServiceV2
 	empSVC       *companylib.EmployeeService
 	perfSVC      *companylib.PerformanceService
+	teamsSVC     *companylib.TeamsServiceV2
+	attributeSVC *companylib.TeamAttributeServiceV2
+	notifSVC     *companylib.NotificationService
+	emailSVC     *companylib.EmailService
 	ddb          *dynamodb.Client
 	perfHubTable string
+
+	// used by the KPI reminder schedule
+	appBaseURL        string
+	kpiReminderRepeat time.Duration
 }
 
 const (
@@ -71,6 +81,31 @@ func NewService() (*Service, error) {
 	perfSvc := companylib.CreatePerformanceService(ctx, ddbclient, logger)
 	perfSvc.OrgPerformanceTable = os.Getenv("ORG_PERFORMANCE_TABLE")
 	perfSvc.OrganizationTable = os.Getenv("ORGANIZATION_TABLE")
+	perfSvc.PerfHubTable = os.Getenv("PERF_HUB_TABLE")
+
+	teamsSvc := companylib.CreateTeamsServiceV2(ctx, ddbclient, logger, empSvc, nil)
+	teamsSvc.TeamsTable = os.Getenv("TEAMS_TABLE")
+
+	attributeSvc := companylib.CreateTeamAttributeServiceV2(ctx, ddbclient, logger)
+	attributeSvc.TeamAttributesTable = os.Getenv("TEAM_ATTRIBUTES_TABLE")
+	attributeSvc.TeamAttributesTeamIdIndex = os.Getenv("TEAM_ATTRIBUTES_TEAMID_INDEX")
+
+	notifSvc := companylib.CreateNotificationService(ctx, ddbclient, logger, nil)
+	notifSvc.NotificationsTable = os.Getenv("NOTIFICATIONS_TABLE")
+	notifSvc.NotificationsTable_DigestIndex = os.Getenv("NOTIFICATIONS_TABLE_DIGEST_INDEX")
+
+	baseURL := os.Getenv("APP_BASE_URL")
+	if baseURL == "" {
+		baseURL = "https://app.gomovo.com"
+	}
+
+	repeatDays := defaultKPIReminderRepeatDays
+	if value := os.Getenv("KPI_REMINDER_REPEAT_DAYS"); value != "" {
+		repeatDays, err = strconv.Atoi(value)
+		if err != nil || repeatDays < 1 {
+			return nil, fmt.Errorf("invalid KPI_REMINDER_REPEAT_DAYS: %s", value)
+		}
+	}
 
 	svc := &Service{
 		ctx:          ctx,
@@ -78,8 +113,15 @@ func NewService() (*Service, error) {
 		orgSVC:       orgSvc,
 		empSVC:       empSvc,
 		perfSVC:      perfSvc,
+		teamsSVC:     teamsSvc,
+		attributeSVC: attributeSvc,
+		notifSVC:     notifSvc,
+		emailSVC:     emailSvc,
 		ddb:          ddbclient,
 		perfHubTable: os.Getenv("PERF_HUB_TABLE"),
+
+		appBaseURL:        strings.TrimSuffix(baseURL, "/"),
+		kpiReminderRepeat: time.Duration(repeatDays) * 24 * time.Hour,
 	}
 
 	return svc, nil
@@ -117,7 +159,7 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 
 	if len(parts) == 4 && parts[1] == "organizations" && parts[3] == "performance-cycles" {
 		orgID := parts[2]
-		if err := svc.ensureOrgAdmin(orgID, userName); err != nil {
+		if err := svc.ensureOrgAdmin(orgID, userName, request.HTTPMethod); err != nil {
 			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
 		}
 		switch request.HTTPMethod {
@@ -162,7 +204,7 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 			if err != nil {
 				return svc.errorResponse(http.StatusNotFound, "Performance cycle not found", err)
 			}
-			if err := svc.ensureOrgAdmin(toString(res["organizationId"]), userName); err != nil {
+			if err := svc.ensureOrgAdmin(toString(res["organizationId"]), userName, request.HTTPMethod); err != nil {
 				return svc.errorResponse(http.StatusForbidden, "Access denied", err)
 			}
 			return svc.successResponse(http.StatusOK, res)
@@ -171,7 +213,7 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 			if err != nil {
 				return svc.errorResponse(http.StatusNotFound, "Performance cycle not found", err)
 			}
-			if err := svc.ensureOrgAdmin(toString(base["organizationId"]), userName); err != nil {
+			if err := svc.ensureOrgAdmin(toString(base["organizationId"]), userName, request.HTTPMethod); err != nil {
 				return svc.errorResponse(http.StatusForbidden, "Access denied", err)
 			}
 			patch, err := parseBody(request.Body)
@@ -180,7 +222,7 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 			}
 			res, err := svc.perfSVC.UpdatePerformanceCycle(cycleID, patch)
 			if err != nil {
-				return svc.errorResponse(http.StatusInternalServerError, "Failed to update performance cycle", err)
+				return svc.perfDataErrorResponse("Failed to update performance cycle", err)
 			}
 			return svc.successResponse(http.StatusOK, res)
 		case "DELETE":
@@ -188,7 +230,7 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 			if err != nil {
 				return svc.errorResponse(http.StatusNotFound, "Performance cycle not found", err)
 			}
-			if err := svc.ensureOrgAdmin(toString(base["organizationId"]), userName); err != nil {
+			if err := svc.ensureOrgAdmin(toString(base["organizationId"]), userName, request.HTTPMethod); err != nil {
 				return svc.errorResponse(http.StatusForbidden, "Access denied", err)
 			}
 			if err := svc.perfSVC.DeletePerformanceCycle(cycleID); err != nil {
@@ -204,7 +246,7 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 		if err != nil {
 			return svc.errorResponse(http.StatusNotFound, "Performance cycle not found", err)
 		}
-		if err := svc.ensureOrgAdmin(toString(cycle["organizationId"]), userName); err != nil {
+		if err := svc.ensureOrgAdmin(toString(cycle["organizationId"]), userName, request.HTTPMethod); err != nil {
 			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
 		}
 		switch request.HTTPMethod {
@@ -233,7 +275,7 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 		if err != nil {
 			return svc.errorResponse(http.StatusNotFound, "Quarter not found", err)
 		}
-		if err := svc.ensureOrgAdmin(toString(quarter["organizationId"]), userName); err != nil {
+		if err := svc.ensureOrgAdmin(toString(quarter["organizationId"]), userName, request.HTTPMethod); err != nil {
 			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
 		}
 		switch request.HTTPMethod {
@@ -256,7 +298,7 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 			}
 			res, err := svc.perfSVC.UpdateQuarter(quarterID, patch)
 			if err != nil {
-				return svc.errorResponse(http.StatusInternalServerError, "Failed to update quarter", err)
+				return svc.perfDataErrorResponse("Failed to update quarter", err)
 			}
 			return svc.successResponse(http.StatusOK, res)
 		case "DELETE":
@@ -267,12 +309,85 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 		}
 	}
 
+	if len(parts) == 4 && parts[1] == "quarters" && (parts[3] == "review-window" || parts[3] == "reviews") {
+		quarterID := parts[2]
+		quarter, err := svc.perfSVC.GetQuarterDetails(quarterID, false, false, false, false)
+		if err != nil {
+			return svc.errorResponse(http.StatusNotFound, "Quarter not found", err)
+		}
+		orgID := toString(quarter["organizationId"])
+		if err := svc.ensureOrgAdmin(orgID, userName, request.HTTPMethod); err != nil {
+			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
+		}
+		switch {
+		case parts[3] == "review-window" && request.HTTPMethod == "POST":
+			input, err := parseBody(request.Body)
+			if err != nil {
+				return svc.errorResponse(http.StatusBadRequest, "Invalid request body", err)
+			}
+			participants, err := svc.reviewParticipants(orgID, toStringSlice(input["teamIds"]))
+			if err != nil {
+				return svc.errorResponse(http.StatusBadRequest, "Failed to load review participants", err)
+			}
+			res, err := svc.perfSVC.OpenReviewWindow(quarterID, input, participants, userName)
+			if err != nil {
+				return svc.reviewErrorResponse("Failed to open review window", err)
+			}
+			return svc.successResponse(http.StatusOK, res)
+		case parts[3] == "review-window" && request.HTTPMethod == "DELETE":
+			res, err := svc.perfSVC.CloseReviewWindow(quarterID, userName)
+			if err != nil {
+				return svc.reviewErrorResponse("Failed to close review window", err)
+			}
+			return svc.successResponse(http.StatusOK, res)
+		case parts[3] == "reviews" && request.HTTPMethod == "GET":
+			filters := map[string]string{
+				"status": request.QueryStringParameters["status"],
+				"teamId": request.QueryStringParameters["teamId"],
+			}
+			res, err := svc.perfSVC.ListQuarterReviews(quarterID, filters)
+			if err != nil {
+				return svc.reviewErrorResponse("Failed to list reviews", err)
+			}
+			return svc.successResponse(http.StatusOK, res)
+		}
+	}
+
+	if len(parts) >= 3 && len(parts) <= 4 && parts[1] == "reviews" {
+		reviewID := parts[2]
+		review, err := svc.perfSVC.GetReview(reviewID)
+		if err != nil {
+			return svc.reviewErrorResponse("Failed to get review", err)
+		}
+		if err := svc.ensureOrgAdmin(review.OrganizationID, userName, request.HTTPMethod); err != nil {
+			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
+		}
+		if len(parts) == 3 && request.HTTPMethod == "GET" {
+			return svc.successResponse(http.StatusOK, review)
+		}
+		if len(parts) == 4 && parts[3] == "calibration" && request.HTTPMethod == "PUT" {
+			input, err := parseBody(request.Body)
+			if err != nil {
+				return svc.errorResponse(http.StatusBadRequest, "Invalid request body", err)
+			}
+			var rating *float64
+			if raw, ok := input["calibratedRating"].(float64); ok {
+				rating = &raw
+			}
+			res, err := svc.perfSVC.CalibrateReview(reviewID, userName, rating, toString(input["notes"]))
+			if err != nil {
+				return svc.reviewErrorResponse("Failed to calibrate review", err)
+			}
+			return svc.successResponse(http.StatusOK, res)
+		}
+	}
+
 	if len(parts) == 2 && parts[1] == "kpis" {
 		orgID := svc.getOrgIDFromHeaders(request)
 		if orgID == "" {
 			return svc.errorResponse(http.StatusBadRequest, "Organization-Id header is required", nil)
 		}
-		if err := svc.ensureOrgAdmin(orgID, userName); err != nil {
+		if err := svc.ensureOrgAdmin(orgID, userName, request.HTTPMethod); err != nil {
 			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
 		}
 		switch request.HTTPMethod {
@@ -309,7 +424,7 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 		if err != nil {
 			return svc.errorResponse(http.StatusNotFound, "KPI not found", err)
 		}
-		if err := svc.ensureOrgAdmin(toString(kpi["organizationId"]), userName); err != nil {
+		if err := svc.ensureOrgAdmin(toString(kpi["organizationId"]), userName, request.HTTPMethod); err != nil {
 			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
 		}
 		switch request.HTTPMethod {
@@ -330,7 +445,10 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 			}
 			res, err := svc.perfSVC.UpdateKPI(kpiID, patch)
 			if err != nil {
-				return svc.errorResponse(http.StatusInternalServerError, "Failed to update KPI", err)
+				if errors.Is(err, companylib.ErrKPIInvalid) {
+					return svc.errorResponse(http.StatusBadRequest, "Invalid KPI", err)
+				}
+				return svc.perfDataErrorResponse("Failed to update KPI", err)
 			}
 			return svc.successResponse(http.StatusOK, res)
 		case "DELETE":
@@ -348,7 +466,7 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 		if err != nil {
 			return svc.errorResponse(http.StatusNotFound, "Parent KPI not found", err)
 		}
-		if err := svc.ensureOrgAdmin(toString(parent["organizationId"]), userName); err != nil {
+		if err := svc.ensureOrgAdmin(toString(parent["organizationId"]), userName, request.HTTPMethod); err != nil {
 			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
 		}
 		input, err := parseBody(request.Body)
@@ -368,7 +486,7 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 		if err != nil {
 			return svc.errorResponse(http.StatusNotFound, "KPI not found", err)
 		}
-		if err := svc.ensureOrgAdmin(toString(kpi["organizationId"]), userName); err != nil {
+		if err := svc.ensureKPIContributor(kpi, userName, request.HTTPMethod); err != nil {
 			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
 		}
 		input, err := parseBody(request.Body)
@@ -377,17 +495,53 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 		}
 		res, err := svc.perfSVC.AddKPIValue(kpiID, input, userName)
 		if err != nil {
-			return svc.errorResponse(http.StatusUnprocessableEntity, "Failed to add KPI value", err)
+			return svc.kpiValueErrorResponse("Failed to add KPI value", err)
 		}
 		return svc.successResponse(http.StatusCreated, res)
 	}
 
+	if len(parts) == 5 && parts[1] == "kpis" && parts[3] == "values" && request.HTTPMethod == "PATCH" {
+		kpiID := parts[2]
+		kpi, err := svc.perfSVC.GetKPIDetails(kpiID, false, false)
+		if err != nil {
+			return svc.errorResponse(http.StatusNotFound, "KPI not found", err)
+		}
+		if err := svc.ensureKPIContributor(kpi, userName, request.HTTPMethod); err != nil {
+			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
+		}
+		input, err := parseBody(request.Body)
+		if err != nil {
+			return svc.errorResponse(http.StatusBadRequest, "Invalid request body", err)
+		}
+		res, err := svc.perfSVC.AmendKPIValue(kpiID, parts[4], input, userName)
+		if err != nil {
+			return svc.kpiValueErrorResponse("Failed to amend KPI value", err)
+		}
+		return svc.successResponse(http.StatusOK, res)
+	}
+
+	if len(parts) == 4 && parts[1] == "kpis" && parts[3] == "value-audit" && request.HTTPMethod == "GET" {
+		kpiID := parts[2]
+		kpi, err := svc.perfSVC.GetKPIDetails(kpiID, false, false)
+		if err != nil {
+			return svc.errorResponse(http.StatusNotFound, "KPI not found", err)
+		}
+		if err := svc.ensureKPIContributor(kpi, userName, request.HTTPMethod); err != nil {
+			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
+		}
+		res, err := svc.perfSVC.GetKPIValueAudit(kpiID)
+		if err != nil {
+			return svc.errorResponse(http.StatusInternalServerError, "Failed to load KPI value audit", err)
+		}
+		return svc.successResponse(http.StatusOK, res)
+	}
+
 	if len(parts) == 2 && parts[1] == "okrs" {
 		orgID := svc.getOrgIDFromHeaders(request)
 		if orgID == "" {
 			return svc.errorResponse(http.StatusBadRequest, "Organization-Id header is required", nil)
 		}
-		if err := svc.ensureOrgAdmin(orgID, userName); err != nil {
+		if err := svc.ensureOrgAdmin(orgID, userName, request.HTTPMethod); err != nil {
 			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
 		}
 		switch request.HTTPMethod {
@@ -422,7 +576,7 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 		if err != nil {
 			return svc.errorResponse(http.StatusNotFound, "OKR not found", err)
 		}
-		if err := svc.ensureOrgAdmin(toString(okr["organizationId"]), userName); err != nil {
+		if err := svc.ensureOrgAdmin(toString(okr["organizationId"]), userName, request.HTTPMethod); err != nil {
 			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
 		}
 		switch request.HTTPMethod {
@@ -443,7 +597,7 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 			}
 			res, err := svc.perfSVC.UpdateOKR(okrID, patch)
 			if err != nil {
-				return svc.errorResponse(http.StatusInternalServerError, "Failed to update OKR", err)
+				return svc.perfDataErrorResponse("Failed to update OKR", err)
 			}
 			return svc.successResponse(http.StatusOK, res)
 		case "DELETE":
@@ -456,16 +610,23 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 
 	if len(parts) == 3 && parts[1] == "key-results" && request.HTTPMethod == "PATCH" {
 		keyResultID := parts[2]
+		keyResult, err := svc.perfSVC.GetKeyResult(keyResultID)
+		if err != nil {
+			return svc.errorResponse(http.StatusNotFound, "Key result not found", err)
+		}
+		if err := svc.ensureOrgAdmin(toString(keyResult["organizationId"]), userName, request.HTTPMethod); err != nil {
+			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
+		}
 		patch, err := parseBody(request.Body)
 		if err != nil {
 			return svc.errorResponse(http.StatusBadRequest, "Invalid request body", err)
 		}
 		res, err := svc.perfSVC.UpdateKeyResult(keyResultID, patch)
 		if err != nil {
-			return svc.errorResponse(http.StatusInternalServerError, "Failed to update key result", err)
-		}
-		if err := svc.ensureOrgAdmin(toString(res["organizationId"]), userName); err != nil {
-			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
+			if errors.Is(err, companylib.ErrKeyResultInvalid) {
+				return svc.errorResponse(http.StatusBadRequest, "Invalid key result", err)
+			}
+			return svc.perfDataErrorResponse("Failed to update key result", err)
 		}
 		return svc.successResponse(http.StatusOK, res)
 	}
@@ -476,7 +637,7 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 		if err != nil {
 			return svc.errorResponse(http.StatusNotFound, "Quarter not found", err)
 		}
-		if err := svc.ensureOrgAdmin(toString(quarter["organizationId"]), userName); err != nil {
+		if err := svc.ensureOrgAdmin(toString(quarter["organizationId"]), userName, request.HTTPMethod); err != nil {
 			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
 		}
 		switch request.HTTPMethod {
@@ -501,6 +662,13 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 
 	if len(parts) == 3 && parts[1] == "meeting-notes" {
 		noteID := parts[2]
+		note, err := svc.perfSVC.GetMeetingNote(noteID)
+		if err != nil {
+			return svc.errorResponse(http.StatusNotFound, "Meeting note not found", err)
+		}
+		if err := svc.ensureOrgAdmin(toString(note["organizationId"]), userName, request.HTTPMethod); err != nil {
+			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
+		}
 		switch request.HTTPMethod {
 		case "PATCH":
 			patch, err := parseBody(request.Body)
@@ -509,10 +677,7 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 			}
 			res, err := svc.perfSVC.UpdateMeetingNote(noteID, patch)
 			if err != nil {
-				return svc.errorResponse(http.StatusInternalServerError, "Failed to update meeting note", err)
-			}
-			if err := svc.ensureOrgAdmin(toString(res["organizationId"]), userName); err != nil {
-				return svc.errorResponse(http.StatusForbidden, "Access denied", err)
+				return svc.perfDataErrorResponse("Failed to update meeting note", err)
 			}
 			return svc.successResponse(http.StatusOK, res)
 		case "DELETE":
@@ -525,30 +690,179 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 
 	if len(parts) == 4 && parts[1] == "performance-cycles" && parts[3] == "analytics" && request.HTTPMethod == "GET" {
 		cycleID := parts[2]
-		res, err := svc.perfSVC.GetCycleAnalytics(cycleID)
+		res, err := svc.perfSVC.GetCycleAnalytics(cycleID, request.QueryStringParameters["granularity"])
+		if errors.Is(err, companylib.ErrTrendGranularityInvalid) {
+			return svc.errorResponse(http.StatusBadRequest, "granularity must be month or week", err)
+		}
 		if err != nil {
 			return svc.errorResponse(http.StatusInternalServerError, "Failed to get cycle analytics", err)
 		}
-		if err := svc.ensureOrgAdmin(toString(res["organizationId"]), userName); err != nil {
+		if err := svc.ensureOrgAdmin(toString(res["organizationId"]), userName, request.HTTPMethod); err != nil {
 			// fallback for analytics payload if organizationId is nested
 			summaryOrg := svc.getOrgIDFromHeaders(request)
-			if summaryOrg == "" || svc.ensureOrgAdmin(summaryOrg, userName) != nil {
+			if summaryOrg == "" || svc.ensureOrgAdmin(summaryOrg, userName, request.HTTPMethod) != nil {
 				return svc.errorResponse(http.StatusForbidden, "Access denied", err)
 			}
 		}
 		return svc.successResponse(http.StatusOK, res)
 	}
 
+	if len(parts) == 4 && parts[1] == "performance-cycles" && parts[3] == "data-freshness" && request.HTTPMethod == "GET" {
+		cycleID := parts[2]
+		cycle, err := svc.perfSVC.GetPerformanceCycleDetails(cycleID, false, false, false, false)
+		if err != nil {
+			return svc.errorResponse(http.StatusNotFound, "Performance cycle not found", err)
+		}
+		if err := svc.ensureOrgAdmin(toString(cycle["organizationId"]), userName, request.HTTPMethod); err != nil {
+			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
+		}
+		res, err := svc.perfSVC.GetCycleDataFreshness(cycleID, time.Now().UTC())
+		if err != nil {
+			return svc.errorResponse(http.StatusInternalServerError, "Failed to get data freshness", err)
+		}
+		return svc.successResponse(http.StatusOK, res)
+	}
+
+	if len(parts) == 4 && parts[1] == "performance-cycles" && parts[3] == "goal-alignment" && request.HTTPMethod == "GET" {
+		cycleID := parts[2]
+		format := strings.ToLower(request.QueryStringParameters["format"])
+		if !goalAlignmentFormats[format] {
+			return svc.errorResponse(http.StatusBadRequest, "format must be tree, graph or csv", nil)
+		}
+		cycle, err := svc.perfSVC.GetPerformanceCycleDetails(cycleID, false, false, false, false)
+		if err != nil {
+			return svc.errorResponse(http.StatusNotFound, "Performance cycle not found", err)
+		}
+		orgID := toString(cycle["organizationId"])
+		if err := svc.ensureOrgAdmin(orgID, userName, request.HTTPMethod); err != nil {
+			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
+		}
+		res, err := svc.goalAlignment(cycleID, orgID)
+		if err != nil {
+			return svc.errorResponse(http.StatusInternalServerError, "Failed to build goal alignment", err)
+		}
+		return svc.goalAlignmentResponse(res, format)
+	}
+
+	if len(parts) == 4 && parts[1] == "performance-cycles" && (parts[3] == "clone" || parts[3] == "apply-template") && request.HTTPMethod == "POST" {
+		cycleID := parts[2]
+		cycle, err := svc.perfSVC.GetPerformanceCycleDetails(cycleID, false, false, false, false)
+		if err != nil {
+			return svc.errorResponse(http.StatusNotFound, "Performance cycle not found", err)
+		}
+		if err := svc.ensureOrgAdmin(toString(cycle["organizationId"]), userName, request.HTTPMethod); err != nil {
+			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
+		}
+		input, err := parseBody(request.Body)
+		if err != nil {
+			return svc.errorResponse(http.StatusBadRequest, "Invalid request body", err)
+		}
+		if parts[3] == "clone" {
+			job, err := svc.perfSVC.CloneCycle(cycleID, input, userName)
+			return svc.copyJobResponse(http.StatusCreated, "Failed to clone performance cycle", job, err)
+		}
+		job, err := svc.perfSVC.ApplyTemplate(cycleID, input, userName)
+		return svc.copyJobResponse(http.StatusCreated, "Failed to apply performance template", job, err)
+	}
+
+	if len(parts) == 4 && parts[1] == "organizations" && parts[3] == "performance-templates" {
+		orgID := parts[2]
+		if err := svc.ensureOrgAdmin(orgID, userName, request.HTTPMethod); err != nil {
+			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
+		}
+		switch request.HTTPMethod {
+		case "GET":
+			res, err := svc.perfSVC.ListPerformanceTemplates(orgID)
+			if err != nil {
+				return svc.errorResponse(http.StatusInternalServerError, "Failed to list performance templates", err)
+			}
+			return svc.successResponse(http.StatusOK, map[string]interface{}{"items": res, "total": len(res)})
+		case "POST":
+			input, err := parseBody(request.Body)
+			if err != nil {
+				return svc.errorResponse(http.StatusBadRequest, "Invalid request body", err)
+			}
+			res, err := svc.perfSVC.CreatePerformanceTemplate(orgID, input, userName)
+			if err != nil {
+				return svc.copyJobErrorResponse("Failed to create performance template", err)
+			}
+			return svc.successResponse(http.StatusCreated, res)
+		}
+	}
+
+	if len(parts) == 3 && parts[1] == "performance-templates" {
+		templateID := parts[2]
+		template, err := svc.perfSVC.GetPerformanceTemplate(templateID)
+		if err != nil {
+			return svc.copyJobErrorResponse("Failed to get performance template", err)
+		}
+		if err := svc.ensureOrgAdmin(toString(template["organizationId"]), userName, request.HTTPMethod); err != nil {
+			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
+		}
+		switch request.HTTPMethod {
+		case "GET":
+			return svc.successResponse(http.StatusOK, template)
+		case "PATCH":
+			input, err := parseBody(request.Body)
+			if err != nil {
+				return svc.errorResponse(http.StatusBadRequest, "Invalid request body", err)
+			}
+			res, err := svc.perfSVC.UpdatePerformanceTemplate(templateID, input)
+			if err != nil {
+				return svc.copyJobErrorResponse("Failed to update performance template", err)
+			}
+			return svc.successResponse(http.StatusOK, res)
+		case "DELETE":
+			if err := svc.perfSVC.DeletePerformanceTemplate(templateID); err != nil {
+				return svc.copyJobErrorResponse("Failed to delete performance template", err)
+			}
+			return svc.successResponse(http.StatusOK, map[string]interface{}{"message": "Performance template deleted"})
+		}
+	}
+
+	if len(parts) >= 3 && len(parts) <= 4 && parts[1] == "performance-jobs" {
+		jobID := parts[2]
+		job, err := svc.perfSVC.GetCopyJob(jobID)
+		if err != nil {
+			return svc.copyJobErrorResponse("Failed to get copy job", err)
+		}
+		if err := svc.ensureOrgAdmin(toString(job["organizationId"]), userName, request.HTTPMethod); err != nil {
+			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
+		}
+		if len(parts) == 3 && request.HTTPMethod == "GET" {
+			return svc.successResponse(http.StatusOK, job)
+		}
+		if len(parts) == 4 && parts[3] == "resume" && request.HTTPMethod == "POST" {
+			res, err := svc.perfSVC.ResumeCopyJob(jobID)
+			return svc.copyJobResponse(http.StatusOK, "Failed to resume copy job", res, err)
+		}
+	}
+
+	if len(parts) == 5 && parts[1] == "organizations" && parts[3] == "performance-records" && parts[4] == "migrate" && request.HTTPMethod == "POST" {
+		orgID := parts[2]
+		if err := svc.ensureOrgAdmin(orgID, userName, request.HTTPMethod); err != nil {
+			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
+		}
+		res, err := svc.perfSVC.MigratePerformanceData(orgID, queryBool(request.QueryStringParameters, "dryRun", true))
+		if err != nil {
+			return svc.errorResponse(http.StatusInternalServerError, "Failed to migrate performance records", err)
+		}
+		return svc.successResponse(http.StatusOK, res)
+	}
+
 	if len(parts) == 4 && parts[1] == "quarters" && parts[3] == "analytics" && request.HTTPMethod == "GET" {
 		quarterID := parts[2]
 		quarter, err := svc.perfSVC.GetQuarterDetails(quarterID, false, false, false, false)
 		if err != nil {
 			return svc.errorResponse(http.StatusNotFound, "Quarter not found", err)
 		}
-		if err := svc.ensureOrgAdmin(toString(quarter["organizationId"]), userName); err != nil {
+		if err := svc.ensureOrgAdmin(toString(quarter["organizationId"]), userName, request.HTTPMethod); err != nil {
 			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
 		}
-		res, err := svc.perfSVC.GetQuarterAnalytics(quarterID)
+		res, err := svc.perfSVC.GetQuarterAnalytics(quarterID, request.QueryStringParameters["granularity"])
+		if errors.Is(err, companylib.ErrTrendGranularityInvalid) {
+			return svc.errorResponse(http.StatusBadRequest, "granularity must be month or week", err)
+		}
 		if err != nil {
 			return svc.errorResponse(http.StatusInternalServerError, "Failed to get quarter analytics", err)
 		}
@@ -571,7 +885,7 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 			if err != nil {
 				return svc.errorResponse(http.StatusNotFound, "Goal not found", err)
 			}
-			if err := svc.ensureOrgAdmin(toString(res["organizationId"]), userName); err != nil {
+			if err := svc.ensureOrgAdmin(toString(res["organizationId"]), userName, request.HTTPMethod); err != nil {
 				return svc.errorResponse(http.StatusForbidden, "Access denied", err)
 			}
 			return svc.successResponse(http.StatusOK, res)
@@ -580,7 +894,7 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 			if err != nil {
 				return svc.errorResponse(http.StatusNotFound, "Goal not found", err)
 			}
-			if err := svc.ensureOrgAdmin(toString(base["organizationId"]), userName); err != nil {
+			if err := svc.ensureOrgAdmin(toString(base["organizationId"]), userName, request.HTTPMethod); err != nil {
 				return svc.errorResponse(http.StatusForbidden, "Access denied", err)
 			}
 			patch, err := parseBody(request.Body)
@@ -589,7 +903,7 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 			}
 			res, err := svc.perfSVC.UpdateGoal(goalID, patch)
 			if err != nil {
-				return svc.errorResponse(http.StatusInternalServerError, "Failed to update goal", err)
+				return svc.perfDataErrorResponse("Failed to update goal", err)
 			}
 			return svc.successResponse(http.StatusOK, res)
 		}
@@ -601,7 +915,7 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 		if err != nil {
 			return svc.errorResponse(http.StatusNotFound, "Goal not found", err)
 		}
-		if err := svc.ensureOrgAdmin(toString(base["organizationId"]), userName); err != nil {
+		if err := svc.ensureOrgAdmin(toString(base["organizationId"]), userName, request.HTTPMethod); err != nil {
 			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
 		}
 		switch request.HTTPMethod {
@@ -634,7 +948,7 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 		if err != nil {
 			return svc.errorResponse(http.StatusNotFound, "Goal not found", err)
 		}
-		if err := svc.ensureOrgAdmin(toString(base["organizationId"]), userName); err != nil {
+		if err := svc.ensureOrgAdmin(toString(base["organizationId"]), userName, request.HTTPMethod); err != nil {
 			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
 		}
 		switch request.HTTPMethod {
@@ -664,7 +978,7 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 		if err != nil {
 			return svc.errorResponse(http.StatusNotFound, "Goal not found", err)
 		}
-		if err := svc.ensureOrgAdmin(toString(base["organizationId"]), userName); err != nil {
+		if err := svc.ensureOrgAdmin(toString(base["organizationId"]), userName, request.HTTPMethod); err != nil {
 			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
 		}
 		if err := svc.perfSVC.RemoveGoalTeam(goalID, teamID); err != nil {
@@ -679,7 +993,7 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 		if err != nil {
 			return svc.errorResponse(http.StatusNotFound, "Goal not found", err)
 		}
-		if err := svc.ensureOrgAdmin(toString(base["organizationId"]), userName); err != nil {
+		if err := svc.ensureOrgAdmin(toString(base["organizationId"]), userName, request.HTTPMethod); err != nil {
 			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
 		}
 		switch request.HTTPMethod {
@@ -704,6 +1018,13 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 
 	if len(parts) == 3 && parts[1] == "sub-items" {
 		subItemID := parts[2]
+		subItem, err := svc.perfSVC.GetSubItem(subItemID)
+		if err != nil {
+			return svc.errorResponse(http.StatusNotFound, "Sub-item not found", err)
+		}
+		if err := svc.ensureOrgAdmin(toString(subItem["organizationId"]), userName, request.HTTPMethod); err != nil {
+			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
+		}
 		switch request.HTTPMethod {
 		case "PATCH":
 			patch, err := parseBody(request.Body)
@@ -712,10 +1033,7 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 			}
 			res, err := svc.perfSVC.UpdateSubItem(subItemID, patch)
 			if err != nil {
-				return svc.errorResponse(http.StatusInternalServerError, "Failed to update sub-item", err)
-			}
-			if err := svc.ensureOrgAdmin(toString(res["organizationId"]), userName); err != nil {
-				return svc.errorResponse(http.StatusForbidden, "Access denied", err)
+				return svc.perfDataErrorResponse("Failed to update sub-item", err)
 			}
 			return svc.successResponse(http.StatusOK, res)
 		case "DELETE":
@@ -726,18 +1044,61 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 		}
 	}
 
-	if len(parts) == 4 && parts[1] == "goals" && parts[3] == "ladder-up" && request.HTTPMethod == "GET" {
+	if len(parts) == 4 && parts[1] == "goals" && parts[3] == "ladder-up" {
 		goalID := parts[2]
 		base, err := svc.perfSVC.GetGoalDetails(goalID, false, false, false, false, false, userName)
 		if err != nil {
 			return svc.errorResponse(http.StatusNotFound, "Goal not found", err)
 		}
-		if err := svc.ensureOrgAdmin(toString(base["organizationId"]), userName); err != nil {
+		switch request.HTTPMethod {
+		case "GET":
+			if !strings.EqualFold(toString(base["owner"]), userName) {
+				if err := svc.ensureOrgAdmin(toString(base["organizationId"]), userName, request.HTTPMethod); err != nil {
+					return svc.errorResponse(http.StatusForbidden, "Access denied", err)
+				}
+			}
+			res, err := svc.perfSVC.GetGoalLadderUp(goalID, request.QueryStringParameters["status"])
+			if err != nil {
+				return svc.errorResponse(http.StatusInternalServerError, "Failed to list ladder-up items", err)
+			}
+			return svc.successResponse(http.StatusOK, res)
+		case "POST":
+			input, err := parseBody(request.Body)
+			if err != nil {
+				return svc.errorResponse(http.StatusBadRequest, "Invalid request body", err)
+			}
+			teamID := strings.TrimSpace(toString(input["teamId"]))
+			if teamID == "" {
+				return svc.errorResponse(http.StatusBadRequest, "teamId is required", nil)
+			}
+			if err := svc.ensureLadderUpSubmitter(toString(base["organizationId"]), teamID, userName, request.HTTPMethod); err != nil {
+				return svc.errorResponse(http.StatusForbidden, "Access denied", err)
+			}
+			if userGoalID := strings.TrimSpace(toString(input["userGoalId"])); userGoalID != "" {
+				if err := svc.ensureMemberGoal(userName, teamID, userGoalID); err != nil {
+					return svc.ladderUpErrorResponse("Failed to submit ladder-up", err)
+				}
+			}
+			res, err := svc.perfSVC.SubmitLadderUp(goalID, input, userName)
+			if err != nil {
+				return svc.ladderUpErrorResponse("Failed to submit ladder-up", err)
+			}
+			svc.notifyLadderUpSubmitted(base, res)
+			return svc.successResponse(http.StatusCreated, res)
+		}
+	}
+
+	if len(parts) == 4 && parts[1] == "ladder-up" && parts[3] == "history" && request.HTTPMethod == "GET" {
+		ladderUp, base, errResp := svc.loadLadderUp(parts[2], userName)
+		if errResp != nil {
+			return *errResp, nil
+		}
+		if err := svc.ensureLadderUpViewer(base, ladderUp, userName); err != nil {
 			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
 		}
-		res, err := svc.perfSVC.GetGoalLadderUp(goalID, request.QueryStringParameters["status"])
+		res, err := svc.perfSVC.GetLadderUpHistory(parts[2])
 		if err != nil {
-			return svc.errorResponse(http.StatusInternalServerError, "Failed to list ladder-up items", err)
+			return svc.ladderUpErrorResponse("Failed to get ladder-up history", err)
 		}
 		return svc.successResponse(http.StatusOK, res)
 	}
@@ -745,25 +1106,51 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 	if len(parts) == 4 && parts[1] == "ladder-up" && request.HTTPMethod == "PATCH" {
 		ladderID := parts[2]
 		action := parts[3]
-		patch, err := parseBody(request.Body)
+		if action != "approve" && action != "reject" && action != "resubmit" {
+			return svc.errorResponse(http.StatusNotFound, "Route not found", nil)
+		}
+		input, err := parseBody(request.Body)
 		if err != nil {
 			return svc.errorResponse(http.StatusBadRequest, "Invalid request body", err)
 		}
-		var res map[string]interface{}
-		switch action {
-		case "approve":
-			res, err = svc.perfSVC.ApproveLadderUp(ladderID, patch)
-		case "reject":
-			res, err = svc.perfSVC.RejectLadderUp(ladderID, patch)
-		default:
-			return svc.errorResponse(http.StatusNotFound, "Route not found", nil)
+		ladderUp, base, errResp := svc.loadLadderUp(ladderID, userName)
+		if errResp != nil {
+			return *errResp, nil
 		}
-		if err != nil {
-			return svc.errorResponse(http.StatusInternalServerError, "Failed to update ladder-up item", err)
+
+		var res map[string]interface{}
+		if action == "resubmit" {
+			if !strings.EqualFold(toString(ladderUp["submittedBy"]), userName) {
+				return svc.errorResponse(http.StatusForbidden, "Access denied", fmt.Errorf("only the submitter can resubmit a ladder-up"))
+			}
+			if err := svc.ensureOrgWritable(toString(base["organizationId"]), request.HTTPMethod); err != nil {
+				return svc.errorResponse(http.StatusForbidden, "Access denied", err)
+			}
+			if userGoalID := strings.TrimSpace(toString(input["userGoalId"])); userGoalID != "" {
+				if err := svc.ensureMemberGoal(userName, toString(ladderUp["teamId"]), userGoalID); err != nil {
+					return svc.ladderUpErrorResponse("Failed to resubmit ladder-up", err)
+				}
+			}
+			res, err = svc.perfSVC.ResubmitLadderUp(ladderID, input, userName)
+			if err != nil {
+				return svc.ladderUpErrorResponse("Failed to resubmit ladder-up", err)
+			}
+			svc.notifyLadderUpSubmitted(base, res)
+			return svc.successResponse(http.StatusOK, res)
 		}
-		if err := svc.ensureOrgAdmin(toString(res["organizationId"]), userName); err != nil {
+
+		if err := svc.ensureLadderUpApprover(base, ladderUp, userName, request.HTTPMethod); err != nil {
 			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
 		}
+		if action == "approve" {
+			res, err = svc.perfSVC.ApproveLadderUp(ladderID, input, userName)
+		} else {
+			res, err = svc.perfSVC.RejectLadderUp(ladderID, input, userName)
+		}
+		if err != nil {
+			return svc.ladderUpErrorResponse("Failed to update ladder-up item", err)
+		}
+		svc.notifyLadderUpDecision(base, res)
 		return svc.successResponse(http.StatusOK, res)
 	}
 
@@ -795,7 +1182,7 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 	if len(parts) == 4 && parts[1] == "teams" && parts[3] == "goals" && request.HTTPMethod == "GET" {
 		teamID := parts[2]
 		orgID := svc.getOrgIDFromHeaders(request)
-		if err := svc.ensureOrgAdmin(orgID, userName); err != nil {
+		if err := svc.ensureOrgAdmin(orgID, userName, request.HTTPMethod); err != nil {
 			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
 		}
 		goalType := request.QueryStringParameters["type"]
@@ -824,7 +1211,7 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 				if strings.Contains(strings.ToLower(err.Error()), "forbidden") {
 					return svc.errorResponse(http.StatusForbidden, "Access denied", err)
 				}
-				return svc.errorResponse(http.StatusInternalServerError, "Failed to update task", err)
+				return svc.perfDataErrorResponse("Failed to update task", err)
 			}
 			return svc.successResponse(http.StatusOK, res)
 		case "DELETE":
@@ -846,7 +1233,7 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 		if err != nil {
 			return svc.errorResponse(http.StatusNotFound, "Goal not found", err)
 		}
-		if err := svc.ensureOrgAdmin(toString(base["organizationId"]), userName); err != nil {
+		if err := svc.ensureOrgAdmin(toString(base["organizationId"]), userName, request.HTTPMethod); err != nil {
 			return svc.errorResponse(http.StatusForbidden, "Access denied", err)
 		}
 		statusFilter := request.QueryStringParameters["status"]
@@ -1076,9 +1463,10 @@ func isPathHandledByGroup(parts []string, routeGroup string) bool {
 	switch routeGroup {
 	case RouteGroupCycles:
 		if resource == "organizations" {
-			return len(parts) >= 4 && parts[3] == "performance-cycles"
+			return len(parts) >= 4 && (parts[3] == "performance-cycles" || parts[3] == "performance-templates" || parts[3] == "performance-records")
 		}
-		return resource == "performance-cycles" || resource == "quarters" || resource == "meeting-notes"
+		return resource == "performance-cycles" || resource == "quarters" || resource == "meeting-notes" || resource == "reviews" ||
+			resource == "performance-templates" || resource == "performance-jobs"
 	case RouteGroupKPIs:
 		return resource == "kpis"
 	case RouteGroupOKRs:
@@ -1132,7 +1520,10 @@ func (svc *Service) getOrgIDFromHeaders(request events.APIGatewayProxyRequest) s
 	return orgID
 }
 
-func (svc *Service) ensureOrgAdmin(orgID string, userName string) error {
+// ensureOrgAdmin checks the user is an org admin and, for writes, that the organization is not
+// suspended (suspended organizations are read-only). Writes are refused when the organization's
+// billing status cannot be read.
+func (svc *Service) ensureOrgAdmin(orgID string, userName string, method string) error {
 	if orgID == "" {
 		return fmt.Errorf("organization ID is required")
 	}
@@ -1143,6 +1534,9 @@ func (svc *Service) ensureOrgAdmin(orgID string, userName string) error {
 	if !isAdmin {
 		return fmt.Errorf("user is not an organization admin")
 	}
+	if companylib.IsWriteMethod(method) {
+		return svc.orgSVC.EnsureOrgWritable(orgID)
+	}
 	return nil
 }
 
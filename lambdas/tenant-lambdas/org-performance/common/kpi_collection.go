@@ -0,0 +1,157 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	companylib "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/company-lib"
+)
+
+const defaultKPIReminderRepeatDays = 3
+
+// KPIReminderSummary is the outcome of one run of the KPI reminder schedule
+type KPIReminderSummary struct {
+	RunAt       string `json:"runAt"`
+	Cycles      int    `json:"cycles"`
+	OverdueKPIs int    `json:"overdueKpis"`
+	Owners      int    `json:"owners"`
+	Reminded    int    `json:"reminded"`
+	Failed      int    `json:"failed"`
+}
+
+// kpiValueErrorResponse maps KPI value errors to their HTTP status
+func (svc *Service) kpiValueErrorResponse(message string, err error) (events.APIGatewayProxyResponse, error) {
+	switch {
+	case errors.Is(err, companylib.ErrKPIValueDuplicate):
+		return svc.errorResponse(http.StatusConflict, message, err)
+	case errors.Is(err, companylib.ErrKPIValueInvalid):
+		return svc.errorResponse(http.StatusBadRequest, message, err)
+	case errors.Is(err, companylib.ErrKPIValueNotFound):
+		return svc.errorResponse(http.StatusNotFound, message, err)
+	default:
+		return svc.errorResponse(http.StatusUnprocessableEntity, message, err)
+	}
+}
+
+// ensureKPIContributor lets the KPI's owner, as well as org admins, record and correct its values
+func (svc *Service) ensureKPIContributor(kpi map[string]interface{}, userName string, method string) error {
+	orgID := toString(kpi["organizationId"])
+	if !strings.EqualFold(toString(kpi["owner"]), userName) {
+		return svc.ensureOrgAdmin(orgID, userName, method)
+	}
+	if companylib.IsWriteMethod(method) {
+		return svc.orgSVC.EnsureOrgWritable(orgID)
+	}
+	return nil
+}
+
+// HandleKPIReminderSchedule runs on an EventBridge schedule. Owners of KPIs with a missed reporting
+// period get one email and one in-app notification listing all of their overdue KPIs.
+func (svc *Service) HandleKPIReminderSchedule(ctx context.Context, event events.CloudWatchEvent) (KPIReminderSummary, error) {
+	now := time.Now().UTC()
+	summary := KPIReminderSummary{RunAt: now.Format(time.RFC3339)}
+
+	cycles, err := svc.perfSVC.ListActivePerformanceCycles()
+	if err != nil {
+		return summary, err
+	}
+	summary.Cycles = len(cycles)
+
+	for _, cycle := range cycles {
+		cycleID := toString(cycle.Data["id"])
+		report, err := svc.perfSVC.GetCycleDataFreshness(cycleID, now)
+		if err != nil {
+			svc.logger.Printf("Failed to compute data freshness of cycle %s: %v", cycleID, err)
+			summary.Failed++
+			continue
+		}
+		summary.OverdueKPIs += report.Summary.Overdue
+
+		for _, statuses := range companylib.KPIRemindersDue(report, now, svc.kpiReminderRepeat) {
+			summary.Owners++
+			if err := svc.remindKPIOwner(toString(cycle.Data["name"]), statuses); err != nil {
+				svc.logger.Printf("Failed to remind %s about %d KPI(s): %v", statuses[0].Owner, len(statuses), err)
+				summary.Failed++
+				continue
+			}
+			for _, status := range statuses {
+				if err := svc.perfSVC.MarkKPIReminderSent(status, now); err != nil {
+					svc.logger.Printf("Failed to record reminder for KPI %s: %v", status.KpiId, err)
+				}
+			}
+			summary.Reminded += len(statuses)
+		}
+	}
+
+	svc.logger.Printf("KPI reminder run complete: %+v", summary)
+	return summary, nil
+}
+
+// remindKPIOwner emails the owner the list of their overdue KPIs and leaves an in-app notification
+func (svc *Service) remindKPIOwner(cycleName string, statuses []companylib.KPISubmissionStatus) error {
+	owner := statuses[0].Owner
+	link := svc.appBaseURL + "/performance/kpis"
+	if cycleName == "" {
+		cycleName = "the current performance cycle"
+	}
+
+	subject := fmt.Sprintf("%d KPI value(s) overdue in %s", len(statuses), cycleName)
+	if len(statuses) == 1 {
+		subject = fmt.Sprintf("%s: KPI value overdue", statuses[0].Name)
+	}
+
+	var htmlItems, textItems strings.Builder
+	for _, status := range statuses {
+		periods := strings.Join(status.MissedPeriods, ", ")
+		if status.MissedPeriodCount > len(status.MissedPeriods) {
+			periods = fmt.Sprintf("%d periods, latest %s", status.MissedPeriodCount, periods)
+		}
+		fmt.Fprintf(&htmlItems, `<li style="margin-bottom: 8px;"><strong>%s</strong> (%s): no value for %s, overdue since %s</li>`,
+			html.EscapeString(status.Name), html.EscapeString(status.ReportingFrequency), html.EscapeString(periods), status.OverdueSince)
+		fmt.Fprintf(&textItems, "- %s (%s): no value for %s, overdue since %s\n",
+			status.Name, status.ReportingFrequency, periods, status.OverdueSince)
+	}
+
+	err := svc.emailSVC.SendEmail(companylib.EmailInput{
+		ToEmails: []string{owner},
+		Subject:  subject,
+		HtmlBody: fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<body style="margin: 0; padding: 20px; font-family: Arial, sans-serif; background-color: #f4f4f4;">
+	<div style="max-width: 600px; margin: 0 auto; background-color: #ffffff; border-radius: 8px; padding: 40px;">
+		<h1 style="color: #333333; font-size: 24px; margin: 0 0 20px 0;">KPI values are overdue</h1>
+		<p style="color: #555555; font-size: 16px; line-height: 1.5;">The following KPIs you own in %s are missing values for reporting periods that have ended:</p>
+		<ul style="color: #555555; font-size: 16px; line-height: 1.5;">%s</ul>
+		<div style="text-align: center; margin: 30px 0;">
+			<a href="%s" style="background-color: #007bff; color: #ffffff; padding: 12px 30px; text-decoration: none; border-radius: 5px; font-size: 16px;">Record KPI values</a>
+		</div>
+	</div>
+</body>
+</html>`, html.EscapeString(cycleName), htmlItems.String(), link),
+		TextBody: fmt.Sprintf("KPI values are overdue\n\nThe following KPIs you own in %s are missing values for reporting periods that have ended:\n\n%s\nRecord KPI values:\n%s\n",
+			cycleName, textItems.String(), link),
+	})
+	if err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("Reminder: %s is missing a value for %s", statuses[0].Name, statuses[0].MissedPeriods[len(statuses[0].MissedPeriods)-1])
+	if len(statuses) > 1 {
+		message = fmt.Sprintf("Reminder: %d of your KPIs are missing values in %s", len(statuses), cycleName)
+	}
+	if err := svc.notifSVC.CreateNotifications([]companylib.Notification{{
+		UserName: owner,
+		Type:     companylib.NotificationTypeKPIValueDue,
+		Message:  message,
+	}}); err != nil {
+		svc.logger.Printf("Failed to create KPI reminder notification for %s: %v", owner, err)
+	}
+	return nil
+}
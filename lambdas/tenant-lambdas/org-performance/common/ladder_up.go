@@ -0,0 +1,161 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	companylib "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/company-lib"
+)
+
+// ladderUpErrorResponse maps ladder-up errors to their HTTP status
+func (svc *Service) ladderUpErrorResponse(message string, err error) (events.APIGatewayProxyResponse, error) {
+	switch {
+	case errors.Is(err, companylib.ErrLadderUpInvalid):
+		return svc.errorResponse(http.StatusBadRequest, message, err)
+	case errors.Is(err, companylib.ErrLadderUpNotFound):
+		return svc.errorResponse(http.StatusNotFound, message, err)
+	case errors.Is(err, companylib.ErrLadderUpConflict):
+		return svc.errorResponse(http.StatusConflict, message, err)
+	default:
+		return svc.errorResponse(http.StatusInternalServerError, message, err)
+	}
+}
+
+// ensureOrgWritable rejects writes to a suspended organization for callers that are not checked
+// through ensureOrgAdmin
+func (svc *Service) ensureOrgWritable(orgID string, method string) error {
+	if companylib.IsWriteMethod(method) {
+		return svc.orgSVC.EnsureOrgWritable(orgID)
+	}
+	return nil
+}
+
+// ensureLadderUpSubmitter lets active members of the team, and org admins, propose the team's
+// contribution to an org goal of their organization
+func (svc *Service) ensureLadderUpSubmitter(orgID string, teamID string, userName string, method string) error {
+	team, err := svc.teamsSVC.GetTeamMetadata(teamID)
+	if err != nil {
+		return err
+	}
+	if strings.TrimPrefix(team.OrgId, "ORG#") != strings.TrimPrefix(orgID, "ORG#") {
+		return fmt.Errorf("team %s does not belong to the organization", teamID)
+	}
+	member, err := svc.teamsSVC.GetTeamMemberDetails(teamID, userName)
+	if err != nil {
+		return err
+	}
+	if member == nil || !member.IsActive || member.Role == companylib.TeamMemberRoleGuest {
+		return svc.ensureOrgAdmin(orgID, userName, method)
+	}
+	return svc.ensureOrgWritable(orgID, method)
+}
+
+// ensureLadderUpApprover lets the org goal's owner, as well as org admins, decide on a ladder-up.
+// Nobody decides on a ladder-up they submitted.
+func (svc *Service) ensureLadderUpApprover(goal map[string]interface{}, ladderUp map[string]interface{}, userName string, method string) error {
+	if strings.EqualFold(toString(ladderUp["submittedBy"]), userName) {
+		return fmt.Errorf("a ladder-up cannot be decided by its submitter")
+	}
+	orgID := toString(goal["organizationId"])
+	if !strings.EqualFold(toString(goal["owner"]), userName) {
+		return svc.ensureOrgAdmin(orgID, userName, method)
+	}
+	return svc.ensureOrgWritable(orgID, method)
+}
+
+// ensureLadderUpViewer lets the submitter and the approvers of a ladder-up read its history
+func (svc *Service) ensureLadderUpViewer(goal map[string]interface{}, ladderUp map[string]interface{}, userName string) error {
+	if strings.EqualFold(toString(ladderUp["submittedBy"]), userName) || strings.EqualFold(toString(goal["owner"]), userName) {
+		return nil
+	}
+	return svc.ensureOrgAdmin(toString(goal["organizationId"]), userName, http.MethodGet)
+}
+
+// ensureMemberGoal checks that a goal linked to a ladder-up is one of the submitter's goals in the team
+func (svc *Service) ensureMemberGoal(userName string, teamID string, userGoalID string) error {
+	if svc.perfHubTable == "" {
+		return fmt.Errorf("PERF_HUB_TABLE is not configured")
+	}
+	out, err := svc.ddb.GetItem(svc.ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(svc.perfHubTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: fmt.Sprintf("USER#%s#TEAM#%s", userName, teamID)},
+			"SK": &types.AttributeValueMemberS{Value: "GOAL#" + userGoalID},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read goal %s: %w", userGoalID, err)
+	}
+	if out.Item == nil {
+		return fmt.Errorf("%w: goal %s is not one of your goals in team %s", companylib.ErrLadderUpInvalid, userGoalID, teamID)
+	}
+	return nil
+}
+
+// notifyLadderUpSubmitted tells the org goal's owner that a ladder-up is waiting for a decision.
+// Goals without an owner are left to org admins, who see pending ladder-ups on the goal.
+func (svc *Service) notifyLadderUpSubmitted(goal map[string]interface{}, ladderUp map[string]interface{}) {
+	owner := toString(goal["owner"])
+	submitter := toString(ladderUp["submittedBy"])
+	if owner == "" || strings.EqualFold(owner, submitter) {
+		return
+	}
+	verb := "proposed"
+	if toString(ladderUp["status"]) == companylib.LadderUpStatusResubmitted {
+		verb = "resubmitted"
+	}
+	svc.createLadderUpNotification(companylib.Notification{
+		UserName:      owner,
+		ActorUserName: submitter,
+		Type:          companylib.NotificationTypeLadderUpSubmitted,
+		TeamId:        toString(ladderUp["teamId"]),
+		Message:       fmt.Sprintf("%s %s \"%s\" as a contribution to %s", submitter, verb, toString(ladderUp["title"]), toString(goal["name"])),
+	})
+}
+
+// notifyLadderUpDecision tells the submitter whether their ladder-up was approved or rejected
+func (svc *Service) notifyLadderUpDecision(goal map[string]interface{}, ladderUp map[string]interface{}) {
+	notification := companylib.Notification{
+		UserName:      toString(ladderUp["submittedBy"]),
+		ActorUserName: toString(ladderUp["decidedBy"]),
+		Type:          companylib.NotificationTypeLadderUpApproved,
+		TeamId:        toString(ladderUp["teamId"]),
+		Message:       fmt.Sprintf("\"%s\" was approved as a contribution to %s", toString(ladderUp["title"]), toString(goal["name"])),
+	}
+	if toString(ladderUp["status"]) == companylib.LadderUpStatusRejected {
+		notification.Type = companylib.NotificationTypeLadderUpRejected
+		notification.Message = fmt.Sprintf("\"%s\" was not approved for %s: %s", toString(ladderUp["title"]), toString(goal["name"]), toString(ladderUp["rejectionReason"]))
+	}
+	if notification.UserName == "" {
+		return
+	}
+	svc.createLadderUpNotification(notification)
+}
+
+func (svc *Service) createLadderUpNotification(notification companylib.Notification) {
+	if err := svc.notifSVC.CreateNotifications([]companylib.Notification{notification}); err != nil {
+		svc.logger.Printf("Failed to notify %s about a ladder-up: %v", notification.UserName, err)
+	}
+}
+
+// loadLadderUp reads a ladder-up with the org goal it contributes to
+func (svc *Service) loadLadderUp(ladderUpID string, userName string) (map[string]interface{}, map[string]interface{}, *events.APIGatewayProxyResponse) {
+	ladderUp, err := svc.perfSVC.GetLadderUp(ladderUpID)
+	if err != nil {
+		resp, _ := svc.ladderUpErrorResponse("Failed to get ladder-up", err)
+		return nil, nil, &resp
+	}
+	base, err := svc.perfSVC.GetGoalDetails(toString(ladderUp["goalId"]), false, false, false, false, false, userName)
+	if err != nil {
+		resp, _ := svc.errorResponse(http.StatusNotFound, "Goal not found", err)
+		return nil, nil, &resp
+	}
+	return ladderUp, base, nil
+}
@@ -0,0 +1,18 @@
+package common
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	companylib "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/company-lib"
+)
+
+// perfDataErrorResponse answers a failed update, where a body the record's schema rejects is a 400
+func (svc *Service) perfDataErrorResponse(message string, err error) (events.APIGatewayProxyResponse, error) {
+	if errors.Is(err, companylib.ErrPerfDataInvalid) {
+		return svc.errorResponse(http.StatusBadRequest, message, err)
+	}
+	return svc.errorResponse(http.StatusInternalServerError, message, err)
+}
@@ -0,0 +1,93 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	companylib "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/company-lib"
+)
+
+// reviewParticipants lists the active, non-guest members of the organization's teams, or of the
+// given teams when teamIDs is set, with the SKILL and VALUE attributes of their team
+func (svc *Service) reviewParticipants(orgID string, teamIDs []string) ([]companylib.ReviewParticipant, error) {
+	teams, err := svc.teamsSVC.GetOrganizationTeams(orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := map[string]bool{}
+	for _, id := range teamIDs {
+		selected[id] = true
+	}
+	found := map[string]bool{}
+
+	participants := []companylib.ReviewParticipant{}
+	for _, team := range teams {
+		if len(selected) > 0 && !selected[team.TeamId] {
+			continue
+		}
+		found[team.TeamId] = true
+		if team.Status == companylib.TeamStatusInactive {
+			continue
+		}
+
+		attributes, err := svc.attributeSVC.ListTeamAttributes(team.TeamId, nil)
+		if err != nil {
+			return nil, err
+		}
+		competencies := companylib.ReviewCompetenciesFromAttributes(attributes)
+
+		members, err := svc.teamsSVC.GetTeamMembers(team.TeamId)
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			if !member.IsActive || member.Role == companylib.TeamMemberRoleGuest {
+				continue
+			}
+			participants = append(participants, companylib.ReviewParticipant{
+				TeamID:         team.TeamId,
+				MemberUserName: member.UserName,
+				MemberName:     member.DisplayName,
+				Competencies:   competencies,
+			})
+		}
+	}
+
+	for _, id := range teamIDs {
+		if !found[id] {
+			return nil, fmt.Errorf("team %s is not part of this organization", id)
+		}
+	}
+	return participants, nil
+}
+
+// reviewErrorResponse maps the review workflow errors to their HTTP status
+func (svc *Service) reviewErrorResponse(message string, err error) (events.APIGatewayProxyResponse, error) {
+	switch {
+	case errors.Is(err, companylib.ErrReviewNotFound):
+		return svc.errorResponse(http.StatusNotFound, "Review not found", err)
+	case errors.Is(err, companylib.ErrReviewForbidden):
+		return svc.errorResponse(http.StatusForbidden, "Access denied", err)
+	case errors.Is(err, companylib.ErrReviewLocked), errors.Is(err, companylib.ErrReviewStage), errors.Is(err, companylib.ErrReviewConflict):
+		return svc.errorResponse(http.StatusConflict, message, err)
+	case errors.Is(err, companylib.ErrReviewWindowClosed), errors.Is(err, companylib.ErrReviewInvalid):
+		return svc.errorResponse(http.StatusBadRequest, message, err)
+	}
+	return svc.errorResponse(http.StatusInternalServerError, message, err)
+}
+
+func toStringSlice(v interface{}) []string {
+	items, _ := v.([]interface{})
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		if s := strings.TrimSpace(toString(item)); s != "" {
+			values = append(values, s)
+		}
+	}
+	return values
+}
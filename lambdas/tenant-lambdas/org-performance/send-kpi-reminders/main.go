@@ -0,0 +1,19 @@
+package main
+
+import (
+	"log"
+
+	"github.com/aws/aws-lambda-go/lambda"
+
+	common "github.com/busyfit-admin/saas-integrated-apis/lambdas/tenant-lambdas/org-performance/common"
+)
+
+// Runs on an EventBridge schedule, reminds KPI owners of reporting periods missing a value
+func main() {
+	svc, err := common.NewService()
+	if err != nil {
+		log.Fatalf("failed to initialize send-kpi-reminders service: %v", err)
+	}
+
+	lambda.Start(svc.HandleKPIReminderSchedule)
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"log"
+
+	"github.com/aws/aws-lambda-go/lambda"
+
+	common "github.com/busyfit-admin/saas-integrated-apis/lambdas/tenant-lambdas/team-feeds/common"
+)
+
+// Invoked by hand to index posts and comments written before the feed search index existed
+func main() {
+	svc, err := common.NewService()
+	if err != nil {
+		log.Fatalf("failed to initialize team-feeds service: %v", err)
+	}
+
+	lambda.Start(svc.HandleSearchBackfill)
+}
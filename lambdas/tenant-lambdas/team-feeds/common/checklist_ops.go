@@ -17,6 +17,8 @@ import (
 // PATCH  /v2/posts/{postId}/checklist/items/{itemId}
 // POST   /v2/posts/{postId}/checklist/items
 // DELETE /v2/posts/{postId}/checklist/items/{itemId}
+// GET    /v2/posts/{postId}/checklist/history?cursor=&limit=
+// GET    /v2/posts/{postId}/checklist/stats
 
 func (svc *Service) handleChecklist(request events.APIGatewayProxyRequest, parts []string, userName, cognitoID string) (events.APIGatewayProxyResponse, error) {
 	// /v2/posts/{postId}/checklist/items/{itemId}  (6 parts: v2, posts, {postId}, checklist, items, {itemId})
@@ -39,6 +41,25 @@ func (svc *Service) handleChecklist(request events.APIGatewayProxyRequest, parts
 		}
 	}
 
+	// /v2/posts/{postId}/checklist/history|stats  (5 parts)
+	if len(parts) == 5 && parts[1] == "posts" && parts[3] == "checklist" && request.HTTPMethod == "GET" &&
+		(parts[4] == "history" || parts[4] == "stats") {
+		post, err := svc.fetchPostRecord(parts[2])
+		if err != nil {
+			return svc.errResp(http.StatusNotFound, "NOT_FOUND", "Post not found")
+		}
+		if post.Type != string(PostTypeChecklist) {
+			return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "This post is not a checklist")
+		}
+		if err := svc.ensureTeamMember(post.TeamID, userName); err != nil {
+			return svc.errResp(http.StatusForbidden, "FORBIDDEN", "You are not a member of this team")
+		}
+		if parts[4] == "history" {
+			return svc.getChecklistHistory(post, request.QueryStringParameters)
+		}
+		return svc.getChecklistStats(post)
+	}
+
 	return svc.errResp(http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed")
 }
 
@@ -54,9 +75,15 @@ func (svc *Service) toggleChecklistItem(postID, itemID, userName, body string) (
 		return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
 	}
 
-	completedVal := "false"
+	// completedBy/completedAt are kept for recurring checklist history
+	updateExpr := "SET completed = :completed REMOVE completedBy, completedAt"
+	values := map[string]types.AttributeValue{
+		":completed": &types.AttributeValueMemberBOOL{Value: req.Completed},
+	}
 	if req.Completed {
-		completedVal = "true"
+		updateExpr = "SET completed = :completed, completedBy = :by, completedAt = :at"
+		values[":by"] = &types.AttributeValueMemberS{Value: userName}
+		values[":at"] = &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)}
 	}
 
 	_, err = svc.ddb.UpdateItem(svc.ctx, &dynamodb.UpdateItemInput{
@@ -65,11 +92,9 @@ func (svc *Service) toggleChecklistItem(postID, itemID, userName, body string) (
 			"PK": &types.AttributeValueMemberS{Value: PrefixPost + postID},
 			"SK": &types.AttributeValueMemberS{Value: SKItemPrefix + itemID},
 		},
-		UpdateExpression: aws.String("SET completed = :completed"),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":completed": &types.AttributeValueMemberBOOL{Value: completedVal == "true"},
-		},
-		ConditionExpression: aws.String("attribute_exists(PK)"),
+		UpdateExpression:          aws.String(updateExpr),
+		ExpressionAttributeValues: values,
+		ConditionExpression:       aws.String("attribute_exists(PK)"),
 	})
 	if err != nil {
 		return svc.errResp(http.StatusNotFound, "NOT_FOUND", "Checklist item not found")
@@ -0,0 +1,538 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ==================== Recurring Checklists ====================
+//
+// A recurring checklist is reset in place at the end of each period: its items are snapshotted
+// into a RUN#{periodEnd} record under the post, then every completed item is un-ticked. Periods
+// end at 00:00 UTC — daily, on Mondays (Weekly), every second Monday (Bi-Weekly) or on the 1st
+// of the month (Monthly).
+//
+// While a checklist is recurring its post carries scheduleKey/nextResetAt, which puts it on the
+// sparse ChecklistScheduleIndex the scheduled reset Lambda reads.
+
+const (
+	checklistScheduleIndex = "ChecklistScheduleIndex"
+	checklistScheduleKey   = "RECURRING_CHECKLIST"
+)
+
+const (
+	FrequencyDaily    = "Daily"
+	FrequencyWeekly   = "Weekly"
+	FrequencyBiWeekly = "Bi-Weekly"
+	FrequencyMonthly  = "Monthly"
+)
+
+var recurringFrequencies = []string{FrequencyDaily, FrequencyWeekly, FrequencyBiWeekly, FrequencyMonthly}
+
+// normaliseRecurringFrequency matches f case-insensitively against the supported frequencies
+func normaliseRecurringFrequency(f string) (string, bool) {
+	for _, freq := range recurringFrequencies {
+		if strings.EqualFold(strings.TrimSpace(f), freq) {
+			return freq, true
+		}
+	}
+	return "", false
+}
+
+// validateRecurrence checks the recurrence fields of a checklist request and returns the
+// canonical frequency ("" when the checklist is not recurring)
+func validateRecurrence(isRecurring bool, frequency string) (string, error) {
+	if !isRecurring {
+		return "", nil
+	}
+	freq, ok := normaliseRecurringFrequency(frequency)
+	if !ok {
+		return "", fmt.Errorf("recurringFrequency must be one of %s", strings.Join(recurringFrequencies, ", "))
+	}
+	return freq, nil
+}
+
+// nextChecklistReset returns the first period boundary strictly after t
+func nextChecklistReset(frequency string, t time.Time) time.Time {
+	t = t.UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch frequency {
+	case FrequencyWeekly, FrequencyBiWeekly:
+		days := (8 - int(midnight.Weekday())) % 7
+		if days == 0 {
+			days = 7
+		}
+		next := midnight.AddDate(0, 0, days)
+		if frequency == FrequencyBiWeekly {
+			next = next.AddDate(0, 0, 7)
+		}
+		return next
+	case FrequencyMonthly:
+		return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return midnight.AddDate(0, 0, 1)
+	}
+}
+
+// applyChecklistSchedule keeps a post's schedule fields in step with its recurrence settings.
+// A checklist that becomes recurring starts its first period now; otherwise the next reset is
+// derived from the start of the current period, so edits do not shift it.
+func applyChecklistSchedule(r *PostRecord, now time.Time) {
+	if PostType(r.Type) != PostTypeChecklist || !r.Data.IsRecurring {
+		r.ScheduleKey, r.NextResetAt, r.LastResetAt = "", "", ""
+		return
+	}
+
+	periodStart, err := time.Parse(time.RFC3339, r.LastResetAt)
+	if r.ScheduleKey == "" || err != nil {
+		periodStart = now.UTC()
+		r.LastResetAt = periodStart.Format(time.RFC3339)
+	}
+	r.ScheduleKey = checklistScheduleKey
+	r.NextResetAt = nextChecklistReset(r.Data.RecurringFrequency, periodStart).Format(time.RFC3339)
+}
+
+// ==================== Scheduled Reset ====================
+
+// ChecklistResetSummary is returned by the scheduled reset run and written to the logs
+type ChecklistResetSummary struct {
+	RunAt  string `json:"runAt"`
+	Due    int    `json:"due"`
+	Reset  int    `json:"reset"`
+	Failed int    `json:"failed"`
+}
+
+// HandleChecklistSchedule resets every recurring checklist whose period has ended. Each reset is
+// safe to repeat, so a checklist that fails is simply picked up again by the next run.
+func (svc *Service) HandleChecklistSchedule(ctx context.Context, event events.CloudWatchEvent) (ChecklistResetSummary, error) {
+	now := time.Now().UTC()
+	summary := ChecklistResetSummary{RunAt: now.Format(time.RFC3339)}
+
+	items, err := svc.queryAll(&dynamodb.QueryInput{
+		TableName:              aws.String(svc.feedTable),
+		IndexName:              aws.String(checklistScheduleIndex),
+		KeyConditionExpression: aws.String("scheduleKey = :key AND nextResetAt <= :now"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":key": &types.AttributeValueMemberS{Value: checklistScheduleKey},
+			":now": &types.AttributeValueMemberS{Value: summary.RunAt},
+		},
+	})
+	if err != nil {
+		return summary, fmt.Errorf("failed to query due checklists: %w", err)
+	}
+
+	var posts []PostRecord
+	if err := attributevalue.UnmarshalListOfMaps(items, &posts); err != nil {
+		return summary, fmt.Errorf("failed to unmarshal due checklists: %w", err)
+	}
+	summary.Due = len(posts)
+
+	for _, post := range posts {
+		if err := svc.resetRecurringChecklist(post, now); err != nil {
+			svc.logger.Printf("Failed to reset checklist %s: %v", post.PostID, err)
+			summary.Failed++
+			continue
+		}
+		summary.Reset++
+	}
+
+	svc.logger.Printf("Recurring checklist run complete: %+v", summary)
+	return summary, nil
+}
+
+// resetRecurringChecklist records the period that ended at post.NextResetAt, un-ticks the
+// completed items and moves the schedule on. The run record is written only once per period, so
+// a retry after a partial failure finishes the reset without duplicating history.
+func (svc *Service) resetRecurringChecklist(post PostRecord, now time.Time) error {
+	items, err := svc.fetchChecklistItems(post.PostID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch checklist items: %w", err)
+	}
+
+	run := checklistRunSnapshot(post, items, now)
+	av, err := attributevalue.MarshalMap(run)
+	if err != nil {
+		return fmt.Errorf("failed to marshal checklist run: %w", err)
+	}
+	_, err = svc.ddb.PutItem(svc.ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(svc.feedTable),
+		Item:                av,
+		ConditionExpression: aws.String("attribute_not_exists(PK)"),
+	})
+	var ccf *types.ConditionalCheckFailedException
+	if err != nil && !errors.As(err, &ccf) {
+		return fmt.Errorf("failed to write checklist run: %w", err)
+	}
+
+	for _, item := range items {
+		if !item.Completed {
+			continue
+		}
+		_, err := svc.ddb.UpdateItem(svc.ctx, &dynamodb.UpdateItemInput{
+			TableName: aws.String(svc.feedTable),
+			Key: map[string]types.AttributeValue{
+				"PK": &types.AttributeValueMemberS{Value: item.PK},
+				"SK": &types.AttributeValueMemberS{Value: item.SK},
+			},
+			UpdateExpression:    aws.String("SET completed = :false REMOVE completedBy, completedAt"),
+			ConditionExpression: aws.String("attribute_exists(PK)"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":false": &types.AttributeValueMemberBOOL{Value: false},
+			},
+		})
+		if err != nil && !errors.As(err, &ccf) {
+			return fmt.Errorf("failed to reset checklist item %s: %w", item.ItemID, err)
+		}
+	}
+
+	// Periods missed while the scheduler was down collapse into the run above
+	next := nextChecklistReset(post.Data.RecurringFrequency, now)
+	_, err = svc.ddb.UpdateItem(svc.ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(svc.feedTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: PrefixPost + post.PostID},
+			"SK": &types.AttributeValueMemberS{Value: SKMetadata},
+		},
+		UpdateExpression:    aws.String("SET lastResetAt = :last, nextResetAt = :next"),
+		ConditionExpression: aws.String("nextResetAt = :due"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":last": &types.AttributeValueMemberS{Value: post.NextResetAt},
+			":next": &types.AttributeValueMemberS{Value: next.Format(time.RFC3339)},
+			":due":  &types.AttributeValueMemberS{Value: post.NextResetAt},
+		},
+	})
+	if err != nil && !errors.As(err, &ccf) {
+		return fmt.Errorf("failed to advance checklist schedule: %w", err)
+	}
+	return nil
+}
+
+// checklistRunSnapshot builds the run record for the period ending at post.NextResetAt
+func checklistRunSnapshot(post PostRecord, items []ChecklistItemRecord, now time.Time) ChecklistRunRecord {
+	sortChecklistItems(items)
+
+	run := ChecklistRunRecord{
+		PK:          PrefixPost + post.PostID,
+		SK:          SKRunPrefix + post.NextResetAt,
+		PostID:      post.PostID,
+		TeamID:      post.TeamID,
+		Frequency:   post.Data.RecurringFrequency,
+		PeriodStart: post.LastResetAt,
+		PeriodEnd:   post.NextResetAt,
+		TotalCount:  len(items),
+		Items:       make([]ChecklistRunItem, 0, len(items)),
+		RecordedAt:  now.UTC().Format(time.RFC3339),
+	}
+	if run.PeriodStart == "" {
+		run.PeriodStart = post.CreatedAt
+	}
+	for _, item := range items {
+		run.Items = append(run.Items, ChecklistRunItem{
+			ItemID:      item.ItemID,
+			Text:        item.Text,
+			Completed:   item.Completed,
+			CompletedBy: item.CompletedBy,
+			CompletedAt: item.CompletedAt,
+		})
+		if item.Completed {
+			run.CompletedCount++
+		}
+	}
+	run.CompletionRate = completionRate(run.CompletedCount, run.TotalCount)
+	return run
+}
+
+// completionRate returns completed/total as a percentage rounded to one decimal place
+func completionRate(completed, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(completed)*1000/float64(total)) / 10
+}
+
+// ==================== Schedule Backfill ====================
+//
+// Checklists made recurring before the scheduled reset existed have no schedule fields, so the
+// reset Lambda never sees them. The backfill job scans for them and starts their first period at
+// the time of the run, as if they had just been made recurring. Like the search backfill it stops
+// before the Lambda times out; invoke it again with {"cursor": ...} until "done" is true.
+
+type ChecklistScheduleBackfillRequest struct {
+	Cursor string `json:"cursor"`
+}
+
+type ChecklistScheduleBackfillSummary struct {
+	Scheduled  int    `json:"scheduled"`
+	Skipped    int    `json:"skipped"` // Checklists changed since the scan, or with an unknown frequency
+	NextCursor string `json:"nextCursor,omitempty"`
+	Done       bool   `json:"done"`
+}
+
+// HandleChecklistScheduleBackfill schedules unscheduled recurring checklists from cursor onwards.
+// A failure stops the run and the error names the cursor to resume from.
+func (svc *Service) HandleChecklistScheduleBackfill(ctx context.Context, req ChecklistScheduleBackfillRequest) (ChecklistScheduleBackfillSummary, error) {
+	summary := ChecklistScheduleBackfillSummary{}
+
+	startKey, err := decodeCursor(req.Cursor, "", "")
+	if err != nil {
+		return summary, err
+	}
+
+	input := &dynamodb.ScanInput{
+		TableName:        aws.String(svc.feedTable),
+		FilterExpression: aws.String("SK = :metadata AND #type = :checklist AND #data.isRecurring = :true AND attribute_not_exists(scheduleKey)"),
+		ExpressionAttributeNames: map[string]string{
+			"#type": "type",
+			"#data": "data",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":metadata":  &types.AttributeValueMemberS{Value: SKMetadata},
+			":checklist": &types.AttributeValueMemberS{Value: string(PostTypeChecklist)},
+			":true":      &types.AttributeValueMemberBOOL{Value: true},
+		},
+		Limit:             aws.Int32(searchBackfillPageSize),
+		ExclusiveStartKey: startKey,
+	}
+
+	for {
+		cursor, err := encodeCursor(input.ExclusiveStartKey)
+		if err != nil {
+			return summary, err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < searchBackfillMargin {
+			summary.NextCursor = cursor
+			break
+		}
+
+		result, err := svc.ddb.Scan(ctx, input)
+		if err != nil {
+			return summary, fmt.Errorf("failed to scan feed table, resume from cursor %q: %w", cursor, err)
+		}
+		var posts []PostRecord
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &posts); err != nil {
+			return summary, fmt.Errorf("failed to unmarshal checklists, resume from cursor %q: %w", cursor, err)
+		}
+		for _, post := range posts {
+			scheduled, err := svc.scheduleLegacyChecklist(post, time.Now())
+			if err != nil {
+				return summary, fmt.Errorf("resume from cursor %q: %w", cursor, err)
+			}
+			if scheduled {
+				summary.Scheduled++
+			} else {
+				summary.Skipped++
+			}
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			summary.Done = true
+			break
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	svc.logger.Printf("Checklist schedule backfill run complete: %+v", summary)
+	return summary, nil
+}
+
+// scheduleLegacyChecklist sets the schedule fields of a recurring checklist that has none. It
+// reports false when the checklist was unscheduled or scheduled by an edit since it was read.
+func (svc *Service) scheduleLegacyChecklist(post PostRecord, now time.Time) (bool, error) {
+	freq, ok := normaliseRecurringFrequency(post.Data.RecurringFrequency)
+	if !ok {
+		svc.logger.Printf("Skipping checklist %s: unknown recurringFrequency %q", post.PostID, post.Data.RecurringFrequency)
+		return false, nil
+	}
+	post.Data.RecurringFrequency = freq
+	post.ScheduleKey, post.LastResetAt = "", ""
+	applyChecklistSchedule(&post, now)
+
+	_, err := svc.ddb.UpdateItem(svc.ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(svc.feedTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: PrefixPost + post.PostID},
+			"SK": &types.AttributeValueMemberS{Value: SKMetadata},
+		},
+		UpdateExpression:    aws.String("SET scheduleKey = :key, nextResetAt = :next, lastResetAt = :last, #data.recurringFrequency = :freq"),
+		ConditionExpression: aws.String("attribute_exists(PK) AND attribute_not_exists(scheduleKey) AND #data.isRecurring = :true"),
+		ExpressionAttributeNames: map[string]string{
+			"#data": "data",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":key":  &types.AttributeValueMemberS{Value: post.ScheduleKey},
+			":next": &types.AttributeValueMemberS{Value: post.NextResetAt},
+			":last": &types.AttributeValueMemberS{Value: post.LastResetAt},
+			":freq": &types.AttributeValueMemberS{Value: freq},
+			":true": &types.AttributeValueMemberBOOL{Value: true},
+		},
+	})
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to schedule checklist %s: %w", post.PostID, err)
+	}
+	return true, nil
+}
+
+// ==================== History & Stats ====================
+
+// ChecklistStats summarises the recorded runs of a recurring checklist
+type ChecklistStats struct {
+	RunCount              int                  `json:"runCount"`
+	AverageCompletionRate float64              `json:"averageCompletionRate"`
+	FullyCompletedRuns    int                  `json:"fullyCompletedRuns"`
+	CurrentStreak         int                  `json:"currentStreak"`
+	LastRun               *ChecklistRunRecord  `json:"lastRun"`
+	Items                 []ChecklistItemStats `json:"items"`
+}
+
+// ChecklistItemStats is how often one item was completed across the runs it appeared in
+type ChecklistItemStats struct {
+	ItemID         string  `json:"itemId"`
+	Text           string  `json:"text"`
+	Runs           int     `json:"runs"`
+	CompletedRuns  int     `json:"completedRuns"`
+	CompletionRate float64 `json:"completionRate"`
+}
+
+// checklistRunStats aggregates runs, which must be ordered oldest first. CurrentStreak counts the
+// most recent consecutive runs with every item completed.
+func checklistRunStats(runs []ChecklistRunRecord) ChecklistStats {
+	stats := ChecklistStats{RunCount: len(runs), Items: []ChecklistItemStats{}}
+	if len(runs) == 0 {
+		return stats
+	}
+
+	itemIndex := make(map[string]int)
+	var rateSum float64
+	for _, run := range runs {
+		rateSum += run.CompletionRate
+		fullyCompleted := run.TotalCount > 0 && run.CompletedCount == run.TotalCount
+		if fullyCompleted {
+			stats.FullyCompletedRuns++
+			stats.CurrentStreak++
+		} else {
+			stats.CurrentStreak = 0
+		}
+
+		for _, item := range run.Items {
+			i, ok := itemIndex[item.ItemID]
+			if !ok {
+				i = len(stats.Items)
+				itemIndex[item.ItemID] = i
+				stats.Items = append(stats.Items, ChecklistItemStats{ItemID: item.ItemID})
+			}
+			stats.Items[i].Text = item.Text
+			stats.Items[i].Runs++
+			if item.Completed {
+				stats.Items[i].CompletedRuns++
+			}
+		}
+	}
+
+	for i := range stats.Items {
+		stats.Items[i].CompletionRate = completionRate(stats.Items[i].CompletedRuns, stats.Items[i].Runs)
+	}
+	stats.AverageCompletionRate = math.Round(rateSum*10/float64(len(runs))) / 10
+	last := runs[len(runs)-1]
+	stats.LastRun = &last
+	return stats
+}
+
+func (svc *Service) getChecklistHistory(post *PostRecord, queryParams map[string]string) (events.APIGatewayProxyResponse, error) {
+	limit := pageLimit(queryParams, defaultPageLimit)
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(svc.feedTable),
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :prefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":     &types.AttributeValueMemberS{Value: PrefixPost + post.PostID},
+			":prefix": &types.AttributeValueMemberS{Value: SKRunPrefix},
+		},
+		ScanIndexForward: aws.Bool(false),
+	}
+
+	items, nextCursor, err := svc.queryPage(pageQuery{
+		input:          input,
+		keyAttrs:       tableKeyAttrs,
+		partitionAttr:  "PK",
+		partitionValue: PrefixPost + post.PostID,
+	}, limit, queryString(queryParams, "cursor"))
+	if err != nil {
+		if errors.Is(err, errInvalidCursor) {
+			return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "Invalid cursor")
+		}
+		svc.logger.Printf("Error querying checklist runs: %v", err)
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to fetch checklist history")
+	}
+
+	runs := make([]ChecklistRunRecord, 0, len(items))
+	if err := attributevalue.UnmarshalListOfMaps(items, &runs); err != nil {
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to parse checklist history")
+	}
+	total, err := svc.queryCount(input)
+	if err != nil {
+		svc.logger.Printf("Error counting checklist runs: %v", err)
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to fetch checklist history")
+	}
+
+	return svc.okResp(runs, &MetaResponse{Total: &total, Count: len(runs), Limit: limit, NextCursor: nextCursor})
+}
+
+func (svc *Service) getChecklistStats(post *PostRecord) (events.APIGatewayProxyResponse, error) {
+	items, err := svc.queryAll(&dynamodb.QueryInput{
+		TableName:              aws.String(svc.feedTable),
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :prefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":     &types.AttributeValueMemberS{Value: PrefixPost + post.PostID},
+			":prefix": &types.AttributeValueMemberS{Value: SKRunPrefix},
+		},
+	})
+	if err != nil {
+		svc.logger.Printf("Error querying checklist runs: %v", err)
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to fetch checklist stats")
+	}
+
+	var runs []ChecklistRunRecord
+	if err := attributevalue.UnmarshalListOfMaps(items, &runs); err != nil {
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to parse checklist history")
+	}
+
+	checklistItems, err := svc.fetchChecklistItems(post.PostID)
+	if err != nil {
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to fetch checklist items")
+	}
+	completed := 0
+	for _, item := range checklistItems {
+		if item.Completed {
+			completed++
+		}
+	}
+
+	return svc.okResp(map[string]interface{}{
+		"postId":             post.PostID,
+		"isRecurring":        post.Data.IsRecurring,
+		"recurringFrequency": post.Data.RecurringFrequency,
+		"stats":              checklistRunStats(runs),
+		"currentPeriod": map[string]interface{}{
+			"periodStart":    nilIfEmpty(post.LastResetAt),
+			"nextResetAt":    nilIfEmpty(post.NextResetAt),
+			"completedCount": completed,
+			"totalCount":     len(checklistItems),
+			"completionRate": completionRate(completed, len(checklistItems)),
+		},
+	}, nil)
+}
@@ -0,0 +1,146 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextChecklistReset(t *testing.T) {
+	// Wednesday
+	wed := time.Date(2024, 5, 15, 13, 30, 0, 0, time.UTC)
+	monday := time.Date(2024, 5, 20, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		frequency string
+		from      time.Time
+		expected  time.Time
+	}{
+		{name: "It should reset daily checklists at the next midnight", frequency: FrequencyDaily, from: wed, expected: time.Date(2024, 5, 16, 0, 0, 0, 0, time.UTC)},
+		{name: "It should reset weekly checklists on the next Monday", frequency: FrequencyWeekly, from: wed, expected: monday},
+		{name: "It should move a week on when starting on a Monday boundary", frequency: FrequencyWeekly, from: monday, expected: monday.AddDate(0, 0, 7)},
+		{name: "It should reset bi-weekly checklists on the second Monday", frequency: FrequencyBiWeekly, from: wed, expected: monday.AddDate(0, 0, 7)},
+		{name: "It should keep bi-weekly periods at 14 days once aligned", frequency: FrequencyBiWeekly, from: monday, expected: monday.AddDate(0, 0, 14)},
+		{name: "It should reset monthly checklists on the 1st", frequency: FrequencyMonthly, from: time.Date(2024, 12, 31, 23, 0, 0, 0, time.UTC), expected: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, nextChecklistReset(test.frequency, test.from))
+		})
+	}
+}
+
+func TestValidateRecurrence(t *testing.T) {
+	freq, err := validateRecurrence(true, "bi-weekly")
+	assert.NoError(t, err)
+	assert.Equal(t, FrequencyBiWeekly, freq)
+
+	_, err = validateRecurrence(true, "Hourly")
+	assert.Error(t, err)
+
+	freq, err = validateRecurrence(false, "Hourly")
+	assert.NoError(t, err)
+	assert.Equal(t, "", freq, "the frequency should be dropped for non-recurring checklists")
+}
+
+func TestApplyChecklistSchedule(t *testing.T) {
+	now := time.Date(2024, 5, 15, 13, 30, 0, 0, time.UTC)
+
+	t.Run("It should start the first period when a checklist becomes recurring", func(t *testing.T) {
+		post := PostRecord{Type: string(PostTypeChecklist), Data: PostData{IsRecurring: true, RecurringFrequency: FrequencyDaily}}
+
+		applyChecklistSchedule(&post, now)
+
+		assert.Equal(t, checklistScheduleKey, post.ScheduleKey)
+		assert.Equal(t, "2024-05-15T13:30:00Z", post.LastResetAt)
+		assert.Equal(t, "2024-05-16T00:00:00Z", post.NextResetAt)
+	})
+
+	t.Run("It should keep the current period when the frequency changes", func(t *testing.T) {
+		post := PostRecord{
+			Type:        string(PostTypeChecklist),
+			ScheduleKey: checklistScheduleKey,
+			LastResetAt: "2024-05-13T00:00:00Z",
+			NextResetAt: "2024-05-14T00:00:00Z",
+			Data:        PostData{IsRecurring: true, RecurringFrequency: FrequencyWeekly},
+		}
+
+		applyChecklistSchedule(&post, now)
+
+		assert.Equal(t, "2024-05-13T00:00:00Z", post.LastResetAt)
+		assert.Equal(t, "2024-05-20T00:00:00Z", post.NextResetAt)
+	})
+
+	t.Run("It should take a checklist off the schedule when it stops recurring", func(t *testing.T) {
+		post := PostRecord{
+			Type:        string(PostTypeChecklist),
+			ScheduleKey: checklistScheduleKey,
+			LastResetAt: "2024-05-13T00:00:00Z",
+			NextResetAt: "2024-05-20T00:00:00Z",
+		}
+
+		applyChecklistSchedule(&post, now)
+
+		assert.Equal(t, "", post.ScheduleKey)
+		assert.Equal(t, "", post.NextResetAt)
+	})
+}
+
+func TestChecklistRunSnapshot(t *testing.T) {
+	post := PostRecord{
+		PostID: "p1", TeamID: "team-1",
+		LastResetAt: "2024-05-13T00:00:00Z", NextResetAt: "2024-05-20T00:00:00Z",
+		Data: PostData{IsRecurring: true, RecurringFrequency: FrequencyWeekly},
+	}
+	items := []ChecklistItemRecord{
+		{ItemID: "i2", Text: "Second", CreatedAt: "2024-05-01T00:00:02Z"},
+		{ItemID: "i1", Text: "First", Completed: true, CompletedBy: "jane@example.com", CreatedAt: "2024-05-01T00:00:01Z"},
+		{ItemID: "i3", Text: "Third", CreatedAt: "2024-05-01T00:00:03Z"},
+	}
+
+	run := checklistRunSnapshot(post, items, time.Date(2024, 5, 20, 0, 5, 0, 0, time.UTC))
+
+	assert.Equal(t, "POST#p1", run.PK)
+	assert.Equal(t, "RUN#2024-05-20T00:00:00Z", run.SK)
+	assert.Equal(t, "2024-05-13T00:00:00Z", run.PeriodStart)
+	assert.Equal(t, 3, run.TotalCount)
+	assert.Equal(t, 1, run.CompletedCount)
+	assert.Equal(t, 33.3, run.CompletionRate)
+	assert.Equal(t, "i1", run.Items[0].ItemID, "items should be recorded in display order")
+	assert.Equal(t, "jane@example.com", run.Items[0].CompletedBy)
+}
+
+func TestChecklistRunStats(t *testing.T) {
+	t.Run("It should return empty stats when there are no runs", func(t *testing.T) {
+		stats := checklistRunStats(nil)
+
+		assert.Equal(t, 0, stats.RunCount)
+		assert.Nil(t, stats.LastRun)
+		assert.Empty(t, stats.Items)
+	})
+
+	t.Run("It should aggregate runs and count the current streak", func(t *testing.T) {
+		runs := []ChecklistRunRecord{
+			{PeriodEnd: "d1", TotalCount: 2, CompletedCount: 2, CompletionRate: 100, Items: []ChecklistRunItem{{ItemID: "a", Text: "A", Completed: true}, {ItemID: "b", Text: "B", Completed: true}}},
+			{PeriodEnd: "d2", TotalCount: 2, CompletedCount: 1, CompletionRate: 50, Items: []ChecklistRunItem{{ItemID: "a", Text: "A", Completed: true}, {ItemID: "b", Text: "B"}}},
+			{PeriodEnd: "d3", TotalCount: 3, CompletedCount: 3, CompletionRate: 100, Items: []ChecklistRunItem{{ItemID: "a", Text: "A2", Completed: true}, {ItemID: "b", Text: "B", Completed: true}, {ItemID: "c", Text: "C", Completed: true}}},
+			{PeriodEnd: "d4", TotalCount: 0, CompletedCount: 0, CompletionRate: 0},
+		}
+
+		stats := checklistRunStats(runs)
+
+		assert.Equal(t, 4, stats.RunCount)
+		assert.Equal(t, 62.5, stats.AverageCompletionRate)
+		assert.Equal(t, 2, stats.FullyCompletedRuns)
+		assert.Equal(t, 0, stats.CurrentStreak, "an empty checklist should break the streak")
+		assert.Equal(t, "d4", stats.LastRun.PeriodEnd)
+		assert.Equal(t, []ChecklistItemStats{
+			{ItemID: "a", Text: "A2", Runs: 3, CompletedRuns: 3, CompletionRate: 100},
+			{ItemID: "b", Text: "B", Runs: 3, CompletedRuns: 2, CompletionRate: 66.7},
+			{ItemID: "c", Text: "C", Runs: 1, CompletedRuns: 1, CompletionRate: 100},
+		}, stats.Items)
+	})
+}
@@ -1,6 +1,7 @@
 package common
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -11,6 +12,8 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/google/uuid"
+
+	companylib "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/company-lib"
 )
 
 // ==================== Route: Comments ====================
@@ -56,30 +59,40 @@ func (svc *Service) handleComments(request events.APIGatewayProxyRequest, parts
 // ==================== List Comments ====================
 
 func (svc *Service) listComments(postID string, queryParams map[string]string) (events.APIGatewayProxyResponse, error) {
-	page := queryInt(queryParams, "page", 1)
-	limit := queryInt(queryParams, "limit", 50)
-
-	records, err := svc.fetchComments(postID, "")
+	limit := pageLimit(queryParams, 50)
+
+	input := commentsQueryInput(svc.feedTable, postID)
+	items, nextCursor, err := svc.queryPage(pageQuery{
+		input:          input,
+		keyAttrs:       tableKeyAttrs,
+		partitionAttr:  "PK",
+		partitionValue: PrefixPost + postID,
+	}, limit, queryString(queryParams, "cursor"))
 	if err != nil {
+		if errors.Is(err, errInvalidCursor) {
+			return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "Invalid cursor")
+		}
+		svc.logger.Printf("Error querying comments: %v", err)
 		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to fetch comments")
 	}
 
-	total := len(records)
-	start := (page - 1) * limit
-	if start > total {
-		start = total
+	var records []CommentRecord
+	if err := attributevalue.UnmarshalListOfMaps(items, &records); err != nil {
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to parse comments")
 	}
-	end := start + limit
-	if end > total {
-		end = total
+
+	total, err := svc.queryCount(input)
+	if err != nil {
+		svc.logger.Printf("Error counting comments: %v", err)
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to fetch comments")
 	}
 
-	response := make([]map[string]interface{}, 0, len(records[start:end]))
-	for _, c := range records[start:end] {
+	response := make([]map[string]interface{}, 0, len(records))
+	for _, c := range records {
 		response = append(response, buildCommentResponse(c))
 	}
 
-	return svc.okResp(response, &MetaResponse{Total: total, Page: page, Limit: limit})
+	return svc.okResp(response, &MetaResponse{Total: &total, Count: len(response), Limit: limit, NextCursor: nextCursor})
 }
 
 // ==================== Add Comment ====================
@@ -95,6 +108,11 @@ func (svc *Service) addComment(postID, userName, cognitoID, body string) (events
 		return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "content is required")
 	}
 
+	mentions, err := svc.resolveMentions(post.TeamID, req.Content, req.Mentions)
+	if err != nil {
+		return svc.mentionErrResp(err)
+	}
+
 	employee, err := svc.empSVC.GetEmployeeDataByCognitoId(cognitoID)
 	if err != nil {
 		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to fetch user data")
@@ -114,6 +132,7 @@ func (svc *Service) addComment(postID, userName, cognitoID, body string) (events
 		AuthorName:      employee.FirstName + " " + employee.LastName,
 		Content:         req.Content,
 		ParentCommentID: req.ParentCommentID,
+		Mentions:        mentions,
 		LikeCount:       0,
 		CreatedAt:       now,
 		UpdatedAt:       now,
@@ -141,6 +160,8 @@ func (svc *Service) addComment(postID, userName, cognitoID, body string) (events
 		},
 	})
 
+	svc.notify(commentNotifications(*post, record, mentions))
+
 	return svc.createdResp(buildCommentResponse(record))
 }
 
@@ -160,6 +181,19 @@ func (svc *Service) editComment(postID, commentID, userName, body string) (event
 		return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "content is required")
 	}
 
+	post, err := svc.fetchPostRecord(postID)
+	if err != nil {
+		return svc.errResp(http.StatusNotFound, "NOT_FOUND", "Post not found")
+	}
+	mentions, err := svc.resolveMentions(post.TeamID, req.Content, req.Mentions)
+	if err != nil {
+		return svc.mentionErrResp(err)
+	}
+	mentionsAV, err := attributevalue.Marshal(mentions)
+	if err != nil {
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to marshal mentions")
+	}
+
 	now := time.Now().UTC().Format(time.RFC3339)
 	_, err = svc.ddb.UpdateItem(svc.ctx, &dynamodb.UpdateItemInput{
 		TableName: aws.String(svc.feedTable),
@@ -167,12 +201,13 @@ func (svc *Service) editComment(postID, commentID, userName, body string) (event
 			"PK": &types.AttributeValueMemberS{Value: PrefixPost + postID},
 			"SK": &types.AttributeValueMemberS{Value: record.SK},
 		},
-		UpdateExpression: aws.String("SET #content = :content, updatedAt = :updatedAt"),
+		UpdateExpression: aws.String("SET #content = :content, mentions = :mentions, updatedAt = :updatedAt"),
 		ExpressionAttributeNames: map[string]string{
 			"#content": "content",
 		},
 		ExpressionAttributeValues: map[string]types.AttributeValue{
 			":content":   &types.AttributeValueMemberS{Value: req.Content},
+			":mentions":  mentionsAV,
 			":updatedAt": &types.AttributeValueMemberS{Value: now},
 		},
 	})
@@ -180,8 +215,19 @@ func (svc *Service) editComment(postID, commentID, userName, body string) (event
 		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to edit comment")
 	}
 
+	newMentions := addedMentions(record.Mentions, mentions)
 	record.Content = req.Content
+	record.Mentions = mentions
 	record.UpdatedAt = now
+
+	svc.notify(mentionNotifications(companylib.Notification{
+		ActorUserName: record.AuthorUserID,
+		ActorName:     record.AuthorName,
+		TeamId:        post.TeamID,
+		PostId:        postID,
+		CommentId:     commentID,
+	}, newMentions, "a comment"))
+
 	return svc.okResp(buildCommentResponse(*record), nil)
 }
 
@@ -237,21 +283,26 @@ func (svc *Service) deleteComment(postID, commentID, userName string) (events.AP
 // ==================== DDB Helpers ====================
 
 func (svc *Service) fetchComments(postID, _ string) ([]CommentRecord, error) {
-	result, err := svc.ddb.Query(svc.ctx, &dynamodb.QueryInput{
-		TableName:              aws.String(svc.feedTable),
+	items, err := svc.queryAll(commentsQueryInput(svc.feedTable, postID))
+	if err != nil {
+		return nil, err
+	}
+	var records []CommentRecord
+	attributevalue.UnmarshalListOfMaps(items, &records)
+	return records, nil
+}
+
+// commentsQueryInput queries a post's comments, oldest first
+func commentsQueryInput(table, postID string) *dynamodb.QueryInput {
+	return &dynamodb.QueryInput{
+		TableName:              aws.String(table),
 		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :prefix)"),
 		ExpressionAttributeValues: map[string]types.AttributeValue{
 			":pk":     &types.AttributeValueMemberS{Value: PrefixPost + postID},
 			":prefix": &types.AttributeValueMemberS{Value: SKCommentPrefix},
 		},
 		ScanIndexForward: aws.Bool(true),
-	})
-	if err != nil {
-		return nil, err
 	}
-	var records []CommentRecord
-	attributevalue.UnmarshalListOfMaps(result.Items, &records)
-	return records, nil
 }
 
 func (svc *Service) fetchCommentByID(postID, commentID string) (*CommentRecord, error) {
@@ -287,6 +338,7 @@ func buildCommentResponse(c CommentRecord) map[string]interface{} {
 		},
 		"content":         c.Content,
 		"parentCommentId": nilIfEmptyStr(c.ParentCommentID),
+		"mentions":        mentionsOrEmpty(c.Mentions),
 		"likeCount":       c.LikeCount,
 		"createdAt":       c.CreatedAt,
 		"updatedAt":       c.UpdatedAt,
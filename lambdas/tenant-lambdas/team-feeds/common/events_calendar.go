@@ -0,0 +1,687 @@
+package common
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	// Lambda's provided runtime has no zoneinfo, so event timezones are resolved from the copy
+	// embedded in the binary
+	_ "time/tzdata"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	companylib "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/company-lib"
+)
+
+// ==================== Event Timing ====================
+//
+// eventDate (YYYY-MM-DD) and eventTime (HH:MM, 24-hour) are wall-clock values in the event's
+// timezone (IANA name, UTC when not set). An event without a time is an all-day event. Start
+// times are stored and exported in UTC, so calendar feeds need no VTIMEZONE definitions.
+
+const (
+	defaultEventDurationMinutes = 60
+	maxEventDurationMinutes     = 7 * 24 * 60
+	defaultEventReminderMinutes = 24 * 60
+	maxEventReminderMinutes     = 7 * 24 * 60
+
+	// Pending reminders share the sparse ChecklistScheduleIndex with recurring checklists under
+	// their own scheduleKey, so the table needs no second schedule index.
+	eventReminderIndex = checklistScheduleIndex
+	eventReminderKey   = "EVENT_REMINDER"
+
+	// Past events stay on calendar feeds for this long
+	calendarLookback = 90 * 24 * time.Hour
+)
+
+// eventStart returns when the event starts in UTC and whether it is an all-day event
+func eventStart(d PostData) (time.Time, bool, error) {
+	loc := time.UTC
+	if d.EventTimezone != "" {
+		l, err := time.LoadLocation(d.EventTimezone)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("unknown timezone %q", d.EventTimezone)
+		}
+		loc = l
+	}
+
+	if d.EventTime == "" {
+		t, err := time.ParseInLocation("2006-01-02", d.EventDate, loc)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("eventDate must be YYYY-MM-DD")
+		}
+		return t.UTC(), true, nil
+	}
+
+	t, err := time.ParseInLocation("2006-01-02 15:04", d.EventDate+" "+d.EventTime, loc)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("eventDate must be YYYY-MM-DD and eventTime HH:MM (24-hour)")
+	}
+	return t.UTC(), false, nil
+}
+
+// applyEventRequest copies the event fields set in req onto d and validates the result. Fields
+// left empty in req keep their current value.
+func applyEventRequest(d *PostData, req *CreatePostRequest) error {
+	if req.Title != "" {
+		d.EventTitle = req.Title
+	}
+	if req.EventDate != "" {
+		d.EventDate = req.EventDate
+	}
+	if req.EventTime != "" {
+		d.EventTime = req.EventTime
+	}
+	if req.Timezone != "" {
+		d.EventTimezone = req.Timezone
+	}
+	if req.Location != "" {
+		d.Location = req.Location
+	}
+	if req.DurationMinutes != 0 {
+		if req.DurationMinutes < 0 || req.DurationMinutes > maxEventDurationMinutes {
+			return fmt.Errorf("durationMinutes must be between 1 and %d", maxEventDurationMinutes)
+		}
+		d.DurationMinutes = req.DurationMinutes
+	}
+	if req.Capacity != nil {
+		if *req.Capacity < 0 {
+			return fmt.Errorf("capacity cannot be negative")
+		}
+		d.Capacity = *req.Capacity
+	}
+	if req.ReminderMinutesBefore != nil {
+		if *req.ReminderMinutesBefore < 0 || *req.ReminderMinutesBefore > maxEventReminderMinutes {
+			return fmt.Errorf("reminderMinutesBefore must be between 0 and %d", maxEventReminderMinutes)
+		}
+		d.ReminderMinutesBefore = *req.ReminderMinutesBefore
+	}
+
+	_, _, err := eventStart(*d)
+	return err
+}
+
+// ==================== Team Calendar ====================
+
+// eventCalendarEntry builds the calendar entry for an event post
+func eventCalendarEntry(post PostRecord) (EventCalendarEntry, error) {
+	start, allDay, err := eventStart(post.Data)
+	if err != nil {
+		return EventCalendarEntry{}, err
+	}
+	startsAt := start.Format(time.RFC3339)
+
+	return EventCalendarEntry{
+		PK:              PrefixTeamEvents + post.TeamID,
+		SK:              startsAt + "#" + post.PostID,
+		PostID:          post.PostID,
+		TeamID:          post.TeamID,
+		Title:           post.Data.EventTitle,
+		Description:     post.Content,
+		Location:        post.Data.Location,
+		StartsAt:        startsAt,
+		AllDay:          allDay,
+		EventDate:       post.Data.EventDate,
+		Timezone:        post.Data.EventTimezone,
+		DurationMinutes: post.Data.DurationMinutes,
+		OrganizerName:   post.AuthorName,
+		UpdatedAt:       post.UpdatedAt,
+	}, nil
+}
+
+// scheduleEventReminder puts entry on the reminder index unless reminders are off, the event has
+// started, or a reminder was already sent for this start time. A reminder whose time has passed
+// is sent on the next run.
+func scheduleEventReminder(entry *EventCalendarEntry, minutesBefore int, now time.Time) {
+	entry.ReminderKey, entry.RemindAt = "", ""
+
+	start, err := time.Parse(time.RFC3339, entry.StartsAt)
+	if err != nil || minutesBefore <= 0 || !start.After(now) || entry.ReminderSentFor == entry.StartsAt {
+		return
+	}
+
+	remindAt := start.Add(-time.Duration(minutesBefore) * time.Minute)
+	if remindAt.Before(now) {
+		remindAt = now
+	}
+	entry.ReminderKey = eventReminderKey
+	entry.RemindAt = remindAt.UTC().Format(time.RFC3339)
+}
+
+// syncEventCalendar writes the calendar entry for post, replacing the entry for prev when the
+// start time moved. Like notifications, the calendar is a side effect: failures are logged.
+func (svc *Service) syncEventCalendar(prev *PostRecord, post PostRecord) {
+	var previous *EventCalendarEntry
+	if prev != nil {
+		if old, err := eventCalendarEntry(*prev); err == nil {
+			previous, _ = svc.fetchCalendarEntry(old.PK, old.SK)
+		}
+	}
+
+	entry, err := eventCalendarEntry(post)
+	if err != nil {
+		svc.logger.Printf("Skipping calendar entry for event %s: %v", post.PostID, err)
+		return
+	}
+	if previous != nil {
+		entry.ReminderSentFor = previous.ReminderSentFor
+	}
+	scheduleEventReminder(&entry, post.Data.ReminderMinutesBefore, time.Now().UTC())
+
+	if previous != nil && previous.SK != entry.SK {
+		svc.removeCalendarEntry(previous.PK, previous.SK)
+	}
+
+	item, err := attributevalue.MarshalMap(entry)
+	if err == nil {
+		_, err = svc.ddb.PutItem(svc.ctx, &dynamodb.PutItemInput{
+			TableName: aws.String(svc.feedTable),
+			Item:      item,
+		})
+	}
+	if err != nil {
+		svc.logger.Printf("Failed to write calendar entry for event %s: %v", post.PostID, err)
+	}
+}
+
+// removeEventCalendar takes a deleted event post off its team's calendar
+func (svc *Service) removeEventCalendar(post PostRecord) {
+	if entry, err := eventCalendarEntry(post); err == nil {
+		svc.removeCalendarEntry(entry.PK, entry.SK)
+	}
+}
+
+func (svc *Service) removeCalendarEntry(pk, sk string) {
+	_, err := svc.ddb.DeleteItem(svc.ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(svc.feedTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: pk},
+			"SK": &types.AttributeValueMemberS{Value: sk},
+		},
+	})
+	if err != nil {
+		svc.logger.Printf("Failed to remove calendar entry %s %s: %v", pk, sk, err)
+	}
+}
+
+func (svc *Service) fetchCalendarEntry(pk, sk string) (*EventCalendarEntry, error) {
+	result, err := svc.ddb.GetItem(svc.ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(svc.feedTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: pk},
+			"SK": &types.AttributeValueMemberS{Value: sk},
+		},
+	})
+	if err != nil || result.Item == nil {
+		return nil, fmt.Errorf("calendar entry not found: %s %s", pk, sk)
+	}
+	var entry EventCalendarEntry
+	if err := attributevalue.UnmarshalMap(result.Item, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// fetchTeamCalendar returns the team's events that started within calendarLookback or later
+func (svc *Service) fetchTeamCalendar(teamID string) ([]EventCalendarEntry, error) {
+	from := time.Now().UTC().Add(-calendarLookback).Format(time.RFC3339)
+	items, err := svc.queryAll(&dynamodb.QueryInput{
+		TableName:              aws.String(svc.feedTable),
+		KeyConditionExpression: aws.String("PK = :pk AND SK >= :from"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":   &types.AttributeValueMemberS{Value: PrefixTeamEvents + teamID},
+			":from": &types.AttributeValueMemberS{Value: from},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query team calendar: %w", err)
+	}
+	var entries []EventCalendarEntry
+	if err := attributevalue.UnmarshalListOfMaps(items, &entries); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal team calendar: %w", err)
+	}
+	return entries, nil
+}
+
+// ==================== ICS ====================
+
+const icsDateTime = "20060102T150405Z"
+
+// buildICS renders entries as an iCalendar (RFC 5545) feed
+func buildICS(calendarName string, entries []EventCalendarEntry, appBaseURL string) string {
+	var b strings.Builder
+	line := func(s string) {
+		b.WriteString(foldICSLine(s))
+		b.WriteString("\r\n")
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//GoMovo//Team Feed//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:" + escapeICSText(calendarName))
+
+	for _, e := range entries {
+		start, err := time.Parse(time.RFC3339, e.StartsAt)
+		if err != nil {
+			continue
+		}
+		stamp, err := time.Parse(time.RFC3339, e.UpdatedAt)
+		if err != nil {
+			stamp = start
+		}
+		postURL := fmt.Sprintf("%s/teams/%s/posts/%s", strings.TrimSuffix(appBaseURL, "/"), e.TeamID, e.PostID)
+
+		line("BEGIN:VEVENT")
+		line("UID:" + e.PostID + "@gomovo.com")
+		line("DTSTAMP:" + stamp.UTC().Format(icsDateTime))
+		if e.AllDay {
+			day, err := time.Parse("2006-01-02", e.EventDate)
+			if err != nil {
+				day = start
+			}
+			days := e.DurationMinutes / (24 * 60)
+			if days < 1 {
+				days = 1
+			}
+			line("DTSTART;VALUE=DATE:" + day.Format("20060102"))
+			line("DTEND;VALUE=DATE:" + day.AddDate(0, 0, days).Format("20060102"))
+		} else {
+			duration := e.DurationMinutes
+			if duration <= 0 {
+				duration = defaultEventDurationMinutes
+			}
+			line("DTSTART:" + start.UTC().Format(icsDateTime))
+			line("DTEND:" + start.Add(time.Duration(duration)*time.Minute).UTC().Format(icsDateTime))
+		}
+		line("SUMMARY:" + escapeICSText(e.Title))
+		description := postURL
+		if e.Description != "" {
+			description = e.Description + "\n\n" + postURL
+		}
+		line("DESCRIPTION:" + escapeICSText(description))
+		if e.Location != "" {
+			line("LOCATION:" + escapeICSText(e.Location))
+		}
+		line("URL:" + postURL)
+		line("END:VEVENT")
+	}
+
+	line("END:VCALENDAR")
+	return b.String()
+}
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", "")
+
+func escapeICSText(s string) string {
+	return icsEscaper.Replace(s)
+}
+
+// foldICSLine splits lines longer than 75 octets, continuing each with a space, without
+// breaking a UTF-8 character
+func foldICSLine(s string) string {
+	const limit = 75
+	if len(s) <= limit {
+		return s
+	}
+
+	var b strings.Builder
+	width := 0
+	for _, r := range s {
+		size := len(string(r))
+		if width+size > limit {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
+
+func (svc *Service) icsResp(body, filename string) (events.APIGatewayProxyResponse, error) {
+	headers := make(map[string]string, len(RESP_HEADERS)+2)
+	for k, v := range RESP_HEADERS {
+		headers[k] = v
+	}
+	headers["Content-Type"] = "text/calendar; charset=utf-8"
+	headers["Content-Disposition"] = fmt.Sprintf("inline; filename=%q", filename)
+	return events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Headers: headers, Body: body}, nil
+}
+
+func (svc *Service) getEventICS(post *PostRecord) (events.APIGatewayProxyResponse, error) {
+	entry, err := eventCalendarEntry(*post)
+	if err != nil {
+		return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "This event has no valid date: "+err.Error())
+	}
+	return svc.icsResp(buildICS(post.Data.EventTitle, []EventCalendarEntry{entry}, svc.appBaseURL), "event.ics")
+}
+
+func (svc *Service) getTeamCalendarICS(teamID string) (events.APIGatewayProxyResponse, error) {
+	body, err := svc.teamCalendarICS(teamID)
+	if err != nil {
+		svc.logger.Printf("Error building calendar for team %s: %v", teamID, err)
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to build team calendar")
+	}
+	return svc.icsResp(body, "team-events.ics")
+}
+
+func (svc *Service) teamCalendarICS(teamID string) (string, error) {
+	entries, err := svc.fetchTeamCalendar(teamID)
+	if err != nil {
+		return "", err
+	}
+	name := "Team events"
+	if team, err := svc.teamsSVC.GetTeamMetadata(teamID); err == nil && team.TeamName != "" {
+		name = team.TeamName + " events"
+	}
+	return buildICS(name, entries, svc.appBaseURL), nil
+}
+
+// ==================== Calendar Subscriptions ====================
+//
+// Calendar apps cannot send a Cognito token, so a subscription is a secret URL. Each member has
+// at most one per team: creating a new one replaces the old URL.
+
+var errCalendarFeedNotFound = errors.New("calendar feed not found")
+
+func hashCalendarToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func calendarSubscriptionKey(teamID, userName string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: PrefixCalendarSubscription + teamID + "#" + userName},
+		"SK": &types.AttributeValueMemberS{Value: SKMetadata},
+	}
+}
+
+func calendarTokenKey(tokenHash string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: PrefixCalendarToken + tokenHash},
+		"SK": &types.AttributeValueMemberS{Value: SKMetadata},
+	}
+}
+
+func (svc *Service) fetchCalendarSubscription(teamID, userName string) (*CalendarSubscriptionRecord, error) {
+	result, err := svc.ddb.GetItem(svc.ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(svc.feedTable),
+		Key:       calendarSubscriptionKey(teamID, userName),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+	var sub CalendarSubscriptionRecord
+	if err := attributevalue.UnmarshalMap(result.Item, &sub); err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+func (svc *Service) createCalendarSubscription(request events.APIGatewayProxyRequest, teamID, userName string) (events.APIGatewayProxyResponse, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create calendar subscription")
+	}
+	token := hex.EncodeToString(secret)
+	tokenHash := hashCalendarToken(token)
+
+	previous, err := svc.fetchCalendarSubscription(teamID, userName)
+	if err != nil {
+		svc.logger.Printf("Error reading calendar subscription for %s: %v", userName, err)
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create calendar subscription")
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	tokenItem, _ := attributevalue.MarshalMap(CalendarTokenRecord{
+		PK:        PrefixCalendarToken + tokenHash,
+		SK:        SKMetadata,
+		TeamID:    teamID,
+		UserName:  userName,
+		CreatedAt: now,
+	})
+	subItem, _ := attributevalue.MarshalMap(CalendarSubscriptionRecord{
+		PK:        PrefixCalendarSubscription + teamID + "#" + userName,
+		SK:        SKMetadata,
+		TokenHash: tokenHash,
+		CreatedAt: now,
+	})
+	writes := []types.TransactWriteItem{
+		{Put: &types.Put{TableName: aws.String(svc.feedTable), Item: tokenItem}},
+		{Put: &types.Put{TableName: aws.String(svc.feedTable), Item: subItem}},
+	}
+	if previous != nil {
+		writes = append(writes, types.TransactWriteItem{
+			Delete: &types.Delete{TableName: aws.String(svc.feedTable), Key: calendarTokenKey(previous.TokenHash)},
+		})
+	}
+	if _, err := svc.ddb.TransactWriteItems(svc.ctx, &dynamodb.TransactWriteItemsInput{TransactItems: writes}); err != nil {
+		svc.logger.Printf("Error creating calendar subscription for %s: %v", userName, err)
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create calendar subscription")
+	}
+
+	feedURL := calendarFeedURL(request, token)
+	return svc.createdResp(map[string]interface{}{
+		"teamId":    teamID,
+		"url":       feedURL,
+		"webcalUrl": "webcal://" + strings.TrimPrefix(feedURL, "https://"),
+		"createdAt": now,
+	})
+}
+
+func (svc *Service) revokeCalendarSubscription(teamID, userName string) (events.APIGatewayProxyResponse, error) {
+	sub, err := svc.fetchCalendarSubscription(teamID, userName)
+	if err != nil {
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to revoke calendar subscription")
+	}
+	if sub == nil {
+		return svc.noContentResp()
+	}
+
+	_, err = svc.ddb.TransactWriteItems(svc.ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{Delete: &types.Delete{TableName: aws.String(svc.feedTable), Key: calendarTokenKey(sub.TokenHash)}},
+			{Delete: &types.Delete{TableName: aws.String(svc.feedTable), Key: calendarSubscriptionKey(teamID, userName)}},
+		},
+	})
+	if err != nil {
+		svc.logger.Printf("Error revoking calendar subscription for %s: %v", userName, err)
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to revoke calendar subscription")
+	}
+	return svc.noContentResp()
+}
+
+// calendarFeedURL builds the public feed URL on the host the request came in on. The stage is
+// only part of the path on the default execute-api domain.
+func calendarFeedURL(request events.APIGatewayProxyRequest, token string) string {
+	host := request.RequestContext.DomainName
+	if host == "" {
+		host = request.Headers["Host"]
+	}
+	prefix := ""
+	if strings.Contains(host, ".execute-api.") && request.RequestContext.Stage != "" {
+		prefix = "/" + request.RequestContext.Stage
+	}
+	return fmt.Sprintf("https://%s%s/v2/calendar-feeds/%s", host, prefix, token)
+}
+
+// HandleCalendarFeed serves GET /v2/calendar-feeds/{token} without a Cognito authorizer. The
+// token's owner must still be an active member of the team.
+func (svc *Service) HandleCalendarFeed(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	parts := splitPath(request.Path)
+	if len(parts) != 3 || parts[0] != "v2" || parts[1] != "calendar-feeds" || request.HTTPMethod != "GET" {
+		return svc.errResp(http.StatusNotFound, "NOT_FOUND", "Route not found")
+	}
+
+	record, err := svc.resolveCalendarToken(strings.TrimSuffix(parts[2], ".ics"))
+	if err != nil {
+		if !errors.Is(err, errCalendarFeedNotFound) {
+			svc.logger.Printf("Error resolving calendar feed: %v", err)
+		}
+		return svc.errResp(http.StatusNotFound, "NOT_FOUND", "Calendar feed not found")
+	}
+
+	body, err := svc.teamCalendarICS(record.TeamID)
+	if err != nil {
+		svc.logger.Printf("Error building calendar for team %s: %v", record.TeamID, err)
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to build team calendar")
+	}
+	return svc.icsResp(body, "team-events.ics")
+}
+
+func (svc *Service) resolveCalendarToken(token string) (*CalendarTokenRecord, error) {
+	if token == "" {
+		return nil, errCalendarFeedNotFound
+	}
+	result, err := svc.ddb.GetItem(svc.ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(svc.feedTable),
+		Key:       calendarTokenKey(hashCalendarToken(token)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get calendar token: %w", err)
+	}
+	if result.Item == nil {
+		return nil, errCalendarFeedNotFound
+	}
+	var record CalendarTokenRecord
+	if err := attributevalue.UnmarshalMap(result.Item, &record); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal calendar token: %w", err)
+	}
+	if err := svc.ensureTeamMember(record.TeamID, record.UserName); err != nil {
+		return nil, errCalendarFeedNotFound
+	}
+	return &record, nil
+}
+
+// ==================== Event Reminders (scheduled) ====================
+
+// EventReminderSummary is returned by the scheduled reminder run and written to the logs
+type EventReminderSummary struct {
+	RunAt    string `json:"runAt"`
+	Due      int    `json:"due"`
+	Sent     int    `json:"sent"`
+	Notified int    `json:"notified"`
+	Failed   int    `json:"failed"`
+}
+
+// HandleEventReminderSchedule notifies members who are going to, or might go to, an event whose
+// reminder time has passed. Each entry is claimed before notifying, so a reminder is sent at
+// most once per start time.
+func (svc *Service) HandleEventReminderSchedule(ctx context.Context, event events.CloudWatchEvent) (EventReminderSummary, error) {
+	now := time.Now().UTC()
+	summary := EventReminderSummary{RunAt: now.Format(time.RFC3339)}
+
+	items, err := svc.queryAll(&dynamodb.QueryInput{
+		TableName:              aws.String(svc.feedTable),
+		IndexName:              aws.String(eventReminderIndex),
+		KeyConditionExpression: aws.String("scheduleKey = :key AND nextResetAt <= :now"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":key": &types.AttributeValueMemberS{Value: eventReminderKey},
+			":now": &types.AttributeValueMemberS{Value: summary.RunAt},
+		},
+	})
+	if err != nil {
+		return summary, fmt.Errorf("failed to query due event reminders: %w", err)
+	}
+
+	var entries []EventCalendarEntry
+	if err := attributevalue.UnmarshalListOfMaps(items, &entries); err != nil {
+		return summary, fmt.Errorf("failed to unmarshal due event reminders: %w", err)
+	}
+	summary.Due = len(entries)
+
+	for _, entry := range entries {
+		notified, err := svc.sendEventReminder(entry, now)
+		if err != nil {
+			svc.logger.Printf("Failed to send reminder for event %s: %v", entry.PostID, err)
+			summary.Failed++
+			continue
+		}
+		summary.Sent++
+		summary.Notified += notified
+	}
+
+	svc.logger.Printf("Event reminder run complete: %+v", summary)
+	return summary, nil
+}
+
+func (svc *Service) sendEventReminder(entry EventCalendarEntry, now time.Time) (int, error) {
+	_, err := svc.ddb.UpdateItem(svc.ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(svc.feedTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: entry.PK},
+			"SK": &types.AttributeValueMemberS{Value: entry.SK},
+		},
+		UpdateExpression:    aws.String("SET reminderSentFor = :starts REMOVE scheduleKey, nextResetAt"),
+		ConditionExpression: aws.String("nextResetAt = :due"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":starts": &types.AttributeValueMemberS{Value: entry.StartsAt},
+			":due":    &types.AttributeValueMemberS{Value: entry.RemindAt},
+		},
+	})
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			// Claimed by another run, or the event was edited since
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to claim reminder: %w", err)
+	}
+
+	start, err := time.Parse(time.RFC3339, entry.StartsAt)
+	if err != nil || !start.After(now) {
+		return 0, nil
+	}
+
+	rsvps, err := svc.fetchRSVPs(entry.PostID)
+	if err != nil {
+		return 0, err
+	}
+	notifications := eventReminderNotifications(entry, rsvps)
+	svc.notify(notifications)
+	return len(notifications), nil
+}
+
+// eventReminderNotifications reminds members who answered going or maybe
+func eventReminderNotifications(entry EventCalendarEntry, rsvps []RSVPRecord) []companylib.Notification {
+	when := "on " + entry.EventDate
+	if start, err := time.Parse(time.RFC3339, entry.StartsAt); err == nil && !entry.AllDay {
+		if loc, err := time.LoadLocation(entry.Timezone); err == nil {
+			start = start.In(loc)
+		}
+		when = "at " + start.Format("15:04 MST") + " on " + start.Format("Mon 2 Jan")
+	}
+
+	sort.Slice(rsvps, func(i, j int) bool { return rsvps[i].UserID < rsvps[j].UserID })
+	var notifications []companylib.Notification
+	for _, r := range rsvps {
+		if r.Status != RSVPGoing && r.Status != RSVPMaybe {
+			continue
+		}
+		notifications = append(notifications, companylib.Notification{
+			UserName: r.UserID,
+			Type:     companylib.NotificationTypeEventReminder,
+			TeamId:   entry.TeamID,
+			PostId:   entry.PostID,
+			Message:  fmt.Sprintf("Reminder: %s starts %s", entry.Title, when),
+		})
+	}
+	return notifications
+}
@@ -0,0 +1,501 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	companylib "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/company-lib"
+)
+
+// ==================== Route: Events ====================
+//
+// PUT    /v2/posts/{postId}/rsvp
+// DELETE /v2/posts/{postId}/rsvp
+// GET    /v2/posts/{postId}/rsvps?status=&cursor=&limit=
+// GET    /v2/posts/{postId}/event.ics
+// GET    /v2/teams/{teamId}/events/calendar.ics
+// POST   /v2/teams/{teamId}/events/calendar/subscription
+// DELETE /v2/teams/{teamId}/events/calendar/subscription
+
+func (svc *Service) handleEvents(request events.APIGatewayProxyRequest, parts []string, userName, cognitoID string) (events.APIGatewayProxyResponse, error) {
+	// /v2/posts/{postId}/{action}  (4 parts)
+	if len(parts) == 4 && parts[1] == "posts" {
+		post, err := svc.fetchPostRecord(parts[2])
+		if err != nil {
+			return svc.errResp(http.StatusNotFound, "NOT_FOUND", "Post not found")
+		}
+		if post.Type != string(PostTypeEvent) {
+			return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "This post is not an event")
+		}
+		if err := svc.ensureTeamMember(post.TeamID, userName); err != nil {
+			return svc.errResp(http.StatusForbidden, "FORBIDDEN", "You are not a member of this team")
+		}
+
+		switch {
+		case parts[3] == "rsvp" && request.HTTPMethod == "PUT":
+			return svc.rsvpToEvent(post, userName, cognitoID, request.Body)
+		case parts[3] == "rsvp" && request.HTTPMethod == "DELETE":
+			return svc.withdrawRSVP(post, userName)
+		case parts[3] == "rsvps" && request.HTTPMethod == "GET":
+			return svc.listRSVPs(post, request.QueryStringParameters)
+		case parts[3] == "event.ics" && request.HTTPMethod == "GET":
+			return svc.getEventICS(post)
+		}
+	}
+
+	// /v2/teams/{teamId}/events/calendar.ics  (5 parts)
+	// /v2/teams/{teamId}/events/calendar/subscription  (6 parts)
+	if len(parts) >= 5 && parts[1] == "teams" && parts[3] == "events" {
+		teamID := parts[2]
+		if err := svc.ensureTeamMember(teamID, userName); err != nil {
+			return svc.errResp(http.StatusForbidden, "FORBIDDEN", "You are not a member of this team")
+		}
+
+		if len(parts) == 5 && parts[4] == "calendar.ics" && request.HTTPMethod == "GET" {
+			return svc.getTeamCalendarICS(teamID)
+		}
+		if len(parts) == 6 && parts[4] == "calendar" && parts[5] == "subscription" {
+			switch request.HTTPMethod {
+			case "POST":
+				return svc.createCalendarSubscription(request, teamID, userName)
+			case "DELETE":
+				return svc.revokeCalendarSubscription(teamID, userName)
+			}
+		}
+	}
+
+	return svc.errResp(http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed")
+}
+
+// ==================== RSVP ====================
+//
+// RSVP counts live in the post's data map and change in the same transaction as the RSVP record.
+// While an event with a capacity is full, "going" is recorded as "waitlisted"; when a place
+// frees up the longest-waiting member is promoted and notified.
+
+var (
+	errRSVPConflict = errors.New("RSVP changed concurrently")
+	errEventFull    = errors.New("event is at capacity")
+)
+
+// maxRSVPAttempts bounds retries when the event or the RSVP changes between reading and writing
+const maxRSVPAttempts = 3
+
+// resolveRSVPStatus returns the status to record when a member asks for requested
+func resolveRSVPStatus(requested, prev RSVPStatus, goingCount, capacity int) RSVPStatus {
+	if requested == RSVPGoing && capacity > 0 && prev != RSVPGoing && goingCount >= capacity {
+		return RSVPWaitlisted
+	}
+	return requested
+}
+
+// rsvpCounterAttr is the data map counter for each status
+var rsvpCounterAttr = map[RSVPStatus]string{
+	RSVPGoing:      "goingCount",
+	RSVPMaybe:      "maybeCount",
+	RSVPDeclined:   "declinedCount",
+	RSVPWaitlisted: "waitlistCount",
+}
+
+// rsvpCounterUpdate builds the SET expression moving one RSVP from prev to next ("" for none)
+func rsvpCounterUpdate(prev, next RSVPStatus) string {
+	var clauses []string
+	if attr, ok := rsvpCounterAttr[prev]; ok {
+		clauses = append(clauses, fmt.Sprintf("#data.%s = if_not_exists(#data.%s, :zero) - :one", attr, attr))
+	}
+	if attr, ok := rsvpCounterAttr[next]; ok {
+		clauses = append(clauses, fmt.Sprintf("#data.%s = if_not_exists(#data.%s, :zero) + :one", attr, attr))
+	}
+	return "SET " + strings.Join(clauses, ", ")
+}
+
+// writeRSVP records the move from prev (nil for a first response) to next, or deletes the RSVP
+// when next is nil. Taking a going place re-checks capacity: errEventFull is returned when the
+// event filled since the post was read, errRSVPConflict when the RSVP itself changed.
+func (svc *Service) writeRSVP(post *PostRecord, prev *RSVPRecord, next *RSVPRecord) error {
+	prevStatus := RSVPStatus("")
+	if prev != nil {
+		prevStatus = prev.Status
+	}
+	nextStatus := RSVPStatus("")
+	if next != nil {
+		nextStatus = next.Status
+	}
+
+	rsvpKey := map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: PrefixPost + post.PostID},
+		"SK": &types.AttributeValueMemberS{Value: SKRSVPPrefix + rsvpUser(prev, next)},
+	}
+	rsvpCondition := aws.String("attribute_not_exists(PK)")
+	rsvpValues := map[string]types.AttributeValue(nil)
+	if prev != nil {
+		rsvpCondition = aws.String("#status = :prev")
+		rsvpValues = map[string]types.AttributeValue{":prev": &types.AttributeValueMemberS{Value: string(prevStatus)}}
+	}
+
+	var rsvpWrite types.TransactWriteItem
+	if next != nil {
+		item, err := attributevalue.MarshalMap(next)
+		if err != nil {
+			return fmt.Errorf("failed to marshal RSVP: %w", err)
+		}
+		rsvpWrite.Put = &types.Put{
+			TableName:                 aws.String(svc.feedTable),
+			Item:                      item,
+			ConditionExpression:       rsvpCondition,
+			ExpressionAttributeValues: rsvpValues,
+		}
+		if prev != nil {
+			rsvpWrite.Put.ExpressionAttributeNames = map[string]string{"#status": "status"}
+		}
+	} else {
+		rsvpWrite.Delete = &types.Delete{
+			TableName:                 aws.String(svc.feedTable),
+			Key:                       rsvpKey,
+			ConditionExpression:       rsvpCondition,
+			ExpressionAttributeNames:  map[string]string{"#status": "status"},
+			ExpressionAttributeValues: rsvpValues,
+		}
+	}
+
+	values := map[string]types.AttributeValue{
+		":zero": &types.AttributeValueMemberN{Value: "0"},
+		":one":  &types.AttributeValueMemberN{Value: "1"},
+	}
+	condition := "attribute_exists(PK)"
+	if nextStatus == RSVPGoing && prevStatus != RSVPGoing && post.Data.Capacity > 0 {
+		condition += " AND (attribute_not_exists(#data.goingCount) OR #data.goingCount < :capacity)"
+		values[":capacity"] = &types.AttributeValueMemberN{Value: fmt.Sprint(post.Data.Capacity)}
+	}
+
+	_, err := svc.ddb.TransactWriteItems(svc.ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			rsvpWrite,
+			{Update: &types.Update{
+				TableName: aws.String(svc.feedTable),
+				Key: map[string]types.AttributeValue{
+					"PK": &types.AttributeValueMemberS{Value: PrefixPost + post.PostID},
+					"SK": &types.AttributeValueMemberS{Value: SKMetadata},
+				},
+				UpdateExpression:          aws.String(rsvpCounterUpdate(prevStatus, nextStatus)),
+				ConditionExpression:       aws.String(condition),
+				ExpressionAttributeNames:  map[string]string{"#data": "data"},
+				ExpressionAttributeValues: values,
+			}},
+		},
+	})
+	if err == nil {
+		return nil
+	}
+
+	var canceled *types.TransactionCanceledException
+	if errors.As(err, &canceled) && len(canceled.CancellationReasons) == 2 {
+		if aws.ToString(canceled.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+			return errRSVPConflict
+		}
+		if aws.ToString(canceled.CancellationReasons[1].Code) == "ConditionalCheckFailed" {
+			return errEventFull
+		}
+	}
+	return fmt.Errorf("failed to write RSVP: %w", err)
+}
+
+func rsvpUser(prev, next *RSVPRecord) string {
+	if next != nil {
+		return next.UserID
+	}
+	return prev.UserID
+}
+
+func (svc *Service) rsvpToEvent(post *PostRecord, userName, cognitoID, body string) (events.APIGatewayProxyResponse, error) {
+	req, err := parseBody[RSVPRequest](body)
+	if err != nil {
+		return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+	}
+	if req.Status != RSVPGoing && req.Status != RSVPMaybe && req.Status != RSVPDeclined {
+		return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "status must be going, maybe or declined")
+	}
+
+	name := svc.actorName(userName)
+	var record *RSVPRecord
+	for attempt := 0; attempt < maxRSVPAttempts; attempt++ {
+		prev, err := svc.fetchRSVP(post.PostID, userName)
+		if err != nil {
+			svc.logger.Printf("Error reading RSVP for %s: %v", userName, err)
+			return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to save RSVP")
+		}
+		prevStatus := RSVPStatus("")
+		if prev != nil {
+			prevStatus = prev.Status
+		}
+
+		next := resolveRSVPStatus(req.Status, prevStatus, post.Data.GoingCount, post.Data.Capacity)
+		if prev != nil && prev.Status == next {
+			record = prev
+			break
+		}
+
+		now := time.Now().UTC().Format(time.RFC3339)
+		record = &RSVPRecord{
+			PK:          PrefixPost + post.PostID,
+			SK:          SKRSVPPrefix + userName,
+			PostID:      post.PostID,
+			UserID:      userName,
+			Name:        name,
+			Status:      next,
+			RespondedAt: now,
+		}
+		if next == RSVPWaitlisted {
+			record.WaitlistedAt = now
+		}
+
+		err = svc.writeRSVP(post, prev, record)
+		if err == nil {
+			if prevStatus == RSVPGoing {
+				svc.promoteWaitlist(post.PostID)
+			}
+			break
+		}
+		if !errors.Is(err, errEventFull) && !errors.Is(err, errRSVPConflict) {
+			svc.logger.Printf("Error saving RSVP for %s: %v", userName, err)
+			return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to save RSVP")
+		}
+
+		// Re-read the counts and try again
+		record = nil
+		if post, err = svc.fetchPostRecord(post.PostID); err != nil {
+			return svc.errResp(http.StatusNotFound, "NOT_FOUND", "Post not found")
+		}
+	}
+	if record == nil {
+		return svc.errResp(http.StatusConflict, "CONFLICT", "The event changed while saving your RSVP, please try again")
+	}
+
+	return svc.rsvpResp(post.PostID, record)
+}
+
+func (svc *Service) withdrawRSVP(post *PostRecord, userName string) (events.APIGatewayProxyResponse, error) {
+	prev, err := svc.fetchRSVP(post.PostID, userName)
+	if err != nil {
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to withdraw RSVP")
+	}
+	if prev == nil {
+		return svc.noContentResp()
+	}
+
+	if err := svc.writeRSVP(post, prev, nil); err != nil {
+		if errors.Is(err, errRSVPConflict) {
+			return svc.errResp(http.StatusConflict, "CONFLICT", "Your RSVP changed while withdrawing it, please try again")
+		}
+		svc.logger.Printf("Error withdrawing RSVP for %s: %v", userName, err)
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to withdraw RSVP")
+	}
+	if prev.Status == RSVPGoing {
+		svc.promoteWaitlist(post.PostID)
+	}
+	return svc.noContentResp()
+}
+
+// rsvpResp returns the caller's RSVP with the event's current counts and waitlist position
+func (svc *Service) rsvpResp(postID string, record *RSVPRecord) (events.APIGatewayProxyResponse, error) {
+	post, err := svc.fetchPostRecord(postID)
+	if err != nil {
+		return svc.errResp(http.StatusNotFound, "NOT_FOUND", "Post not found")
+	}
+
+	resp := map[string]interface{}{
+		"postId":      postID,
+		"status":      record.Status,
+		"respondedAt": record.RespondedAt,
+		"rsvpCounts":  rsvpCounts(post.Data),
+	}
+	if record.Status == RSVPWaitlisted {
+		rsvps, err := svc.fetchRSVPs(postID)
+		if err == nil {
+			resp["waitlistPosition"] = waitlistPosition(rsvps, record.UserID)
+		}
+	}
+	return svc.okResp(resp, nil)
+}
+
+func (svc *Service) listRSVPs(post *PostRecord, queryParams map[string]string) (events.APIGatewayProxyResponse, error) {
+	limit := pageLimit(queryParams, defaultPageLimit)
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(svc.feedTable),
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :prefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":     &types.AttributeValueMemberS{Value: PrefixPost + post.PostID},
+			":prefix": &types.AttributeValueMemberS{Value: SKRSVPPrefix},
+		},
+	}
+	if status := queryString(queryParams, "status"); status != "" {
+		if _, ok := rsvpCounterAttr[RSVPStatus(status)]; !ok {
+			return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "status must be going, maybe, declined or waitlisted")
+		}
+		input.FilterExpression = aws.String("#status = :status")
+		input.ExpressionAttributeNames = map[string]string{"#status": "status"}
+		input.ExpressionAttributeValues[":status"] = &types.AttributeValueMemberS{Value: status}
+	}
+
+	items, nextCursor, err := svc.queryPage(pageQuery{
+		input:          input,
+		keyAttrs:       tableKeyAttrs,
+		partitionAttr:  "PK",
+		partitionValue: PrefixPost + post.PostID,
+	}, limit, queryString(queryParams, "cursor"))
+	if err != nil {
+		if errors.Is(err, errInvalidCursor) {
+			return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "Invalid cursor")
+		}
+		svc.logger.Printf("Error querying RSVPs: %v", err)
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to fetch RSVPs")
+	}
+
+	rsvps := make([]RSVPRecord, 0, len(items))
+	if err := attributevalue.UnmarshalListOfMaps(items, &rsvps); err != nil {
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to parse RSVPs")
+	}
+	total, err := svc.queryCount(input)
+	if err != nil {
+		svc.logger.Printf("Error counting RSVPs: %v", err)
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to fetch RSVPs")
+	}
+	return svc.okResp(rsvps, &MetaResponse{Total: &total, Count: len(rsvps), Limit: limit, NextCursor: nextCursor})
+}
+
+// ==================== Waitlist ====================
+
+// promoteWaitlist fills free places from the waitlist, oldest first, and notifies each member
+// promoted. Called after a going RSVP is withdrawn or the capacity is raised; failures are
+// logged and the next change retries.
+func (svc *Service) promoteWaitlist(postID string) {
+	for {
+		post, err := svc.fetchPostRecord(postID)
+		if err != nil || post.Data.WaitlistCount <= 0 {
+			return
+		}
+		if post.Data.Capacity > 0 && post.Data.GoingCount >= post.Data.Capacity {
+			return
+		}
+
+		rsvps, err := svc.fetchRSVPs(postID)
+		if err != nil {
+			svc.logger.Printf("Failed to read waitlist for event %s: %v", postID, err)
+			return
+		}
+		waitlist := waitlistOrder(rsvps)
+		if len(waitlist) == 0 {
+			return
+		}
+
+		prev := waitlist[0]
+		next := prev
+		next.Status = RSVPGoing
+		next.WaitlistedAt = ""
+		next.RespondedAt = time.Now().UTC().Format(time.RFC3339)
+		if err := svc.writeRSVP(post, &prev, &next); err != nil {
+			if !errors.Is(err, errEventFull) && !errors.Is(err, errRSVPConflict) {
+				svc.logger.Printf("Failed to promote %s on event %s: %v", prev.UserID, postID, err)
+				return
+			}
+			// Someone else took the place or the member changed their RSVP; look again
+			continue
+		}
+
+		svc.notify([]companylib.Notification{{
+			UserName: next.UserID,
+			Type:     companylib.NotificationTypeEventSpotConfirmed,
+			TeamId:   post.TeamID,
+			PostId:   postID,
+			Message:  fmt.Sprintf("A place opened up: you're now going to %s", post.Data.EventTitle),
+		}})
+	}
+}
+
+// waitlistOrder returns the waitlisted RSVPs, longest waiting first
+func waitlistOrder(rsvps []RSVPRecord) []RSVPRecord {
+	var waitlist []RSVPRecord
+	for _, r := range rsvps {
+		if r.Status == RSVPWaitlisted {
+			waitlist = append(waitlist, r)
+		}
+	}
+	sort.SliceStable(waitlist, func(i, j int) bool {
+		if waitlist[i].WaitlistedAt != waitlist[j].WaitlistedAt {
+			return waitlist[i].WaitlistedAt < waitlist[j].WaitlistedAt
+		}
+		return waitlist[i].UserID < waitlist[j].UserID
+	})
+	return waitlist
+}
+
+// waitlistPosition is userID's 1-based place on the waitlist, or 0 when not waitlisted
+func waitlistPosition(rsvps []RSVPRecord, userID string) int {
+	for i, r := range waitlistOrder(rsvps) {
+		if r.UserID == userID {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// ==================== DDB Helpers ====================
+
+func (svc *Service) fetchRSVP(postID, userName string) (*RSVPRecord, error) {
+	result, err := svc.ddb.GetItem(svc.ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(svc.feedTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: PrefixPost + postID},
+			"SK": &types.AttributeValueMemberS{Value: SKRSVPPrefix + userName},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+	var record RSVPRecord
+	if err := attributevalue.UnmarshalMap(result.Item, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (svc *Service) fetchRSVPs(postID string) ([]RSVPRecord, error) {
+	items, err := svc.queryAll(&dynamodb.QueryInput{
+		TableName:              aws.String(svc.feedTable),
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :prefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":     &types.AttributeValueMemberS{Value: PrefixPost + postID},
+			":prefix": &types.AttributeValueMemberS{Value: SKRSVPPrefix},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query RSVPs: %w", err)
+	}
+	var rsvps []RSVPRecord
+	if err := attributevalue.UnmarshalListOfMaps(items, &rsvps); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal RSVPs: %w", err)
+	}
+	return rsvps, nil
+}
+
+func rsvpCounts(d PostData) map[string]int {
+	return map[string]int{
+		"going":      d.GoingCount,
+		"maybe":      d.MaybeCount,
+		"declined":   d.DeclinedCount,
+		"waitlisted": d.WaitlistCount,
+	}
+}
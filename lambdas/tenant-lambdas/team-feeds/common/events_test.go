@@ -0,0 +1,195 @@
+package common
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	companylib "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/company-lib"
+)
+
+func TestEventStart(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     PostData
+		expected string
+		allDay   bool
+		wantErr  bool
+	}{
+		{name: "It should treat events without a timezone as UTC", data: PostData{EventDate: "2026-03-01", EventTime: "12:30"}, expected: "2026-03-01T12:30:00Z"},
+		{name: "It should convert from the event timezone in winter", data: PostData{EventDate: "2026-01-15", EventTime: "09:00", EventTimezone: "America/New_York"}, expected: "2026-01-15T14:00:00Z"},
+		{name: "It should apply daylight saving time", data: PostData{EventDate: "2026-07-15", EventTime: "09:00", EventTimezone: "America/New_York"}, expected: "2026-07-15T13:00:00Z"},
+		{name: "It should start all-day events at local midnight", data: PostData{EventDate: "2026-03-01", EventTimezone: "Asia/Kolkata"}, expected: "2026-02-28T18:30:00Z", allDay: true},
+		{name: "It should reject unknown timezones", data: PostData{EventDate: "2026-03-01", EventTimezone: "Mars/Olympus"}, wantErr: true},
+		{name: "It should reject 12-hour times", data: PostData{EventDate: "2026-03-01", EventTime: "2:30 PM"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			start, allDay, err := eventStart(test.data)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, start.Format(time.RFC3339))
+			assert.Equal(t, test.allDay, allDay)
+		})
+	}
+}
+
+func TestApplyEventRequest(t *testing.T) {
+	capacity, reminder := 10, 0
+	data := PostData{EventTitle: "Offsite", EventDate: "2026-03-01", ReminderMinutesBefore: defaultEventReminderMinutes}
+
+	err := applyEventRequest(&data, &CreatePostRequest{EventTime: "10:00", Timezone: "Europe/London", Capacity: &capacity, ReminderMinutesBefore: &reminder})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Offsite", data.EventTitle, "unset fields should be kept")
+	assert.Equal(t, 10, data.Capacity)
+	assert.Equal(t, 0, data.ReminderMinutesBefore, "an explicit 0 should turn reminders off")
+
+	negative := -1
+	assert.Error(t, applyEventRequest(&data, &CreatePostRequest{Capacity: &negative}))
+	assert.Error(t, applyEventRequest(&data, &CreatePostRequest{EventDate: "01/03/2026"}))
+}
+
+func TestScheduleEventReminder(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("It should schedule the reminder before the start", func(t *testing.T) {
+		entry := EventCalendarEntry{StartsAt: "2026-03-03T09:00:00Z"}
+		scheduleEventReminder(&entry, 24*60, now)
+
+		assert.Equal(t, eventReminderKey, entry.ReminderKey)
+		assert.Equal(t, "2026-03-02T09:00:00Z", entry.RemindAt)
+	})
+
+	t.Run("It should remind on the next run when the reminder time has passed", func(t *testing.T) {
+		entry := EventCalendarEntry{StartsAt: "2026-03-01T18:00:00Z"}
+		scheduleEventReminder(&entry, 24*60, now)
+
+		assert.Equal(t, "2026-03-01T12:00:00Z", entry.RemindAt)
+	})
+
+	t.Run("It should not remind twice for the same start time", func(t *testing.T) {
+		entry := EventCalendarEntry{StartsAt: "2026-03-03T09:00:00Z", ReminderSentFor: "2026-03-03T09:00:00Z", ReminderKey: eventReminderKey}
+		scheduleEventReminder(&entry, 24*60, now)
+
+		assert.Equal(t, "", entry.ReminderKey)
+	})
+
+	t.Run("It should not remind for past events or when reminders are off", func(t *testing.T) {
+		past := EventCalendarEntry{StartsAt: "2026-02-28T09:00:00Z"}
+		scheduleEventReminder(&past, 60, now)
+		off := EventCalendarEntry{StartsAt: "2026-03-03T09:00:00Z"}
+		scheduleEventReminder(&off, 0, now)
+
+		assert.Equal(t, "", past.ReminderKey)
+		assert.Equal(t, "", off.ReminderKey)
+	})
+}
+
+func TestBuildICS(t *testing.T) {
+	entries := []EventCalendarEntry{
+		{
+			PostID: "p1", TeamID: "team-1", Title: "Retro; sprint 12, part 1",
+			Description: "Bring notes\nand snacks", Location: "Room 4",
+			StartsAt: "2026-03-01T14:00:00Z", DurationMinutes: 90, UpdatedAt: "2026-02-20T10:00:00Z",
+		},
+		{PostID: "p2", TeamID: "team-1", Title: "Team day", StartsAt: "2026-02-28T18:30:00Z", AllDay: true, EventDate: "2026-03-01"},
+	}
+
+	ics := buildICS("Platform events", entries, "https://app.gomovo.com/")
+
+	assert.True(t, strings.HasPrefix(ics, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+	assert.True(t, strings.HasSuffix(ics, "END:VCALENDAR\r\n"))
+	assert.Contains(t, ics, "UID:p1@gomovo.com\r\n")
+	assert.Contains(t, ics, "DTSTART:20260301T140000Z\r\nDTEND:20260301T153000Z\r\n")
+	assert.Contains(t, ics, `SUMMARY:Retro\; sprint 12\, part 1`)
+	assert.Contains(t, ics, `DESCRIPTION:Bring notes\nand snacks\n\nhttps://app.gomovo.com/teams/team-1/`)
+	assert.Contains(t, ics, "DTSTART;VALUE=DATE:20260301\r\nDTEND;VALUE=DATE:20260302\r\n", "all-day events should use the local date")
+
+	for _, line := range strings.Split(ics, "\r\n") {
+		assert.True(t, len(line) <= 75, "lines should be folded at 75 octets")
+	}
+}
+
+func TestFoldICSLine(t *testing.T) {
+	line := "SUMMARY:" + strings.Repeat("é", 40)
+
+	folded := foldICSLine(line)
+
+	assert.Equal(t, line, strings.ReplaceAll(folded, "\r\n ", ""))
+	for _, part := range strings.Split(folded, "\r\n") {
+		assert.True(t, len(part) <= 75)
+		assert.True(t, strings.ToValidUTF8(part, "?") == part, "folding should not split a character")
+	}
+}
+
+func TestResolveRSVPStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		requested RSVPStatus
+		prev      RSVPStatus
+		going     int
+		capacity  int
+		expected  RSVPStatus
+	}{
+		{name: "It should accept going when there is no capacity", requested: RSVPGoing, going: 50, expected: RSVPGoing},
+		{name: "It should accept going while places remain", requested: RSVPGoing, going: 9, capacity: 10, expected: RSVPGoing},
+		{name: "It should waitlist going when the event is full", requested: RSVPGoing, going: 10, capacity: 10, expected: RSVPWaitlisted},
+		{name: "It should keep a member who is already going", requested: RSVPGoing, prev: RSVPGoing, going: 10, capacity: 10, expected: RSVPGoing},
+		{name: "It should keep a waitlisted member waiting", requested: RSVPGoing, prev: RSVPWaitlisted, going: 10, capacity: 10, expected: RSVPWaitlisted},
+		{name: "It should accept maybe when the event is full", requested: RSVPMaybe, going: 10, capacity: 10, expected: RSVPMaybe},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, resolveRSVPStatus(test.requested, test.prev, test.going, test.capacity))
+		})
+	}
+}
+
+func TestRSVPCounterUpdate(t *testing.T) {
+	assert.Equal(t,
+		"SET #data.waitlistCount = if_not_exists(#data.waitlistCount, :zero) - :one, #data.goingCount = if_not_exists(#data.goingCount, :zero) + :one",
+		rsvpCounterUpdate(RSVPWaitlisted, RSVPGoing))
+	assert.Equal(t,
+		"SET #data.maybeCount = if_not_exists(#data.maybeCount, :zero) - :one",
+		rsvpCounterUpdate(RSVPMaybe, ""))
+}
+
+func TestWaitlistOrder(t *testing.T) {
+	rsvps := []RSVPRecord{
+		{UserID: "c@example.com", Status: RSVPWaitlisted, WaitlistedAt: "2026-03-01T10:05:00Z"},
+		{UserID: "a@example.com", Status: RSVPGoing},
+		{UserID: "b@example.com", Status: RSVPWaitlisted, WaitlistedAt: "2026-03-01T10:00:00Z"},
+	}
+
+	waitlist := waitlistOrder(rsvps)
+
+	assert.Len(t, waitlist, 2)
+	assert.Equal(t, "b@example.com", waitlist[0].UserID)
+	assert.Equal(t, 2, waitlistPosition(rsvps, "c@example.com"))
+	assert.Equal(t, 0, waitlistPosition(rsvps, "a@example.com"))
+}
+
+func TestEventReminderNotifications(t *testing.T) {
+	entry := EventCalendarEntry{PostID: "p1", TeamID: "team-1", Title: "Retro", StartsAt: "2026-07-15T13:00:00Z", Timezone: "America/New_York"}
+	rsvps := []RSVPRecord{
+		{UserID: "declined@example.com", Status: RSVPDeclined},
+		{UserID: "maybe@example.com", Status: RSVPMaybe},
+		{UserID: "going@example.com", Status: RSVPGoing},
+		{UserID: "waiting@example.com", Status: RSVPWaitlisted},
+	}
+
+	notifications := eventReminderNotifications(entry, rsvps)
+
+	assert.Len(t, notifications, 2)
+	assert.Equal(t, "going@example.com", notifications[0].UserName)
+	assert.Equal(t, companylib.NotificationTypeEventReminder, notifications[0].Type)
+	assert.Equal(t, "Reminder: Retro starts at 09:00 EDT on Wed 15 Jul", notifications[0].Message)
+}
@@ -1,8 +1,10 @@
 package common
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"reflect"
 	"sort"
 	"strings"
 	"time"
@@ -13,11 +15,16 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/google/uuid"
+
+	companylib "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/company-lib"
 )
 
+var errPostNotFound = errors.New("post not found")
+
 // ==================== Route: Posts ====================
 //
 // GET  /v2/teams/{teamId}/feed
+// GET  /v2/teams/{teamId}/feed/search
 // POST /v2/teams/{teamId}/posts
 // GET  /v2/teams/{teamId}/posts/{postId}
 // PUT  /v2/teams/{teamId}/posts/{postId}
@@ -33,6 +40,15 @@ func (svc *Service) handlePosts(request events.APIGatewayProxyRequest, parts []s
 		return svc.listTeamFeed(teamID, userName, request.QueryStringParameters)
 	}
 
+	// /v2/teams/{teamId}/feed/search  (5 parts: v2, teams, {teamId}, feed, search)
+	if len(parts) == 5 && parts[1] == "teams" && parts[3] == "feed" && parts[4] == "search" && request.HTTPMethod == "GET" {
+		teamID := parts[2]
+		if err := svc.ensureTeamMember(teamID, userName); err != nil {
+			return svc.errResp(http.StatusForbidden, "FORBIDDEN", "You are not a member of this team")
+		}
+		return svc.searchTeamFeed(teamID, userName, request.QueryStringParameters)
+	}
+
 	// /v2/teams/{teamId}/posts  (4 parts: v2, teams, {teamId}, posts)
 	if len(parts) == 4 && parts[1] == "teams" && parts[3] == "posts" {
 		teamID := parts[2]
@@ -67,8 +83,7 @@ func (svc *Service) handlePosts(request events.APIGatewayProxyRequest, parts []s
 // ==================== List Team Feed ====================
 
 func (svc *Service) listTeamFeed(teamID, userName string, queryParams map[string]string) (events.APIGatewayProxyResponse, error) {
-	page := queryInt(queryParams, "page", 1)
-	limit := queryInt(queryParams, "limit", 20)
+	limit := pageLimit(queryParams, defaultPageLimit)
 	typeFilter := queryString(queryParams, "type")
 
 	gsi1pk := PrefixTeam + teamID
@@ -89,34 +104,37 @@ func (svc *Service) listTeamFeed(teamID, userName string, queryParams map[string
 		input.ExpressionAttributeValues[":ptype"] = &types.AttributeValueMemberS{Value: typeFilter}
 	}
 
-	result, err := svc.ddb.Query(svc.ctx, input)
+	items, nextCursor, err := svc.queryPage(pageQuery{
+		input:          input,
+		keyAttrs:       gsi1KeyAttrs,
+		partitionAttr:  "GSI1PK",
+		partitionValue: gsi1pk,
+	}, limit, queryString(queryParams, "cursor"))
 	if err != nil {
+		if errors.Is(err, errInvalidCursor) {
+			return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "Invalid cursor")
+		}
 		svc.logger.Printf("Error querying feed: %v", err)
 		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to list feed")
 	}
 
 	var records []PostRecord
-	if err := attributevalue.UnmarshalListOfMaps(result.Items, &records); err != nil {
+	if err := attributevalue.UnmarshalListOfMaps(items, &records); err != nil {
 		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to parse feed items")
 	}
 
-	total := len(records)
-	start := (page - 1) * limit
-	if start > total {
-		start = total
-	}
-	end := start + limit
-	if end > total {
-		end = total
+	total, err := svc.queryCount(input)
+	if err != nil {
+		svc.logger.Printf("Error counting feed: %v", err)
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to list feed")
 	}
-	page_records := records[start:end]
 
-	posts := make([]map[string]interface{}, 0, len(page_records))
-	for _, r := range page_records {
+	posts := make([]map[string]interface{}, 0, len(records))
+	for _, r := range records {
 		posts = append(posts, svc.buildPostResponse(r, userName, nil, nil))
 	}
 
-	return svc.okResp(posts, &MetaResponse{Total: total, Page: page, Limit: limit})
+	return svc.okResp(posts, &MetaResponse{Total: &total, Count: len(posts), Limit: limit, NextCursor: nextCursor})
 }
 
 // ==================== Create Post ====================
@@ -162,8 +180,12 @@ func (svc *Service) createPost(teamID, userName, cognitoID, body string) (events
 		if req.RecipientUserID == "" {
 			return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "recipientUserId is required for kudos posts")
 		}
-		record.Data.KudosRecipientUserID = req.RecipientUserID
-		if recip, err2 := svc.empSVC.GetEmployeeDataByUserName(req.RecipientUserID); err2 == nil {
+		recipient, err := svc.teamRecipient(teamID, req.RecipientUserID)
+		if err != nil {
+			return svc.mentionErrResp(err)
+		}
+		record.Data.KudosRecipientUserID = recipient.UserName
+		if recip, err2 := svc.empSVC.GetEmployeeDataByUserName(recipient.UserName); err2 == nil {
 			record.Data.KudosRecipientName = recip.FirstName + " " + recip.LastName
 		}
 
@@ -174,13 +196,17 @@ func (svc *Service) createPost(teamID, userName, cognitoID, body string) (events
 		record.Data.TaskNumber = fmt.Sprintf("TASK-%s", strings.ToUpper(postID[:6]))
 		record.Data.TaskSummary = req.TaskSummary
 		record.Data.TaskDesc = req.TaskDescription
-		record.Data.AssigneeUserID = req.AssigneeUserID
 		record.Data.DueDate = req.DueDate
 		record.Data.Urgency = req.Urgency
 		record.Data.TaskStatus = "todo"
 		if req.AssigneeUserID != "" {
-			if assignee, err2 := svc.empSVC.GetEmployeeDataByUserName(req.AssigneeUserID); err2 == nil {
-				record.Data.AssigneeName = assignee.FirstName + " " + assignee.LastName
+			assignee, err := svc.teamRecipient(teamID, req.AssigneeUserID)
+			if err != nil {
+				return svc.mentionErrResp(err)
+			}
+			record.Data.AssigneeUserID = assignee.UserName
+			if employee, err2 := svc.empSVC.GetEmployeeDataByUserName(assignee.UserName); err2 == nil {
+				record.Data.AssigneeName = employee.FirstName + " " + employee.LastName
 			}
 		}
 
@@ -199,20 +225,31 @@ func (svc *Service) createPost(teamID, userName, cognitoID, body string) (events
 		if req.Title == "" {
 			return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "title is required for checklist posts")
 		}
+		freq, err := validateRecurrence(req.IsRecurring, req.RecurringFrequency)
+		if err != nil {
+			return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		}
 		record.Data.ChecklistTitle = req.Title
 		record.Data.IsRecurring = req.IsRecurring
-		record.Data.RecurringFrequency = req.RecurringFrequency
+		record.Data.RecurringFrequency = freq
+		applyChecklistSchedule(&record, time.Now())
 
 	case PostTypeEvent:
 		if req.Title == "" || req.EventDate == "" {
 			return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "title and eventDate are required for event posts")
 		}
-		record.Data.EventTitle = req.Title
-		record.Data.EventDate = req.EventDate
-		record.Data.EventTime = req.EventTime
-		record.Data.Location = req.Location
+		record.Data.ReminderMinutesBefore = defaultEventReminderMinutes
+		if err := applyEventRequest(&record.Data, req); err != nil {
+			return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		}
 	}
 
+	mentions, err := svc.resolveMentions(teamID, req.Content, req.Mentions)
+	if err != nil {
+		return svc.mentionErrResp(err)
+	}
+	record.Mentions = mentions
+
 	item, err := attributevalue.MarshalMap(record)
 	if err != nil {
 		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to marshal post")
@@ -246,6 +283,12 @@ func (svc *Service) createPost(teamID, userName, cognitoID, body string) (events
 		}
 	}
 
+	if req.Type == PostTypeEvent {
+		svc.syncEventCalendar(nil, record)
+	}
+
+	svc.notify(postNotifications(record, record.Mentions))
+
 	return svc.createdResp(svc.buildPostResponse(record, userName, nil, nil))
 }
 
@@ -292,9 +335,17 @@ func (svc *Service) updatePost(teamID, postID, userName, body string) (events.AP
 		return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
 	}
 
+	mentions, err := svc.resolveMentions(teamID, req.Content, req.Mentions)
+	if err != nil {
+		return svc.mentionErrResp(err)
+	}
+	newMentions := addedMentions(record.Mentions, mentions)
+	previous := *record
+
 	now := time.Now().UTC().Format(time.RFC3339)
 	record.Content = req.Content
 	record.Tags = req.Tags
+	record.Mentions = mentions
 	record.UpdatedAt = now
 
 	switch PostType(record.Type) {
@@ -312,38 +363,160 @@ func (svc *Service) updatePost(teamID, postID, userName, body string) (events.AP
 			record.Data.Urgency = req.Urgency
 		}
 	case PostTypeChecklist:
+		freq, err := validateRecurrence(req.IsRecurring, req.RecurringFrequency)
+		if err != nil {
+			return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		}
 		if req.Title != "" {
 			record.Data.ChecklistTitle = req.Title
 		}
 		record.Data.IsRecurring = req.IsRecurring
-		record.Data.RecurringFrequency = req.RecurringFrequency
+		record.Data.RecurringFrequency = freq
+		applyChecklistSchedule(record, time.Now())
 	case PostTypeEvent:
-		if req.Title != "" {
-			record.Data.EventTitle = req.Title
-		}
-		if req.EventDate != "" {
-			record.Data.EventDate = req.EventDate
-		}
-		if req.EventTime != "" {
-			record.Data.EventTime = req.EventTime
-		}
-		if req.Location != "" {
-			record.Data.Location = req.Location
+		if err := applyEventRequest(&record.Data, req); err != nil {
+			return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
 		}
 	}
 
-	item, _ := attributevalue.MarshalMap(record)
-	_, err = svc.ddb.PutItem(svc.ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(svc.feedTable),
-		Item:      item,
-	})
+	input, err := postEditUpdate(previous, *record)
+	if err != nil {
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to update post")
+	}
+	input.TableName = aws.String(svc.feedTable)
+	input.ReturnValues = types.ReturnValueAllNew
+	out, err := svc.ddb.UpdateItem(svc.ctx, input)
 	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			return svc.errResp(http.StatusConflict, "CONFLICT", "The post changed while saving your edit, please try again")
+		}
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to update post")
+	}
+	if err := attributevalue.UnmarshalMap(out.Attributes, record); err != nil {
 		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to update post")
 	}
 
+	if PostType(record.Type) == PostTypeEvent {
+		svc.syncEventCalendar(&previous, *record)
+		if record.Data.Capacity == 0 || record.Data.Capacity > previous.Data.Capacity {
+			svc.promoteWaitlist(postID)
+		}
+	}
+
+	svc.notify(mentionNotifications(companylib.Notification{
+		ActorUserName: record.AuthorUserID,
+		ActorName:     record.AuthorName,
+		TeamId:        record.TeamID,
+		PostId:        record.PostID,
+	}, newMentions, "a post"))
+
 	return svc.okResp(svc.buildPostResponse(*record, userName, nil, nil), nil)
 }
 
+// postEditUpdate writes only the attributes an edit changed, so like, comment and RSVP counters
+// and the waitlist, which are kept up to date by their own conditional writes, are left alone.
+// A changed checklist schedule is only written if the reset job has not moved it meanwhile.
+func postEditUpdate(previous, edited PostRecord) (*dynamodb.UpdateItemInput, error) {
+	before, err := attributevalue.MarshalMap(previous)
+	if err != nil {
+		return nil, err
+	}
+	after, err := attributevalue.MarshalMap(edited)
+	if err != nil {
+		return nil, err
+	}
+
+	var sets, removes []string
+	names := map[string]string{}
+	values := map[string]types.AttributeValue{}
+	diff := func(path []string, prev, next types.AttributeValue) {
+		if reflect.DeepEqual(prev, next) {
+			return
+		}
+		placeholders := make([]string, len(path))
+		for i, name := range path {
+			placeholders[i] = "#" + name
+			names["#"+name] = name
+		}
+		attr := strings.Join(placeholders, ".")
+		if next == nil {
+			removes = append(removes, attr)
+			return
+		}
+		value := ":" + strings.Join(path, "_")
+		values[value] = next
+		sets = append(sets, attr+" = "+value)
+	}
+
+	keys := map[string]bool{}
+	for key := range before {
+		keys[key] = true
+	}
+	for key := range after {
+		keys[key] = true
+	}
+	for key := range keys {
+		switch key {
+		case "PK", "SK":
+			continue
+		case "data":
+			prevData, ok := before[key].(*types.AttributeValueMemberM)
+			nextData, _ := after[key].(*types.AttributeValueMemberM)
+			if !ok || nextData == nil {
+				diff([]string{key}, before[key], after[key])
+				continue
+			}
+			fields := map[string]bool{}
+			for field := range prevData.Value {
+				fields[field] = true
+			}
+			for field := range nextData.Value {
+				fields[field] = true
+			}
+			for field := range fields {
+				diff([]string{key, field}, prevData.Value[field], nextData.Value[field])
+			}
+		default:
+			diff([]string{key}, before[key], after[key])
+		}
+	}
+	sort.Strings(sets)
+	sort.Strings(removes)
+
+	condition := "attribute_exists(PK)"
+	if previous.ScheduleKey != edited.ScheduleKey || previous.NextResetAt != edited.NextResetAt || previous.LastResetAt != edited.LastResetAt {
+		names["#nextResetAt"] = "nextResetAt"
+		if previous.NextResetAt == "" {
+			condition += " AND attribute_not_exists(#nextResetAt)"
+		} else {
+			condition += " AND #nextResetAt = :readNextResetAt"
+			values[":readNextResetAt"] = &types.AttributeValueMemberS{Value: previous.NextResetAt}
+		}
+	}
+
+	update := ""
+	if len(sets) > 0 {
+		update = "SET " + strings.Join(sets, ", ")
+	}
+	if len(removes) > 0 {
+		update = strings.TrimSpace(update + " REMOVE " + strings.Join(removes, ", "))
+	}
+	input := &dynamodb.UpdateItemInput{
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: previous.PK},
+			"SK": &types.AttributeValueMemberS{Value: previous.SK},
+		},
+		UpdateExpression:         aws.String(update),
+		ConditionExpression:      aws.String(condition),
+		ExpressionAttributeNames: names,
+	}
+	if len(values) > 0 {
+		input.ExpressionAttributeValues = values
+	}
+	return input, nil
+}
+
 // ==================== Delete Post ====================
 
 func (svc *Service) deletePost(teamID, postID, userName string) (events.APIGatewayProxyResponse, error) {
@@ -367,6 +540,10 @@ func (svc *Service) deletePost(teamID, postID, userName string) (events.APIGatew
 		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to delete post")
 	}
 
+	if PostType(record.Type) == PostTypeEvent {
+		svc.removeEventCalendar(*record)
+	}
+
 	return svc.noContentResp()
 }
 
@@ -380,8 +557,11 @@ func (svc *Service) fetchPostRecord(postID string) (*PostRecord, error) {
 			"SK": &types.AttributeValueMemberS{Value: SKMetadata},
 		},
 	})
-	if err != nil || result.Item == nil {
-		return nil, fmt.Errorf("post not found: %s", postID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get post %s: %w", postID, err)
+	}
+	if result.Item == nil {
+		return nil, fmt.Errorf("%w: %s", errPostNotFound, postID)
 	}
 	var record PostRecord
 	if err := attributevalue.UnmarshalMap(result.Item, &record); err != nil {
@@ -407,6 +587,13 @@ func (svc *Service) fetchChecklistItems(postID string) ([]ChecklistItemRecord, e
 	return items, nil
 }
 
+// sortChecklistItems orders items by creation time, the order they are shown in
+func sortChecklistItems(items []ChecklistItemRecord) {
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].CreatedAt < items[j].CreatedAt
+	})
+}
+
 func (svc *Service) userHasLikedPost(postID, userName string) (bool, error) {
 	result, err := svc.ddb.GetItem(svc.ctx, &dynamodb.GetItemInput{
 		TableName: aws.String(svc.feedTable),
@@ -438,6 +625,7 @@ func (svc *Service) buildPostResponse(r PostRecord, userName string, comments in
 		},
 		"content":      nilIfEmpty(r.Content),
 		"tags":         r.Tags,
+		"mentions":     mentionsOrEmpty(r.Mentions),
 		"likeCount":    r.LikeCount,
 		"commentCount": r.CommentCount,
 		"userHasLiked": userHasLiked,
@@ -490,9 +678,7 @@ func (svc *Service) buildPostResponse(r PostRecord, userName string, comments in
 		items := make([]map[string]interface{}, 0)
 		completedCount := 0
 		if checklistItems != nil {
-			sort.Slice(checklistItems, func(i, j int) bool {
-				return checklistItems[i].CreatedAt < checklistItems[j].CreatedAt
-			})
+			sortChecklistItems(checklistItems)
 			for _, ci := range checklistItems {
 				items = append(items, map[string]interface{}{
 					"itemId":    ci.ItemID,
@@ -504,7 +690,7 @@ func (svc *Service) buildPostResponse(r PostRecord, userName string, comments in
 				}
 			}
 		}
-		resp["checklist"] = map[string]interface{}{
+		checklist := map[string]interface{}{
 			"title":              r.Data.ChecklistTitle,
 			"isRecurring":        r.Data.IsRecurring,
 			"recurringFrequency": r.Data.RecurringFrequency,
@@ -512,14 +698,41 @@ func (svc *Service) buildPostResponse(r PostRecord, userName string, comments in
 			"completedCount":     completedCount,
 			"totalCount":         len(items),
 		}
+		if r.Data.IsRecurring {
+			checklist["periodStart"] = nilIfEmpty(r.LastResetAt)
+			checklist["nextResetAt"] = nilIfEmpty(r.NextResetAt)
+		}
+		resp["checklist"] = checklist
 
 	case PostTypeEvent:
-		resp["event"] = map[string]interface{}{
-			"title":     r.Data.EventTitle,
-			"eventDate": r.Data.EventDate,
-			"eventTime": r.Data.EventTime,
-			"location":  r.Data.Location,
+		event := map[string]interface{}{
+			"title":                 r.Data.EventTitle,
+			"eventDate":             r.Data.EventDate,
+			"eventTime":             r.Data.EventTime,
+			"timezone":              nilIfEmpty(r.Data.EventTimezone),
+			"durationMinutes":       r.Data.DurationMinutes,
+			"location":              r.Data.Location,
+			"capacity":              nil,
+			"spotsLeft":             nil,
+			"reminderMinutesBefore": r.Data.ReminderMinutesBefore,
+			"rsvpCounts":            rsvpCounts(r.Data),
+			"userRsvpStatus":        nil,
+		}
+		if r.Data.DurationMinutes == 0 {
+			event["durationMinutes"] = defaultEventDurationMinutes
+		}
+		if r.Data.Capacity > 0 {
+			event["capacity"] = r.Data.Capacity
+			event["spotsLeft"] = max(0, r.Data.Capacity-r.Data.GoingCount)
+		}
+		if start, allDay, err := eventStart(r.Data); err == nil {
+			event["startsAt"] = start.Format(time.RFC3339)
+			event["allDay"] = allDay
+		}
+		if rsvp, err := svc.fetchRSVP(r.PostID, userName); err == nil && rsvp != nil {
+			event["userRsvpStatus"] = rsvp.Status
 		}
+		resp["event"] = event
 	}
 
 	return resp
@@ -0,0 +1,64 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostEditUpdate(t *testing.T) {
+	post := PostRecord{
+		PK: "TEAM#t1", SK: "POST#2024-05-15T13:30:00Z#p1", PostID: "p1", TeamID: "t1", Type: string(PostTypeEvent),
+		Content: "Offsite", Tags: []Tag{{Type: "milestone", RefID: "m1", Name: "Planning"}}, LikeCount: 4, CommentCount: 2,
+		CreatedAt: "2024-05-15T13:30:00Z", UpdatedAt: "2024-05-15T13:30:00Z",
+		Data: PostData{EventTitle: "Offsite", EventDate: "2024-06-01", Capacity: 10, GoingCount: 10, WaitlistCount: 3},
+	}
+
+	t.Run("It should write only the edited fields and leave the counters alone", func(t *testing.T) {
+		edited := post
+		edited.Content = "Team offsite"
+		edited.Tags = nil
+		edited.UpdatedAt = "2024-05-16T09:00:00Z"
+		edited.Data.Capacity = 12
+
+		input, err := postEditUpdate(post, edited)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "SET #content = :content, #data.#capacity = :data_capacity, #updatedAt = :updatedAt REMOVE #tags", aws.ToString(input.UpdateExpression))
+		assert.Equal(t, "attribute_exists(PK)", aws.ToString(input.ConditionExpression))
+		assert.Equal(t, &types.AttributeValueMemberN{Value: "12"}, input.ExpressionAttributeValues[":data_capacity"])
+		assert.NotContains(t, input.ExpressionAttributeNames, "#goingCount")
+		assert.NotContains(t, input.ExpressionAttributeNames, "#likeCount")
+		assert.Equal(t, &types.AttributeValueMemberS{Value: "POST#2024-05-15T13:30:00Z#p1"}, input.Key["SK"])
+	})
+
+	t.Run("It should only move a checklist schedule the reset job has not moved", func(t *testing.T) {
+		checklist := PostRecord{PK: "TEAM#t1", SK: "POST#p2", Type: string(PostTypeChecklist), UpdatedAt: "2024-05-15T13:30:00Z",
+			Data: PostData{ChecklistTitle: "Standup"}}
+		edited := checklist
+		edited.UpdatedAt = "2024-05-15T14:00:00Z"
+		edited.Data.IsRecurring = true
+		edited.Data.RecurringFrequency = FrequencyDaily
+		applyChecklistSchedule(&edited, time.Date(2024, 5, 15, 14, 0, 0, 0, time.UTC))
+
+		input, err := postEditUpdate(checklist, edited)
+
+		assert.NoError(t, err)
+		assert.Contains(t, aws.ToString(input.UpdateExpression), "#nextResetAt = :nextResetAt")
+		assert.Equal(t, "attribute_exists(PK) AND attribute_not_exists(#nextResetAt)", aws.ToString(input.ConditionExpression))
+
+		recurring := edited
+		edited = recurring
+		edited.Data.RecurringFrequency = FrequencyWeekly
+		applyChecklistSchedule(&edited, time.Date(2024, 5, 15, 15, 0, 0, 0, time.UTC))
+
+		input, err = postEditUpdate(recurring, edited)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "attribute_exists(PK) AND #nextResetAt = :readNextResetAt", aws.ToString(input.ConditionExpression))
+		assert.Equal(t, &types.AttributeValueMemberS{Value: "2024-05-16T00:00:00Z"}, input.ExpressionAttributeValues[":readNextResetAt"])
+	})
+}
@@ -2,6 +2,7 @@ package common
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -9,6 +10,8 @@ import (
 	"strings"
 
 	"github.com/aws/aws-lambda-go/events"
+
+	companylib "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/company-lib"
 )
 
 // ==================== Entry Point ====================
@@ -36,6 +39,16 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 		return svc.errResp(http.StatusNotFound, "NOT_FOUND", "Route not found")
 	}
 
+	// Suspended organizations are read-only
+	if companylib.IsWriteMethod(request.HTTPMethod) {
+		if err := svc.ensureRequestWritable(parts); errors.Is(err, companylib.ErrOrgReadOnly) {
+			return svc.errResp(http.StatusForbidden, "ORG_READ_ONLY", err.Error())
+		} else if err != nil {
+			svc.logger.Printf("Could not check billing status for %s: %v", request.Path, err)
+			return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Could not check organization billing status")
+		}
+	}
+
 	switch routeGroup {
 	case RouteGroupPosts:
 		return svc.handlePosts(request, parts, userName, cognitoID)
@@ -49,6 +62,10 @@ func (svc *Service) HandleWithGroup(request events.APIGatewayProxyRequest, route
 		return svc.handleChecklist(request, parts, userName, cognitoID)
 	case RouteGroupTask:
 		return svc.handleTask(request, parts, userName, cognitoID)
+	case RouteGroupNotifications:
+		return svc.handleNotifications(request, parts, userName, cognitoID)
+	case RouteGroupEvents:
+		return svc.handleEvents(request, parts, userName, cognitoID)
 	default:
 		return svc.errResp(http.StatusNotFound, "NOT_FOUND", "Route not found")
 	}
@@ -163,3 +180,26 @@ func (svc *Service) ensureTeamAdminOrAuthor(teamID, userName, authorUserName str
 	}
 	return nil
 }
+
+// ensureRequestWritable resolves the team a write targets (/v2/teams/{teamId}/... or
+// /v2/posts/{postId}/...) and rejects it when the team's organization is suspended.
+func (svc *Service) ensureRequestWritable(parts []string) error {
+	if len(parts) < 3 {
+		return nil
+	}
+
+	teamID := ""
+	switch parts[1] {
+	case "teams":
+		teamID = parts[2]
+	case "posts":
+		post, err := svc.fetchPostRecord(parts[2])
+		if err != nil {
+			// Missing posts are reported by the route handler
+			return nil
+		}
+		teamID = post.TeamID
+	}
+
+	return svc.orgSVC.EnsureTeamWritable(svc.teamsSVC, teamID)
+}
@@ -1,6 +1,7 @@
 package common
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
@@ -9,6 +10,8 @@ import (
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	companylib "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/company-lib"
 )
 
 // ==================== Route: Likes ====================
@@ -30,7 +33,7 @@ func (svc *Service) handleLikes(request events.APIGatewayProxyRequest, parts []s
 		case "DELETE":
 			return svc.unlikePost(postID, userName)
 		case "GET":
-			return svc.getPostLikes(postID)
+			return svc.getPostLikes(postID, request.QueryStringParameters)
 		}
 	}
 
@@ -89,6 +92,10 @@ func (svc *Service) likePost(postID, userName string) (events.APIGatewayProxyRes
 		},
 	})
 
+	if post, err := svc.fetchPostRecord(postID); err == nil && post.AuthorUserID != userName {
+		svc.notify([]companylib.Notification{likeNotification(*post, userName, svc.actorName(userName))})
+	}
+
 	return svc.okResp(map[string]interface{}{"liked": true}, nil)
 }
 
@@ -126,21 +133,37 @@ func (svc *Service) unlikePost(postID, userName string) (events.APIGatewayProxyR
 
 // ==================== Get Post Likes ====================
 
-func (svc *Service) getPostLikes(postID string) (events.APIGatewayProxyResponse, error) {
-	result, err := svc.ddb.Query(svc.ctx, &dynamodb.QueryInput{
+func (svc *Service) getPostLikes(postID string, queryParams map[string]string) (events.APIGatewayProxyResponse, error) {
+	limit := pageLimit(queryParams, 50)
+
+	input := &dynamodb.QueryInput{
 		TableName:              aws.String(svc.feedTable),
 		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :prefix)"),
 		ExpressionAttributeValues: map[string]types.AttributeValue{
 			":pk":     &types.AttributeValueMemberS{Value: PrefixPost + postID},
 			":prefix": &types.AttributeValueMemberS{Value: SKLikePrefix},
 		},
-	})
+	}
+	items, nextCursor, err := svc.queryPage(pageQuery{
+		input:          input,
+		keyAttrs:       tableKeyAttrs,
+		partitionAttr:  "PK",
+		partitionValue: PrefixPost + postID,
+	}, limit, queryString(queryParams, "cursor"))
+	if err != nil {
+		if errors.Is(err, errInvalidCursor) {
+			return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "Invalid cursor")
+		}
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to fetch likes")
+	}
+
+	total, err := svc.queryCount(input)
 	if err != nil {
 		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to fetch likes")
 	}
 
 	var records []LikeRecord
-	attributevalue.UnmarshalListOfMaps(result.Items, &records)
+	attributevalue.UnmarshalListOfMaps(items, &records)
 
 	users := make([]map[string]interface{}, 0, len(records))
 	for _, r := range records {
@@ -149,7 +172,7 @@ func (svc *Service) getPostLikes(postID string) (events.APIGatewayProxyResponse,
 			"likedAt": r.LikedAt,
 		})
 	}
-	return svc.okResp(users, &MetaResponse{Total: len(users), Page: 1, Limit: len(users)})
+	return svc.okResp(users, &MetaResponse{Total: &total, Count: len(users), Limit: limit, NextCursor: nextCursor})
 }
 
 // ==================== Like Comment ====================
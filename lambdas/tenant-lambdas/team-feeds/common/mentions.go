@@ -0,0 +1,152 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	companylib "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/company-lib"
+)
+
+// ==================== Mentions ====================
+//
+// Teammates are mentioned by writing @{email} in a post or comment, or by listing their user IDs
+// in the request's `mentions` array. Only active members of the post's team are kept: unknown
+// @{email} text stays plain text, while unknown IDs in `mentions` are rejected.
+
+const maxMentions = 20
+
+var errInvalidMentions = errors.New("mentions must be active members of this team")
+
+// errInvalidRecipient is returned when a kudos recipient or task assignee is not an active member
+var errInvalidRecipient = errors.New("is not an active member of this team")
+
+// An @ must start the text or follow a character that cannot be part of an email address, so
+// the @ inside jane@example.com is not read as a mention of example.com
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w.%+-])@([A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,})`)
+
+// parseMentions returns the user IDs written as @{email} in text, lower-cased, in order of
+// first appearance
+func parseMentions(text string) []string {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		id := strings.ToLower(match[1])
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// resolveMentions returns the team members mentioned in text or listed in explicit
+func (svc *Service) resolveMentions(teamID, text string, explicit []string) ([]Mention, error) {
+	inline := parseMentions(text)
+	if len(inline) == 0 && len(explicit) == 0 {
+		return nil, nil
+	}
+
+	members, err := svc.teamsSVC.GetTeamMembers(teamID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load team members: %w", err)
+	}
+	return matchMentions(members, inline, explicit)
+}
+
+// matchMentions keeps the candidates that are active members, explicit IDs first
+func matchMentions(members []companylib.TeamMember, inline, explicit []string) ([]Mention, error) {
+	active := make(map[string]companylib.TeamMember, len(members))
+	for _, m := range members {
+		if m.IsActive {
+			active[strings.ToLower(m.UserName)] = m
+		}
+	}
+
+	var unknown []string
+	for _, id := range explicit {
+		if _, ok := active[strings.ToLower(strings.TrimSpace(id))]; !ok {
+			unknown = append(unknown, id)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("%w: %s", errInvalidMentions, strings.Join(unknown, ", "))
+	}
+
+	seen := make(map[string]bool)
+	var mentions []Mention
+	for _, id := range append(append([]string{}, explicit...), inline...) {
+		member, ok := active[strings.ToLower(strings.TrimSpace(id))]
+		if !ok || seen[member.UserName] {
+			continue
+		}
+		seen[member.UserName] = true
+		mentions = append(mentions, Mention{UserID: member.UserName, Name: member.DisplayName})
+	}
+
+	if len(mentions) > maxMentions {
+		return nil, fmt.Errorf("%w: at most %d people can be mentioned", errInvalidMentions, maxMentions)
+	}
+	return mentions, nil
+}
+
+// teamRecipient returns the active member of teamID named as a kudos recipient or task assignee,
+// matching the user ID without regard to case
+func (svc *Service) teamRecipient(teamID, userID string) (companylib.TeamMember, error) {
+	members, err := svc.teamsSVC.GetTeamMembers(teamID)
+	if err != nil {
+		return companylib.TeamMember{}, fmt.Errorf("failed to load team members: %w", err)
+	}
+	member, ok := findActiveMember(members, userID)
+	if !ok {
+		return companylib.TeamMember{}, fmt.Errorf("%s %w", userID, errInvalidRecipient)
+	}
+	return member, nil
+}
+
+func findActiveMember(members []companylib.TeamMember, userID string) (companylib.TeamMember, bool) {
+	id := strings.ToLower(strings.TrimSpace(userID))
+	for _, m := range members {
+		if m.IsActive && strings.ToLower(m.UserName) == id {
+			return m, true
+		}
+	}
+	return companylib.TeamMember{}, false
+}
+
+// addedMentions returns the mentions in next that were not in prev, so edits only notify
+// people who were newly mentioned
+func addedMentions(prev, next []Mention) []Mention {
+	before := make(map[string]bool, len(prev))
+	for _, m := range prev {
+		before[m.UserID] = true
+	}
+	var added []Mention
+	for _, m := range next {
+		if !before[m.UserID] {
+			added = append(added, m)
+		}
+	}
+	return added
+}
+
+// mentionErrResp maps a resolveMentions or teamRecipient error to an API response
+func (svc *Service) mentionErrResp(err error) (events.APIGatewayProxyResponse, error) {
+	if errors.Is(err, errInvalidMentions) || errors.Is(err, errInvalidRecipient) {
+		return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+	}
+	svc.logger.Printf("Error resolving mentions: %v", err)
+	return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to resolve mentions")
+}
+
+func mentionsOrEmpty(mentions []Mention) []Mention {
+	if mentions == nil {
+		return []Mention{}
+	}
+	return mentions
+}
@@ -24,6 +24,15 @@ const (
 	SKCommentPrefix = "CMMNT#"
 	SKVotePrefix    = "VOTE#"
 	SKItemPrefix    = "ITEM#"
+	SKRunPrefix     = "RUN#"
+	SKRSVPPrefix    = "RSVP#"
+
+	// Team calendar: PK = EVENTS#{teamId}, SK = {startsAt}#{postId}
+	PrefixTeamEvents = "EVENTS#"
+
+	// Calendar feed subscriptions
+	PrefixCalendarToken        = "CALTOKEN#"
+	PrefixCalendarSubscription = "CALSUB#"
 )
 
 // ==================== Tag ====================
@@ -34,6 +43,14 @@ type Tag struct {
 	Name  string `json:"name" dynamodbav:"name"`
 }
 
+// ==================== Mention ====================
+
+// Mention is a teammate mentioned in a post or comment. UserID is the member's user name (email).
+type Mention struct {
+	UserID string `json:"userId" dynamodbav:"userId"`
+	Name   string `json:"name,omitempty" dynamodbav:"name,omitempty"`
+}
+
 // ==================== Kudos ====================
 
 type KudosData struct {
@@ -92,6 +109,18 @@ type EventData struct {
 	Location  string `json:"location,omitempty" dynamodbav:"location,omitempty"`
 }
 
+// RSVPStatus is a member's response to an event post
+type RSVPStatus string
+
+const (
+	RSVPGoing    RSVPStatus = "going"
+	RSVPMaybe    RSVPStatus = "maybe"
+	RSVPDeclined RSVPStatus = "declined"
+
+	// RSVPWaitlisted is recorded instead of going while the event is at capacity
+	RSVPWaitlisted RSVPStatus = "waitlisted"
+)
+
 // ==================== PostData (type-specific map stored under "data" in DDB) ====================
 
 type PostData struct {
@@ -120,10 +149,18 @@ type PostData struct {
 	RecurringFrequency string `dynamodbav:"recurringFrequency,omitempty"`
 
 	// Event fields
-	EventTitle string `dynamodbav:"eventTitle,omitempty"`
-	EventDate  string `dynamodbav:"eventDate,omitempty"`
-	EventTime  string `dynamodbav:"eventTime,omitempty"`
-	Location   string `dynamodbav:"location,omitempty"`
+	EventTitle            string `dynamodbav:"eventTitle,omitempty"`
+	EventDate             string `dynamodbav:"eventDate,omitempty"`
+	EventTime             string `dynamodbav:"eventTime,omitempty"`
+	EventTimezone         string `dynamodbav:"eventTimezone,omitempty"`
+	DurationMinutes       int    `dynamodbav:"durationMinutes,omitempty"`
+	Location              string `dynamodbav:"location,omitempty"`
+	Capacity              int    `dynamodbav:"capacity,omitempty"` // 0 = unlimited
+	ReminderMinutesBefore int    `dynamodbav:"reminderMinutesBefore,omitempty"`
+	GoingCount            int    `dynamodbav:"goingCount,omitempty"`
+	MaybeCount            int    `dynamodbav:"maybeCount,omitempty"`
+	DeclinedCount         int    `dynamodbav:"declinedCount,omitempty"`
+	WaitlistCount         int    `dynamodbav:"waitlistCount,omitempty"`
 }
 
 // ==================== Author ====================
@@ -151,8 +188,9 @@ type PostRecord struct {
 	AuthorRole       string `dynamodbav:"authorRole,omitempty"`
 	AuthorProfilePic string `dynamodbav:"authorProfilePic,omitempty"`
 
-	Content string `dynamodbav:"content,omitempty"`
-	Tags    []Tag  `dynamodbav:"tags,omitempty"`
+	Content  string    `dynamodbav:"content,omitempty"`
+	Tags     []Tag     `dynamodbav:"tags,omitempty"`
+	Mentions []Mention `dynamodbav:"mentions,omitempty"`
 
 	LikeCount    int `dynamodbav:"likeCount"`
 	CommentCount int `dynamodbav:"commentCount"`
@@ -160,6 +198,12 @@ type PostRecord struct {
 	CreatedAt string `dynamodbav:"createdAt"`
 	UpdatedAt string `dynamodbav:"updatedAt"`
 
+	// Recurring checklist schedule. ScheduleKey is only set while a checklist is recurring, which
+	// keeps ChecklistScheduleIndex sparse.
+	ScheduleKey string `dynamodbav:"scheduleKey,omitempty"`
+	NextResetAt string `dynamodbav:"nextResetAt,omitempty"`
+	LastResetAt string `dynamodbav:"lastResetAt,omitempty"`
+
 	// Type-specific data stored as a DDB Map under the "data" field
 	Data PostData `dynamodbav:"data,omitempty"`
 }
@@ -167,19 +211,20 @@ type PostRecord struct {
 // ==================== Comment DDB Record ====================
 
 type CommentRecord struct {
-	PK              string `dynamodbav:"PK"`
-	SK              string `dynamodbav:"SK"`
-	GSI1PK          string `dynamodbav:"GSI1PK"`
-	GSI1SK          string `dynamodbav:"GSI1SK"`
-	CommentID       string `dynamodbav:"commentId"`
-	PostID          string `dynamodbav:"postId"`
-	AuthorUserID    string `dynamodbav:"authorUserId"`
-	AuthorName      string `dynamodbav:"authorName"`
-	Content         string `dynamodbav:"content"`
-	ParentCommentID string `dynamodbav:"parentCommentId,omitempty"`
-	LikeCount       int    `dynamodbav:"likeCount"`
-	CreatedAt       string `dynamodbav:"createdAt"`
-	UpdatedAt       string `dynamodbav:"updatedAt"`
+	PK              string    `dynamodbav:"PK"`
+	SK              string    `dynamodbav:"SK"`
+	GSI1PK          string    `dynamodbav:"GSI1PK"`
+	GSI1SK          string    `dynamodbav:"GSI1SK"`
+	CommentID       string    `dynamodbav:"commentId"`
+	PostID          string    `dynamodbav:"postId"`
+	AuthorUserID    string    `dynamodbav:"authorUserId"`
+	AuthorName      string    `dynamodbav:"authorName"`
+	Content         string    `dynamodbav:"content"`
+	ParentCommentID string    `dynamodbav:"parentCommentId,omitempty"`
+	Mentions        []Mention `dynamodbav:"mentions,omitempty"`
+	LikeCount       int       `dynamodbav:"likeCount"`
+	CreatedAt       string    `dynamodbav:"createdAt"`
+	UpdatedAt       string    `dynamodbav:"updatedAt"`
 }
 
 // ==================== Like DDB Record ====================
@@ -204,12 +249,99 @@ type VoteRecord struct {
 // ==================== Checklist Item DDB Record ====================
 
 type ChecklistItemRecord struct {
-	PK        string `dynamodbav:"PK"`
-	SK        string `dynamodbav:"SK"`
-	ItemID    string `dynamodbav:"itemId"`
-	PostID    string `dynamodbav:"postId"`
-	Text      string `dynamodbav:"text"`
-	Completed bool   `dynamodbav:"completed"`
+	PK          string `dynamodbav:"PK"`
+	SK          string `dynamodbav:"SK"`
+	ItemID      string `dynamodbav:"itemId"`
+	PostID      string `dynamodbav:"postId"`
+	Text        string `dynamodbav:"text"`
+	Completed   bool   `dynamodbav:"completed"`
+	CompletedBy string `dynamodbav:"completedBy,omitempty"`
+	CompletedAt string `dynamodbav:"completedAt,omitempty"`
+	CreatedAt   string `dynamodbav:"createdAt"`
+}
+
+// ==================== Checklist Run DDB Record ====================
+
+// ChecklistRunRecord snapshots a recurring checklist at the end of one period, before its items
+// are reset. SK is RUN#{periodEnd}, so runs sort oldest first within the post's partition.
+type ChecklistRunRecord struct {
+	PK             string             `json:"-" dynamodbav:"PK"`
+	SK             string             `json:"-" dynamodbav:"SK"`
+	PostID         string             `json:"postId" dynamodbav:"postId"`
+	TeamID         string             `json:"teamId" dynamodbav:"teamId"`
+	Frequency      string             `json:"frequency" dynamodbav:"frequency"`
+	PeriodStart    string             `json:"periodStart" dynamodbav:"periodStart"`
+	PeriodEnd      string             `json:"periodEnd" dynamodbav:"periodEnd"`
+	TotalCount     int                `json:"totalCount" dynamodbav:"totalCount"`
+	CompletedCount int                `json:"completedCount" dynamodbav:"completedCount"`
+	CompletionRate float64            `json:"completionRate" dynamodbav:"completionRate"`
+	Items          []ChecklistRunItem `json:"items" dynamodbav:"items"`
+	RecordedAt     string             `json:"recordedAt" dynamodbav:"recordedAt"`
+}
+
+type ChecklistRunItem struct {
+	ItemID      string `json:"itemId" dynamodbav:"itemId"`
+	Text        string `json:"text" dynamodbav:"text"`
+	Completed   bool   `json:"completed" dynamodbav:"completed"`
+	CompletedBy string `json:"completedBy,omitempty" dynamodbav:"completedBy,omitempty"`
+	CompletedAt string `json:"completedAt,omitempty" dynamodbav:"completedAt,omitempty"`
+}
+
+// ==================== RSVP DDB Record ====================
+
+type RSVPRecord struct {
+	PK           string     `json:"-" dynamodbav:"PK"`
+	SK           string     `json:"-" dynamodbav:"SK"`
+	PostID       string     `json:"postId" dynamodbav:"postId"`
+	UserID       string     `json:"userId" dynamodbav:"userId"`
+	Name         string     `json:"name" dynamodbav:"name"`
+	Status       RSVPStatus `json:"status" dynamodbav:"status"`
+	WaitlistedAt string     `json:"waitlistedAt,omitempty" dynamodbav:"waitlistedAt,omitempty"`
+	RespondedAt  string     `json:"respondedAt" dynamodbav:"respondedAt"`
+}
+
+// ==================== Event Calendar DDB Record ====================
+
+// EventCalendarEntry lists an event post on its team's calendar, ordered by start time. While a
+// reminder is pending, its key and time are stored as scheduleKey/nextResetAt, which puts the
+// entry on the sparse ChecklistScheduleIndex under EVENT_REMINDER.
+type EventCalendarEntry struct {
+	PK              string `dynamodbav:"PK"`
+	SK              string `dynamodbav:"SK"`
+	PostID          string `dynamodbav:"postId"`
+	TeamID          string `dynamodbav:"teamId"`
+	Title           string `dynamodbav:"title"`
+	Description     string `dynamodbav:"description,omitempty"`
+	Location        string `dynamodbav:"location,omitempty"`
+	StartsAt        string `dynamodbav:"startsAt"` // UTC, RFC3339
+	AllDay          bool   `dynamodbav:"allDay,omitempty"`
+	EventDate       string `dynamodbav:"eventDate"`
+	Timezone        string `dynamodbav:"timezone,omitempty"`
+	DurationMinutes int    `dynamodbav:"durationMinutes,omitempty"`
+	OrganizerName   string `dynamodbav:"organizerName,omitempty"`
+	ReminderKey     string `dynamodbav:"scheduleKey,omitempty"`
+	RemindAt        string `dynamodbav:"nextResetAt,omitempty"`
+	ReminderSentFor string `dynamodbav:"reminderSentFor,omitempty"` // StartsAt the reminder was sent for
+	UpdatedAt       string `dynamodbav:"updatedAt"`
+}
+
+// ==================== Calendar Subscription DDB Records ====================
+
+// CalendarTokenRecord resolves a calendar feed URL. Only the SHA-256 of the token is stored.
+type CalendarTokenRecord struct {
+	PK        string `dynamodbav:"PK"` // CALTOKEN#{tokenHash}
+	SK        string `dynamodbav:"SK"` // #METADATA
+	TeamID    string `dynamodbav:"teamId"`
+	UserName  string `dynamodbav:"userName"`
+	CreatedAt string `dynamodbav:"createdAt"`
+}
+
+// CalendarSubscriptionRecord points at a member's current token for a team so it can be rotated
+// or revoked
+type CalendarSubscriptionRecord struct {
+	PK        string `dynamodbav:"PK"` // CALSUB#{teamId}#{userName}
+	SK        string `dynamodbav:"SK"` // #METADATA
+	TokenHash string `dynamodbav:"tokenHash"`
 	CreatedAt string `dynamodbav:"createdAt"`
 }
 
@@ -220,6 +352,9 @@ type CreatePostRequest struct {
 	Content string   `json:"content,omitempty"`
 	Tags    []Tag    `json:"tags,omitempty"`
 
+	// User IDs to mention in addition to any @{email} written in content
+	Mentions []string `json:"mentions,omitempty"`
+
 	// kudos
 	RecipientUserID string `json:"recipientUserId,omitempty"`
 
@@ -241,18 +376,24 @@ type CreatePostRequest struct {
 	RecurringFrequency string          `json:"recurringFrequency,omitempty"`
 
 	// event
-	EventDate string `json:"eventDate,omitempty"`
-	EventTime string `json:"eventTime,omitempty"`
-	Location  string `json:"location,omitempty"`
+	EventDate             string `json:"eventDate,omitempty"`
+	EventTime             string `json:"eventTime,omitempty"`
+	Timezone              string `json:"timezone,omitempty"`
+	DurationMinutes       int    `json:"durationMinutes,omitempty"`
+	Location              string `json:"location,omitempty"`
+	Capacity              *int   `json:"capacity,omitempty"`
+	ReminderMinutesBefore *int   `json:"reminderMinutesBefore,omitempty"`
 }
 
 type AddCommentRequest struct {
-	Content         string `json:"content"`
-	ParentCommentID string `json:"parentCommentId,omitempty"`
+	Content         string   `json:"content"`
+	ParentCommentID string   `json:"parentCommentId,omitempty"`
+	Mentions        []string `json:"mentions,omitempty"`
 }
 
 type EditCommentRequest struct {
-	Content string `json:"content"`
+	Content  string   `json:"content"`
+	Mentions []string `json:"mentions,omitempty"`
 }
 
 type CastVoteRequest struct {
@@ -267,6 +408,10 @@ type AddChecklistItemRequest struct {
 	Text string `json:"text"`
 }
 
+type RSVPRequest struct {
+	Status RSVPStatus `json:"status"` // going | maybe | declined
+}
+
 type UpdateTaskStatusRequest struct {
 	Status string `json:"status"` // todo | in-progress | done
 }
@@ -275,12 +420,27 @@ type LogTimeRequest struct {
 	Hours float64 `json:"hours"`
 }
 
+// MarkNotificationsReadRequest marks the listed notifications, or the whole inbox when All is set
+type MarkNotificationsReadRequest struct {
+	NotificationIDs []string `json:"notificationIds,omitempty"`
+	All             bool     `json:"all,omitempty"`
+}
+
+type UpdateNotificationPreferencesRequest struct {
+	EmailDigest *bool `json:"emailDigest"`
+}
+
 // ==================== Response Envelope ====================
 
+// MetaResponse describes a list page. Total counts every matching item and Count the items in
+// this page. Cursor-paginated lists leave Page unset and set NextCursor while more items remain;
+// search results have no Total.
 type MetaResponse struct {
-	Total int `json:"total"`
-	Page  int `json:"page"`
-	Limit int `json:"limit"`
+	Total      *int   `json:"total,omitempty"`
+	Count      int    `json:"count"`
+	Page       int    `json:"page,omitempty"`
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"nextCursor,omitempty"`
 }
 
 type ErrorResponse struct {
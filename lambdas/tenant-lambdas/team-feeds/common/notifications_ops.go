@@ -0,0 +1,284 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	companylib "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/company-lib"
+)
+
+// ==================== Route: Notifications ====================
+//
+// GET  /v2/users/me/notifications?unreadOnly=&cursor=&limit=
+// GET  /v2/users/me/notifications/unread-count
+// POST /v2/users/me/notifications/read
+// POST /v2/users/me/notifications/{notificationId}/read
+// GET  /v2/users/me/notifications/preferences
+// PUT  /v2/users/me/notifications/preferences
+
+func (svc *Service) handleNotifications(request events.APIGatewayProxyRequest, parts []string, userName, cognitoID string) (events.APIGatewayProxyResponse, error) {
+	if len(parts) < 4 || parts[1] != "users" || parts[2] != "me" || parts[3] != "notifications" {
+		return svc.errResp(http.StatusNotFound, "NOT_FOUND", "Route not found")
+	}
+
+	// /v2/users/me/notifications  (4 parts)
+	if len(parts) == 4 && request.HTTPMethod == "GET" {
+		return svc.listNotifications(userName, request.QueryStringParameters)
+	}
+
+	// /v2/users/me/notifications/{action}  (5 parts)
+	if len(parts) == 5 {
+		switch {
+		case parts[4] == "unread-count" && request.HTTPMethod == "GET":
+			return svc.getUnreadNotificationCount(userName)
+		case parts[4] == "read" && request.HTTPMethod == "POST":
+			return svc.markNotificationsRead(userName, request.Body)
+		case parts[4] == "preferences" && request.HTTPMethod == "GET":
+			return svc.getNotificationPreferences(userName)
+		case parts[4] == "preferences" && request.HTTPMethod == "PUT":
+			return svc.updateNotificationPreferences(userName, request.Body)
+		}
+	}
+
+	// /v2/users/me/notifications/{notificationId}/read  (6 parts)
+	if len(parts) == 6 && parts[5] == "read" && request.HTTPMethod == "POST" {
+		return svc.markNotificationRead(userName, parts[4])
+	}
+
+	return svc.errResp(http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed")
+}
+
+// ==================== List Notifications ====================
+
+func (svc *Service) listNotifications(userName string, queryParams map[string]string) (events.APIGatewayProxyResponse, error) {
+	limit := pageLimit(queryParams, defaultPageLimit)
+	unreadOnly := queryString(queryParams, "unreadOnly") == "true"
+
+	page, err := svc.notifSVC.ListNotifications(userName, unreadOnly, limit, queryString(queryParams, "cursor"))
+	if err != nil {
+		if errors.Is(err, companylib.ErrInvalidNotificationCursor) {
+			return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "Invalid cursor")
+		}
+		svc.logger.Printf("Error listing notifications for %s: %v", userName, err)
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to list notifications")
+	}
+
+	total, err := svc.notifSVC.CountNotifications(userName, unreadOnly)
+	if err != nil {
+		svc.logger.Printf("Error counting notifications for %s: %v", userName, err)
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to list notifications")
+	}
+
+	return svc.okResp(page.Notifications, &MetaResponse{Total: &total, Count: len(page.Notifications), Limit: limit, NextCursor: page.NextCursor})
+}
+
+func (svc *Service) getUnreadNotificationCount(userName string) (events.APIGatewayProxyResponse, error) {
+	count, err := svc.notifSVC.GetUnreadCount(userName)
+	if err != nil {
+		svc.logger.Printf("Error counting notifications for %s: %v", userName, err)
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to count notifications")
+	}
+	return svc.okResp(map[string]interface{}{"unreadCount": count}, nil)
+}
+
+// ==================== Mark Read ====================
+
+func (svc *Service) markNotificationRead(userName, notificationID string) (events.APIGatewayProxyResponse, error) {
+	if _, err := svc.notifSVC.MarkNotificationsRead(userName, []string{notificationID}); err != nil {
+		svc.logger.Printf("Error marking notification %s read: %v", notificationID, err)
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to mark notification as read")
+	}
+	return svc.noContentResp()
+}
+
+func (svc *Service) markNotificationsRead(userName, body string) (events.APIGatewayProxyResponse, error) {
+	req, err := parseBody[MarkNotificationsReadRequest](body)
+	if err != nil {
+		return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+	}
+	if req.All == (len(req.NotificationIDs) > 0) {
+		return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "Provide either notificationIds or all=true")
+	}
+	if len(req.NotificationIDs) > companylib.MaxNotificationIdsPerRequest {
+		return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("At most %d notificationIds per request", companylib.MaxNotificationIdsPerRequest))
+	}
+
+	var marked int
+	if req.All {
+		marked, err = svc.notifSVC.MarkAllNotificationsRead(userName)
+	} else {
+		marked, err = svc.notifSVC.MarkNotificationsRead(userName, req.NotificationIDs)
+	}
+	if err != nil {
+		svc.logger.Printf("Error marking notifications read for %s: %v", userName, err)
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to mark notifications as read")
+	}
+
+	return svc.okResp(map[string]interface{}{"marked": marked}, nil)
+}
+
+// ==================== Preferences ====================
+
+func (svc *Service) getNotificationPreferences(userName string) (events.APIGatewayProxyResponse, error) {
+	prefs, err := svc.notifSVC.GetNotificationPreferences(userName)
+	if err != nil {
+		svc.logger.Printf("Error getting notification preferences for %s: %v", userName, err)
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get notification preferences")
+	}
+	return svc.okResp(prefs, nil)
+}
+
+func (svc *Service) updateNotificationPreferences(userName, body string) (events.APIGatewayProxyResponse, error) {
+	req, err := parseBody[UpdateNotificationPreferencesRequest](body)
+	if err != nil || req.EmailDigest == nil {
+		return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "emailDigest is required")
+	}
+
+	prefs, err := svc.notifSVC.UpdateNotificationPreferences(userName, *req.EmailDigest)
+	if err != nil {
+		svc.logger.Printf("Error updating notification preferences for %s: %v", userName, err)
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to update notification preferences")
+	}
+	return svc.okResp(prefs, nil)
+}
+
+// ==================== Notification Fan-out ====================
+//
+// Notifications are a side effect: failures are logged and never fail the post, comment or like
+// that caused them.
+
+func (svc *Service) notify(notifications []companylib.Notification) {
+	if len(notifications) == 0 {
+		return
+	}
+	if err := svc.notifSVC.CreateNotifications(notifications); err != nil {
+		svc.logger.Printf("Failed to create %d notifications: %v", len(notifications), err)
+	}
+}
+
+// postNotifications notifies the kudos recipient or task assignee of a new post, then anyone
+// mentioned in it
+func postNotifications(post PostRecord, mentions []Mention) []companylib.Notification {
+	base := companylib.Notification{
+		ActorUserName: post.AuthorUserID,
+		ActorName:     post.AuthorName,
+		TeamId:        post.TeamID,
+		PostId:        post.PostID,
+	}
+
+	var notifications []companylib.Notification
+	switch PostType(post.Type) {
+	case PostTypeKudos:
+		n := base
+		n.UserName = post.Data.KudosRecipientUserID
+		n.Type = companylib.NotificationTypeKudos
+		n.Message = fmt.Sprintf("%s gave you kudos", post.AuthorName)
+		notifications = append(notifications, n)
+	case PostTypeTask:
+		n := base
+		n.UserName = post.Data.AssigneeUserID
+		n.Type = companylib.NotificationTypeTaskAssigned
+		n.Message = fmt.Sprintf("%s assigned you %s: %s", post.AuthorName, post.Data.TaskNumber, post.Data.TaskSummary)
+		notifications = append(notifications, n)
+	}
+
+	return append(notifications, mentionNotifications(base, mentions, "a post")...)
+}
+
+// commentNotifications notifies anyone mentioned in a comment, then the post's author
+func commentNotifications(post PostRecord, comment CommentRecord, mentions []Mention) []companylib.Notification {
+	base := companylib.Notification{
+		ActorUserName: comment.AuthorUserID,
+		ActorName:     comment.AuthorName,
+		TeamId:        post.TeamID,
+		PostId:        post.PostID,
+		CommentId:     comment.CommentID,
+	}
+
+	notifications := mentionNotifications(base, mentions, "a comment")
+
+	n := base
+	n.UserName = post.AuthorUserID
+	n.Type = companylib.NotificationTypeComment
+	n.Message = fmt.Sprintf("%s commented on your post", comment.AuthorName)
+	return append(notifications, n)
+}
+
+func likeNotification(post PostRecord, actorUserName, actorName string) companylib.Notification {
+	return companylib.Notification{
+		UserName:      post.AuthorUserID,
+		Type:          companylib.NotificationTypeLike,
+		ActorUserName: actorUserName,
+		ActorName:     actorName,
+		TeamId:        post.TeamID,
+		PostId:        post.PostID,
+		Message:       fmt.Sprintf("%s liked your post", actorName),
+	}
+}
+
+func mentionNotifications(base companylib.Notification, mentions []Mention, where string) []companylib.Notification {
+	notifications := make([]companylib.Notification, 0, len(mentions))
+	for _, m := range mentions {
+		n := base
+		n.UserName = m.UserID
+		n.Type = companylib.NotificationTypeMention
+		n.Message = fmt.Sprintf("%s mentioned you in %s", base.ActorName, where)
+		notifications = append(notifications, n)
+	}
+	return notifications
+}
+
+// actorName returns the display name of userName, falling back to the user name itself
+func (svc *Service) actorName(userName string) string {
+	employee, err := svc.empSVC.GetEmployeeDataByUserName(userName)
+	if err != nil || strings.TrimSpace(employee.FirstName+" "+employee.LastName) == "" {
+		return userName
+	}
+	return employee.FirstName + " " + employee.LastName
+}
+
+// ==================== Email Digest (scheduled) ====================
+
+// DigestRunSummary is returned by the scheduled digest run and written to the logs
+type DigestRunSummary struct {
+	RunAt       string `json:"runAt"`
+	Subscribers int    `json:"subscribers"`
+	Sent        int    `json:"sent"`
+	Skipped     int    `json:"skipped"`
+	Failed      int    `json:"failed"`
+}
+
+// HandleDigestSchedule emails each digest subscriber their unread notifications since the last
+// digest. Subscribers with nothing new are skipped.
+func (svc *Service) HandleDigestSchedule(ctx context.Context, event events.CloudWatchEvent) (DigestRunSummary, error) {
+	summary := DigestRunSummary{RunAt: time.Now().UTC().Format(time.RFC3339)}
+
+	subscribers, err := svc.notifSVC.ListDigestSubscribers()
+	if err != nil {
+		return summary, err
+	}
+	summary.Subscribers = len(subscribers)
+
+	inboxURL := strings.TrimSuffix(svc.appBaseURL, "/") + "/notifications"
+	for _, prefs := range subscribers {
+		sent, err := svc.notifSVC.SendNotificationDigest(prefs, inboxURL)
+		switch {
+		case err != nil:
+			svc.logger.Printf("Failed to send notification digest to %s: %v", prefs.UserName, err)
+			summary.Failed++
+		case sent:
+			summary.Sent++
+		default:
+			summary.Skipped++
+		}
+	}
+
+	svc.logger.Printf("Notification digest run complete: %+v", summary)
+	return summary, nil
+}
@@ -0,0 +1,122 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	companylib "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/company-lib"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected []string
+	}{
+		{
+			name:     "It should read @email mentions in order without duplicates",
+			text:     "Thanks @Jane@Example.com and @sam@example.com, cc @jane@example.com",
+			expected: []string{"jane@example.com", "sam@example.com"},
+		},
+		{
+			name:     "It should ignore plain email addresses",
+			text:     "Send it to jane@example.com",
+			expected: nil,
+		},
+		{
+			name:     "It should stop at trailing punctuation",
+			text:     "(@sam@example.com).",
+			expected: []string{"sam@example.com"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, parseMentions(test.text))
+		})
+	}
+}
+
+func TestMatchMentions(t *testing.T) {
+	members := []companylib.TeamMember{
+		{UserName: "jane@example.com", DisplayName: "Jane Smith", IsActive: true},
+		{UserName: "sam@example.com", DisplayName: "Sam Lee", IsActive: true},
+		{UserName: "old@example.com", DisplayName: "Former Member", IsActive: false},
+	}
+
+	t.Run("It should keep active members and drop unknown inline mentions", func(t *testing.T) {
+		mentions, err := matchMentions(members, []string{"old@example.com", "jane@example.com", "who@example.com"}, []string{"Sam@example.com"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []Mention{
+			{UserID: "sam@example.com", Name: "Sam Lee"},
+			{UserID: "jane@example.com", Name: "Jane Smith"},
+		}, mentions)
+	})
+
+	t.Run("It should reject explicit mentions of non-members", func(t *testing.T) {
+		_, err := matchMentions(members, nil, []string{"old@example.com"})
+
+		assert.ErrorIs(t, err, errInvalidMentions)
+	})
+}
+
+func TestFindActiveMember(t *testing.T) {
+	members := []companylib.TeamMember{
+		{UserName: "jane@example.com", IsActive: true},
+		{UserName: "old@example.com", IsActive: false},
+	}
+
+	t.Run("It should match an active member whatever the case", func(t *testing.T) {
+		member, ok := findActiveMember(members, " Jane@Example.com")
+
+		assert.True(t, ok)
+		assert.Equal(t, "jane@example.com", member.UserName)
+	})
+
+	t.Run("It should not match former or unknown members", func(t *testing.T) {
+		_, ok := findActiveMember(members, "old@example.com")
+		assert.False(t, ok)
+
+		_, ok = findActiveMember(members, "who@example.com")
+		assert.False(t, ok)
+	})
+}
+
+func TestPostNotifications(t *testing.T) {
+	post := PostRecord{
+		PostID: "p1", TeamID: "team-1", Type: string(PostTypeTask),
+		AuthorUserID: "jane@example.com", AuthorName: "Jane Smith",
+		Data: PostData{TaskNumber: "TASK-ABC123", TaskSummary: "Fix export", AssigneeUserID: "sam@example.com"},
+	}
+
+	notifications := postNotifications(post, []Mention{{UserID: "sam@example.com"}, {UserID: "alex@example.com"}})
+
+	assert.Len(t, notifications, 3)
+	assert.Equal(t, companylib.NotificationTypeTaskAssigned, notifications[0].Type, "the assignment should take precedence over a mention")
+	assert.Equal(t, "Jane Smith assigned you TASK-ABC123: Fix export", notifications[0].Message)
+	assert.Equal(t, "alex@example.com", notifications[2].UserName)
+	assert.Equal(t, "Jane Smith mentioned you in a post", notifications[2].Message)
+}
+
+func TestCommentNotifications(t *testing.T) {
+	post := PostRecord{PostID: "p1", TeamID: "team-1", AuthorUserID: "jane@example.com"}
+	comment := CommentRecord{CommentID: "c1", AuthorUserID: "sam@example.com", AuthorName: "Sam Lee"}
+
+	notifications := commentNotifications(post, comment, []Mention{{UserID: "jane@example.com"}})
+
+	assert.Len(t, notifications, 2)
+	assert.Equal(t, companylib.NotificationTypeMention, notifications[0].Type, "a mention should take precedence over the comment notice")
+	assert.Equal(t, companylib.NotificationTypeComment, notifications[1].Type)
+	assert.Equal(t, "c1", notifications[1].CommentId)
+}
+
+func TestAddedMentions(t *testing.T) {
+	added := addedMentions(
+		[]Mention{{UserID: "jane@example.com"}},
+		[]Mention{{UserID: "jane@example.com"}, {UserID: "sam@example.com"}},
+	)
+
+	assert.Equal(t, []Mention{{UserID: "sam@example.com"}}, added)
+}
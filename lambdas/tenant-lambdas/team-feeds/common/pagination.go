@@ -0,0 +1,209 @@
+package common
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ==================== Cursor Pagination ====================
+//
+// List endpoints return an opaque `nextCursor` in meta. Passing it back as `?cursor=` resumes the
+// query from DynamoDB's ExclusiveStartKey, so each page reads only what it returns instead of the
+// whole partition.
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+
+	// maxPageQueries bounds the DynamoDB calls spent filling one filtered page. A sparse filter
+	// returns a short page with a cursor rather than reading the whole partition.
+	maxPageQueries = 10
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// Key attributes captured in cursors
+var (
+	tableKeyAttrs = []string{"PK", "SK"}
+	gsi1KeyAttrs  = []string{"PK", "SK", "GSI1PK", "GSI1SK"}
+)
+
+// queryClient is the part of the DynamoDB client the pagination helpers use
+type queryClient interface {
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+}
+
+// pageQuery describes one paginated Query. partitionAttr/partitionValue pin cursors to the
+// partition being listed so a cursor from another team or post is rejected.
+type pageQuery struct {
+	input          *dynamodb.QueryInput
+	keyAttrs       []string
+	partitionAttr  string
+	partitionValue string
+}
+
+// pageLimit reads ?limit=, capped at maxPageLimit
+func pageLimit(params map[string]string, def int) int {
+	limit := queryInt(params, "limit", def)
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+	return limit
+}
+
+// queryPage returns up to limit items starting after cursor, and the cursor for the next page
+// ("" when there are no more items). When the query has a FilterExpression it keeps reading
+// until the page is full, the partition is exhausted or maxPageQueries is reached.
+func (svc *Service) queryPage(q pageQuery, limit int, cursor string) ([]map[string]types.AttributeValue, string, error) {
+	return queryPageItems(svc.ctx, svc.ddb, q, limit, cursor)
+}
+
+func queryPageItems(ctx context.Context, ddb queryClient, q pageQuery, limit int, cursor string) ([]map[string]types.AttributeValue, string, error) {
+	startKey, err := decodeCursor(cursor, q.partitionAttr, q.partitionValue)
+	if err != nil {
+		return nil, "", err
+	}
+
+	input := *q.input
+	input.ExclusiveStartKey = startKey
+
+	items := make([]map[string]types.AttributeValue, 0, limit)
+	for i := 0; i < maxPageQueries; i++ {
+		input.Limit = aws.Int32(int32(limit - len(items)))
+		if input.FilterExpression != nil {
+			// Limit applies before the filter, so read a full page each time and trim below
+			input.Limit = aws.Int32(int32(limit))
+		}
+
+		result, err := ddb.Query(ctx, &input)
+		if err != nil {
+			return nil, "", err
+		}
+		items = append(items, result.Items...)
+
+		if len(items) > limit {
+			// The page filled part way through this read; resume after the last item kept
+			items = items[:limit]
+			next, err := encodeCursor(keyFromItem(items[limit-1], q.keyAttrs))
+			return items, next, err
+		}
+		if len(result.LastEvaluatedKey) == 0 {
+			return items, "", nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+		if len(items) == limit {
+			break
+		}
+	}
+
+	next, err := encodeCursor(input.ExclusiveStartKey)
+	return items, next, err
+}
+
+// queryAll reads every page of a query. Used where the full result is needed, e.g. a post's
+// comments on the post detail view.
+func (svc *Service) queryAll(input *dynamodb.QueryInput) ([]map[string]types.AttributeValue, error) {
+	return queryAllItems(svc.ctx, svc.ddb, input)
+}
+
+func queryAllItems(ctx context.Context, ddb queryClient, input *dynamodb.QueryInput) ([]map[string]types.AttributeValue, error) {
+	paged := *input
+	var items []map[string]types.AttributeValue
+	for {
+		result, err := ddb.Query(ctx, &paged)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, result.Items...)
+		if len(result.LastEvaluatedKey) == 0 {
+			return items, nil
+		}
+		paged.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+// queryCount counts the items a query (with its filter) matches, across all pages. List
+// endpoints report it as meta.total alongside the page they return.
+func (svc *Service) queryCount(input *dynamodb.QueryInput) (int, error) {
+	return countQueryItems(svc.ctx, svc.ddb, input)
+}
+
+func countQueryItems(ctx context.Context, ddb queryClient, input *dynamodb.QueryInput) (int, error) {
+	paged := *input
+	paged.Select = types.SelectCount
+	paged.Limit = nil
+	paged.ExclusiveStartKey = nil
+	count := 0
+	for {
+		result, err := ddb.Query(ctx, &paged)
+		if err != nil {
+			return 0, err
+		}
+		count += int(result.Count)
+		if len(result.LastEvaluatedKey) == 0 {
+			return count, nil
+		}
+		paged.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+func keyFromItem(item map[string]types.AttributeValue, attrs []string) map[string]types.AttributeValue {
+	key := make(map[string]types.AttributeValue, len(attrs))
+	for _, attr := range attrs {
+		if v, ok := item[attr]; ok {
+			key[attr] = v
+		}
+	}
+	return key
+}
+
+// encodeCursor serialises a DynamoDB key as URL-safe base64 JSON. Feed keys are all strings.
+func encodeCursor(key map[string]types.AttributeValue) (string, error) {
+	if len(key) == 0 {
+		return "", nil
+	}
+	plain := make(map[string]string, len(key))
+	for name, v := range key {
+		s, ok := v.(*types.AttributeValueMemberS)
+		if !ok {
+			return "", fmt.Errorf("cursor key %s is not a string attribute", name)
+		}
+		plain[name] = s.Value
+	}
+	raw, err := json.Marshal(plain)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// decodeCursor parses a cursor from encodeCursor and checks it belongs to the expected partition
+func decodeCursor(cursor, partitionAttr, partitionValue string) (map[string]types.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var plain map[string]string
+	if err := json.Unmarshal(raw, &plain); err != nil || len(plain) == 0 {
+		return nil, errInvalidCursor
+	}
+	if plain[partitionAttr] != partitionValue {
+		return nil, errInvalidCursor
+	}
+
+	key := make(map[string]types.AttributeValue, len(plain))
+	for name, v := range plain {
+		key[name] = &types.AttributeValueMemberS{Value: v}
+	}
+	return key, nil
+}
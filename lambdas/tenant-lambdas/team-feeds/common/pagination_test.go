@@ -0,0 +1,237 @@
+package common
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	awsclients "github.com/busyfit-admin/saas-integrated-apis/lambdas/lib/clients"
+	"github.com/stretchr/testify/assert"
+)
+
+func feedKey(pk, sk string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: pk},
+		"SK": &types.AttributeValueMemberS{Value: sk},
+	}
+}
+
+func feedItems(pk string, sks ...string) []map[string]types.AttributeValue {
+	items := make([]map[string]types.AttributeValue, 0, len(sks))
+	for _, sk := range sks {
+		items = append(items, feedKey(pk, sk))
+	}
+	return items
+}
+
+func mustCursor(t *testing.T, key map[string]types.AttributeValue) string {
+	cursor, err := encodeCursor(key)
+	assert.NoError(t, err)
+	return cursor
+}
+
+func TestDecodeCursor(t *testing.T) {
+	valid := mustCursor(t, feedKey("TEAM#1", "POST#2026-02-01#p1"))
+
+	tests := []struct {
+		name        string
+		cursor      string
+		expectedKey map[string]types.AttributeValue
+		expectedErr error
+	}{
+		{name: "It should start from the beginning without a cursor", cursor: ""},
+		{name: "It should decode a cursor from the same partition", cursor: valid, expectedKey: feedKey("TEAM#1", "POST#2026-02-01#p1")},
+		{name: "It should reject a cursor that is not base64", cursor: "not a cursor!", expectedErr: errInvalidCursor},
+		{name: "It should reject a tampered cursor", cursor: valid[:len(valid)-4], expectedErr: errInvalidCursor},
+		{name: "It should reject a cursor without a key", cursor: base64.RawURLEncoding.EncodeToString([]byte(`{}`)), expectedErr: errInvalidCursor},
+		{name: "It should reject a cursor from another partition", cursor: mustCursor(t, feedKey("TEAM#2", "POST#2026-02-01#p9")), expectedErr: errInvalidCursor},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key, err := decodeCursor(test.cursor, "PK", "TEAM#1")
+
+			if test.expectedErr != nil {
+				assert.ErrorIs(t, err, test.expectedErr)
+				assert.Nil(t, key)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedKey, key)
+		})
+	}
+}
+
+func TestEncodeCursor(t *testing.T) {
+	t.Run("It should return no cursor for an empty key", func(t *testing.T) {
+		cursor, err := encodeCursor(nil)
+
+		assert.NoError(t, err)
+		assert.Empty(t, cursor)
+	})
+
+	t.Run("It should reject keys that are not strings", func(t *testing.T) {
+		_, err := encodeCursor(map[string]types.AttributeValue{"PK": &types.AttributeValueMemberN{Value: "1"}})
+
+		assert.Error(t, err)
+	})
+}
+
+func TestQueryPage(t *testing.T) {
+	query := func(filtered bool) pageQuery {
+		input := &dynamodb.QueryInput{
+			TableName:              aws.String("TeamFeedTable-test"),
+			KeyConditionExpression: aws.String("PK = :pk"),
+		}
+		if filtered {
+			input.FilterExpression = aws.String("#type = :type")
+		}
+		return pageQuery{input: input, keyAttrs: tableKeyAttrs, partitionAttr: "PK", partitionValue: "TEAM#1"}
+	}
+
+	tests := []struct {
+		name           string
+		filtered       bool
+		limit          int
+		cursor         string
+		outputs        []dynamodb.QueryOutput
+		expectedSKs    []string
+		expectedCursor map[string]types.AttributeValue
+		expectedLimits []int32
+		expectedStart  map[string]types.AttributeValue
+		expectedErr    error
+	}{
+		{
+			name:           "It should return the last page without a cursor",
+			limit:          3,
+			outputs:        []dynamodb.QueryOutput{{Items: feedItems("TEAM#1", "s1", "s2")}},
+			expectedSKs:    []string{"s1", "s2"},
+			expectedLimits: []int32{3},
+		},
+		{
+			name:           "It should return the next cursor for a full page",
+			limit:          2,
+			outputs:        []dynamodb.QueryOutput{{Items: feedItems("TEAM#1", "s1", "s2"), LastEvaluatedKey: feedKey("TEAM#1", "s2")}},
+			expectedSKs:    []string{"s1", "s2"},
+			expectedCursor: feedKey("TEAM#1", "s2"),
+			expectedLimits: []int32{2},
+		},
+		{
+			name:   "It should resume a page from its cursor",
+			limit:  2,
+			cursor: mustCursor(t, feedKey("TEAM#1", "s2")),
+			outputs: []dynamodb.QueryOutput{
+				{Items: feedItems("TEAM#1", "s3")},
+			},
+			expectedSKs:    []string{"s3"},
+			expectedLimits: []int32{2},
+			expectedStart:  feedKey("TEAM#1", "s2"),
+		},
+		{
+			name:        "It should reject a cursor from another team without querying",
+			limit:       2,
+			cursor:      mustCursor(t, feedKey("TEAM#2", "s2")),
+			expectedErr: errInvalidCursor,
+		},
+		{
+			name:     "It should keep reading a filtered query until the page is full",
+			filtered: true,
+			limit:    2,
+			outputs: []dynamodb.QueryOutput{
+				{Items: feedItems("TEAM#1", "s1"), LastEvaluatedKey: feedKey("TEAM#1", "s4")},
+				{Items: feedItems("TEAM#1", "s6", "s7"), LastEvaluatedKey: feedKey("TEAM#1", "s8")},
+			},
+			expectedSKs: []string{"s1", "s6"},
+			// The page filled part way through the second read, so the next page starts after s6
+			expectedCursor: feedKey("TEAM#1", "s6"),
+			expectedLimits: []int32{2, 2},
+		},
+		{
+			name:     "It should return a short filtered page with a cursor once the read budget is spent",
+			filtered: true,
+			limit:    3,
+			outputs: func() []dynamodb.QueryOutput {
+				outputs := make([]dynamodb.QueryOutput, maxPageQueries)
+				for i := range outputs {
+					outputs[i] = dynamodb.QueryOutput{LastEvaluatedKey: feedKey("TEAM#1", "s"+string(rune('a'+i)))}
+				}
+				outputs[0].Items = feedItems("TEAM#1", "s0")
+				return outputs
+			}(),
+			expectedSKs:    []string{"s0"},
+			expectedCursor: feedKey("TEAM#1", "sj"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ddbClient := &awsclients.MockDynamodbClient{
+				QueryOutputs: test.outputs,
+				QueryErrors:  make([]error, len(test.outputs)),
+			}
+
+			items, next, err := queryPageItems(context.TODO(), ddbClient, query(test.filtered), test.limit, test.cursor)
+
+			if test.expectedErr != nil {
+				assert.ErrorIs(t, err, test.expectedErr)
+				assert.Empty(t, ddbClient.QueryInputs)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, ddbClient.QueryInputs, len(test.outputs))
+
+			sks := make([]string, 0, len(items))
+			for _, item := range items {
+				sks = append(sks, item["SK"].(*types.AttributeValueMemberS).Value)
+			}
+			assert.Equal(t, test.expectedSKs, sks)
+
+			if test.expectedCursor == nil {
+				assert.Empty(t, next)
+			} else {
+				assert.Equal(t, mustCursor(t, test.expectedCursor), next)
+			}
+
+			if test.expectedLimits != nil {
+				limits := make([]int32, 0, len(ddbClient.QueryInputs))
+				for _, input := range ddbClient.QueryInputs {
+					limits = append(limits, aws.ToInt32(input.Limit))
+				}
+				assert.Equal(t, test.expectedLimits, limits)
+			}
+			assert.Equal(t, test.expectedStart, ddbClient.QueryInputs[0].ExclusiveStartKey)
+		})
+	}
+}
+
+func TestCountQueryItems(t *testing.T) {
+	t.Run("It should count every page of a filtered query", func(t *testing.T) {
+		ddbClient := &awsclients.MockDynamodbClient{
+			QueryOutputs: []dynamodb.QueryOutput{
+				{Count: 3, LastEvaluatedKey: feedKey("TEAM#1", "s3")},
+				{Count: 2},
+			},
+			QueryErrors: make([]error, 2),
+		}
+		input := &dynamodb.QueryInput{
+			TableName:              aws.String("TeamFeedTable-test"),
+			KeyConditionExpression: aws.String("PK = :pk"),
+			FilterExpression:       aws.String("#type = :type"),
+			Limit:                  aws.Int32(20),
+		}
+
+		count, err := countQueryItems(context.TODO(), ddbClient, input)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 5, count)
+		assert.Len(t, ddbClient.QueryInputs, 2)
+		assert.Equal(t, types.SelectCount, ddbClient.QueryInputs[0].Select)
+		assert.Nil(t, ddbClient.QueryInputs[0].Limit)
+		assert.Equal(t, input.FilterExpression, ddbClient.QueryInputs[0].FilterExpression)
+		assert.Equal(t, feedKey("TEAM#1", "s3"), ddbClient.QueryInputs[1].ExclusiveStartKey)
+		assert.Equal(t, types.Select(""), input.Select)
+	})
+}
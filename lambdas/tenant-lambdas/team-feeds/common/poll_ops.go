@@ -176,5 +176,5 @@ func (svc *Service) getPollResults(postID, userName string) (events.APIGatewayPr
 		"options":           opts,
 		"totalVotes":        totalVotes,
 		"userVotedOptionId": userVotedStr,
-	}, &MetaResponse{Total: totalVotes, Page: 1, Limit: totalVotes + 1})
+	}, &MetaResponse{Total: &totalVotes, Count: totalVotes, Page: 1, Limit: totalVotes + 1})
 }
@@ -0,0 +1,427 @@
+package common
+
+import (
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// ==================== Feed Search ====================
+//
+// Posts and comments are indexed as documents of terms: keyword tokens from their text plus facet
+// terms for the post's author, type, tags and task status. A search is the intersection of the
+// posts matching every query term, newest first. Comment documents only contribute keywords, so
+// facets always describe the post itself.
+
+const (
+	searchDocPost      = "POST"
+	maxTermsPerDoc     = 200
+	maxSearchTermRunes = 64
+
+	// maxTermPostings bounds the postings read for one term per search. Postings are read newest
+	// first, so a common term limits the search to its newest matches and the page's cursor moves
+	// the window on.
+	maxTermPostings = 1000
+)
+
+var errInvalidSearch = errors.New("invalid search")
+
+var searchStopWords = map[string]struct{}{
+	"a": {}, "an": {}, "and": {}, "are": {}, "as": {}, "at": {}, "be": {}, "by": {}, "for": {},
+	"from": {}, "in": {}, "is": {}, "it": {}, "of": {}, "on": {}, "or": {}, "that": {}, "the": {},
+	"this": {}, "to": {}, "was": {}, "with": {},
+}
+
+// SearchDocument is the indexed form of one post or comment. DocID is "POST" for the post itself
+// and the comment ID for comments; CreatedAt is always the post's creation time.
+type SearchDocument struct {
+	TeamID    string
+	PostID    string
+	DocID     string
+	CreatedAt string
+	Terms     []string
+}
+
+// SearchQuery is a feed search within one team. From/To bound the post's createdAt (inclusive).
+type SearchQuery struct {
+	TeamID       string
+	Text         string
+	AuthorUserID string
+	TagType      string
+	TagRefID     string
+	PostType     string
+	TaskStatus   string
+	From         string
+	To           string
+	Limit        int
+	Cursor       string
+}
+
+type SearchHit struct {
+	PostID    string
+	CreatedAt string
+}
+
+type SearchResult struct {
+	Hits       []SearchHit
+	NextCursor string
+}
+
+// SearchBackend stores the feed search index. The DynamoDB backend is used by the Lambdas; the
+// in-memory backend serves tests and local runs.
+type SearchBackend interface {
+	// IndexDocument replaces the indexed terms of a document
+	IndexDocument(doc SearchDocument) error
+	// RemoveDocument drops one document (e.g. a deleted comment)
+	RemoveDocument(postID, docID string) error
+	// RemovePost drops a post and all of its comment documents
+	RemovePost(postID string) error
+	Search(query SearchQuery) (*SearchResult, error)
+}
+
+// ==================== Terms ====================
+
+// searchTokens lower-cases text and splits it into unique keyword terms, dropping stop words and
+// single characters
+func searchTokens(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	seen := make(map[string]struct{}, len(fields))
+	tokens := make([]string, 0, len(fields))
+	for _, f := range fields {
+		runes := []rune(f)
+		if len(runes) < 2 {
+			continue
+		}
+		if len(runes) > maxSearchTermRunes {
+			f = string(runes[:maxSearchTermRunes])
+		}
+		if _, stop := searchStopWords[f]; stop {
+			continue
+		}
+		if _, dup := seen[f]; dup {
+			continue
+		}
+		seen[f] = struct{}{}
+		tokens = append(tokens, f)
+	}
+	return tokens
+}
+
+// Facet terms contain ':', which searchTokens never produces
+func authorTerm(userID string) string     { return "author:" + strings.ToLower(userID) }
+func postTypeTerm(postType string) string { return "type:" + strings.ToLower(postType) }
+func tagTypeTerm(tagType string) string   { return "tag:" + strings.ToLower(tagType) }
+func taskStatusTerm(status string) string { return "status:" + strings.ToLower(status) }
+func tagRefTerm(tagType, refID string) string {
+	return "tag:" + strings.ToLower(tagType) + ":" + refID
+}
+
+// postSearchDocument builds the index document for a post
+func postSearchDocument(r PostRecord) SearchDocument {
+	text := []string{
+		r.Content, r.AuthorName,
+		r.Data.KudosRecipientName,
+		r.Data.TaskNumber, r.Data.TaskSummary, r.Data.TaskDesc, r.Data.AssigneeName,
+		r.Data.PollQuestion,
+		r.Data.ChecklistTitle,
+		r.Data.EventTitle, r.Data.Location,
+	}
+	for _, o := range r.Data.PollOptions {
+		text = append(text, o.Text)
+	}
+	for _, t := range r.Tags {
+		text = append(text, t.Name)
+	}
+
+	terms := searchTokens(strings.Join(text, " "))
+	terms = append(terms, authorTerm(r.AuthorUserID), postTypeTerm(r.Type))
+	for _, t := range r.Tags {
+		if t.Type == "" {
+			continue
+		}
+		terms = append(terms, tagTypeTerm(t.Type))
+		if t.RefID != "" {
+			terms = append(terms, tagRefTerm(t.Type, t.RefID))
+		}
+	}
+	if PostType(r.Type) == PostTypeTask && r.Data.TaskStatus != "" {
+		terms = append(terms, taskStatusTerm(r.Data.TaskStatus))
+	}
+
+	return SearchDocument{
+		TeamID:    r.TeamID,
+		PostID:    r.PostID,
+		DocID:     searchDocPost,
+		CreatedAt: r.CreatedAt,
+		Terms:     capTerms(uniqueTerms(terms)),
+	}
+}
+
+// commentSearchDocument builds the index document for a comment on post
+func commentSearchDocument(c CommentRecord, post PostRecord) SearchDocument {
+	return SearchDocument{
+		TeamID:    post.TeamID,
+		PostID:    post.PostID,
+		DocID:     c.CommentID,
+		CreatedAt: post.CreatedAt,
+		Terms:     capTerms(searchTokens(c.Content + " " + c.AuthorName)),
+	}
+}
+
+// terms returns every term a matching post must have
+func (q SearchQuery) terms() []string {
+	terms := searchTokens(q.Text)
+	if q.AuthorUserID != "" {
+		terms = append(terms, authorTerm(q.AuthorUserID))
+	}
+	if q.PostType != "" {
+		terms = append(terms, postTypeTerm(q.PostType))
+	}
+	if q.TagType != "" {
+		if q.TagRefID != "" {
+			terms = append(terms, tagRefTerm(q.TagType, q.TagRefID))
+		} else {
+			terms = append(terms, tagTypeTerm(q.TagType))
+		}
+	}
+	if q.TaskStatus != "" {
+		terms = append(terms, taskStatusTerm(q.TaskStatus))
+	}
+	return uniqueTerms(terms)
+}
+
+func uniqueTerms(terms []string) []string {
+	seen := make(map[string]struct{}, len(terms))
+	out := make([]string, 0, len(terms))
+	for _, t := range terms {
+		if _, ok := seen[t]; ok {
+			continue
+		}
+		seen[t] = struct{}{}
+		out = append(out, t)
+	}
+	return out
+}
+
+func capTerms(terms []string) []string {
+	if len(terms) > maxTermsPerDoc {
+		return terms[:maxTermsPerDoc]
+	}
+	return terms
+}
+
+// diffTerms returns the terms only in next (to add) and only in prev (to remove)
+func diffTerms(prev, next []string) (added, removed []string) {
+	prevSet := make(map[string]struct{}, len(prev))
+	for _, t := range prev {
+		prevSet[t] = struct{}{}
+	}
+	nextSet := make(map[string]struct{}, len(next))
+	for _, t := range next {
+		nextSet[t] = struct{}{}
+		if _, ok := prevSet[t]; !ok {
+			added = append(added, t)
+		}
+	}
+	for _, t := range prev {
+		if _, ok := nextSet[t]; !ok {
+			removed = append(removed, t)
+		}
+	}
+	return added, removed
+}
+
+// ==================== Date Range ====================
+
+// searchDateBound normalises a from/to parameter (YYYY-MM-DD or RFC3339) to a UTC RFC3339
+// timestamp. Whole days are inclusive: a date-only "to" covers the end of that day.
+func searchDateBound(value string, endOfDay bool) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	if day, err := time.Parse("2006-01-02", value); err == nil {
+		if endOfDay {
+			day = day.Add(24*time.Hour - time.Second)
+		}
+		return day.UTC().Format(time.RFC3339), nil
+	}
+	ts, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return "", errInvalidSearch
+	}
+	return ts.UTC().Format(time.RFC3339), nil
+}
+
+// inSearchRange compares createdAt (RFC3339 UTC) against normalised bounds
+func inSearchRange(createdAt, from, to string) bool {
+	if from != "" && createdAt < from {
+		return false
+	}
+	if to != "" && createdAt > to {
+		return false
+	}
+	return true
+}
+
+// ==================== Ranking ====================
+
+// decodeSearchCursor returns the hit key a page starts below, or "" for the first page
+func decodeSearchCursor(cursor string) (string, error) {
+	if cursor == "" {
+		return "", nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", errInvalidCursor
+	}
+	return string(raw), nil
+}
+
+// rankSearchHits intersects the posts matched by each term (postID -> createdAt) and returns one
+// page, newest first. The cursor is the last hit of the previous page. floor is the hit key of the
+// oldest posting read for a term whose postings were cut off at maxTermPostings ("" if none were):
+// only posts from the floor up are known to match every term, so older posts are left for the
+// next page, which starts below the floor.
+func rankSearchHits(matches []map[string]string, limit int, cursor, floor string) (*SearchResult, error) {
+	after, err := decodeSearchCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SearchResult{Hits: []SearchHit{}}
+	if len(matches) == 0 {
+		return result, nil
+	}
+
+	hits := make([]SearchHit, 0, len(matches[0]))
+	for postID, createdAt := range matches[0] {
+		inAll := true
+		for _, other := range matches[1:] {
+			if _, ok := other[postID]; !ok {
+				inAll = false
+				break
+			}
+		}
+		if !inAll {
+			continue
+		}
+		if after != "" && hitKey(createdAt, postID) >= after {
+			continue
+		}
+		if floor != "" && hitKey(createdAt, postID) < floor {
+			continue
+		}
+		hits = append(hits, SearchHit{PostID: postID, CreatedAt: createdAt})
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		return hitKey(hits[i].CreatedAt, hits[i].PostID) > hitKey(hits[j].CreatedAt, hits[j].PostID)
+	})
+
+	if len(hits) > limit {
+		hits = hits[:limit]
+		last := hits[limit-1]
+		result.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(hitKey(last.CreatedAt, last.PostID)))
+	} else if floor != "" {
+		result.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(floor))
+	}
+	result.Hits = hits
+	return result, nil
+}
+
+func hitKey(createdAt, postID string) string {
+	return createdAt + "#" + postID
+}
+
+// ==================== In-Memory Backend ====================
+
+type memorySearchDoc struct {
+	teamID    string
+	createdAt string
+	terms     []string
+}
+
+// InMemorySearchBackend keeps the index in process memory. It is safe for concurrent use.
+type InMemorySearchBackend struct {
+	mu   sync.RWMutex
+	docs map[string]map[string]memorySearchDoc // postID -> docID -> doc
+}
+
+func NewInMemorySearchBackend() *InMemorySearchBackend {
+	return &InMemorySearchBackend{docs: make(map[string]map[string]memorySearchDoc)}
+}
+
+func (b *InMemorySearchBackend) IndexDocument(doc SearchDocument) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.docs[doc.PostID] == nil {
+		b.docs[doc.PostID] = make(map[string]memorySearchDoc)
+	}
+	b.docs[doc.PostID][doc.DocID] = memorySearchDoc{
+		teamID:    doc.TeamID,
+		createdAt: doc.CreatedAt,
+		terms:     append([]string(nil), doc.Terms...),
+	}
+	return nil
+}
+
+func (b *InMemorySearchBackend) RemoveDocument(postID, docID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.docs[postID], docID)
+	return nil
+}
+
+func (b *InMemorySearchBackend) RemovePost(postID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.docs, postID)
+	return nil
+}
+
+func (b *InMemorySearchBackend) Search(query SearchQuery) (*SearchResult, error) {
+	terms := query.terms()
+	if len(terms) == 0 {
+		return nil, errInvalidSearch
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	matches := make([]map[string]string, len(terms))
+	for i, term := range terms {
+		matches[i] = make(map[string]string)
+		for postID, docs := range b.docs {
+			for _, doc := range docs {
+				if doc.teamID != query.TeamID || !inSearchRange(doc.createdAt, query.From, query.To) {
+					continue
+				}
+				if containsTerm(doc.terms, term) {
+					matches[i][postID] = doc.createdAt
+					break
+				}
+			}
+		}
+	}
+
+	return rankSearchHits(matches, query.Limit, query.Cursor, "")
+}
+
+func containsTerm(terms []string, term string) bool {
+	for _, t := range terms {
+		if t == term {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ==================== Search Backfill ====================
+//
+// Posts and comments written before the search index existed never passed through the stream.
+// The backfill job scans TeamFeedTable and indexes them. A run stops before the Lambda times out
+// and returns a cursor; invoke it again with {"cursor": ...} until "done" is true. Indexing is
+// idempotent, so re-running a range, or the whole table, is safe.
+
+const (
+	searchBackfillPageSize = 200
+
+	// searchBackfillMargin is the time left for the last page when a run stops
+	searchBackfillMargin = 30 * time.Second
+)
+
+type SearchBackfillRequest struct {
+	Cursor string `json:"cursor"`
+}
+
+type SearchBackfillSummary struct {
+	Posts      int    `json:"posts"`
+	Comments   int    `json:"comments"`
+	Skipped    int    `json:"skipped"` // Comments whose post no longer exists
+	NextCursor string `json:"nextCursor,omitempty"`
+	Done       bool   `json:"done"`
+}
+
+// HandleSearchBackfill indexes posts and comments from cursor onwards. A failure stops the run and
+// the error names the cursor to resume from.
+func (svc *Service) HandleSearchBackfill(ctx context.Context, req SearchBackfillRequest) (SearchBackfillSummary, error) {
+	summary := SearchBackfillSummary{}
+
+	// Scan cursors span every partition, so they are not pinned to one
+	startKey, err := decodeCursor(req.Cursor, "", "")
+	if err != nil {
+		return summary, err
+	}
+
+	input := &dynamodb.ScanInput{
+		TableName:        aws.String(svc.feedTable),
+		FilterExpression: aws.String("begins_with(PK, :post) AND (SK = :metadata OR begins_with(SK, :comment))"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":post":     &types.AttributeValueMemberS{Value: PrefixPost},
+			":metadata": &types.AttributeValueMemberS{Value: SKMetadata},
+			":comment":  &types.AttributeValueMemberS{Value: SKCommentPrefix},
+		},
+		Limit:             aws.Int32(searchBackfillPageSize),
+		ExclusiveStartKey: startKey,
+	}
+
+	for {
+		cursor, err := encodeCursor(input.ExclusiveStartKey)
+		if err != nil {
+			return summary, err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < searchBackfillMargin {
+			summary.NextCursor = cursor
+			break
+		}
+
+		result, err := svc.ddb.Scan(ctx, input)
+		if err != nil {
+			return summary, fmt.Errorf("failed to scan feed table, resume from cursor %q: %w", cursor, err)
+		}
+		// Comments are indexed with their post's createdAt. A post's metadata item sorts before its
+		// comments, so the post is usually already on the page.
+		posts := make(map[string]*PostRecord)
+		for _, item := range result.Items {
+			if err := svc.backfillSearchItem(item, posts, &summary); err != nil {
+				return summary, fmt.Errorf("resume from cursor %q: %w", cursor, err)
+			}
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			summary.Done = true
+			break
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	svc.logger.Printf("Search backfill run complete: %+v", summary)
+	return summary, nil
+}
+
+// backfillSearchItem indexes one post metadata or comment item
+func (svc *Service) backfillSearchItem(item map[string]types.AttributeValue, posts map[string]*PostRecord, summary *SearchBackfillSummary) error {
+	var key struct {
+		PK string `dynamodbav:"PK"`
+		SK string `dynamodbav:"SK"`
+	}
+	if err := attributevalue.UnmarshalMap(item, &key); err != nil {
+		return fmt.Errorf("failed to unmarshal feed item key: %w", err)
+	}
+	postID := strings.TrimPrefix(key.PK, PrefixPost)
+
+	if key.SK == SKMetadata {
+		var post PostRecord
+		if err := attributevalue.UnmarshalMap(item, &post); err != nil {
+			return fmt.Errorf("failed to unmarshal post %s: %w", postID, err)
+		}
+		if err := svc.search.IndexDocument(postSearchDocument(post)); err != nil {
+			return err
+		}
+		posts[postID] = &post
+		summary.Posts++
+		return nil
+	}
+
+	var comment CommentRecord
+	if err := attributevalue.UnmarshalMap(item, &comment); err != nil {
+		return fmt.Errorf("failed to unmarshal comment on post %s: %w", postID, err)
+	}
+	post, ok := posts[postID]
+	if !ok {
+		var err error
+		post, err = svc.fetchPostRecord(postID)
+		if errors.Is(err, errPostNotFound) {
+			summary.Skipped++
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		posts[postID] = post
+	}
+	if err := svc.search.IndexDocument(commentSearchDocument(comment, *post)); err != nil {
+		return err
+	}
+	summary.Comments++
+	return nil
+}
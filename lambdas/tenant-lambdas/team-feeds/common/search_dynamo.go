@@ -0,0 +1,319 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ==================== DynamoDB Search Backend ====================
+//
+// TeamFeedSearchTable layout:
+//   Term posting :  PK = TEAM#{teamId}#TERM#{term}   SK = {createdAt}#{postId}#{docId}
+//   Document     :  PK = DOC#{postId}                SK = {docId}   (teamId, createdAt, terms)
+//
+// Postings are sorted by the post's createdAt, so date ranges are key conditions. Document items
+// record what was indexed so updates and deletes only touch the postings that changed.
+
+const (
+	searchPrefixDoc  = "DOC#"
+	searchTermInfix  = "#TERM#"
+	batchWriteMax    = 25
+	batchWriteTrials = 5
+)
+
+type searchDocRecord struct {
+	PK        string   `dynamodbav:"PK"`
+	SK        string   `dynamodbav:"SK"`
+	TeamID    string   `dynamodbav:"teamId"`
+	PostID    string   `dynamodbav:"postId"`
+	CreatedAt string   `dynamodbav:"createdAt"`
+	Terms     []string `dynamodbav:"terms"`
+}
+
+type searchPostingRecord struct {
+	PK        string `dynamodbav:"PK"`
+	SK        string `dynamodbav:"SK"`
+	PostID    string `dynamodbav:"postId"`
+	CreatedAt string `dynamodbav:"createdAt"`
+}
+
+type DynamoSearchBackend struct {
+	ctx   context.Context
+	ddb   *dynamodb.Client
+	table string
+}
+
+func NewDynamoSearchBackend(ctx context.Context, ddb *dynamodb.Client, table string) *DynamoSearchBackend {
+	return &DynamoSearchBackend{ctx: ctx, ddb: ddb, table: table}
+}
+
+func termPartitionKey(teamID, term string) string {
+	return PrefixTeam + teamID + searchTermInfix + term
+}
+
+func postingSortKey(createdAt, postID, docID string) string {
+	return createdAt + "#" + postID + "#" + docID
+}
+
+func (b *DynamoSearchBackend) IndexDocument(doc SearchDocument) error {
+	prev, err := b.getDoc(doc.PostID, doc.DocID)
+	if err != nil {
+		return err
+	}
+
+	var writes []types.WriteRequest
+	added, removed := doc.Terms, []string(nil)
+	if prev != nil {
+		if prev.TeamID != doc.TeamID || prev.CreatedAt != doc.CreatedAt {
+			// Posting keys changed; drop everything indexed before
+			writes = append(writes, postingDeletes(prev.TeamID, prev.CreatedAt, doc.PostID, doc.DocID, prev.Terms)...)
+		} else {
+			added, removed = diffTerms(prev.Terms, doc.Terms)
+		}
+	}
+
+	writes = append(writes, postingDeletes(doc.TeamID, doc.CreatedAt, doc.PostID, doc.DocID, removed)...)
+	for _, term := range added {
+		item, err := attributevalue.MarshalMap(searchPostingRecord{
+			PK:        termPartitionKey(doc.TeamID, term),
+			SK:        postingSortKey(doc.CreatedAt, doc.PostID, doc.DocID),
+			PostID:    doc.PostID,
+			CreatedAt: doc.CreatedAt,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to marshal search posting: %w", err)
+		}
+		writes = append(writes, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+	}
+
+	if err := b.batchWrite(writes); err != nil {
+		return err
+	}
+
+	// The document item is written last so a failed run is retried from the old term list
+	item, err := attributevalue.MarshalMap(searchDocRecord{
+		PK:        searchPrefixDoc + doc.PostID,
+		SK:        doc.DocID,
+		TeamID:    doc.TeamID,
+		PostID:    doc.PostID,
+		CreatedAt: doc.CreatedAt,
+		Terms:     doc.Terms,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal search document: %w", err)
+	}
+	_, err = b.ddb.PutItem(b.ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(b.table),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save search document %s/%s: %w", doc.PostID, doc.DocID, err)
+	}
+	return nil
+}
+
+func (b *DynamoSearchBackend) RemoveDocument(postID, docID string) error {
+	prev, err := b.getDoc(postID, docID)
+	if err != nil || prev == nil {
+		return err
+	}
+	return b.removeDoc(*prev)
+}
+
+func (b *DynamoSearchBackend) RemovePost(postID string) error {
+	items, err := queryAllItems(b.ctx, b.ddb, &dynamodb.QueryInput{
+		TableName:              aws.String(b.table),
+		KeyConditionExpression: aws.String("PK = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: searchPrefixDoc + postID},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list search documents for post %s: %w", postID, err)
+	}
+
+	var docs []searchDocRecord
+	if err := attributevalue.UnmarshalListOfMaps(items, &docs); err != nil {
+		return fmt.Errorf("failed to unmarshal search documents: %w", err)
+	}
+	for _, doc := range docs {
+		if err := b.removeDoc(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *DynamoSearchBackend) Search(query SearchQuery) (*SearchResult, error) {
+	terms := query.terms()
+	if len(terms) == 0 {
+		return nil, errInvalidSearch
+	}
+	after, err := decodeSearchCursor(query.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	// Postings are read newest first from the page's upper bound: the cursor, or the end of "to"
+	upper := ""
+	if query.To != "" {
+		// "~" sorts after the "#postId#docId" suffix of any posting at that second
+		upper = query.To + "~"
+	}
+	if after != "" && (upper == "" || after < upper) {
+		upper = after
+	}
+
+	matches := make([]map[string]string, 0, len(terms))
+	floor := ""
+	for _, term := range terms {
+		input := &dynamodb.QueryInput{
+			TableName:              aws.String(b.table),
+			KeyConditionExpression: aws.String("PK = :pk"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":pk": &types.AttributeValueMemberS{Value: termPartitionKey(query.TeamID, term)},
+			},
+			ScanIndexForward: aws.Bool(false),
+		}
+		switch {
+		case query.From != "" && upper != "":
+			input.KeyConditionExpression = aws.String("PK = :pk AND SK BETWEEN :from AND :upper")
+		case query.From != "":
+			input.KeyConditionExpression = aws.String("PK = :pk AND SK >= :from")
+		case upper != "":
+			input.KeyConditionExpression = aws.String("PK = :pk AND SK <= :upper")
+		}
+		if query.From != "" {
+			input.ExpressionAttributeValues[":from"] = &types.AttributeValueMemberS{Value: query.From}
+		}
+		if upper != "" {
+			input.ExpressionAttributeValues[":upper"] = &types.AttributeValueMemberS{Value: upper}
+		}
+
+		postings, truncated, err := b.queryPostings(input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query search term %q: %w", term, err)
+		}
+
+		posts := make(map[string]string, len(postings))
+		for _, p := range postings {
+			posts[p.PostID] = p.CreatedAt
+		}
+		if len(posts) == 0 {
+			// No post can match every term
+			return &SearchResult{Hits: []SearchHit{}}, nil
+		}
+		if truncated {
+			oldest := postings[len(postings)-1]
+			if key := hitKey(oldest.CreatedAt, oldest.PostID); key > floor {
+				floor = key
+			}
+		}
+		matches = append(matches, posts)
+	}
+
+	return rankSearchHits(matches, query.Limit, query.Cursor, floor)
+}
+
+// queryPostings reads up to maxTermPostings postings and reports whether more were left unread
+func (b *DynamoSearchBackend) queryPostings(input *dynamodb.QueryInput) ([]searchPostingRecord, bool, error) {
+	var postings []searchPostingRecord
+	for {
+		input.Limit = aws.Int32(int32(maxTermPostings - len(postings)))
+		result, err := b.ddb.Query(b.ctx, input)
+		if err != nil {
+			return nil, false, err
+		}
+
+		var page []searchPostingRecord
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, false, fmt.Errorf("failed to unmarshal search postings: %w", err)
+		}
+		postings = append(postings, page...)
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return postings, false, nil
+		}
+		if len(postings) >= maxTermPostings {
+			return postings, true, nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+func (b *DynamoSearchBackend) getDoc(postID, docID string) (*searchDocRecord, error) {
+	result, err := b.ddb.GetItem(b.ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(b.table),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: searchPrefixDoc + postID},
+			"SK": &types.AttributeValueMemberS{Value: docID},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get search document %s/%s: %w", postID, docID, err)
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+	var doc searchDocRecord
+	if err := attributevalue.UnmarshalMap(result.Item, &doc); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal search document: %w", err)
+	}
+	return &doc, nil
+}
+
+func (b *DynamoSearchBackend) removeDoc(doc searchDocRecord) error {
+	writes := postingDeletes(doc.TeamID, doc.CreatedAt, doc.PostID, doc.SK, doc.Terms)
+	writes = append(writes, types.WriteRequest{DeleteRequest: &types.DeleteRequest{
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: doc.PK},
+			"SK": &types.AttributeValueMemberS{Value: doc.SK},
+		},
+	}})
+	return b.batchWrite(writes)
+}
+
+func postingDeletes(teamID, createdAt, postID, docID string, terms []string) []types.WriteRequest {
+	writes := make([]types.WriteRequest, 0, len(terms))
+	for _, term := range terms {
+		writes = append(writes, types.WriteRequest{DeleteRequest: &types.DeleteRequest{
+			Key: map[string]types.AttributeValue{
+				"PK": &types.AttributeValueMemberS{Value: termPartitionKey(teamID, term)},
+				"SK": &types.AttributeValueMemberS{Value: postingSortKey(createdAt, postID, docID)},
+			},
+		}})
+	}
+	return writes
+}
+
+// batchWrite writes in chunks of 25 and retries unprocessed items
+func (b *DynamoSearchBackend) batchWrite(writes []types.WriteRequest) error {
+	for start := 0; start < len(writes); start += batchWriteMax {
+		end := start + batchWriteMax
+		if end > len(writes) {
+			end = len(writes)
+		}
+
+		pending := map[string][]types.WriteRequest{b.table: writes[start:end]}
+		for trial := 0; len(pending[b.table]) > 0; trial++ {
+			if trial == batchWriteTrials {
+				return fmt.Errorf("failed to write %d search index items after %d attempts", len(pending[b.table]), trial)
+			}
+			if trial > 0 {
+				time.Sleep(time.Duration(trial) * 100 * time.Millisecond)
+			}
+			result, err := b.ddb.BatchWriteItem(b.ctx, &dynamodb.BatchWriteItemInput{RequestItems: pending})
+			if err != nil {
+				return fmt.Errorf("failed to write search index: %w", err)
+			}
+			pending = result.UnprocessedItems
+		}
+	}
+	return nil
+}
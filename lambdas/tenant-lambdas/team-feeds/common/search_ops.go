@@ -0,0 +1,227 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ==================== Route: Feed Search ====================
+//
+// GET /v2/teams/{teamId}/feed/search?q=&author=&tagType=&tagRefId=&type=&taskStatus=&from=&to=&cursor=&limit=
+
+const batchGetMax = 100
+
+func (svc *Service) searchTeamFeed(teamID, userName string, queryParams map[string]string) (events.APIGatewayProxyResponse, error) {
+	query := SearchQuery{
+		TeamID:       teamID,
+		Text:         queryString(queryParams, "q"),
+		AuthorUserID: queryString(queryParams, "author"),
+		TagType:      queryString(queryParams, "tagType"),
+		TagRefID:     queryString(queryParams, "tagRefId"),
+		PostType:     queryString(queryParams, "type"),
+		TaskStatus:   queryString(queryParams, "taskStatus"),
+		Limit:        pageLimit(queryParams, defaultPageLimit),
+		Cursor:       queryString(queryParams, "cursor"),
+	}
+
+	if query.TagRefID != "" && query.TagType == "" {
+		return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "tagRefId requires tagType")
+	}
+	if len(query.terms()) == 0 {
+		return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "Provide at least one of q, author, tagType, type or taskStatus")
+	}
+
+	var err error
+	if query.From, err = searchDateBound(queryString(queryParams, "from"), false); err != nil {
+		return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "from must be YYYY-MM-DD or RFC3339")
+	}
+	if query.To, err = searchDateBound(queryString(queryParams, "to"), true); err != nil {
+		return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "to must be YYYY-MM-DD or RFC3339")
+	}
+
+	result, err := svc.search.Search(query)
+	if err != nil {
+		if errors.Is(err, errInvalidCursor) {
+			return svc.errResp(http.StatusBadRequest, "VALIDATION_ERROR", "Invalid cursor")
+		}
+		svc.logger.Printf("Error searching feed for team %s: %v", teamID, err)
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to search feed")
+	}
+
+	postIDs := make([]string, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		postIDs = append(postIDs, hit.PostID)
+	}
+	records, err := svc.fetchPostRecords(postIDs)
+	if err != nil {
+		svc.logger.Printf("Error loading search results: %v", err)
+		return svc.errResp(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to search feed")
+	}
+
+	posts := make([]map[string]interface{}, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		// The index is updated from the table stream, so a just-deleted post can still match
+		record, ok := records[hit.PostID]
+		if !ok || record.TeamID != teamID {
+			continue
+		}
+		posts = append(posts, svc.buildPostResponse(record, userName, nil, nil))
+	}
+
+	// The index cannot count matches without reading them all, so search pages carry no total
+	return svc.okResp(posts, &MetaResponse{Count: len(posts), Limit: query.Limit, NextCursor: result.NextCursor})
+}
+
+// fetchPostRecords loads post metadata for postIDs, keyed by post ID. Missing posts are omitted.
+func (svc *Service) fetchPostRecords(postIDs []string) (map[string]PostRecord, error) {
+	records := make(map[string]PostRecord, len(postIDs))
+
+	for start := 0; start < len(postIDs); start += batchGetMax {
+		end := start + batchGetMax
+		if end > len(postIDs) {
+			end = len(postIDs)
+		}
+
+		keys := make([]map[string]types.AttributeValue, 0, end-start)
+		for _, postID := range postIDs[start:end] {
+			keys = append(keys, map[string]types.AttributeValue{
+				"PK": &types.AttributeValueMemberS{Value: PrefixPost + postID},
+				"SK": &types.AttributeValueMemberS{Value: SKMetadata},
+			})
+		}
+
+		pending := map[string]types.KeysAndAttributes{svc.feedTable: {Keys: keys}}
+		for len(pending) > 0 {
+			result, err := svc.ddb.BatchGetItem(svc.ctx, &dynamodb.BatchGetItemInput{RequestItems: pending})
+			if err != nil {
+				return nil, fmt.Errorf("failed to batch get posts: %w", err)
+			}
+
+			var batch []PostRecord
+			if err := attributevalue.UnmarshalListOfMaps(result.Responses[svc.feedTable], &batch); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal posts: %w", err)
+			}
+			for _, r := range batch {
+				records[r.PostID] = r
+			}
+			pending = result.UnprocessedKeys
+		}
+	}
+
+	return records, nil
+}
+
+// ==================== Search Indexer (TeamFeedTable stream) ====================
+
+// HandleFeedStream keeps the search index in step with posts and comments. Failed records are
+// reported individually so the stream retries only those.
+func (svc *Service) HandleFeedStream(ctx context.Context, event events.DynamoDBEvent) (events.DynamoDBEventResponse, error) {
+	response := events.DynamoDBEventResponse{}
+
+	for _, record := range event.Records {
+		if err := svc.indexStreamRecord(record); err != nil {
+			svc.logger.Printf("Failed to index stream record %s: %v", record.EventID, err)
+			response.BatchItemFailures = append(response.BatchItemFailures, events.DynamoDBBatchItemFailure{
+				ItemIdentifier: record.Change.SequenceNumber,
+			})
+		}
+	}
+
+	return response, nil
+}
+
+func (svc *Service) indexStreamRecord(record events.DynamoDBEventRecord) error {
+	pk := record.Change.Keys["PK"].String()
+	sk := record.Change.Keys["SK"].String()
+	if !strings.HasPrefix(pk, PrefixPost) {
+		return nil
+	}
+	postID := strings.TrimPrefix(pk, PrefixPost)
+	removed := record.EventName == string(events.DynamoDBOperationTypeRemove)
+
+	switch {
+	case sk == SKMetadata:
+		if removed {
+			return svc.search.RemovePost(postID)
+		}
+		var post PostRecord
+		if err := attributevalue.UnmarshalMap(fromStreamImage(record.Change.NewImage), &post); err != nil {
+			return fmt.Errorf("failed to unmarshal post %s: %w", postID, err)
+		}
+		return svc.search.IndexDocument(postSearchDocument(post))
+
+	case strings.HasPrefix(sk, SKCommentPrefix):
+		if removed {
+			// SK is CMMNT#{createdAt}#{commentId}
+			return svc.search.RemoveDocument(postID, sk[strings.LastIndex(sk, "#")+1:])
+		}
+		var comment CommentRecord
+		if err := attributevalue.UnmarshalMap(fromStreamImage(record.Change.NewImage), &comment); err != nil {
+			return fmt.Errorf("failed to unmarshal comment on post %s: %w", postID, err)
+		}
+		post, err := svc.fetchPostRecord(postID)
+		if errors.Is(err, errPostNotFound) {
+			// The post was deleted; its documents are removed with it
+			svc.logger.Printf("Skipping comment %s: %v", comment.CommentID, err)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return svc.search.IndexDocument(commentSearchDocument(comment, *post))
+	}
+
+	return nil
+}
+
+// fromStreamImage converts a stream image to SDK attribute values
+func fromStreamImage(image map[string]events.DynamoDBAttributeValue) map[string]types.AttributeValue {
+	out := make(map[string]types.AttributeValue, len(image))
+	for name, v := range image {
+		out[name] = fromStreamValue(v)
+	}
+	return out
+}
+
+func fromStreamValue(v events.DynamoDBAttributeValue) types.AttributeValue {
+	switch v.DataType() {
+	case events.DataTypeString:
+		return &types.AttributeValueMemberS{Value: v.String()}
+	case events.DataTypeNumber:
+		return &types.AttributeValueMemberN{Value: v.Number()}
+	case events.DataTypeBoolean:
+		return &types.AttributeValueMemberBOOL{Value: v.Boolean()}
+	case events.DataTypeBinary:
+		return &types.AttributeValueMemberB{Value: v.Binary()}
+	case events.DataTypeStringSet:
+		return &types.AttributeValueMemberSS{Value: v.StringSet()}
+	case events.DataTypeNumberSet:
+		return &types.AttributeValueMemberNS{Value: v.NumberSet()}
+	case events.DataTypeBinarySet:
+		return &types.AttributeValueMemberBS{Value: v.BinarySet()}
+	case events.DataTypeList:
+		list := make([]types.AttributeValue, 0, len(v.List()))
+		for _, item := range v.List() {
+			list = append(list, fromStreamValue(item))
+		}
+		return &types.AttributeValueMemberL{Value: list}
+	case events.DataTypeMap:
+		return &types.AttributeValueMemberM{Value: fromStreamImage(v.Map())}
+	default:
+		return &types.AttributeValueMemberNULL{Value: true}
+	}
+}
+
+// Compile-time check that both backends satisfy SearchBackend
+var (
+	_ SearchBackend = (*DynamoSearchBackend)(nil)
+	_ SearchBackend = (*InMemorySearchBackend)(nil)
+)
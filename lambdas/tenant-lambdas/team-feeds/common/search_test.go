@@ -0,0 +1,204 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/stretchr/testify/assert"
+)
+
+func indexTestPost(t *testing.T, backend SearchBackend, post PostRecord) {
+	assert.NoError(t, backend.IndexDocument(postSearchDocument(post)))
+}
+
+func searchPostIDs(result *SearchResult) []string {
+	ids := make([]string, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		ids = append(ids, hit.PostID)
+	}
+	return ids
+}
+
+func newTestSearchBackend(t *testing.T) *InMemorySearchBackend {
+	backend := NewInMemorySearchBackend()
+	indexTestPost(t, backend, PostRecord{
+		PostID: "p1", TeamID: "team-1", Type: "update", AuthorUserID: "jane@example.com", AuthorName: "Jane Smith",
+		Content: "Shipped the billing dashboard", CreatedAt: "2026-02-01T09:00:00Z",
+		Tags: []Tag{{Type: "goal", RefID: "goal-99", Name: "Q1 Revenue"}},
+	})
+	indexTestPost(t, backend, PostRecord{
+		PostID: "p2", TeamID: "team-1", Type: "task", AuthorUserID: "sam@example.com", AuthorName: "Sam Lee",
+		CreatedAt: "2026-02-10T09:00:00Z",
+		Data:      PostData{TaskSummary: "Fix billing export", TaskStatus: "in-progress"},
+	})
+	indexTestPost(t, backend, PostRecord{
+		PostID: "p3", TeamID: "team-2", Type: "update", AuthorUserID: "jane@example.com",
+		Content: "Billing for another team", CreatedAt: "2026-02-11T09:00:00Z",
+	})
+	return backend
+}
+
+func TestSearchTokens(t *testing.T) {
+	assert.Equal(t, []string{"shipped", "billing", "dashboard", "v2"}, searchTokens("Shipped the BILLING dashboard, v2 a billing!"))
+	assert.Empty(t, searchTokens("a of the"))
+}
+
+func TestInMemorySearchBackend(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    SearchQuery
+		expected []string
+	}{
+		{
+			name:     "It should match keywords within the team, newest first",
+			query:    SearchQuery{Text: "billing"},
+			expected: []string{"p2", "p1"},
+		},
+		{
+			name:     "It should require every keyword",
+			query:    SearchQuery{Text: "billing dashboard"},
+			expected: []string{"p1"},
+		},
+		{
+			name:     "It should filter by author",
+			query:    SearchQuery{Text: "billing", AuthorUserID: "Jane@example.com"},
+			expected: []string{"p1"},
+		},
+		{
+			name:     "It should filter by tag type and reference",
+			query:    SearchQuery{TagType: "goal", TagRefID: "goal-99"},
+			expected: []string{"p1"},
+		},
+		{
+			name:     "It should filter by task status",
+			query:    SearchQuery{TaskStatus: "in-progress"},
+			expected: []string{"p2"},
+		},
+		{
+			name:     "It should filter by date range",
+			query:    SearchQuery{Text: "billing", From: "2026-02-05T00:00:00Z", To: "2026-02-28T23:59:59Z"},
+			expected: []string{"p2"},
+		},
+	}
+
+	backend := newTestSearchBackend(t)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.query.TeamID = "team-1"
+			test.query.Limit = 20
+
+			result, err := backend.Search(test.query)
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, searchPostIDs(result))
+		})
+	}
+}
+
+func TestInMemorySearchBackendComments(t *testing.T) {
+	backend := newTestSearchBackend(t)
+	post := PostRecord{PostID: "p1", TeamID: "team-1", CreatedAt: "2026-02-01T09:00:00Z"}
+
+	t.Run("It should find a post by its comments", func(t *testing.T) {
+		assert.NoError(t, backend.IndexDocument(commentSearchDocument(CommentRecord{CommentID: "c1", PostID: "p1", Content: "Great rollout"}, post)))
+
+		result, err := backend.Search(SearchQuery{TeamID: "team-1", Text: "rollout", Limit: 20})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"p1"}, searchPostIDs(result))
+	})
+
+	t.Run("It should not apply facets to comment authors", func(t *testing.T) {
+		assert.NoError(t, backend.IndexDocument(commentSearchDocument(CommentRecord{CommentID: "c2", PostID: "p2", AuthorUserID: "jane@example.com", Content: "On it"}, PostRecord{PostID: "p2", TeamID: "team-1", CreatedAt: "2026-02-10T09:00:00Z"})))
+
+		result, err := backend.Search(SearchQuery{TeamID: "team-1", AuthorUserID: "jane@example.com", Limit: 20})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"p1"}, searchPostIDs(result))
+	})
+
+	t.Run("It should drop a post and its comments together", func(t *testing.T) {
+		assert.NoError(t, backend.RemovePost("p1"))
+
+		result, err := backend.Search(SearchQuery{TeamID: "team-1", Text: "rollout", Limit: 20})
+
+		assert.NoError(t, err)
+		assert.Empty(t, result.Hits)
+	})
+}
+
+func TestSearchPagination(t *testing.T) {
+	backend := newTestSearchBackend(t)
+
+	first, err := backend.Search(SearchQuery{TeamID: "team-1", Text: "billing", Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"p2"}, searchPostIDs(first))
+	assert.NotEmpty(t, first.NextCursor)
+
+	second, err := backend.Search(SearchQuery{TeamID: "team-1", Text: "billing", Limit: 1, Cursor: first.NextCursor})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"p1"}, searchPostIDs(second))
+	assert.Empty(t, second.NextCursor)
+}
+
+func TestRankSearchHitsFloor(t *testing.T) {
+	matches := []map[string]string{
+		{"p1": "2026-02-01T09:00:00Z", "p2": "2026-02-10T09:00:00Z", "p3": "2026-02-11T09:00:00Z"},
+		{"p1": "2026-02-01T09:00:00Z", "p2": "2026-02-10T09:00:00Z", "p3": "2026-02-11T09:00:00Z"},
+	}
+	floor := hitKey("2026-02-10T09:00:00Z", "p2")
+
+	t.Run("It should only return posts from the floor of a cut off term and continue below it", func(t *testing.T) {
+		result, err := rankSearchHits(matches, 20, "", floor)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"p3", "p2"}, searchPostIDs(result))
+		assert.NotEmpty(t, result.NextCursor)
+
+		next, err := rankSearchHits(matches, 20, result.NextCursor, "")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"p1"}, searchPostIDs(next))
+		assert.Empty(t, next.NextCursor)
+	})
+
+	t.Run("It should page normally when the page fills above the floor", func(t *testing.T) {
+		result, err := rankSearchHits(matches, 1, "", floor)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"p3"}, searchPostIDs(result))
+
+		next, err := rankSearchHits(matches, 1, result.NextCursor, floor)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"p2"}, searchPostIDs(next))
+		assert.NotEmpty(t, next.NextCursor)
+	})
+}
+
+func TestBackfillSearchItem(t *testing.T) {
+	backend := NewInMemorySearchBackend()
+	svc := &Service{search: backend}
+	summary := SearchBackfillSummary{}
+	posts := make(map[string]*PostRecord)
+
+	postItem, _ := attributevalue.MarshalMap(PostRecord{PK: PrefixPost + "p1", SK: SKMetadata, PostID: "p1", TeamID: "team-1",
+		Type: "update", Content: "Quarterly planning", CreatedAt: "2026-02-01T09:00:00Z"})
+	commentItem, _ := attributevalue.MarshalMap(CommentRecord{PK: PrefixPost + "p1", SK: SKCommentPrefix + "2026-02-02T09:00:00Z#c1",
+		CommentID: "c1", PostID: "p1", Content: "Agenda attached"})
+
+	assert.NoError(t, svc.backfillSearchItem(postItem, posts, &summary))
+	assert.NoError(t, svc.backfillSearchItem(commentItem, posts, &summary))
+
+	result, err := backend.Search(SearchQuery{TeamID: "team-1", Text: "agenda", From: "2026-02-01T00:00:00Z", Limit: 20})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"p1"}, searchPostIDs(result))
+	assert.Equal(t, SearchBackfillSummary{Posts: 1, Comments: 1}, summary)
+}
+
+func TestDiffTerms(t *testing.T) {
+	added, removed := diffTerms([]string{"billing", "status:todo"}, []string{"billing", "status:done"})
+
+	assert.Equal(t, []string{"status:done"}, added)
+	assert.Equal(t, []string{"status:todo"}, removed)
+}
@@ -18,12 +18,14 @@ import (
 // ==================== Route Group Constants ====================
 
 const (
-	RouteGroupPosts     = "posts"
-	RouteGroupLikes     = "likes"
-	RouteGroupComments  = "comments"
-	RouteGroupPoll      = "poll"
-	RouteGroupChecklist = "checklist"
-	RouteGroupTask      = "task"
+	RouteGroupPosts         = "posts"
+	RouteGroupLikes         = "likes"
+	RouteGroupComments      = "comments"
+	RouteGroupPoll          = "poll"
+	RouteGroupChecklist     = "checklist"
+	RouteGroupTask          = "task"
+	RouteGroupNotifications = "notifications"
+	RouteGroupEvents        = "events"
 )
 
 // ==================== Service Struct ====================
@@ -33,8 +35,13 @@ type Service struct {
 	logger    *log.Logger
 	empSVC    *companylib.EmployeeService
 	teamsSVC  *companylib.TeamsServiceV2
+	orgSVC    *companylib.OrgServiceV2
 	ddb       *dynamodb.Client
 	feedTable string
+	search    SearchBackend
+
+	notifSVC   *companylib.NotificationService
+	appBaseURL string
 }
 
 var RESP_HEADERS = companylib.GetHeadersForAPI("TeamFeedsAPI")
@@ -66,12 +73,29 @@ func NewService() (*Service, error) {
 	teamsSvc := companylib.CreateTeamsServiceV2(ctx, ddbClient, logger, empSvc, emailSvc)
 	teamsSvc.TeamsTable = os.Getenv("TEAMS_TABLE")
 
+	orgSvc := companylib.CreateOrgServiceV2(ctx, ddbClient, logger, empSvc, emailSvc)
+	orgSvc.OrganizationTable = os.Getenv("ORGANIZATION_TABLE")
+
+	notifSvc := companylib.CreateNotificationService(ctx, ddbClient, logger, emailSvc)
+	notifSvc.NotificationsTable = os.Getenv("NOTIFICATIONS_TABLE")
+	notifSvc.NotificationsTable_DigestIndex = os.Getenv("NOTIFICATIONS_TABLE_DIGEST_INDEX")
+
+	appBaseURL := os.Getenv("APP_BASE_URL")
+	if appBaseURL == "" {
+		appBaseURL = "https://app.gomovo.com"
+	}
+
 	return &Service{
 		ctx:       ctx,
 		logger:    logger,
 		empSVC:    empSvc,
 		teamsSVC:  teamsSvc,
+		orgSVC:    orgSvc,
 		ddb:       ddbClient,
 		feedTable: os.Getenv("TEAM_FEED_TABLE"),
+		search:    NewDynamoSearchBackend(ctx, ddbClient, os.Getenv("TEAM_FEED_SEARCH_TABLE")),
+
+		notifSVC:   notifSvc,
+		appBaseURL: appBaseURL,
 	}, nil
 }
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"log"
+
+	"github.com/aws/aws-lambda-go/lambda"
+
+	common "github.com/busyfit-admin/saas-integrated-apis/lambdas/tenant-lambdas/team-feeds/common"
+)
+
+// Consumes the TeamFeedTable stream and maintains the feed search index
+func main() {
+	svc, err := common.NewService()
+	if err != nil {
+		log.Fatalf("failed to initialize team-feeds service: %v", err)
+	}
+
+	lambda.Start(svc.HandleFeedStream)
+}
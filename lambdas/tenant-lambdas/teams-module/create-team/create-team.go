@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -134,6 +135,14 @@ func (svc *Service) createTeam(userName string, request events.APIGatewayProxyRe
 		return svc.errorResponse(http.StatusForbidden, "Only organization admins can create teams", nil)
 	}
 
+	// Suspended organizations are read-only
+	if err := svc.orgSVC.EnsureOrgWritable(orgAdmins.OrganizationId); err != nil {
+		if errors.Is(err, companylib.ErrOrgReadOnly) {
+			return svc.errorResponse(http.StatusForbidden, "Organization is read-only", err)
+		}
+		svc.logger.Printf("Could not check billing status for organization %s: %v", orgAdmins.OrganizationId, err)
+	}
+
 	// Set the requesting user as the creator
 	input.UserName = userName
 	input.OrgId = orgAdmins.OrganizationId
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -27,6 +28,7 @@ type Service struct {
 	logger   *log.Logger
 	teamsSVC *companylib.TeamsServiceV2
 	empSVC   *companylib.EmployeeService
+	orgSVC   *companylib.OrgServiceV2
 }
 
 var RESP_HEADERS = companylib.GetHeadersForAPI("TeamsAPI")
@@ -60,11 +62,20 @@ func main() {
 	teamsSvc := companylib.CreateTeamsServiceV2(ctx, ddbclient, logger, empSvc, emailSvc)
 	teamsSvc.TeamsTable = os.Getenv("TEAMS_TABLE")
 
+	// Initialize organization service
+	orgSvc := companylib.CreateOrgServiceV2(ctx, ddbclient, logger, empSvc, emailSvc)
+	orgSvc.OrganizationTable = os.Getenv("ORGANIZATION_TABLE")
+	orgSvc.TeamsTable = os.Getenv("TEAMS_TABLE")
+
+	// New team members take a seat from the organization's plan
+	teamsSvc.OrgService = orgSvc
+
 	svc := &Service{
 		ctx:      ctx,
 		logger:   logger,
 		teamsSVC: teamsSvc,
 		empSVC:   empSvc,
+		orgSVC:   orgSvc,
 	}
 
 	lambda.Start(svc.Handler)
@@ -202,6 +213,13 @@ func (svc *Service) getTeamMembers(teamId string, userName string) (events.APIGa
 func (svc *Service) updateTeamStatus(teamId string, userName string, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	svc.logger.Printf("Updating team status for team: %s by user: %s", teamId, userName)
 
+	// Suspended organizations are read-only
+	if err := svc.orgSVC.EnsureTeamWritable(svc.teamsSVC, teamId); errors.Is(err, companylib.ErrOrgReadOnly) {
+		return svc.errorResponse(http.StatusForbidden, "Organization is read-only", err)
+	} else if err != nil {
+		return svc.errorResponse(http.StatusInternalServerError, "Failed to check organization billing status", err)
+	}
+
 	// Parse request body
 	var input struct {
 		Status companylib.TeamStatus `json:"status"`
@@ -243,6 +261,13 @@ func (svc *Service) updateTeamStatus(teamId string, userName string, request eve
 func (svc *Service) addTeamMembers(teamId string, userName string, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	svc.logger.Printf("Adding members to team: %s by user: %s", teamId, userName)
 
+	// Suspended organizations are read-only
+	if err := svc.orgSVC.EnsureTeamWritable(svc.teamsSVC, teamId); errors.Is(err, companylib.ErrOrgReadOnly) {
+		return svc.errorResponse(http.StatusForbidden, "Organization is read-only", err)
+	} else if err != nil {
+		return svc.errorResponse(http.StatusInternalServerError, "Failed to check organization billing status", err)
+	}
+
 	// Parse request body
 	var input struct {
 		UserNames []string `json:"userNames"`
@@ -272,6 +297,9 @@ func (svc *Service) addTeamMembers(teamId string, userName string, request event
 		if strings.Contains(err.Error(), "inactive team") {
 			return svc.errorResponse(http.StatusBadRequest, "Cannot add members to inactive team", err)
 		}
+		if errors.Is(err, companylib.ErrMemberLimitReached) {
+			return svc.errorResponse(http.StatusPaymentRequired, companylib.MemberLimitErrorCode, err)
+		}
 		return svc.errorResponse(http.StatusInternalServerError, "Failed to add team members", err)
 	}
 
@@ -292,6 +320,13 @@ func (svc *Service) addTeamMembers(teamId string, userName string, request event
 func (svc *Service) updateMemberRole(teamId string, userName string, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	svc.logger.Printf("Updating member role in team: %s by user: %s", teamId, userName)
 
+	// Suspended organizations are read-only
+	if err := svc.orgSVC.EnsureTeamWritable(svc.teamsSVC, teamId); errors.Is(err, companylib.ErrOrgReadOnly) {
+		return svc.errorResponse(http.StatusForbidden, "Organization is read-only", err)
+	} else if err != nil {
+		return svc.errorResponse(http.StatusInternalServerError, "Failed to check organization billing status", err)
+	}
+
 	// Parse request body
 	var input struct {
 		UserName string                    `json:"userName"`